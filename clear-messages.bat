@@ -21,11 +21,13 @@ echo.
 echo Clearing message queue by consuming all messages...
 
 REM Clear messages by consuming them (this effectively clears the queue)
-echo Clearing chat-messages topic...
-timeout /t 2 >nul
-docker exec kafka kafka-console-consumer.sh --bootstrap-server localhost:9092 --topic chat-messages --from-beginning --timeout-ms 1000 >nul 2>&1
+for %%t in (chat-messages chat-responses chat-control) do (
+    echo Clearing %%t topic...
+    timeout /t 2 >nul
+    docker exec kafka kafka-console-consumer.sh --bootstrap-server localhost:9092 --topic %%t --from-beginning --timeout-ms 1000 >nul 2>&1
+)
 
 echo.
 echo ✅ Kafka message queues cleared successfully!
-echo You can now start the application with: .\philoking.exe
+echo You can now start the application with: .\philoking.exe serve
 echo.
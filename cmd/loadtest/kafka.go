@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"philoking/internal/config"
+	"philoking/internal/kafka"
+	"philoking/internal/types"
+
+	"github.com/google/uuid"
+)
+
+// kafkaDriver publishes messages straight to the brokers, bypassing the web server
+type kafkaDriver struct {
+	client *kafka.Client
+}
+
+func newKafkaDriver(profile string) (*kafkaDriver, error) {
+	cfg, err := config.Load(profile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load configuration: %w", err)
+	}
+
+	client, err := kafka.NewClient(cfg.Kafka)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize Kafka client: %w", err)
+	}
+	return &kafkaDriver{client: client}, nil
+}
+
+// start follows the conversation with a consumer group of its own
+func (d *kafkaDriver) start(ctx context.Context, rec *recorder) error {
+	groupID := "philoking-loadtest-" + uuid.New().String()[:8]
+	go d.client.SubscribeFromLatest(ctx, groupID, func(message *types.ChatMessage) error {
+		rec.received(message.Content)
+		return nil
+	})
+	return nil
+}
+
+// connect returns a sender that publishes user messages for the given user
+func (d *kafkaDriver) connect(ctx context.Context, user int) (sender, error) {
+	userID := fmt.Sprintf("loadtest-user-%d", user)
+
+	return func(ctx context.Context, content string) error {
+		return d.client.PublishMessage(ctx, &types.ChatMessage{
+			ID:        uuid.New().String(),
+			Type:      types.MessageTypeUser,
+			Content:   content,
+			AgentID:   userID,
+			UserID:    userID,
+			Timestamp: time.Now(),
+			Metadata: types.Metadata{
+				ConversationID: "main-conversation",
+				FromAgent:      userID,
+			},
+		})
+	}, nil
+}
+
+func (d *kafkaDriver) close() {
+	d.client.Close()
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"os"
+	"os/signal"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/spf13/cobra"
+)
+
+// options are the load test settings given on the command line
+type options struct {
+	path     string
+	users    int
+	rate     float64
+	duration time.Duration
+	warmup   time.Duration
+	drain    time.Duration
+	url      string
+	profile  string
+	verbose  bool
+}
+
+// sender publishes one message for a simulated user
+type sender func(ctx context.Context, content string) error
+
+// driver connects simulated users through the WebSocket or Kafka path and
+// reports every load test message that comes back to the recorder
+type driver interface {
+	start(ctx context.Context, rec *recorder) error
+	connect(ctx context.Context, user int) (sender, error)
+	close()
+}
+
+func main() {
+	if err := newRootCmd().Execute(); err != nil {
+		os.Exit(1)
+	}
+}
+
+// newRootCmd creates the loadtest command
+func newRootCmd() *cobra.Command {
+	opts := options{}
+
+	cmd := &cobra.Command{
+		Use:          "loadtest",
+		Short:        "Simulate users chatting and report throughput and latency",
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return run(opts)
+		},
+	}
+
+	cmd.Flags().StringVar(&opts.path, "path", "ws", `path messages take: "ws" (through the web server) or "kafka" (straight to the brokers)`)
+	cmd.Flags().IntVar(&opts.users, "users", 10, "number of simulated users")
+	cmd.Flags().Float64Var(&opts.rate, "rate", 1, "messages per second sent by each user")
+	cmd.Flags().DurationVar(&opts.duration, "duration", 30*time.Second, "how long users keep sending")
+	cmd.Flags().DurationVar(&opts.warmup, "warmup", 3*time.Second, "time for consumers to join before sending starts")
+	cmd.Flags().DurationVar(&opts.drain, "drain", 5*time.Second, "how long to wait for outstanding messages after sending stops")
+	cmd.Flags().StringVar(&opts.url, "url", "ws://localhost:8080/ws", "WebSocket endpoint for the ws path")
+	cmd.Flags().StringVar(&opts.profile, "profile", "", "configuration profile with the Kafka settings for the kafka path")
+	cmd.Flags().BoolVar(&opts.verbose, "verbose", false, "keep the Kafka client's per-message logging")
+
+	return cmd
+}
+
+// run drives the load test and prints the report
+func run(opts options) error {
+	if opts.users <= 0 || opts.rate <= 0 {
+		return fmt.Errorf("--users and --rate must be positive")
+	}
+	if !opts.verbose {
+		log.SetOutput(io.Discard)
+	}
+
+	var d driver
+	switch opts.path {
+	case "ws":
+		d = &wsDriver{url: opts.url}
+	case "kafka":
+		kd, err := newKafkaDriver(opts.profile)
+		if err != nil {
+			return err
+		}
+		d = kd
+	default:
+		return fmt.Errorf("unsupported path %q (supported: ws, kafka)", opts.path)
+	}
+	defer d.close()
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	rec := newRecorder()
+	if err := d.start(ctx, rec); err != nil {
+		return err
+	}
+
+	senders := make([]sender, opts.users)
+	for i := range senders {
+		send, err := d.connect(ctx, i)
+		if err != nil {
+			return fmt.Errorf("failed to connect user %d: %w", i, err)
+		}
+		senders[i] = send
+	}
+
+	fmt.Printf("Connected %d users over %s, warming up for %s\n", opts.users, opts.path, opts.warmup)
+	sleep(ctx, opts.warmup)
+
+	runID := uuid.New().String()[:8]
+	sendCtx, stop := context.WithTimeout(ctx, opts.duration)
+	defer stop()
+
+	fmt.Printf("Sending %.1f msg/s per user for %s\n", opts.rate, opts.duration)
+	started := time.Now()
+	var wg sync.WaitGroup
+	for i, send := range senders {
+		wg.Add(1)
+		go func(user int, send sender) {
+			defer wg.Done()
+			simulateUser(sendCtx, rec, runID, user, opts.rate, send)
+		}(i, send)
+	}
+	wg.Wait()
+	elapsed := time.Since(started)
+
+	rec.waitForOutstanding(ctx, opts.drain)
+	rec.report(os.Stdout, elapsed)
+	return nil
+}
+
+// simulateUser sends messages at the given rate until ctx is done
+func simulateUser(ctx context.Context, rec *recorder, runID string, user int, rate float64, send sender) {
+	interval := max(time.Duration(float64(time.Second)/rate), time.Microsecond)
+
+	// Spread users over the first interval so they don't send in lockstep
+	sleep(ctx, time.Duration(rand.Int63n(int64(interval))))
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for seq := 0; ; seq++ {
+		content := fmt.Sprintf("loadtest %s user-%d #%d", runID, user, seq)
+		rec.sent(content)
+		if err := send(ctx, content); err != nil && ctx.Err() == nil {
+			rec.failed(content)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// sleep waits for d or until ctx is done
+func sleep(ctx context.Context, d time.Duration) {
+	select {
+	case <-ctx.Done():
+	case <-time.After(d):
+	}
+}
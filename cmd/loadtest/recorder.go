@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+// recorder matches sent messages with their delivery and collects latencies
+type recorder struct {
+	pending   map[string]time.Time // Content -> send time
+	latencies []time.Duration
+	sentCount int
+	errors    int
+	mu        sync.Mutex
+}
+
+func newRecorder() *recorder {
+	return &recorder{pending: make(map[string]time.Time)}
+}
+
+// sent records that a message is about to be sent
+func (r *recorder) sent(content string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.pending[content] = time.Now()
+	r.sentCount++
+}
+
+// failed records that sending a message failed
+func (r *recorder) failed(content string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.pending, content)
+	r.errors++
+}
+
+// received records the first delivery of a message; later deliveries, such as
+// the broadcast copies other users receive, are ignored
+func (r *recorder) received(content string) {
+	now := time.Now()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if at, ok := r.pending[content]; ok {
+		r.latencies = append(r.latencies, now.Sub(at))
+		delete(r.pending, content)
+	}
+}
+
+// waitForOutstanding waits until every sent message arrived or the timeout passes
+func (r *recorder) waitForOutstanding(ctx context.Context, timeout time.Duration) {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) && ctx.Err() == nil {
+		r.mu.Lock()
+		outstanding := len(r.pending)
+		r.mu.Unlock()
+		if outstanding == 0 {
+			return
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// report writes throughput and latency percentiles
+func (r *recorder) report(w io.Writer, elapsed time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delivered := len(r.latencies)
+	fmt.Fprintf(w, "\nSent:       %d\n", r.sentCount)
+	fmt.Fprintf(w, "Delivered:  %d\n", delivered)
+	fmt.Fprintf(w, "Lost:       %d\n", len(r.pending))
+	fmt.Fprintf(w, "Errors:     %d\n", r.errors)
+	fmt.Fprintf(w, "Throughput: %.1f msg/s\n", float64(delivered)/elapsed.Seconds())
+
+	if delivered == 0 {
+		return
+	}
+
+	sort.Slice(r.latencies, func(i, j int) bool { return r.latencies[i] < r.latencies[j] })
+	fmt.Fprintf(w, "Latency:    p50 %s  p90 %s  p99 %s  max %s\n",
+		percentile(r.latencies, 50), percentile(r.latencies, 90), percentile(r.latencies, 99), r.latencies[delivered-1].Round(time.Microsecond))
+}
+
+// percentile returns the p-th percentile of sorted durations
+func percentile(sorted []time.Duration, p int) time.Duration {
+	i := (len(sorted)*p + 99) / 100
+	if i > 0 {
+		i--
+	}
+	return sorted[i].Round(time.Microsecond)
+}
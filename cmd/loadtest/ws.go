@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"sync"
+
+	"philoking/internal/types"
+
+	"github.com/gorilla/websocket"
+)
+
+// wsDriver sends messages through the web server, like the browser does
+type wsDriver struct {
+	url   string
+	rec   *recorder
+	conns []*websocket.Conn
+	mu    sync.Mutex
+}
+
+func (d *wsDriver) start(ctx context.Context, rec *recorder) error {
+	d.rec = rec
+	return nil
+}
+
+// connect opens a WebSocket for a user and reads the messages broadcast to it
+func (d *wsDriver) connect(ctx context.Context, user int) (sender, error) {
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, d.url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	d.mu.Lock()
+	d.conns = append(d.conns, conn)
+	d.mu.Unlock()
+
+	go func() {
+		for {
+			var message types.ChatMessage
+			if err := conn.ReadJSON(&message); err != nil {
+				return
+			}
+			d.rec.received(message.Content)
+		}
+	}()
+
+	// The connection has a single writer: the user's own goroutine
+	return func(ctx context.Context, content string) error {
+		return conn.WriteJSON(map[string]string{"type": "message", "content": content})
+	}, nil
+}
+
+func (d *wsDriver) close() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for _, conn := range d.conns {
+		conn.Close()
+	}
+}
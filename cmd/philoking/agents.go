@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"philoking/internal/presets"
+
+	"github.com/spf13/cobra"
+)
+
+// newAgentsCmd creates the agents command group
+func newAgentsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "agents",
+		Short: "Inspect and clone agents",
+	}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "list",
+		Short: "List the agents defined in the configuration",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfig()
+			if err != nil {
+				return fmt.Errorf("failed to load configuration: %w", err)
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "ID\tNAME\tTYPE\tCHANCE\tENABLED")
+			for _, a := range cfg.Agents.Agents {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%.2f\t%t\n", a.ID, a.Name, a.Type, a.ResponseChance, a.IsEnabled)
+			}
+			return w.Flush()
+		},
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "presets",
+		Short: "List the built-in agent presets",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "PRESET\tTYPE\tCHANCE\tTRAITS\tDESCRIPTION")
+			for _, p := range presets.All() {
+				fmt.Fprintf(w, "%s\t%s\t%.2f\t%s\t%s\n", p.Name, p.Type, p.ResponseChance, strings.Join(p.Traits, ", "), p.Description)
+			}
+			return w.Flush()
+		},
+	})
+
+	cmd.AddCommand(newAgentsCloneCmd())
+
+	return cmd
+}
+
+// newAgentsCloneCmd creates the command that clones an agent of a running server
+func newAgentsCloneCmd() *cobra.Command {
+	var server, adminToken, apiKey string
+	var clone struct {
+		ID             string   `json:"id,omitempty"`
+		Name           string   `json:"name,omitempty"`
+		Model          string   `json:"model,omitempty"`
+		Temperature    float64  `json:"temperature,omitempty"`
+		ResponseChance float64  `json:"response_chance,omitempty"`
+		Traits         []string `json:"traits,omitempty"`
+		Instructions   string   `json:"instructions,omitempty"`
+		Oppose         bool     `json:"oppose,omitempty"`
+	}
+
+	cmd := &cobra.Command{
+		Use:   "clone <agent-id>",
+		Short: "Clone an agent of the running server with a changed persona",
+		Example: `  philoking agents clone rational-agent --oppose --temperature 1.1
+  philoking agents clone mythic-agent --name "Young Augustine" --trait rebellious
+  philoking --tenant acme agents clone rational-agent --name "Devil's Advocate" --oppose`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if server == "" || adminToken == "" {
+				cfg, err := loadConfig()
+				if err != nil && server == "" {
+					return fmt.Errorf("failed to load configuration: %w", err)
+				}
+				if err == nil && server == "" {
+					server = "http://" + net.JoinHostPort(cfg.Web.Host, cfg.Web.Port)
+				}
+				if err == nil && adminToken == "" {
+					adminToken = cfg.Web.AdminToken
+				}
+			}
+			// With tenants the server only lets requests with an API key through
+			if apiKey == "" && tenant != "" {
+				key, err := tenantAPIKey()
+				if err != nil {
+					return err
+				}
+				apiKey = key
+			}
+
+			body, err := json.Marshal(clone)
+			if err != nil {
+				return err
+			}
+			req, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(server, "/")+"/api/agents/"+url.PathEscape(args[0])+"/clone", bytes.NewReader(body))
+			if err != nil {
+				return err
+			}
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("X-Admin-Token", adminToken)
+			if apiKey != "" {
+				req.Header.Set("X-API-Key", apiKey)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				return fmt.Errorf("failed to reach the server: %w", err)
+			}
+			defer resp.Body.Close()
+
+			var result struct {
+				ID    string `json:"id"`
+				Name  string `json:"name"`
+				Error string `json:"error"`
+			}
+			if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+				return fmt.Errorf("unexpected answer from the server (%s): %w", resp.Status, err)
+			}
+			if resp.StatusCode != http.StatusCreated {
+				return fmt.Errorf("cloning failed: %s", result.Error)
+			}
+
+			fmt.Printf("Created %s (%s)\n", result.Name, result.ID)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&server, "server", "", "URL of the running server (default: from the web configuration)")
+	cmd.Flags().StringVar(&adminToken, "admin-token", "", "admin token of the running server (default: web.admin_token)")
+	cmd.Flags().StringVar(&apiKey, "api-key", "", "API key of the tenant the agent belongs to (default: the first of the --tenant's api_keys)")
+	cmd.Flags().StringVar(&clone.ID, "id", "", "ID of the clone (default: generated)")
+	cmd.Flags().StringVar(&clone.Name, "name", "", "name of the clone")
+	cmd.Flags().StringVar(&clone.Model, "model", "", "model of the clone")
+	cmd.Flags().Float64Var(&clone.Temperature, "temperature", 0, "sampling temperature of the clone")
+	cmd.Flags().Float64Var(&clone.ResponseChance, "response-chance", 0, "response chance of the clone")
+	cmd.Flags().StringArrayVar(&clone.Traits, "trait", nil, "trait of the clone, replacing the original's (repeatable)")
+	cmd.Flags().StringVar(&clone.Instructions, "instructions", "", "instructions added to the original's")
+	cmd.Flags().BoolVar(&clone.Oppose, "oppose", false, "make the clone take the opposite stance to the original")
+
+	return cmd
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+
+	"philoking/internal/agent"
+
+	"github.com/spf13/cobra"
+)
+
+// newConfigCmd creates the config command group
+func newConfigCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Work with the configuration",
+	}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "validate",
+		Short: "Check the configuration for errors",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfig()
+			if err != nil {
+				return fmt.Errorf("failed to load configuration: %w", err)
+			}
+
+			errs := cfg.Validate()
+			for _, plugin := range cfg.Agents.Plugins {
+				if agent.IsSupportedType(plugin.Type) {
+					errs = append(errs, fmt.Errorf("plugin type %q is a built-in agent type", plugin.Type))
+				}
+			}
+			for _, a := range cfg.Agents.Agents {
+				if _, ok := cfg.Agents.Plugin(a.Type); !ok && !agent.IsSupportedType(a.Type) {
+					errs = append(errs, fmt.Errorf("agent %s: unknown type %q (supported: %v and plugin types)", a.ID, a.Type, agent.SupportedTypes))
+				}
+			}
+
+			for _, err := range errs {
+				fmt.Println("✗", err)
+			}
+			if len(errs) > 0 {
+				return fmt.Errorf("configuration has %d error(s)", len(errs))
+			}
+
+			fmt.Printf("✓ Configuration is valid (%d agents, %d enabled)\n", len(cfg.Agents.Agents), len(cfg.GetEnabledAgents()))
+			return nil
+		},
+	})
+
+	return cmd
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"philoking/internal/app"
+	"philoking/internal/conversation"
+	"philoking/internal/kafka"
+	"philoking/internal/types"
+
+	"github.com/spf13/cobra"
+)
+
+// newExportCmd creates the export command that writes a conversation as an HTML transcript
+func newExportCmd() *cobra.Command {
+	var conversationID, out string
+
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Write a conversation stored in the chat topic as an HTML transcript",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfig()
+			if err != nil {
+				return fmt.Errorf("failed to load configuration: %w", err)
+			}
+
+			ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+			defer cancel()
+
+			kafkaClient, err := kafka.NewClient(cfg.Kafka)
+			if err != nil {
+				return fmt.Errorf("failed to initialize Kafka client: %w", err)
+			}
+			defer kafkaClient.Close()
+
+			messages, err := kafkaClient.ReadAllMessages(ctx)
+			if err != nil {
+				return err
+			}
+
+			snapshot, ok := rebuildConversation(messages, conversationID)
+			if !ok {
+				return fmt.Errorf("no messages found for conversation %s", conversationID)
+			}
+
+			if out == "" {
+				out = conversationID + ".html"
+			}
+			file, err := os.Create(out)
+			if err != nil {
+				return fmt.Errorf("failed to create %s: %w", out, err)
+			}
+			w := bufio.NewWriter(file)
+			if err := conversation.WriteHTML(w, snapshot); err != nil {
+				file.Close()
+				return fmt.Errorf("failed to render transcript: %w", err)
+			}
+			if err := w.Flush(); err != nil {
+				file.Close()
+				return fmt.Errorf("failed to write %s: %w", out, err)
+			}
+			if err := file.Close(); err != nil {
+				return fmt.Errorf("failed to write %s: %w", out, err)
+			}
+
+			fmt.Printf("Exported %d messages of %s to %s\n", len(snapshot.Messages), conversationID, out)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&conversationID, "conversation", app.DefaultConversationID, "conversation to export")
+	cmd.Flags().StringVarP(&out, "out", "o", "", "file to write (default: <conversation>.html)")
+
+	return cmd
+}
+
+// rebuildConversation replays the stored messages of a conversation into a
+// manager, tracking topic and mood the way the conversation flow does, and
+// leaves out deleted messages
+func rebuildConversation(messages []*types.ChatMessage, conversationID string) (*conversation.Snapshot, bool) {
+	belongs := func(msg *types.ChatMessage) bool {
+		id := msg.Metadata.ConversationID
+		if id == "" {
+			id = app.DefaultConversationID
+		}
+		return id == conversationID
+	}
+
+	deleted := make(map[string]bool)
+	for _, msg := range messages {
+		if msg.Type == types.MessageTypeDeletion && belongs(msg) {
+			deleted[msg.Metadata.Custom[types.DeletedMessageKey]] = true
+		}
+	}
+
+	manager := conversation.NewManager()
+	for _, msg := range messages {
+		if !belongs(msg) || deleted[msg.ID] {
+			continue
+		}
+		switch msg.Type {
+		case types.MessageTypeDeletion, types.MessageTypeScore, types.MessageTypeWhiteboard, types.MessageTypeAlert:
+			continue
+		}
+		manager.AddMessage(conversationID, msg)
+		manager.UpdateTopicMood(conversationID, conversation.DetectTopic(msg.Content), conversation.DetectMood(msg.Content), msg.ID, msg.Timestamp)
+	}
+	return manager.Snapshot(conversationID)
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"philoking/internal/kafka"
+
+	"github.com/spf13/cobra"
+)
+
+// newReplayCmd creates the replay command that prints the stored conversation
+func newReplayCmd() *cobra.Command {
+	var conversationID string
+
+	cmd := &cobra.Command{
+		Use:   "replay",
+		Short: "Print the messages stored in the chat topic",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfig()
+			if err != nil {
+				return fmt.Errorf("failed to load configuration: %w", err)
+			}
+
+			ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+			defer cancel()
+
+			kafkaClient, err := kafka.NewClient(cfg.Kafka)
+			if err != nil {
+				return fmt.Errorf("failed to initialize Kafka client: %w", err)
+			}
+			defer kafkaClient.Close()
+
+			messages, err := kafkaClient.ReadAllMessages(ctx)
+			if err != nil {
+				return err
+			}
+
+			for _, msg := range messages {
+				if conversationID != "" && msg.Metadata.ConversationID != conversationID {
+					continue
+				}
+
+				sender := msg.AgentID
+				if msg.Metadata.FromAgent != "" {
+					sender = msg.Metadata.FromAgent
+				}
+				fmt.Printf("[%s] %s (%s): %s\n", msg.Timestamp.Format("2006-01-02 15:04:05"), sender, msg.Type, msg.Content)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&conversationID, "conversation", "", "only replay messages of this conversation")
+
+	return cmd
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+
+	"philoking/internal/config"
+
+	"github.com/spf13/cobra"
+)
+
+// profile is the configuration profile selected with --profile
+var profile string
+
+// tenant is the tenant selected with --tenant
+var tenant string
+
+// newRootCmd creates the philoking command with all subcommands
+func newRootCmd() *cobra.Command {
+	root := &cobra.Command{
+		Use:           "philoking",
+		Short:         "PhiloKing multi-agent conversation system",
+		SilenceUsage:  true,
+		SilenceErrors: false,
+	}
+
+	root.PersistentFlags().StringVar(&profile, "profile", "", "configuration profile to overlay, e.g. dev or prod (loads config.<profile>.yaml)")
+	root.PersistentFlags().StringVar(&tenant, "tenant", "", "work on a single tenant's topics, agents and state")
+
+	root.AddCommand(
+		newServeCmd(),
+		newAgentsCmd(),
+		newConfigCmd(),
+		newReplayCmd(),
+		newExportCmd(),
+		newSeedCmd(),
+	)
+
+	return root
+}
+
+// loadConfig loads the configuration for the selected profile and tenant
+func loadConfig() (*config.Config, error) {
+	cfg, err := config.Load(profile)
+	if err != nil || tenant == "" {
+		return cfg, err
+	}
+	for _, t := range cfg.Tenants {
+		if t.ID == tenant {
+			return cfg.ForTenant(t), nil
+		}
+	}
+	return nil, fmt.Errorf("unknown tenant %q", tenant)
+}
+
+// tenantAPIKey returns the first API key of the tenant selected with --tenant
+func tenantAPIKey() (string, error) {
+	cfg, err := config.Load(profile)
+	if err != nil {
+		return "", fmt.Errorf("failed to load configuration: %w", err)
+	}
+	for _, t := range cfg.Tenants {
+		if t.ID != tenant {
+			continue
+		}
+		if len(t.APIKeys) == 0 {
+			return "", fmt.Errorf("tenant %q has no API keys", tenant)
+		}
+		return t.APIKeys[0], nil
+	}
+	return "", fmt.Errorf("unknown tenant %q", tenant)
+}
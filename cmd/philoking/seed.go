@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"philoking/internal/app"
+	"philoking/internal/conversation"
+	"philoking/internal/kafka"
+
+	"github.com/spf13/cobra"
+)
+
+// newSeedCmd creates the seed command that imports a transcript into a conversation
+func newSeedCmd() *cobra.Command {
+	var file, conversationID string
+
+	cmd := &cobra.Command{
+		Use:   "seed",
+		Short: "Import a transcript so agents continue the discussion",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				return fmt.Errorf("failed to read transcript: %w", err)
+			}
+			transcript, err := conversation.ParseTranscript(data)
+			if err != nil {
+				return err
+			}
+
+			if conversationID == "" {
+				conversationID = transcript.ConversationID
+			}
+			if conversationID == "" {
+				conversationID = app.DefaultConversationID
+			}
+
+			cfg, err := loadConfig()
+			if err != nil {
+				return fmt.Errorf("failed to load configuration: %w", err)
+			}
+
+			ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+			defer cancel()
+
+			kafkaClient, err := kafka.NewClient(cfg.Kafka)
+			if err != nil {
+				return fmt.Errorf("failed to initialize Kafka client: %w", err)
+			}
+			defer kafkaClient.Close()
+
+			count, err := conversation.Seed(ctx, kafkaClient, transcript.ChatMessages(conversationID), conversationID)
+			if err != nil {
+				return err
+			}
+
+			fmt.Printf("Seeded %s with %d messages\n", conversationID, count)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&file, "file", "", "transcript JSON file to import")
+	cmd.Flags().StringVar(&conversationID, "conversation", "", "conversation to seed (default: the transcript's, or the main conversation)")
+	cmd.MarkFlagRequired("file")
+
+	return cmd
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"philoking/internal/app"
+
+	"github.com/spf13/cobra"
+)
+
+// newServeCmd creates the serve command that runs agents and the web server
+func newServeCmd() *cobra.Command {
+	var mode string
+	var waitForDeps bool
+
+	cmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Run the agents and the web interface",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfig()
+			if err != nil {
+				return fmt.Errorf("failed to load configuration: %w", err)
+			}
+			if waitForDeps {
+				cfg.Startup.WaitForDeps = true
+			}
+
+			// Create context for graceful shutdown
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			// With tenants, every tenant runs its own application behind one web server
+			var application interface {
+				Start(ctx context.Context) error
+				Close() error
+			}
+			if len(cfg.Tenants) > 0 {
+				application, err = app.NewTenants(cfg, mode)
+			} else {
+				application, err = app.New(cfg, mode)
+			}
+			if err != nil {
+				return err
+			}
+			defer application.Close()
+
+			if err := application.Start(ctx); err != nil {
+				return err
+			}
+
+			// Wait for interrupt signal
+			sigChan := make(chan os.Signal, 1)
+			signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+			<-sigChan
+
+			log.Println("Shutting down...")
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&mode, "mode", app.ModeNatural, fmt.Sprintf("conversation mode %v", app.Modes))
+	cmd.Flags().BoolVar(&waitForDeps, "wait-for-deps", false, "wait until Kafka and the LLM provider are reachable before starting")
+
+	return cmd
+}
@@ -0,0 +1,40 @@
+// Command shout is an example agent plugin: it repeats user messages in
+// capitals, with an optional prefix from the agent's settings. Build it with
+//
+//	go build -o plugins/shout ./examples/plugins/shout
+//
+// and register it under agents.plugins to use agents of type "shout".
+package main
+
+import (
+	"log"
+	"strings"
+
+	"philoking/pkg/agentplugin"
+)
+
+// shouter implements agentplugin.Handler
+type shouter struct {
+	prefix string
+}
+
+func (s *shouter) Init(cfg agentplugin.Config) error {
+	s.prefix = cfg.Settings["prefix"]
+	log.Printf("Shout plugin serving agent %s", cfg.ID)
+	return nil
+}
+
+func (s *shouter) HandleMessage(message agentplugin.Message, history []agentplugin.Message) ([]agentplugin.Reply, error) {
+	if message.Type != "user" {
+		return nil, nil
+	}
+	return []agentplugin.Reply{{Content: s.prefix + strings.ToUpper(message.Content)}}, nil
+}
+
+func (s *shouter) Shutdown() error {
+	return nil
+}
+
+func main() {
+	agentplugin.Serve(&shouter{})
+}
@@ -0,0 +1,29 @@
+-- Greets everyone the first time they speak and counts how often they
+-- talked since. Run it with an agent like:
+--
+--   - id: "greeter-agent"
+--     name: "The Doorman"
+--     type: "script"
+--     script: "./examples/scripts/greeter.lua"
+--     response_chance: 1.0
+--     settings:
+--       greeting: "Welcome to the council"
+
+local greeting = settings.greeting or "Hello"
+local seen = {}  -- Globals and locals of the script live as long as the agent
+
+function handle(message, history)
+  if message.type ~= "user" or message.is_command then
+    return
+  end
+
+  local count = (seen[message.from] or 0) + 1
+  seen[message.from] = count
+
+  if count == 1 then
+    send(string.format("%s, %s! %d messages were said before you arrived.", greeting, message.from, #history))
+  elseif count % 10 == 0 then
+    log(message.from .. " is on a roll")
+    send(string.format("That's %d messages from %s already. Keep going!", count, message.from))
+  end
+end
@@ -2,31 +2,56 @@ package agent
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"math/rand"
 	"sync"
 	"time"
 
+	"philoking/internal/config"
 	"philoking/internal/conversation"
 	"philoking/internal/kafka"
+	"philoking/internal/rules"
 	"philoking/internal/types"
 
 	"github.com/google/uuid"
+	"golang.org/x/sync/errgroup"
 )
 
 // BaseAgent provides common functionality for all agents
 type BaseAgent struct {
-	id             string
-	name           string
-	kafkaClient    *kafka.Client
-	handler        MessageHandler
-	running        bool
-	mu             sync.RWMutex
-	ctx            context.Context
-	cancel         context.CancelFunc
-	responseChance float64
-	convManager    *conversation.Manager
+	id              string
+	name            string
+	kafkaClient     *kafka.Client
+	handler         MessageHandler
+	running         bool
+	mu              sync.RWMutex
+	ctx             context.Context
+	cancel          context.CancelFunc
+	group           *errgroup.Group // The subscriptions of the running agent, which Stop waits for
+	busySince       time.Time       // When the agent picked up the message it is handling; zero when idle
+	panicked        error           // The last panic of the handler since the agent started
+	messageTimeout  time.Duration   // How long handling a message may take; 0 for no limit
+	responseChance  float64
+	convManager     *conversation.Manager
+	stats           statsCounter
+	responses       responseHistory
+	allowRepeats    bool                           // Skip duplicate suppression, e.g. for deterministic command replies
+	claims          *kafka.Claimer                 // Set when replicas must claim a message before replying
+	engagement      *engagementTracker             // Set when the response chance adapts to user engagement
+	routed          bool                           // Set when a router picks who answers user messages
+	rules           *rules.Engine                  // Set when the agent has respond/ignore rules
+	fromLatest      bool                           // Set for agents created at runtime, which skip the topics' history
+	startFrom       string                         // Configured start position of the agent's consumer group, see kafka.ParseStartPosition
+	replayedBefore  time.Time                      // Messages sent before this were replayed on start and are only context
+	agentHandlers   map[string]AgentMessageHandler // Direct messages from other agents, by type
+	lastRespondedTo string                         // ID of the last message the agent replied to
+	dryRun          bool                           // Computes responses without publishing them
+	dryRuns         dryRunLog                      // What the agent would have sent in a dry run
+	lastPublished   *types.ChatMessage             // The last message the agent sent
+	shadow          *BaseAgent                     // Set when a variant answers the same messages in the agent's shadow
+	shadows         shadowLog                      // The agent's replies next to its shadow's
 }
 
 // NewBaseAgent creates a new base agent
@@ -68,14 +93,57 @@ func (a *BaseAgent) Start(ctx context.Context) error {
 		return fmt.Errorf("agent %s is already running", a.id)
 	}
 	a.running = true
+	a.busySince, a.panicked = time.Time{}, nil
+	a.ctx, a.cancel = context.WithCancel(ctx)
+	ctx = a.ctx
+	subscribe := a.kafkaClient.SubscribeToMessages
+	if a.fromLatest {
+		subscribe = a.kafkaClient.SubscribeFromLatest
+	}
+	if a.startFrom != "" {
+		now := time.Now()
+		if position, err := kafka.ParseStartPosition(a.startFrom, now); err != nil {
+			log.Printf("Warning: Agent %s ignores start_from: %v", a.id, err)
+		} else {
+			subscribe = func(ctx context.Context, groupID string, handler func(*types.ChatMessage) error) error {
+				return a.kafkaClient.SubscribeFrom(ctx, groupID, position, handler)
+			}
+			if position.Reset && position.Replays() {
+				a.replayedBefore = now
+			}
+		}
+	}
+	group, ctx := errgroup.WithContext(ctx)
+	a.group = group
 	a.mu.Unlock()
 
-	// Start listening for all chat messages
-	go func() {
-		if err := a.kafkaClient.SubscribeToMessages(ctx, "philoking-agent-"+a.id, func(msg *types.ChatMessage) error {
+	// Listen for all chat messages until the agent is stopped
+	group.Go(func() error {
+		if err := subscribe(ctx, a.groupID("philoking-agent-"), func(msg *types.ChatMessage) error {
 			return a.ProcessMessage(ctx, msg)
-		}); err != nil {
-			log.Printf("Agent %s error subscribing to messages: %v", a.id, err)
+		}); err != nil && ctx.Err() == nil {
+			return fmt.Errorf("subscribing to messages: %w", err)
+		}
+		return nil
+	})
+	a.listenForAgentMessages(ctx, group)
+
+	// A subscription that fails takes the agent down until it is restarted, as
+	// does cancelling the context passed to Start
+	go func() {
+		err := group.Wait()
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		if a.group != group {
+			return // Stopped
+		}
+		a.cancel()
+		a.running = false
+		a.group = nil
+		if err != nil {
+			log.Printf("Agent %s stopped: %v", a.id, err)
+		} else {
+			log.Printf("Agent %s stopped", a.id) // The context passed to Start was cancelled
 		}
 	}()
 
@@ -83,26 +151,34 @@ func (a *BaseAgent) Start(ctx context.Context) error {
 	return nil
 }
 
-// Stop gracefully stops the agent
+// Stop gracefully stops the agent, returning once its subscriptions have
+// closed their Kafka readers
 func (a *BaseAgent) Stop() error {
 	a.mu.Lock()
-	defer a.mu.Unlock()
-
 	if !a.running {
+		a.mu.Unlock()
 		return nil
 	}
-
 	a.cancel()
 	a.running = false
+	group := a.group
+	a.group = nil
+	a.mu.Unlock()
+
+	// Messages being handled see the cancelled context and return
+	err := group.Wait()
 	log.Printf("Agent %s stopped", a.id)
-	return nil
+	return err
 }
 
 // ProcessMessage handles incoming chat messages
-func (a *BaseAgent) ProcessMessage(ctx context.Context, message *types.ChatMessage) error {
+func (a *BaseAgent) ProcessMessage(ctx context.Context, message *types.ChatMessage) (err error) {
+	defer a.recoverPanic(message, &err)
+
 	a.mu.RLock()
 	handler := a.handler
 	responseChance := a.responseChance
+	replayedBefore := a.replayedBefore
 	a.mu.RUnlock()
 
 	if handler == nil {
@@ -114,19 +190,283 @@ func (a *BaseAgent) ProcessMessage(ctx context.Context, message *types.ChatMessa
 		return nil
 	}
 
-	// Add message to conversation history
+	// Deletion, whiteboard and alert events only concern clients, and scores only the leaderboard
+	if message.Type == types.MessageTypeDeletion || message.Type == types.MessageTypeWhiteboard || message.Type == types.MessageTypeAlert || message.Type == types.MessageTypeScore {
+		return nil
+	}
+
+	a.stats.messageSeen()
+
+	// Add message to conversation history and mark it as read by this agent
 	if a.convManager != nil {
 		a.convManager.AddMessage(message.Metadata.ConversationID, message)
+		a.convManager.MarkRead(message.Metadata.ConversationID, message.ID, a.id)
+	}
+
+	// Users reacting to this agent make it speak up more
+	if a.engagement != nil {
+		now := time.Now()
+		a.engagement.observe(a.id, a.name, message, a.previousMessage(message), now)
+		responseChance = a.engagement.chance(responseChance, now)
+	}
+
+	// A message answered before a restart may be delivered again
+	if message.ID == a.lastResponded() {
+		return nil
+	}
+
+	// Imported history is context, not something to reply to
+	if hasTag(message, conversation.SeededTag) {
+		return nil
+	}
+
+	// So is the history replayed when the agent started
+	if message.Timestamp.Before(replayedBefore) {
+		return nil
+	}
+
+	// Required questions are for the human, not for the other agents
+	if message.Type == types.MessageTypeQuestion && message.Metadata.Required {
+		return nil
+	}
+
+	// A conversation that is wrapping up only hears the closing statement asked for, and a locked one nothing
+	if a.convManager != nil {
+		if wrapUp := a.convManager.GetWrapUp(message.Metadata.ConversationID); wrapUp != nil {
+			if !wrapUp.ShouldRespond(a.id, message) {
+				return nil
+			}
+			return a.handle(ctx, handler, message)
+		}
+	}
+
+	// While a question is pending only the asking agent may react, to the human's answer
+	if a.convManager != nil {
+		question := a.convManager.GetPendingQuestion(message.Metadata.ConversationID)
+		if paused, mayRespond := question.AwaitsHuman(a.id, message); paused {
+			if !mayRespond {
+				return nil
+			}
+			return a.handle(ctx, handler, message)
+		}
+	}
+
+	// Side conversations have fixed participants who always answer each other
+	if a.convManager != nil {
+		if side, ok := a.convManager.GetSideConversation(message.Metadata.ConversationID); ok {
+			if !side.ShouldRespond(a.id, message) {
+				return nil
+			}
+			return a.handle(ctx, handler, message)
+		}
+	}
+
+	// During a debate only the agent the moderator gives the floor speaks
+	if a.convManager != nil && !message.IsCommand() {
+		if debate := a.convManager.GetActiveDebate(message.Metadata.ConversationID); debate != nil {
+			if !debate.ShouldRespond(a.id, message) {
+				return nil
+			}
+			return a.handle(ctx, handler, message)
+		}
+	}
+
+	// During tutoring the tutor leads and the others only answer when summoned
+	if a.convManager != nil && !message.IsCommand() {
+		if tutoring := a.convManager.GetActiveTutoring(message.Metadata.ConversationID); tutoring != nil {
+			if !tutoring.ShouldRespond(a.id, a.name, message) {
+				return nil
+			}
+			return a.handle(ctx, handler, message)
+		}
+	}
+
+	// Agents outside the conversation's members, or its active phase, stay quiet
+	if a.convManager != nil && !message.IsCommand() {
+		if !a.convManager.IsMember(message.Metadata.ConversationID, a.id) {
+			return nil
+		}
+		if phase := a.convManager.GetPhase(message.Metadata.ConversationID); !phase.Allows(a.id) {
+			return nil
+		}
+	}
+
+	// With a router, the specialist it addresses answers the user and the others sit the turn out;
+	// likewise only the agent asked to revive a quiet conversation answers the moderator
+	if hasTag(message, RoutedTag) || hasTag(message, conversation.RevivalTag) {
+		if message.Metadata.ReplyTo != a.id {
+			return nil
+		}
+		return a.handle(ctx, handler, message)
+	}
+	if a.routed && message.Type == types.MessageTypeUser && !message.IsCommand() {
+		return nil
+	}
+
+	// Rules decide first; the response chance only applies to messages no rule matches
+	action, rule, errs := a.rules.Decide(a.ruleInput(message))
+	for _, err := range errs {
+		log.Printf("Agent %s could not evaluate %v", a.id, err)
+	}
+	switch action {
+	case rules.Ignore:
+		log.Printf("Agent %s ignored message %s by rule %q", a.name, message.ID, rule)
+		a.stats.ignored()
+		return nil
+	case rules.Respond:
+		return a.handle(ctx, handler, message)
 	}
 
 	// Check response chance
 	if !a.shouldRespond(responseChance) {
 		log.Printf("Agent %s decided not to respond (chance: %.2f)", a.name, responseChance)
+		a.stats.skipped()
 		return nil
 	}
 
 	// Process the message with full conversation context
-	return handler.HandleMessage(ctx, message)
+	return a.handle(ctx, handler, message)
+}
+
+// handle passes a message to the handler once this replica has claimed it
+func (a *BaseAgent) handle(ctx context.Context, handler MessageHandler, message *types.ChatMessage) error {
+	// A hung provider must not hold the agent forever
+	parent := ctx
+	if a.messageTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, a.messageTimeout)
+		defer cancel()
+	}
+
+	if a.claims != nil && !a.dryRun {
+		won, err := a.claims.Claim(ctx, message.ID, a.id)
+		if err != nil {
+			// A rare double reply beats an agent that falls silent when claims are slow
+			log.Printf("Agent %s could not confirm its claim, replying anyway: %v", a.id, err)
+		} else if !won {
+			log.Printf("Agent %s left message %s to the replica that claimed it", a.id, message.ID)
+			return nil
+		}
+	}
+
+	if a.shadow != nil {
+		go a.runShadow(message)
+	}
+
+	sent := a.stats.sent()
+	err := a.callHandler(ctx, handler, message)
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) && parent.Err() == nil && a.stats.sent() == sent {
+		// Retrying would most likely time out again
+		a.reportTimeout(parent, message)
+		a.reportError(parent, message, fmt.Errorf("gave up after %s: %w", a.messageTimeout, ctx.Err()))
+		return nil
+	}
+	if err != nil {
+		a.reportError(parent, message, err)
+		return err
+	}
+	if a.stats.sent() > sent {
+		a.mu.Lock()
+		a.lastRespondedTo = message.ID
+		a.mu.Unlock()
+		if a.shadow != nil {
+			a.recordLive(message)
+		}
+	}
+	return nil
+}
+
+// reportTimeout posts a system note that the agent gave up on a message
+func (a *BaseAgent) reportTimeout(ctx context.Context, message *types.ChatMessage) {
+	log.Printf("Agent %s gave up on message %s after %s", a.id, message.ID, a.messageTimeout)
+
+	note := a.newMessage(fmt.Sprintf("⏱️ %s took longer than %s to answer and gave up.", a.name, a.messageTimeout), message.Metadata.ConversationID)
+	note.Type = types.MessageTypeSystem
+	note.Metadata.ReplyTo = message.ID
+	if a.holdBack(note) {
+		return
+	}
+	if err := a.kafkaClient.PublishSigned(ctx, note); err != nil {
+		log.Printf("Failed to publish timeout note: %v", err)
+	}
+}
+
+// lastResponded returns the ID of the last message the agent replied to
+func (a *BaseAgent) lastResponded() string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.lastRespondedTo
+}
+
+// previousMessage returns the message that came right before the given one
+func (a *BaseAgent) previousMessage(message *types.ChatMessage) *types.ChatMessage {
+	if a.convManager == nil {
+		return nil
+	}
+	recent := a.convManager.GetRecentMessages(message.Metadata.ConversationID, 2)
+	if len(recent) == 2 && recent[1].ID == message.ID {
+		return recent[0]
+	}
+	return nil
+}
+
+// ReceiveFeedback lets a user's rating of one of the agent's messages count
+// towards its engagement
+func (a *BaseAgent) ReceiveFeedback(positive bool) {
+	if a.engagement != nil {
+		a.engagement.rate(positive, time.Now())
+	}
+}
+
+// setEngagement adapts the agent's response chance to user engagement
+func (a *BaseAgent) setEngagement(cfg config.AdaptiveConfig) {
+	a.engagement = newEngagementTracker(cfg)
+}
+
+// setRouted leaves answering user messages to the specialist a router picks
+func (a *BaseAgent) setRouted() {
+	a.routed = true
+}
+
+// setRules lets rules decide whether the agent responds
+func (a *BaseAgent) setRules(engine *rules.Engine) {
+	a.rules = engine
+}
+
+// ruleInput describes a message and its conversation to the agent's rules
+func (a *BaseAgent) ruleInput(message *types.ChatMessage) rules.Input {
+	input := rules.Input{Message: message, AgentID: a.id, AgentName: a.name}
+	if a.rules == nil || a.convManager == nil {
+		return input
+	}
+	conversationID := message.Metadata.ConversationID
+	if info, ok := a.convManager.GetInfo(conversationID); ok {
+		input.Topic, input.Mood, input.Goal, input.Messages = info.Topic, info.Mood, info.Goal, info.Messages
+	}
+	if phase := a.convManager.GetPhase(conversationID); phase != nil {
+		input.Phase = phase.Name
+	}
+	return input
+}
+
+// startAtLatest makes a new agent ignore the messages sent before it was created
+func (a *BaseAgent) startAtLatest() {
+	a.fromLatest = true
+}
+
+// setStartFrom sets where the agent starts reading the chat topics
+func (a *BaseAgent) setStartFrom(startFrom string) {
+	a.startFrom = startFrom
+}
+
+// setMessageTimeout limits how long the agent may take to handle a message
+func (a *BaseAgent) setMessageTimeout(timeout time.Duration) {
+	a.messageTimeout = timeout
+}
+
+// setClaimer makes the agent claim messages before replying
+func (a *BaseAgent) setClaimer(claims *kafka.Claimer) {
+	a.claims = claims
 }
 
 // shouldRespond determines if this agent should respond based on response chance
@@ -144,9 +484,52 @@ func (a *BaseAgent) shouldRespond(responseChance float64) bool {
 	return rand.Float64() < responseChance
 }
 
-// SendMessage sends a message to the global conversation
+// SendMessage sends a message to the global conversation. It returns
+// ErrDuplicateResponse without publishing if the content repeats one of the
+// agent's recent messages.
 func (a *BaseAgent) SendMessage(ctx context.Context, content string, conversationID string) error {
-	message := &types.ChatMessage{
+	return a.sendMessage(ctx, a.newMessage(content, conversationID))
+}
+
+// sendMessage sends a prepared agent message like SendMessage
+func (a *BaseAgent) sendMessage(ctx context.Context, message *types.ChatMessage) error {
+	if !a.allowRepeats && a.responses.isDuplicate(message.Content) {
+		log.Printf("Agent %s suppressed duplicate response: %s", a.id, message.Content)
+		return ErrDuplicateResponse
+	}
+
+	log.Printf("Agent %s publishing message to Kafka: %s", a.id, message.Content)
+	if err := a.publish(ctx, message); err != nil {
+		return err
+	}
+
+	a.responses.add(message.Content)
+	return nil
+}
+
+// publish sends a prepared message to Kafka and records it in the agent's stats
+func (a *BaseAgent) publish(ctx context.Context, message *types.ChatMessage) error {
+	if a.holdBack(message) {
+		return nil
+	}
+	if err := a.kafkaClient.PublishSigned(ctx, message); err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	a.lastPublished = message
+	a.mu.Unlock()
+
+	a.stats.responseSent()
+	if a.engagement != nil && message.Type == types.MessageTypeAgent {
+		a.engagement.sent(message.ID, message.Timestamp)
+	}
+	return nil
+}
+
+// newMessage builds an agent chat message for the given conversation
+func (a *BaseAgent) newMessage(content string, conversationID string) *types.ChatMessage {
+	return &types.ChatMessage{
 		ID:        uuid.New().String(),
 		Type:      types.MessageTypeAgent,
 		Content:   content,
@@ -157,9 +540,26 @@ func (a *BaseAgent) SendMessage(ctx context.Context, content string, conversatio
 			FromAgent:      a.name, // Human-readable name
 		},
 	}
+}
+
+// Stats returns a snapshot of the agent's runtime statistics
+func (a *BaseAgent) Stats() Stats {
+	a.mu.RLock()
+	stats := Stats{
+		AgentID:        a.id,
+		Name:           a.name,
+		Running:        a.running,
+		ResponseChance: a.responseChance,
+	}
+	a.mu.RUnlock()
+
+	stats.EffectiveResponseChance = stats.ResponseChance
+	if a.engagement != nil {
+		stats.EffectiveResponseChance = a.engagement.chance(stats.ResponseChance, time.Now())
+	}
 
-	log.Printf("Agent %s publishing message to Kafka: %s", a.id, content)
-	return a.kafkaClient.PublishMessage(ctx, message)
+	a.stats.snapshot(&stats)
+	return stats
 }
 
 // IsRunning returns whether the agent is currently running
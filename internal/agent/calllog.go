@@ -0,0 +1,191 @@
+package agent
+
+import (
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// redactedSecret replaces keys in recorded LLM calls
+const redactedSecret = "[REDACTED]"
+
+// keyPattern matches bearer tokens and common API key formats that may turn
+// up in provider responses, e.g. when an error echoes the request
+var keyPattern = regexp.MustCompile(`(?i)(bearer\s+)[A-Za-z0-9._~+/=-]{8,}|\bsk-[A-Za-z0-9_-]{8,}`)
+
+// LLMCall is one exchange with an LLM provider, kept for debugging prompts
+type LLMCall struct {
+	At             time.Time `json:"at"`
+	ConversationID string    `json:"conversation_id,omitempty"`
+	Provider       string    `json:"provider"`
+	Model          string    `json:"model"`
+	Messages       []Message `json:"messages"`           // Exactly as sent, after redaction of personal data
+	Response       string    `json:"response,omitempty"` // The provider's raw response body
+	Error          string    `json:"error,omitempty"`
+	DurationMs     int64     `json:"duration_ms"`
+
+	users []string // The users who wrote in the conversation, whose messages the prompt may hold
+}
+
+// CallRecorder is implemented by agents that keep their latest LLM calls
+type CallRecorder interface {
+	// LastCalls returns up to n of the latest calls, newest first
+	LastCalls(n int) []LLMCall
+
+	// ForgetUser drops the calls whose prompts may hold a user's messages,
+	// and returns how many were dropped
+	ForgetUser(userID string) int
+}
+
+// callLog keeps the latest LLM calls of an agent
+type callLog struct {
+	mu    sync.Mutex
+	limit int
+	calls []LLMCall // Oldest first
+}
+
+// add keeps a call, dropping the oldest beyond the limit
+func (c *callLog) add(call LLMCall) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.limit <= 0 {
+		return
+	}
+	c.calls = append(c.calls, call)
+	if len(c.calls) > c.limit {
+		c.calls = append([]LLMCall(nil), c.calls[len(c.calls)-c.limit:]...)
+	}
+}
+
+// latest returns up to n calls, newest first
+func (c *callLog) latest(n int) []LLMCall {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if n <= 0 || n > len(c.calls) {
+		n = len(c.calls)
+	}
+	calls := make([]LLMCall, 0, n)
+	for i := len(c.calls) - 1; i >= len(c.calls)-n; i-- {
+		calls = append(calls, c.calls[i])
+	}
+	return calls
+}
+
+// forget drops the calls that involve a user and returns how many were dropped
+func (c *callLog) forget(userID string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	calls := make([]LLMCall, 0, len(c.calls))
+	for _, call := range c.calls {
+		if !slices.Contains(call.users, userID) {
+			calls = append(calls, call)
+		}
+	}
+	dropped := len(c.calls) - len(calls)
+	c.calls = calls
+	return dropped
+}
+
+// LastCalls returns up to n of the agent's latest LLM calls, newest first
+func (l *LLMAgent) LastCalls(n int) []LLMCall {
+	return l.calls.latest(n)
+}
+
+// ForgetUser drops the agent's LLM calls whose prompts may hold a user's messages
+func (l *LLMAgent) ForgetUser(userID string) int {
+	return l.calls.forget(userID)
+}
+
+// recordCall keeps an LLM call for the debug API, with keys redacted
+func (l *LLMAgent) recordCall(conversationID, provider string, messages []Message, completion *Completion, err error, took time.Duration) {
+	if l.config.DebugCalls <= 0 {
+		return
+	}
+
+	secrets := l.secrets()
+	call := LLMCall{
+		At:             time.Now(),
+		ConversationID: conversationID,
+		Provider:       provider,
+		Model:          l.config.Model,
+		Messages:       make([]Message, len(messages)),
+		DurationMs:     took.Milliseconds(),
+		users:          l.writers(conversationID),
+	}
+	for i, message := range messages {
+		call.Messages[i] = Message{Role: message.Role, Content: redactSecrets(message.Content, secrets)}
+	}
+	if completion != nil {
+		call.Response = redactSecrets(firstNonEmpty(completion.Raw, completion.Content), secrets)
+	}
+	if err != nil {
+		call.Error = redactSecrets(err.Error(), secrets)
+	}
+	l.calls.add(call)
+}
+
+// writers lists the users who wrote in a conversation, so their calls can be
+// dropped when their data is deleted
+func (l *LLMAgent) writers(conversationID string) []string {
+	if l.convManager == nil || conversationID == "" {
+		return nil
+	}
+	var users []string
+	for _, message := range l.convManager.GetMessages(conversationID) {
+		if message.UserID != "" && !slices.Contains(users, message.UserID) {
+			users = append(users, message.UserID)
+		}
+	}
+	return users
+}
+
+// secrets lists the configured credentials that must never show up in recorded calls
+func (l *LLMAgent) secrets() []string {
+	var secrets []string
+	if l.config.LLMAPIKey != "" {
+		secrets = append(secrets, l.config.LLMAPIKey)
+	}
+	for _, headers := range l.config.HTTP.Headers {
+		for _, value := range headers {
+			if value = os.ExpandEnv(value); len(value) >= 8 { // Shorter values are no credentials
+				secrets = append(secrets, value)
+			}
+		}
+	}
+	if proxy, err := url.Parse(l.config.HTTP.ProxyURL); err == nil && proxy.User != nil {
+		if password, ok := proxy.User.Password(); ok && password != "" {
+			secrets = append(secrets, password)
+		}
+	}
+	return secrets
+}
+
+// redactSecrets masks the given secrets and anything that looks like an API key
+func redactSecrets(text string, secrets []string) string {
+	for _, secret := range secrets {
+		text = strings.ReplaceAll(text, secret, redactedSecret)
+	}
+	return keyPattern.ReplaceAllStringFunc(text, func(match string) string {
+		if prefix := keyPattern.FindStringSubmatch(match)[1]; prefix != "" {
+			return prefix + redactedSecret
+		}
+		return redactedSecret
+	})
+}
+
+// firstNonEmpty returns the first of the values that isn't empty
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
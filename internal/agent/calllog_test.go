@@ -0,0 +1,40 @@
+package agent
+
+import (
+	"testing"
+	"time"
+
+	"philoking/internal/config"
+	"philoking/internal/conversation"
+	"philoking/internal/types"
+)
+
+func TestForgetUserDropsTheirCalls(t *testing.T) {
+	conversations := conversation.NewManager()
+	conversations.AddMessage("c1", &types.ChatMessage{ID: "m1", Type: types.MessageTypeUser, UserID: "alice", Content: "I live at ...", Timestamp: time.Now()})
+	conversations.AddMessage("c2", &types.ChatMessage{ID: "m2", Type: types.MessageTypeUser, UserID: "bob", Content: "hi", Timestamp: time.Now()})
+
+	socrates := NewLLMAgent("socrates", "Socrates", "", nil, config.AgentsConfig{DebugCalls: 10}, 1, conversations)
+	socrates.recordCall("c1", "openai", []Message{{Role: "user", Content: "alice: I live at ..."}}, nil, nil, time.Second)
+	socrates.recordCall("c2", "openai", []Message{{Role: "user", Content: "bob: hi"}}, nil, nil, time.Second)
+	socrates.recordCall("", "openai", []Message{{Role: "user", Content: "Pick a question of the day"}}, nil, nil, time.Second)
+
+	// Alice writing later doesn't put her in a prompt that was already sent
+	conversations.AddMessage("c2", &types.ChatMessage{ID: "m3", Type: types.MessageTypeUser, UserID: "alice", Content: "me too", Timestamp: time.Now()})
+
+	manager := NewManager(nil, config.AgentsConfig{})
+	if err := manager.RegisterAgent(socrates); err != nil {
+		t.Fatal(err)
+	}
+	dropped, err := manager.ForgetUser("alice")
+	if err != nil || dropped != 1 {
+		t.Fatalf("ForgetUser = %d, %v, want 1 call dropped", dropped, err)
+	}
+	calls := socrates.LastCalls(0)
+	if len(calls) != 2 || calls[0].ConversationID != "" || calls[1].ConversationID != "c2" {
+		t.Errorf("kept %+v", calls)
+	}
+	if dropped, _ := manager.ForgetUser("alice"); dropped != 0 {
+		t.Errorf("dropped %d calls again", dropped)
+	}
+}
@@ -0,0 +1,71 @@
+package agent
+
+import (
+	"context"
+	"regexp"
+	"strconv"
+	"sync"
+
+	"philoking/internal/types"
+)
+
+// citationRef matches a reference to a cited message in a reply, e.g. "[2]"
+var citationRef = regexp.MustCompile(`\[(\d+)\]`)
+
+// citationsKey carries the citations collected while generating a reply
+type citationsKey struct{}
+
+// citations are the messages of other conversations shown to the agent while
+// it generates a reply, numbered in the order they were shown
+type citations struct {
+	mu   sync.Mutex
+	refs []types.Citation
+}
+
+// withCitations collects the citations offered while generating a reply with ctx
+func withCitations(ctx context.Context) context.Context {
+	return context.WithValue(ctx, citationsKey{}, &citations{})
+}
+
+// citationsFrom returns the citations collected with ctx, or nil when the reply can't cite
+func citationsFrom(ctx context.Context) *citations {
+	cited, _ := ctx.Value(citationsKey{}).(*citations)
+	return cited
+}
+
+// add offers a message for citing and returns its reference, the same one
+// each time the message is offered
+func (c *citations) add(conversationID, messageID, title string) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, citation := range c.refs {
+		if citation.MessageID == messageID {
+			return citation.Ref
+		}
+	}
+	ref := strconv.Itoa(len(c.refs) + 1)
+	c.refs = append(c.refs, types.Citation{Ref: ref, ConversationID: conversationID, MessageID: messageID, Title: title})
+	return ref
+}
+
+// used returns the citations a reply refers to, in the order offered
+func (c *citations) used(reply string) []types.Citation {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	referred := make(map[string]bool)
+	for _, match := range citationRef.FindAllStringSubmatch(reply, -1) {
+		referred[match[1]] = true
+	}
+	var used []types.Citation
+	for _, citation := range c.refs {
+		if referred[citation.Ref] {
+			used = append(used, citation)
+		}
+	}
+	return used
+}
@@ -0,0 +1,44 @@
+package agent
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"philoking/internal/config"
+)
+
+// clockPrompt tells the agent the date, the time of day and where it is
+func clockPrompt(cfg config.ClockConfig, now time.Time) string {
+	if !cfg.Enabled {
+		return ""
+	}
+	if cfg.Timezone != "" {
+		location, err := time.LoadLocation(cfg.Timezone)
+		if err != nil {
+			log.Printf("Unknown timezone %q, using the server's: %v", cfg.Timezone, err)
+		} else {
+			now = now.In(location)
+		}
+	}
+
+	prompt := fmt.Sprintf(" It is %s, %s", now.Format("Monday 2 January 2006, 15:04 MST"), partOfDay(now.Hour()))
+	if cfg.Location != "" {
+		prompt += ", in " + cfg.Location
+	}
+	return prompt + "."
+}
+
+// partOfDay says which part of the day an hour falls in
+func partOfDay(hour int) string {
+	switch {
+	case hour >= 5 && hour < 12:
+		return "in the morning"
+	case hour >= 12 && hour < 18:
+		return "in the afternoon"
+	case hour >= 18 && hour < 23:
+		return "in the evening"
+	default:
+		return "at night"
+	}
+}
@@ -0,0 +1,30 @@
+package agent
+
+import (
+	"strconv"
+	"strings"
+
+	"philoking/internal/conversation"
+	"philoking/internal/types"
+)
+
+// debateWordLimit returns the word limit of a debate prompt, or 0
+func debateWordLimit(message *types.ChatMessage) int {
+	if !hasTag(message, conversation.DebateTag) {
+		return 0
+	}
+	limit, err := strconv.Atoi(message.Metadata.Custom[conversation.DebateWordLimitKey])
+	if err != nil {
+		return 0
+	}
+	return limit
+}
+
+// limitWords cuts text down to at most limit words; 0 leaves it alone
+func limitWords(text string, limit int) string {
+	words := strings.Fields(text)
+	if limit <= 0 || len(words) <= limit {
+		return text
+	}
+	return strings.Join(words[:limit], " ") + "…"
+}
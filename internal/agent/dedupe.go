@@ -0,0 +1,107 @@
+package agent
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+const (
+	// recentResponseWindow is how many of its own recent messages an agent compares against
+	recentResponseWindow = 5
+	// similarityThreshold is the word-set overlap above which a response counts as a repeat
+	similarityThreshold = 0.8
+)
+
+// ErrDuplicateResponse is returned by SendMessage when the content repeats one
+// of the agent's recent messages; the message is not published
+var ErrDuplicateResponse = errors.New("response duplicates a recent message")
+
+// responseHistory remembers an agent's most recent responses
+type responseHistory struct {
+	recent [][]string // Normalized words of each recent response, oldest first
+	mu     sync.Mutex
+}
+
+// isDuplicate reports whether content matches a recent response exactly or fuzzily
+func (h *responseHistory) isDuplicate(content string) bool {
+	words := normalizeWords(content)
+	if len(words) == 0 {
+		return false
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, previous := range h.recent {
+		if similarity(words, previous) >= similarityThreshold {
+			return true
+		}
+	}
+	return false
+}
+
+// add records a response that was sent
+func (h *responseHistory) add(content string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.recent = append(h.recent, normalizeWords(content))
+	if len(h.recent) > recentResponseWindow {
+		h.recent = h.recent[len(h.recent)-recentResponseWindow:]
+	}
+}
+
+// snapshot copies the recent responses
+func (h *responseHistory) snapshot() [][]string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	recent := make([][]string, len(h.recent))
+	copy(recent, h.recent)
+	return recent
+}
+
+// restore replaces the recent responses, e.g. with those of a previous run
+func (h *responseHistory) restore(recent [][]string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if len(recent) > recentResponseWindow {
+		recent = recent[len(recent)-recentResponseWindow:]
+	}
+	h.recent = recent
+}
+
+// normalizeWords lower-cases content and splits it into words without punctuation
+func normalizeWords(content string) []string {
+	return strings.FieldsFunc(strings.ToLower(content), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// similarity returns the Jaccard similarity of two word lists; identical
+// lists (an exact repeat) score 1
+func similarity(a, b []string) float64 {
+	setA := make(map[string]bool, len(a))
+	for _, w := range a {
+		setA[w] = true
+	}
+	setB := make(map[string]bool, len(b))
+	for _, w := range b {
+		setB[w] = true
+	}
+
+	intersection := 0
+	for w := range setA {
+		if setB[w] {
+			intersection++
+		}
+	}
+	union := len(setA) + len(setB) - intersection
+	if union == 0 {
+		return 0
+	}
+	return float64(intersection) / float64(union)
+}
@@ -0,0 +1,81 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"philoking/internal/types"
+
+	"github.com/google/uuid"
+	"golang.org/x/sync/errgroup"
+)
+
+// OnAgentMessage registers the handler of a type of direct message. Agents
+// with handlers follow the agent messages topic once started.
+func (a *BaseAgent) OnAgentMessage(messageType string, handler AgentMessageHandler) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.agentHandlers == nil {
+		a.agentHandlers = make(map[string]AgentMessageHandler)
+	}
+	a.agentHandlers[messageType] = handler
+}
+
+// SendAgentMessage sends a direct message to another agent, or to every agent when to is empty
+func (a *BaseAgent) SendAgentMessage(ctx context.Context, to, messageType, conversationID string, payload interface{}) error {
+	message := &types.AgentMessage{
+		ID:             uuid.New().String(),
+		FromAgent:      a.id,
+		ToAgent:        to,
+		Type:           messageType,
+		Payload:        payload,
+		Timestamp:      time.Now(),
+		ConversationID: conversationID,
+	}
+	if a.holdBackAgentMessage(message) {
+		return nil
+	}
+	return a.kafkaClient.PublishAgentMessage(ctx, message)
+}
+
+// listenForAgentMessages follows the agent messages topic while the agent
+// runs, if it handles any
+func (a *BaseAgent) listenForAgentMessages(ctx context.Context, group *errgroup.Group) {
+	a.mu.RLock()
+	listening := len(a.agentHandlers) > 0
+	a.mu.RUnlock()
+	if !listening || !a.kafkaClient.AgentMessagesEnabled() {
+		return
+	}
+
+	group.Go(func() error {
+		if err := a.kafkaClient.SubscribeToAgentMessages(ctx, a.groupID("philoking-agent-dm-"), func(message *types.AgentMessage) error {
+			return a.handleAgentMessage(ctx, message)
+		}); err != nil && ctx.Err() == nil {
+			return fmt.Errorf("subscribing to agent messages: %w", err)
+		}
+		return nil
+	})
+}
+
+// handleAgentMessage passes a direct message meant for this agent to the handler of its type
+func (a *BaseAgent) handleAgentMessage(ctx context.Context, message *types.AgentMessage) error {
+	if message.FromAgent == a.id || (message.ToAgent != "" && message.ToAgent != a.id) {
+		return nil
+	}
+
+	a.mu.RLock()
+	handler, ok := a.agentHandlers[message.Type]
+	if !ok {
+		handler, ok = a.agentHandlers[""]
+	}
+	a.mu.RUnlock()
+	if !ok {
+		log.Printf("Agent %s ignored %s message from %s", a.id, message.Type, message.FromAgent)
+		return nil
+	}
+	return handler(ctx, message)
+}
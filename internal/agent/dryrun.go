@@ -0,0 +1,104 @@
+package agent
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"philoking/internal/types"
+)
+
+// maxDryRunMessages bounds the would-be messages a dry-running agent keeps
+const maxDryRunMessages = 50
+
+// DryRunMessage is a message a dry-running agent would have sent
+type DryRunMessage struct {
+	At           time.Time           `json:"at"`
+	Message      *types.ChatMessage  `json:"message,omitempty"`       // To the conversation
+	AgentMessage *types.AgentMessage `json:"agent_message,omitempty"` // To other agents
+}
+
+// DryRunner is implemented by agents that can run without publishing
+type DryRunner interface {
+	// DryRun reports whether the agent keeps its messages to itself
+	DryRun() bool
+	// DryRunMessages returns up to n of the latest would-be messages, newest first
+	DryRunMessages(n int) []DryRunMessage
+}
+
+// dryRunLog keeps the latest would-be messages of a dry-running agent
+type dryRunLog struct {
+	mu       sync.Mutex
+	messages []DryRunMessage // Oldest first
+}
+
+// add keeps a would-be message, dropping the oldest beyond maxDryRunMessages
+func (d *dryRunLog) add(message DryRunMessage) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.messages = append(d.messages, message)
+	if len(d.messages) > maxDryRunMessages {
+		d.messages = append([]DryRunMessage(nil), d.messages[len(d.messages)-maxDryRunMessages:]...)
+	}
+}
+
+// latest returns up to n would-be messages, newest first
+func (d *dryRunLog) latest(n int) []DryRunMessage {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if n <= 0 || n > len(d.messages) {
+		n = len(d.messages)
+	}
+	messages := make([]DryRunMessage, 0, n)
+	for i := len(d.messages) - 1; i >= len(d.messages)-n; i-- {
+		messages = append(messages, d.messages[i])
+	}
+	return messages
+}
+
+// setDryRun makes the agent compute its responses without publishing them.
+// It reads the topics with consumer groups of its own, so it doesn't take
+// messages away from a live agent with the same ID.
+func (a *BaseAgent) setDryRun() {
+	a.dryRun = true
+}
+
+// DryRun reports whether the agent keeps its messages to itself
+func (a *BaseAgent) DryRun() bool {
+	return a.dryRun
+}
+
+// DryRunMessages returns up to n of the messages the agent would have sent, newest first
+func (a *BaseAgent) DryRunMessages(n int) []DryRunMessage {
+	return a.dryRuns.latest(n)
+}
+
+// holdBack records a chat message instead of publishing it when the agent dry-runs
+func (a *BaseAgent) holdBack(message *types.ChatMessage) bool {
+	if !a.dryRun {
+		return false
+	}
+	log.Printf("Agent %s (dry run) would send %s message: %s", a.id, message.Type, message.Content)
+	a.dryRuns.add(DryRunMessage{At: time.Now(), Message: message})
+	return true
+}
+
+// holdBackAgentMessage records a direct message instead of publishing it when the agent dry-runs
+func (a *BaseAgent) holdBackAgentMessage(message *types.AgentMessage) bool {
+	if !a.dryRun {
+		return false
+	}
+	log.Printf("Agent %s (dry run) would send a %s message to %s", a.id, message.Type, firstNonEmpty(message.ToAgent, "every agent"))
+	a.dryRuns.add(DryRunMessage{At: time.Now(), AgentMessage: message})
+	return true
+}
+
+// groupID returns the consumer group of one of the agent's subscriptions
+func (a *BaseAgent) groupID(prefix string) string {
+	if a.dryRun {
+		return prefix + "dryrun-" + a.id
+	}
+	return prefix + a.id
+}
@@ -0,0 +1,142 @@
+package agent
+
+import (
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"philoking/internal/config"
+	"philoking/internal/types"
+)
+
+// engagementTracker adapts an agent's response chance to how users react to
+// it: replies raise the chance, messages nobody reacts to lower it, and the
+// adjustment decays back towards the configured chance over time
+type engagementTracker struct {
+	cfg       config.AdaptiveConfig
+	boost     float64              // Added to the configured chance
+	updatedAt time.Time            // When boost was last decayed
+	pending   map[string]time.Time // Agent message ID -> sent at, awaiting a reaction
+	mu        sync.Mutex
+}
+
+func newEngagementTracker(cfg config.AdaptiveConfig) *engagementTracker {
+	return &engagementTracker{
+		cfg:       cfg,
+		updatedAt: time.Now(),
+		pending:   make(map[string]time.Time),
+	}
+}
+
+// sent starts waiting for reactions to one of the agent's messages
+func (e *engagementTracker) sent(messageID string, at time.Time) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.pending[messageID] = at
+}
+
+// observe credits the agent when a user message reacts to it: the message
+// replies to or mentions the agent, or directly follows the agent's message
+func (e *engagementTracker) observe(agentID, agentName string, message, previous *types.ChatMessage, now time.Time) {
+	if message.Type != types.MessageTypeUser {
+		return
+	}
+
+	addressed := message.Metadata.ReplyTo == agentID ||
+		strings.Contains(strings.ToLower(message.Content), strings.ToLower(agentName))
+	followed := previous != nil && previous.AgentID == agentID
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.expire(now)
+	if len(e.pending) == 0 || !(addressed || followed) {
+		return
+	}
+
+	// One reaction is enough to count all recent messages as engaging
+	for id := range e.pending {
+		delete(e.pending, id)
+	}
+	e.adjust(e.cfg.Step, now)
+}
+
+// rate applies a user's explicit rating of one of the agent's messages
+func (e *engagementTracker) rate(positive bool, now time.Time) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if positive {
+		e.adjust(e.cfg.Step, now)
+	} else {
+		e.adjust(-e.cfg.Step, now)
+	}
+}
+
+// chance returns the effective response chance for a configured base chance.
+// Agents that always respond, such as summarizers and judges, are not adapted.
+func (e *engagementTracker) chance(base float64, now time.Time) float64 {
+	if base >= 1 {
+		return base
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.expire(now)
+	e.decay(now)
+	return math.Min(math.Max(base+e.boost, e.cfg.MinChance), e.cfg.MaxChance)
+}
+
+// snapshot copies the tracker's state
+func (e *engagementTracker) snapshot() *EngagementState {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	pending := make(map[string]time.Time, len(e.pending))
+	for id, at := range e.pending {
+		pending[id] = at
+	}
+	return &EngagementState{Boost: e.boost, UpdatedAt: e.updatedAt, Pending: pending}
+}
+
+// restore continues from a saved state; the boost keeps decaying from when it was saved
+func (e *engagementTracker) restore(state *EngagementState) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	limit := e.cfg.MaxChance - e.cfg.MinChance
+	e.boost = math.Min(math.Max(state.Boost, -limit), limit)
+	e.updatedAt = state.UpdatedAt
+	for id, at := range state.Pending {
+		e.pending[id] = at
+	}
+}
+
+// expire counts messages nobody reacted to within the window as ignored
+func (e *engagementTracker) expire(now time.Time) {
+	for id, at := range e.pending {
+		if now.Sub(at) >= e.cfg.Window {
+			delete(e.pending, id)
+			e.adjust(-e.cfg.Step, now)
+		}
+	}
+}
+
+// adjust decays the boost to now and then changes it by delta, keeping it
+// within the distance between the configured bounds
+func (e *engagementTracker) adjust(delta float64, now time.Time) {
+	e.decay(now)
+	limit := e.cfg.MaxChance - e.cfg.MinChance
+	e.boost = math.Min(math.Max(e.boost+delta, -limit), limit)
+}
+
+// decay halves the boost every half-life
+func (e *engagementTracker) decay(now time.Time) {
+	if e.cfg.HalfLife > 0 && now.After(e.updatedAt) {
+		e.boost *= math.Pow(0.5, float64(now.Sub(e.updatedAt))/float64(e.cfg.HalfLife))
+	}
+	e.updatedAt = now
+}
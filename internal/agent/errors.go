@@ -0,0 +1,90 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"philoking/internal/config"
+	"philoking/internal/kafka"
+	"philoking/internal/quota"
+	"philoking/internal/types"
+
+	"github.com/google/uuid"
+)
+
+// ProviderError is an LLM call the provider failed: it could not be reached,
+// or answered with an error status
+type ProviderError struct {
+	Provider   string
+	Model      string
+	StatusCode int    // 0 when the provider could not be reached
+	Body       string // What the provider answered with an error status
+	Err        error  // Why the provider could not be reached
+}
+
+func (e *ProviderError) Error() string {
+	if e.StatusCode != 0 {
+		return fmt.Sprintf("%s API error: %d - %s", e.Provider, e.StatusCode, e.Body)
+	}
+	return fmt.Sprintf("failed to make %s request: %v", e.Provider, e.Err)
+}
+
+func (e *ProviderError) Unwrap() error {
+	return e.Err
+}
+
+// errorKind classifies a failure for the error feed
+func errorKind(err error) types.ErrorKind {
+	var provider *ProviderError
+	var broker *kafka.BrokerError
+	var invalid *config.ValidationError
+	switch {
+	case errors.Is(err, ErrHandlerPanic):
+		return types.ErrorKindPanic
+	case errors.As(err, &provider):
+		return types.ErrorKindProvider
+	case errors.As(err, &broker):
+		return types.ErrorKindBroker
+	case errors.As(err, &invalid):
+		return types.ErrorKindValidation
+	case errors.Is(err, context.DeadlineExceeded):
+		return types.ErrorKindTimeout
+	default:
+		return types.ErrorKindInternal
+	}
+}
+
+// reportError publishes a failure handling a message to the error feed.
+// Quotas running out is expected and not reported, and neither is a failure
+// after ctx is done: handle reports the timeout itself.
+func (a *BaseAgent) reportError(ctx context.Context, message *types.ChatMessage, err error) {
+	var exceeded *quota.ExceededError
+	if a.dryRun || !a.kafkaClient.ErrorsEnabled() || errors.As(err, &exceeded) || ctx.Err() != nil {
+		return
+	}
+
+	event := &types.ErrorEvent{
+		ID:             uuid.New().String(),
+		Kind:           errorKind(err),
+		AgentID:        a.id,
+		ConversationID: message.Metadata.ConversationID,
+		MessageID:      message.ID,
+		Error:          err.Error(),
+		Timestamp:      time.Now(),
+	}
+	var provider *ProviderError
+	if errors.As(err, &provider) {
+		event.Provider, event.StatusCode = provider.Provider, provider.StatusCode
+	}
+	var broker *kafka.BrokerError
+	if errors.As(err, &broker) {
+		event.Topic = broker.Topic
+	}
+
+	if err := a.kafkaClient.PublishError(ctx, event); err != nil {
+		log.Printf("Agent %s failed to report an error: %v", a.id, err)
+	}
+}
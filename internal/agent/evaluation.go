@@ -0,0 +1,114 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"philoking/internal/evaluation"
+	"philoking/internal/jsonschema"
+	"philoking/internal/types"
+)
+
+// maxEvaluationTranscript bounds the bytes of a conversation the judge of an evaluation reads
+const maxEvaluationTranscript = 12000
+
+// EvaluateConversation scores each agent taking part in a conversation on
+// coherence, repetitiveness and engagement; it judges the evaluation reports
+func (l *LLMAgent) EvaluateConversation(ctx context.Context, conversationID string, messages []*types.ChatMessage) (map[string]evaluation.Scores, error) {
+	var agents []string
+	seen := make(map[string]bool)
+	var transcript strings.Builder
+	for _, message := range messages {
+		if message.Type == types.MessageTypeAgent && !seen[message.AgentID] {
+			seen[message.AgentID] = true
+			agents = append(agents, message.AgentID)
+		}
+		fmt.Fprintf(&transcript, "%s [%s]: %s\n", senderName(message), message.AgentID, message.Content)
+	}
+	if len(agents) == 0 {
+		return nil, fmt.Errorf("no agent took part")
+	}
+	text := transcript.String()
+	if len(text) > maxEvaluationTranscript {
+		text = text[len(text)-maxEvaluationTranscript:]
+	}
+
+	systemPrompt := "You evaluate how the AI agents in a group discussion did. For each agent, by the ID in brackets, " +
+		"score from 1 to 10: coherence (how well its messages follow the discussion and hang together), " +
+		"repetitiveness (how much it repeats itself or the others; 10 is very repetitive) and " +
+		"engagement (how much it draws the others in and moves the discussion on). Add a one-sentence note on what stands out."
+	response, err := l.completeStructured(ctx, conversationID, []Message{
+		{Role: "system", Content: systemPrompt},
+		{Role: "user", Content: fmt.Sprintf("The discussion:\n%s\nAgents to score: %s", text, strings.Join(agents, ", "))},
+	}, evaluationSchema(agents))
+	if err != nil {
+		return nil, err
+	}
+	return parseEvaluation(response, agents)
+}
+
+// evaluationSchema describes the judge's answer: the scores of each agent, by ID
+func evaluationSchema(agents []string) *jsonschema.Schema {
+	score := map[string]interface{}{"type": "integer", "minimum": 1, "maximum": 10}
+	properties := make(map[string]interface{}, len(agents))
+	for _, agent := range agents {
+		properties[agent] = map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"coherence":      score,
+				"repetitiveness": score,
+				"engagement":     score,
+				"note":           map[string]interface{}{"type": "string"},
+			},
+			"required": []string{"coherence", "repetitiveness", "engagement"},
+		}
+	}
+	data, _ := json.Marshal(map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+		"required":   agents,
+	})
+	return jsonschema.MustParse(string(data))
+}
+
+// parseEvaluation extracts the agents' scores from the judge's JSON answer,
+// clamping them to 1-10 and skipping agents it left out
+func parseEvaluation(response string, agents []string) (map[string]evaluation.Scores, error) {
+	start, end := strings.Index(response, "{"), strings.LastIndex(response, "}")
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("no JSON object in evaluation: %q", response)
+	}
+
+	var raw map[string]struct {
+		Coherence      float64 `json:"coherence"`
+		Repetitiveness float64 `json:"repetitiveness"`
+		Engagement     float64 `json:"engagement"`
+		Note           string  `json:"note"`
+	}
+	if err := json.Unmarshal([]byte(response[start:end+1]), &raw); err != nil {
+		return nil, fmt.Errorf("invalid evaluation: %w", err)
+	}
+
+	clamp := func(score float64) int {
+		return min(max(int(score+0.5), 1), 10)
+	}
+	scores := make(map[string]evaluation.Scores, len(agents))
+	for _, agent := range agents {
+		given, ok := raw[agent]
+		if !ok {
+			continue
+		}
+		scores[agent] = evaluation.Scores{
+			Coherence:      clamp(given.Coherence),
+			Repetitiveness: clamp(given.Repetitiveness),
+			Engagement:     clamp(given.Engagement),
+			Note:           strings.TrimSpace(given.Note),
+		}
+	}
+	if len(scores) == 0 {
+		return nil, fmt.Errorf("the evaluation scores none of the agents")
+	}
+	return scores, nil
+}
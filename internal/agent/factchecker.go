@@ -0,0 +1,106 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"philoking/internal/config"
+	"philoking/internal/conversation"
+	"philoking/internal/kafka"
+	"philoking/internal/search"
+	"philoking/internal/types"
+)
+
+// noCorrection is the reply the model gives when there is nothing to correct
+const noCorrection = "NONE"
+
+// FactCheckerAgent monitors claims in the chat and posts sourced corrections
+type FactCheckerAgent struct {
+	*LLMAgent
+	tools []Tool
+}
+
+// NewFactCheckerAgent creates a new fact-checker agent
+func NewFactCheckerAgent(id, name, description string, kafkaClient *kafka.Client, config config.AgentsConfig, responseChance float64, convManager *conversation.Manager, searchClient search.Client) *FactCheckerAgent {
+	llm := NewLLMAgent(id, name, description, kafkaClient, config, responseChance, convManager)
+	agent := &FactCheckerAgent{
+		LLMAgent: llm,
+		tools:    []Tool{&searchTool{client: searchClient}},
+	}
+
+	// Set the message handler
+	agent.SetHandler(agent)
+
+	return agent
+}
+
+// HandleMessage checks the claims in a message and posts a correction if needed
+func (f *FactCheckerAgent) HandleMessage(ctx context.Context, message *types.ChatMessage) error {
+	if message.IsCommand() {
+		return nil
+	}
+
+	systemPrompt := "You are a fact-checker in a group chat. Look for concrete factual claims in the latest message. " +
+		"If it makes no checkable claim, or every claim is accurate, reply with exactly " + noCorrection + ". " +
+		"Otherwise verify the claim with a web search and reply with a short, friendly correction that cites the source links you used."
+	systemPrompt += f.personality()
+
+	sender := message.AgentID
+	if message.Metadata.FromAgent != "" {
+		sender = message.Metadata.FromAgent
+	}
+
+	messages := []Message{
+		{Role: "system", Content: systemPrompt},
+		{Role: "user", Content: fmt.Sprintf("%s: %s", sender, message.Content)},
+	}
+
+	response, err := f.completeWithTools(ctx, message.Metadata.ConversationID, messages, f.tools)
+	if err != nil {
+		log.Printf("Error fact-checking message %s: %v", message.ID, err)
+		return nil
+	}
+
+	response = f.cleanResponse(strings.TrimSpace(response))
+	if response == "" || strings.HasPrefix(strings.ToUpper(response), noCorrection) {
+		return nil
+	}
+
+	log.Printf("FactCheckerAgent posting correction: %s", response)
+	if err := f.SendMessage(ctx, response, message.Metadata.ConversationID); err != nil && !errors.Is(err, ErrDuplicateResponse) {
+		return err
+	}
+	return nil
+}
+
+// searchTool exposes a web search client as an agent tool
+type searchTool struct {
+	client search.Client
+}
+
+func (t *searchTool) Name() string {
+	return "web_search"
+}
+
+func (t *searchTool) Description() string {
+	return "searches the web; input is a search query, output is a list of titles, links and snippets"
+}
+
+func (t *searchTool) Call(ctx context.Context, input string) (string, error) {
+	results, err := t.client.Search(ctx, input)
+	if err != nil {
+		return "", err
+	}
+	if len(results) == 0 {
+		return "no results", nil
+	}
+
+	var b strings.Builder
+	for i, r := range results {
+		fmt.Fprintf(&b, "%d. %s (%s): %s\n", i+1, r.Title, r.URL, r.Snippet)
+	}
+	return b.String(), nil
+}
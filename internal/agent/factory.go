@@ -1,30 +1,83 @@
 package agent
 
 import (
+	"fmt"
 	"log"
+	"net/http"
+	"time"
 
 	"philoking/internal/config"
 	"philoking/internal/conversation"
+	"philoking/internal/embedding"
+	"philoking/internal/enrich"
+	"philoking/internal/fetch"
+	"philoking/internal/jsonschema"
 	"philoking/internal/kafka"
+	"philoking/internal/quota"
+	"philoking/internal/redact"
+	"philoking/internal/rules"
+	"philoking/internal/search"
+	"philoking/internal/tasks"
 )
 
+// SupportedTypes lists the agent types the factory can create
+var SupportedTypes = []string{"llm", "echo", "summarizer", "factchecker", "utility", "judge", "router", "script", "question_of_the_day"}
+
 // Factory creates agents from configuration
 type Factory struct {
 	kafkaClient         *kafka.Client
 	conversationManager *conversation.Manager
+	quotas              *quota.Limiter
+	claims              *kafka.Claimer
+	redactor            *redact.Redactor
+	httpClient          *http.Client        // Shared by all LLM agents
+	fetcher             *fetch.Fetcher      // Nil unless agents can read linked pages
+	tasks               *tasks.Queue        // Nil unless the task queue is enabled
+	enricher            *enrich.Enricher    // Nil without context providers
+	searcher            *embedding.Searcher // Nil unless embeddings are enabled
+	crossConversation   bool                // Agents also search and cite the other conversations they took part in
 }
 
-// NewFactory creates a new agent factory
-func NewFactory(kafkaClient *kafka.Client, convManager *conversation.Manager) *Factory {
+// NewFactory creates a new agent factory; claims may be nil when agents run as
+// a single replica, and redactor may be nil when prompts are not redacted
+func NewFactory(kafkaClient *kafka.Client, convManager *conversation.Manager, quotas *quota.Limiter, claims *kafka.Claimer, redactor *redact.Redactor, httpClient *http.Client) *Factory {
 	return &Factory{
 		kafkaClient:         kafkaClient,
 		conversationManager: convManager,
+		quotas:              quotas,
+		claims:              claims,
+		redactor:            redactor,
+		httpClient:          httpClient,
 	}
 }
 
+// UseFetcher lets the agents it creates read the pages people link to
+func (f *Factory) UseFetcher(fetcher *fetch.Fetcher) {
+	f.fetcher = fetcher
+}
+
+// UseTasks lets the agents it creates with task_tools start background tasks
+func (f *Factory) UseTasks(queue *tasks.Queue) {
+	f.tasks = queue
+}
+
+// UseEnricher lets the LLM agents it creates add outside facts to their prompts
+func (f *Factory) UseEnricher(enricher *enrich.Enricher) {
+	f.enricher = enricher
+}
+
+// UseSearcher lets the LLM agents it creates search earlier messages by
+// meaning and, with crossConversation, cite those of other conversations
+func (f *Factory) UseSearcher(searcher *embedding.Searcher, crossConversation bool) {
+	f.searcher = searcher
+	f.crossConversation = crossConversation
+}
+
 // CreateAgents creates agents from configuration based on their type
 func (f *Factory) CreateAgents(agentConfigs []config.AgentConfig, agentsConfig config.AgentsConfig) []Agent {
 	var agents []Agent
+	var routers []*RouterAgent
+	var discussants []config.AgentConfig
 
 	for _, agentConfig := range agentConfigs {
 		if !agentConfig.IsEnabled {
@@ -32,16 +85,104 @@ func (f *Factory) CreateAgents(agentConfigs []config.AgentConfig, agentsConfig c
 			continue
 		}
 
-		agent := f.createAgent(agentConfig, agentsConfig)
+		agent := f.build(agentConfig, agentsConfig)
 		if agent != nil {
+			if router, ok := agent.(*RouterAgent); ok {
+				routers = append(routers, router)
+			}
+			if agentConfig.Type == "llm" {
+				discussants = append(discussants, agentConfig)
+			}
 			agents = append(agents, agent)
 			log.Printf("Created %s agent: %s - %s", agentConfig.Type, agentConfig.Name, agentConfig.Description)
 		}
 	}
 
+	// A router decides who answers user messages, instead of chance
+	if len(routers) > 0 {
+		for _, router := range routers {
+			router.useSpecialists(discussants)
+		}
+		for _, agent := range agents {
+			if routed, ok := agent.(*LLMAgent); ok {
+				routed.setRouted()
+			}
+		}
+	}
+
 	return agents
 }
 
+// CreateAgent creates an agent at runtime, e.g. from the API. Unlike the
+// agents from the configuration it starts at the end of the chat topics
+// unless it sets start_from, and routers don't hand it questions.
+func (f *Factory) CreateAgent(agentConfig config.AgentConfig, agentsConfig config.AgentsConfig) (Agent, error) {
+	agentConfig.ApplyPreset()
+	errs := agentConfig.Validate()
+	if _, ok := agentsConfig.Plugin(agentConfig.Type); !ok && !IsSupportedType(agentConfig.Type) {
+		errs = append(errs, fmt.Errorf("agent %s: unknown type %q (supported: %v and plugin types)", agentConfig.ID, agentConfig.Type, SupportedTypes))
+	}
+	if len(errs) > 0 {
+		return nil, &config.ValidationError{Errs: errs}
+	}
+
+	agent := f.build(agentConfig, agentsConfig)
+	if agent == nil {
+		return nil, fmt.Errorf("agent %s could not be created, see the log", agentConfig.ID)
+	}
+	if late, ok := agent.(interface{ startAtLatest() }); ok && agentConfig.StartFrom == "" {
+		late.startAtLatest()
+	}
+	return agent, nil
+}
+
+// build creates an agent and applies the settings shared by all agent types
+func (f *Factory) build(agentConfig config.AgentConfig, agentsConfig config.AgentsConfig) Agent {
+	agent := f.createAgent(agentConfig, agentsConfig)
+	if agent == nil {
+		return nil
+	}
+
+	if positioned, ok := agent.(interface{ setStartFrom(string) }); ok && agentConfig.StartFrom != "" {
+		positioned.setStartFrom(agentConfig.StartFrom)
+	}
+	timeout := agentsConfig.MessageTimeout
+	if agentConfig.MessageTimeout > 0 {
+		timeout = agentConfig.MessageTimeout
+	}
+	if limited, ok := agent.(interface{ setMessageTimeout(time.Duration) }); ok && timeout > 0 {
+		limited.setMessageTimeout(timeout)
+	}
+	if claimant, ok := agent.(interface{ setClaimer(*kafka.Claimer) }); ok && f.claims != nil {
+		claimant.setClaimer(f.claims)
+	}
+	if adaptive, ok := agent.(interface{ setEngagement(config.AdaptiveConfig) }); ok && agentsConfig.Adaptive.Enabled {
+		adaptive.setEngagement(agentsConfig.Adaptive)
+	}
+	if dryRunning, ok := agent.(interface{ setDryRun() }); ok && (agentsConfig.DryRun || agentConfig.DryRun) {
+		dryRunning.setDryRun()
+	}
+	if ruled, ok := agent.(interface{ setRules(*rules.Engine) }); ok && len(agentConfig.Rules) > 0 {
+		engine, err := newRules(agentConfig.Rules)
+		if err != nil {
+			log.Printf("Warning: Ignoring the rules of agent %s: %v", agentConfig.ID, err)
+		} else {
+			ruled.setRules(engine)
+		}
+	}
+	return agent
+}
+
+// IsSupportedType reports whether the factory can create the given built-in type
+func IsSupportedType(agentType string) bool {
+	for _, t := range SupportedTypes {
+		if t == agentType {
+			return true
+		}
+	}
+	return false
+}
+
 // createAgent creates a single agent from configuration based on its type
 func (f *Factory) createAgent(agentConfig config.AgentConfig, agentsConfig config.AgentsConfig) Agent {
 	// Validate required fields
@@ -60,13 +201,40 @@ func (f *Factory) createAgent(agentConfig config.AgentConfig, agentsConfig confi
 		agentConfig.ResponseChance = 0.7
 	}
 
+	if agentConfig.Model != "" {
+		agentsConfig.Model = agentConfig.Model
+	}
+	if agentConfig.Temperature != 0 {
+		agentsConfig.Temperature = agentConfig.Temperature
+	}
+	if agentConfig.HistoryRoles != "" {
+		agentsConfig.HistoryRoles = agentConfig.HistoryRoles
+	}
+
 	// Create agent based on type
 	switch agentConfig.Type {
 	case "llm":
 		return f.createLLMAgent(agentConfig, agentsConfig)
 	case "echo":
 		return f.createEchoAgent(agentConfig)
+	case "summarizer":
+		return f.createSummarizerAgent(agentConfig, agentsConfig)
+	case "factchecker":
+		return f.createFactCheckerAgent(agentConfig, agentsConfig)
+	case "utility":
+		return f.createUtilityAgent(agentConfig)
+	case "judge":
+		return f.createJudgeAgent(agentConfig, agentsConfig)
+	case "router":
+		return f.createRouterAgent(agentConfig, agentsConfig)
+	case "script":
+		return f.createScriptAgent(agentConfig)
+	case "question_of_the_day":
+		return f.createQuestionOfTheDayAgent(agentConfig, agentsConfig)
 	default:
+		if plugin, ok := agentsConfig.Plugin(agentConfig.Type); ok {
+			return f.createPluginAgent(agentConfig, plugin)
+		}
 		log.Printf("Warning: Unknown agent type '%s' for agent %s, skipping", agentConfig.Type, agentConfig.ID)
 		return nil
 	}
@@ -74,7 +242,33 @@ func (f *Factory) createAgent(agentConfig config.AgentConfig, agentsConfig confi
 
 // createLLMAgent creates an LLM agent
 func (f *Factory) createLLMAgent(agentConfig config.AgentConfig, agentsConfig config.AgentsConfig) Agent {
-	return NewLLMAgent(agentConfig.ID, agentConfig.Name, agentConfig.Description, f.kafkaClient, agentsConfig, agentConfig.ResponseChance, f.conversationManager)
+	agent := NewLLMAgent(agentConfig.ID, agentConfig.Name, agentConfig.Description, f.kafkaClient, agentsConfig, agentConfig.ResponseChance, f.conversationManager)
+	agent.votesInPolls = agentConfig.VoteInPolls
+	agent.worldAccess = agentConfig.WorldTools
+	agent.whiteboardAccess = agentConfig.WhiteboardTools
+	agent.reminderAccess = agentConfig.ReminderTools
+	agent.fetcher = f.fetcher
+	agent.enricher = f.enricher
+	agent.searcher = f.searcher
+	agent.crossConversation = f.crossConversation
+	agent.scratchpad = agentConfig.Scratchpad
+	if agentConfig.TaskTools {
+		agent.tasks = f.tasks
+	}
+	if agentConfig.DirectMessages {
+		agent.enableDirectMessages()
+	}
+	agent.traits = agentConfig.Traits
+	agent.instructions = agentConfig.Instructions
+	agent.quotas = f.quotas
+	agent.redactor = f.redactor
+	agent.client = f.httpClient
+	agent.useCompletion(mergeCompletion(agentsConfig.Completion, agentConfig.Completion))
+	agent.outputSchema = outputSchema(agentConfig)
+	if agentConfig.Shadow.Enabled {
+		agent.shadow = f.createShadow(agentConfig, agentsConfig).BaseAgent
+	}
+	return agent
 }
 
 // createEchoAgent creates an echo agent
@@ -82,6 +276,117 @@ func (f *Factory) createEchoAgent(agentConfig config.AgentConfig) Agent {
 	return NewEchoAgent(agentConfig.ID, agentConfig.Name, f.kafkaClient, agentConfig.ResponseChance, f.conversationManager)
 }
 
+// createSummarizerAgent creates a summarizer agent
+func (f *Factory) createSummarizerAgent(agentConfig config.AgentConfig, agentsConfig config.AgentsConfig) Agent {
+	agent := NewSummarizerAgent(agentConfig.ID, agentConfig.Name, agentConfig.Description, f.kafkaClient, agentsConfig, agentConfig.SummaryInterval, f.conversationManager)
+	agent.traits = agentConfig.Traits
+	agent.instructions = agentConfig.Instructions
+	agent.quotas = f.quotas
+	agent.redactor = f.redactor
+	agent.client = f.httpClient
+	agent.useCompletion(mergeCompletion(agentsConfig.Completion, agentConfig.Completion))
+	return agent
+}
+
+// createQuestionOfTheDayAgent creates an agent that opens scheduled conversations with a question
+func (f *Factory) createQuestionOfTheDayAgent(agentConfig config.AgentConfig, agentsConfig config.AgentsConfig) Agent {
+	agent := NewQuestionOfTheDayAgent(agentConfig.ID, agentConfig.Name, agentConfig.Description, f.kafkaClient, agentsConfig, agentConfig.Theme, f.conversationManager)
+	agent.traits = agentConfig.Traits
+	agent.instructions = agentConfig.Instructions
+	agent.quotas = f.quotas
+	agent.redactor = f.redactor
+	agent.client = f.httpClient
+	agent.useCompletion(mergeCompletion(agentsConfig.Completion, agentConfig.Completion))
+	return agent
+}
+
+// createFactCheckerAgent creates a fact-checker agent backed by the configured search API
+func (f *Factory) createFactCheckerAgent(agentConfig config.AgentConfig, agentsConfig config.AgentsConfig) Agent {
+	searchClient, err := search.NewClient(agentsConfig.Search)
+	if err != nil {
+		log.Printf("Warning: Cannot create fact-checker %s: %v, skipping", agentConfig.ID, err)
+		return nil
+	}
+
+	agent := NewFactCheckerAgent(agentConfig.ID, agentConfig.Name, agentConfig.Description, f.kafkaClient, agentsConfig, agentConfig.ResponseChance, f.conversationManager, searchClient)
+	if f.fetcher != nil {
+		agent.tools = append(agent.tools, &fetchTool{fetcher: f.fetcher})
+	}
+	agent.traits = agentConfig.Traits
+	agent.instructions = agentConfig.Instructions
+	agent.quotas = f.quotas
+	agent.redactor = f.redactor
+	agent.client = f.httpClient
+	agent.useCompletion(mergeCompletion(agentsConfig.Completion, agentConfig.Completion))
+	return agent
+}
+
+// createUtilityAgent creates a utility agent that answers commands
+func (f *Factory) createUtilityAgent(agentConfig config.AgentConfig) Agent {
+	return NewUtilityAgent(agentConfig.ID, agentConfig.Name, f.kafkaClient, f.conversationManager)
+}
+
+// createJudgeAgent creates a judge agent that scores the other agents' replies
+func (f *Factory) createJudgeAgent(agentConfig config.AgentConfig, agentsConfig config.AgentsConfig) Agent {
+	agent := NewJudgeAgent(agentConfig.ID, agentConfig.Name, agentConfig.Description, f.kafkaClient, agentsConfig, agentConfig.Rubric, f.conversationManager)
+	agent.traits = agentConfig.Traits
+	agent.instructions = agentConfig.Instructions
+	agent.quotas = f.quotas
+	agent.redactor = f.redactor
+	agent.client = f.httpClient
+	agent.useCompletion(mergeCompletion(agentsConfig.Completion, agentConfig.Completion))
+	agent.outputSchema = outputSchema(agentConfig)
+	return agent
+}
+
+// createRouterAgent creates a router agent that hands user messages to specialists
+func (f *Factory) createRouterAgent(agentConfig config.AgentConfig, agentsConfig config.AgentsConfig) Agent {
+	agent := NewRouterAgent(agentConfig.ID, agentConfig.Name, agentConfig.Description, f.kafkaClient, agentsConfig, agentConfig.Specialists, f.conversationManager)
+	agent.quotas = f.quotas
+	agent.redactor = f.redactor
+	agent.client = f.httpClient
+	agent.useCompletion(mergeCompletion(agentsConfig.Completion, agentConfig.Completion))
+	return agent
+}
+
+// createPluginAgent creates an agent of a type provided by a plugin program
+func (f *Factory) createPluginAgent(agentConfig config.AgentConfig, plugin config.PluginConfig) Agent {
+	return NewPluginAgent(agentConfig.ID, agentConfig.Name, agentConfig.Description, f.kafkaClient, plugin, agentConfig.Settings, agentConfig.ResponseChance, f.conversationManager)
+}
+
+// createScriptAgent creates an agent whose handler is a Lua script
+func (f *Factory) createScriptAgent(agentConfig config.AgentConfig) Agent {
+	agent, err := NewScriptAgent(agentConfig.ID, agentConfig.Name, agentConfig.Description, agentConfig.Script, f.kafkaClient, agentConfig.Settings, agentConfig.ResponseChance, f.conversationManager)
+	if err != nil {
+		log.Printf("Warning: Cannot create script agent %s: %v, skipping", agentConfig.ID, err)
+		return nil
+	}
+	return agent
+}
+
+// newRules compiles an agent's respond/ignore rules
+func newRules(configs []config.RuleConfig) (*rules.Engine, error) {
+	list := make([]rules.Rule, 0, len(configs))
+	for _, rule := range configs {
+		list = append(list, rules.Rule{When: rule.When, Action: rules.Action(rule.Action)})
+	}
+	return rules.New(list)
+}
+
+// outputSchema parses an agent's output schema; config validation reports
+// invalid schemas, which are ignored here
+func outputSchema(agentConfig config.AgentConfig) *jsonschema.Schema {
+	if agentConfig.OutputSchema == "" {
+		return nil
+	}
+	schema, err := jsonschema.Parse([]byte(agentConfig.OutputSchema))
+	if err != nil {
+		log.Printf("Warning: Ignoring output schema of agent %s: %v", agentConfig.ID, err)
+		return nil
+	}
+	return schema
+}
+
 // RegisterAgentsInConversationFlow registers agents in the conversation flow
 func (f *Factory) RegisterAgentsInConversationFlow(flowManager *conversation.FlowManager, agentConfigs []config.AgentConfig) {
 	for _, agentConfig := range agentConfigs {
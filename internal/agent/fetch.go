@@ -0,0 +1,53 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"philoking/internal/fetch"
+)
+
+// fetchTools returns the tool agents read linked pages with, if fetching is enabled
+func (l *LLMAgent) fetchTools() []Tool {
+	if l.fetcher == nil {
+		return nil
+	}
+	return []Tool{&fetchTool{fetcher: l.fetcher}}
+}
+
+// fetchTool exposes a page fetcher as an agent tool
+type fetchTool struct {
+	fetcher *fetch.Fetcher
+}
+
+func (t *fetchTool) Name() string {
+	return "fetch_url"
+}
+
+func (t *fetchTool) Description() string {
+	return "reads a web page, e.g. a link someone posted, and returns its title and main text; input is the URL"
+}
+
+func (t *fetchTool) Call(ctx context.Context, input string) (string, error) {
+	page, err := t.fetcher.Fetch(ctx, strings.Trim(strings.TrimSpace(input), "<>\"'"))
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "URL: %s\n", page.URL)
+	if page.Title != "" {
+		fmt.Fprintf(&b, "Title: %s\n", page.Title)
+	}
+	b.WriteString("\n")
+	if page.Text == "" {
+		b.WriteString("(the page has no readable text)")
+	} else {
+		b.WriteString(page.Text)
+	}
+	if page.Truncated {
+		b.WriteString("\n\n(the page was cut short)")
+	}
+	return b.String(), nil
+}
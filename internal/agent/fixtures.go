@@ -0,0 +1,139 @@
+package agent
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"philoking/internal/config"
+)
+
+// ReplayProvider serves recorded LLM responses from fixtures instead of calling a model
+const ReplayProvider = "replay"
+
+// Fixture is a recorded LLM call: the prompt as sent and the provider's answer
+type Fixture struct {
+	Provider         string    `json:"provider"`
+	Model            string    `json:"model,omitempty"`
+	Messages         []Message `json:"messages"`
+	Response         string    `json:"response"`
+	PromptTokens     int       `json:"prompt_tokens,omitempty"`
+	CompletionTokens int       `json:"completion_tokens,omitempty"`
+}
+
+// Fixtures records LLM calls to a directory and serves them back, one JSON
+// file per prompt, so agents can run offline and deterministically
+type Fixtures struct {
+	dir    string
+	record bool
+}
+
+// NewFixtures returns the fixtures configured for LLM agents, or nil when no
+// fixture directory is set
+func NewFixtures(cfg config.FixturesConfig) *Fixtures {
+	if cfg.Dir == "" {
+		return nil
+	}
+	return &Fixtures{dir: cfg.Dir, record: cfg.Record}
+}
+
+// FixtureKey identifies a prompt; the provider is left out so a prompt
+// recorded with one provider replays under any configuration
+func FixtureKey(messages []Message) string {
+	data, _ := json.Marshal(messages)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:8])
+}
+
+// Recording reports whether provider calls are saved as fixtures
+func (f *Fixtures) Recording() bool {
+	return f != nil && f.record
+}
+
+// Record saves a provider's answer to a prompt, replacing an earlier recording
+func (f *Fixtures) Record(provider, model string, messages []Message, completion *Completion) error {
+	fixture := Fixture{
+		Provider:         provider,
+		Model:            model,
+		Messages:         messages,
+		Response:         completion.Content,
+		PromptTokens:     completion.PromptTokens,
+		CompletionTokens: completion.CompletionTokens,
+	}
+	data, err := json.MarshalIndent(fixture, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode fixture: %w", err)
+	}
+
+	if err := os.MkdirAll(f.dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create fixture directory: %w", err)
+	}
+
+	// Agents record concurrently; write aside and rename so readers never see a partial file
+	path := f.path(messages)
+	tmp, err := os.CreateTemp(f.dir, ".fixture-*")
+	if err != nil {
+		return fmt.Errorf("failed to write fixture: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write fixture: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write fixture: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write fixture: %w", err)
+	}
+	return nil
+}
+
+// Replay returns the recorded answer to a prompt
+func (f *Fixtures) Replay(messages []Message) (*Completion, error) {
+	if f == nil {
+		return nil, fmt.Errorf("the %s provider needs agents.fixtures.dir", ReplayProvider)
+	}
+
+	path := f.path(messages)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("no recorded response for prompt %s; record it with agents.fixtures.record", FixtureKey(messages))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fixture: %w", err)
+	}
+
+	var fixture Fixture
+	if err := json.Unmarshal(data, &fixture); err != nil {
+		return nil, fmt.Errorf("invalid fixture %s: %w", path, err)
+	}
+	return &Completion{
+		Content:          fixture.Response,
+		PromptTokens:     fixture.PromptTokens,
+		CompletionTokens: fixture.CompletionTokens,
+	}, nil
+}
+
+// path returns the fixture file for a prompt
+func (f *Fixtures) path(messages []Message) string {
+	return filepath.Join(f.dir, FixtureKey(messages)+".json")
+}
+
+// recordFixture saves a completion when recording, logging failures so a
+// read-only fixture directory never breaks the conversation
+func (l *LLMAgent) recordFixture(provider string, messages []Message, completion *Completion) {
+	if !l.fixtures.Recording() || provider == ReplayProvider || provider == ScriptedProvider {
+		return
+	}
+	if err := l.fixtures.Record(provider, l.config.Model, messages, completion); err != nil {
+		log.Printf("Agent %s failed to record LLM fixture: %v", l.id, err)
+	}
+}
@@ -0,0 +1,197 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"text/template"
+
+	"philoking/internal/config"
+	"philoking/internal/jsonschema"
+	"philoking/internal/llmhttp"
+)
+
+// OllamaGenerateProvider sends raw prompts to Ollama's generate endpoint, for
+// local models that work best with their own prompt format
+const OllamaGenerateProvider = "ollama-generate"
+
+// defaultCompletionTemplate renders the chat as a plain transcript
+const defaultCompletionTemplate = `{{.System}}
+
+{{range .History}}{{.Content}}
+{{end}}{{.Prompt}}
+{{.Agent}}:`
+
+// OllamaGenerateRequest represents a request to Ollama's generate endpoint
+type OllamaGenerateRequest struct {
+	Model   string                `json:"model"`
+	Prompt  string                `json:"prompt"`
+	Raw     bool                  `json:"raw"` // The prompt is already in the model's format
+	Stream  bool                  `json:"stream"`
+	Options OllamaGenerateOptions `json:"options,omitempty"`
+	Format  *jsonschema.Schema    `json:"format,omitempty"` // Constrains the answer to a JSON schema
+}
+
+// OllamaGenerateOptions represents options for generate requests
+type OllamaGenerateOptions struct {
+	OllamaOptions
+	Stop []string `json:"stop,omitempty"`
+}
+
+// OllamaGenerateResponse represents the response from Ollama's generate endpoint
+type OllamaGenerateResponse struct {
+	Response        string `json:"response"`
+	Done            bool   `json:"done"`
+	PromptEvalCount int    `json:"prompt_eval_count"`
+	EvalCount       int    `json:"eval_count"`
+}
+
+// completionData is what completion templates can refer to
+type completionData struct {
+	System  string    // The system prompt
+	History []Message // Messages between the system prompt and the prompt
+	Prompt  string    // The message being answered
+	Agent   string    // The agent's display name
+}
+
+// completionFormat is an agent's compiled completion configuration
+type completionFormat struct {
+	template *template.Template
+	prefix   string
+	suffix   string
+	stop     []string
+}
+
+// mergeCompletion applies an agent's own completion settings over the shared ones
+func mergeCompletion(shared, own config.CompletionConfig) config.CompletionConfig {
+	cfg := shared
+	if own.Template != "" {
+		cfg.Template = own.Template
+	}
+	if own.Prefix != "" {
+		cfg.Prefix = own.Prefix
+	}
+	if own.Suffix != "" {
+		cfg.Suffix = own.Suffix
+	}
+	if len(own.Stop) > 0 {
+		cfg.Stop = own.Stop
+	}
+	return cfg
+}
+
+// useCompletion sets the prompt format the agent uses with the ollama-generate provider
+func (l *LLMAgent) useCompletion(cfg config.CompletionConfig) {
+	source := cfg.Template
+	if source == "" {
+		source = defaultCompletionTemplate
+	}
+	tmpl, err := template.New("completion").Parse(source)
+	if err != nil {
+		log.Printf("Agent %s has an invalid completion template, using the default: %v", l.id, err)
+		tmpl = template.Must(template.New("completion").Parse(defaultCompletionTemplate))
+	}
+
+	l.completion = &completionFormat{template: tmpl, prefix: cfg.Prefix, suffix: cfg.Suffix, stop: cfg.Stop}
+}
+
+// renderCompletionPrompt turns chat messages into a raw prompt
+func (l *LLMAgent) renderCompletionPrompt(messages []Message) (string, error) {
+	var data completionData
+	data.Agent = l.Name()
+	rest := messages
+	if len(rest) > 0 && rest[0].Role == "system" {
+		data.System = rest[0].Content
+		rest = rest[1:]
+	}
+	if len(rest) > 0 {
+		data.Prompt = rest[len(rest)-1].Content
+		data.History = make([]Message, 0, len(rest)-1)
+		for _, msg := range rest[:len(rest)-1] {
+			// Unless every agent speaks in assistant turns, those are the agent's
+			// own and carry no name; a raw transcript needs it
+			if _, _, named := speakerPrefix(msg.Content, []string{data.Agent}); msg.Role == "assistant" && l.config.HistoryRoles != "agents" && !named {
+				msg.Content = data.Agent + ": " + msg.Content
+			}
+			data.History = append(data.History, msg)
+		}
+	}
+
+	var prompt strings.Builder
+	prompt.WriteString(l.completion.prefix)
+	if err := l.completion.template.Execute(&prompt, data); err != nil {
+		return "", fmt.Errorf("failed to render completion prompt: %w", err)
+	}
+	prompt.WriteString(l.completion.suffix)
+	return prompt.String(), nil
+}
+
+// generateOllamaCompletion generates a response using Ollama's generate endpoint
+func (l *LLMAgent) generateOllamaCompletion(ctx context.Context, messages []Message, schema *jsonschema.Schema) (*Completion, error) {
+	prompt, err := l.renderCompletionPrompt(messages)
+	if err != nil {
+		return nil, err
+	}
+
+	reqBody := OllamaGenerateRequest{
+		Model:  l.config.Model,
+		Prompt: prompt,
+		Raw:    true,
+		Stream: false,
+		Options: OllamaGenerateOptions{
+			OllamaOptions: OllamaOptions{
+				Temperature: l.temperature(ctx),
+				TopP:        0.9,
+				TopK:        40,
+			},
+			Stop: l.completion.stop,
+		},
+		Format: schema,
+	}
+
+	jsonData, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal Ollama request: %w", err)
+	}
+
+	url := l.config.OllamaURL + "/api/generate"
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Ollama request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	llmhttp.SetHeaders(req, l.config.HTTP, "ollama")
+
+	resp, err := l.client.Do(req)
+	if err != nil {
+		return nil, &ProviderError{Provider: OllamaGenerateProvider, Model: l.config.Model, Err: err}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, &ProviderError{Provider: OllamaGenerateProvider, Model: l.config.Model, StatusCode: resp.StatusCode, Body: string(body)}
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Ollama response: %w", err)
+	}
+	var generated OllamaGenerateResponse
+	if err := json.Unmarshal(body, &generated); err != nil {
+		return nil, fmt.Errorf("failed to decode Ollama response: %w", err)
+	}
+
+	return &Completion{
+		Content:          strings.TrimSpace(generated.Response),
+		Raw:              string(body),
+		PromptTokens:     generated.PromptEvalCount,
+		CompletionTokens: generated.EvalCount,
+	}, nil
+}
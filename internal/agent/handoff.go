@@ -0,0 +1,112 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"philoking/internal/conversation"
+	"philoking/internal/types"
+
+	"github.com/google/uuid"
+)
+
+// anyAgent addresses a handoff to whichever agent accepts it first
+const anyAgent = "anyone"
+
+// enableHandoffs lets the agent answer the tasks other agents hand off and
+// take them on once the conversation flow assigns them
+func (l *LLMAgent) enableHandoffs() {
+	l.OnAgentMessage(types.AgentMessageHandoff, l.answerHandoff)
+	l.OnAgentMessage(types.AgentMessageHandoffAssign, l.takeOverHandoff)
+}
+
+// answerHandoff tells the conversation flow whether the agent takes a handoff:
+// it accepts one for a conversation it takes part in and declines one meant
+// for it otherwise
+func (l *LLMAgent) answerHandoff(ctx context.Context, message *types.AgentMessage) error {
+	var handoff types.HandoffPayload
+	if err := message.DecodePayload(&handoff); err != nil || handoff.HandoffID == "" || handoff.Task == "" {
+		return nil // Nothing to answer
+	}
+
+	answer := types.HandoffPayload{HandoffID: handoff.HandoffID}
+	switch {
+	case l.convManager == nil || l.convManager.IsMember(message.ConversationID, l.id):
+		return l.SendAgentMessage(ctx, conversation.OrchestratorID, types.AgentMessageHandoffAccept, message.ConversationID, answer)
+	case message.ToAgent == l.id:
+		answer.Reason = "not taking part in the conversation"
+		return l.SendAgentMessage(ctx, conversation.OrchestratorID, types.AgentMessageHandoffDecline, message.ConversationID, answer)
+	}
+	return nil
+}
+
+// takeOverHandoff replies to the conversation on a task assigned to the agent
+func (l *LLMAgent) takeOverHandoff(ctx context.Context, message *types.AgentMessage) error {
+	if message.FromAgent != conversation.OrchestratorID {
+		log.Printf("Agent %s ignored handoff assignment from %s", l.id, message.FromAgent)
+		return nil
+	}
+	var handoff types.HandoffPayload
+	if err := message.DecodePayload(&handoff); err != nil || handoff.Task == "" {
+		return nil
+	}
+
+	prompt := fmt.Sprintf("Another agent handed this task over to you, and it is yours now: %s.", handoff.Task)
+	if handoff.Context != "" {
+		prompt += " What they told you about it: " + handoff.Context
+	}
+	prompt += " Take it on in your reply to the group."
+
+	response, err := l.generateResponse(ctx, prompt, message.ConversationID, l.getConversationHistory(message.ConversationID))
+	if err != nil {
+		log.Printf("Agent %s failed to take over handoff %s: %v", l.id, handoff.HandoffID, err)
+		return nil
+	}
+	if err := l.SendMessage(ctx, l.cleanReply(response, message.ConversationID), message.ConversationID); err != nil && !errors.Is(err, ErrDuplicateResponse) {
+		return err
+	}
+	return nil
+}
+
+// handOffTool transfers a task to another agent
+type handOffTool struct {
+	agent          *BaseAgent
+	conversationID string
+}
+
+func (t *handOffTool) Name() string {
+	return "hand_off"
+}
+
+func (t *handOffTool) Description() string {
+	return "hands a task you can't or shouldn't do yourself over to another agent, who takes it on in the conversation; " +
+		"the input is the agent's id (or \"anyone\"), the task and optionally \" | \" and what they need to know, " +
+		"e.g. \"historian-agent find the date of the treaty | the user asked about the 1648 peace\""
+}
+
+func (t *handOffTool) Call(ctx context.Context, input string) (string, error) {
+	to, rest, _ := strings.Cut(strings.TrimSpace(input), " ")
+	task, background, _ := strings.Cut(rest, "|")
+	task, background = strings.TrimSpace(task), strings.TrimSpace(background)
+	if to == "" || task == "" {
+		return "", fmt.Errorf("input must be an agent id or \"anyone\" followed by the task")
+	}
+	if to == t.agent.id {
+		return "", fmt.Errorf("you can't hand a task over to yourself")
+	}
+	if to == anyAgent {
+		to = ""
+	}
+
+	payload := types.HandoffPayload{HandoffID: uuid.New().String(), Task: task, Context: background}
+	if err := t.agent.SendAgentMessage(ctx, to, types.AgentMessageHandoff, t.conversationID, payload); err != nil {
+		return "", err
+	}
+	if to == "" {
+		return "the task was offered to the other agents; the user is asked if nobody takes it", nil
+	}
+	return "the task was handed over to " + to + "; the user is asked if they don't take it", nil
+}
@@ -0,0 +1,88 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"philoking/internal/conversation"
+	"philoking/internal/embedding"
+)
+
+const (
+	historyResults = 5   // Messages a history search returns
+	historyChars   = 300 // Characters of each message shown
+)
+
+// historyTools returns the tool agents search earlier messages with, if embeddings are enabled
+func (l *LLMAgent) historyTools(conversationID string) []Tool {
+	if l.searcher == nil {
+		return nil
+	}
+	tool := &historyTool{searcher: l.searcher, conversationID: conversationID}
+	if l.crossConversation && l.convManager != nil {
+		tool.convManager, tool.agentID = l.convManager, l.id
+	}
+	return []Tool{tool}
+}
+
+// historyTool searches the earlier messages of a conversation by meaning and,
+// when the agent may cite them, those of the other conversations it took part in
+type historyTool struct {
+	searcher       *embedding.Searcher
+	convManager    *conversation.Manager // Nil unless other conversations are searched too
+	conversationID string
+	agentID        string
+}
+
+func (t *historyTool) Name() string {
+	return "search_history"
+}
+
+func (t *historyTool) Description() string {
+	if t.convManager != nil {
+		return "finds earlier messages by meaning, in this conversation (also those too old for you to see) and in other conversations you took part in, " +
+			"e.g. \"what the user said about Kant last week\"; input is what to look for. Messages from other conversations come with a reference like [1]: " +
+			"when you use one, put its reference after what you took from it"
+	}
+	return "finds earlier messages of this conversation by meaning, also those too old for you to see, e.g. \"what the user said about Kant last week\"; input is what to look for"
+}
+
+func (t *historyTool) Call(ctx context.Context, input string) (string, error) {
+	// Other conversations are only searched when the reply can cite them
+	cited := citationsFrom(ctx)
+	conversationIDs := []string{t.conversationID}
+	if t.convManager != nil && cited != nil {
+		conversationIDs = append(conversationIDs, t.convManager.CitableConversations(t.agentID, t.conversationID)...)
+	}
+	matches, err := t.searcher.Search(ctx, input, conversationIDs, historyResults)
+	if err != nil {
+		return "", err
+	}
+	if len(matches) == 0 {
+		return "no earlier messages match", nil
+	}
+
+	var b strings.Builder
+	for _, match := range matches {
+		sender := match.Name
+		if sender == "" {
+			sender = match.Sender
+		}
+		content := match.Content
+		if runes := []rune(content); len(runes) > historyChars {
+			content = string(runes[:historyChars]) + "…"
+		}
+		if match.ConversationID == t.conversationID {
+			fmt.Fprintf(&b, "- %s, %s: %s\n", match.At.Format("Mon 2 Jan 15:04"), sender, content)
+			continue
+		}
+		title := match.ConversationID
+		if info, ok := t.convManager.GetInfo(match.ConversationID); ok && info.Title != "" {
+			title = info.Title
+		}
+		ref := cited.add(match.ConversationID, match.MessageID, title)
+		fmt.Fprintf(&b, "- [%s] in %q, %s, %s: %s\n", ref, title, match.At.Format("Mon 2 Jan 15:04"), sender, content)
+	}
+	return b.String(), nil
+}
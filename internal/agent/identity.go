@@ -0,0 +1,122 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// promptMiddleware rewrites the chat messages of an LLM call before they are sent
+type promptMiddleware func(ctx context.Context, conversationID string, messages []Message) []Message
+
+// usePromptMiddleware adds middleware that every LLM call of the agent passes through, in order
+func (l *LLMAgent) usePromptMiddleware(middleware ...promptMiddleware) {
+	l.middleware = append(l.middleware, middleware...)
+}
+
+// applyMiddleware passes the messages of an LLM call through the agent's middleware
+func (l *LLMAgent) applyMiddleware(ctx context.Context, conversationID string, messages []Message) []Message {
+	for _, middleware := range l.middleware {
+		messages = middleware(ctx, conversationID, messages)
+	}
+	return messages
+}
+
+// reinforceIdentity reminds the model who it speaks as right before the
+// message it answers, where a long history makes it lose track of the
+// system prompt and slip into the other participants' voices
+func (l *LLMAgent) reinforceIdentity(ctx context.Context, conversationID string, messages []Message) []Message {
+	// The system prompt and the message answered are not history
+	if len(messages) < 2 || len(messages)-2 < l.config.Identity.MinHistory {
+		return messages
+	}
+
+	reminder := Message{Role: "system", Content: l.identityReminder(conversationID)}
+	reinforced := make([]Message, 0, len(messages)+1)
+	reinforced = append(reinforced, messages[:len(messages)-1]...)
+	return append(reinforced, reminder, messages[len(messages)-1])
+}
+
+// identityReminder tells the model who it is and whom it must not speak for
+func (l *LLMAgent) identityReminder(conversationID string) string {
+	reminder := fmt.Sprintf("Remember: you are %s. Stay in character and write only your own next message.", l.Name())
+	if others := l.otherParticipants(conversationID); len(others) > 0 {
+		names := others[0]
+		if len(others) > 1 {
+			names = strings.Join(others[:len(others)-1], ", ") + " or " + others[len(others)-1]
+		}
+		reminder += fmt.Sprintf(" Never speak as %s or write their lines.", names)
+	}
+	return reminder + " Don't start your message with a name and a colon."
+}
+
+// otherParticipants names the people and agents the agent talks with
+func (l *LLMAgent) otherParticipants(conversationID string) []string {
+	if l.convManager == nil {
+		return nil
+	}
+
+	var names []string
+	seen := map[string]bool{l.Name(): true}
+	for _, participant := range l.convManager.GetParticipants(conversationID) {
+		if participant.ID == l.id || participant.Type == "system" || participant.Name == "" || seen[participant.Name] {
+			continue
+		}
+		seen[participant.Name] = true
+		names = append(names, participant.Name)
+	}
+	return names
+}
+
+// cleanReply cleans a reply like cleanResponse and, when identity is
+// reinforced, strips the "Name:" prefixes the model copies from the history
+// and cuts the reply where it starts writing another participant's lines
+func (l *LLMAgent) cleanReply(response, conversationID string) string {
+	cleaned := l.cleanResponse(response)
+	if !l.config.Identity.Enabled {
+		return cleaned
+	}
+
+	own := []string{l.Name()}
+	others := l.otherParticipants(conversationID)
+	lines := strings.Split(cleaned, "\n")
+	for i, line := range lines {
+		if _, rest, ok := speakerPrefix(line, own); ok {
+			lines[i] = rest
+			continue
+		}
+		name, rest, ok := speakerPrefix(line, others)
+		if !ok {
+			continue
+		}
+		if i == 0 {
+			// The reply opens with the wrong name; what follows is still the agent's
+			log.Printf("Agent %s began its reply as %s", l.id, name)
+			lines[i] = rest
+			continue
+		}
+		log.Printf("Agent %s cut the lines it wrote for %s from its reply", l.id, name)
+		lines = lines[:i]
+		break
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// speakerPrefix finds which of the names a line starts with, followed by a
+// colon, ignoring case and markdown emphasis such as "**Name:**", and returns
+// it with the rest of the line
+func speakerPrefix(line string, names []string) (string, string, bool) {
+	trimmed := strings.TrimLeft(strings.TrimSpace(line), "*_")
+	for _, name := range names {
+		if name == "" || len(trimmed) <= len(name) || !strings.EqualFold(trimmed[:len(name)], name) {
+			continue
+		}
+		rest := strings.TrimLeft(trimmed[len(name):], " *_")
+		if !strings.HasPrefix(rest, ":") {
+			continue
+		}
+		return name, strings.TrimSpace(strings.TrimLeft(rest[1:], "*_")), true
+	}
+	return "", "", false
+}
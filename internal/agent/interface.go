@@ -2,6 +2,7 @@ package agent
 
 import (
 	"context"
+	"philoking/internal/conversation"
 	"philoking/internal/types"
 )
 
@@ -23,6 +24,22 @@ type Agent interface {
 	ProcessMessage(ctx context.Context, message *types.ChatMessage) error
 }
 
+// AgentMessageHandler handles one type of direct message from another agent
+type AgentMessageHandler func(ctx context.Context, message *types.AgentMessage) error
+
+// DirectMessenger is implemented by agents that exchange direct messages
+// with other agents, e.g. to coordinate or hand over work. These messages are
+// never broadcast to the conversation.
+type DirectMessenger interface {
+	// OnAgentMessage registers the handler of a message type before the agent
+	// starts; the "" handler takes the types without their own
+	OnAgentMessage(messageType string, handler AgentMessageHandler)
+
+	// SendAgentMessage sends a direct message to another agent, or to every
+	// agent when to is empty
+	SendAgentMessage(ctx context.Context, to, messageType, conversationID string, payload interface{}) error
+}
+
 // MessageHandler defines how agents handle messages
 type MessageHandler interface {
 	HandleMessage(ctx context.Context, message *types.ChatMessage) error
@@ -32,3 +49,13 @@ type MessageHandler interface {
 type LLMProvider interface {
 	GenerateResponse(ctx context.Context, prompt string, conversation []types.ChatMessage) (string, error)
 }
+
+// Summarizer is implemented by agents that can produce conversation digests on demand
+type Summarizer interface {
+	Summarize(ctx context.Context, conversationID string) (*conversation.Summary, error)
+}
+
+// FeedbackReceiver is implemented by agents that learn from users rating their messages
+type FeedbackReceiver interface {
+	ReceiveFeedback(positive bool)
+}
@@ -0,0 +1,189 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"philoking/internal/config"
+	"philoking/internal/conversation"
+	"philoking/internal/jsonschema"
+	"philoking/internal/kafka"
+	"philoking/internal/types"
+)
+
+// DefaultRubric is used when a judge has no rubric configured
+var DefaultRubric = []string{"relevance", "novelty", "civility"}
+
+// JudgeAgent scores every agent reply against a rubric and posts the scores
+// as score messages, which feed the leaderboard
+type JudgeAgent struct {
+	*LLMAgent
+	rubric []string
+}
+
+// NewJudgeAgent creates a new judge agent
+func NewJudgeAgent(id, name, description string, kafkaClient *kafka.Client, config config.AgentsConfig, rubric []string, convManager *conversation.Manager) *JudgeAgent {
+	if len(rubric) == 0 {
+		rubric = DefaultRubric
+	}
+
+	// The judge sees every reply; it never joins the discussion itself
+	llm := NewLLMAgent(id, name, description, kafkaClient, config, 1.0, convManager)
+	agent := &JudgeAgent{
+		LLMAgent: llm,
+		rubric:   normalizeRubric(rubric),
+	}
+
+	// Set the message handler
+	agent.SetHandler(agent)
+
+	return agent
+}
+
+// HandleMessage scores agent replies, and gives the verdict when a debate it judges is over
+func (j *JudgeAgent) HandleMessage(ctx context.Context, message *types.ChatMessage) error {
+	if hasTag(message, conversation.DebateTag) && message.Metadata.ReplyTo == j.id {
+		return j.LLMAgent.HandleMessage(ctx, message)
+	}
+	if message.Type != types.MessageTypeAgent || message.IsCommand() {
+		return nil
+	}
+
+	scores, err := j.score(ctx, message)
+	if err != nil {
+		log.Printf("Error judging message %s: %v", message.ID, err)
+		return nil
+	}
+
+	return j.publish(ctx, j.newScoreMessage(message, scores))
+}
+
+// score asks the LLM to rate a reply, in the context of the recent conversation
+func (j *JudgeAgent) score(ctx context.Context, message *types.ChatMessage) (map[string]int, error) {
+	systemPrompt := fmt.Sprintf("You judge replies in a group discussion. Score the latest reply from 1 (poor) to 10 (excellent) on each criterion: %s. "+
+		"Answer with a single JSON object mapping each criterion to its score, and nothing else.", strings.Join(j.rubric, ", "))
+	systemPrompt += j.personality()
+
+	var transcript strings.Builder
+	for _, msg := range j.getConversationHistory(message.Metadata.ConversationID) {
+		if msg.ID == message.ID {
+			break
+		}
+		fmt.Fprintf(&transcript, "%s: %s\n", senderName(msg), msg.Content)
+	}
+	recent := transcript.String()
+	if len(recent) > 4000 {
+		recent = recent[len(recent)-4000:]
+	}
+
+	messages := []Message{
+		{Role: "system", Content: systemPrompt},
+		{Role: "user", Content: fmt.Sprintf("Conversation so far:\n%s\nReply to judge:\n%s: %s", recent, senderName(message), message.Content)},
+	}
+
+	schema := j.outputSchema
+	if schema == nil {
+		schema = rubricSchema(j.rubric)
+	}
+	response, err := j.completeStructured(ctx, message.Metadata.ConversationID, messages, schema)
+	if err != nil {
+		return nil, err
+	}
+	return parseScores(response, j.rubric)
+}
+
+// rubricSchema describes the judge's answer: an integer score per criterion
+func rubricSchema(rubric []string) *jsonschema.Schema {
+	properties := make(map[string]interface{}, len(rubric))
+	for _, criterion := range rubric {
+		properties[criterion] = map[string]interface{}{"type": "integer", "minimum": 1, "maximum": 10}
+	}
+	data, _ := json.Marshal(map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+		"required":   rubric,
+	})
+	return jsonschema.MustParse(string(data))
+}
+
+// newScoreMessage builds the score message for a judged reply
+func (j *JudgeAgent) newScoreMessage(judged *types.ChatMessage, scores map[string]int) *types.ChatMessage {
+	message := j.newMessage("", judged.Metadata.ConversationID)
+	message.Type = types.MessageTypeScore
+	message.Metadata.ReplyTo = judged.AgentID
+	message.Metadata.Custom = map[string]string{
+		types.ScoredMessageKey: judged.ID,
+		types.ScoredAgentKey:   judged.AgentID,
+	}
+
+	parts := make([]string, 0, len(j.rubric))
+	for _, criterion := range j.rubric {
+		message.Metadata.Custom[criterion] = strconv.Itoa(scores[criterion])
+		parts = append(parts, fmt.Sprintf("%s %d/10", criterion, scores[criterion]))
+	}
+	message.Content = fmt.Sprintf("%s: %s", senderName(judged), strings.Join(parts, ", "))
+	return message
+}
+
+// parseScores extracts the rubric scores from the model's JSON answer,
+// tolerating text around the object and scores given as strings
+func parseScores(response string, rubric []string) (map[string]int, error) {
+	start, end := strings.Index(response, "{"), strings.LastIndex(response, "}")
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("no JSON object in judge response: %q", response)
+	}
+
+	var raw map[string]interface{}
+	if err := json.Unmarshal([]byte(response[start:end+1]), &raw); err != nil {
+		return nil, fmt.Errorf("invalid judge response: %w", err)
+	}
+
+	values := make(map[string]interface{}, len(raw))
+	for key, value := range raw {
+		values[strings.ToLower(strings.TrimSpace(key))] = value
+	}
+
+	scores := make(map[string]int, len(rubric))
+	for _, criterion := range rubric {
+		var score float64
+		switch v := values[criterion].(type) {
+		case float64:
+			score = v
+		case string:
+			parsed, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s score %q", criterion, v)
+			}
+			score = parsed
+		default:
+			return nil, fmt.Errorf("judge response has no %s score", criterion)
+		}
+		scores[criterion] = min(max(int(score+0.5), 1), 10)
+	}
+	return scores, nil
+}
+
+// normalizeRubric lower-cases criteria and drops the reserved metadata keys
+func normalizeRubric(rubric []string) []string {
+	normalized := make([]string, 0, len(rubric))
+	for _, criterion := range rubric {
+		criterion = strings.ToLower(strings.TrimSpace(criterion))
+		if criterion == "" || criterion == types.ScoredMessageKey || criterion == types.ScoredAgentKey {
+			continue
+		}
+		normalized = append(normalized, criterion)
+	}
+	return normalized
+}
+
+// senderName returns the display name of a message's sender
+func senderName(message *types.ChatMessage) string {
+	if message.Metadata.FromAgent != "" {
+		return message.Metadata.FromAgent
+	}
+	return message.AgentID
+}
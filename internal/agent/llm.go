@@ -4,24 +4,59 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"strings"
 	"time"
 
 	"philoking/internal/config"
 	"philoking/internal/conversation"
+	"philoking/internal/embedding"
+	"philoking/internal/enrich"
+	"philoking/internal/fetch"
+	"philoking/internal/jsonschema"
 	"philoking/internal/kafka"
+	"philoking/internal/llmhttp"
+	"philoking/internal/quota"
+	"philoking/internal/redact"
+	"philoking/internal/tasks"
 	"philoking/internal/types"
 )
 
 // LLMAgent is an agent that uses an LLM API to generate responses
 type LLMAgent struct {
 	*BaseAgent
-	config      config.AgentsConfig
-	client      *http.Client
-	description string
+	config       config.AgentsConfig
+	client       *http.Client
+	description  string
+	traits       []string // Character traits, e.g. from a preset
+	instructions string   // How to take part in the discussion, e.g. from a preset
+	votesInPolls bool
+	quotas       *quota.Limiter
+	redactor     *redact.Redactor   // Nil when prompts are sent as they are
+	fixtures     *Fixtures          // Nil unless LLM calls are recorded or replayed
+	scripted     *scripted          // Responses of the scripted provider
+	completion   *completionFormat  // Prompt format of the ollama-generate provider
+	outputSchema *jsonschema.Schema // Nil unless replies must be JSON matching a schema
+	worldAccess  bool               // Reads and writes the story's world state through tools
+	// Reads and edits the conversation's whiteboard through tools
+	whiteboardAccess bool
+	reminderAccess   bool                // Schedules reminders through a tool
+	fetcher          *fetch.Fetcher      // Nil unless agents can read linked pages
+	enricher         *enrich.Enricher    // Nil without context providers
+	searcher         *embedding.Searcher // Nil unless agents can search earlier messages
+	// Also searches and cites the other conversations it took part in
+	crossConversation bool
+	directMessages    bool               // Sends and receives private notes from other agents
+	notes             agentNotes         // Notes received for the next reply
+	history           agentHistory       // The conversations as last read, see getConversationHistory
+	tasks             *tasks.Queue       // Nil unless the agent starts background tasks through a tool
+	scratchpad        bool               // Thinks in a hidden scratchpad before answering
+	calls             callLog            // Latest LLM calls, for the debug API
+	middleware        []promptMiddleware // Rewrites the messages of every LLM call
 }
 
 // LLMRequest represents a request to the LLM API (OpenAI format)
@@ -30,6 +65,20 @@ type LLMRequest struct {
 	Messages    []Message `json:"messages"`
 	MaxTokens   int       `json:"max_tokens,omitempty"`
 	Temperature float64   `json:"temperature,omitempty"`
+	// ResponseFormat constrains the answer to a JSON schema
+	ResponseFormat *ResponseFormat `json:"response_format,omitempty"`
+}
+
+// ResponseFormat asks OpenAI for JSON matching a schema
+type ResponseFormat struct {
+	Type       string          `json:"type"` // "json_schema"
+	JSONSchema *JSONSchemaSpec `json:"json_schema,omitempty"`
+}
+
+// JSONSchemaSpec names the schema of a structured OpenAI response
+type JSONSchemaSpec struct {
+	Name   string             `json:"name"`
+	Schema *jsonschema.Schema `json:"schema"`
 }
 
 // Message represents a message in the LLM conversation
@@ -41,11 +90,23 @@ type Message struct {
 // LLMResponse represents the response from the LLM API (OpenAI format)
 type LLMResponse struct {
 	Choices []Choice `json:"choices"`
+	Usage   struct {
+		PromptTokens     int `json:"prompt_tokens"`
+		CompletionTokens int `json:"completion_tokens"`
+	} `json:"usage"`
 }
 
 // Choice represents a choice in the LLM response
 type Choice struct {
-	Message Message `json:"message"`
+	Message ResponseMessage `json:"message"`
+}
+
+// ResponseMessage is the message a provider answers with, including the
+// reasoning thinking models return next to their answer
+type ResponseMessage struct {
+	Message
+	Thinking         string `json:"thinking,omitempty"`          // Ollama
+	ReasoningContent string `json:"reasoning_content,omitempty"` // OpenAI-compatible servers such as DeepSeek
 }
 
 // OllamaRequest represents a request to the Ollama API
@@ -54,6 +115,8 @@ type OllamaRequest struct {
 	Messages []Message     `json:"messages"`
 	Stream   bool          `json:"stream"`
 	Options  OllamaOptions `json:"options,omitempty"`
+	// Format constrains the answer to a JSON schema
+	Format *jsonschema.Schema `json:"format,omitempty"`
 }
 
 // OllamaOptions represents options for Ollama requests
@@ -65,10 +128,27 @@ type OllamaOptions struct {
 
 // OllamaResponse represents the response from the Ollama API
 type OllamaResponse struct {
-	Model     string  `json:"model"`
-	Message   Message `json:"message"`
-	Done      bool    `json:"done"`
-	CreatedAt string  `json:"created_at"`
+	Model     string          `json:"model"`
+	Message   ResponseMessage `json:"message"`
+	Done      bool            `json:"done"`
+	CreatedAt string          `json:"created_at"`
+	// Token counts reported by Ollama
+	PromptEvalCount int `json:"prompt_eval_count"`
+	EvalCount       int `json:"eval_count"`
+}
+
+// Completion is a provider's answer together with its token usage
+type Completion struct {
+	Content          string
+	Reasoning        string // What a thinking model reasoned before answering
+	Raw              string // The provider's response body, for debugging
+	PromptTokens     int
+	CompletionTokens int
+}
+
+// TotalTokens returns the number of prompt and completion tokens
+func (c *Completion) TotalTokens() int {
+	return c.PromptTokens + c.CompletionTokens
 }
 
 // NewLLMAgent creates a new LLM agent
@@ -78,8 +158,11 @@ func NewLLMAgent(id, name, description string, kafkaClient *kafka.Client, config
 		BaseAgent: base,
 		config:    config,
 		client: &http.Client{
-			Timeout: 30 * time.Second,
+			Timeout: llmhttp.DefaultTimeout,
 		},
+		fixtures: NewFixtures(config.Fixtures),
+		scripted: newScripted(config.Scripted),
+		calls:    callLog{limit: config.DebugCalls},
 	}
 
 	// Set the message handler
@@ -87,6 +170,10 @@ func NewLLMAgent(id, name, description string, kafkaClient *kafka.Client, config
 
 	// Store the description for use in system prompts
 	agent.description = description
+	agent.useCompletion(config.Completion)
+	if config.Identity.Enabled {
+		agent.usePromptMiddleware(agent.reinforceIdentity)
+	}
 
 	return agent
 }
@@ -95,34 +182,98 @@ func NewLLMAgent(id, name, description string, kafkaClient *kafka.Client, config
 func (l *LLMAgent) HandleMessage(ctx context.Context, message *types.ChatMessage) error {
 	log.Printf("LLMAgent received message from %s: %s", message.AgentID, message.Content)
 
+	// Commands are handled by utility agents, not part of the discussion
+	if message.IsCommand() {
+		return nil
+	}
+
+	// Poll announcements get a vote instead of a chat reply
+	if hasTag(message, conversation.PollTag) {
+		if l.votesInPolls {
+			return l.castVote(ctx, message)
+		}
+		return nil
+	}
+
 	// Get full conversation history
 	conversationHistory := l.getConversationHistory(message.Metadata.ConversationID)
 
+	// Messages of other conversations the agent is shown may be cited in the
+	// reply, and the LLM calls it takes are added up for its annotation
+	ctx = withUsage(withCitations(ctx))
+
+	// A question routed to this agent is answered as if it was asked directly
+	prompt := message.Content
+	if hasTag(message, RoutedTag) {
+		prompt = routedQuestion(message, conversationHistory)
+	}
+
 	// Call the LLM API to generate a response with full context
-	response, err := l.generateResponse(ctx, message.Content, message.Metadata.ConversationID, conversationHistory)
+	response, err := l.generateResponse(ctx, prompt, message.Metadata.ConversationID, conversationHistory)
 	if err != nil {
 		log.Printf("Error generating LLM response: %v", err)
-		// Don't send a response if LLM fails - just log the error
+		// Don't send a response if LLM fails - just report the error
+		l.reportError(ctx, message, err)
 		return nil
 	}
 
-	// Clean the response to remove any agent name prefixes
-	cleanResponse := l.cleanResponse(response)
+	// Clean the response to remove any agent name prefixes and lines written for others
+	cleanResponse := limitWords(l.cleanReply(response, message.Metadata.ConversationID), debateWordLimit(message))
+
+	// Responses that only restate the conversation are regenerated or dropped
+	cleanResponse, ok := l.avoidParroting(ctx, cleanResponse, prompt, message, conversationHistory)
+	if !ok {
+		return nil
+	}
+
+	// The tutor marks the end of a lesson for the moderator
+	if content, met := parseObjectiveMet(cleanResponse); met && l.tutors(message.Metadata.ConversationID) {
+		return l.sendObjectiveMet(ctx, content, message.Metadata.ConversationID)
+	}
+
+	if question, ok := parseHumanQuestion(cleanResponse); ok {
+		return l.AskHuman(ctx, question, message.Metadata.ConversationID)
+	}
 
 	log.Printf("LLMAgent sending response: %s", cleanResponse)
 
-	// Send response
-	return l.SendMessage(ctx, cleanResponse, message.Metadata.ConversationID)
+	// Send response, regenerating once if it repeats a recent message
+	err = l.sendReply(ctx, cleanResponse, message.Metadata.ConversationID)
+	if !errors.Is(err, ErrDuplicateResponse) {
+		return err
+	}
+
+	retryPrompt := prompt + "\n\n(You just said something very similar. Say something new or stay brief.)"
+	response, err = l.generateResponse(ctx, retryPrompt, message.Metadata.ConversationID, conversationHistory)
+	if err != nil {
+		log.Printf("Error regenerating LLM response: %v", err)
+		return nil
+	}
+
+	if err := l.sendReply(ctx, limitWords(l.cleanReply(response, message.Metadata.ConversationID), debateWordLimit(message)), message.Metadata.ConversationID); err != nil && !errors.Is(err, ErrDuplicateResponse) {
+		return err
+	}
+	return nil
 }
 
-// getConversationHistory retrieves the full conversation history
+// sendReply sends a reply with the citations it refers to and, if enabled,
+// what generating it took in its metadata
+func (l *LLMAgent) sendReply(ctx context.Context, content, conversationID string) error {
+	message := l.newMessage(content, conversationID)
+	message.SetCitations(citationsFrom(ctx).used(content))
+	if l.config.Usage.Annotate {
+		usageFrom(ctx).annotate(message)
+	}
+	return l.sendMessage(ctx, message)
+}
+
+// getConversationHistory retrieves the latest maxHistory messages of a
+// conversation, reading only those that arrived since the last call
 func (l *LLMAgent) getConversationHistory(conversationID string) []*types.ChatMessage {
 	if l.convManager == nil {
 		return []*types.ChatMessage{}
 	}
-
-	// Get all messages from the conversation (no limit)
-	return l.convManager.GetRecentMessages(conversationID, 1000) // Large limit to get all messages
+	return l.history.read(l.convManager, conversationID)
 }
 
 // cleanResponse removes agent name prefixes from the LLM response
@@ -156,32 +307,97 @@ func (l *LLMAgent) cleanResponse(response string) string {
 
 // generateResponse generates a response using the configured LLM provider
 func (l *LLMAgent) generateResponse(ctx context.Context, userMessage, conversationID string, conversationHistory []*types.ChatMessage) (string, error) {
-	// Determine which provider to use
-	provider := l.config.Provider
-	if provider == "" {
-		provider = "ollama" // Default to Ollama
+	systemPrompt := l.systemPrompt() + clockPrompt(l.config.Clock, time.Now())
+	if l.convManager != nil {
+		if goal := l.convManager.GetGoal(conversationID); goal != "" {
+			systemPrompt += fmt.Sprintf(" The goal of this conversation: %s", goal)
+		}
+		if phase := l.convManager.GetPhase(conversationID); phase != nil && phase.Prompt != "" {
+			systemPrompt += fmt.Sprintf(" The discussion is in its %s phase: %s", phase.Name, phase.Prompt)
+		}
+		if debate := l.convManager.GetActiveDebate(conversationID); debate != nil {
+			if stance := debate.StanceOf(l.id); stance != "" {
+				systemPrompt += fmt.Sprintf(" You are in a formal debate, arguing %s the proposition \"%s\". Stick to your side, address your opponents' arguments and keep to the moderator's word limit.", stance, debate.Proposition)
+			}
+		}
+		if tutoring := l.convManager.GetActiveTutoring(conversationID); tutoring != nil && tutoring.Tutor == l.id {
+			systemPrompt += tutorPrompt(tutoring)
+		}
+	}
+	if l.config.FeedbackInPrompt && l.convManager != nil {
+		if feedback := l.convManager.FeedbackPrompt(conversationID, l.id); feedback != "" {
+			systemPrompt += " " + feedback
+		}
+	}
+	systemPrompt += l.notesPrompt(conversationID)
+	if l.enricher != nil {
+		topic := ""
+		if l.convManager != nil {
+			if info, ok := l.convManager.GetInfo(conversationID); ok {
+				topic = info.Topic
+			}
+		}
+		systemPrompt += l.enricher.Prompt(ctx, userMessage, topic)
 	}
 
-	switch provider {
-	case "ollama":
-		return l.generateOllamaResponse(ctx, userMessage, conversationHistory)
-	case "openai":
-		return l.generateOpenAIResponse(ctx, userMessage, conversationHistory)
-	default:
-		return "", fmt.Errorf("unsupported LLM provider: %s", provider)
+	messages := l.buildMessages(systemPrompt, conversationHistory, userMessage)
+	if l.outputSchema != nil {
+		return l.completeStructured(ctx, conversationID, messages, l.outputSchema)
+	}
+	if l.dryRun {
+		// Tools that change shared state stay out of dry runs
+		return l.completeWithTools(ctx, conversationID, messages, append(l.fetchTools(), l.historyTools(conversationID)...))
 	}
+	tools := append(l.worldTools(conversationID), l.whiteboardTools(conversationID)...)
+	tools = append(tools, l.reminderTools(conversationID)...)
+	tools = append(tools, l.fetchTools()...)
+	tools = append(tools, l.historyTools(conversationID)...)
+	tools = append(tools, l.directMessageTools(conversationID)...)
+	tools = append(tools, l.taskTools(conversationID)...)
+	return l.completeWithTools(ctx, conversationID, messages, tools)
 }
 
-// generateOllamaResponse generates a response using Ollama
-func (l *LLMAgent) generateOllamaResponse(ctx context.Context, userMessage string, conversationHistory []*types.ChatMessage) (string, error) {
-	// Build conversation context
+// systemPrompt builds the system prompt for this agent
+func (l *LLMAgent) systemPrompt() string {
 	systemPrompt := "You're chatting in a group conversation. Keep it casual and natural like you're texting friends. No fancy formatting, lists, or sections - just talk like a normal person. Keep responses short and conversational. You can see the full chat history."
+	systemPrompt += " Only if you really can't continue without the human's input, reply with \"" + askHumanPrefix + "\" followed by a single short question for them."
 
-	// Add agent description if available
+	if l.scratchpad {
+		systemPrompt += scratchpadPrompt
+	}
+	return systemPrompt + l.personality()
+}
+
+// personality describes the agent's character and role for its system prompt
+func (l *LLMAgent) personality() string {
+	var parts []string
 	if l.description != "" {
-		systemPrompt += fmt.Sprintf(" Your personality: %s", l.description)
+		parts = append(parts, "Your personality: "+l.description)
+	}
+	if len(l.traits) > 0 {
+		parts = append(parts, "Your traits: "+strings.Join(l.traits, ", "))
+	}
+	if l.instructions != "" {
+		parts = append(parts, l.instructions)
+	}
+	if len(parts) == 0 {
+		return ""
 	}
 
+	// Separate the parts into sentences, leaving the last one as configured
+	for i := range parts[:len(parts)-1] {
+		if !strings.HasSuffix(parts[i], ".") && !strings.HasSuffix(parts[i], "!") && !strings.HasSuffix(parts[i], "?") {
+			parts[i] += "."
+		}
+	}
+	return " " + strings.Join(parts, " ")
+}
+
+// buildMessages converts the conversation history into LLM chat messages.
+// By default only the agent's own messages are assistant turns; everyone
+// else speaks in user turns that start with their name, so the model can
+// tell its earlier replies from the other participants'.
+func (l *LLMAgent) buildMessages(systemPrompt string, conversationHistory []*types.ChatMessage, userMessage string) []Message {
 	messages := []Message{
 		{
 			Role:    "system",
@@ -191,22 +407,28 @@ func (l *LLMAgent) generateOllamaResponse(ctx context.Context, userMessage strin
 
 	// Add conversation history
 	for _, msg := range conversationHistory {
-		role := "user"
-		if msg.Type == types.MessageTypeAgent {
-			role = "assistant"
+		sender := msg.Metadata.FromAgent
+		if sender == "" {
+			sender = msg.AgentID
+		}
+		if sender == "" {
+			sender = msg.UserID
 		}
 
-		sender := msg.AgentID
-		if msg.Metadata.FromAgent != "" {
-			sender = msg.Metadata.FromAgent
+		// Models copy names into their replies; drop one so it isn't doubled
+		content := msg.Content
+		if _, rest, ok := speakerPrefix(content, []string{sender}); ok {
+			content = rest
 		}
 
-		// Include sender info in the message
-		content := fmt.Sprintf("%s: %s", sender, msg.Content)
-		messages = append(messages, Message{
-			Role:    role,
-			Content: content,
-		})
+		switch {
+		case l.ownsMessage(msg):
+			messages = append(messages, Message{Role: "assistant", Content: content})
+		case l.config.HistoryRoles == "agents" && msg.Type == types.MessageTypeAgent:
+			messages = append(messages, Message{Role: "assistant", Content: fmt.Sprintf("%s: %s", sender, content)})
+		default:
+			messages = append(messages, Message{Role: "user", Content: fmt.Sprintf("%s: %s", sender, content)})
+		}
 	}
 
 	// Add the current user message
@@ -215,144 +437,238 @@ func (l *LLMAgent) generateOllamaResponse(ctx context.Context, userMessage strin
 		Content: userMessage,
 	})
 
+	return messages
+}
+
+// ownsMessage reports whether a message of the history is one of the agent's
+// own replies, which buildMessages makes assistant turns
+func (l *LLMAgent) ownsMessage(msg *types.ChatMessage) bool {
+	return l.config.HistoryRoles != "agents" && msg.Type == types.MessageTypeAgent && msg.AgentID == l.id
+}
+
+// complete sends the chat messages for a conversation to the configured LLM
+// provider, enforcing usage quotas and recording the call in the agent's stats
+func (l *LLMAgent) complete(ctx context.Context, conversationID string, messages []Message) (string, error) {
+	return l.completeWith(ctx, conversationID, messages, nil)
+}
+
+// completeWith is complete with an optional schema the provider must constrain its answer to
+func (l *LLMAgent) completeWith(ctx context.Context, conversationID string, messages []Message, schema *jsonschema.Schema) (string, error) {
+	provider := l.provider()
+
+	if l.quotas != nil {
+		if err := l.quotas.Allow(conversationID, provider); err != nil {
+			l.reportQuotaExceeded(ctx, conversationID, err)
+			return "", err
+		}
+	}
+
+	messages = l.applyMiddleware(ctx, conversationID, messages)
+	if l.redactor.Applies(provider) {
+		messages = l.redactMessages(ctx, messages)
+	}
+
+	start := time.Now()
+	completion, err := l.callProvider(ctx, provider, messages, schema)
+	took := time.Since(start)
+	l.stats.llmCall(took, err)
+	l.recordCall(conversationID, provider, messages, completion, err, took)
+	if err != nil {
+		return "", err
+	}
+	usageFrom(ctx).add(completion, took, l.price())
+	l.recordFixture(provider, messages, completion)
+
+	if l.quotas != nil {
+		l.quotas.RecordTokens(provider, completion.TotalTokens())
+	}
+	content, reasoning := splitThinking(completion.Content)
+	l.keepScratchNote(conversationID, strings.TrimSpace(completion.Reasoning+"\n"+reasoning))
+	return content, nil
+}
+
+// redactMessages returns a copy of the messages with personal data masked
+func (l *LLMAgent) redactMessages(ctx context.Context, messages []Message) []Message {
+	redacted := make([]Message, len(messages))
+	for i, message := range messages {
+		redacted[i] = Message{Role: message.Role, Content: l.redactor.Redact(ctx, message.Content)}
+	}
+	return redacted
+}
+
+// reportQuotaExceeded posts a system message the first time a quota is hit
+func (l *LLMAgent) reportQuotaExceeded(ctx context.Context, conversationID string, err error) {
+	log.Printf("Agent %s skipped LLM call: %v", l.id, err)
+
+	var exceeded *quota.ExceededError
+	if !errors.As(err, &exceeded) || !exceeded.FirstNotice {
+		return
+	}
+
+	notice := l.newMessage("⚠️ LLM usage "+exceeded.Error()+". Agents will stay quiet until the quota resets.", conversationID)
+	notice.Type = types.MessageTypeSystem
+	if l.holdBack(notice) {
+		return
+	}
+	if err := l.kafkaClient.PublishSigned(ctx, notice); err != nil {
+		log.Printf("Failed to publish quota notice: %v", err)
+	}
+}
+
+// provider returns the configured LLM provider name
+func (l *LLMAgent) provider() string {
+	if l.config.Provider == "" {
+		return "ollama" // Default to Ollama
+	}
+	return l.config.Provider
+}
+
+// callProvider dispatches the request to the given LLM provider
+func (l *LLMAgent) callProvider(ctx context.Context, provider string, messages []Message, schema *jsonschema.Schema) (*Completion, error) {
+	switch provider {
+	case "ollama":
+		return l.generateOllamaResponse(ctx, messages, schema)
+	case "openai":
+		return l.generateOpenAIResponse(ctx, messages, schema)
+	case OllamaGenerateProvider:
+		return l.generateOllamaCompletion(ctx, messages, schema)
+	case ReplayProvider:
+		return l.fixtures.Replay(messages)
+	case ScriptedProvider:
+		return l.scripted.respond(l, messages)
+	default:
+		return nil, fmt.Errorf("unsupported LLM provider: %s", provider)
+	}
+}
+
+// generateOllamaResponse generates a response using Ollama
+func (l *LLMAgent) generateOllamaResponse(ctx context.Context, messages []Message, schema *jsonschema.Schema) (*Completion, error) {
 	// Prepare the request
 	reqBody := OllamaRequest{
 		Model:    l.config.Model,
 		Messages: messages,
 		Stream:   false,
 		Options: OllamaOptions{
-			Temperature: 0.7,
+			Temperature: l.temperature(ctx),
 			TopP:        0.9,
 			TopK:        40,
 		},
+		Format: schema,
 	}
 
 	jsonData, err := json.Marshal(reqBody)
 	if err != nil {
-		return "", fmt.Errorf("failed to marshal Ollama request: %w", err)
+		return nil, fmt.Errorf("failed to marshal Ollama request: %w", err)
 	}
 
 	// Create HTTP request
 	url := l.config.OllamaURL + "/api/chat"
 	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
 	if err != nil {
-		return "", fmt.Errorf("failed to create Ollama request: %w", err)
+		return nil, fmt.Errorf("failed to create Ollama request: %w", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
+	llmhttp.SetHeaders(req, l.config.HTTP, "ollama")
 
 	// Make the request
 	resp, err := l.client.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("failed to make Ollama request: %w", err)
+		return nil, &ProviderError{Provider: "ollama", Model: l.config.Model, Err: err}
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("ollama API error: %d - %s", resp.StatusCode, string(body))
+		return nil, &ProviderError{Provider: "ollama", Model: l.config.Model, StatusCode: resp.StatusCode, Body: string(body)}
 	}
 
 	// Parse response
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Ollama response: %w", err)
+	}
 	var ollamaResp OllamaResponse
-	if err := json.NewDecoder(resp.Body).Decode(&ollamaResp); err != nil {
-		return "", fmt.Errorf("failed to decode Ollama response: %w", err)
+	if err := json.Unmarshal(body, &ollamaResp); err != nil {
+		return nil, fmt.Errorf("failed to decode Ollama response: %w", err)
 	}
 
-	return ollamaResp.Message.Content, nil
+	return &Completion{
+		Content:          ollamaResp.Message.Content,
+		Reasoning:        ollamaResp.Message.Thinking,
+		Raw:              string(body),
+		PromptTokens:     ollamaResp.PromptEvalCount,
+		CompletionTokens: ollamaResp.EvalCount,
+	}, nil
 }
 
 // generateOpenAIResponse generates a response using OpenAI API
-func (l *LLMAgent) generateOpenAIResponse(ctx context.Context, userMessage string, conversationHistory []*types.ChatMessage) (string, error) {
+func (l *LLMAgent) generateOpenAIResponse(ctx context.Context, messages []Message, schema *jsonschema.Schema) (*Completion, error) {
 	// If no API key is configured, return an error
 	if l.config.LLMAPIKey == "" {
-		return "", fmt.Errorf("OpenAI API key not configured")
-	}
-
-	// Build conversation context
-	systemPrompt := "You're chatting in a group conversation. Keep it casual and natural like you're texting friends. No fancy formatting, lists, or sections - just talk like a normal person. Keep responses short and conversational. You can see the full chat history."
-
-	// Add agent description if available
-	if l.description != "" {
-		systemPrompt += fmt.Sprintf(" Your personality: %s", l.description)
-	}
-
-	messages := []Message{
-		{
-			Role:    "system",
-			Content: systemPrompt,
-		},
-	}
-
-	// Add conversation history
-	for _, msg := range conversationHistory {
-		role := "user"
-		if msg.Type == types.MessageTypeAgent {
-			role = "assistant"
-		}
-
-		sender := msg.AgentID
-		if msg.Metadata.FromAgent != "" {
-			sender = msg.Metadata.FromAgent
-		}
-
-		// Include sender info in the message
-		content := fmt.Sprintf("%s: %s", sender, msg.Content)
-		messages = append(messages, Message{
-			Role:    role,
-			Content: content,
-		})
+		return nil, fmt.Errorf("OpenAI API key not configured")
 	}
 
-	// Add the current user message
-	messages = append(messages, Message{
-		Role:    "user",
-		Content: userMessage,
-	})
-
 	// Prepare the request
 	reqBody := LLMRequest{
 		Model:       "gpt-3.5-turbo",
 		Messages:    messages,
 		MaxTokens:   150,
-		Temperature: 0.7,
+		Temperature: l.temperature(ctx),
+	}
+	if schema != nil {
+		reqBody.ResponseFormat = &ResponseFormat{
+			Type:       "json_schema",
+			JSONSchema: &JSONSchemaSpec{Name: "response", Schema: schema},
+		}
 	}
 
 	jsonData, err := json.Marshal(reqBody)
 	if err != nil {
-		return "", fmt.Errorf("failed to marshal OpenAI request: %w", err)
+		return nil, fmt.Errorf("failed to marshal OpenAI request: %w", err)
 	}
 
 	// Create HTTP request
 	req, err := http.NewRequestWithContext(ctx, "POST", l.config.LLMURL, bytes.NewBuffer(jsonData))
 	if err != nil {
-		return "", fmt.Errorf("failed to create OpenAI request: %w", err)
+		return nil, fmt.Errorf("failed to create OpenAI request: %w", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Authorization", "Bearer "+l.config.LLMAPIKey)
+	llmhttp.SetHeaders(req, l.config.HTTP, "openai")
 
 	// Make the request
 	resp, err := l.client.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("failed to make OpenAI request: %w", err)
+		return nil, &ProviderError{Provider: "openai", Model: l.config.Model, Err: err}
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("OpenAI API error: %d - %s", resp.StatusCode, string(body))
+		return nil, &ProviderError{Provider: "openai", Model: l.config.Model, StatusCode: resp.StatusCode, Body: string(body)}
 	}
 
 	// Parse response
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read OpenAI response: %w", err)
+	}
 	var llmResp LLMResponse
-	if err := json.NewDecoder(resp.Body).Decode(&llmResp); err != nil {
-		return "", fmt.Errorf("failed to decode OpenAI response: %w", err)
+	if err := json.Unmarshal(body, &llmResp); err != nil {
+		return nil, fmt.Errorf("failed to decode OpenAI response: %w", err)
 	}
 
 	if len(llmResp.Choices) == 0 {
-		return "", fmt.Errorf("no choices in OpenAI response")
+		return nil, fmt.Errorf("no choices in OpenAI response")
 	}
 
-	return llmResp.Choices[0].Message.Content, nil
+	return &Completion{
+		Content:          llmResp.Choices[0].Message.Content,
+		Reasoning:        llmResp.Choices[0].Message.ReasoningContent,
+		Raw:              string(body),
+		PromptTokens:     llmResp.Usage.PromptTokens,
+		CompletionTokens: llmResp.Usage.CompletionTokens,
+	}, nil
 }
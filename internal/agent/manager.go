@@ -2,28 +2,47 @@ package agent
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"strings"
 	"sync"
+	"time"
 
 	"philoking/internal/config"
 	"philoking/internal/kafka"
+
+	"github.com/google/uuid"
+	"golang.org/x/sync/errgroup"
 )
 
 // Manager manages all agents in the system
 type Manager struct {
-	agents      map[string]Agent
-	kafkaClient *kafka.Client
-	config      config.AgentsConfig
-	mu          sync.RWMutex
+	agents        map[string]Agent
+	kafkaClient   *kafka.Client
+	config        config.AgentsConfig
+	states        *StateStore // Nil unless agent state is persisted
+	stateInterval time.Duration
+	factory       *Factory                      // Creates agents at runtime; nil until UseFactory is called
+	created       map[string]config.AgentConfig // Configuration of the agents created at runtime
+	ctx           context.Context               // Lifetime of the agents, set by Start
+	supervisor    *supervisor                   // Nil unless agents are restarted when in trouble
+	mu            sync.RWMutex
 }
 
+// ErrAgentExists is returned when creating an agent with an ID already in use
+var ErrAgentExists = errors.New("agent already exists")
+
+// ErrAgentNotFound is returned when removing an agent that isn't registered
+var ErrAgentNotFound = errors.New("agent not found")
+
 // NewManager creates a new agent manager
-func NewManager(kafkaClient *kafka.Client, config config.AgentsConfig) *Manager {
+func NewManager(kafkaClient *kafka.Client, agentsConfig config.AgentsConfig) *Manager {
 	return &Manager{
 		agents:      make(map[string]Agent),
+		created:     make(map[string]config.AgentConfig),
 		kafkaClient: kafkaClient,
-		config:      config,
+		config:      agentsConfig,
 	}
 }
 
@@ -33,7 +52,7 @@ func (m *Manager) RegisterAgent(agent Agent) error {
 	defer m.mu.Unlock()
 
 	if _, exists := m.agents[agent.ID()]; exists {
-		return fmt.Errorf("agent with ID %s already registered", agent.ID())
+		return fmt.Errorf("agent with ID %s already registered: %w", agent.ID(), ErrAgentExists)
 	}
 
 	m.agents[agent.ID()] = agent
@@ -43,33 +62,32 @@ func (m *Manager) RegisterAgent(agent Agent) error {
 
 // Start starts all registered agents
 func (m *Manager) Start(ctx context.Context) error {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.ctx = ctx
 
-	var wg sync.WaitGroup
-	errors := make(chan error, len(m.agents))
+	// Continue where the agents left off before the restart
+	if m.states != nil {
+		m.restoreStates()
+		if m.stateInterval > 0 {
+			go m.saveStatesEvery(ctx, m.stateInterval)
+		}
+	}
 
+	var group errgroup.Group
 	for _, agent := range m.agents {
-		wg.Add(1)
-		go func(a Agent) {
-			defer wg.Done()
-			if err := a.Start(ctx); err != nil {
-				errors <- fmt.Errorf("failed to start agent %s: %w", a.ID(), err)
+		group.Go(func() error {
+			if err := agent.Start(ctx); err != nil {
+				return fmt.Errorf("failed to start agent %s: %w", agent.ID(), err)
 			}
-		}(agent)
+			return nil
+		})
 	}
-
-	// Wait for all agents to start
-	go func() {
-		wg.Wait()
-		close(errors)
-	}()
-
-	// Check for errors
-	for err := range errors {
-		if err != nil {
-			return err
-		}
+	if err := group.Wait(); err != nil {
+		return err
+	}
+	if m.supervisor != nil {
+		go m.supervisor.run(ctx)
 	}
 
 	log.Printf("All %d agents started successfully", len(m.agents))
@@ -81,33 +99,181 @@ func (m *Manager) Stop() error {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
-	var wg sync.WaitGroup
-	errors := make(chan error, len(m.agents))
-
+	// Each agent returns once its subscriptions have ended
+	var group errgroup.Group
 	for _, agent := range m.agents {
-		wg.Add(1)
-		go func(a Agent) {
-			defer wg.Done()
-			if err := a.Stop(); err != nil {
-				errors <- fmt.Errorf("failed to stop agent %s: %w", a.ID(), err)
+		group.Go(func() error {
+			if err := agent.Stop(); err != nil {
+				log.Printf("Error stopping agent %s: %v", agent.ID(), err)
 			}
-		}(agent)
+			return nil
+		})
+	}
+	group.Wait()
+
+	if m.states != nil {
+		m.saveStates()
+	}
+
+	log.Printf("All agents stopped")
+	return nil
+}
+
+// UseFactory lets CreateAgent create agents at runtime
+func (m *Manager) UseFactory(factory *Factory) {
+	m.factory = factory
+}
+
+// CreateAgent creates, registers and starts an agent while the system runs
+func (m *Manager) CreateAgent(agentConfig config.AgentConfig) (Agent, error) {
+	if m.factory == nil {
+		return nil, fmt.Errorf("agents cannot be created at runtime")
 	}
 
-	// Wait for all agents to stop
-	go func() {
-		wg.Wait()
-		close(errors)
-	}()
+	m.mu.Lock()
+	defer m.mu.Unlock()
 
-	// Check for errors
-	for err := range errors {
-		if err != nil {
-			log.Printf("Error stopping agent: %v", err)
+	if _, exists := m.agents[agentConfig.ID]; exists {
+		return nil, fmt.Errorf("agent with ID %s already registered: %w", agentConfig.ID, ErrAgentExists)
+	}
+	agent, err := m.factory.CreateAgent(agentConfig, m.config)
+	if err != nil {
+		return nil, err
+	}
+	if m.ctx != nil {
+		if err := agent.Start(m.ctx); err != nil {
+			return nil, fmt.Errorf("failed to start agent %s: %w", agent.ID(), err)
 		}
 	}
 
-	log.Printf("All agents stopped")
+	m.agents[agent.ID()] = agent
+	m.created[agent.ID()] = agentConfig
+	log.Printf("Created agent at runtime: %s (%s)", agent.ID(), agent.Name())
+	return agent, nil
+}
+
+// Clone describes how a cloned agent differs from the original; fields left
+// empty are copied from it
+type Clone struct {
+	ID             string // Generated from the original's when empty
+	Name           string // Marks the original's name as a clone or adversary when empty
+	Model          string
+	Temperature    float64
+	ResponseChance float64
+	Traits         []string // Replace the original's traits
+	Instructions   string   // Added to the original's instructions
+	Oppose         bool     // Makes the clone take the opposite stance, e.g. for debates
+}
+
+// CloneAgent creates an agent like an existing one, with a changed persona
+func (m *Manager) CloneAgent(id string, clone Clone) (Agent, error) {
+	original, err := m.agentConfig(id)
+	if err != nil {
+		return nil, err
+	}
+
+	agentConfig := original
+	agentConfig.ID = clone.ID
+	if agentConfig.ID == "" {
+		agentConfig.ID = fmt.Sprintf("%s-%s", original.ID, uuid.New().String()[:8])
+	}
+	agentConfig.Name = clone.Name
+	if agentConfig.Name == "" && clone.Oppose {
+		agentConfig.Name = original.Name + "'s Adversary"
+	} else if agentConfig.Name == "" {
+		agentConfig.Name = original.Name + " (clone)"
+	}
+	if clone.Model != "" {
+		agentConfig.Model = clone.Model
+	}
+	if clone.Temperature != 0 {
+		agentConfig.Temperature = clone.Temperature
+	}
+	if clone.ResponseChance != 0 {
+		agentConfig.ResponseChance = clone.ResponseChance
+	}
+	if len(clone.Traits) > 0 {
+		agentConfig.Traits = clone.Traits
+	}
+	var instructions []string
+	if original.Instructions != "" {
+		instructions = append(instructions, original.Instructions)
+	}
+	if clone.Oppose {
+		instructions = append(instructions, fmt.Sprintf("You are %s's adversary: take the opposite stance to theirs on every question, argue against their views and challenge their reasoning directly.", original.Name))
+	}
+	if clone.Instructions != "" {
+		instructions = append(instructions, clone.Instructions)
+	}
+	agentConfig.Instructions = strings.Join(instructions, " ")
+	agentConfig.Preset = "" // Already applied to the original
+	agentConfig.IsEnabled = true
+
+	return m.CreateAgent(agentConfig)
+}
+
+// agentConfig returns the configuration a registered agent was created from
+func (m *Manager) agentConfig(id string) (config.AgentConfig, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if _, exists := m.agents[id]; !exists {
+		return config.AgentConfig{}, fmt.Errorf("agent %s: %w", id, ErrAgentNotFound)
+	}
+	if agentConfig, ok := m.created[id]; ok {
+		return agentConfig, nil
+	}
+	for _, agentConfig := range m.config.Agents {
+		if agentConfig.ID == id {
+			return agentConfig, nil
+		}
+	}
+	return config.AgentConfig{}, fmt.Errorf("agent %s has no configuration to clone", id)
+}
+
+// RemoveAgent stops an agent and unregisters it
+func (m *Manager) RemoveAgent(id string) error {
+	m.mu.Lock()
+	agent, exists := m.agents[id]
+	if !exists {
+		m.mu.Unlock()
+		return fmt.Errorf("agent %s: %w", id, ErrAgentNotFound)
+	}
+	delete(m.agents, id)
+	delete(m.created, id)
+	m.mu.Unlock()
+
+	// Stopping waits for the message being handled, which may need the manager
+	if err := agent.Stop(); err != nil {
+		log.Printf("Error stopping agent %s: %v", id, err)
+	}
+
+	log.Printf("Removed agent: %s (%s)", id, agent.Name())
+	return nil
+}
+
+// RestartAgent stops an agent and starts it again with fresh subscriptions,
+// e.g. after one of them failed
+func (m *Manager) RestartAgent(id string) error {
+	m.mu.RLock()
+	agent, exists := m.agents[id]
+	ctx := m.ctx
+	m.mu.RUnlock()
+
+	if !exists {
+		return fmt.Errorf("agent %s: %w", id, ErrAgentNotFound)
+	}
+	if ctx == nil {
+		return fmt.Errorf("agents have not been started")
+	}
+	if err := agent.Stop(); err != nil {
+		log.Printf("Error stopping agent %s: %v", id, err)
+	}
+	if err := agent.Start(ctx); err != nil {
+		return fmt.Errorf("failed to restart agent %s: %w", id, err)
+	}
+
+	log.Printf("Restarted agent: %s (%s)", id, agent.Name())
 	return nil
 }
 
@@ -119,6 +285,22 @@ func (m *Manager) GetAgent(id string) (Agent, bool) {
 	return agent, exists
 }
 
+// IsAgentID reports whether an ID belongs to an agent, registered or only configured
+func (m *Manager) IsAgentID(id string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if _, exists := m.agents[id]; exists {
+		return true
+	}
+	for _, agentConfig := range m.config.Agents {
+		if agentConfig.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
 // ListAgents returns a list of all registered agents
 func (m *Manager) ListAgents() []Agent {
 	m.mu.RLock()
@@ -131,8 +313,19 @@ func (m *Manager) ListAgents() []Agent {
 	return agents
 }
 
+// ForgetUser drops the LLM calls the agents keep for debugging whose prompts
+// may hold a user's messages, and returns how many were dropped
+func (m *Manager) ForgetUser(userID string) (int, error) {
+	dropped := 0
+	for _, a := range m.ListAgents() {
+		if recorder, ok := a.(CallRecorder); ok {
+			dropped += recorder.ForgetUser(userID)
+		}
+	}
+	return dropped, nil
+}
+
 // GetConfig returns the agent configuration
 func (m *Manager) GetConfig() config.AgentsConfig {
 	return m.config
 }
-
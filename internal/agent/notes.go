@@ -0,0 +1,114 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"philoking/internal/types"
+)
+
+// maxNotes is how many unread notes an agent keeps per conversation
+const maxNotes = 5
+
+// notePayload is the payload of a note between agents
+type notePayload struct {
+	Text string `json:"text"`
+}
+
+// agentNotes keeps the private notes other agents sent, until the next reply uses them
+type agentNotes struct {
+	mu             sync.Mutex
+	byConversation map[string][]string
+}
+
+// add keeps a note, dropping the oldest beyond maxNotes
+func (n *agentNotes) add(conversationID, from, text string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.byConversation == nil {
+		n.byConversation = make(map[string][]string)
+	}
+	notes := append(n.byConversation[conversationID], fmt.Sprintf("%s: %s", from, text))
+	if len(notes) > maxNotes {
+		notes = notes[len(notes)-maxNotes:]
+	}
+	n.byConversation[conversationID] = notes
+}
+
+// take returns and forgets the notes of a conversation
+func (n *agentNotes) take(conversationID string) []string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	notes := n.byConversation[conversationID]
+	delete(n.byConversation, conversationID)
+	return notes
+}
+
+// enableDirectMessages lets the agent send notes and hand off tasks through
+// tools, take the notes it receives into account and take on handed off tasks
+func (l *LLMAgent) enableDirectMessages() {
+	l.directMessages = true
+	l.OnAgentMessage(types.AgentMessageNote, func(ctx context.Context, message *types.AgentMessage) error {
+		var note notePayload
+		if err := message.DecodePayload(&note); err != nil || strings.TrimSpace(note.Text) == "" {
+			return nil // Nothing to take into account
+		}
+		l.notes.add(message.ConversationID, message.FromAgent, note.Text)
+		return nil
+	})
+	l.enableHandoffs()
+}
+
+// notesPrompt tells the agent about the notes it received in a conversation
+func (l *LLMAgent) notesPrompt(conversationID string) string {
+	notes := l.notes.take(conversationID)
+	if len(notes) == 0 {
+		return ""
+	}
+	return " Other agents sent you these private notes, which nobody else can see: " + strings.Join(notes, " | ")
+}
+
+// directMessageTools returns the tools agents send each other notes and tasks with
+func (l *LLMAgent) directMessageTools(conversationID string) []Tool {
+	if !l.directMessages {
+		return nil
+	}
+	return []Tool{
+		&messageAgentTool{agent: l.BaseAgent, conversationID: conversationID},
+		&handOffTool{agent: l.BaseAgent, conversationID: conversationID},
+	}
+}
+
+// messageAgentTool sends another agent a private note
+type messageAgentTool struct {
+	agent          *BaseAgent
+	conversationID string
+}
+
+func (t *messageAgentTool) Name() string {
+	return "message_agent"
+}
+
+func (t *messageAgentTool) Description() string {
+	return "sends another agent a private note that the conversation doesn't see, e.g. to coordinate who answers what; " +
+		"the input is the agent's id followed by the note, e.g. \"rational-agent leave the ethics question to me\""
+}
+
+func (t *messageAgentTool) Call(ctx context.Context, input string) (string, error) {
+	to, text, _ := strings.Cut(strings.TrimSpace(input), " ")
+	text = strings.TrimSpace(text)
+	if to == "" || text == "" {
+		return "", fmt.Errorf("input must be an agent id followed by the note")
+	}
+	if to == t.agent.id {
+		return "", fmt.Errorf("you can't send a note to yourself")
+	}
+	if err := t.agent.SendAgentMessage(ctx, to, types.AgentMessageNote, t.conversationID, notePayload{Text: text}); err != nil {
+		return "", err
+	}
+	return "the note was sent to " + to, nil
+}
@@ -0,0 +1,183 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"philoking/internal/config"
+	"philoking/internal/conversation"
+	"philoking/internal/kafka"
+	"philoking/internal/types"
+	"philoking/pkg/agentplugin"
+)
+
+const (
+	// pluginTimeout bounds a plugin's handling of a single message
+	pluginTimeout = 30 * time.Second
+	// pluginHistory is how many recent messages a plugin gets with each message
+	pluginHistory = 50
+)
+
+// PluginAgent is an agent whose behaviour is implemented by a plugin program;
+// see pkg/agentplugin. A plugin that crashes is started again on the next message.
+type PluginAgent struct {
+	*BaseAgent
+	description string
+	plugin      config.PluginConfig
+	settings    map[string]string
+	client      *agentplugin.Client // Nil until started, or after a crash
+	clientMu    sync.Mutex
+}
+
+// NewPluginAgent creates an agent served by the given plugin
+func NewPluginAgent(id, name, description string, kafkaClient *kafka.Client, plugin config.PluginConfig, settings map[string]string, responseChance float64, convManager *conversation.Manager) *PluginAgent {
+	base := NewBaseAgent(id, name, kafkaClient, responseChance, convManager)
+	agent := &PluginAgent{
+		BaseAgent:   base,
+		description: description,
+		plugin:      plugin,
+		settings:    settings,
+	}
+
+	// Set the message handler
+	agent.SetHandler(agent)
+
+	return agent
+}
+
+// Start starts the plugin program and then the agent
+func (p *PluginAgent) Start(ctx context.Context) error {
+	if _, err := p.connection(ctx); err != nil {
+		return err
+	}
+	return p.BaseAgent.Start(ctx)
+}
+
+// Stop stops the agent and shuts the plugin program down
+func (p *PluginAgent) Stop() error {
+	err := p.BaseAgent.Stop()
+
+	p.clientMu.Lock()
+	defer p.clientMu.Unlock()
+	if p.client != nil {
+		if closeErr := p.client.Close(); closeErr != nil {
+			log.Printf("Plugin of agent %s did not shut down cleanly: %v", p.id, closeErr)
+		}
+		p.client = nil
+	}
+	return err
+}
+
+// HandleMessage passes the message to the plugin and sends its replies
+func (p *PluginAgent) HandleMessage(ctx context.Context, message *types.ChatMessage) error {
+	client, err := p.connection(ctx)
+	if err != nil {
+		return err
+	}
+
+	history := p.getConversationHistory(message.Metadata.ConversationID)
+	if len(history) > pluginHistory {
+		history = history[len(history)-pluginHistory:]
+	}
+	pluginHistory := make([]agentplugin.Message, 0, len(history))
+	for _, msg := range history {
+		pluginHistory = append(pluginHistory, toPluginMessage(msg))
+	}
+
+	callCtx, cancel := context.WithTimeout(ctx, pluginTimeout)
+	defer cancel()
+	replies, err := client.HandleMessage(callCtx, toPluginMessage(message), pluginHistory)
+	if err != nil {
+		if client.Exited() {
+			p.dropClient(client)
+		}
+		return fmt.Errorf("plugin %s failed to handle message %s: %w", p.plugin.Type, message.ID, err)
+	}
+
+	for _, reply := range replies {
+		if reply.Content == "" {
+			continue
+		}
+		if p.responses.isDuplicate(reply.Content) {
+			log.Printf("Agent %s suppressed duplicate response: %s", p.id, reply.Content)
+			continue
+		}
+		response := p.newMessage(reply.Content, message.Metadata.ConversationID)
+		response.Metadata.Tags = append(response.Metadata.Tags, reply.Tags...)
+		if err := p.publish(ctx, response); err != nil {
+			return err
+		}
+		p.responses.add(reply.Content)
+	}
+	return nil
+}
+
+// connection returns the running plugin, starting it when needed
+func (p *PluginAgent) connection(ctx context.Context) (*agentplugin.Client, error) {
+	p.clientMu.Lock()
+	defer p.clientMu.Unlock()
+
+	if p.client != nil && !p.client.Exited() {
+		return p.client, nil
+	}
+
+	client, err := agentplugin.Launch(p.plugin.Path, p.plugin.Args...)
+	if err != nil {
+		return nil, err
+	}
+	initCtx, cancel := context.WithTimeout(ctx, pluginTimeout)
+	defer cancel()
+	if err := client.Init(initCtx, agentplugin.Config{
+		ID:          p.id,
+		Name:        p.name,
+		Description: p.description,
+		Settings:    p.settings,
+	}); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("plugin %s failed to initialize: %w", p.plugin.Type, err)
+	}
+
+	log.Printf("Started plugin %s (%s) for agent %s", p.plugin.Type, p.plugin.Path, p.id)
+	p.client = client
+	return client, nil
+}
+
+// dropClient forgets a plugin process that exited, so the next message starts a new one
+func (p *PluginAgent) dropClient(client *agentplugin.Client) {
+	p.clientMu.Lock()
+	defer p.clientMu.Unlock()
+
+	if p.client == client {
+		log.Printf("Plugin of agent %s exited; it is restarted on the next message", p.id)
+		client.Close()
+		p.client = nil
+	}
+}
+
+// getConversationHistory returns the conversation the agent has seen
+func (p *PluginAgent) getConversationHistory(conversationID string) []*types.ChatMessage {
+	if p.convManager == nil {
+		return nil
+	}
+	return p.convManager.GetRecentMessages(conversationID, pluginHistory)
+}
+
+// toPluginMessage converts a chat message to the plugin protocol
+func toPluginMessage(message *types.ChatMessage) agentplugin.Message {
+	return agentplugin.Message{
+		ID:             message.ID,
+		Type:           string(message.Type),
+		Content:        message.Content,
+		AgentID:        message.AgentID,
+		UserID:         message.UserID,
+		FromName:       senderName(message),
+		ConversationID: message.Metadata.ConversationID,
+		ReplyTo:        message.Metadata.ReplyTo,
+		Tags:           message.Metadata.Tags,
+		Custom:         message.Metadata.Custom,
+		Timestamp:      message.Timestamp,
+	}
+}
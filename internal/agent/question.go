@@ -0,0 +1,34 @@
+package agent
+
+import (
+	"context"
+	"log"
+	"strings"
+
+	"philoking/internal/types"
+)
+
+// askHumanPrefix marks an LLM reply that is a required question for the human
+const askHumanPrefix = "ASK HUMAN:"
+
+// AskHuman puts a required question to the human; the conversation flow
+// pauses the other agents until it is answered or times out
+func (a *BaseAgent) AskHuman(ctx context.Context, question string, conversationID string) error {
+	message := a.newMessage(question, conversationID)
+	message.Type = types.MessageTypeQuestion
+	message.Metadata.Required = true
+
+	log.Printf("Agent %s asking the human: %s", a.id, question)
+	return a.publish(ctx, message)
+}
+
+// parseHumanQuestion extracts the question from a reply that starts with askHumanPrefix
+func parseHumanQuestion(response string) (string, bool) {
+	trimmed := strings.TrimSpace(response)
+	if len(trimmed) < len(askHumanPrefix) || !strings.EqualFold(trimmed[:len(askHumanPrefix)], askHumanPrefix) {
+		return "", false
+	}
+
+	question := strings.TrimSpace(trimmed[len(askHumanPrefix):])
+	return question, question != ""
+}
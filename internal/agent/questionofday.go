@@ -0,0 +1,166 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+
+	"philoking/internal/config"
+	"philoking/internal/conversation"
+	"philoking/internal/kafka"
+	"philoking/internal/types"
+)
+
+// QuestionOfTheDayTag marks the question that opens a scheduled conversation
+const QuestionOfTheDayTag = "question_of_the_day"
+
+const (
+	// maxPastQuestions bounds the questions remembered to avoid repeats
+	maxPastQuestions = 365
+	// pastQuestionsInPrompt is how many of the latest questions the model is shown
+	pastQuestionsInPrompt = 30
+	// questionAttempts is how often a question that repeats an earlier one is asked for again
+	questionAttempts = 3
+)
+
+// QuestionOfTheDayAgent opens every scheduled conversation with a question on
+// its theme, one it hasn't asked before
+type QuestionOfTheDayAgent struct {
+	*LLMAgent
+	theme   string // Empty uses the conversation's topic
+	past    []string
+	pastMu  sync.Mutex
+	askedIn map[string]bool // Conversations opened since the start; guarded by pastMu
+}
+
+// NewQuestionOfTheDayAgent creates a new question-of-the-day agent
+func NewQuestionOfTheDayAgent(id, name, description string, kafkaClient *kafka.Client, config config.AgentsConfig, theme string, convManager *conversation.Manager) *QuestionOfTheDayAgent {
+	// The agent sees every message; only welcomes of scheduled conversations make it speak
+	llm := NewLLMAgent(id, name, description, kafkaClient, config, 1.0, convManager)
+	agent := &QuestionOfTheDayAgent{
+		LLMAgent: llm,
+		theme:    theme,
+		askedIn:  make(map[string]bool),
+	}
+
+	// Set the message handler
+	agent.SetHandler(agent)
+
+	return agent
+}
+
+// HandleMessage asks the opening question when a scheduled conversation is welcomed
+func (q *QuestionOfTheDayAgent) HandleMessage(ctx context.Context, message *types.ChatMessage) error {
+	if message.Type != types.MessageTypeSystem || !hasTag(message, conversation.ScheduledTag) {
+		return nil
+	}
+	conversationID := message.Metadata.ConversationID
+
+	q.pastMu.Lock()
+	asked := q.askedIn[conversationID]
+	q.askedIn[conversationID] = true
+	q.pastMu.Unlock()
+	if asked {
+		return nil
+	}
+
+	question, err := q.WriteQuestion(ctx, conversationID)
+	if err != nil {
+		return fmt.Errorf("failed to write the question of the day: %w", err)
+	}
+
+	reply := q.newMessage(question, conversationID)
+	reply.Metadata.ReplyTo = message.ID
+	reply.Metadata.Tags = append(reply.Metadata.Tags, QuestionOfTheDayTag)
+	log.Printf("QuestionOfTheDayAgent opening %s with: %s", conversationID, question)
+	if err := q.publish(ctx, reply); err != nil {
+		return fmt.Errorf("failed to publish the question of the day: %w", err)
+	}
+
+	q.remember(question)
+	return nil
+}
+
+// WriteQuestion generates a question on the theme that doesn't repeat the
+// earlier ones, without posting it
+func (q *QuestionOfTheDayAgent) WriteQuestion(ctx context.Context, conversationID string) (string, error) {
+	theme := q.theme
+	if theme == "" && q.convManager != nil {
+		if info, ok := q.convManager.GetInfo(conversationID); ok {
+			theme = info.Topic
+		}
+	}
+	if theme == "" {
+		theme = "anything worth discussing"
+	}
+
+	systemPrompt := fmt.Sprintf("You open the day's conversation of a discussion group with a single thought-provoking question. The theme: %s. Ask an open question that invites disagreement, in one or two sentences. Reply with the question only.", theme)
+	systemPrompt += q.personality()
+
+	avoid := q.recentQuestions()
+	var question string
+	for attempt := 0; attempt < questionAttempts; attempt++ {
+		prompt := "Write today's question."
+		if len(avoid) > 0 {
+			prompt = "These questions were asked before; don't repeat or rephrase any of them:\n- " + strings.Join(avoid, "\n- ") + "\n\n" + prompt
+		}
+
+		response, err := q.complete(ctx, conversationID, []Message{
+			{Role: "system", Content: systemPrompt},
+			{Role: "user", Content: prompt},
+		})
+		if err != nil {
+			return "", err
+		}
+		question = strings.TrimSpace(q.cleanResponse(response))
+		if question == "" {
+			continue
+		}
+		if !q.isRepeat(question) {
+			return question, nil
+		}
+		log.Printf("QuestionOfTheDayAgent %s rejected a repeated question: %s", q.ID(), question)
+		avoid = append(avoid, question)
+	}
+	if question == "" {
+		return "", fmt.Errorf("the model returned no question")
+	}
+	return question, nil // A repeat beats no opening at all
+}
+
+// recentQuestions returns the latest questions shown to the model
+func (q *QuestionOfTheDayAgent) recentQuestions() []string {
+	q.pastMu.Lock()
+	defer q.pastMu.Unlock()
+
+	start := max(0, len(q.past)-pastQuestionsInPrompt)
+	return append([]string(nil), q.past[start:]...)
+}
+
+// isRepeat reports whether a question matches any earlier one exactly or fuzzily
+func (q *QuestionOfTheDayAgent) isRepeat(question string) bool {
+	words := normalizeWords(question)
+
+	q.pastMu.Lock()
+	defer q.pastMu.Unlock()
+
+	for _, previous := range q.past {
+		if similarity(words, normalizeWords(previous)) >= similarityThreshold {
+			return true
+		}
+	}
+	return false
+}
+
+// remember adds an asked question to the past ones
+func (q *QuestionOfTheDayAgent) remember(question string) {
+	q.pastMu.Lock()
+	defer q.pastMu.Unlock()
+
+	q.past = append(q.past, question)
+	if len(q.past) > maxPastQuestions {
+		q.past = q.past[len(q.past)-maxPastQuestions:]
+	}
+}
@@ -0,0 +1,55 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"philoking/internal/conversation"
+	"philoking/internal/types"
+)
+
+// reminderTools returns the tool agents schedule reminders in one conversation with
+func (l *LLMAgent) reminderTools(conversationID string) []Tool {
+	if !l.reminderAccess {
+		return nil
+	}
+	return []Tool{&reminderTool{agent: l.BaseAgent, conversationID: conversationID}}
+}
+
+// reminderTool sets a reminder by posting a /remind command, which the conversation flow schedules
+type reminderTool struct {
+	agent          *BaseAgent
+	conversationID string
+}
+
+func (t *reminderTool) Name() string {
+	return "set_reminder"
+}
+
+func (t *reminderTool) Description() string {
+	return "posts a reminder to the conversation later, e.g. when asked to \"remind us in 2 hours\"; " +
+		"the input is when, as a delay like 30m, 2h or 1d, a clock time like 17:30 or an RFC 3339 time, " +
+		"followed by what to remind about, e.g. \"2h check whether the dough has risen\""
+}
+
+func (t *reminderTool) Call(ctx context.Context, input string) (string, error) {
+	when, text, _ := strings.Cut(strings.TrimSpace(input), " ")
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return "", fmt.Errorf("input must be when followed by what to remind about")
+	}
+	dueAt, err := conversation.ParseReminderTime(when, time.Now())
+	if err != nil {
+		return "", err
+	}
+
+	content := fmt.Sprintf("/remind %s %s", when, text)
+	command := t.agent.newMessage(content, t.conversationID)
+	command.Metadata.Command = types.ParseCommand(content)
+	if err := t.agent.publish(ctx, command); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("the reminder will be posted at %s", dueAt.Format(time.RFC1123)), nil
+}
@@ -0,0 +1,100 @@
+package agent
+
+import (
+	"context"
+	"log"
+	"strings"
+
+	"philoking/internal/types"
+)
+
+// maxTemperature caps the temperature a regenerated response is asked for
+const maxTemperature = 2.0
+
+// temperatureKey carries a temperature that overrides the agent's for a single response
+type temperatureKey struct{}
+
+// withTemperature asks for the responses generated with ctx at the given temperature
+func withTemperature(ctx context.Context, temperature float64) context.Context {
+	return context.WithValue(ctx, temperatureKey{}, temperature)
+}
+
+// temperature returns the temperature to generate at: the agent's own unless ctx overrides it
+func (l *LLMAgent) temperature(ctx context.Context) float64 {
+	if temperature, ok := ctx.Value(temperatureKey{}).(float64); ok {
+		return temperature
+	}
+	return l.config.Temperature
+}
+
+// avoidParroting checks a response against the conversation's recent
+// messages. A response restating them is regenerated once at a higher
+// temperature, or dropped; false means the agent skips its turn.
+func (l *LLMAgent) avoidParroting(ctx context.Context, response, prompt string, message *types.ChatMessage, history []*types.ChatMessage) (string, bool) {
+	check := l.config.Repetition
+	if !check.Enabled {
+		return response, true
+	}
+	recent := history
+	if len(recent) > check.Window {
+		recent = recent[len(recent)-check.Window:]
+	}
+
+	overlap := parrotOverlap(response, recent, check.NGram)
+	if overlap <= check.MaxOverlap {
+		return response, true
+	}
+	if check.Action != "regenerate" {
+		log.Printf("Agent %s skipped its turn: the response restates %.0f%% of the recent messages", l.id, overlap*100)
+		l.stats.skipped()
+		return "", false
+	}
+
+	temperature := min(l.temperature(ctx)+check.TemperatureStep, maxTemperature)
+	log.Printf("Agent %s regenerates at temperature %.2f: the response restates %.0f%% of the recent messages", l.id, temperature, overlap*100)
+	retryPrompt := prompt + "\n\n(Don't restate what was already said. Add something new, or stay brief.)"
+	regenerated, err := l.generateResponse(withTemperature(ctx, temperature), retryPrompt, message.Metadata.ConversationID, history)
+	if err != nil {
+		log.Printf("Error regenerating LLM response: %v", err)
+		return "", false
+	}
+	regenerated = limitWords(l.cleanReply(regenerated, message.Metadata.ConversationID), debateWordLimit(message))
+	if overlap := parrotOverlap(regenerated, recent, check.NGram); overlap > check.MaxOverlap {
+		log.Printf("Agent %s skipped its turn: the regenerated response restates %.0f%% of the recent messages", l.id, overlap*100)
+		l.stats.skipped()
+		return "", false
+	}
+	return regenerated, true
+}
+
+// parrotOverlap returns the share of the response's n-word sequences that
+// also occur in the given messages; responses shorter than n words score 0
+func parrotOverlap(response string, messages []*types.ChatMessage, n int) float64 {
+	own := ngrams(normalizeWords(response), n)
+	if len(own) == 0 {
+		return 0
+	}
+
+	said := make(map[string]bool)
+	for _, message := range messages {
+		for gram := range ngrams(normalizeWords(message.Content), n) {
+			said[gram] = true
+		}
+	}
+	repeated := 0
+	for gram := range own {
+		if said[gram] {
+			repeated++
+		}
+	}
+	return float64(repeated) / float64(len(own))
+}
+
+// ngrams returns the distinct sequences of n consecutive words
+func ngrams(words []string, n int) map[string]bool {
+	grams := make(map[string]bool)
+	for i := 0; i+n <= len(words); i++ {
+		grams[strings.Join(words[i:i+n], " ")] = true
+	}
+	return grams
+}
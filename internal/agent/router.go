@@ -0,0 +1,161 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/rand"
+	"strings"
+
+	"philoking/internal/config"
+	"philoking/internal/conversation"
+	"philoking/internal/jsonschema"
+	"philoking/internal/kafka"
+	"philoking/internal/types"
+)
+
+// RoutedTag marks a router's message handing a user's question to a specialist
+const RoutedTag = "routed"
+
+// RoutedMessageKey is the metadata key holding the ID of the routed user message
+const RoutedMessageKey = "routed_message_id"
+
+// specialist is an agent a router can hand questions to
+type specialist struct {
+	ID          string
+	Name        string
+	Description string
+}
+
+// RouterAgent reads every user message, picks the specialist best suited to
+// answer it and addresses them; the other agents sit that turn out
+type RouterAgent struct {
+	*LLMAgent
+	only        []string // Specialist IDs from the configuration; empty allows all
+	specialists []specialist
+}
+
+// NewRouterAgent creates a new router agent; specialists limits the agents it
+// routes to, or allows every discussing agent when empty
+func NewRouterAgent(id, name, description string, kafkaClient *kafka.Client, config config.AgentsConfig, specialists []string, convManager *conversation.Manager) *RouterAgent {
+	// The router sees every user message; it never joins the discussion itself
+	llm := NewLLMAgent(id, name, description, kafkaClient, config, 1.0, convManager)
+	agent := &RouterAgent{
+		LLMAgent: llm,
+		only:     specialists,
+	}
+
+	// Set the message handler
+	agent.SetHandler(agent)
+
+	return agent
+}
+
+// useSpecialists sets the agents the router chooses from
+func (r *RouterAgent) useSpecialists(candidates []config.AgentConfig) {
+	r.specialists = nil
+	for _, candidate := range candidates {
+		if candidate.ID == r.id || (len(r.only) > 0 && !contains(r.only, candidate.ID)) {
+			continue
+		}
+		r.specialists = append(r.specialists, specialist{ID: candidate.ID, Name: candidate.Name, Description: candidate.Description})
+	}
+}
+
+// HandleMessage routes user messages to a specialist
+func (r *RouterAgent) HandleMessage(ctx context.Context, message *types.ChatMessage) error {
+	if message.Type != types.MessageTypeUser || message.IsCommand() || len(r.specialists) == 0 {
+		return nil
+	}
+
+	chosen, reason, err := r.route(ctx, message)
+	if err != nil {
+		// Somebody should still answer the user
+		log.Printf("Error routing message %s, picking a specialist at random: %v", message.ID, err)
+		chosen, reason = r.specialists[rand.Intn(len(r.specialists))], ""
+	}
+
+	content := fmt.Sprintf("🧭 %s, this one is for you.", chosen.Name)
+	if reason != "" {
+		content = fmt.Sprintf("🧭 %s, this one is for you: %s", chosen.Name, reason)
+	}
+
+	routing := r.newMessage(content, message.Metadata.ConversationID)
+	routing.Type = types.MessageTypeSystem
+	routing.Metadata.ReplyTo = chosen.ID
+	routing.Metadata.Tags = []string{RoutedTag}
+	routing.Metadata.Custom = map[string]string{RoutedMessageKey: message.ID}
+	return r.publish(ctx, routing)
+}
+
+// route asks the LLM which specialist fits the message best
+func (r *RouterAgent) route(ctx context.Context, message *types.ChatMessage) (specialist, string, error) {
+	var roster strings.Builder
+	ids := make([]string, 0, len(r.specialists))
+	for _, s := range r.specialists {
+		fmt.Fprintf(&roster, "- %s (%s): %s\n", s.ID, s.Name, s.Description)
+		ids = append(ids, s.ID)
+	}
+
+	systemPrompt := "You route questions in a group discussion to the participant best suited to answer them. " +
+		"Pick exactly one of these participants and give a short reason, addressed to them:\n" + roster.String()
+	messages := []Message{
+		{Role: "system", Content: systemPrompt},
+		{Role: "user", Content: fmt.Sprintf("%s: %s", senderName(message), message.Content)},
+	}
+
+	response, err := r.completeStructured(ctx, message.Metadata.ConversationID, messages, routeSchema(ids))
+	if err != nil {
+		return specialist{}, "", err
+	}
+
+	var decision struct {
+		Agent  string `json:"agent"`
+		Reason string `json:"reason"`
+	}
+	if err := json.Unmarshal([]byte(response), &decision); err != nil {
+		return specialist{}, "", fmt.Errorf("invalid routing decision: %w", err)
+	}
+	for _, s := range r.specialists {
+		if s.ID == decision.Agent {
+			return s, strings.TrimSpace(decision.Reason), nil
+		}
+	}
+	return specialist{}, "", fmt.Errorf("unknown specialist %q", decision.Agent)
+}
+
+// routeSchema describes the router's answer: one of the specialists and a reason
+func routeSchema(ids []string) *jsonschema.Schema {
+	data, _ := json.Marshal(map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"agent":  map[string]interface{}{"type": "string", "enum": ids},
+			"reason": map[string]interface{}{"type": "string"},
+		},
+		"required": []string{"agent", "reason"},
+	})
+	return jsonschema.MustParse(string(data))
+}
+
+// routedQuestion returns the user message a routing message refers to, or the
+// routing message itself when the question is not in the history
+func routedQuestion(routing *types.ChatMessage, history []*types.ChatMessage) string {
+	id := routing.Metadata.Custom[RoutedMessageKey]
+	for i := len(history) - 1; i >= 0; i-- {
+		if history[i].ID == id {
+			return history[i].Content
+		}
+	}
+	return routing.Content
+}
+
+// contains reports whether a list holds a value
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
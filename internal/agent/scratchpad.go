@@ -0,0 +1,41 @@
+package agent
+
+import (
+	"strings"
+)
+
+// scratchpadPrompt asks the model to reason in a scratchpad only admins can read
+const scratchpadPrompt = " Before you answer, think it through inside <think></think> tags. Nobody in the conversation sees what you write there; only what follows the tags is posted."
+
+// splitThinking separates the <think> blocks thinking models put before their
+// answer from the answer itself; an unclosed block runs to the end
+func splitThinking(content string) (answer, reasoning string) {
+	var thoughts []string
+	for {
+		start := strings.Index(content, "<think>")
+		if start < 0 {
+			break
+		}
+		rest := content[start+len("<think>"):]
+		end := strings.Index(rest, "</think>")
+		if end < 0 {
+			thoughts = append(thoughts, strings.TrimSpace(rest))
+			content = content[:start]
+			break
+		}
+		thoughts = append(thoughts, strings.TrimSpace(rest[:end]))
+		content = content[:start] + rest[end+len("</think>"):]
+	}
+	if len(thoughts) == 0 {
+		return content, ""
+	}
+	return strings.TrimSpace(content), strings.Join(thoughts, "\n")
+}
+
+// keepScratchNote stores an agent's reasoning in the conversation's scratchpad
+func (l *LLMAgent) keepScratchNote(conversationID, reasoning string) {
+	if reasoning == "" || conversationID == "" || l.convManager == nil {
+		return
+	}
+	l.convManager.AddScratchNote(conversationID, l.id, reasoning)
+}
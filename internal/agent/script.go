@@ -0,0 +1,220 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"philoking/internal/conversation"
+	"philoking/internal/kafka"
+	"philoking/internal/types"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+const (
+	// scriptTimeout bounds a script's handling of a single message
+	scriptTimeout = 5 * time.Second
+	// scriptHistory is how many recent messages a script gets with each message
+	scriptHistory = 50
+	// scriptHandler is the function a script must define
+	scriptHandler = "handle"
+)
+
+// ScriptAgent is an agent whose behaviour is a Lua script. The script defines
+// handle(message, history) and answers with send(text); globals keep their
+// values between messages. Only the base, table, string and math libraries
+// are available, so scripts cannot touch files or run programs.
+type ScriptAgent struct {
+	*BaseAgent
+	path        string
+	description string
+	state       *lua.LState        // Nil once stopped, until started again
+	stateMu     sync.Mutex         // An LState must not be used concurrently
+	outgoing    []string           // Texts passed to send() while handling a message
+	settings    map[string]string  // Exposed to the script as the settings table
+	current     *types.ChatMessage // Message being handled, for send()
+}
+
+// NewScriptAgent loads a Lua script and runs its top-level code
+func NewScriptAgent(id, name, description, path string, kafkaClient *kafka.Client, settings map[string]string, responseChance float64, convManager *conversation.Manager) (*ScriptAgent, error) {
+	base := NewBaseAgent(id, name, kafkaClient, responseChance, convManager)
+	agent := &ScriptAgent{
+		BaseAgent:   base,
+		path:        path,
+		description: description,
+		settings:    settings,
+	}
+	if err := agent.load(); err != nil {
+		return nil, err
+	}
+
+	// Set the message handler
+	agent.SetHandler(agent)
+
+	return agent, nil
+}
+
+// load creates the interpreter and runs the script's top-level code; the
+// caller holds stateMu unless the agent is being created
+func (s *ScriptAgent) load() error {
+	state := lua.NewState(lua.Options{SkipOpenLibs: true})
+	for _, lib := range []struct {
+		name string
+		open lua.LGFunction
+	}{
+		{lua.BaseLibName, lua.OpenBase},
+		{lua.TabLibName, lua.OpenTable},
+		{lua.StringLibName, lua.OpenString},
+		{lua.MathLibName, lua.OpenMath},
+	} {
+		state.Push(state.NewFunction(lib.open))
+		state.Push(lua.LString(lib.name))
+		state.Call(1, 0)
+	}
+	// The base library can still load other files
+	for _, unsafe := range []string{"dofile", "loadfile", "load", "loadstring", "require"} {
+		state.SetGlobal(unsafe, lua.LNil)
+	}
+
+	state.SetGlobal("send", state.NewFunction(s.luaSend))
+	state.SetGlobal("log", state.NewFunction(s.luaLog))
+	state.SetGlobal("agent", luaTable(state, map[string]string{"id": s.id, "name": s.name, "description": s.description}))
+	state.SetGlobal("settings", luaTable(state, s.settings))
+
+	ctx, cancel := context.WithTimeout(context.Background(), scriptTimeout)
+	defer cancel()
+	state.SetContext(ctx)
+	if err := state.DoFile(s.path); err != nil {
+		state.Close()
+		return fmt.Errorf("failed to load script %s: %w", s.path, err)
+	}
+	if state.GetGlobal(scriptHandler).Type() != lua.LTFunction {
+		state.Close()
+		return fmt.Errorf("script %s does not define %s(message, history)", s.path, scriptHandler)
+	}
+	state.RemoveContext()
+	s.state = state
+	return nil
+}
+
+// Start starts the agent, reloading the script if it was stopped before so
+// that a restart picks up changes to it
+func (s *ScriptAgent) Start(ctx context.Context) error {
+	s.stateMu.Lock()
+	if s.state == nil {
+		if err := s.load(); err != nil {
+			s.stateMu.Unlock()
+			return err
+		}
+	}
+	s.stateMu.Unlock()
+	return s.BaseAgent.Start(ctx)
+}
+
+// HandleMessage runs the script's handler and sends what it passed to send()
+func (s *ScriptAgent) HandleMessage(ctx context.Context, message *types.ChatMessage) error {
+	var history []*types.ChatMessage
+	if s.convManager != nil {
+		history = s.convManager.GetRecentMessages(message.Metadata.ConversationID, scriptHistory)
+	}
+
+	s.stateMu.Lock()
+	if s.state == nil {
+		s.stateMu.Unlock()
+		return nil // Stopped
+	}
+	s.outgoing = nil
+	s.current = message
+
+	callCtx, cancel := context.WithTimeout(ctx, scriptTimeout)
+	s.state.SetContext(callCtx)
+	historyTable := s.state.NewTable()
+	for _, msg := range history {
+		historyTable.Append(luaMessage(s.state, msg))
+	}
+	err := s.state.CallByParam(lua.P{
+		Fn:      s.state.GetGlobal(scriptHandler),
+		NRet:    0,
+		Protect: true,
+	}, luaMessage(s.state, message), historyTable)
+	s.state.RemoveContext()
+	cancel()
+
+	outgoing := s.outgoing
+	s.outgoing, s.current = nil, nil
+	s.stateMu.Unlock()
+
+	if err != nil {
+		return fmt.Errorf("script %s failed on message %s: %w", s.path, message.ID, err)
+	}
+
+	for _, content := range outgoing {
+		if err := s.SendMessage(ctx, content, message.Metadata.ConversationID); err != nil && err != ErrDuplicateResponse {
+			return err
+		}
+	}
+	return nil
+}
+
+// Stop stops the agent and releases the interpreter
+func (s *ScriptAgent) Stop() error {
+	err := s.BaseAgent.Stop()
+
+	s.stateMu.Lock()
+	defer s.stateMu.Unlock()
+	if s.state != nil {
+		s.state.Close()
+		s.state = nil
+	}
+	return err
+}
+
+// luaSend implements send(text): the text is sent to the conversation once the handler returns
+func (s *ScriptAgent) luaSend(state *lua.LState) int {
+	if s.current == nil {
+		state.RaiseError("send() can only be called while handling a message")
+		return 0
+	}
+	s.outgoing = append(s.outgoing, state.CheckString(1))
+	return 0
+}
+
+// luaLog implements log(text)
+func (s *ScriptAgent) luaLog(state *lua.LState) int {
+	log.Printf("Script agent %s: %s", s.id, state.CheckString(1))
+	return 0
+}
+
+// luaMessage converts a chat message to a Lua table
+func luaMessage(state *lua.LState, message *types.ChatMessage) *lua.LTable {
+	table := luaTable(state, map[string]string{
+		"id":              message.ID,
+		"type":            string(message.Type),
+		"content":         message.Content,
+		"agent_id":        message.AgentID,
+		"user_id":         message.UserID,
+		"from":            senderName(message),
+		"conversation_id": message.Metadata.ConversationID,
+		"reply_to":        message.Metadata.ReplyTo,
+	})
+	table.RawSetString("is_command", lua.LBool(message.IsCommand()))
+
+	tags := state.NewTable()
+	for _, tag := range message.Metadata.Tags {
+		tags.Append(lua.LString(tag))
+	}
+	table.RawSetString("tags", tags)
+	return table
+}
+
+// luaTable converts a string map to a Lua table
+func luaTable(state *lua.LState, values map[string]string) *lua.LTable {
+	table := state.NewTable()
+	for key, value := range values {
+		table.RawSetString(key, lua.LString(value))
+	}
+	return table
+}
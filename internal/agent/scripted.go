@@ -0,0 +1,115 @@
+package agent
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"sync"
+	"text/template"
+
+	"philoking/internal/config"
+)
+
+// ScriptedProvider answers from responses written in the configuration
+// instead of calling a model, for demos and tests
+const ScriptedProvider = "scripted"
+
+// scriptedRule is a compiled scripted response rule
+type scriptedRule struct {
+	match     *regexp.Regexp // Nil matches every prompt
+	responses []*template.Template
+	next      int
+}
+
+// scriptedData is what response templates can refer to
+type scriptedData struct {
+	Agent   string   // The agent's display name
+	AgentID string   // The agent's ID
+	Message string   // The message being answered
+	Turn    int      // How often this rule has answered, this time included
+	Groups  []string // Submatches of the rule's regular expression
+}
+
+// scripted serves an agent's scripted responses; each agent cycles through
+// the responses of a rule on its own
+type scripted struct {
+	mu    sync.Mutex
+	rules []*scriptedRule
+}
+
+// newScripted compiles the scripted responses; invalid rules are reported by
+// config validation and skipped here
+func newScripted(cfg config.ScriptedConfig) *scripted {
+	s := &scripted{}
+	for i, rule := range cfg.Responses {
+		compiled, err := compileScriptedRule(rule)
+		if err != nil {
+			log.Printf("Skipping scripted response %d: %v", i+1, err)
+			continue
+		}
+		s.rules = append(s.rules, compiled)
+	}
+	return s
+}
+
+// compileScriptedRule compiles a rule's regular expression and templates
+func compileScriptedRule(rule config.ScriptedResponse) (*scriptedRule, error) {
+	compiled := &scriptedRule{}
+	if rule.Match != "" {
+		match, err := regexp.Compile(rule.Match)
+		if err != nil {
+			return nil, err
+		}
+		compiled.match = match
+	}
+	if len(rule.Responses) == 0 {
+		return nil, fmt.Errorf("no responses")
+	}
+	for _, response := range rule.Responses {
+		tmpl, err := template.New("response").Parse(response)
+		if err != nil {
+			return nil, err
+		}
+		compiled.responses = append(compiled.responses, tmpl)
+	}
+	return compiled, nil
+}
+
+// respond answers the last message of the prompt with the first matching
+// rule, taking the rule's responses in turn
+func (s *scripted) respond(l *LLMAgent, messages []Message) (*Completion, error) {
+	prompt := ""
+	if len(messages) > 0 {
+		prompt = messages[len(messages)-1].Content
+	}
+
+	s.mu.Lock()
+	var rule *scriptedRule
+	var groups []string
+	for _, r := range s.rules {
+		if r.match == nil {
+			rule = r
+			break
+		}
+		if groups = r.match.FindStringSubmatch(prompt); groups != nil {
+			rule = r
+			break
+		}
+	}
+	if rule == nil {
+		s.mu.Unlock()
+		return nil, fmt.Errorf("no scripted response matches %q", prompt)
+	}
+	tmpl := rule.responses[rule.next%len(rule.responses)]
+	rule.next++
+	turn := rule.next
+	s.mu.Unlock()
+
+	var content strings.Builder
+	data := scriptedData{Agent: l.Name(), AgentID: l.id, Message: prompt, Turn: turn, Groups: groups}
+	if err := tmpl.Execute(&content, data); err != nil {
+		return nil, fmt.Errorf("failed to render scripted response: %w", err)
+	}
+	return &Completion{Content: content.String()}, nil
+}
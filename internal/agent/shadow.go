@@ -0,0 +1,167 @@
+package agent
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"philoking/internal/config"
+	"philoking/internal/types"
+)
+
+// maxShadowComparisons bounds the comparisons an agent with a shadow keeps
+const maxShadowComparisons = 100
+
+// ShadowComparison puts an agent's reply to a message next to its shadow's
+type ShadowComparison struct {
+	MessageID      string    `json:"message_id"`
+	ConversationID string    `json:"conversation_id"`
+	Message        string    `json:"message"` // What both answered
+	Live           string    `json:"live,omitempty"`
+	Shadow         string    `json:"shadow,omitempty"`
+	ShadowError    string    `json:"shadow_error,omitempty"`
+	At             time.Time `json:"at"`
+}
+
+// ShadowEvaluator is implemented by agents that can run a shadow variant
+type ShadowEvaluator interface {
+	// HasShadow reports whether the agent runs a shadow variant
+	HasShadow() bool
+	// ShadowComparisons returns up to n of the latest comparisons, newest first
+	ShadowComparisons(n int) []ShadowComparison
+}
+
+// shadowLog keeps the latest comparisons of an agent with its shadow
+type shadowLog struct {
+	mu          sync.Mutex
+	comparisons []ShadowComparison // Oldest first
+}
+
+// record fills in the comparison for a message, adding it when it's new
+func (s *shadowLog) record(message *types.ChatMessage, fill func(*ShadowComparison)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := len(s.comparisons) - 1; i >= 0; i-- {
+		if s.comparisons[i].MessageID == message.ID {
+			fill(&s.comparisons[i])
+			return
+		}
+	}
+	comparison := ShadowComparison{
+		MessageID:      message.ID,
+		ConversationID: message.Metadata.ConversationID,
+		Message:        message.Content,
+		At:             time.Now(),
+	}
+	fill(&comparison)
+	s.comparisons = append(s.comparisons, comparison)
+	if len(s.comparisons) > maxShadowComparisons {
+		s.comparisons = append([]ShadowComparison(nil), s.comparisons[len(s.comparisons)-maxShadowComparisons:]...)
+	}
+}
+
+// latest returns up to n comparisons, newest first
+func (s *shadowLog) latest(n int) []ShadowComparison {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if n <= 0 || n > len(s.comparisons) {
+		n = len(s.comparisons)
+	}
+	comparisons := make([]ShadowComparison, 0, n)
+	for i := len(s.comparisons) - 1; i >= len(s.comparisons)-n; i-- {
+		comparisons = append(comparisons, s.comparisons[i])
+	}
+	return comparisons
+}
+
+// HasShadow reports whether the agent runs a shadow variant
+func (a *BaseAgent) HasShadow() bool {
+	return a.shadow != nil
+}
+
+// ShadowComparisons returns up to n of the agent's latest replies next to its shadow's, newest first
+func (a *BaseAgent) ShadowComparisons(n int) []ShadowComparison {
+	return a.shadows.latest(n)
+}
+
+// runShadow has the shadow answer a message the agent handles, keeping what it would have said
+func (a *BaseAgent) runShadow(message *types.ChatMessage) {
+	a.mu.RLock()
+	ctx := a.ctx // Cancelled when the agent stops
+	a.mu.RUnlock()
+	if a.messageTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, a.messageTimeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	err := a.shadow.callHandler(ctx, a.shadow.handler, message)
+
+	var reply string
+	for _, held := range a.shadow.dryRuns.latest(0) {
+		if held.At.Before(start) {
+			break
+		}
+		if held.Message != nil && held.Message.Type == types.MessageTypeAgent {
+			reply = held.Message.Content
+			break
+		}
+	}
+	if reply == "" && err == nil {
+		return
+	}
+	if err != nil {
+		log.Printf("Agent %s: shadow could not answer message %s: %v", a.id, message.ID, err)
+	}
+	a.shadows.record(message, func(comparison *ShadowComparison) {
+		comparison.Shadow = reply
+		if err != nil {
+			comparison.ShadowError = err.Error()
+		}
+	})
+}
+
+// recordLive adds the agent's own reply to a message to its comparison with the shadow
+func (a *BaseAgent) recordLive(message *types.ChatMessage) {
+	a.mu.RLock()
+	reply := a.lastPublished
+	a.mu.RUnlock()
+	if reply == nil || reply.Type != types.MessageTypeAgent {
+		return
+	}
+	a.shadows.record(message, func(comparison *ShadowComparison) {
+		comparison.Live = reply.Content
+	})
+}
+
+// createShadow creates the dry-running variant of an LLM agent described by its shadow config
+func (f *Factory) createShadow(agentConfig config.AgentConfig, agentsConfig config.AgentsConfig) *LLMAgent {
+	shadow := agentConfig.Shadow
+	agentConfig.Shadow = config.ShadowConfig{}
+	if shadow.Model != "" {
+		agentsConfig.Model = shadow.Model
+	}
+	if shadow.Temperature != 0 {
+		agentsConfig.Temperature = shadow.Temperature
+	}
+	if shadow.Description != "" {
+		agentConfig.Description = shadow.Description
+	}
+	if len(shadow.Traits) > 0 {
+		agentConfig.Traits = shadow.Traits
+	}
+	if shadow.Instructions != "" {
+		agentConfig.Instructions = shadow.Instructions
+	}
+
+	agent := f.createLLMAgent(agentConfig, agentsConfig).(*LLMAgent)
+	agent.setDryRun()
+	// The shadow neither spends the agent's quota nor writes to its scratchpad
+	agent.quotas = nil
+	agent.scratchpad = false
+	return agent
+}
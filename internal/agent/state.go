@@ -0,0 +1,231 @@
+package agent
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"philoking/internal/conversation"
+	"philoking/internal/storage"
+)
+
+// stateBucket stores one state snapshot per agent
+const stateBucket = "agent-state"
+
+// State is what an agent remembers across restarts, so its behaviour
+// continues where it left off after a deploy
+type State struct {
+	LastRespondedTo string           `json:"last_responded_to,omitempty"` // ID of the last message the agent replied to
+	LastResponseAt  time.Time        `json:"last_response_at,omitempty"`
+	RecentResponses [][]string       `json:"recent_responses,omitempty"` // Normalized words, for duplicate suppression
+	Engagement      *EngagementState `json:"engagement,omitempty"`
+	// SummaryCounts holds the messages counted towards the next digest, per conversation
+	SummaryCounts map[string]int `json:"summary_counts,omitempty"`
+	// Summaries holds the agent's latest digest of each conversation
+	Summaries map[string]*conversation.Summary `json:"summaries,omitempty"`
+	// PastQuestions holds the questions a question-of-the-day agent opened conversations with, oldest first
+	PastQuestions []string  `json:"past_questions,omitempty"`
+	SavedAt       time.Time `json:"saved_at"`
+}
+
+// EngagementState is the adaptive part of an agent's response chance
+type EngagementState struct {
+	Boost     float64              `json:"boost"`
+	UpdatedAt time.Time            `json:"updated_at"`
+	Pending   map[string]time.Time `json:"pending,omitempty"` // Agent message ID -> sent at, awaiting a reaction
+}
+
+// stateful is implemented by agents whose state survives restarts
+type stateful interface {
+	saveState(state *State)
+	restoreState(state *State)
+}
+
+// StateStore keeps agent state snapshots in the storage backend
+type StateStore struct {
+	store *storage.Store
+}
+
+// NewStateStore creates a state store in the given storage
+func NewStateStore(store *storage.Store) *StateStore {
+	return &StateStore{store: store}
+}
+
+// Load returns the saved state of an agent and whether there was one
+func (s *StateStore) Load(agentID string) (*State, bool, error) {
+	var state State
+	ok, err := s.store.Get(stateBucket, agentID, &state)
+	if err != nil || !ok {
+		return nil, false, err
+	}
+	return &state, true, nil
+}
+
+// Save stores the state of several agents in one transaction
+func (s *StateStore) Save(states map[string]*State) error {
+	return s.store.Update(func(tx *storage.Tx) error {
+		for agentID, state := range states {
+			if err := tx.Put(stateBucket, agentID, state); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// UseStateStore makes agents keep their state in the store: it is restored
+// when they start and saved every interval (if positive) and when they stop
+func (m *Manager) UseStateStore(states *StateStore, interval time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.states = states
+	m.stateInterval = interval
+}
+
+// restoreStates loads the saved state of every agent; callers hold m.mu
+func (m *Manager) restoreStates() {
+	restored := 0
+	for id, agent := range m.agents {
+		holder, ok := agent.(stateful)
+		if !ok {
+			continue
+		}
+		state, ok, err := m.states.Load(id)
+		if err != nil {
+			log.Printf("Skipping unreadable state of agent %s: %v", id, err)
+			continue
+		}
+		if ok {
+			holder.restoreState(state)
+			restored++
+		}
+	}
+	log.Printf("Restored the state of %d agent(s) from storage", restored)
+}
+
+// saveStates stores the state of every agent; callers hold m.mu
+func (m *Manager) saveStates() {
+	now := time.Now()
+	states := make(map[string]*State, len(m.agents))
+	for id, agent := range m.agents {
+		if holder, ok := agent.(stateful); ok {
+			state := &State{SavedAt: now}
+			holder.saveState(state)
+			states[id] = state
+		}
+	}
+
+	if err := m.states.Save(states); err != nil {
+		log.Printf("Error saving agent state: %v", err)
+	}
+}
+
+// saveStatesEvery saves agent state periodically until ctx is done
+func (m *Manager) saveStatesEvery(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.mu.RLock()
+			m.saveStates()
+			m.mu.RUnlock()
+		}
+	}
+}
+
+// saveState records the state shared by all agents
+func (a *BaseAgent) saveState(state *State) {
+	a.mu.RLock()
+	state.LastRespondedTo = a.lastRespondedTo
+	a.mu.RUnlock()
+
+	state.LastResponseAt = a.stats.lastResponse()
+	state.RecentResponses = a.responses.snapshot()
+	if a.engagement != nil {
+		state.Engagement = a.engagement.snapshot()
+	}
+}
+
+// restoreState picks up where a previous run of the agent left off
+func (a *BaseAgent) restoreState(state *State) {
+	a.mu.Lock()
+	a.lastRespondedTo = state.LastRespondedTo
+	a.mu.Unlock()
+
+	a.stats.restoreLastResponse(state.LastResponseAt)
+	a.responses.restore(state.RecentResponses)
+	if a.engagement != nil && state.Engagement != nil {
+		a.engagement.restore(state.Engagement)
+	}
+}
+
+// saveState adds the progress towards the next digests and the latest ones
+func (s *SummarizerAgent) saveState(state *State) {
+	s.LLMAgent.saveState(state)
+
+	s.countsMu.Lock()
+	defer s.countsMu.Unlock()
+
+	state.SummaryCounts = make(map[string]int, len(s.counts))
+	for conversationID, count := range s.counts {
+		state.SummaryCounts[conversationID] = count
+	}
+	if s.convManager == nil {
+		return
+	}
+	for conversationID := range s.counts {
+		if summary := s.convManager.GetSummary(conversationID); summary != nil && summary.AgentID == s.ID() {
+			if state.Summaries == nil {
+				state.Summaries = make(map[string]*conversation.Summary)
+			}
+			state.Summaries[conversationID] = summary
+		}
+	}
+}
+
+// restoreState resumes counting towards the next digests and brings back
+// the latest ones, unless a conversation has a newer digest already
+func (s *SummarizerAgent) restoreState(state *State) {
+	s.LLMAgent.restoreState(state)
+
+	s.countsMu.Lock()
+	for conversationID, count := range state.SummaryCounts {
+		s.counts[conversationID] = count
+	}
+	s.countsMu.Unlock()
+
+	if s.convManager == nil {
+		return
+	}
+	for conversationID, summary := range state.Summaries {
+		if s.convManager.GetSummary(conversationID) == nil {
+			s.convManager.SetSummary(conversationID, summary)
+		}
+	}
+}
+
+// saveState adds the questions asked so far
+func (q *QuestionOfTheDayAgent) saveState(state *State) {
+	q.LLMAgent.saveState(state)
+
+	q.pastMu.Lock()
+	defer q.pastMu.Unlock()
+	state.PastQuestions = append([]string(nil), q.past...)
+}
+
+// restoreState brings back the questions asked before, so they aren't repeated
+func (q *QuestionOfTheDayAgent) restoreState(state *State) {
+	q.LLMAgent.restoreState(state)
+
+	q.pastMu.Lock()
+	defer q.pastMu.Unlock()
+	q.past = append([]string(nil), state.PastQuestions...)
+	if len(q.past) > maxPastQuestions {
+		q.past = q.past[len(q.past)-maxPastQuestions:]
+	}
+}
@@ -0,0 +1,127 @@
+package agent
+
+import (
+	"sync"
+	"time"
+)
+
+// Stats is a snapshot of an agent's runtime counters
+type Stats struct {
+	AgentID        string  `json:"agent_id"`
+	Name           string  `json:"name"`
+	Running        bool    `json:"running"`
+	ResponseChance float64 `json:"response_chance"`
+	// EffectiveResponseChance is the chance after adapting to user engagement
+	EffectiveResponseChance float64   `json:"effective_response_chance"`
+	MessagesSeen            int64     `json:"messages_seen"`
+	ResponsesSent           int64     `json:"responses_sent"`
+	SkippedByChance         int64     `json:"skipped_by_chance"`
+	IgnoredByRule           int64     `json:"ignored_by_rule"`
+	LLMCalls                int64     `json:"llm_calls"`
+	LLMFailures             int64     `json:"llm_failures"`
+	Panics                  int64     `json:"panics"`             // Messages whose handling panicked
+	AverageLatencyMs        float64   `json:"average_latency_ms"` // Average LLM call latency
+	LastResponseAt          time.Time `json:"last_response_at,omitempty"`
+}
+
+// StatsReporter is implemented by agents that track runtime statistics
+type StatsReporter interface {
+	Stats() Stats
+}
+
+// statsCounter accumulates an agent's runtime counters
+type statsCounter struct {
+	mu              sync.Mutex
+	messagesSeen    int64
+	responsesSent   int64
+	skippedByChance int64
+	ignoredByRule   int64
+	llmCalls        int64
+	llmFailures     int64
+	panics          int64
+	llmLatency      time.Duration
+	lastResponseAt  time.Time
+}
+
+func (s *statsCounter) messageSeen() {
+	s.mu.Lock()
+	s.messagesSeen++
+	s.mu.Unlock()
+}
+
+func (s *statsCounter) skipped() {
+	s.mu.Lock()
+	s.skippedByChance++
+	s.mu.Unlock()
+}
+
+func (s *statsCounter) ignored() {
+	s.mu.Lock()
+	s.ignoredByRule++
+	s.mu.Unlock()
+}
+
+func (s *statsCounter) panicked() {
+	s.mu.Lock()
+	s.panics++
+	s.mu.Unlock()
+}
+
+func (s *statsCounter) responseSent() {
+	s.mu.Lock()
+	s.responsesSent++
+	s.lastResponseAt = time.Now()
+	s.mu.Unlock()
+}
+
+// sent returns the number of responses sent so far
+func (s *statsCounter) sent() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.responsesSent
+}
+
+// lastResponse returns when the agent last sent a response
+func (s *statsCounter) lastResponse() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lastResponseAt
+}
+
+// restoreLastResponse carries the last response time over from a previous run
+func (s *statsCounter) restoreLastResponse(at time.Time) {
+	s.mu.Lock()
+	if at.After(s.lastResponseAt) {
+		s.lastResponseAt = at
+	}
+	s.mu.Unlock()
+}
+
+// llmCall records the latency and outcome of an LLM request
+func (s *statsCounter) llmCall(latency time.Duration, err error) {
+	s.mu.Lock()
+	s.llmCalls++
+	s.llmLatency += latency
+	if err != nil {
+		s.llmFailures++
+	}
+	s.mu.Unlock()
+}
+
+// snapshot fills the counter fields of a Stats value
+func (s *statsCounter) snapshot(stats *Stats) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats.MessagesSeen = s.messagesSeen
+	stats.ResponsesSent = s.responsesSent
+	stats.SkippedByChance = s.skippedByChance
+	stats.IgnoredByRule = s.ignoredByRule
+	stats.LLMCalls = s.llmCalls
+	stats.LLMFailures = s.llmFailures
+	stats.Panics = s.panics
+	stats.LastResponseAt = s.lastResponseAt
+	if s.llmCalls > 0 {
+		stats.AverageLatencyMs = float64(s.llmLatency.Milliseconds()) / float64(s.llmCalls)
+	}
+}
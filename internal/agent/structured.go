@@ -0,0 +1,47 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"philoking/internal/jsonschema"
+)
+
+// maxStructuredAttempts bounds the tries for an answer matching the schema
+const maxStructuredAttempts = 3
+
+// completeStructured asks the provider for JSON matching a schema. Answers
+// that don't validate are shown to the model with the error, and it is asked
+// again; the JSON document of the first valid answer is returned.
+func (l *LLMAgent) completeStructured(ctx context.Context, conversationID string, messages []Message, schema *jsonschema.Schema) (string, error) {
+	messages = append([]Message(nil), messages...)
+	messages[0].Content += " Answer only with JSON matching this schema: " + schemaText(schema)
+
+	var lastErr error
+	for attempt := 1; attempt <= maxStructuredAttempts; attempt++ {
+		response, err := l.completeWith(ctx, conversationID, messages, schema)
+		if err != nil {
+			return "", err
+		}
+
+		document := jsonschema.Extract(response)
+		if lastErr = schema.Validate([]byte(document)); lastErr == nil {
+			return document, nil
+		}
+
+		log.Printf("Agent %s gave malformed structured output (attempt %d): %v", l.id, attempt, lastErr)
+		messages = append(messages,
+			Message{Role: "assistant", Content: response},
+			Message{Role: "user", Content: fmt.Sprintf("That answer does not match the schema: %v. Answer again with only the JSON.", lastErr)},
+		)
+	}
+	return "", fmt.Errorf("no valid structured output after %d attempts: %w", maxStructuredAttempts, lastErr)
+}
+
+// schemaText renders a schema for the prompt, for providers that can't enforce it
+func schemaText(schema *jsonschema.Schema) string {
+	data, _ := schema.MarshalJSON()
+	return strings.TrimSpace(string(data))
+}
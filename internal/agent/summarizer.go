@@ -0,0 +1,132 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"philoking/internal/config"
+	"philoking/internal/conversation"
+	"philoking/internal/kafka"
+	"philoking/internal/types"
+)
+
+// SummaryTag marks messages that contain a conversation digest
+const SummaryTag = "summary"
+
+// defaultSummaryInterval is used when no summary_interval is configured
+const defaultSummaryInterval = 20
+
+// SummarizerAgent posts a concise digest of the discussion every N messages
+type SummarizerAgent struct {
+	*LLMAgent
+	interval int
+	counts   map[string]int
+	countsMu sync.Mutex
+}
+
+// NewSummarizerAgent creates a new summarizer agent
+func NewSummarizerAgent(id, name, description string, kafkaClient *kafka.Client, config config.AgentsConfig, interval int, convManager *conversation.Manager) *SummarizerAgent {
+	if interval <= 0 {
+		interval = defaultSummaryInterval
+	}
+
+	// The summarizer sees every message; the interval decides when it speaks
+	llm := NewLLMAgent(id, name, description, kafkaClient, config, 1.0, convManager)
+	agent := &SummarizerAgent{
+		LLMAgent: llm,
+		interval: interval,
+		counts:   make(map[string]int),
+	}
+
+	// Set the message handler
+	agent.SetHandler(agent)
+
+	return agent
+}
+
+// HandleMessage counts messages and posts a digest once the interval is reached
+func (s *SummarizerAgent) HandleMessage(ctx context.Context, message *types.ChatMessage) error {
+	conversationID := message.Metadata.ConversationID
+
+	s.countsMu.Lock()
+	s.counts[conversationID]++
+	due := s.counts[conversationID] >= s.interval
+	if due {
+		s.counts[conversationID] = 0
+	}
+	s.countsMu.Unlock()
+
+	if !due {
+		return nil
+	}
+
+	if _, err := s.Summarize(ctx, conversationID); err != nil {
+		log.Printf("Error generating summary for conversation %s: %v", conversationID, err)
+	}
+	return nil
+}
+
+// Summarize generates a digest of the conversation, stores it on the
+// conversation and posts it to the chat
+func (s *SummarizerAgent) Summarize(ctx context.Context, conversationID string) (*conversation.Summary, error) {
+	summary, err := s.WriteSummary(ctx, conversationID)
+	if err != nil {
+		return nil, err
+	}
+
+	message := s.newMessage(summary.Content, conversationID)
+	message.Metadata.Tags = append(message.Metadata.Tags, SummaryTag)
+
+	log.Printf("SummarizerAgent posting summary of %d messages", summary.MessageCount)
+	if err := s.publish(ctx, message); err != nil {
+		return summary, fmt.Errorf("failed to publish summary: %w", err)
+	}
+
+	return summary, nil
+}
+
+// WriteSummary generates a digest of the conversation and stores it on the
+// conversation without posting it, e.g. for the email digest
+func (s *SummarizerAgent) WriteSummary(ctx context.Context, conversationID string) (*conversation.Summary, error) {
+	history := s.getConversationHistory(conversationID)
+	if len(history) == 0 {
+		return nil, fmt.Errorf("conversation %s has no messages to summarize", conversationID)
+	}
+
+	var transcript strings.Builder
+	for _, msg := range history {
+		sender := msg.AgentID
+		if msg.Metadata.FromAgent != "" {
+			sender = msg.Metadata.FromAgent
+		}
+		fmt.Fprintf(&transcript, "%s: %s\n", sender, msg.Content)
+	}
+
+	systemPrompt := "You summarize group discussions for people who just joined. Write a concise digest in a few sentences: the main topics, who argued what, and any open questions. Plain text only."
+	systemPrompt += s.personality()
+
+	messages := []Message{
+		{Role: "system", Content: systemPrompt},
+		{Role: "user", Content: "Summarize this conversation so far:\n\n" + transcript.String()},
+	}
+
+	response, err := s.complete(ctx, conversationID, messages)
+	if err != nil {
+		return nil, err
+	}
+
+	summary := &conversation.Summary{
+		Content:      s.cleanResponse(response),
+		AgentID:      s.ID(),
+		MessageCount: len(history),
+		CreatedAt:    time.Now(),
+	}
+	if s.convManager != nil {
+		s.convManager.SetSummary(conversationID, summary)
+	}
+	return summary, nil
+}
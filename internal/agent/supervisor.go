@@ -0,0 +1,224 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"philoking/internal/config"
+	"philoking/internal/kafka"
+	"philoking/internal/types"
+
+	"github.com/google/uuid"
+)
+
+// ErrHandlerPanic is returned for a message whose handling panicked; the
+// consumer moves such messages to the dead-letter topic without a retry
+var ErrHandlerPanic = fmt.Errorf("message %w", kafka.ErrPanicked)
+
+// health is what the supervisor checks of an agent
+type health struct {
+	running   bool
+	busySince time.Time // Zero when idle
+	panicked  error
+}
+
+// supervised is implemented by agents the supervisor can check
+type supervised interface {
+	health() health
+}
+
+// health reports whether the agent runs, and how its handler fares
+func (a *BaseAgent) health() health {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return health{running: a.running, busySince: a.busySince, panicked: a.panicked}
+}
+
+// callHandler has the handler handle a message, noting how long it takes so
+// a stuck handler shows
+func (a *BaseAgent) callHandler(ctx context.Context, handler MessageHandler, message *types.ChatMessage) (err error) {
+	a.mu.Lock()
+	a.busySince = time.Now()
+	a.mu.Unlock()
+
+	defer func() {
+		a.mu.Lock()
+		a.busySince = time.Time{}
+		a.mu.Unlock()
+	}()
+	defer a.recoverPanic(message, &err)
+
+	return handler.HandleMessage(ctx, message)
+}
+
+// recoverPanic turns a panic while handling a message into an error, logging
+// the stack and counting it; it must be deferred directly
+func (a *BaseAgent) recoverPanic(message *types.ChatMessage, err *error) {
+	recovered := recover()
+	if recovered == nil {
+		return
+	}
+	*err = fmt.Errorf("%w: %v", ErrHandlerPanic, recovered)
+	log.Printf("Agent %s panicked handling message %s: %v\n%s", a.id, message.ID, recovered, debug.Stack())
+	a.stats.panicked()
+
+	a.mu.Lock()
+	a.panicked = *err
+	a.mu.Unlock()
+}
+
+// supervisor restarts the agents of a manager that died or got stuck
+type supervisor struct {
+	manager *Manager
+	config  config.SupervisorConfig
+	mu      sync.Mutex
+	agents  map[string]*supervision
+}
+
+// supervision is what the supervisor keeps of an agent it restarted
+type supervision struct {
+	restarts    int       // Restarts in a row, for the backoff
+	restartedAt time.Time // Time of the latest restart
+	next        time.Time // Earliest time of the next restart
+	restarting  bool
+}
+
+// UseSupervisor has the manager check its agents once started and restart
+// those whose subscription ended, whose handler panicked or that are stuck
+func (m *Manager) UseSupervisor(cfg config.SupervisorConfig) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.supervisor = &supervisor{
+		manager: m,
+		config:  cfg,
+		agents:  make(map[string]*supervision),
+	}
+}
+
+// run checks the agents every interval until ctx is done
+func (s *supervisor) run(ctx context.Context) {
+	ticker := time.NewTicker(s.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			s.check(ctx, now)
+		}
+	}
+}
+
+// check restarts the agents in trouble whose backoff has passed
+func (s *supervisor) check(ctx context.Context, now time.Time) {
+	agents := s.manager.ListAgents()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	registered := make(map[string]bool, len(agents))
+	for _, agent := range agents {
+		registered[agent.ID()] = true
+		checked, ok := agent.(supervised)
+		if !ok {
+			continue
+		}
+		sup, ok := s.agents[agent.ID()]
+		if !ok {
+			sup = &supervision{}
+			s.agents[agent.ID()] = sup
+		}
+
+		reason := s.diagnose(checked.health(), now)
+		if reason == "" {
+			// An agent that stays up long enough starts over with the shortest backoff
+			if sup.restarts > 0 && now.Sub(sup.restartedAt) >= s.config.MaxBackoff {
+				sup.restarts = 0
+			}
+			continue
+		}
+		if sup.restarting || now.Before(sup.next) {
+			continue
+		}
+
+		sup.restarting = true
+		sup.restarts++
+		sup.restartedAt = now
+		sup.next = now.Add(s.backoff(sup.restarts))
+		// A stuck handler may take a while to give up, so each restart runs on its own
+		go s.restart(ctx, agent, reason)
+	}
+
+	for id := range s.agents {
+		if !registered[id] {
+			delete(s.agents, id)
+		}
+	}
+}
+
+// diagnose returns what is wrong with an agent, or "" when it is fine
+func (s *supervisor) diagnose(h health, now time.Time) string {
+	switch {
+	case !h.running:
+		return "it stopped unexpectedly"
+	case h.panicked != nil:
+		return "its " + h.panicked.Error()
+	case !h.busySince.IsZero() && now.Sub(h.busySince) > s.config.StuckAfter:
+		return fmt.Sprintf("it was stuck on a message for %s", now.Sub(h.busySince).Round(time.Second))
+	}
+	return ""
+}
+
+// backoff returns the least time before restarting an agent again after its nth restart in a row
+func (s *supervisor) backoff(restarts int) time.Duration {
+	wait := s.config.Backoff
+	for i := 1; i < restarts && wait < s.config.MaxBackoff; i++ {
+		wait *= 2
+	}
+	return min(wait, s.config.MaxBackoff)
+}
+
+// restart restarts an agent and sends an alert saying why
+func (s *supervisor) restart(ctx context.Context, agent Agent, reason string) {
+	defer func() {
+		s.mu.Lock()
+		if sup, ok := s.agents[agent.ID()]; ok {
+			sup.restarting = false
+		}
+		s.mu.Unlock()
+	}()
+
+	log.Printf("Supervisor restarting agent %s (%s): %s", agent.ID(), agent.Name(), reason)
+	err := s.manager.RestartAgent(agent.ID())
+	if errors.Is(err, ErrAgentNotFound) {
+		return // Removed in the meantime
+	}
+
+	content := fmt.Sprintf("%s was restarted because %s", agent.Name(), reason)
+	if err != nil {
+		log.Printf("Supervisor could not restart agent %s: %v", agent.ID(), err)
+		content = fmt.Sprintf("%s could not be restarted after %s: %v", agent.Name(), reason, err)
+	}
+	alert := &types.ChatMessage{
+		ID:        uuid.New().String(),
+		Type:      types.MessageTypeAlert,
+		Content:   content,
+		Timestamp: time.Now(),
+		Metadata: types.Metadata{
+			Custom: map[string]string{
+				types.AlertAgentKey:  agent.ID(),
+				types.AlertReasonKey: reason,
+			},
+		},
+	}
+	if err := s.manager.kafkaClient.PublishMessage(ctx, alert); err != nil {
+		log.Printf("Supervisor failed to send an alert about agent %s: %v", agent.ID(), err)
+	}
+}
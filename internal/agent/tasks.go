@@ -0,0 +1,244 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"philoking/internal/tasks"
+)
+
+// Kinds of tasks agents do in the background
+const (
+	// TaskResearch looks into a question step by step and reports the findings
+	TaskResearch = "research"
+	// TaskIngest reads a document, part by part, and tells the group what it says
+	TaskIngest = "ingest"
+)
+
+// TaskKinds lists the kinds of tasks agents do
+var TaskKinds = []string{TaskResearch, TaskIngest}
+
+const (
+	// maxResearchSteps bounds the sub-questions of a research task
+	maxResearchSteps = 4
+	// ingestChunkSize is the number of bytes of a document read at a time
+	ingestChunkSize = 6000
+)
+
+// TaskRunner is implemented by agents that do tasks in the background
+type TaskRunner interface {
+	RunTask(ctx context.Context, task *tasks.Task, progress tasks.Progress) (string, error)
+}
+
+// RunTask hands a task to the agent it names; it is the worker the manager
+// registers with the task queue
+func (m *Manager) RunTask(ctx context.Context, task *tasks.Task, progress tasks.Progress) (string, error) {
+	agent, exists := m.GetAgent(task.AgentID)
+	if !exists {
+		return "", fmt.Errorf("agent %s not found", task.AgentID)
+	}
+	runner, ok := agent.(TaskRunner)
+	if !ok {
+		return "", fmt.Errorf("agent %s does not do tasks", task.AgentID)
+	}
+	return runner.RunTask(ctx, task, progress)
+}
+
+// RunTask does a research or ingestion task and posts the outcome to the task's conversation
+func (l *LLMAgent) RunTask(ctx context.Context, task *tasks.Task, progress tasks.Progress) (string, error) {
+	var report string
+	var err error
+	switch task.Kind {
+	case TaskResearch:
+		report, err = l.research(ctx, task, progress)
+	case TaskIngest:
+		report, err = l.ingest(ctx, task, progress)
+	default:
+		return "", fmt.Errorf("agent %s does not do %s tasks", l.id, task.Kind)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	report = l.cleanResponse(report)
+	if task.ConversationID != "" {
+		if err := l.SendMessage(ctx, report, task.ConversationID); err != nil && !errors.Is(err, ErrDuplicateResponse) {
+			return "", err
+		}
+	}
+	return report, nil
+}
+
+// research splits a question into sub-questions, looks into each (reading
+// pages when fetching is enabled) and writes up the findings
+func (l *LLMAgent) research(ctx context.Context, task *tasks.Task, progress tasks.Progress) (string, error) {
+	system := l.systemPrompt() + " You are researching a question in depth before reporting back to the group."
+
+	progress(5, "planning")
+	plan, err := l.complete(ctx, task.ConversationID, []Message{
+		{Role: "system", Content: system},
+		{Role: "user", Content: fmt.Sprintf("Break this question into at most %d sub-questions worth looking into, one per line and nothing else: %s", maxResearchSteps, task.Input)},
+	})
+	if err != nil {
+		return "", err
+	}
+	steps := planSteps(plan, maxResearchSteps)
+	if len(steps) == 0 {
+		steps = []string{task.Input}
+	}
+
+	notes := make([]string, 0, len(steps))
+	for i, step := range steps {
+		progress(10+80*i/len(steps), "looking into: "+step)
+		finding, err := l.completeWithTools(ctx, task.ConversationID, []Message{
+			{Role: "system", Content: system},
+			{Role: "user", Content: fmt.Sprintf("The question is: %s\nLook into this part of it and note what you find in a few sentences: %s", task.Input, step)},
+		}, l.fetchTools())
+		if err != nil {
+			return "", err
+		}
+		notes = append(notes, step+"\n"+finding)
+	}
+
+	progress(90, "writing up")
+	return l.complete(ctx, task.ConversationID, []Message{
+		{Role: "system", Content: l.systemPrompt()},
+		{Role: "user", Content: fmt.Sprintf("You researched the question %q. Your notes:\n\n%s\n\nTell the group what you found in a short message.", task.Input, strings.Join(notes, "\n\n"))},
+	})
+}
+
+// ingest reads a document, a URL or the text itself, summarizes it part by
+// part and tells the group what it says
+func (l *LLMAgent) ingest(ctx context.Context, task *tasks.Task, progress tasks.Progress) (string, error) {
+	text, source := task.Input, "a document"
+	if strings.HasPrefix(text, "http://") || strings.HasPrefix(text, "https://") {
+		if l.fetcher == nil {
+			return "", fmt.Errorf("reading pages is disabled (agents.fetch.enabled)")
+		}
+		progress(5, "fetching "+text)
+		page, err := l.fetcher.Fetch(ctx, text)
+		if err != nil {
+			return "", err
+		}
+		text, source = page.Text, page.URL
+		if page.Title != "" {
+			source = page.Title
+		}
+	}
+
+	parts := splitText(text, ingestChunkSize)
+	if len(parts) == 0 {
+		return "", fmt.Errorf("%s has no readable text", source)
+	}
+	summaries := make([]string, 0, len(parts))
+	for i, part := range parts {
+		progress(10+80*i/len(parts), fmt.Sprintf("reading part %d of %d", i+1, len(parts)))
+		summary, err := l.complete(ctx, task.ConversationID, []Message{
+			{Role: "system", Content: "You summarize documents faithfully, keeping facts, names and numbers."},
+			{Role: "user", Content: fmt.Sprintf("Summarize this part of %s in a few sentences:\n\n%s", source, part)},
+		})
+		if err != nil {
+			return "", err
+		}
+		summaries = append(summaries, summary)
+	}
+
+	progress(90, "writing up")
+	return l.complete(ctx, task.ConversationID, []Message{
+		{Role: "system", Content: l.systemPrompt()},
+		{Role: "user", Content: fmt.Sprintf("You read %s. Summaries of its parts, in order:\n\n%s\n\nTell the group in a short message what it says and what stands out.", source, strings.Join(summaries, "\n\n"))},
+	})
+}
+
+// planSteps reads the sub-questions of a research plan, one per line, without list markers
+func planSteps(plan string, limit int) []string {
+	var steps []string
+	for _, line := range strings.Split(plan, "\n") {
+		line = strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(line), "-*•0123456789.)"))
+		if line == "" {
+			continue
+		}
+		steps = append(steps, line)
+		if len(steps) == limit {
+			break
+		}
+	}
+	return steps
+}
+
+// splitText splits text into parts of at most size bytes, at paragraph
+// breaks where it can
+func splitText(text string, size int) []string {
+	var parts []string
+	var current strings.Builder
+	flush := func() {
+		if part := strings.TrimSpace(current.String()); part != "" {
+			parts = append(parts, part)
+		}
+		current.Reset()
+	}
+
+	for _, paragraph := range strings.Split(text, "\n") {
+		for len(paragraph) > size {
+			flush()
+			cut := strings.LastIndexByte(paragraph[:size], ' ')
+			if cut <= 0 {
+				cut = size
+			}
+			parts = append(parts, strings.TrimSpace(paragraph[:cut]))
+			paragraph = paragraph[cut:]
+		}
+		if current.Len()+len(paragraph)+1 > size {
+			flush()
+		}
+		current.WriteString(paragraph)
+		current.WriteString("\n")
+	}
+	flush()
+	return parts
+}
+
+// taskTools returns the tool agents start background tasks with, if they may
+func (l *LLMAgent) taskTools(conversationID string) []Tool {
+	if l.tasks == nil {
+		return nil
+	}
+	return []Tool{&startTaskTool{agent: l, conversationID: conversationID}}
+}
+
+// startTaskTool queues a research or ingestion task for the agent itself
+type startTaskTool struct {
+	agent          *LLMAgent
+	conversationID string
+}
+
+func (t *startTaskTool) Name() string {
+	return "start_task"
+}
+
+func (t *startTaskTool) Description() string {
+	return "starts work that takes longer than a reply, which you report on when it's done; " +
+		"the input is \"research\" followed by a question to look into in depth, " +
+		"or \"ingest\" followed by the URL or text of a document to read, e.g. \"research how did stoicism spread in Rome\""
+}
+
+func (t *startTaskTool) Call(ctx context.Context, input string) (string, error) {
+	kind, rest, _ := strings.Cut(strings.TrimSpace(input), " ")
+	rest = strings.TrimSpace(rest)
+	if (kind != TaskResearch && kind != TaskIngest) || rest == "" {
+		return "", fmt.Errorf("input must be \"research\" or \"ingest\" followed by what to work on")
+	}
+
+	task, err := t.agent.tasks.Enqueue(ctx, tasks.Task{
+		Kind:           kind,
+		ConversationID: t.conversationID,
+		AgentID:        t.agent.id,
+		Input:          rest,
+	})
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("task %s was queued; the group will see its progress, and you'll post the outcome when it's done", task.ID), nil
+}
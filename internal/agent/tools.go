@@ -0,0 +1,96 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// maxToolCalls bounds the number of tool round-trips per reply
+const maxToolCalls = 4
+
+// Tool is a capability an LLM agent can invoke while composing a reply
+type Tool interface {
+	// Name returns the identifier the model uses to call the tool
+	Name() string
+
+	// Description explains to the model what the tool does and what input it expects
+	Description() string
+
+	// Call runs the tool with the model-provided input
+	Call(ctx context.Context, input string) (string, error)
+}
+
+// toolInstructions describes the available tools and the calling convention
+func toolInstructions(tools []Tool) string {
+	var b strings.Builder
+	b.WriteString("\n\nYou have access to these tools:\n")
+	for _, tool := range tools {
+		fmt.Fprintf(&b, "- %s: %s\n", tool.Name(), tool.Description())
+	}
+	b.WriteString("To use a tool, reply with a single line of the form \"CALL <tool>: <input>\" and nothing else. ")
+	b.WriteString("You will receive the result and can then call another tool or write your final answer.")
+	return b.String()
+}
+
+// parseToolCall extracts the tool name and input from a CALL line
+func parseToolCall(response string) (name, input string, ok bool) {
+	line := strings.TrimSpace(response)
+	if !strings.HasPrefix(line, "CALL ") {
+		return "", "", false
+	}
+
+	name, input, found := strings.Cut(strings.TrimPrefix(line, "CALL "), ":")
+	if !found {
+		return "", "", false
+	}
+	return strings.TrimSpace(name), strings.TrimSpace(input), true
+}
+
+// completeWithTools runs a completion loop in which the model may call tools
+// before giving its final answer
+func (l *LLMAgent) completeWithTools(ctx context.Context, conversationID string, messages []Message, tools []Tool) (string, error) {
+	if len(tools) == 0 {
+		return l.complete(ctx, conversationID, messages)
+	}
+
+	byName := make(map[string]Tool, len(tools))
+	for _, tool := range tools {
+		byName[tool.Name()] = tool
+	}
+
+	// Describe the tools in the system prompt
+	messages = append([]Message(nil), messages...)
+	messages[0].Content += toolInstructions(tools)
+
+	for i := 0; i <= maxToolCalls; i++ {
+		response, err := l.complete(ctx, conversationID, messages)
+		if err != nil {
+			return "", err
+		}
+
+		name, input, ok := parseToolCall(response)
+		if !ok {
+			return response, nil
+		}
+
+		var result string
+		if tool, exists := byName[name]; !exists {
+			result = fmt.Sprintf("unknown tool %q", name)
+		} else {
+			log.Printf("Agent %s calling tool %s: %s", l.id, name, input)
+			result, err = tool.Call(ctx, input)
+			if err != nil {
+				result = "error: " + err.Error()
+			}
+		}
+
+		messages = append(messages,
+			Message{Role: "assistant", Content: response},
+			Message{Role: "user", Content: fmt.Sprintf("RESULT %s: %s", name, result)},
+		)
+	}
+
+	return "", fmt.Errorf("agent %s exceeded %d tool calls", l.id, maxToolCalls)
+}
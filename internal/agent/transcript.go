@@ -0,0 +1,52 @@
+package agent
+
+import (
+	"sync"
+
+	"philoking/internal/conversation"
+	"philoking/internal/types"
+)
+
+// maxHistory is how many of the latest messages an agent puts in its prompts
+const maxHistory = 1000
+
+// agentHistory keeps the history of each conversation an agent takes part in,
+// extending it with the messages that arrived since it was last read instead
+// of copying the whole history for every reply
+type agentHistory struct {
+	mu             sync.Mutex
+	byConversation map[string]*heldHistory
+}
+
+// heldHistory is a conversation's history as an agent last read it
+type heldHistory struct {
+	messages []*types.ChatMessage
+	cursor   conversation.HistoryCursor
+}
+
+// read returns the latest messages of a conversation. The slice is shared
+// with later reads and must not be modified; appending to it is fine.
+func (h *agentHistory) read(manager *conversation.Manager, conversationID string) []*types.ChatMessage {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.byConversation == nil {
+		h.byConversation = make(map[string]*heldHistory)
+	}
+	held, ok := h.byConversation[conversationID]
+	if !ok {
+		held = &heldHistory{}
+		h.byConversation[conversationID] = held
+	}
+
+	newer, cursor, ok := manager.GetMessagesSince(conversationID, held.cursor)
+	if !ok {
+		held.messages = nil // Messages were removed or replaced since
+	}
+	held.messages = append(held.messages, newer...)
+	held.cursor = cursor
+	if len(held.messages) > maxHistory {
+		held.messages = append([]*types.ChatMessage(nil), held.messages[len(held.messages)-maxHistory:]...)
+	}
+	return held.messages[:len(held.messages):len(held.messages)]
+}
@@ -0,0 +1,52 @@
+package agent
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"philoking/internal/conversation"
+	"philoking/internal/types"
+)
+
+func TestAgentHistoryRead(t *testing.T) {
+	manager := conversation.NewManager()
+	add := func(from, to int) {
+		for i := from; i < to; i++ {
+			manager.AddMessage("c", &types.ChatMessage{ID: fmt.Sprintf("m%d", i), Type: types.MessageTypeUser, Timestamp: time.Now()})
+		}
+	}
+	var history agentHistory
+
+	add(0, maxHistory+10)
+	first := history.read(manager, "c")
+	if len(first) != maxHistory || first[0].ID != "m10" {
+		t.Fatalf("read %d messages from %s, want the latest %d", len(first), first[0].ID, maxHistory)
+	}
+
+	add(maxHistory+10, maxHistory+12)
+	second := history.read(manager, "c")
+	if len(second) != maxHistory || second[len(second)-1].ID != fmt.Sprintf("m%d", maxHistory+11) {
+		t.Fatalf("read %d messages up to %s", len(second), second[len(second)-1].ID)
+	}
+	if first[0].ID != "m10" || first[len(first)-1].ID != fmt.Sprintf("m%d", maxHistory+9) {
+		t.Error("a later read changed the history an earlier one returned")
+	}
+
+	// Appending to what read returned must not leak into the next read
+	_ = append(second, &types.ChatMessage{ID: "prompt"})
+	if third := history.read(manager, "c"); third[len(third)-1].ID == "prompt" {
+		t.Error("an append by the caller ended up in the history")
+	}
+
+	// Expiring messages rewrites the history, so it is read anew
+	expiresAt := time.Now().Add(time.Minute)
+	manager.AddMessage("c", &types.ChatMessage{ID: "ephemeral", Type: types.MessageTypeUser, Timestamp: time.Now(), Metadata: types.Metadata{ExpiresAt: &expiresAt}})
+	if latest := history.read(manager, "c"); latest[len(latest)-1].ID != "ephemeral" {
+		t.Fatalf("latest message %s, want the ephemeral one", latest[len(latest)-1].ID)
+	}
+	manager.ExpireMessages("c", time.Now().Add(time.Hour))
+	if latest := history.read(manager, "c"); latest[len(latest)-1].ID == "ephemeral" {
+		t.Error("the expired message is still in the history")
+	}
+}
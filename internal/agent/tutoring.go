@@ -0,0 +1,52 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"philoking/internal/conversation"
+)
+
+// objectiveMetMarker ends a tutor's reply once the user met the lesson's objective
+const objectiveMetMarker = "OBJECTIVE MET"
+
+// tutorPrompt returns the Socratic instructions for the tutor of a session
+func tutorPrompt(tutoring *conversation.Tutoring) string {
+	lesson := tutoring.Lesson()
+	prompt := fmt.Sprintf(" You are tutoring the human Socratically. The current lesson is %q", lesson.Topic)
+	if lesson.Objective != "" {
+		prompt += fmt.Sprintf(" and its objective: %s", lesson.Objective)
+	}
+	return prompt + ". Only ask guiding questions, one at a time, that lead them to the answer; never lecture or give the answer away." +
+		" Once they can explain the objective in their own words, confirm it briefly and end your reply with a line saying \"" + objectiveMetMarker + "\"."
+}
+
+// tutors reports whether the agent is the tutor of a running tutoring session
+func (l *LLMAgent) tutors(conversationID string) bool {
+	if l.convManager == nil {
+		return false
+	}
+	tutoring := l.convManager.GetActiveTutoring(conversationID)
+	return tutoring != nil && tutoring.Tutor == l.id
+}
+
+// parseObjectiveMet strips the objective marker off a tutor's reply and reports whether it was there
+func parseObjectiveMet(response string) (string, bool) {
+	trimmed := strings.TrimSpace(response)
+	if len(trimmed) < len(objectiveMetMarker) || !strings.EqualFold(trimmed[len(trimmed)-len(objectiveMetMarker):], objectiveMetMarker) {
+		return response, false
+	}
+	return strings.TrimSpace(trimmed[:len(trimmed)-len(objectiveMetMarker)]), true
+}
+
+// sendObjectiveMet tells the moderator, with the confirming reply, that the lesson is done
+func (a *BaseAgent) sendObjectiveMet(ctx context.Context, content, conversationID string) error {
+	message := a.newMessage(content, conversationID)
+	message.Metadata.Tags = []string{conversation.ObjectiveMetTag}
+	if err := a.publish(ctx, message); err != nil {
+		return err
+	}
+	a.responses.add(content)
+	return nil
+}
@@ -0,0 +1,85 @@
+package agent
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"philoking/internal/config"
+	"philoking/internal/types"
+)
+
+// usageKey carries the usage added up while generating a reply
+type usageKey struct{}
+
+// replyUsage adds up the LLM calls made to generate a reply, tool calls and
+// regenerations included
+type replyUsage struct {
+	mu               sync.Mutex
+	latency          time.Duration
+	promptTokens     int
+	completionTokens int
+	cost             float64
+	priced           bool // Set once a call had a price
+}
+
+// withUsage adds up the LLM calls made with ctx
+func withUsage(ctx context.Context) context.Context {
+	return context.WithValue(ctx, usageKey{}, &replyUsage{})
+}
+
+// usageFrom returns the usage added up with ctx, or nil outside a reply
+func usageFrom(ctx context.Context) *replyUsage {
+	usage, _ := ctx.Value(usageKey{}).(*replyUsage)
+	return usage
+}
+
+// add counts a call that took the given time; price may be nil
+func (u *replyUsage) add(completion *Completion, took time.Duration, price *config.ModelPriceConfig) {
+	if u == nil {
+		return
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	u.latency += took
+	u.promptTokens += completion.PromptTokens
+	u.completionTokens += completion.CompletionTokens
+	if price != nil {
+		u.cost += (float64(completion.PromptTokens)*price.Input + float64(completion.CompletionTokens)*price.Output) / 1e6
+		u.priced = true
+	}
+}
+
+// annotate records the usage in the custom metadata of a reply
+func (u *replyUsage) annotate(message *types.ChatMessage) {
+	if u == nil {
+		return
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if u.latency == 0 {
+		return
+	}
+	if message.Metadata.Custom == nil {
+		message.Metadata.Custom = make(map[string]string)
+	}
+	message.Metadata.Custom[types.LatencyKey] = strconv.FormatInt(u.latency.Milliseconds(), 10)
+	message.Metadata.Custom[types.PromptTokensKey] = strconv.Itoa(u.promptTokens)
+	message.Metadata.Custom[types.CompletionTokensKey] = strconv.Itoa(u.completionTokens)
+	if u.priced {
+		message.Metadata.Custom[types.CostKey] = strconv.FormatFloat(u.cost, 'f', 6, 64)
+	}
+}
+
+// price returns what the agent's model costs, or nil when it has no price
+func (l *LLMAgent) price() *config.ModelPriceConfig {
+	for i, price := range l.config.Usage.Prices {
+		if price.Model == l.config.Model {
+			return &l.config.Usage.Prices[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/rand"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"philoking/internal/conversation"
+	"philoking/internal/kafka"
+	"philoking/internal/types"
+)
+
+// maxDice bounds the number of dice and sides in a single roll
+const maxDice = 100
+
+// dicePattern matches dice notation such as "d20", "2d6" or "3d8+2"
+var dicePattern = regexp.MustCompile(`^(\d*)d(\d+)([+-]\d+)?$`)
+
+// UtilityAgent answers structured commands such as /roll, /flip, /pick and /timer.
+// Poll commands are handled by the conversation flow.
+type UtilityAgent struct {
+	*BaseAgent
+	rng *rand.Rand
+}
+
+// NewUtilityAgent creates a new utility agent
+func NewUtilityAgent(id, name string, kafkaClient *kafka.Client, convManager *conversation.Manager) *UtilityAgent {
+	// Commands always deserve an answer, so the utility agent never skips by chance
+	base := NewBaseAgent(id, name, kafkaClient, 1.0, convManager)
+	base.allowRepeats = true // Two rolls may well give the same result
+	agent := &UtilityAgent{
+		BaseAgent: base,
+		rng:       rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+
+	// Set the message handler
+	agent.SetHandler(agent)
+
+	return agent
+}
+
+// HandleMessage executes command messages and ignores everything else
+func (u *UtilityAgent) HandleMessage(ctx context.Context, message *types.ChatMessage) error {
+	if !message.IsCommand() {
+		return nil
+	}
+
+	command := message.Metadata.Command
+	conversationID := message.Metadata.ConversationID
+
+	var response string
+	var err error
+	switch command.Name {
+	case "roll":
+		response, err = u.roll(command.Args)
+	case "flip":
+		response = u.flip()
+	case "pick":
+		response, err = u.pick(command.Args)
+	case "timer":
+		response, err = u.timer(ctx, command, conversationID)
+	case "help":
+		response = "Commands: /roll [NdM+K], /flip, /pick <a> <b> ..., /timer <duration> [label], /poll <question> | <option> | <option> ..., /vote <poll id> <number> [reason], /closepoll <poll id>, /aside <agent id> <agent id> <topic>, /phase [next|start|<name>]"
+	default:
+		// Unknown commands may belong to another agent
+		return nil
+	}
+
+	if err != nil {
+		response = fmt.Sprintf("/%s: %v", command.Name, err)
+	}
+
+	log.Printf("UtilityAgent answering /%s: %s", command.Name, response)
+	return u.SendMessage(ctx, response, conversationID)
+}
+
+// roll rolls dice in NdM+K notation, defaulting to a single six-sided die
+func (u *UtilityAgent) roll(args []string) (string, error) {
+	notation := "1d6"
+	if len(args) > 0 {
+		notation = strings.ToLower(args[0])
+	}
+
+	match := dicePattern.FindStringSubmatch(notation)
+	if match == nil {
+		return "", fmt.Errorf("invalid dice notation %q, try something like 2d6+1", notation)
+	}
+
+	count := 1
+	if match[1] != "" {
+		count, _ = strconv.Atoi(match[1])
+	}
+	sides, _ := strconv.Atoi(match[2])
+	modifier := 0
+	if match[3] != "" {
+		modifier, _ = strconv.Atoi(match[3])
+	}
+
+	if count < 1 || count > maxDice || sides < 2 || sides > maxDice*10 {
+		return "", fmt.Errorf("dice out of range (1-%d dice with 2-%d sides)", maxDice, maxDice*10)
+	}
+
+	rolls := make([]string, count)
+	total := modifier
+	for i := range rolls {
+		value := u.rng.Intn(sides) + 1
+		total += value
+		rolls[i] = strconv.Itoa(value)
+	}
+
+	return fmt.Sprintf("🎲 %s → [%s] = %d", notation, strings.Join(rolls, ", "), total), nil
+}
+
+// flip flips a fair coin
+func (u *UtilityAgent) flip() string {
+	if u.rng.Intn(2) == 0 {
+		return "🪙 Heads"
+	}
+	return "🪙 Tails"
+}
+
+// pick chooses one of the given options at random
+func (u *UtilityAgent) pick(args []string) (string, error) {
+	if len(args) < 2 {
+		return "", fmt.Errorf("give at least two options to pick from")
+	}
+	return fmt.Sprintf("👉 %s", args[u.rng.Intn(len(args))]), nil
+}
+
+// timer schedules a reminder message after the given duration
+func (u *UtilityAgent) timer(ctx context.Context, command *types.Command, conversationID string) (string, error) {
+	if len(command.Args) == 0 {
+		return "", fmt.Errorf("usage: /timer <duration> [label], e.g. /timer 5m tea")
+	}
+
+	duration, err := time.ParseDuration(command.Args[0])
+	if err != nil || duration <= 0 {
+		return "", fmt.Errorf("invalid duration %q", command.Args[0])
+	}
+
+	label := strings.Join(command.Args[1:], " ")
+	if label == "" {
+		label = "Timer"
+	}
+
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-time.After(duration):
+			if err := u.SendMessage(ctx, fmt.Sprintf("⏰ %s (%s) is up!", label, duration), conversationID); err != nil {
+				log.Printf("UtilityAgent failed to send timer message: %v", err)
+			}
+		}
+	}()
+
+	return fmt.Sprintf("⏱️ %s set for %s", label, duration), nil
+}
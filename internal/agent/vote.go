@@ -0,0 +1,76 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"philoking/internal/types"
+)
+
+// castVote asks the LLM to pick an option in an announced poll and posts the vote
+func (l *LLMAgent) castVote(ctx context.Context, message *types.ChatMessage) error {
+	if l.convManager == nil {
+		return nil
+	}
+
+	poll, exists := l.convManager.GetPoll(message.Metadata.Custom["poll_id"])
+	if !exists || poll.Closed {
+		return nil
+	}
+
+	var prompt strings.Builder
+	fmt.Fprintf(&prompt, "A poll was opened: %s\n", poll.Question)
+	for i, option := range poll.Options {
+		fmt.Fprintf(&prompt, "%d. %s\n", i+1, option)
+	}
+	prompt.WriteString("Reply with the number of your choice followed by a one-sentence reason, e.g. \"2 because ...\".")
+
+	history := l.getConversationHistory(message.Metadata.ConversationID)
+	response, err := l.complete(ctx, message.Metadata.ConversationID, l.buildMessages(l.systemPrompt(), history, prompt.String()))
+	if err != nil {
+		log.Printf("Error generating vote for poll %s: %v", poll.ID, err)
+		return nil
+	}
+
+	option, reason := parseVote(l.cleanResponse(response), len(poll.Options))
+	if option == 0 {
+		log.Printf("Agent %s gave no usable vote for poll %s: %s", l.id, poll.ID, response)
+		return nil
+	}
+
+	content := strings.TrimSpace(fmt.Sprintf("/vote %s %d %s", poll.ID, option, reason))
+	vote := l.newMessage(content, message.Metadata.ConversationID)
+	vote.Metadata.Command = types.ParseCommand(content)
+
+	log.Printf("Agent %s voting %d in poll %s", l.id, option, poll.ID)
+	return l.publish(ctx, vote)
+}
+
+// parseVote extracts a leading option number and the remaining reason from a
+// model response, returning option 0 if no valid number was found
+func parseVote(response string, options int) (int, string) {
+	fields := strings.Fields(response)
+	if len(fields) == 0 {
+		return 0, ""
+	}
+
+	option, err := strconv.Atoi(strings.Trim(fields[0], ".:)-"))
+	if err != nil || option < 1 || option > options {
+		return 0, ""
+	}
+
+	return option, strings.Join(fields[1:], " ")
+}
+
+// hasTag reports whether a message carries the given tag
+func hasTag(message *types.ChatMessage, tag string) bool {
+	for _, t := range message.Metadata.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,79 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"philoking/internal/conversation"
+	"philoking/internal/kafka"
+)
+
+// whiteboardTools returns the tools agents use to co-write the whiteboard of one conversation
+func (l *LLMAgent) whiteboardTools(conversationID string) []Tool {
+	if !l.whiteboardAccess || l.convManager == nil {
+		return nil
+	}
+	return []Tool{
+		&whiteboardReadTool{convManager: l.convManager, conversationID: conversationID},
+		&whiteboardEditTool{convManager: l.convManager, kafkaClient: l.kafkaClient, conversationID: conversationID, agentID: l.id},
+	}
+}
+
+// whiteboardReadTool reads the whiteboard of a conversation
+type whiteboardReadTool struct {
+	convManager    *conversation.Manager
+	conversationID string
+}
+
+func (t *whiteboardReadTool) Name() string {
+	return "read_whiteboard"
+}
+
+func (t *whiteboardReadTool) Description() string {
+	return "returns the document the group is writing together, with its version; the input is ignored"
+}
+
+func (t *whiteboardReadTool) Call(ctx context.Context, input string) (string, error) {
+	whiteboard := t.convManager.GetWhiteboard(t.conversationID)
+	if whiteboard == nil {
+		return "the whiteboard is empty (version 0)", nil
+	}
+	return fmt.Sprintf("version %d, %q:\n%s", whiteboard.Version, whiteboard.Title, whiteboard.Text), nil
+}
+
+// whiteboardEditTool proposes an edit of the whiteboard of a conversation
+type whiteboardEditTool struct {
+	convManager    *conversation.Manager
+	kafkaClient    *kafka.Client
+	conversationID string
+	agentID        string
+}
+
+func (t *whiteboardEditTool) Name() string {
+	return "edit_whiteboard"
+}
+
+func (t *whiteboardEditTool) Description() string {
+	return "edits the shared document; the input is a JSON object: " +
+		`{"op":"append","text":"a new paragraph","summary":"why"}, ` +
+		`{"op":"replace","find":"exact text on the whiteboard","replace":"its new wording","summary":"why"} or ` +
+		`{"op":"set","text":"the whole document","base_version":3,"summary":"why"}. Prefer small edits over rewriting the document`
+}
+
+func (t *whiteboardEditTool) Call(ctx context.Context, input string) (string, error) {
+	var edit conversation.WhiteboardEdit
+	if err := json.Unmarshal([]byte(input), &edit); err != nil {
+		return "", fmt.Errorf("input must be a JSON edit: %w", err)
+	}
+
+	whiteboard, revision, err := t.convManager.EditWhiteboard(t.conversationID, edit, t.agentID)
+	if err != nil {
+		return "", err
+	}
+	if err := t.kafkaClient.PublishMessage(ctx, conversation.NewWhiteboardEvent(t.conversationID, revision)); err != nil {
+		log.Printf("Failed to publish whiteboard edit: %v", err)
+	}
+	return fmt.Sprintf("done, the whiteboard is now at version %d", whiteboard.Version), nil
+}
@@ -0,0 +1,98 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"philoking/internal/conversation"
+)
+
+// worldTools returns the tools narrative agents use to keep a story's world
+// state, bound to one conversation
+func (l *LLMAgent) worldTools(conversationID string) []Tool {
+	if !l.worldAccess || l.convManager == nil {
+		return nil
+	}
+	return []Tool{
+		&worldStateTool{convManager: l.convManager, conversationID: conversationID},
+		&worldUpdateTool{convManager: l.convManager, conversationID: conversationID, agentID: l.id},
+	}
+}
+
+// worldStateTool reads the world state of a conversation
+type worldStateTool struct {
+	convManager    *conversation.Manager
+	conversationID string
+}
+
+func (t *worldStateTool) Name() string {
+	return "world_state"
+}
+
+func (t *worldStateTool) Description() string {
+	return "returns the story's world as JSON: locations, characters and who holds which items. " +
+		"Read it before describing places, people or things so the story stays consistent; the input is ignored"
+}
+
+func (t *worldStateTool) Call(ctx context.Context, input string) (string, error) {
+	world := t.convManager.GetWorld(t.conversationID)
+	if world == nil {
+		return "the world is empty; create it with update_world", nil
+	}
+	return world.Describe(), nil
+}
+
+// worldUpdateTool changes the world state of a conversation
+type worldUpdateTool struct {
+	convManager    *conversation.Manager
+	conversationID string
+	agentID        string
+}
+
+func (t *worldUpdateTool) Name() string {
+	return "update_world"
+}
+
+func (t *worldUpdateTool) Description() string {
+	return "records changes to the story's world; the input is a JSON list of operations, all applied or none: " +
+		`{"op":"set_location","id":"tavern","name":"The Tavern","description":"...","exits":["square"]}, ` +
+		`{"op":"set_character","id":"mira","name":"Mira","location":"tavern","status":"tired"}, ` +
+		`{"op":"move","character":"mira","to":"square"}, {"op":"add_item","item":"lantern","to":"mira"}, ` +
+		`{"op":"move_item","item":"lantern","from":"mira","to":"tavern"}, {"op":"remove_item","item":"lantern","from":"tavern"}, ` +
+		`{"op":"remove_character","id":"mira"} and {"op":"remove_location","id":"tavern"}`
+}
+
+func (t *worldUpdateTool) Call(ctx context.Context, input string) (string, error) {
+	update, err := parseWorldUpdate(input)
+	if err != nil {
+		return "", err
+	}
+	world, err := t.convManager.UpdateWorld(t.conversationID, update, t.agentID)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("done, the world is now at version %d", world.Version), nil
+}
+
+// parseWorldUpdate accepts a list of operations, a single operation or a {"ops": [...]} object
+func parseWorldUpdate(input string) (conversation.WorldUpdate, error) {
+	input = strings.TrimSpace(input)
+
+	var update conversation.WorldUpdate
+	if strings.HasPrefix(input, "[") {
+		err := json.Unmarshal([]byte(input), &update.Ops)
+		return update, err
+	}
+	if err := json.Unmarshal([]byte(input), &update); err == nil && len(update.Ops) > 0 {
+		return update, nil
+	}
+
+	var op conversation.WorldOp
+	if err := json.Unmarshal([]byte(input), &op); err != nil {
+		return update, fmt.Errorf("input must be JSON operations: %w", err)
+	}
+	update.Ops = []conversation.WorldOp{op}
+	return update, nil
+}
@@ -0,0 +1,471 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+
+	"philoking/internal/agent"
+	"philoking/internal/archive"
+	"philoking/internal/audit"
+	"philoking/internal/config"
+	"philoking/internal/conversation"
+	"philoking/internal/daily"
+	"philoking/internal/deps"
+	"philoking/internal/digest"
+	"philoking/internal/embedding"
+	"philoking/internal/enrich"
+	"philoking/internal/evaluation"
+	"philoking/internal/fetch"
+	"philoking/internal/kafka"
+	"philoking/internal/llmhttp"
+	"philoking/internal/moderation"
+	"philoking/internal/ollama"
+	"philoking/internal/outbox"
+	"philoking/internal/quota"
+	"philoking/internal/redact"
+	"philoking/internal/storage"
+	"philoking/internal/tap"
+	"philoking/internal/tasks"
+	"philoking/internal/web"
+)
+
+// DefaultConversationID is the conversation every agent and user joins
+const DefaultConversationID = "main-conversation"
+
+// ModeNatural lets agents join the conversation freely based on their response chance
+const ModeNatural = "natural"
+
+// Modes lists the supported conversation modes
+var Modes = []string{ModeNatural}
+
+// App wires together the Kafka client, conversation state, agents and web server
+type App struct {
+	Config         *config.Config
+	Mode           string
+	ConversationID string
+
+	Kafka         *kafka.Client
+	Conversations *conversation.Manager
+	Flow          *conversation.FlowManager
+	Agents        *agent.Manager
+	Web           *web.Server
+
+	enabledAgents []agent.Agent
+	claims        *kafka.Claimer        // Nil unless agents claim messages before replying
+	outbox        *outbox.Outbox        // Nil unless storage is configured
+	archiver      *archive.Archiver     // Nil unless archiving is configured
+	ollama        *ollama.Client        // Nil unless agents use Ollama
+	llmClient     *http.Client          // Shared by the LLM agents
+	tap           *tap.Tap              // Nil unless conversations are tapped to a file
+	digester      *digest.Digester      // Nil unless the email digest is enabled
+	daily         *daily.Scheduler      // Nil unless daily conversations are enabled
+	tasks         *tasks.Queue          // Nil unless the task queue is enabled
+	evaluations   *evaluation.Evaluator // Nil unless evaluation reports are enabled
+	indexer       *embedding.Indexer    // Nil unless embeddings are enabled
+	routed        bool                  // Set for tenants, whose web server is served by a TenantRouter
+}
+
+// New creates the application components from configuration
+func New(cfg *config.Config, mode string) (*App, error) {
+	if !isMode(mode) {
+		return nil, fmt.Errorf("unsupported mode %q (supported: %v)", mode, Modes)
+	}
+
+	// Initialize Kafka producer and consumer
+	kafkaClient, err := kafka.NewClient(cfg.Kafka)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize Kafka client: %w", err)
+	}
+	keys, err := kafka.NewKeyring(cfg.Agents.Agents)
+	if err != nil {
+		kafkaClient.Close()
+		return nil, fmt.Errorf("failed to load signing keys: %w", err)
+	}
+	kafkaClient.UseKeyring(keys)
+
+	// Initialize conversation manager
+	convManager := conversation.NewManager()
+	flowManager := conversation.NewFlowManager(cfg.Conversation, kafkaClient, convManager)
+
+	// Record privacy-relevant actions such as user data deletion
+	auditLog, err := audit.New(cfg.Audit.File)
+	if err != nil {
+		kafkaClient.Close()
+		return nil, fmt.Errorf("failed to initialize audit log: %w", err)
+	}
+	flowManager.UseAuditLog(auditLog)
+
+	// Persist state changes together with the messages announcing them
+	var messageOutbox *outbox.Outbox
+	var store *storage.Store
+	if cfg.Storage.Dir != "" {
+		if store, err = storage.Open(cfg.Storage.Dir); err != nil {
+			kafkaClient.Close()
+			return nil, fmt.Errorf("failed to open storage: %w", err)
+		}
+		messageOutbox = outbox.New(store, kafkaClient)
+		flowManager.UseOutbox(messageOutbox)
+	}
+
+	// Log every message to a file for offline analysis
+	var conversationTap *tap.Tap
+	if cfg.Tap.File != "" {
+		if conversationTap, err = tap.New(cfg.Tap); err != nil {
+			kafkaClient.Close()
+			return nil, fmt.Errorf("failed to initialize conversation tap: %w", err)
+		}
+		flowManager.UseEraser("tapped messages", conversationTap.ForgetUser)
+	}
+
+	// Move completed and idle conversations to cold storage
+	var archiver *archive.Archiver
+	if cfg.Archive.Provider != "" {
+		if archiver, err = archive.NewArchiver(cfg.Archive, convManager); err != nil {
+			kafkaClient.Close()
+			return nil, fmt.Errorf("failed to initialize archiving: %w", err)
+		}
+	}
+
+	// Initialize agent factory and create agents from configuration
+	quotas := quota.NewLimiter(cfg.Agents.Quotas)
+	var claims *kafka.Claimer
+	if cfg.Agents.ClaimReplies {
+		claims = kafkaClient.NewClaimer(cfg.Web.InstanceID, cfg.Agents.ClaimTimeout)
+	}
+	redactor, err := redact.New(cfg.Agents.Redaction)
+	if err != nil {
+		kafkaClient.Close()
+		return nil, fmt.Errorf("failed to initialize redaction: %w", err)
+	}
+	llmClient, err := llmhttp.NewClient(cfg.Agents.HTTP)
+	if err != nil {
+		kafkaClient.Close()
+		return nil, fmt.Errorf("failed to initialize LLM HTTP client: %w", err)
+	}
+	agentFactory := agent.NewFactory(kafkaClient, convManager, quotas, claims, redactor, llmClient)
+	if cfg.Agents.Fetch.Enabled {
+		agentFactory.UseFetcher(fetch.New(cfg.Agents.Fetch, llmClient))
+	}
+	enricher, err := enrich.New(cfg.Agents.Context, llmClient)
+	if err != nil {
+		kafkaClient.Close()
+		return nil, fmt.Errorf("failed to initialize context providers: %w", err)
+	}
+	if enricher != nil {
+		agentFactory.UseEnricher(enricher)
+	}
+
+	// Embed messages to cluster them into the topics that emerge and to let agents search them
+	var indexer *embedding.Indexer
+	if cfg.Embeddings.Enabled {
+		embeddings, err := embedding.NewStore(store)
+		if err != nil {
+			kafkaClient.Close()
+			return nil, err
+		}
+		embeddingClient := embedding.NewClient(cfg.Embeddings, cfg.Agents, llmClient)
+		indexer = embedding.New(cfg.Embeddings, embeddingClient, convManager, embeddings)
+		flowManager.UseEraser("embeddings", embeddings.ForgetUser)
+		agentFactory.UseSearcher(embedding.NewSearcher(embeddingClient, embeddings), cfg.Embeddings.CrossConversation)
+	}
+
+	// Run work that takes longer than a chat turn in the background
+	var taskQueue *tasks.Queue
+	if cfg.Tasks.Workers > 0 {
+		if taskQueue, err = tasks.New(cfg.Tasks, kafkaClient, messageOutbox); err != nil {
+			kafkaClient.Close()
+			return nil, err
+		}
+		agentFactory.UseTasks(taskQueue)
+	}
+	allAgents := agentFactory.CreateAgents(cfg.GetEnabledAgents(), cfg.Agents)
+
+	// Register agents in conversation flow
+	agentFactory.RegisterAgentsInConversationFlow(flowManager, cfg.Agents.Agents)
+
+	// Initialize agent manager and register all agents
+	agentManager := agent.NewManager(kafkaClient, cfg.Agents)
+	agentManager.UseFactory(agentFactory)
+	if store != nil {
+		agentManager.UseStateStore(agent.NewStateStore(store), cfg.Storage.AgentStateInterval)
+	}
+	if cfg.Agents.Supervisor.Enabled {
+		agentManager.UseSupervisor(cfg.Agents.Supervisor)
+	}
+	for _, a := range allAgents {
+		if err := agentManager.RegisterAgent(a); err != nil {
+			kafkaClient.Close()
+			return nil, fmt.Errorf("failed to register agent %s: %w", a.ID(), err)
+		}
+	}
+	flowManager.UseEraser("LLM calls", agentManager.ForgetUser)
+	if taskQueue != nil {
+		for _, kind := range agent.TaskKinds {
+			taskQueue.Register(kind, agentManager.RunTask)
+		}
+	}
+
+	// Warm up and manage local models
+	ollamaClient := newOllamaClient(cfg.Agents, llmClient)
+	webServer := web.NewServer(cfg.Web, kafkaClient, convManager, flowManager, agentManager, archiver)
+	webServer.UseConfig(cfg)
+	webServer.UseAudit(auditLog)
+	if ollamaClient != nil {
+		webServer.UseOllama(ollamaClient)
+	}
+	if taskQueue != nil {
+		webServer.UseTasks(taskQueue)
+	}
+	if filter := moderation.New(cfg.Moderation); filter != nil {
+		webServer.UseModeration(filter)
+	}
+
+	// Mail subscribers a daily digest of their conversations
+	var digester *digest.Digester
+	if cfg.Digest.Enabled {
+		if digester, err = digest.New(cfg.Digest, convManager, digest.NewSMTPMailer(cfg.Digest.SMTP), store); err != nil {
+			kafkaClient.Close()
+			return nil, err
+		}
+		for _, a := range allAgents {
+			if summarizer, ok := a.(*agent.SummarizerAgent); ok {
+				digester.UseSummarizer(summarizer)
+				break
+			}
+		}
+		webServer.UseDigest(digester)
+	}
+
+	// Start a fresh conversation every day
+	var scheduler *daily.Scheduler
+	if cfg.Daily.Enabled {
+		var dailyArchiver daily.Archiver
+		if archiver != nil && cfg.Daily.ArchivePrevious {
+			dailyArchiver = archiver
+		}
+		scheduler = daily.New(cfg.Daily, flowManager, convManager, dailyArchiver)
+		webServer.UseDaily(scheduler)
+	}
+
+	// Have an LLM judge score the agents over the stored conversations
+	var evaluator *evaluation.Evaluator
+	if cfg.Evaluation.Enabled {
+		judgeID, judge := evaluationJudge(allAgents, cfg.Evaluation.Judge)
+		if judge == nil {
+			kafkaClient.Close()
+			return nil, fmt.Errorf("evaluation needs an LLM agent to judge (evaluation.judge)")
+		}
+		if evaluator, err = evaluation.New(cfg.Evaluation, convManager, judgeID, judge, store); err != nil {
+			kafkaClient.Close()
+			return nil, err
+		}
+		webServer.UseEvaluations(evaluator)
+	}
+
+	return &App{
+		Config:         cfg,
+		Mode:           mode,
+		ConversationID: DefaultConversationID,
+		Kafka:          kafkaClient,
+		Conversations:  convManager,
+		Flow:           flowManager,
+		Agents:         agentManager,
+		Web:            webServer,
+		enabledAgents:  allAgents,
+		claims:         claims,
+		outbox:         messageOutbox,
+		archiver:       archiver,
+		ollama:         ollamaClient,
+		llmClient:      llmClient,
+		tap:            conversationTap,
+		digester:       digester,
+		daily:          scheduler,
+		tasks:          taskQueue,
+		evaluations:    evaluator,
+		indexer:        indexer,
+	}, nil
+}
+
+// Start starts the conversation flow, the agents and the web server
+func (a *App) Start(ctx context.Context) error {
+	// Kafka and the LLM provider may still be starting, e.g. with docker compose
+	if err := deps.Wait(ctx, a.dependencies(), a.Config.Startup); err != nil {
+		if a.Config.Startup.WaitForDeps {
+			return fmt.Errorf("dependencies unavailable: %w", err)
+		}
+		log.Printf("⚠️  Starting without all dependencies: %v", err)
+	}
+
+	// Relay staged messages, including those left over from a previous run
+	if a.outbox != nil {
+		go a.outbox.Run(ctx)
+	}
+
+	if a.archiver != nil {
+		go a.archiver.Run(ctx)
+	}
+
+	if a.tap != nil {
+		a.startTap(ctx)
+	}
+
+	if a.digester != nil {
+		go a.digester.Run(ctx)
+	}
+
+	if a.daily != nil {
+		go a.daily.Run(ctx)
+	}
+
+	if a.tasks != nil {
+		go a.tasks.Run(ctx)
+	}
+
+	if a.evaluations != nil {
+		go a.evaluations.Run(ctx)
+	}
+
+	if a.indexer != nil {
+		go a.indexer.Run(ctx)
+	}
+
+	// Start conversation flow
+	if err := a.Flow.StartConversationFlow(ctx, a.ConversationID); err != nil {
+		return fmt.Errorf("failed to start conversation flow: %w", err)
+	}
+
+	// Load models before agents start answering
+	if a.ollama != nil && a.Config.Agents.Warmup.Enabled {
+		a.ollama.Warmup(ctx, a.warmupModels(), a.Config.Agents.Warmup.Pull, a.Config.Agents.Warmup.Timeout)
+	}
+
+	// Follow reply claims before agents start answering
+	if a.claims != nil {
+		if err := a.claims.Start(ctx); err != nil {
+			return fmt.Errorf("failed to start reply claims: %w", err)
+		}
+	}
+
+	// Start agents
+	if err := a.Agents.Start(ctx); err != nil {
+		return fmt.Errorf("failed to start agents: %w", err)
+	}
+
+	// Start web server
+	if !a.routed {
+		go func() {
+			if err := a.Web.Start(); err != nil {
+				log.Fatalf("Failed to start web server: %v", err)
+			}
+		}()
+	}
+
+	a.logStartup()
+	return nil
+}
+
+// Close stops the agents and releases the Kafka client
+func (a *App) Close() error {
+	a.Agents.Stop()
+	if a.tap != nil {
+		a.tap.Close()
+	}
+	return a.Kafka.Close()
+}
+
+// startTap feeds the conversation, and the deletions analysts must honour, to the tap
+func (a *App) startTap(ctx context.Context) {
+	go func() {
+		if err := a.Kafka.SubscribeToMessages(ctx, "philoking-tap", a.tap.Write); err != nil {
+			log.Printf("Error in conversation tap: %v", err)
+		}
+	}()
+	go func() {
+		if err := a.Kafka.SubscribeToControl(ctx, "philoking-tap-control", a.tap.Write); err != nil {
+			log.Printf("Error in conversation tap: %v", err)
+		}
+	}()
+}
+
+// logStartup displays startup information
+func (a *App) logStartup() {
+	log.Println("🎉 Multi-Agent Conversation System Started!")
+	log.Println("📝 Conversation ID:", a.ConversationID)
+	log.Println("🧭 Mode:", a.Mode)
+	log.Println("🤖 Active Agents:")
+
+	for _, agentConfig := range a.Config.GetEnabledAgents() {
+		log.Printf("   - %s (%s) - %s", agentConfig.Name, agentConfig.Type, agentConfig.Description)
+	}
+
+	log.Printf("📊 Total Agents: %d", len(a.enabledAgents))
+	log.Printf("🌐 Web Interface: http://%s:%s", a.Config.Web.Host, a.Config.Web.Port)
+	log.Println("💬 Start chatting and watch the multi-agent conversation!")
+	log.Println("⚙️  Configure agents in config.yaml")
+}
+
+// dependencies lists the services to probe before starting
+func (a *App) dependencies() []deps.Probe {
+	probes := []deps.Probe{{Name: "Kafka", Check: a.Kafka.Ping}}
+	if a.ollama != nil {
+		probes = append(probes, deps.Probe{Name: "Ollama", Check: a.ollama.Ping})
+	}
+	if a.Config.Agents.Provider == "openai" {
+		probes = append(probes, deps.OpenAI(a.llmClient, a.Config.Agents))
+	}
+	return probes
+}
+
+// evaluationJudge finds the agent that scores the evaluation reports: the
+// one configured, or else the first judge agent
+func evaluationJudge(agents []agent.Agent, judgeID string) (string, evaluation.Judge) {
+	for _, a := range agents {
+		judge, ok := a.(evaluation.Judge)
+		if !ok {
+			continue
+		}
+		if a.ID() == judgeID {
+			return a.ID(), judge
+		}
+		if _, isJudge := a.(*agent.JudgeAgent); judgeID == "" && isJudge {
+			return a.ID(), judge
+		}
+	}
+	return "", nil
+}
+
+// newOllamaClient returns a client for managing the models of the Ollama
+// server, or nil when agents don't use Ollama
+func newOllamaClient(cfg config.AgentsConfig, llmClient *http.Client) *ollama.Client {
+	switch cfg.Provider {
+	case "ollama", agent.OllamaGenerateProvider, "":
+	default:
+		return nil
+	}
+	return ollama.NewClient(cfg.OllamaURL, llmhttp.WithoutReadTimeout(llmClient), cfg.HTTP)
+}
+
+// warmupModels lists the configured models to load, without duplicates
+func (a *App) warmupModels() []string {
+	seen := make(map[string]bool)
+	var models []string
+	for _, model := range append([]string{a.Config.Agents.Model}, a.Config.Agents.Warmup.Models...) {
+		if model != "" && !seen[model] {
+			seen[model] = true
+			models = append(models, model)
+		}
+	}
+	return models
+}
+
+// isMode reports whether mode is a supported conversation mode
+func isMode(mode string) bool {
+	for _, m := range Modes {
+		if m == mode {
+			return true
+		}
+	}
+	return false
+}
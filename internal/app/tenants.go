@@ -0,0 +1,70 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"philoking/internal/config"
+	"philoking/internal/web"
+)
+
+// Tenants runs an App per configured tenant behind one web server. Tenants
+// share nothing but the process: each has its own topics, consumer groups,
+// conversations, agents and storage.
+type Tenants struct {
+	Apps   map[string]*App // By tenant ID
+	Router *web.TenantRouter
+
+	tenants []config.TenantConfig
+}
+
+// NewTenants creates the application of every tenant
+func NewTenants(cfg *config.Config, mode string) (*Tenants, error) {
+	t := &Tenants{
+		Apps:    make(map[string]*App, len(cfg.Tenants)),
+		Router:  web.NewTenantRouter(cfg.Web),
+		tenants: cfg.Tenants,
+	}
+	for _, tenant := range cfg.Tenants {
+		application, err := New(cfg.ForTenant(tenant), mode)
+		if err != nil {
+			t.Close()
+			return nil, fmt.Errorf("tenant %s: %w", tenant.ID, err)
+		}
+		application.routed = true
+		t.Apps[tenant.ID] = application
+	}
+	return t, nil
+}
+
+// Start starts every tenant, then the shared web server
+func (t *Tenants) Start(ctx context.Context) error {
+	for _, tenant := range t.tenants {
+		application := t.Apps[tenant.ID]
+		if err := application.Start(ctx); err != nil {
+			return fmt.Errorf("tenant %s: %w", tenant.ID, err)
+		}
+		t.Router.Add(tenant.ID, tenant.APIKeys, application.Web)
+		log.Printf("🏢 Tenant %s started", tenant.ID)
+	}
+
+	go func() {
+		if err := t.Router.Start(); err != nil {
+			log.Fatalf("Failed to start web server: %v", err)
+		}
+	}()
+	return nil
+}
+
+// Close stops every tenant
+func (t *Tenants) Close() error {
+	var errs []error
+	for id, application := range t.Apps {
+		if err := application.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("tenant %s: %w", id, err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,166 @@
+package archive
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"philoking/internal/config"
+	"philoking/internal/conversation"
+	"philoking/internal/types"
+)
+
+// Archiver moves completed and idle conversations from memory to cold
+// storage as gzip-compressed JSONL and loads them back on demand
+type Archiver struct {
+	config  config.ArchiveConfig
+	store   Store
+	manager *conversation.Manager
+}
+
+// archiveHeader is the first line of an archive; every following line is a message
+type archiveHeader struct {
+	Conversation *conversation.Snapshot `json:"conversation"`
+	ArchivedAt   time.Time              `json:"archived_at"`
+}
+
+// NewArchiver creates an archiver for the configured cold storage
+func NewArchiver(cfg config.ArchiveConfig, manager *conversation.Manager) (*Archiver, error) {
+	store, err := NewStore(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Archiver{
+		config:  cfg,
+		store:   store,
+		manager: manager,
+	}, nil
+}
+
+// Run archives completed and idle conversations periodically until ctx is done
+func (a *Archiver) Run(ctx context.Context) {
+	ticker := time.NewTicker(a.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			a.archiveInactive(ctx)
+		}
+	}
+}
+
+// archiveInactive archives closed side conversations and conversations idle for too long
+func (a *Archiver) archiveInactive(ctx context.Context) {
+	idleSince := time.Now().Add(-a.config.IdleAfter)
+
+	for _, id := range a.manager.ListConversations() {
+		snapshot, ok := a.manager.Snapshot(id)
+		if !ok {
+			continue
+		}
+
+		side, isSide := a.manager.GetSideConversation(id)
+		completed := isSide && side.Status == conversation.SideStatusClosed
+		if !completed && snapshot.UpdatedAt.After(idleSince) {
+			continue
+		}
+
+		if err := a.archive(ctx, snapshot); err != nil {
+			log.Printf("Failed to archive conversation %s: %v", id, err)
+		}
+	}
+}
+
+// Archive writes a conversation to cold storage and removes it from memory
+func (a *Archiver) Archive(ctx context.Context, conversationID string) error {
+	snapshot, ok := a.manager.Snapshot(conversationID)
+	if !ok {
+		return fmt.Errorf("conversation %s is not loaded", conversationID)
+	}
+	return a.archive(ctx, snapshot)
+}
+
+// archive uploads a snapshot and drops the conversation from memory
+func (a *Archiver) archive(ctx context.Context, snapshot *conversation.Snapshot) error {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	encoder := json.NewEncoder(gz)
+
+	if err := encoder.Encode(archiveHeader{Conversation: snapshot, ArchivedAt: time.Now()}); err != nil {
+		return err
+	}
+	for _, message := range snapshot.Messages {
+		if err := encoder.Encode(message); err != nil {
+			return err
+		}
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+
+	if err := a.store.Put(ctx, a.key(snapshot.ID), buf.Bytes()); err != nil {
+		return err
+	}
+
+	a.manager.RemoveConversation(snapshot.ID)
+	log.Printf("Archived conversation %s (%d messages, %d bytes)", snapshot.ID, len(snapshot.Messages), buf.Len())
+	return nil
+}
+
+// Rehydrate loads an archived conversation back into memory and returns it
+func (a *Archiver) Rehydrate(ctx context.Context, conversationID string) (*conversation.Snapshot, error) {
+	data, err := a.store.Get(ctx, a.key(conversationID))
+	if err != nil {
+		return nil, err
+	}
+
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("corrupt archive: %w", err)
+	}
+	defer gz.Close()
+
+	scanner := bufio.NewScanner(gz)
+	scanner.Buffer(make([]byte, 64*1024), 10*1024*1024)
+
+	var header archiveHeader
+	if !scanner.Scan() {
+		return nil, fmt.Errorf("empty archive")
+	}
+	if err := json.Unmarshal(scanner.Bytes(), &header); err != nil || header.Conversation == nil {
+		return nil, fmt.Errorf("corrupt archive header: %v", err)
+	}
+
+	snapshot := header.Conversation
+	for scanner.Scan() {
+		var message types.ChatMessage
+		if err := json.Unmarshal(scanner.Bytes(), &message); err != nil {
+			return nil, fmt.Errorf("corrupt archived message: %w", err)
+		}
+		snapshot.Messages = append(snapshot.Messages, &message)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := a.manager.RestoreConversation(snapshot); err != nil {
+		return nil, err
+	}
+
+	log.Printf("Rehydrated conversation %s (%d messages)", conversationID, len(snapshot.Messages))
+	return snapshot, nil
+}
+
+// key returns the object key of a conversation's archive
+func (a *Archiver) key(conversationID string) string {
+	return a.config.Prefix + conversationID + ".jsonl.gz"
+}
@@ -0,0 +1,61 @@
+package archive
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"philoking/internal/config"
+)
+
+// ErrNotFound is returned when an archive object does not exist
+var ErrNotFound = errors.New("archive not found")
+
+// Store is cold storage for archived conversations
+type Store interface {
+	Put(ctx context.Context, key string, data []byte) error
+	Get(ctx context.Context, key string) ([]byte, error)
+}
+
+// NewStore creates the store for the configured provider
+func NewStore(cfg config.ArchiveConfig) (Store, error) {
+	switch cfg.Provider {
+	case "file":
+		if cfg.Dir == "" {
+			return nil, fmt.Errorf("the file archive needs a dir")
+		}
+		return &fileStore{dir: cfg.Dir}, nil
+	case "s3", "gcs":
+		if cfg.Bucket == "" {
+			return nil, fmt.Errorf("the %s archive needs a bucket", cfg.Provider)
+		}
+		return newS3Store(cfg), nil
+	default:
+		return nil, fmt.Errorf("unsupported archive provider %q", cfg.Provider)
+	}
+}
+
+// fileStore keeps archives in a local directory, for development
+type fileStore struct {
+	dir string
+}
+
+// Put writes an archive file
+func (s *fileStore) Put(ctx context.Context, key string, data []byte) error {
+	path := filepath.Join(s.dir, filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// Get reads an archive file
+func (s *fileStore) Get(ctx context.Context, key string) ([]byte, error) {
+	data, err := os.ReadFile(filepath.Join(s.dir, filepath.FromSlash(key)))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return data, err
+}
@@ -0,0 +1,107 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// ErrNotStored is returned when reading records that went to the process log
+var ErrNotStored = errors.New("audit records are not stored (audit.file is not set)")
+
+// Record is one entry in the audit trail
+type Record struct {
+	Action    string      `json:"action"`  // e.g. "user_data_deleted"
+	Subject   string      `json:"subject"` // Who or what the action was applied to
+	Actor     string      `json:"actor,omitempty"`
+	Details   interface{} `json:"details,omitempty"`
+	Timestamp time.Time   `json:"timestamp"`
+}
+
+// Log appends audit records as JSON lines to a file; without a file records
+// go to the process log
+type Log struct {
+	path string
+	mu   sync.Mutex
+}
+
+// New creates an audit log writing to path, or to the process log when path is empty
+func New(path string) (*Log, error) {
+	if path != "" {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create audit directory: %w", err)
+		}
+	}
+	return &Log{path: path}, nil
+}
+
+// Write appends a record to the audit trail
+func (l *Log) Write(record Record) error {
+	if record.Timestamp.IsZero() {
+		record.Timestamp = time.Now()
+	}
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit record: %w", err)
+	}
+
+	if l.path == "" {
+		log.Printf("AUDIT %s", data)
+		return nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	file, err := os.OpenFile(l.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write audit record: %w", err)
+	}
+	return file.Sync()
+}
+
+// Records reads the audit trail, oldest first
+func (l *Log) Records() ([]Record, error) {
+	if l.path == "" {
+		return nil, ErrNotStored
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	file, err := os.Open(l.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	defer file.Close()
+
+	var records []Record
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		var record Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return nil, fmt.Errorf("audit log line %d: %w", line, err)
+		}
+		records = append(records, record)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read audit log: %w", err)
+	}
+	return records, nil
+}
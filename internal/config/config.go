@@ -1,40 +1,646 @@
 package config
 
 import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
 	"fmt"
+	"net"
+	"net/url"
 	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
 
+	"philoking/internal/jsonschema"
+	"philoking/internal/presets"
+	"philoking/internal/rules"
+
+	"github.com/google/uuid"
 	"github.com/spf13/viper"
 )
 
+// ProfileEnvVar selects a configuration profile when no --profile flag is given
+const ProfileEnvVar = "PHILOKING_PROFILE"
+
+// envAliases are legacy environment variable names kept for existing deployments
+var envAliases = map[string][]string{
+	"agents.provider":    {"PROVIDER"},
+	"agents.model":       {"MODEL"},
+	"agents.ollama_url":  {"OLLAMA_URL"},
+	"agents.llm_url":     {"LLM_URL"},
+	"agents.llm_api_key": {"LLM_API_KEY"},
+}
+
 type Config struct {
-	Kafka  KafkaConfig  `mapstructure:"kafka"`
-	Web    WebConfig    `mapstructure:"web"`
-	Agents AgentsConfig `mapstructure:"agents"`
+	Kafka        KafkaConfig        `mapstructure:"kafka"`
+	Web          WebConfig          `mapstructure:"web"`
+	Agents       AgentsConfig       `mapstructure:"agents"`
+	Conversation ConversationConfig `mapstructure:"conversation"`
+	Storage      StorageConfig      `mapstructure:"storage"`
+	Archive      ArchiveConfig      `mapstructure:"archive"`
+	Audit        AuditConfig        `mapstructure:"audit"`
+	Startup      StartupConfig      `mapstructure:"startup"`
+	Tap          TapConfig          `mapstructure:"tap"`
+	Digest       DigestConfig       `mapstructure:"digest"`
+	Daily        DailyConfig        `mapstructure:"daily"`
+	Tasks        TasksConfig        `mapstructure:"tasks"`
+	Evaluation   EvaluationConfig   `mapstructure:"evaluation"`
+	Moderation   ModerationConfig   `mapstructure:"moderation"`
+	Embeddings   EmbeddingsConfig   `mapstructure:"embeddings"`
+	// Tenants are independent groups served by the same binary; empty runs a single one
+	Tenants []TenantConfig `mapstructure:"tenants"`
+}
+
+// TenantConfig describes a tenant: it gets its own topics, consumer groups,
+// conversations, agents and state, and its API keys select it in the web API
+type TenantConfig struct {
+	ID   string `mapstructure:"id"`
+	Name string `mapstructure:"name"`
+	// TopicPrefix is prepended to every topic and consumer group; defaults to "<id>."
+	TopicPrefix string   `mapstructure:"topic_prefix"`
+	APIKeys     []string `mapstructure:"api_keys" secret:"true"`
+	// Agents replaces agents.agents for this tenant; empty uses those
+	Agents []AgentConfig `mapstructure:"agents"`
+}
+
+// DigestConfig configures the daily email digest of active conversations
+type DigestConfig struct {
+	Enabled bool       `mapstructure:"enabled"`
+	SendAt  string     `mapstructure:"send_at"` // Local time of day, e.g. "08:00"
+	Subject string     `mapstructure:"subject"`
+	SMTP    SMTPConfig `mapstructure:"smtp"`
+}
+
+// DailyConfig starts a fresh conversation every day, e.g. for a question of the day
+type DailyConfig struct {
+	Enabled  bool   `mapstructure:"enabled"`
+	StartAt  string `mapstructure:"start_at"`  // Local time of day, e.g. "09:00"
+	IDPrefix string `mapstructure:"id_prefix"` // Conversations are named "<prefix>-2006-01-02"
+	Title    string `mapstructure:"title"`     // The date is appended
+	// Topic is announced as the day's topic, e.g. "today's philosophical question"
+	Topic   string   `mapstructure:"topic"`
+	Goal    string   `mapstructure:"goal"`
+	Members []string `mapstructure:"members"` // Agents taking part; empty means all
+	// ArchivePrevious moves yesterday's conversation to cold storage; needs archive.provider
+	ArchivePrevious bool `mapstructure:"archive_previous"`
+	// Webhooks are POSTed the new conversation, e.g. to bridge it to a chat app
+	Webhooks       []string      `mapstructure:"webhooks" secret:"true"`
+	WebhookTimeout time.Duration `mapstructure:"webhook_timeout"`
+}
+
+// TasksConfig configures the queue of agent work that takes longer than a chat turn
+type TasksConfig struct {
+	Workers     int           `mapstructure:"workers"`      // Tasks processed at the same time; 0 disables the queue
+	MaxAttempts int           `mapstructure:"max_attempts"` // Tries before a task fails
+	RetryDelay  time.Duration `mapstructure:"retry_delay"`  // Before the first retry; doubles with every further one
+	Timeout     time.Duration `mapstructure:"timeout"`      // Of a single attempt
+}
+
+// EvaluationConfig configures the reports in which an LLM judge scores the agents over the stored conversations
+type EvaluationConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// Judge is the ID of the LLM agent that scores; empty picks the first judge agent
+	Judge       string        `mapstructure:"judge"`
+	Interval    time.Duration `mapstructure:"interval"`     // Between scheduled evaluations; 0 only evaluates on request
+	MinMessages int           `mapstructure:"min_messages"` // Conversations with fewer are skipped
+	MaxMessages int           `mapstructure:"max_messages"` // The latest messages of a conversation the judge reads
+	Keep        int           `mapstructure:"keep"`         // Reports kept
+}
+
+// EmbeddingsConfig sets up the embedding of conversation messages, which
+// clusters them into the topics that emerge from the discussion
+type EmbeddingsConfig struct {
+	Enabled  bool   `mapstructure:"enabled"`
+	Provider string `mapstructure:"provider"` // "ollama" or "openai"
+	// URL of the embeddings endpoint; empty derives it from agents.ollama_url or agents.llm_url
+	URL         string        `mapstructure:"url"`
+	Model       string        `mapstructure:"model"`
+	Interval    time.Duration `mapstructure:"interval"`     // How often new messages are embedded and clustered
+	BatchSize   int           `mapstructure:"batch_size"`   // Messages embedded per request
+	Clusters    int           `mapstructure:"clusters"`     // Most clusters per conversation
+	MinMessages int           `mapstructure:"min_messages"` // Conversations with fewer aren't clustered
+	// CrossConversation lets agents search and cite the other conversations they took part in
+	CrossConversation bool `mapstructure:"cross_conversation"`
+}
+
+// ModerationConfig filters the messages users send before they are posted
+type ModerationConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// BlockedWords reject a message; FlaggedWords let it through but flag it for admins
+	BlockedWords []string `mapstructure:"blocked_words"`
+	FlaggedWords []string `mapstructure:"flagged_words"`
+	// RejectionMessage is what the sender is told when a message is rejected
+	RejectionMessage string              `mapstructure:"rejection_message"`
+	API              ModerationAPIConfig `mapstructure:"api"`
+}
+
+// ModerationAPIConfig configures an optional moderation API in the format of
+// OpenAI's /v1/moderations, which scores each message per category from 0 to 1
+type ModerationAPIConfig struct {
+	URL    string `mapstructure:"url"`                   // Empty checks the word lists only
+	APIKey string `mapstructure:"api_key" secret:"true"` // Set via MODERATION_API_KEY environment variable
+	Model  string `mapstructure:"model"`
+	// Messages scoring at least BlockThreshold in a category are rejected,
+	// those scoring at least FlagThreshold are flagged
+	BlockThreshold float64       `mapstructure:"block_threshold"`
+	FlagThreshold  float64       `mapstructure:"flag_threshold"`
+	Timeout        time.Duration `mapstructure:"timeout"`
+}
+
+// SMTPConfig configures the mail server digests are sent through
+type SMTPConfig struct {
+	Host     string `mapstructure:"host"`
+	Port     int    `mapstructure:"port"` // 465 uses implicit TLS; other ports upgrade with STARTTLS when offered
+	Username string `mapstructure:"username"`
+	Password string `mapstructure:"password" secret:"true"` // Set via SMTP_PASSWORD environment variable
+	From     string `mapstructure:"from"`
+}
+
+// TapConfig configures the conversation tap, which appends every chat message
+// to a JSON lines file for offline analysis
+type TapConfig struct {
+	File        string        `mapstructure:"file"`         // Empty disables the tap
+	MaxSizeMB   int           `mapstructure:"max_size_mb"`  // Rotate once the file reaches this size; 0 disables size-based rotation
+	RotateEvery time.Duration `mapstructure:"rotate_every"` // Rotate files older than this; 0 disables time-based rotation
+	MaxBackups  int           `mapstructure:"max_backups"`  // Rotated files to keep; 0 keeps all
+}
+
+// StartupConfig controls how startup probes Kafka and the LLM provider
+type StartupConfig struct {
+	WaitForDeps    bool          `mapstructure:"wait_for_deps"` // Keep probing until every dependency is reachable instead of starting anyway
+	Attempts       int           `mapstructure:"attempts"`      // Probes per dependency before starting without it
+	InitialBackoff time.Duration `mapstructure:"initial_backoff"`
+	MaxBackoff     time.Duration `mapstructure:"max_backoff"`
+}
+
+// AuditConfig configures the audit trail of privacy-relevant actions
+type AuditConfig struct {
+	File string `mapstructure:"file"` // JSON lines file; empty writes audit records to the log
+}
+
+// ArchiveConfig configures cold storage for completed and idle conversations
+type ArchiveConfig struct {
+	Provider        string        `mapstructure:"provider"` // "" (disabled), "file", "s3" or "gcs"
+	Dir             string        `mapstructure:"dir"`      // For the file provider
+	Bucket          string        `mapstructure:"bucket"`
+	Prefix          string        `mapstructure:"prefix"`   // Prepended to object keys, e.g. "conversations/"
+	Endpoint        string        `mapstructure:"endpoint"` // For S3-compatible stores; defaults to AWS or GCS
+	Region          string        `mapstructure:"region"`
+	AccessKeyID     string        `mapstructure:"access_key_id" secret:"true"`
+	SecretAccessKey string        `mapstructure:"secret_access_key" secret:"true"`
+	IdleAfter       time.Duration `mapstructure:"idle_after"` // Archive conversations without messages for this long
+	Interval        time.Duration `mapstructure:"interval"`   // How often the archival job runs
+}
+
+// StorageConfig configures persistent state; without a directory all state is kept in memory
+type StorageConfig struct {
+	Dir                string        `mapstructure:"dir"`
+	AgentStateInterval time.Duration `mapstructure:"agent_state_interval"` // How often agent state is saved; 0 saves it on shutdown only
 }
 
 type KafkaConfig struct {
 	Brokers []string `mapstructure:"brokers"`
 	Topics  struct {
-		ChatMessages string `mapstructure:"chat_messages"`
+		ChatMessages  string `mapstructure:"chat_messages"`  // What users and the moderator say
+		ChatResponses string `mapstructure:"chat_responses"` // What agents say
+		Control       string `mapstructure:"control"`        // Housekeeping events such as deletions
+		Presence      string `mapstructure:"presence"`       // Users coming online and going offline
+		Claims        string `mapstructure:"claims"`         // Compacted topic arbitrating replies between agent replicas
+		DeadLetter    string `mapstructure:"dead_letter"`    // Records consumers gave up on; empty drops them with a log line
+		AgentMessages string `mapstructure:"agent_messages"` // Direct messages between agents, never shown in the conversation
+		Errors        string `mapstructure:"errors"`         // Agent failures for the admins' error feed; empty disables it
 	} `mapstructure:"topics"`
+	// GroupPrefix is prepended to the consumer group IDs, e.g. to keep tenants apart
+	GroupPrefix string `mapstructure:"group_prefix"`
+	// Serialization selects the wire format of chat messages
+	Serialization SerializationConfig `mapstructure:"serialization"`
+	Consumer      ConsumerConfig      `mapstructure:"consumer"`
+}
+
+// ConsumerConfig tunes how consumers back off when reading or handling fails
+type ConsumerConfig struct {
+	InitialBackoff  time.Duration `mapstructure:"initial_backoff"` // Delay after the first failure; doubles on every further one
+	MaxBackoff      time.Duration `mapstructure:"max_backoff"`
+	RecreateAfter   int           `mapstructure:"recreate_after"`   // Consecutive failures after which the reader is replaced
+	HandlerAttempts int           `mapstructure:"handler_attempts"` // Tries per record before it goes to the dead-letter topic
+}
+
+// SerializationConfig selects the Kafka wire format
+type SerializationConfig struct {
+	Format         string               `mapstructure:"format"` // "json" (default) or "avro"
+	SchemaRegistry SchemaRegistryConfig `mapstructure:"schema_registry"`
+	Encryption     EncryptionConfig     `mapstructure:"encryption"`
+}
+
+// EncryptionConfig encrypts chat message payloads with AES-GCM before they
+// reach the broker
+type EncryptionConfig struct {
+	Enabled bool   `mapstructure:"enabled"`
+	KeyID   string `mapstructure:"key_id"` // Key that encrypts new messages; the others still decrypt older ones
+	// Keys are base64 AES keys of 16, 24 or 32 bytes, by ID
+	Keys map[string]string `mapstructure:"keys" secret:"true"`
+	// KeysFile holds more keys, one "id=base64key" per line, to keep them out of config.yaml
+	KeysFile string `mapstructure:"keys_file"`
+	// AcceptPlaintext reads unencrypted messages too, e.g. history from before encryption was enabled
+	AcceptPlaintext bool `mapstructure:"accept_plaintext"`
+}
+
+// SchemaRegistryConfig points to a Confluent Schema Registry
+type SchemaRegistryConfig struct {
+	URL      string `mapstructure:"url"`
+	Username string `mapstructure:"username"`
+	Password string `mapstructure:"password" secret:"true"`
 }
 
 type WebConfig struct {
 	Port string `mapstructure:"port"`
 	Host string `mapstructure:"host"`
+	// InstanceID identifies this web replica; it defaults to the hostname
+	// (the pod name in Kubernetes) plus a random suffix
+	InstanceID string `mapstructure:"instance_id"`
+	// BackfillMessages is the number of recent messages sent to a client when it connects
+	BackfillMessages int `mapstructure:"backfill_messages"`
+	// CompressWebSocket negotiates permessage-deflate with browsers
+	CompressWebSocket bool `mapstructure:"compress_websocket"`
+	// GzipResponses compresses API responses of at least GzipMinBytes
+	GzipResponses bool `mapstructure:"gzip_responses"`
+	GzipMinBytes  int  `mapstructure:"gzip_min_bytes"`
+	// AdminToken unlocks the admin endpoints, such as those under /api/debug
+	// and the ones managing agents, conversations and models, when sent in the
+	// X-Admin-Token header; empty disables them. Set via WEB_ADMIN_TOKEN.
+	AdminToken string `mapstructure:"admin_token" secret:"true"`
+	// SessionSecret signs the session tokens that identify users, so their
+	// sessions survive restarts and work on every replica; empty uses a
+	// random key per process. Set via WEB_SESSION_SECRET.
+	SessionSecret string `mapstructure:"session_secret" secret:"true"`
+	// TrustedProxies lists the addresses or CIDR ranges of the proxies whose
+	// X-Forwarded-For header gives the client's address, which blocks apply
+	// to; empty trusts no proxy
+	TrustedProxies []string `mapstructure:"trusted_proxies"`
+}
+
+// ConversationConfig tunes the conversation flow
+type ConversationConfig struct {
+	// QuestionTimeout is how long agents wait for the human to answer a required question
+	QuestionTimeout time.Duration `mapstructure:"question_timeout"`
+	// HandoffTimeout is how long a task handed off between agents waits to be
+	// accepted before the user is asked to step in
+	HandoffTimeout time.Duration `mapstructure:"handoff_timeout"`
+	// NoticeTTL expires transient moderator notices such as command errors (0 keeps them)
+	NoticeTTL time.Duration `mapstructure:"notice_ttl"`
+	// Phases structure the main conversation into stages, e.g. brainstorm,
+	// critique and synthesis; empty leaves the floor open to every agent
+	Phases []PhaseConfig `mapstructure:"phases"`
+	// Debate sets the defaults of debates started with /debate or the API
+	Debate DebateConfig `mapstructure:"debate"`
+	// Tutoring sets up Socratic tutoring started with /tutor or the API
+	Tutoring TutoringConfig `mapstructure:"tutoring"`
+	// DepthGuard wraps up conversations that run too long
+	DepthGuard DepthGuardConfig `mapstructure:"depth_guard"`
+	// Revival has an agent ask a new question when a conversation falls silent
+	Revival RevivalConfig `mapstructure:"revival"`
+}
+
+// RevivalConfig keeps quiet conversations going: after IdleAfter without
+// messages, the host agent, or else a random one, asks a new question on the
+// conversation's topic
+type RevivalConfig struct {
+	IdleAfter     time.Duration `mapstructure:"idle_after"`    // 0 disables revival
+	Host          string        `mapstructure:"host"`          // Empty picks an agent at random each time
+	Conversations []string      `mapstructure:"conversations"` // Watched; empty watches the main conversation
+}
+
+// DepthGuardConfig caps the length of a conversation. Past either cap, the
+// agents each post a closing statement and the conversation is locked.
+type DepthGuardConfig struct {
+	MaxMessages int `mapstructure:"max_messages"` // Messages of users and agents; 0 for no cap
+	// MaxDuration counts from the conversation's start; 0 for no cap
+	MaxDuration time.Duration `mapstructure:"max_duration"`
+	// ClosingTimeout skips an agent that stays silent this long after being asked for its closing statement
+	ClosingTimeout time.Duration `mapstructure:"closing_timeout"`
+}
+
+// TutoringConfig sets up Socratic tutoring: the tutor only asks the user
+// guided questions, lesson by lesson, while the other agents stay quiet
+// unless the user summons them
+type TutoringConfig struct {
+	Tutor    string         `mapstructure:"tutor"`    // Default tutor agent
+	Syllabus []LessonConfig `mapstructure:"syllabus"` // Worked through in order
+}
+
+// LessonConfig is a step of the tutoring syllabus
+type LessonConfig struct {
+	Topic     string `mapstructure:"topic"`
+	Objective string `mapstructure:"objective"` // What the user should be able to explain before moving on
+}
+
+// DebateConfig tunes debates: opening statements, alternating rebuttals and
+// closing statements, followed by a judge's verdict
+type DebateConfig struct {
+	Rounds      int           `mapstructure:"rounds"`       // Rebuttal rounds
+	WordLimit   int           `mapstructure:"word_limit"`   // Per statement
+	TurnTimeout time.Duration `mapstructure:"turn_timeout"` // A speaker who stays silent this long loses the turn
+	Judge       string        `mapstructure:"judge"`        // Agent that gives the verdict; empty ends without one
+	// Prompts overrides the moderator's prompts, see DebatePrompts
+	Prompts DebatePrompts `mapstructure:"prompts"`
+}
+
+// DebatePrompts are text/templates of the moderator's prompts; empty ones use
+// the built-in prompts. They can use .Proposition, .Speaker, .Stance ("for" or
+// "against"), .Opponents, .Previous, .WordLimit, .Round, .Rounds, .For and .Against.
+type DebatePrompts struct {
+	Opening  string `mapstructure:"opening"`
+	Rebuttal string `mapstructure:"rebuttal"`
+	Closing  string `mapstructure:"closing"`
+	Verdict  string `mapstructure:"verdict"`
+}
+
+// PhaseConfig defines a stage of the conversation. A phase ends after the
+// given number of messages or time, whichever comes first, or on "/phase next".
+type PhaseConfig struct {
+	Name     string        `mapstructure:"name"`
+	Agents   []string      `mapstructure:"agents"`   // Agents allowed to speak; empty allows all
+	Prompt   string        `mapstructure:"prompt"`   // Added to the agents' instructions
+	Messages int           `mapstructure:"messages"` // 0 doesn't end the phase on a message count
+	Duration time.Duration `mapstructure:"duration"` // 0 doesn't end the phase on time
 }
 
 type AgentsConfig struct {
-	LLMAPIKey string `mapstructure:"llm_api_key"`
+	LLMAPIKey string `mapstructure:"llm_api_key" secret:"true"`
 	LLMURL    string `mapstructure:"llm_url"`
 	OllamaURL string `mapstructure:"ollama_url"`
 	Model     string `mapstructure:"model"`
-	Provider  string `mapstructure:"provider"` // "openai" or "ollama"
+	Provider  string `mapstructure:"provider"` // "openai", "ollama", "ollama-generate", "replay" or "scripted"
+	// Temperature is the sampling temperature of LLM agents; higher is more creative
+	Temperature float64 `mapstructure:"temperature"`
+	// Usage quotas enforced before every LLM call
+	Quotas QuotaConfig `mapstructure:"quotas"`
+	// Search API used by tool-using agents such as the fact-checker
+	Search SearchConfig `mapstructure:"search"`
+	// Fetch lets LLM agents read the web pages people link to
+	Fetch FetchConfig `mapstructure:"fetch"`
+	// ClaimReplies makes replicas of an agent claim a message before answering,
+	// so only one of them replies; ClaimTimeout bounds the wait for the outcome
+	ClaimReplies bool          `mapstructure:"claim_replies"`
+	ClaimTimeout time.Duration `mapstructure:"claim_timeout"`
+	// FeedbackInPrompt tells LLM agents how users rated their recent replies
+	FeedbackInPrompt bool `mapstructure:"feedback_in_prompt"`
+	// Adaptive response chances that follow user engagement
+	Adaptive AdaptiveConfig `mapstructure:"adaptive"`
+	// Checks that keep LLM agents from restating what was just said
+	Repetition RepetitionConfig `mapstructure:"repetition"`
+	// Reminders that keep LLM agents from drifting into other participants' voices
+	Identity IdentityConfig `mapstructure:"identity"`
+	// HistoryRoles is how the history is mapped to chat roles in LLM prompts:
+	// "own" makes only the agent's own messages assistant turns and everyone
+	// else's named user turns; "agents" makes every agent's message an
+	// assistant turn, as before
+	HistoryRoles string `mapstructure:"history_roles"`
+	// The date, time and place LLM agents are told about
+	Clock ClockConfig `mapstructure:"clock"`
+	// Context providers add outside facts to LLM prompts
+	Context ContextConfig `mapstructure:"context"`
+	// Latency, tokens and cost shown with each agent reply
+	Usage UsageConfig `mapstructure:"usage"`
+	// Restarts of agents that died or got stuck
+	Supervisor SupervisorConfig `mapstructure:"supervisor"`
+	// MessageTimeout bounds how long an agent may take to handle a message,
+	// LLM calls included; an agent that takes longer gives up with a system
+	// note. 0 sets no limit.
+	MessageTimeout time.Duration `mapstructure:"message_timeout"`
+	// Proxy, trusted certificates and extra headers for requests to LLM providers
+	HTTP LLMHTTPConfig `mapstructure:"http"`
+	// Masking of personal data before prompts leave for external providers
+	Redaction RedactionConfig `mapstructure:"redaction"`
+	// Prompt template of the "ollama-generate" provider; agents can override it
+	Completion CompletionConfig `mapstructure:"completion"`
+	// Pulling and loading Ollama models at startup
+	Warmup WarmupConfig `mapstructure:"warmup"`
+	// Recording of LLM calls, served back by the "replay" provider
+	Fixtures FixturesConfig `mapstructure:"fixtures"`
+	// DebugCalls is the number of latest LLM calls each agent keeps for the
+	// debug API, prompts and raw responses included; 0 keeps none
+	DebugCalls int `mapstructure:"debug_calls"`
+	// DryRun has every agent compute its responses without publishing them,
+	// e.g. to try new prompts against live traffic
+	DryRun bool `mapstructure:"dry_run"`
+	// Canned responses served by the "scripted" provider
+	Scripted ScriptedConfig `mapstructure:"scripted"`
+	// Custom agent types implemented by plugin programs
+	Plugins []PluginConfig `mapstructure:"plugins"`
 	// Agents configuration
 	Agents []AgentConfig `mapstructure:"agents"`
 }
 
+// PluginConfig registers an agent type implemented by a plugin program, see pkg/agentplugin
+type PluginConfig struct {
+	Type string   `mapstructure:"type"` // Agent type the plugin provides
+	Path string   `mapstructure:"path"` // Plugin program, started once per agent
+	Args []string `mapstructure:"args"`
+}
+
+// Plugin returns the plugin providing an agent type
+func (c AgentsConfig) Plugin(agentType string) (PluginConfig, bool) {
+	for _, plugin := range c.Plugins {
+		if plugin.Type == agentType {
+			return plugin, true
+		}
+	}
+	return PluginConfig{}, false
+}
+
+// IdentityConfig keeps LLM agents in character: a reminder of who they are
+// goes right before the message they answer, and the lines they write for
+// other participants are cut from their replies
+type IdentityConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// MinHistory is the number of history messages from which the reminder is
+	// added; in shorter prompts the system prompt is close enough
+	MinHistory int `mapstructure:"min_history"`
+}
+
+// QuotaConfig limits LLM usage; zero values mean unlimited
+type QuotaConfig struct {
+	MaxCallsPerConversationPerHour int            `mapstructure:"max_calls_per_conversation_per_hour"`
+	MaxDailyTokens                 map[string]int `mapstructure:"max_daily_tokens"` // Keyed by provider name
+}
+
+// AdaptiveConfig makes agents speak up more when users engage with them and
+// less when they are ignored
+type AdaptiveConfig struct {
+	Enabled   bool          `mapstructure:"enabled"`
+	Step      float64       `mapstructure:"step"`       // Change in response chance per reaction or ignored message
+	Window    time.Duration `mapstructure:"window"`     // How long a message waits for a reaction before it counts as ignored
+	HalfLife  time.Duration `mapstructure:"half_life"`  // How fast adjustments fade back to the configured chance
+	MinChance float64       `mapstructure:"min_chance"` // Bounds of the effective response chance
+	MaxChance float64       `mapstructure:"max_chance"`
+}
+
+// RepetitionConfig makes LLM agents check their responses against the
+// conversation's recent messages and not post the ones that parrot them
+type RepetitionConfig struct {
+	Enabled    bool    `mapstructure:"enabled"`
+	NGram      int     `mapstructure:"ngram"`       // Length of the word sequences compared
+	Window     int     `mapstructure:"window"`      // Recent messages a response is compared with
+	MaxOverlap float64 `mapstructure:"max_overlap"` // Share of a response's word sequences found in them above which it parrots
+	// Action is "regenerate", to try once more at a higher temperature and
+	// skip the turn if that parrots too, or "skip"
+	Action          string  `mapstructure:"action"`
+	TemperatureStep float64 `mapstructure:"temperature_step"` // Added to the temperature when regenerating
+}
+
+// ClockConfig tells LLM agents the current date and time, and where they
+// are, so they know what day it is and greet by the time of day
+type ClockConfig struct {
+	Enabled  bool   `mapstructure:"enabled"`
+	Timezone string `mapstructure:"timezone"` // IANA name, e.g. "Europe/Amsterdam"; empty uses the server's
+	Location string `mapstructure:"location"` // e.g. "Amsterdam, the Netherlands"; empty leaves the place out
+}
+
+// UsageConfig annotates agent replies with what generating them took, so
+// the UI can show e.g. "2.3s · 412 tokens · $0.004" next to each
+type UsageConfig struct {
+	Annotate bool `mapstructure:"annotate"`
+	// Prices estimate the cost; models without a price get no cost
+	Prices []ModelPriceConfig `mapstructure:"prices"`
+}
+
+// ModelPriceConfig is what a model costs, in US dollars per million tokens
+type ModelPriceConfig struct {
+	Model  string  `mapstructure:"model"`
+	Input  float64 `mapstructure:"input"`  // Per million prompt tokens
+	Output float64 `mapstructure:"output"` // Per million completion tokens
+}
+
+// SupervisorConfig has the agent manager restart agents whose subscription
+// ended, whose handler panicked or that are stuck handling a message
+type SupervisorConfig struct {
+	Enabled    bool          `mapstructure:"enabled"`
+	Interval   time.Duration `mapstructure:"interval"`    // How often the agents are checked
+	StuckAfter time.Duration `mapstructure:"stuck_after"` // An agent handling a message for longer is stuck
+	// Backoff is the least time between restarts of an agent; it doubles with
+	// each restart in a row, up to MaxBackoff, and resets once the agent stays
+	// up for MaxBackoff
+	Backoff    time.Duration `mapstructure:"backoff"`
+	MaxBackoff time.Duration `mapstructure:"max_backoff"`
+}
+
+// ContextConfig sets up the context providers that add facts from outside
+// the conversation, such as the weather, to LLM prompts when a message calls for them
+type ContextConfig struct {
+	Providers []ContextProviderConfig `mapstructure:"providers"`
+	Timeout   time.Duration           `mapstructure:"timeout"`   // Per lookup; a slow provider is left out
+	CacheTTL  time.Duration           `mapstructure:"cache_ttl"` // How long a lookup is reused
+}
+
+// ContextProviderConfig configures a context provider. It is asked when a
+// message contains one of its keywords, about the rest of the sentence, or
+// when the conversation is on one of its topics.
+type ContextProviderConfig struct {
+	Type     string   `mapstructure:"type"`     // "weather" or "wikipedia"
+	Keywords []string `mapstructure:"keywords"` // Empty uses the provider's own
+	Topics   []string `mapstructure:"topics"`   // Detected topics, e.g. "science"
+	Location string   `mapstructure:"location"` // Weather: a place, or "latitude,longitude"
+	Language string   `mapstructure:"language"` // Wikipedia: the edition, "en" by default
+	URL      string   `mapstructure:"url"`      // Overrides the provider's API
+}
+
+// LLMHTTPConfig configures how LLM agents reach their providers over HTTP
+type LLMHTTPConfig struct {
+	ProxyURL string                       `mapstructure:"proxy_url" secret:"true"` // Empty uses HTTPS_PROXY/HTTP_PROXY/NO_PROXY
+	CABundle string                       `mapstructure:"ca_bundle"`               // PEM file trusted in addition to the system roots
+	Headers  map[string]map[string]string `mapstructure:"headers" secret:"true"`   // Extra request headers keyed by provider
+	// Connection pool shared by all LLM agents
+	MaxIdleConns        int           `mapstructure:"max_idle_conns"`
+	MaxIdleConnsPerHost int           `mapstructure:"max_idle_conns_per_host"`
+	MaxConnsPerHost     int           `mapstructure:"max_conns_per_host"` // 0 is unlimited
+	IdleConnTimeout     time.Duration `mapstructure:"idle_conn_timeout"`
+	ConnectTimeout      time.Duration `mapstructure:"connect_timeout"` // Dialing and the TLS handshake
+	ReadTimeout         time.Duration `mapstructure:"read_timeout"`    // Waiting for the provider to answer
+	HTTP2               bool          `mapstructure:"http2"`
+}
+
+// RedactionConfig configures the masking of personal data in prompts
+type RedactionConfig struct {
+	Enabled   bool               `mapstructure:"enabled"`
+	Providers []string           `mapstructure:"providers"` // Providers whose prompts are redacted, e.g. ["openai"]
+	Builtins  []string           `mapstructure:"builtins"`  // "email", "phone" and "credit_card"
+	Patterns  []RedactionPattern `mapstructure:"patterns"`
+	NERURL    string             `mapstructure:"ner_url"`    // Optional named-entity recognition service
+	NERLabels []string           `mapstructure:"ner_labels"` // Entity labels to mask; empty masks all
+}
+
+// RedactionPattern masks every match of a regular expression
+type RedactionPattern struct {
+	Name        string `mapstructure:"name"`
+	Pattern     string `mapstructure:"pattern"`
+	Replacement string `mapstructure:"replacement"` // Defaults to "[<NAME>]"
+}
+
+// CompletionConfig turns a chat into a raw prompt for models served through
+// Ollama's generate endpoint. Template is a Go template that can use
+// {{.System}}, {{.History}} (messages with .Role and .Content), {{.Prompt}}
+// and {{.Agent}}; Prefix and Suffix wrap the rendered prompt.
+type CompletionConfig struct {
+	Template string   `mapstructure:"template"`
+	Prefix   string   `mapstructure:"prefix"`
+	Suffix   string   `mapstructure:"suffix"`
+	Stop     []string `mapstructure:"stop"` // Generation ends at any of these sequences
+}
+
+// WarmupConfig preloads Ollama models before agents start answering
+type WarmupConfig struct {
+	Enabled bool          `mapstructure:"enabled"`
+	Pull    bool          `mapstructure:"pull"`    // Download missing or outdated models first
+	Models  []string      `mapstructure:"models"`  // Loaded in addition to agents.model
+	Timeout time.Duration `mapstructure:"timeout"` // Per model, download included
+}
+
+// FixturesConfig configures recording and replaying LLM calls for offline,
+// deterministic runs
+type FixturesConfig struct {
+	Dir    string `mapstructure:"dir"`    // One JSON file per recorded prompt
+	Record bool   `mapstructure:"record"` // Save every provider answer to dir
+}
+
+// ScriptedConfig lists the responses of the scripted provider; the first
+// rule matching the message being answered is used
+type ScriptedConfig struct {
+	Responses []ScriptedResponse `mapstructure:"responses"`
+}
+
+// ScriptedResponse answers messages matching a regular expression with its
+// responses in turn. Responses are Go templates that can use {{.Agent}},
+// {{.AgentID}}, {{.Message}}, {{.Turn}} and {{index .Groups 1}}.
+type ScriptedResponse struct {
+	Match     string   `mapstructure:"match"` // Empty matches every message
+	Responses []string `mapstructure:"responses"`
+}
+
+// SearchConfig configures the web search API
+type SearchConfig struct {
+	Provider   string `mapstructure:"provider"` // "searxng", "brave" or "bing"
+	URL        string `mapstructure:"url"`
+	APIKey     string `mapstructure:"api_key" secret:"true"`
+	MaxResults int    `mapstructure:"max_results"`
+}
+
+// FetchConfig tunes the fetch_url tool. Pages are read as the configured user
+// agent, which robots.txt can shut out, and cached for CacheTTL.
+type FetchConfig struct {
+	Enabled      bool          `mapstructure:"enabled"`
+	UserAgent    string        `mapstructure:"user_agent"`
+	MaxBytes     int64         `mapstructure:"max_bytes"` // Larger pages are cut off
+	MaxChars     int           `mapstructure:"max_chars"` // Extracted text handed to the model
+	Timeout      time.Duration `mapstructure:"timeout"`
+	CacheTTL     time.Duration `mapstructure:"cache_ttl"`
+	AllowPrivate bool          `mapstructure:"allow_private"` // Also fetch from loopback and private networks, through the agents.http proxy
+}
+
 // AgentConfig defines the configuration for any agent
 type AgentConfig struct {
 	ID             string  `mapstructure:"id"`
@@ -43,9 +649,107 @@ type AgentConfig struct {
 	ResponseChance float64 `mapstructure:"response_chance"`
 	IsEnabled      bool    `mapstructure:"enabled"`
 	Description    string  `mapstructure:"description,omitempty"`
+	// Model overrides agents.model for this agent
+	Model string `mapstructure:"model,omitempty"`
+	// Temperature overrides agents.temperature for this agent
+	Temperature float64 `mapstructure:"temperature,omitempty"`
+	// HistoryRoles overrides agents.history_roles for this agent
+	HistoryRoles string `mapstructure:"history_roles,omitempty"`
+	// Preset names a built-in personality (e.g. "skeptic") that fills in the fields left unset here
+	Preset string `mapstructure:"preset,omitempty"`
+	// Traits are character traits added to the agent's instructions
+	Traits []string `mapstructure:"traits,omitempty"`
+	// Instructions tell the agent how to take part in the discussion
+	Instructions string `mapstructure:"instructions,omitempty"`
+	// SummaryInterval is the number of messages between digests (summarizer agents only)
+	SummaryInterval int `mapstructure:"summary_interval,omitempty"`
+	// Theme is what the questions of a question_of_the_day agent are about;
+	// empty uses the topic of the scheduled conversation
+	Theme string `mapstructure:"theme,omitempty"`
+	// VoteInPolls lets LLM agents cast a reasoned vote when a poll is announced
+	VoteInPolls bool `mapstructure:"vote_in_polls,omitempty"`
+	// WorldTools lets LLM agents read and change the story's world state with tools
+	WorldTools bool `mapstructure:"world_tools,omitempty"`
+	// WhiteboardTools lets LLM agents read and edit the conversation's whiteboard with tools
+	WhiteboardTools bool `mapstructure:"whiteboard_tools,omitempty"`
+	// ReminderTools lets LLM agents schedule reminders, e.g. when asked to "remind us in 2 hours"
+	ReminderTools bool `mapstructure:"reminder_tools,omitempty"`
+	// Rubric lists the criteria a judge agent scores replies on
+	Rubric []string `mapstructure:"rubric,omitempty"`
+	// Specialists limits the agents a router hands questions to; empty allows every LLM agent
+	Specialists []string `mapstructure:"specialists,omitempty"`
+	// Completion overrides agents.completion for this agent's model
+	Completion CompletionConfig `mapstructure:"completion,omitempty"`
+	// Settings are passed as they are to the plugin of a plugin-provided agent type
+	// and to the script of a script agent
+	Settings map[string]string `mapstructure:"settings,omitempty"`
+	// Script is the Lua file implementing a script agent
+	Script string `mapstructure:"script,omitempty"`
+	// Rules decide whether the agent responds before its response chance is
+	// rolled; the first rule whose expression is true wins
+	Rules []RuleConfig `mapstructure:"rules,omitempty"`
+	// DirectMessages lets LLM agents send each other private notes through a tool
+	DirectMessages bool `mapstructure:"direct_messages,omitempty"`
+	// TaskTools lets LLM agents start research and document ingestion tasks through a tool
+	TaskTools bool `mapstructure:"task_tools,omitempty"`
+	// Scratchpad has LLM agents think before answering, in notes only admins can read
+	Scratchpad bool `mapstructure:"scratchpad,omitempty"`
+	// DryRun has the agent compute its responses without publishing them; agents.dry_run does so for all
+	DryRun bool `mapstructure:"dry_run,omitempty"`
+	// Shadow runs a variant of an LLM agent on the same messages, without
+	// publishing, to compare its replies with the agent's own
+	Shadow ShadowConfig `mapstructure:"shadow,omitempty"`
+	// OutputSchema is a JSON schema the agent's answers must match; providers
+	// are asked to constrain their output to it and malformed answers are retried
+	OutputSchema string `mapstructure:"output_schema,omitempty"`
+	// StartFrom is where the agent starts reading the chat topics: "checkpoint"
+	// (the default) resumes where it stopped, "earliest" and "latest" jump to
+	// the start or end, and a duration ("2h") or RFC 3339 time replays from then
+	StartFrom string `mapstructure:"start_from,omitempty"`
+	// MessageTimeout overrides agents.message_timeout for this agent
+	MessageTimeout time.Duration `mapstructure:"message_timeout,omitempty"`
+	// Signing lets consumers check that messages from this agent are genuine
+	Signing SigningConfig `mapstructure:"signing,omitempty"`
 }
 
-func Load() (*Config, error) {
+// ShadowConfig is the variant of an agent that answers in its shadow; the
+// fields left unset are the agent's own
+type ShadowConfig struct {
+	Enabled      bool     `mapstructure:"enabled"`
+	Model        string   `mapstructure:"model,omitempty"`
+	Temperature  float64  `mapstructure:"temperature,omitempty"`
+	Description  string   `mapstructure:"description,omitempty"`
+	Traits       []string `mapstructure:"traits,omitempty"`
+	Instructions string   `mapstructure:"instructions,omitempty"`
+}
+
+// SigningConfig holds an agent's message signing key. Consumers drop messages
+// that claim to come from the agent without a valid signature.
+type SigningConfig struct {
+	Algorithm string `mapstructure:"algorithm"` // "hmac" (shared secret) or "ed25519"
+	// Key is the HMAC secret, or the base64 ed25519 private key (or its 32-byte
+	// seed); only replicas that run the agent need an ed25519 key
+	Key string `mapstructure:"key" secret:"true"`
+	// PublicKey is the base64 ed25519 public key, enough to verify the agent's messages
+	PublicKey string `mapstructure:"public_key"`
+}
+
+// RuleConfig makes an agent respond to or ignore the messages matching a CEL
+// expression, e.g. `message.type == "user" && contains(topic, "ethics")`
+type RuleConfig struct {
+	When   string `mapstructure:"when"`
+	Action string `mapstructure:"action"` // "respond" or "ignore"
+}
+
+// Load reads config.yaml, overlays config.<profile>.yaml when a profile is
+// given (or set via PHILOKING_PROFILE) and applies environment overrides.
+// Nested keys map to upper-cased, underscore-joined variables, e.g.
+// kafka.topics.chat_messages -> KAFKA_TOPICS_CHAT_MESSAGES.
+func Load(profile string) (*Config, error) {
+	if profile == "" {
+		profile = os.Getenv(ProfileEnvVar)
+	}
+
 	viper.SetConfigName("config")
 	viper.SetConfigType("yaml")
 	viper.AddConfigPath(".")
@@ -54,15 +758,115 @@ func Load() (*Config, error) {
 	// Set default values
 	viper.SetDefault("kafka.brokers", []string{"localhost:9092"})
 	viper.SetDefault("kafka.topics.chat_messages", "chat-messages")
+	viper.SetDefault("kafka.topics.chat_responses", "chat-responses")
+	viper.SetDefault("kafka.topics.control", "chat-control")
+	viper.SetDefault("kafka.topics.presence", "chat-presence")
+	viper.SetDefault("kafka.topics.claims", "chat-claims")
+	viper.SetDefault("kafka.topics.agent_messages", "agent-messages")
+	viper.SetDefault("kafka.topics.errors", "agent-errors")
+	viper.SetDefault("kafka.serialization.format", "json")
 	viper.SetDefault("web.port", "8080")
 	viper.SetDefault("web.host", "localhost")
+	viper.SetDefault("web.backfill_messages", 50)
+	viper.SetDefault("web.compress_websocket", true)
+	viper.SetDefault("web.gzip_responses", true)
+	viper.SetDefault("web.gzip_min_bytes", 1024)
 	viper.SetDefault("agents.llm_url", "https://api.openai.com/v1/chat/completions")
 	viper.SetDefault("agents.ollama_url", "http://localhost:11434")
 	viper.SetDefault("agents.model", "llama2")
+	viper.SetDefault("agents.temperature", 0.7)
+	viper.SetDefault("agents.debug_calls", 20)
 	viper.SetDefault("agents.provider", "ollama")
+	viper.SetDefault("agents.search.max_results", 5)
+	viper.SetDefault("agents.fetch.user_agent", "philoking/1.0")
+	viper.SetDefault("agents.fetch.max_bytes", 2*1024*1024)
+	viper.SetDefault("agents.fetch.max_chars", 8000)
+	viper.SetDefault("agents.fetch.timeout", "10s")
+	viper.SetDefault("agents.fetch.cache_ttl", "15m")
+	viper.SetDefault("agents.claim_timeout", "2s")
+	viper.SetDefault("agents.adaptive.step", 0.05)
+	viper.SetDefault("agents.adaptive.window", "2m")
+	viper.SetDefault("agents.adaptive.half_life", "15m")
+	viper.SetDefault("agents.adaptive.min_chance", 0.05)
+	viper.SetDefault("agents.adaptive.max_chance", 0.95)
+	viper.SetDefault("agents.repetition.ngram", 3)
+	viper.SetDefault("agents.clock.enabled", true)
+	viper.SetDefault("agents.identity.enabled", true)
+	viper.SetDefault("agents.identity.min_history", 6)
+	viper.SetDefault("agents.history_roles", "own")
+	viper.SetDefault("agents.message_timeout", "3m")
+	viper.SetDefault("agents.supervisor.interval", "10s")
+	viper.SetDefault("agents.supervisor.stuck_after", "5m")
+	viper.SetDefault("agents.supervisor.backoff", "5s")
+	viper.SetDefault("agents.supervisor.max_backoff", "5m")
+	viper.SetDefault("agents.context.timeout", "5s")
+	viper.SetDefault("agents.context.cache_ttl", "10m")
+	viper.SetDefault("agents.repetition.window", 10)
+	viper.SetDefault("agents.repetition.max_overlap", 0.5)
+	viper.SetDefault("agents.repetition.action", "regenerate")
+	viper.SetDefault("agents.repetition.temperature_step", 0.3)
+	viper.SetDefault("agents.http.max_idle_conns", 100)
+	viper.SetDefault("agents.http.max_idle_conns_per_host", 32)
+	viper.SetDefault("agents.http.idle_conn_timeout", "90s")
+	viper.SetDefault("agents.http.connect_timeout", "10s")
+	viper.SetDefault("agents.http.read_timeout", "120s")
+	viper.SetDefault("agents.http.http2", true)
+	viper.SetDefault("agents.warmup.pull", true)
+	viper.SetDefault("agents.warmup.timeout", "30m")
+	viper.SetDefault("agents.redaction.providers", []string{"openai"})
+	viper.SetDefault("agents.redaction.builtins", []string{"email", "phone", "credit_card"})
+	viper.SetDefault("conversation.question_timeout", "2m")
+	viper.SetDefault("conversation.handoff_timeout", "2m")
+	viper.SetDefault("conversation.notice_ttl", "30s")
+	viper.SetDefault("conversation.debate.rounds", 2)
+	viper.SetDefault("conversation.debate.word_limit", 150)
+	viper.SetDefault("conversation.debate.turn_timeout", "2m")
+	viper.SetDefault("conversation.depth_guard.closing_timeout", "2m")
+	viper.SetDefault("storage.agent_state_interval", "1m")
+	viper.SetDefault("archive.idle_after", "24h")
+	viper.SetDefault("archive.interval", "1h")
+	viper.SetDefault("tap.max_size_mb", 100)
+	viper.SetDefault("tap.rotate_every", "24h")
+	viper.SetDefault("tap.max_backups", 7)
+	viper.SetDefault("kafka.consumer.initial_backoff", "100ms")
+	viper.SetDefault("kafka.consumer.max_backoff", "30s")
+	viper.SetDefault("kafka.consumer.recreate_after", 5)
+	viper.SetDefault("kafka.consumer.handler_attempts", 3)
+	viper.SetDefault("digest.send_at", "08:00")
+	viper.SetDefault("digest.subject", "Your daily PhiloKing digest")
+	viper.SetDefault("daily.start_at", "09:00")
+	viper.SetDefault("daily.id_prefix", "daily")
+	viper.SetDefault("daily.title", "Daily conversation")
+	viper.SetDefault("daily.webhook_timeout", "10s")
+	viper.SetDefault("tasks.workers", 2)
+	viper.SetDefault("tasks.max_attempts", 3)
+	viper.SetDefault("tasks.retry_delay", "30s")
+	viper.SetDefault("tasks.timeout", "10m")
+	viper.SetDefault("moderation.rejection_message", "Your message was not posted because it breaks the conversation's rules.")
+	viper.SetDefault("moderation.api.block_threshold", 0.8)
+	viper.SetDefault("moderation.api.flag_threshold", 0.4)
+	viper.SetDefault("moderation.api.timeout", "5s")
+	viper.SetDefault("evaluation.interval", "24h")
+	viper.SetDefault("evaluation.min_messages", 10)
+	viper.SetDefault("evaluation.max_messages", 100)
+	viper.SetDefault("evaluation.keep", 20)
+	viper.SetDefault("embeddings.provider", "ollama")
+	viper.SetDefault("embeddings.model", "nomic-embed-text")
+	viper.SetDefault("embeddings.interval", "10m")
+	viper.SetDefault("embeddings.batch_size", 32)
+	viper.SetDefault("embeddings.clusters", 5)
+	viper.SetDefault("embeddings.min_messages", 12)
+	viper.SetDefault("digest.smtp.port", 587)
+	viper.SetDefault("startup.attempts", 5)
+	viper.SetDefault("startup.initial_backoff", "1s")
+	viper.SetDefault("startup.max_backoff", "30s")
 
-	// Allow environment variables to override config
+	// Allow environment variables to override config, including nested keys
+	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
 	viper.AutomaticEnv()
+	if err := bindEnvs(reflect.TypeOf(Config{}), ""); err != nil {
+		return nil, fmt.Errorf("error binding environment variables: %w", err)
+	}
 
 	// Read config file
 	if err := viper.ReadInConfig(); err != nil {
@@ -72,19 +876,270 @@ func Load() (*Config, error) {
 		// Config file not found, use defaults and env vars
 	}
 
+	// Overlay the profile-specific config file
+	if profile != "" {
+		viper.SetConfigName("config." + profile)
+		if err := viper.MergeInConfig(); err != nil {
+			return nil, fmt.Errorf("error reading config for profile %s: %w", profile, err)
+		}
+	}
+
 	var config Config
 	if err := viper.Unmarshal(&config); err != nil {
 		return nil, fmt.Errorf("error unmarshaling config: %w", err)
 	}
 
+	config.applyPresets()
+
+	if config.Web.InstanceID == "" {
+		config.Web.InstanceID = defaultInstanceID()
+	}
+
 	// Override with environment variables if set
-	if apiKey := os.Getenv("LLM_API_KEY"); apiKey != "" {
-		config.Agents.LLMAPIKey = apiKey
+	if searchKey := os.Getenv("SEARCH_API_KEY"); searchKey != "" {
+		config.Agents.Search.APIKey = searchKey
+	}
+	if smtpPassword := os.Getenv("SMTP_PASSWORD"); smtpPassword != "" {
+		config.Digest.SMTP.Password = smtpPassword
+	}
+	if moderationKey := os.Getenv("MODERATION_API_KEY"); moderationKey != "" {
+		config.Moderation.API.APIKey = moderationKey
 	}
 
 	return &config, nil
 }
 
+// applyPresets fills in the fields agents leave unset from the preset they
+// name; unknown presets are reported by Validate
+func (c *Config) applyPresets() {
+	for i := range c.Agents.Agents {
+		c.Agents.Agents[i].ApplyPreset()
+	}
+	for _, tenant := range c.Tenants {
+		for i := range tenant.Agents {
+			tenant.Agents[i].ApplyPreset()
+		}
+	}
+}
+
+// ApplyPreset fills in the fields the agent leaves unset from its preset
+func (a *AgentConfig) ApplyPreset() {
+	preset, ok := presets.Get(a.Preset)
+	if !ok {
+		return
+	}
+
+	if a.Type == "" {
+		a.Type = preset.Type
+	}
+	if a.Description == "" {
+		a.Description = preset.Description
+	}
+	if a.ResponseChance == 0 {
+		a.ResponseChance = preset.ResponseChance
+	}
+	if len(a.Traits) == 0 {
+		a.Traits = append([]string(nil), preset.Traits...)
+	}
+	if a.Instructions == "" {
+		a.Instructions = preset.Instructions
+	}
+}
+
+// ValidationError is an agent spec, or another input, with invalid settings
+type ValidationError struct {
+	Errs []error
+}
+
+func (e *ValidationError) Error() string {
+	return errors.Join(e.Errs...).Error()
+}
+
+func (e *ValidationError) Unwrap() []error {
+	return e.Errs
+}
+
+// Validate checks the settings of a single agent
+func (a AgentConfig) Validate() []error {
+	var errs []error
+	if _, ok := presets.Get(a.Preset); a.Preset != "" && !ok {
+		errs = append(errs, fmt.Errorf("agent %s: unknown preset %q (available: %s)", a.ID, a.Preset, strings.Join(presets.Names(), ", ")))
+	}
+	if a.ResponseChance < 0 || a.ResponseChance > 1 {
+		errs = append(errs, fmt.Errorf("agent %s: response_chance must be between 0 and 1", a.ID))
+	}
+	if a.Temperature < 0 || a.Temperature > 2 {
+		errs = append(errs, fmt.Errorf("agent %s: temperature must be between 0 and 2", a.ID))
+	}
+	if a.HistoryRoles != "" && !validHistoryRoles(a.HistoryRoles) {
+		errs = append(errs, fmt.Errorf("agent %s: history_roles must be \"own\" or \"agents\"", a.ID))
+	}
+	if _, err := template.New("completion").Parse(a.Completion.Template); err != nil {
+		errs = append(errs, fmt.Errorf("agent %s: completion.template: %w", a.ID, err))
+	}
+	for i, rule := range a.Rules {
+		if rule.Action != "respond" && rule.Action != "ignore" {
+			errs = append(errs, fmt.Errorf("agent %s: rules[%d]: action must be \"respond\" or \"ignore\"", a.ID, i))
+		}
+		if err := rules.Check(rule.When); err != nil {
+			errs = append(errs, fmt.Errorf("agent %s: rules[%d]: %w", a.ID, i, err))
+		}
+	}
+	if err := a.Signing.Validate(); err != nil {
+		errs = append(errs, fmt.Errorf("agent %s: signing: %w", a.ID, err))
+	}
+	if a.MessageTimeout < 0 {
+		errs = append(errs, fmt.Errorf("agent %s: message_timeout must not be negative", a.ID))
+	}
+	if !validStartFrom(a.StartFrom) {
+		errs = append(errs, fmt.Errorf("agent %s: start_from must be checkpoint, earliest, latest, a positive duration or an RFC 3339 time", a.ID))
+	}
+	if a.Type == "script" && a.Script == "" {
+		errs = append(errs, fmt.Errorf("agent %s: script agents need a script", a.ID))
+	}
+	if a.Shadow.Enabled && a.Type != "llm" {
+		errs = append(errs, fmt.Errorf("agent %s: only llm agents have a shadow", a.ID))
+	}
+	if a.Shadow.Temperature < 0 || a.Shadow.Temperature > 2 {
+		errs = append(errs, fmt.Errorf("agent %s: shadow.temperature must be between 0 and 2", a.ID))
+	}
+	if a.OutputSchema != "" {
+		if _, err := jsonschema.Parse([]byte(a.OutputSchema)); err != nil {
+			errs = append(errs, fmt.Errorf("agent %s: output_schema: %w", a.ID, err))
+		}
+	}
+
+	return errs
+}
+
+// Validate checks that the signing keys match the algorithm
+func (s SigningConfig) Validate() error {
+	switch s.Algorithm {
+	case "":
+		if s.Key != "" || s.PublicKey != "" {
+			return fmt.Errorf("algorithm must be \"hmac\" or \"ed25519\" when a key is set")
+		}
+	case "hmac":
+		if s.Key == "" {
+			return fmt.Errorf("hmac needs a key")
+		}
+	case "ed25519":
+		if s.Key == "" && s.PublicKey == "" {
+			return fmt.Errorf("ed25519 needs a key or a public_key")
+		}
+		if key, err := base64.StdEncoding.DecodeString(s.Key); s.Key != "" && (err != nil || (len(key) != ed25519.SeedSize && len(key) != ed25519.PrivateKeySize)) {
+			return fmt.Errorf("key must be a base64 ed25519 private key or seed")
+		}
+		if key, err := base64.StdEncoding.DecodeString(s.PublicKey); s.PublicKey != "" && (err != nil || len(key) != ed25519.PublicKeySize) {
+			return fmt.Errorf("public_key must be a base64 ed25519 public key")
+		}
+	default:
+		return fmt.Errorf("unknown algorithm %q (supported: hmac, ed25519)", s.Algorithm)
+	}
+	return nil
+}
+
+// validHistoryRoles checks a history_roles setting
+func validHistoryRoles(historyRoles string) bool {
+	return historyRoles == "own" || historyRoles == "agents"
+}
+
+// validStartFrom checks the format of an agent's start_from setting
+func validStartFrom(startFrom string) bool {
+	switch startFrom {
+	case "", "checkpoint", "earliest", "latest":
+		return true
+	}
+	if window, err := time.ParseDuration(startFrom); err == nil {
+		return window > 0
+	}
+	_, err := time.Parse(time.RFC3339, startFrom)
+	return err == nil
+}
+
+// defaultInstanceID derives a unique instance ID from the hostname
+func defaultInstanceID() string {
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "philoking"
+	}
+	return hostname + "-" + uuid.New().String()[:8]
+}
+
+// bindEnvs binds every nested config key to its environment variable so that
+// viper.Unmarshal picks them up even when the key is absent from the config file
+func bindEnvs(t reflect.Type, prefix string) error {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := strings.Split(field.Tag.Get("mapstructure"), ",")[0]
+		if tag == "" || tag == "-" {
+			continue
+		}
+
+		key := tag
+		if prefix != "" {
+			key = prefix + "." + tag
+		}
+
+		// Lists of structs (e.g. agents) can only be configured in files
+		if field.Type.Kind() == reflect.Struct {
+			if err := bindEnvs(field.Type, key); err != nil {
+				return err
+			}
+			continue
+		}
+		if field.Type.Kind() == reflect.Slice && field.Type.Elem().Kind() == reflect.Struct {
+			continue
+		}
+
+		envName := strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+		if err := viper.BindEnv(append([]string{key, envName}, envAliases[key]...)...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// redactedSecret replaces the settings tagged secret in Redacted
+const redactedSecret = "[REDACTED]"
+
+// Redacted returns the configuration keyed as in config.yaml, with the
+// secrets that are set replaced, for showing it to admins
+func (c *Config) Redacted() map[string]interface{} {
+	return redactStruct(reflect.ValueOf(*c))
+}
+
+// redactStruct maps the fields of a config struct to their values
+func redactStruct(v reflect.Value) map[string]interface{} {
+	result := make(map[string]interface{})
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		tag := strings.Split(field.Tag.Get("mapstructure"), ",")[0]
+		if tag == "" || tag == "-" {
+			continue
+		}
+
+		value := v.Field(i)
+		switch {
+		case field.Tag.Get("secret") == "true" && !value.IsZero():
+			result[tag] = redactedSecret
+		case value.Kind() == reflect.Struct:
+			result[tag] = redactStruct(value)
+		case value.Kind() == reflect.Slice && value.Type().Elem().Kind() == reflect.Struct:
+			items := make([]map[string]interface{}, value.Len())
+			for j := range items {
+				items[j] = redactStruct(value.Index(j))
+			}
+			result[tag] = items
+		case value.Type() == reflect.TypeOf(time.Duration(0)):
+			result[tag] = time.Duration(value.Int()).String()
+		default:
+			result[tag] = value.Interface()
+		}
+	}
+	return result
+}
+
 // GetEnabledAgents returns only the enabled agents
 func (c *Config) GetEnabledAgents() []AgentConfig {
 	var enabled []AgentConfig
@@ -95,3 +1150,438 @@ func (c *Config) GetEnabledAgents() []AgentConfig {
 	}
 	return enabled
 }
+
+// Validate checks the configuration for mistakes that would only surface at runtime
+func (c *Config) Validate() []error {
+	var errs []error
+
+	if len(c.Kafka.Brokers) == 0 {
+		errs = append(errs, fmt.Errorf("kafka.brokers must list at least one broker"))
+	}
+	topics := map[string]string{
+		"chat_messages":  c.Kafka.Topics.ChatMessages,
+		"chat_responses": c.Kafka.Topics.ChatResponses,
+		"control":        c.Kafka.Topics.Control,
+		"presence":       c.Kafka.Topics.Presence,
+	}
+	for _, role := range []string{"chat_messages", "chat_responses", "control", "presence"} {
+		if topics[role] == "" {
+			errs = append(errs, fmt.Errorf("kafka.topics.%s must not be empty", role))
+		}
+		if c.Kafka.Topics.Claims != "" && topics[role] == c.Kafka.Topics.Claims {
+			errs = append(errs, fmt.Errorf("kafka.topics.%s must not share the compacted claims topic", role))
+		}
+		if c.Kafka.Topics.DeadLetter != "" && topics[role] == c.Kafka.Topics.DeadLetter {
+			errs = append(errs, fmt.Errorf("kafka.topics.%s must not share the dead-letter topic", role))
+		}
+		if c.Kafka.Topics.AgentMessages != "" && topics[role] == c.Kafka.Topics.AgentMessages {
+			errs = append(errs, fmt.Errorf("kafka.topics.%s must not share the agent messages topic, which is never broadcast", role))
+		}
+		if c.Kafka.Topics.Errors != "" && topics[role] == c.Kafka.Topics.Errors {
+			errs = append(errs, fmt.Errorf("kafka.topics.%s must not share the errors topic, which only admins see", role))
+		}
+	}
+	switch c.Kafka.Serialization.Format {
+	case "json", "":
+	case "avro":
+		if c.Kafka.Serialization.SchemaRegistry.URL == "" {
+			errs = append(errs, fmt.Errorf("kafka.serialization.schema_registry.url is required for the avro format"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("kafka.serialization.format %q is not supported", c.Kafka.Serialization.Format))
+	}
+	if encryption := c.Kafka.Serialization.Encryption; encryption.Enabled && encryption.KeyID == "" {
+		errs = append(errs, fmt.Errorf("kafka.serialization.encryption.key_id is required when encryption is enabled"))
+	}
+	if consumer := c.Kafka.Consumer; consumer.InitialBackoff <= 0 || consumer.MaxBackoff < consumer.InitialBackoff || consumer.RecreateAfter < 1 {
+		errs = append(errs, fmt.Errorf("kafka.consumer.initial_backoff must be positive and no larger than max_backoff, and recreate_after at least 1"))
+	}
+	if c.Kafka.Consumer.HandlerAttempts < 1 {
+		errs = append(errs, fmt.Errorf("kafka.consumer.handler_attempts must be at least 1"))
+	}
+	if c.Agents.ClaimReplies && c.Kafka.Topics.Claims == "" {
+		errs = append(errs, fmt.Errorf("kafka.topics.claims must not be empty when agents.claim_replies is on"))
+	}
+	if c.Web.Port == "" {
+		errs = append(errs, fmt.Errorf("web.port must not be empty"))
+	}
+	if c.Web.BackfillMessages < 0 {
+		errs = append(errs, fmt.Errorf("web.backfill_messages must not be negative"))
+	}
+	for _, proxy := range c.Web.TrustedProxies {
+		if _, _, err := net.ParseCIDR(proxy); err != nil && net.ParseIP(proxy) == nil {
+			errs = append(errs, fmt.Errorf("web.trusted_proxies: %q is not an IP address or CIDR range", proxy))
+		}
+	}
+
+	switch c.Agents.Provider {
+	case "ollama", "ollama-generate", "":
+	case "openai":
+		if c.Agents.LLMAPIKey == "" {
+			errs = append(errs, fmt.Errorf("agents.llm_api_key is required for the openai provider"))
+		}
+	case "replay":
+		if c.Agents.Fixtures.Dir == "" {
+			errs = append(errs, fmt.Errorf("agents.fixtures.dir is required for the replay provider"))
+		}
+		if c.Agents.Fixtures.Record {
+			errs = append(errs, fmt.Errorf("agents.fixtures.record cannot be used with the replay provider"))
+		}
+	case "scripted":
+		if len(c.Agents.Scripted.Responses) == 0 {
+			errs = append(errs, fmt.Errorf("agents.scripted.responses is required for the scripted provider"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("agents.provider %q is not supported", c.Agents.Provider))
+	}
+	for i, rule := range c.Agents.Scripted.Responses {
+		if _, err := regexp.Compile(rule.Match); err != nil {
+			errs = append(errs, fmt.Errorf("agents.scripted.responses[%d].match: %w", i, err))
+		}
+		if len(rule.Responses) == 0 {
+			errs = append(errs, fmt.Errorf("agents.scripted.responses[%d] has no responses", i))
+		}
+		for _, response := range rule.Responses {
+			if _, err := template.New("response").Parse(response); err != nil {
+				errs = append(errs, fmt.Errorf("agents.scripted.responses[%d]: %w", i, err))
+			}
+		}
+	}
+	if _, err := template.New("completion").Parse(c.Agents.Completion.Template); err != nil {
+		errs = append(errs, fmt.Errorf("agents.completion.template: %w", err))
+	}
+	if c.Agents.Fixtures.Record && c.Agents.Fixtures.Dir == "" {
+		errs = append(errs, fmt.Errorf("agents.fixtures.dir is required when recording"))
+	}
+
+	if a := c.Agents.Adaptive; a.Enabled {
+		if a.MinChance < 0 || a.MaxChance > 1 || a.MinChance > a.MaxChance {
+			errs = append(errs, fmt.Errorf("agents.adaptive needs 0 <= min_chance <= max_chance <= 1"))
+		}
+		if a.Step <= 0 || a.Window <= 0 {
+			errs = append(errs, fmt.Errorf("agents.adaptive.step and window must be positive"))
+		}
+	}
+	if r := c.Agents.Repetition; r.Enabled {
+		if r.NGram < 1 || r.Window < 1 {
+			errs = append(errs, fmt.Errorf("agents.repetition.ngram and window must be at least 1"))
+		}
+		if r.MaxOverlap <= 0 || r.MaxOverlap > 1 {
+			errs = append(errs, fmt.Errorf("agents.repetition.max_overlap must be above 0 and at most 1"))
+		}
+		if r.Action != "regenerate" && r.Action != "skip" {
+			errs = append(errs, fmt.Errorf("agents.repetition.action must be \"regenerate\" or \"skip\""))
+		}
+		if r.TemperatureStep < 0 {
+			errs = append(errs, fmt.Errorf("agents.repetition.temperature_step must not be negative"))
+		}
+	}
+	if c.Agents.Identity.Enabled && c.Agents.Identity.MinHistory < 0 {
+		errs = append(errs, fmt.Errorf("agents.identity.min_history must not be negative"))
+	}
+	if !validHistoryRoles(c.Agents.HistoryRoles) {
+		errs = append(errs, fmt.Errorf("agents.history_roles must be \"own\" or \"agents\""))
+	}
+	for i, provider := range c.Agents.Context.Providers {
+		switch provider.Type {
+		case "weather":
+			if provider.Location == "" {
+				errs = append(errs, fmt.Errorf("agents.context.providers[%d]: the weather provider needs a location", i))
+			}
+		case "wikipedia":
+		default:
+			errs = append(errs, fmt.Errorf("agents.context.providers[%d]: type must be \"weather\" or \"wikipedia\"", i))
+		}
+	}
+	if c.Agents.Context.Timeout < 0 || c.Agents.Context.CacheTTL < 0 {
+		errs = append(errs, fmt.Errorf("agents.context.timeout and cache_ttl must not be negative"))
+	}
+	for i, price := range c.Agents.Usage.Prices {
+		if price.Model == "" || price.Input < 0 || price.Output < 0 {
+			errs = append(errs, fmt.Errorf("agents.usage.prices[%d] needs a model and prices that are not negative", i))
+		}
+	}
+	if c.Agents.MessageTimeout < 0 {
+		errs = append(errs, fmt.Errorf("agents.message_timeout must not be negative"))
+	}
+	if sv := c.Agents.Supervisor; sv.Enabled {
+		if sv.Interval <= 0 || sv.StuckAfter <= 0 || sv.Backoff <= 0 {
+			errs = append(errs, fmt.Errorf("agents.supervisor.interval, stuck_after and backoff must be positive"))
+		}
+		if sv.MaxBackoff < sv.Backoff {
+			errs = append(errs, fmt.Errorf("agents.supervisor.max_backoff must be at least backoff"))
+		}
+	}
+	if c.Agents.Clock.Timezone != "" {
+		if _, err := time.LoadLocation(c.Agents.Clock.Timezone); err != nil {
+			errs = append(errs, fmt.Errorf("agents.clock.timezone: %w", err))
+		}
+	}
+	if c.Agents.HTTP.MaxIdleConns < 0 || c.Agents.HTTP.MaxIdleConnsPerHost < 0 || c.Agents.HTTP.MaxConnsPerHost < 0 {
+		errs = append(errs, fmt.Errorf("agents.http connection limits must not be negative"))
+	}
+	if c.Agents.HTTP.MaxConnsPerHost > 0 && c.Agents.HTTP.MaxIdleConnsPerHost > c.Agents.HTTP.MaxConnsPerHost {
+		errs = append(errs, fmt.Errorf("agents.http.max_idle_conns_per_host must not exceed max_conns_per_host"))
+	}
+
+	for _, builtin := range c.Agents.Redaction.Builtins {
+		switch builtin {
+		case "email", "phone", "credit_card":
+		default:
+			errs = append(errs, fmt.Errorf("agents.redaction.builtins: %q is not a built-in rule", builtin))
+		}
+	}
+	for _, pattern := range c.Agents.Redaction.Patterns {
+		if _, err := regexp.Compile(pattern.Pattern); err != nil {
+			errs = append(errs, fmt.Errorf("agents.redaction.patterns: %s: %w", pattern.Name, err))
+		}
+	}
+
+	switch c.Archive.Provider {
+	case "":
+	case "file":
+		if c.Archive.Dir == "" {
+			errs = append(errs, fmt.Errorf("archive.dir is required for the file provider"))
+		}
+	case "s3", "gcs":
+		if c.Archive.Bucket == "" {
+			errs = append(errs, fmt.Errorf("archive.bucket is required for the %s provider", c.Archive.Provider))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("archive.provider %q is not supported", c.Archive.Provider))
+	}
+	if c.Archive.Provider != "" && (c.Archive.Interval <= 0 || c.Archive.IdleAfter <= 0) {
+		errs = append(errs, fmt.Errorf("archive.interval and archive.idle_after must be positive"))
+	}
+
+	if c.Digest.Enabled {
+		if c.Digest.SMTP.Host == "" || c.Digest.SMTP.From == "" {
+			errs = append(errs, fmt.Errorf("digest.smtp.host and digest.smtp.from are required"))
+		}
+		if _, err := time.Parse("15:04", c.Digest.SendAt); err != nil {
+			errs = append(errs, fmt.Errorf("digest.send_at must be a time of day like 08:00"))
+		}
+	}
+
+	if c.Daily.Enabled {
+		if _, err := time.Parse("15:04", c.Daily.StartAt); err != nil {
+			errs = append(errs, fmt.Errorf("daily.start_at must be a time of day like 09:00"))
+		}
+		if c.Daily.IDPrefix == "" {
+			errs = append(errs, fmt.Errorf("daily.id_prefix is required"))
+		}
+		if c.Daily.ArchivePrevious && c.Archive.Provider == "" {
+			errs = append(errs, fmt.Errorf("daily.archive_previous needs archive.provider"))
+		}
+		for _, webhook := range c.Daily.Webhooks {
+			if u, err := url.Parse(webhook); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				errs = append(errs, fmt.Errorf("daily.webhooks must be http or https URLs"))
+				break
+			}
+		}
+	}
+
+	if c.Tasks.Workers < 0 || c.Tasks.MaxAttempts < 1 || c.Tasks.RetryDelay < 0 || c.Tasks.Timeout < 0 {
+		errs = append(errs, fmt.Errorf("tasks.workers, retry_delay and timeout must not be negative and tasks.max_attempts must be at least 1"))
+	}
+
+	if e := c.Embeddings; e.Enabled {
+		if e.Provider != "ollama" && e.Provider != "openai" {
+			errs = append(errs, fmt.Errorf("embeddings.provider must be \"ollama\" or \"openai\""))
+		}
+		if e.Model == "" || e.Interval <= 0 || e.BatchSize < 1 || e.Clusters < 1 || e.MinMessages < 1 {
+			errs = append(errs, fmt.Errorf("embeddings need a model, a positive interval, and a batch_size, clusters and min_messages of at least 1"))
+		}
+	}
+	if c.Evaluation.Enabled && (c.Evaluation.Interval < 0 || c.Evaluation.MinMessages < 1 || c.Evaluation.MaxMessages < c.Evaluation.MinMessages || c.Evaluation.Keep < 1) {
+		errs = append(errs, fmt.Errorf("evaluation.interval must not be negative, evaluation.min_messages and keep must be at least 1 and max_messages at least min_messages"))
+	}
+
+	if m := c.Moderation.API; c.Moderation.Enabled && m.URL != "" {
+		if m.FlagThreshold <= 0 || m.FlagThreshold > m.BlockThreshold || m.BlockThreshold > 1 {
+			errs = append(errs, fmt.Errorf("moderation.api needs 0 < flag_threshold <= block_threshold <= 1"))
+		}
+	}
+
+	if c.Startup.Attempts < 1 {
+		errs = append(errs, fmt.Errorf("startup.attempts must be at least 1"))
+	}
+	if c.Startup.InitialBackoff <= 0 || c.Startup.MaxBackoff < c.Startup.InitialBackoff {
+		errs = append(errs, fmt.Errorf("startup.initial_backoff must be positive and no larger than startup.max_backoff"))
+	}
+
+	if c.Tap.MaxSizeMB < 0 || c.Tap.RotateEvery < 0 || c.Tap.MaxBackups < 0 {
+		errs = append(errs, fmt.Errorf("tap.max_size_mb, tap.rotate_every and tap.max_backups must not be negative"))
+	}
+
+	if c.Agents.Temperature < 0 || c.Agents.Temperature > 2 {
+		errs = append(errs, fmt.Errorf("agents.temperature must be between 0 and 2"))
+	}
+	if c.Agents.DebugCalls < 0 {
+		errs = append(errs, fmt.Errorf("agents.debug_calls must not be negative"))
+	}
+
+	if fetch := c.Agents.Fetch; fetch.MaxBytes < 0 || fetch.MaxChars < 0 || fetch.Timeout < 0 || fetch.CacheTTL < 0 {
+		errs = append(errs, fmt.Errorf("agents.fetch.max_bytes, max_chars, timeout and cache_ttl must not be negative"))
+	}
+
+	if c.Storage.AgentStateInterval < 0 {
+		errs = append(errs, fmt.Errorf("storage.agent_state_interval must not be negative"))
+	}
+
+	if c.Conversation.Debate.Rounds < 0 || c.Conversation.Debate.WordLimit < 0 || c.Conversation.Debate.TurnTimeout < 0 {
+		errs = append(errs, fmt.Errorf("conversation.debate.rounds, word_limit and turn_timeout must not be negative"))
+	}
+	prompts := c.Conversation.Debate.Prompts
+	for _, prompt := range []struct{ name, text string }{
+		{"opening", prompts.Opening}, {"rebuttal", prompts.Rebuttal}, {"closing", prompts.Closing}, {"verdict", prompts.Verdict},
+	} {
+		if _, err := template.New(prompt.name).Parse(prompt.text); err != nil {
+			errs = append(errs, fmt.Errorf("conversation.debate.prompts.%s: %w", prompt.name, err))
+		}
+	}
+
+	for i, lesson := range c.Conversation.Tutoring.Syllabus {
+		if lesson.Topic == "" {
+			errs = append(errs, fmt.Errorf("conversation.tutoring.syllabus[%d] is missing a topic", i))
+		}
+	}
+
+	if c.Conversation.Revival.IdleAfter < 0 {
+		errs = append(errs, fmt.Errorf("conversation.revival.idle_after must not be negative"))
+	}
+	if guard := c.Conversation.DepthGuard; guard.MaxMessages < 0 || guard.MaxDuration < 0 || guard.ClosingTimeout < 0 {
+		errs = append(errs, fmt.Errorf("conversation.depth_guard.max_messages, max_duration and closing_timeout must not be negative"))
+	}
+
+	phases := make(map[string]bool)
+	for i, phase := range c.Conversation.Phases {
+		if phase.Name == "" {
+			errs = append(errs, fmt.Errorf("conversation.phases[%d] is missing a name", i))
+		} else if phases[phase.Name] {
+			errs = append(errs, fmt.Errorf("phase %q is defined more than once", phase.Name))
+		}
+		phases[phase.Name] = true
+		if phase.Messages < 0 || phase.Duration < 0 {
+			errs = append(errs, fmt.Errorf("phase %s: messages and duration must not be negative", phase.Name))
+		}
+	}
+
+	pluginTypes := make(map[string]bool)
+	for i, plugin := range c.Agents.Plugins {
+		if plugin.Type == "" || plugin.Path == "" {
+			errs = append(errs, fmt.Errorf("agents.plugins[%d] needs a type and a path", i))
+			continue
+		}
+		if pluginTypes[plugin.Type] {
+			errs = append(errs, fmt.Errorf("plugin type %q is registered more than once", plugin.Type))
+		}
+		pluginTypes[plugin.Type] = true
+	}
+
+	seen := make(map[string]bool)
+	for i, agent := range c.Agents.Agents {
+		if agent.ID == "" {
+			errs = append(errs, fmt.Errorf("agents.agents[%d] is missing an id", i))
+			continue
+		}
+		if seen[agent.ID] {
+			errs = append(errs, fmt.Errorf("agent id %q is used more than once", agent.ID))
+		}
+		seen[agent.ID] = true
+
+		errs = append(errs, agent.Validate()...)
+	}
+
+	errs = append(errs, c.validateTenants()...)
+
+	return errs
+}
+
+// ForTenant returns the configuration of one tenant: its topics, consumer
+// groups, storage, archive, tap and audit files are namespaced by the tenant,
+// and its agents replace the shared ones when it has any
+func (c *Config) ForTenant(tenant TenantConfig) *Config {
+	cfg := *c
+	cfg.Tenants = nil
+
+	prefix := tenant.TopicPrefix
+	if prefix == "" {
+		prefix = tenant.ID + "."
+	}
+	topics := &cfg.Kafka.Topics
+	for _, topic := range []*string{&topics.ChatMessages, &topics.ChatResponses, &topics.Control, &topics.Presence, &topics.Claims, &topics.DeadLetter, &topics.AgentMessages, &topics.Errors} {
+		if *topic != "" {
+			*topic = prefix + *topic
+		}
+	}
+	cfg.Kafka.GroupPrefix = prefix + cfg.Kafka.GroupPrefix
+
+	if len(tenant.Agents) > 0 {
+		cfg.Agents.Agents = tenant.Agents
+	}
+	if cfg.Storage.Dir != "" {
+		cfg.Storage.Dir = filepath.Join(cfg.Storage.Dir, tenant.ID)
+	}
+	if cfg.Archive.Dir != "" {
+		cfg.Archive.Dir = filepath.Join(cfg.Archive.Dir, tenant.ID)
+	}
+	cfg.Archive.Prefix += tenant.ID + "/"
+	cfg.Tap.File = tenantFile(cfg.Tap.File, tenant.ID)
+	cfg.Audit.File = tenantFile(cfg.Audit.File, tenant.ID)
+	return &cfg
+}
+
+// tenantFile inserts the tenant ID before the extension of a file name, e.g. tap.jsonl -> tap.acme.jsonl
+func tenantFile(path, tenantID string) string {
+	if path == "" {
+		return ""
+	}
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "." + tenantID + ext
+}
+
+// validateTenants checks that tenants have unique IDs and API keys and valid agents
+func (c *Config) validateTenants() []error {
+	var errs []error
+	ids := make(map[string]bool)
+	keys := make(map[string]string)
+	for i, tenant := range c.Tenants {
+		if tenant.ID == "" {
+			errs = append(errs, fmt.Errorf("tenants[%d] is missing an id", i))
+			continue
+		}
+		if ids[tenant.ID] {
+			errs = append(errs, fmt.Errorf("tenant id %q is used more than once", tenant.ID))
+		}
+		ids[tenant.ID] = true
+
+		if len(tenant.APIKeys) == 0 {
+			errs = append(errs, fmt.Errorf("tenant %s needs at least one api key", tenant.ID))
+		}
+		for _, key := range tenant.APIKeys {
+			if key == "" {
+				errs = append(errs, fmt.Errorf("tenant %s has an empty api key", tenant.ID))
+			} else if owner, taken := keys[key]; taken {
+				errs = append(errs, fmt.Errorf("tenant %s shares an api key with tenant %s", tenant.ID, owner))
+			}
+			keys[key] = tenant.ID
+		}
+
+		agents := make(map[string]bool)
+		for j, agent := range tenant.Agents {
+			if agent.ID == "" {
+				errs = append(errs, fmt.Errorf("tenants[%d].agents[%d] is missing an id", i, j))
+				continue
+			}
+			if agents[agent.ID] {
+				errs = append(errs, fmt.Errorf("tenant %s: agent id %q is used more than once", tenant.ID, agent.ID))
+			}
+			agents[agent.ID] = true
+			for _, err := range agent.Validate() {
+				errs = append(errs, fmt.Errorf("tenant %s: %w", tenant.ID, err))
+			}
+		}
+	}
+	return errs
+}
@@ -0,0 +1,64 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestForTenant(t *testing.T) {
+	base := &Config{Tenants: []TenantConfig{{ID: "acme"}}}
+	topics := reflect.ValueOf(&base.Kafka.Topics).Elem()
+	for i := 0; i < topics.NumField(); i++ {
+		topics.Field(i).SetString("topic-" + topics.Type().Field(i).Name)
+	}
+	base.Kafka.GroupPrefix = "philoking-"
+	base.Storage.Dir = "data"
+	base.Tap.File = "logs/tap.jsonl"
+	base.Audit.File = "audit"
+
+	tests := []struct {
+		name   string
+		tenant TenantConfig
+		prefix string
+	}{
+		{name: "default prefix", tenant: TenantConfig{ID: "acme"}, prefix: "acme."},
+		{name: "own prefix", tenant: TenantConfig{ID: "acme", TopicPrefix: "a-"}, prefix: "a-"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := base.ForTenant(tt.tenant)
+
+			// Every topic is the tenant's own, so no events are shared between tenants
+			got := reflect.ValueOf(cfg.Kafka.Topics)
+			for i := 0; i < got.NumField(); i++ {
+				name := got.Type().Field(i).Name
+				if want := tt.prefix + "topic-" + name; got.Field(i).String() != want {
+					t.Errorf("topic %s = %q, want %q", name, got.Field(i).String(), want)
+				}
+			}
+			if cfg.Kafka.Topics.Errors != tt.prefix+"topic-Errors" {
+				t.Errorf("errors topic %q is shared between tenants", cfg.Kafka.Topics.Errors)
+			}
+			if cfg.Kafka.GroupPrefix != tt.prefix+"philoking-" {
+				t.Errorf("group prefix %q", cfg.Kafka.GroupPrefix)
+			}
+			if cfg.Storage.Dir != "data/acme" || cfg.Tap.File != "logs/tap.acme.jsonl" || cfg.Audit.File != "audit.acme" || cfg.Archive.Prefix != "acme/" {
+				t.Errorf("files %q, %q, %q, %q", cfg.Storage.Dir, cfg.Tap.File, cfg.Audit.File, cfg.Archive.Prefix)
+			}
+			if len(cfg.Tenants) != 0 {
+				t.Error("the tenant's configuration lists tenants")
+			}
+		})
+	}
+
+	if base.Kafka.Topics.Errors != "topic-Errors" || base.Storage.Dir != "data" {
+		t.Error("ForTenant changed the shared configuration")
+	}
+}
+
+func TestForTenantLeavesDisabledTopicsEmpty(t *testing.T) {
+	cfg := (&Config{}).ForTenant(TenantConfig{ID: "acme"})
+	if cfg.Kafka.Topics.Errors != "" || cfg.Kafka.Topics.DeadLetter != "" {
+		t.Errorf("disabled topics were enabled: %+v", cfg.Kafka.Topics)
+	}
+}
@@ -0,0 +1,172 @@
+package conversation
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"philoking/internal/types"
+)
+
+// analyticsBucket is the resolution at which message volume is recorded
+const analyticsBucket = time.Minute
+
+// Analytics is a dashboard-ready view of a conversation's activity
+type Analytics struct {
+	ConversationID           string              `json:"conversation_id"`
+	TotalMessages            int                 `json:"total_messages"`
+	Volume                   []VolumeBucket      `json:"volume"`
+	Participants             []*ParticipantShare `json:"participants"`
+	TopicTimeline            []TimelineEntry     `json:"topic_timeline"`
+	MoodTimeline             []TimelineEntry     `json:"mood_timeline"`
+	AverageResponseLatencyMs float64             `json:"average_response_latency_ms"` // From a user message to the first agent reply
+	// Clusters are the topics that emerged from the messages' embeddings, when enabled
+	Clusters        []Cluster       `json:"clusters,omitempty"`
+	ClusterTimeline []TimelineEntry `json:"cluster_timeline,omitempty"`
+}
+
+// VolumeBucket counts the messages sent in a time window
+type VolumeBucket struct {
+	Start time.Time `json:"start"`
+	Count int       `json:"count"`
+}
+
+// ParticipantShare describes how much a participant contributed to the conversation
+type ParticipantShare struct {
+	ID         string  `json:"id"`
+	Name       string  `json:"name"`
+	Messages   int     `json:"messages"`
+	Characters int     `json:"characters"`
+	Share      float64 `json:"share"` // Fraction of all characters written
+}
+
+// TimelineEntry records when a topic or mood value took effect
+type TimelineEntry struct {
+	Value     string    `json:"value"`
+	MessageID string    `json:"message_id"`
+	At        time.Time `json:"at"`
+}
+
+// analyticsState accumulates analytics for one conversation as messages arrive
+type analyticsState struct {
+	total           int
+	totalCharacters int
+	volume          map[time.Time]int
+	participants    map[string]*ParticipantShare
+	pendingUserAt   time.Time // Time of the last unanswered user message
+	latencyTotal    time.Duration
+	latencySamples  int
+}
+
+// analyticsTracker holds the analytics state of all conversations
+type analyticsTracker struct {
+	states map[string]*analyticsState
+	mu     sync.Mutex
+}
+
+func newAnalyticsTracker() *analyticsTracker {
+	return &analyticsTracker{
+		states: make(map[string]*analyticsState),
+	}
+}
+
+// record updates the analytics of a conversation with a new message
+func (t *analyticsTracker) record(conversationID string, message *types.ChatMessage, participantID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	state, exists := t.states[conversationID]
+	if !exists {
+		state = &analyticsState{
+			volume:       make(map[time.Time]int),
+			participants: make(map[string]*ParticipantShare),
+		}
+		t.states[conversationID] = state
+	}
+
+	at := message.Timestamp
+	if at.IsZero() {
+		at = time.Now()
+	}
+
+	state.total++
+	state.totalCharacters += len(message.Content)
+	state.volume[at.Truncate(analyticsBucket)]++
+
+	share, exists := state.participants[participantID]
+	if !exists {
+		share = &ParticipantShare{ID: participantID}
+		state.participants[participantID] = share
+	}
+	share.Name = message.Metadata.FromAgent
+	share.Messages++
+	share.Characters += len(message.Content)
+
+	switch message.Type {
+	case types.MessageTypeUser:
+		if state.pendingUserAt.IsZero() {
+			state.pendingUserAt = at
+		}
+	case types.MessageTypeAgent:
+		if !state.pendingUserAt.IsZero() {
+			state.latencyTotal += at.Sub(state.pendingUserAt)
+			state.latencySamples++
+			state.pendingUserAt = time.Time{}
+		}
+	}
+}
+
+// snapshot builds the analytics view of a conversation, aggregating volume
+// into buckets of the given size; topic and mood timelines are filled in by
+// the FlowManager from the conversation timeline
+func (t *analyticsTracker) snapshot(conversationID string, bucket time.Duration) *Analytics {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	analytics := &Analytics{
+		ConversationID: conversationID,
+		Volume:         []VolumeBucket{},
+		Participants:   []*ParticipantShare{},
+		TopicTimeline:  []TimelineEntry{},
+		MoodTimeline:   []TimelineEntry{},
+	}
+
+	state, exists := t.states[conversationID]
+	if !exists {
+		return analytics
+	}
+
+	if bucket < analyticsBucket {
+		bucket = analyticsBucket
+	}
+
+	analytics.TotalMessages = state.total
+
+	volume := make(map[time.Time]int)
+	for start, count := range state.volume {
+		volume[start.Truncate(bucket)] += count
+	}
+	for start, count := range volume {
+		analytics.Volume = append(analytics.Volume, VolumeBucket{Start: start, Count: count})
+	}
+	sort.Slice(analytics.Volume, func(i, j int) bool {
+		return analytics.Volume[i].Start.Before(analytics.Volume[j].Start)
+	})
+
+	for _, share := range state.participants {
+		cp := *share
+		if state.totalCharacters > 0 {
+			cp.Share = float64(cp.Characters) / float64(state.totalCharacters)
+		}
+		analytics.Participants = append(analytics.Participants, &cp)
+	}
+	sort.Slice(analytics.Participants, func(i, j int) bool {
+		return analytics.Participants[i].Characters > analytics.Participants[j].Characters
+	})
+
+	if state.latencySamples > 0 {
+		analytics.AverageResponseLatencyMs = float64(state.latencyTotal.Milliseconds()) / float64(state.latencySamples)
+	}
+
+	return analytics
+}
@@ -0,0 +1,40 @@
+package conversation
+
+import "philoking/internal/types"
+
+// CitableConversations returns the conversations held in memory, other than
+// except, whose messages an agent may cite: those it took part in as a
+// member or by sending a message, unless they are private
+func (m *Manager) CitableConversations(agentID, except string) []string {
+	var citable []string
+	for _, id := range m.ListConversations() {
+		if id == except {
+			continue
+		}
+		if conv, exists := m.lookup(id); exists && conv.citableBy(agentID) {
+			citable = append(citable, id)
+		}
+	}
+	return citable
+}
+
+// citableBy reports whether an agent may cite the conversation elsewhere
+func (conv *Conversation) citableBy(agentID string) bool {
+	conv.mu.RLock()
+	defer conv.mu.RUnlock()
+
+	if conv.Private {
+		return false
+	}
+	for _, member := range conv.Members {
+		if member == agentID {
+			return true
+		}
+	}
+	for _, message := range conv.Messages {
+		if message.Type == types.MessageTypeAgent && message.AgentID == agentID {
+			return true
+		}
+	}
+	return false
+}
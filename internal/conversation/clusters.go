@@ -0,0 +1,74 @@
+package conversation
+
+import "time"
+
+// Cluster is a topic that emerged from a conversation: messages whose
+// embeddings lie close together, labeled by their most distinctive words
+type Cluster struct {
+	Label    string   `json:"label"`
+	Keywords []string `json:"keywords"`
+	Messages int      `json:"messages"`
+}
+
+// Clustering groups the messages of a conversation into emergent topics
+type Clustering struct {
+	Clusters []Cluster `json:"clusters"`
+	// Timeline records when the discussion moved to another cluster
+	Timeline  []TimelineEntry `json:"timeline"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+// copy returns a deep copy of the clustering, or nil
+func (c *Clustering) copy() *Clustering {
+	if c == nil {
+		return nil
+	}
+	cp := *c
+	cp.Clusters = make([]Cluster, len(c.Clusters))
+	for i, cluster := range c.Clusters {
+		cluster.Keywords = append([]string(nil), cluster.Keywords...)
+		cp.Clusters[i] = cluster
+	}
+	cp.Timeline = append([]TimelineEntry(nil), c.Timeline...)
+	return &cp
+}
+
+// SetClusters stores the emergent topics of a conversation held in memory.
+// assignments maps message IDs to an index in clusters; the timeline follows
+// the assigned messages in order and records each change of cluster.
+func (m *Manager) SetClusters(conversationID string, clusters []Cluster, assignments map[string]int) {
+	conv, exists := m.lookup(conversationID)
+	if !exists {
+		return
+	}
+
+	conv.mu.Lock()
+	defer conv.mu.Unlock()
+
+	clustering := &Clustering{Clusters: clusters, Timeline: []TimelineEntry{}, UpdatedAt: time.Now()}
+	for _, message := range conv.Messages {
+		index, assigned := assignments[message.ID]
+		if !assigned || index < 0 || index >= len(clusters) {
+			continue
+		}
+		label := clusters[index].Label
+		if timeline := clustering.Timeline; len(timeline) > 0 && timeline[len(timeline)-1].Value == label {
+			continue
+		}
+		clustering.Timeline = append(clustering.Timeline, TimelineEntry{Value: label, MessageID: message.ID, At: message.Timestamp})
+	}
+	conv.Clustering = clustering
+}
+
+// GetClusters returns a copy of the emergent topics of a conversation, or nil
+// when it was not clustered yet
+func (m *Manager) GetClusters(conversationID string) *Clustering {
+	conv, exists := m.lookup(conversationID)
+	if !exists {
+		return nil
+	}
+
+	conv.mu.RLock()
+	defer conv.mu.RUnlock()
+	return conv.Clustering.copy()
+}
@@ -0,0 +1,496 @@
+package conversation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"philoking/internal/types"
+)
+
+// DebateTag marks the moderator's debate announcements and prompts
+const DebateTag = "debate"
+
+// DebateWordLimitKey is the custom metadata key of a debate prompt's word limit
+const DebateWordLimitKey = "word_limit"
+
+// ErrDebateActive is returned when starting a debate while another one runs
+var ErrDebateActive = errors.New("a debate is already running in this conversation")
+
+// DebateStage is a part of a debate
+type DebateStage string
+
+const (
+	DebateOpening  DebateStage = "opening"
+	DebateRebuttal DebateStage = "rebuttal"
+	DebateClosing  DebateStage = "closing"
+	DebateVerdict  DebateStage = "verdict"
+	DebateFinished DebateStage = "finished"
+)
+
+// Default prompts of the moderator, see config.DebatePrompts
+const (
+	defaultOpeningPrompt  = `{{.Speaker}}, you argue {{.Stance}} the proposition "{{.Proposition}}". Give your opening statement in at most {{.WordLimit}} words.`
+	defaultRebuttalPrompt = `Rebuttal round {{.Round}} of {{.Rounds}}. {{.Speaker}}, you argue {{.Stance}} "{{.Proposition}}". Rebut {{.Previous}}'s last argument in at most {{.WordLimit}} words.`
+	defaultClosingPrompt  = `Closing statements. {{.Speaker}}, sum up your case {{.Stance}} "{{.Proposition}}" in at most {{.WordLimit}} words.`
+	defaultVerdictPrompt  = `{{.Speaker}}, the debate on "{{.Proposition}}" is over. For: {{.For}}. Against: {{.Against}}. Weigh the arguments of both sides and declare the winner, with a short justification.`
+)
+
+// DebateTurn is one statement in a debate
+type DebateTurn struct {
+	Stage     DebateStage `json:"stage"`
+	AgentID   string      `json:"agent_id"`
+	Round     int         `json:"round,omitempty"`      // Rebuttal round, from 1
+	MessageID string      `json:"message_id,omitempty"` // The statement, once made
+	Skipped   bool        `json:"skipped,omitempty"`    // The speaker let the turn time out
+}
+
+// Debate is a structured debate on a proposition: opening statements,
+// alternating rebuttals and closing statements, then a judge's verdict. Only
+// the agent the moderator gives the floor speaks.
+type Debate struct {
+	Proposition   string       `json:"proposition"`
+	For           []string     `json:"for"` // Agent IDs
+	Against       []string     `json:"against"`
+	Judge         string       `json:"judge,omitempty"`
+	Rounds        int          `json:"rounds"`
+	WordLimit     int          `json:"word_limit"`
+	Turns         []DebateTurn `json:"turns"`
+	Turn          int          `json:"turn"` // Index of the current turn; len(Turns) once finished
+	Verdict       string       `json:"verdict,omitempty"`
+	StartedAt     time.Time    `json:"started_at"`
+	TurnStartedAt time.Time    `json:"turn_started_at"`
+}
+
+// Finished reports whether the debate is over
+func (d *Debate) Finished() bool {
+	return d.Turn >= len(d.Turns)
+}
+
+// Stage returns the stage of the current turn
+func (d *Debate) Stage() DebateStage {
+	if d.Finished() {
+		return DebateFinished
+	}
+	return d.Turns[d.Turn].Stage
+}
+
+// Speaker returns the agent holding the floor, or "" once the debate is over
+func (d *Debate) Speaker() string {
+	if d.Finished() {
+		return ""
+	}
+	return d.Turns[d.Turn].AgentID
+}
+
+// StanceOf returns "for" or "against" for a debater, or "" for anybody else
+func (d *Debate) StanceOf(agentID string) string {
+	for _, id := range d.For {
+		if id == agentID {
+			return "for"
+		}
+	}
+	for _, id := range d.Against {
+		if id == agentID {
+			return "against"
+		}
+	}
+	return ""
+}
+
+// ShouldRespond reports whether an agent must answer a message during the
+// debate: only the speaker answers, and only the moderator's prompt
+func (d *Debate) ShouldRespond(agentID string, message *types.ChatMessage) bool {
+	return message.Type == types.MessageTypeSystem &&
+		message.Metadata.ReplyTo == agentID &&
+		d.Speaker() == agentID &&
+		isTagged(message, DebateTag)
+}
+
+// copy returns a deep copy of the debate
+func (d *Debate) copy() *Debate {
+	cp := *d
+	cp.For = append([]string(nil), d.For...)
+	cp.Against = append([]string(nil), d.Against...)
+	cp.Turns = append([]DebateTurn(nil), d.Turns...)
+	return &cp
+}
+
+// debateTurns lays out the turns of a debate; the sides take turns, starting with "for"
+func debateTurns(forIDs, againstIDs []string, judge string, rounds int) []DebateTurn {
+	var order []string
+	for i := 0; i < len(forIDs) || i < len(againstIDs); i++ {
+		if i < len(forIDs) {
+			order = append(order, forIDs[i])
+		}
+		if i < len(againstIDs) {
+			order = append(order, againstIDs[i])
+		}
+	}
+
+	var turns []DebateTurn
+	for _, id := range order {
+		turns = append(turns, DebateTurn{Stage: DebateOpening, AgentID: id})
+	}
+	for round := 1; round <= rounds; round++ {
+		for _, id := range order {
+			turns = append(turns, DebateTurn{Stage: DebateRebuttal, AgentID: id, Round: round})
+		}
+	}
+	for _, id := range order {
+		turns = append(turns, DebateTurn{Stage: DebateClosing, AgentID: id})
+	}
+	if judge != "" {
+		turns = append(turns, DebateTurn{Stage: DebateVerdict, AgentID: judge})
+	}
+	return turns
+}
+
+// GetDebate returns a copy of the debate of a conversation, or nil
+func (m *Manager) GetDebate(conversationID string) *Debate {
+	conv := m.GetOrCreateConversation(conversationID)
+
+	conv.mu.RLock()
+	defer conv.mu.RUnlock()
+
+	if conv.Debate == nil {
+		return nil
+	}
+	return conv.Debate.copy()
+}
+
+// GetActiveDebate returns a copy of the debate of a conversation while it runs, or nil
+func (m *Manager) GetActiveDebate(conversationID string) *Debate {
+	if debate := m.GetDebate(conversationID); debate != nil && !debate.Finished() {
+		return debate
+	}
+	return nil
+}
+
+// startDebate puts a conversation into a debate, unless one is running
+func (m *Manager) startDebate(conversationID string, debate *Debate) error {
+	conv := m.GetOrCreateConversation(conversationID)
+
+	conv.mu.Lock()
+	defer conv.mu.Unlock()
+
+	if conv.Debate != nil && !conv.Debate.Finished() {
+		return ErrDebateActive
+	}
+	conv.Debate = debate
+	return nil
+}
+
+// advanceDebate ends turn `expected` with the speaker's statement, or as
+// skipped when statement is nil, and returns the updated copy. Timers and
+// statements race to end a turn and only one of them may.
+func (m *Manager) advanceDebate(conversationID string, expected int, statement *types.ChatMessage) *Debate {
+	conv := m.GetOrCreateConversation(conversationID)
+
+	conv.mu.Lock()
+	defer conv.mu.Unlock()
+
+	debate := conv.Debate
+	if debate == nil || debate.Finished() || debate.Turn != expected {
+		return nil
+	}
+
+	turn := &debate.Turns[debate.Turn]
+	if statement == nil {
+		turn.Skipped = true
+	} else {
+		turn.MessageID = statement.ID
+		if turn.Stage == DebateVerdict {
+			debate.Verdict = statement.Content
+		}
+	}
+	debate.Turn++
+	debate.TurnStartedAt = time.Now()
+	return debate.copy()
+}
+
+// stopDebate ends a running debate early
+func (m *Manager) stopDebate(conversationID string) bool {
+	conv := m.GetOrCreateConversation(conversationID)
+
+	conv.mu.Lock()
+	defer conv.mu.Unlock()
+
+	if conv.Debate == nil || conv.Debate.Finished() {
+		return false
+	}
+	conv.Debate.Turn = len(conv.Debate.Turns)
+	return true
+}
+
+// StartDebate starts a debate on a proposition between agents arguing for and
+// against it. Rounds, word limit and judge default to the configuration when
+// zero or empty.
+func (f *FlowManager) StartDebate(ctx context.Context, conversationID, proposition string, forIDs, againstIDs []string, judge string, rounds, wordLimit int) (*Debate, error) {
+	if proposition == "" {
+		return nil, fmt.Errorf("a debate needs a proposition")
+	}
+	if len(forIDs) == 0 || len(againstIDs) == 0 {
+		return nil, fmt.Errorf("a debate needs at least one agent on each side")
+	}
+	sides := make(map[string]bool)
+	for _, id := range append(append([]string(nil), forIDs...), againstIDs...) {
+		if participant, exists := f.participant(id); !exists || participant.Type != "agent" {
+			return nil, fmt.Errorf("unknown agent %q", id)
+		}
+		if sides[id] {
+			return nil, fmt.Errorf("agent %q can only argue once", id)
+		}
+		sides[id] = true
+	}
+
+	cfg := f.config.Debate
+	if rounds <= 0 {
+		rounds = cfg.Rounds
+	}
+	if wordLimit <= 0 {
+		wordLimit = cfg.WordLimit
+	}
+	if judge == "" {
+		judge = cfg.Judge
+	}
+	if judge != "" {
+		if participant, exists := f.participant(judge); !exists || participant.Type != "agent" {
+			return nil, fmt.Errorf("unknown judge %q", judge)
+		}
+		if sides[judge] {
+			return nil, fmt.Errorf("agent %q cannot both argue and judge", judge)
+		}
+	}
+
+	now := time.Now()
+	debate := &Debate{
+		Proposition:   proposition,
+		For:           append([]string(nil), forIDs...),
+		Against:       append([]string(nil), againstIDs...),
+		Judge:         judge,
+		Rounds:        rounds,
+		WordLimit:     wordLimit,
+		Turns:         debateTurns(forIDs, againstIDs, judge, rounds),
+		StartedAt:     now,
+		TurnStartedAt: now,
+	}
+	if err := f.conversationManager.startDebate(conversationID, debate); err != nil {
+		return nil, err
+	}
+
+	announcement := fmt.Sprintf("⚖️ Debate: \"%s\". For: %s. Against: %s.", proposition, f.participantNames(forIDs), f.participantNames(againstIDs))
+	if judge != "" {
+		announcement += fmt.Sprintf(" Judge: %s.", f.participantName(judge))
+	}
+	announcement += fmt.Sprintf(" Opening statements, %d rebuttal round(s) and closing statements of at most %d words each.", rounds, wordLimit)
+	message := f.newSystemMessage(announcement, conversationID)
+	message.Metadata.Tags = []string{DebateTag}
+	if err := f.publisher.PublishMessage(ctx, message); err != nil {
+		log.Printf("Failed to announce debate: %v", err)
+	}
+
+	log.Printf("Started debate in conversation %s on: %s", conversationID, proposition)
+	f.promptDebateTurn(ctx, conversationID, debate.copy())
+	return debate.copy(), nil
+}
+
+// StopDebate ends a running debate without a verdict
+func (f *FlowManager) StopDebate(ctx context.Context, conversationID string) error {
+	if !f.conversationManager.stopDebate(conversationID) {
+		return fmt.Errorf("no debate is running")
+	}
+
+	message := f.newSystemMessage("🛑 The debate was stopped. The floor is open to everyone again.", conversationID)
+	message.Metadata.Tags = []string{DebateTag}
+	if err := f.publisher.PublishMessage(ctx, message); err != nil {
+		log.Printf("Failed to announce the end of the debate: %v", err)
+	}
+	log.Printf("Stopped debate in conversation %s", conversationID)
+	return nil
+}
+
+// promptDebateTurn gives the floor to the speaker of the current turn, or
+// closes the debate once all turns are done
+func (f *FlowManager) promptDebateTurn(ctx context.Context, conversationID string, debate *Debate) {
+	if debate.Finished() {
+		closing := fmt.Sprintf("🏁 The debate on \"%s\" is over.", debate.Proposition)
+		if debate.Judge == "" {
+			closing += " There is no judge; the audience decides."
+		}
+		message := f.newSystemMessage(closing+" The floor is open to everyone again.", conversationID)
+		message.Metadata.Tags = []string{DebateTag}
+		if err := f.publisher.PublishMessage(ctx, message); err != nil {
+			log.Printf("Failed to announce the end of the debate: %v", err)
+		}
+		log.Printf("Debate in conversation %s finished", conversationID)
+		return
+	}
+
+	turn := debate.Turns[debate.Turn]
+	content, err := f.debatePrompt(debate)
+	if err != nil {
+		log.Printf("Failed to render debate prompt: %v", err)
+		content = fmt.Sprintf("%s, it is your turn (%s) in the debate on \"%s\".", f.participantName(turn.AgentID), turn.Stage, debate.Proposition)
+	}
+
+	prompt := f.newSystemMessage(content, conversationID)
+	prompt.Metadata.ReplyTo = turn.AgentID
+	prompt.Metadata.Tags = []string{DebateTag}
+	prompt.Metadata.Custom = map[string]string{"debate_stage": string(turn.Stage)}
+	if turn.Stage != DebateVerdict && debate.WordLimit > 0 {
+		prompt.Metadata.Custom[DebateWordLimitKey] = strconv.Itoa(debate.WordLimit)
+	}
+	if err := f.publisher.PublishMessage(ctx, prompt); err != nil {
+		log.Printf("Failed to prompt debate turn: %v", err)
+	}
+
+	if timeout := f.config.Debate.TurnTimeout; timeout > 0 {
+		index, started := debate.Turn, debate.TurnStartedAt
+		time.AfterFunc(timeout, func() {
+			// The turn may have been taken, or the debate restarted, in the meantime
+			current := f.conversationManager.GetActiveDebate(conversationID)
+			if current == nil || current.Turn != index || !current.TurnStartedAt.Equal(started) {
+				return
+			}
+			if next := f.conversationManager.advanceDebate(conversationID, index, nil); next != nil {
+				notice := f.newSystemMessage(fmt.Sprintf("⏱️ %s let the turn pass.", f.participantName(turn.AgentID)), conversationID)
+				notice.Metadata.Tags = []string{DebateTag}
+				if err := f.publisher.PublishMessage(f.ctx, notice); err != nil {
+					log.Printf("Failed to announce skipped debate turn: %v", err)
+				}
+				f.promptDebateTurn(f.ctx, conversationID, next)
+			}
+		})
+	}
+}
+
+// debatePrompt renders the moderator's prompt for the current turn
+func (f *FlowManager) debatePrompt(debate *Debate) (string, error) {
+	turn := debate.Turns[debate.Turn]
+	prompts := f.config.Debate.Prompts
+
+	text := map[DebateStage]string{
+		DebateOpening:  firstNonEmpty(prompts.Opening, defaultOpeningPrompt),
+		DebateRebuttal: firstNonEmpty(prompts.Rebuttal, defaultRebuttalPrompt),
+		DebateClosing:  firstNonEmpty(prompts.Closing, defaultClosingPrompt),
+		DebateVerdict:  firstNonEmpty(prompts.Verdict, defaultVerdictPrompt),
+	}[turn.Stage]
+	tmpl, err := template.New(string(turn.Stage)).Parse(text)
+	if err != nil {
+		return "", err
+	}
+
+	stance := debate.StanceOf(turn.AgentID)
+	opponents := debate.Against
+	if stance == "against" {
+		opponents = debate.For
+	}
+	previous := ""
+	if debate.Turn > 0 {
+		previous = f.participantName(debate.Turns[debate.Turn-1].AgentID)
+	}
+
+	var b strings.Builder
+	err = tmpl.Execute(&b, struct {
+		Proposition, Speaker, Stance, Opponents, Previous, For, Against string
+		WordLimit, Round, Rounds                                        int
+	}{
+		Proposition: debate.Proposition,
+		Speaker:     f.participantName(turn.AgentID),
+		Stance:      stance,
+		Opponents:   f.participantNames(opponents),
+		Previous:    previous,
+		For:         f.participantNames(debate.For),
+		Against:     f.participantNames(debate.Against),
+		WordLimit:   debate.WordLimit,
+		Round:       turn.Round,
+		Rounds:      debate.Rounds,
+	})
+	return b.String(), err
+}
+
+// handleDebateMessage ends the speaker's turn when they make their statement
+// and gives the floor to the next one
+func (f *FlowManager) handleDebateMessage(ctx context.Context, message *types.ChatMessage, conversationID string) {
+	debate := f.conversationManager.GetActiveDebate(conversationID)
+	if debate == nil || message.AgentID != debate.Speaker() {
+		return
+	}
+
+	stage := debate.Stage()
+	if words := len(strings.Fields(message.Content)); stage != DebateVerdict && debate.WordLimit > 0 && words > debate.WordLimit {
+		notice := f.newNotice(fmt.Sprintf("✂️ %s went over the %d-word limit (%d words).", f.participantName(message.AgentID), debate.WordLimit, words), conversationID)
+		notice.Metadata.Tags = []string{DebateTag}
+		if err := f.publisher.PublishMessage(ctx, notice); err != nil {
+			log.Printf("Failed to publish word limit notice: %v", err)
+		}
+	}
+
+	if next := f.conversationManager.advanceDebate(conversationID, debate.Turn, message); next != nil {
+		f.promptDebateTurn(ctx, conversationID, next)
+	}
+}
+
+// handleDebateCommand handles "/debate", "/debate stop" and
+// "/debate <agent for> <agent against> <proposition>"
+func (f *FlowManager) handleDebateCommand(ctx context.Context, message *types.ChatMessage, conversationID string) {
+	command := message.Metadata.Command
+
+	switch {
+	case len(command.Args) == 0:
+		status := "No debate is running. Use /debate <agent for> <agent against> <proposition> to start one."
+		if debate := f.conversationManager.GetActiveDebate(conversationID); debate != nil {
+			status = fmt.Sprintf("⚖️ Debate on \"%s\": %s stage, %s has the floor (turn %d of %d).",
+				debate.Proposition, debate.Stage(), f.participantName(debate.Speaker()), debate.Turn+1, len(debate.Turns))
+		}
+		if err := f.publisher.PublishMessage(ctx, f.newNotice(status, conversationID)); err != nil {
+			log.Printf("Failed to publish debate status: %v", err)
+		}
+	case len(command.Args) == 1 && strings.EqualFold(command.Args[0], "stop"):
+		if err := f.StopDebate(ctx, conversationID); err != nil {
+			f.replyCommandError(ctx, conversationID, command, err)
+		}
+	case len(command.Args) < 3:
+		f.replyCommandError(ctx, conversationID, command, fmt.Errorf("usage: /debate <agent for> <agent against> <proposition>"))
+	default:
+		proposition := strings.Join(command.Args[2:], " ")
+		if _, err := f.StartDebate(ctx, conversationID, proposition, command.Args[:1], command.Args[1:2], "", 0, 0); err != nil {
+			f.replyCommandError(ctx, conversationID, command, err)
+		}
+	}
+}
+
+// participantNames joins the display names of participants
+func (f *FlowManager) participantNames(ids []string) string {
+	names := make([]string, 0, len(ids))
+	for _, id := range ids {
+		names = append(names, f.participantName(id))
+	}
+	return strings.Join(names, ", ")
+}
+
+// firstNonEmpty returns the first of the values that isn't empty
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// isTagged reports whether a message carries a tag
+func isTagged(message *types.ChatMessage, tag string) bool {
+	for _, t := range message.Metadata.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
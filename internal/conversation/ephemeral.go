@@ -0,0 +1,44 @@
+package conversation
+
+import (
+	"log"
+	"time"
+
+	"philoking/internal/types"
+
+	"github.com/google/uuid"
+)
+
+// scheduleExpiry removes an ephemeral message from history when its TTL runs out
+// and broadcasts a deletion event so clients drop it too
+func (f *FlowManager) scheduleExpiry(message *types.ChatMessage, conversationID string) {
+	time.AfterFunc(time.Until(*message.Metadata.ExpiresAt), func() {
+		for _, expired := range f.conversationManager.ExpireMessages(conversationID, time.Now()) {
+			event := newDeletionEvent(expired.ID, conversationID)
+			if err := f.publisher.PublishMessage(f.ctx, event); err != nil {
+				log.Printf("Failed to publish deletion of message %s: %v", expired.ID, err)
+			}
+		}
+	})
+}
+
+// newNotice builds a transient moderator message that expires after the configured notice TTL
+func (f *FlowManager) newNotice(content, conversationID string) *types.ChatMessage {
+	notice := f.newSystemMessage(content, conversationID)
+	notice.SetTTL(f.config.NoticeTTL)
+	return notice
+}
+
+// newDeletionEvent builds the event that tells consumers to remove a message
+func newDeletionEvent(messageID, conversationID string) *types.ChatMessage {
+	return &types.ChatMessage{
+		ID:        uuid.New().String(),
+		Type:      types.MessageTypeDeletion,
+		AgentID:   "system",
+		Timestamp: time.Now(),
+		Metadata: types.Metadata{
+			ConversationID: conversationID,
+			Custom:         map[string]string{types.DeletedMessageKey: messageID},
+		},
+	}
+}
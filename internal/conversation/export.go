@@ -0,0 +1,181 @@
+package conversation
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+	"time"
+
+	"philoking/internal/types"
+)
+
+// speakerColors are the colors of the speakers in a transcript, handed out
+// in order of appearance
+var speakerColors = []string{"#2563eb", "#d97706", "#059669", "#9333ea", "#dc2626", "#0891b2", "#65a30d", "#db2777"}
+
+// transcriptEntry is a message of a transcript with what the reader needs to show it
+type transcriptEntry struct {
+	Sender  string
+	Color   string
+	Kind    string // Message type, used as CSS class
+	Time    string
+	Content string
+	Topic   string // Set when the message changed the topic
+	Mood    string // Set when the message changed the mood
+}
+
+// transcriptPage is what the transcript template renders
+type transcriptPage struct {
+	Title    string
+	Goal     string
+	Topic    string
+	Mood     string
+	Summary  string
+	Period   string
+	Speakers []transcriptSpeaker
+	Entries  []transcriptEntry
+	Exported string
+}
+
+// transcriptSpeaker is a speaker of the legend
+type transcriptSpeaker struct {
+	Name  string
+	Color string
+}
+
+// WriteHTML renders a conversation as a standalone HTML page: every message
+// with its speaker in their own color, marked where the topic or mood changed
+func WriteHTML(w io.Writer, snapshot *Snapshot) error {
+	page := transcriptPage{
+		Title:    snapshot.Title,
+		Goal:     snapshot.Goal,
+		Topic:    snapshot.Topic,
+		Mood:     snapshot.Mood,
+		Exported: time.Now().Format("2 Jan 2006 15:04"),
+	}
+	if page.Title == "" {
+		page.Title = snapshot.ID
+	}
+	if snapshot.Summary != nil {
+		page.Summary = snapshot.Summary.Content
+	}
+
+	// The timeline names the message that brought each change
+	changes := make(map[string]TopicMoodEntry, len(snapshot.Timeline))
+	topic, mood := "", ""
+	for _, entry := range snapshot.Timeline {
+		if entry.Topic != topic || entry.Mood != mood {
+			changed := TopicMoodEntry{}
+			if entry.Topic != topic {
+				changed.Topic = entry.Topic
+			}
+			if entry.Mood != mood {
+				changed.Mood = entry.Mood
+			}
+			changes[entry.MessageID] = changed
+		}
+		topic, mood = entry.Topic, entry.Mood
+	}
+
+	colors := make(map[string]string)
+	for _, message := range snapshot.Messages {
+		if message.Type == types.MessageTypeDeletion || message.Type == types.MessageTypeScore {
+			continue
+		}
+		sender := transcriptSender(snapshot, message)
+		color, seen := colors[sender]
+		if !seen {
+			color = speakerColors[len(colors)%len(speakerColors)]
+			colors[sender] = color
+			page.Speakers = append(page.Speakers, transcriptSpeaker{Name: sender, Color: color})
+		}
+		change := changes[message.ID]
+		page.Entries = append(page.Entries, transcriptEntry{
+			Sender:  sender,
+			Color:   color,
+			Kind:    string(message.Type),
+			Time:    message.Timestamp.Format("15:04:05"),
+			Content: message.Content,
+			Topic:   change.Topic,
+			Mood:    change.Mood,
+		})
+	}
+
+	if len(snapshot.Messages) > 0 {
+		first, last := snapshot.Messages[0].Timestamp, snapshot.Messages[len(snapshot.Messages)-1].Timestamp
+		page.Period = fmt.Sprintf("%s – %s", first.Format("2 Jan 2006 15:04"), last.Format("2 Jan 2006 15:04"))
+	}
+
+	return transcriptTemplate.Execute(w, page)
+}
+
+// transcriptSender names who sent a message
+func transcriptSender(snapshot *Snapshot, message *types.ChatMessage) string {
+	if message.Metadata.FromAgent != "" {
+		return message.Metadata.FromAgent
+	}
+	for _, id := range []string{message.AgentID, message.UserID} {
+		if participant, exists := snapshot.Participants[id]; exists && participant.Name != "" {
+			return participant.Name
+		}
+		if id != "" {
+			return id
+		}
+	}
+	if message.Type == types.MessageTypeSystem {
+		return "System"
+	}
+	return string(message.Type)
+}
+
+var transcriptTemplate = template.Must(template.New("transcript").Funcs(template.FuncMap{
+	"paragraphs": func(content string) []string {
+		return strings.Split(strings.TrimSpace(content), "\n")
+	},
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<style>
+body { margin: 0; background: #f8fafc; color: #1e293b; font: 16px/1.5 -apple-system, "Segoe UI", Roboto, sans-serif; }
+main { max-width: 48rem; margin: 0 auto; padding: 2rem 1rem 4rem; }
+header { border-bottom: 1px solid #e2e8f0; margin-bottom: 1.5rem; padding-bottom: 1rem; }
+h1 { font-size: 1.75rem; margin: 0 0 .25rem; }
+.details { color: #64748b; font-size: .9rem; margin: .25rem 0; }
+.summary { background: #fff; border: 1px solid #e2e8f0; border-radius: .5rem; margin: 1rem 0 0; padding: .75rem 1rem; }
+.speakers { display: flex; flex-wrap: wrap; gap: .5rem; list-style: none; margin: 1rem 0 0; padding: 0; }
+.speakers li { border-left: .25rem solid; font-size: .85rem; padding: 0 .5rem; }
+.annotation { color: #64748b; font-size: .8rem; letter-spacing: .02em; margin: 1.25rem 0 .5rem; text-align: center; text-transform: uppercase; }
+.message { background: #fff; border-left: .25rem solid; border-radius: .25rem .5rem .5rem .25rem; box-shadow: 0 1px 2px rgba(15, 23, 42, .06); margin: .75rem 0; padding: .5rem .9rem; }
+.message.user { background: #f1f5f9; }
+.message.system, .message.context, .message.alert, .message.whiteboard { background: transparent; box-shadow: none; color: #64748b; font-size: .9rem; }
+.sender { font-weight: 600; }
+time { color: #94a3b8; float: right; font-size: .8rem; }
+.message p { margin: .25rem 0; white-space: pre-wrap; }
+footer { color: #94a3b8; font-size: .8rem; margin-top: 2rem; text-align: center; }
+</style>
+</head>
+<body>
+<main>
+<header>
+<h1>{{.Title}}</h1>
+{{if .Period}}<p class="details">{{.Period}}</p>{{end}}
+{{if .Goal}}<p class="details">Goal: {{.Goal}}</p>{{end}}
+{{if or .Topic .Mood}}<p class="details">{{if .Topic}}Topic: {{.Topic}}{{end}}{{if and .Topic .Mood}} · {{end}}{{if .Mood}}Mood: {{.Mood}}{{end}}</p>{{end}}
+{{if .Summary}}<div class="summary">{{range paragraphs .Summary}}<p>{{.}}</p>{{end}}</div>{{end}}
+{{if .Speakers}}<ul class="speakers">{{range .Speakers}}<li style="border-color: {{.Color}}">{{.Name}}</li>{{end}}</ul>{{end}}
+</header>
+{{range .Entries}}{{if or .Topic .Mood}}<div class="annotation">{{if .Topic}}Topic: {{.Topic}}{{end}}{{if and .Topic .Mood}} · {{end}}{{if .Mood}}Mood: {{.Mood}}{{end}}</div>
+{{end}}<article class="message {{.Kind}}" style="border-color: {{.Color}}">
+<time>{{.Time}}</time><span class="sender" style="color: {{.Color}}">{{.Sender}}</span>
+{{range paragraphs .Content}}<p>{{.}}</p>{{end}}
+</article>
+{{else}}<p class="details">No messages.</p>
+{{end}}<footer>Exported from PhiloKing on {{.Exported}}</footer>
+</main>
+</body>
+</html>
+`))
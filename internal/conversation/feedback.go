@@ -0,0 +1,157 @@
+package conversation
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"philoking/internal/types"
+)
+
+// feedbackWindow is the number of an agent's most recent rated messages summarized for its prompt
+const feedbackWindow = 10
+
+// longReplyWords is the length from which a disliked reply is called long
+const longReplyWords = 60
+
+// Rating is a user's thumbs up or down on an agent message
+type Rating struct {
+	UserID   string    `json:"user_id"`
+	Positive bool      `json:"positive"`
+	RatedAt  time.Time `json:"rated_at"`
+}
+
+// FeedbackTally counts the ratings of a message or an agent
+type FeedbackTally struct {
+	Likes    int `json:"likes"`
+	Dislikes int `json:"dislikes"`
+}
+
+// RateMessage records a user's rating of an agent message, replacing an
+// earlier rating by the same user. It returns the rated agent's ID and the
+// message's new tally.
+func (m *Manager) RateMessage(conversationID, messageID, userID string, positive bool) (string, FeedbackTally, error) {
+	conv := m.GetOrCreateConversation(conversationID)
+
+	conv.mu.Lock()
+	defer conv.mu.Unlock()
+
+	var rated *types.ChatMessage
+	for _, message := range conv.Messages {
+		if message.ID == messageID {
+			rated = message
+			break
+		}
+	}
+	if rated == nil {
+		return "", FeedbackTally{}, fmt.Errorf("message %s not found", messageID)
+	}
+	if rated.Type != types.MessageTypeAgent {
+		return "", FeedbackTally{}, fmt.Errorf("only agent messages can be rated")
+	}
+
+	if conv.ratings == nil {
+		conv.ratings = make(map[string]map[string]Rating)
+	}
+	ratings, exists := conv.ratings[messageID]
+	if !exists {
+		ratings = make(map[string]Rating)
+		conv.ratings[messageID] = ratings
+	}
+	ratings[userID] = Rating{UserID: userID, Positive: positive, RatedAt: time.Now()}
+
+	return rated.AgentID, tally(ratings), nil
+}
+
+// GetRatings returns the ratings of a message, oldest first
+func (m *Manager) GetRatings(conversationID, messageID string) []Rating {
+	conv := m.GetOrCreateConversation(conversationID)
+
+	conv.mu.RLock()
+	defer conv.mu.RUnlock()
+
+	ratings := make([]Rating, 0, len(conv.ratings[messageID]))
+	for _, rating := range conv.ratings[messageID] {
+		ratings = append(ratings, rating)
+	}
+	sort.Slice(ratings, func(i, j int) bool {
+		return ratings[i].RatedAt.Before(ratings[j].RatedAt)
+	})
+	return ratings
+}
+
+// GetAgentFeedback tallies the ratings of all of an agent's messages in a conversation
+func (m *Manager) GetAgentFeedback(conversationID, agentID string) FeedbackTally {
+	conv := m.GetOrCreateConversation(conversationID)
+
+	conv.mu.RLock()
+	defer conv.mu.RUnlock()
+
+	var total FeedbackTally
+	for _, message := range conv.Messages {
+		if message.AgentID == agentID {
+			t := tally(conv.ratings[message.ID])
+			total.Likes += t.Likes
+			total.Dislikes += t.Dislikes
+		}
+	}
+	return total
+}
+
+// FeedbackPrompt summarizes how users rated an agent's recent messages, for
+// its system prompt; it is empty when nothing was rated
+func (m *Manager) FeedbackPrompt(conversationID, agentID string) string {
+	conv := m.GetOrCreateConversation(conversationID)
+
+	conv.mu.RLock()
+	defer conv.mu.RUnlock()
+
+	var (
+		total FeedbackTally
+		last  *types.ChatMessage
+		liked bool
+		rated int
+	)
+	for i := len(conv.Messages) - 1; i >= 0 && rated < feedbackWindow; i-- {
+		message := conv.Messages[i]
+		if message.AgentID != agentID || len(conv.ratings[message.ID]) == 0 {
+			continue
+		}
+		t := tally(conv.ratings[message.ID])
+		total.Likes += t.Likes
+		total.Dislikes += t.Dislikes
+		if last == nil {
+			last, liked = message, t.Likes >= t.Dislikes
+		}
+		rated++
+	}
+	if last == nil {
+		return ""
+	}
+
+	summary := fmt.Sprintf("Users rated your recent replies: %d liked, %d disliked.", total.Likes, total.Dislikes)
+	words := len(strings.Fields(last.Content))
+	switch {
+	case liked:
+		summary += " They liked your last rated reply, so keep that style."
+	case words >= longReplyWords:
+		summary += fmt.Sprintf(" Users disliked your last long reply (%d words); keep it shorter.", words)
+	default:
+		summary += " Users disliked your last rated reply; try a different angle."
+	}
+	return summary
+}
+
+// tally counts likes and dislikes
+func tally(ratings map[string]Rating) FeedbackTally {
+	var t FeedbackTally
+	for _, rating := range ratings {
+		if rating.Positive {
+			t.Likes++
+		} else {
+			t.Dislikes++
+		}
+	}
+	return t
+}
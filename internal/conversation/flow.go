@@ -2,31 +2,54 @@ package conversation
 
 import (
 	"context"
+	"fmt"
 	"log"
+	"sync"
 	"time"
 
+	"philoking/internal/audit"
+	"philoking/internal/config"
 	"philoking/internal/kafka"
+	"philoking/internal/outbox"
 	"philoking/internal/types"
+
+	"github.com/google/uuid"
 )
 
 // FlowManager manages the natural conversation flow
 type FlowManager struct {
+	config              config.ConversationConfig
 	kafkaClient         *kafka.Client
+	publisher           outbox.Publisher // The Kafka client, or the outbox when storage is configured
+	outbox              *outbox.Outbox
 	conversationManager *Manager
 	participants        map[string]*Participant
+	participantsMu      sync.RWMutex // Agents come and go at runtime
+	analytics           *analyticsTracker
+	leaderboard         *leaderboard
+	auditLog            *audit.Log      // Nil until UseAuditLog is called
+	erasers             []namedEraser   // Run on user data deletion
+	ctx                 context.Context // Lifetime of the conversation flow, used by timers
 }
 
 // NewFlowManager creates a new conversation flow manager
-func NewFlowManager(kafkaClient *kafka.Client, convManager *Manager) *FlowManager {
+func NewFlowManager(cfg config.ConversationConfig, kafkaClient *kafka.Client, convManager *Manager) *FlowManager {
 	return &FlowManager{
+		config:              cfg,
 		kafkaClient:         kafkaClient,
+		publisher:           kafkaClient,
 		conversationManager: convManager,
 		participants:        make(map[string]*Participant),
+		analytics:           newAnalyticsTracker(),
+		leaderboard:         newLeaderboard(),
+		ctx:                 context.Background(),
 	}
 }
 
 // RegisterParticipant registers a participant in the conversation
 func (f *FlowManager) RegisterParticipant(participantID, name, participantType string) {
+	f.participantsMu.Lock()
+	defer f.participantsMu.Unlock()
 	f.participants[participantID] = &Participant{
 		ID:       participantID,
 		Name:     name,
@@ -38,11 +61,37 @@ func (f *FlowManager) RegisterParticipant(participantID, name, participantType s
 	log.Printf("Registered participant: %s (%s)", name, participantType)
 }
 
+// UnregisterParticipant removes a participant, e.g. an agent deleted at runtime
+func (f *FlowManager) UnregisterParticipant(participantID string) {
+	f.participantsMu.Lock()
+	defer f.participantsMu.Unlock()
+	delete(f.participants, participantID)
+
+	log.Printf("Unregistered participant: %s", participantID)
+}
+
+// participant returns a registered participant
+func (f *FlowManager) participant(participantID string) (*Participant, bool) {
+	f.participantsMu.RLock()
+	defer f.participantsMu.RUnlock()
+	participant, exists := f.participants[participantID]
+	return participant, exists
+}
+
 // StartConversationFlow starts the natural conversation flow
 func (f *FlowManager) StartConversationFlow(ctx context.Context, conversationID string) error {
+	f.ctx = ctx
+
 	// Register the user as a participant
 	f.RegisterParticipant("user", "User", "user")
 
+	if err := f.restorePolls(); err != nil {
+		return fmt.Errorf("failed to restore polls: %w", err)
+	}
+	if err := f.restoreReminders(); err != nil {
+		return fmt.Errorf("failed to restore reminders: %w", err)
+	}
+
 	// Start listening to the unified conversation topic
 	go func() {
 		err := f.kafkaClient.SubscribeToMessages(ctx, "philoking-conversation", func(message *types.ChatMessage) error {
@@ -53,21 +102,109 @@ func (f *FlowManager) StartConversationFlow(ctx context.Context, conversationID
 		}
 	}()
 
+	f.startPhases(ctx, conversationID)
+	f.watchHandoffs(ctx)
+	go f.watchSilence(ctx, conversationID)
+
 	log.Printf("Started conversation flow for conversation: %s", conversationID)
 	return nil
 }
 
-// handleMessage handles incoming messages in the conversation flow
+// handleMessage handles incoming messages in the conversation flow;
+// conversationID is used for messages that don't name their conversation
 func (f *FlowManager) handleMessage(ctx context.Context, message *types.ChatMessage, conversationID string) error {
+	if message.Metadata.ConversationID != "" {
+		conversationID = message.Metadata.ConversationID
+	}
+
+	// Deletion, whiteboard and alert events only concern clients
+	if message.Type == types.MessageTypeDeletion || message.Type == types.MessageTypeWhiteboard || message.Type == types.MessageTypeAlert {
+		return nil
+	}
+
+	// Scores feed the leaderboard and are not part of the discussion
+	if message.Type == types.MessageTypeScore {
+		f.leaderboard.record(conversationID, message)
+		return nil
+	}
+
 	// Add message to conversation history
 	f.conversationManager.AddMessage(conversationID, message)
+	if message.Metadata.ExpiresAt != nil {
+		f.scheduleExpiry(message, conversationID)
+	}
+
+	// Track topic, mood and activity
+	at := message.Timestamp
+	if at.IsZero() {
+		at = time.Now()
+	}
+	f.conversationManager.UpdateTopicMood(conversationID, DetectTopic(message.Content), DetectMood(message.Content), message.ID, at)
+	f.analytics.record(conversationID, message, f.getParticipantID(message))
 
 	log.Printf("Conversation flow handled message: %s (type: %s, from: %s)",
 		message.Content, message.Type, f.getParticipantID(message))
 
+	if message.IsCommand() {
+		f.handleCommand(ctx, message, conversationID)
+	}
+
+	if message.Type == types.MessageTypeAgent {
+		f.handleSideMessage(ctx, message, conversationID)
+		f.handleDebateMessage(ctx, message, conversationID)
+		f.handleTutoringMessage(ctx, message, conversationID)
+		f.handleWrapUpMessage(ctx, message, conversationID)
+	}
+
+	f.handleQuestionFlow(ctx, message, conversationID)
+	f.handlePhaseMessage(ctx, message, conversationID)
+	f.checkDepth(ctx, message, conversationID)
+
 	return nil
 }
 
+// handleCommand dispatches the chat commands the conversation flow is responsible for
+func (f *FlowManager) handleCommand(ctx context.Context, message *types.ChatMessage, conversationID string) {
+	switch message.Metadata.Command.Name {
+	case "poll", "vote", "closepoll":
+		f.handlePollCommand(ctx, message, conversationID)
+	case "aside":
+		f.handleSideCommand(ctx, message, conversationID)
+	case "phase":
+		f.handlePhaseCommand(ctx, message, conversationID)
+	case "debate":
+		f.handleDebateCommand(ctx, message, conversationID)
+	case "tutor":
+		f.handleTutorCommand(ctx, message, conversationID)
+	case "remind", "reminders":
+		f.handleReminderCommand(ctx, message, conversationID)
+	}
+}
+
+// replyCommandError tells the conversation why a command failed
+func (f *FlowManager) replyCommandError(ctx context.Context, conversationID string, command *types.Command, err error) {
+	log.Printf("Command /%s failed: %v", command.Name, err)
+	reply := f.newNotice(fmt.Sprintf("/%s: %v", command.Name, err), conversationID)
+	if err := f.publisher.PublishMessage(ctx, reply); err != nil {
+		log.Printf("Failed to publish command error: %v", err)
+	}
+}
+
+// newSystemMessage builds a system message from the conversation moderator
+func (f *FlowManager) newSystemMessage(content, conversationID string) *types.ChatMessage {
+	return &types.ChatMessage{
+		ID:        uuid.New().String(),
+		Type:      types.MessageTypeSystem,
+		Content:   content,
+		AgentID:   "system",
+		Timestamp: time.Now(),
+		Metadata: types.Metadata{
+			ConversationID: conversationID,
+			FromAgent:      "Moderator",
+		},
+	}
+}
+
 // getParticipantID gets the participant ID from a message
 func (f *FlowManager) getParticipantID(message *types.ChatMessage) string {
 	if message.AgentID != "" {
@@ -79,10 +216,36 @@ func (f *FlowManager) getParticipantID(message *types.ChatMessage) string {
 	return "unknown"
 }
 
+// GetAnalytics returns the analytics of a conversation with message volume
+// aggregated into buckets of the given size (minimum one minute)
+func (f *FlowManager) GetAnalytics(conversationID string, bucket time.Duration) *Analytics {
+	analytics := f.analytics.snapshot(conversationID, bucket)
+
+	// Split the combined timeline into separate topic and mood timelines
+	for _, entry := range f.conversationManager.GetTimeline(conversationID) {
+		topics := analytics.TopicTimeline
+		if entry.Topic != "" && (len(topics) == 0 || topics[len(topics)-1].Value != entry.Topic) {
+			analytics.TopicTimeline = append(topics, TimelineEntry{Value: entry.Topic, MessageID: entry.MessageID, At: entry.Timestamp})
+		}
+		moods := analytics.MoodTimeline
+		if len(moods) == 0 || moods[len(moods)-1].Value != entry.Mood {
+			analytics.MoodTimeline = append(moods, TimelineEntry{Value: entry.Mood, MessageID: entry.MessageID, At: entry.Timestamp})
+		}
+	}
+	if clustering := f.conversationManager.GetClusters(conversationID); clustering != nil {
+		analytics.Clusters, analytics.ClusterTimeline = clustering.Clusters, clustering.Timeline
+	}
+
+	return analytics
+}
+
 // GetConversationStats returns statistics about the conversation
 func (f *FlowManager) GetConversationStats(conversationID string) map[string]interface{} {
 	conv := f.conversationManager.GetConversationContext(conversationID)
 
+	conv.mu.RLock()
+	defer conv.mu.RUnlock()
+
 	stats := map[string]interface{}{
 		"id":           conv.ID,
 		"participants": len(conv.Participants),
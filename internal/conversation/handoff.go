@@ -0,0 +1,281 @@
+package conversation
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"philoking/internal/types"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// DefaultHandoffTimeout is how long a handoff waits for an agent to accept when no timeout is configured
+	DefaultHandoffTimeout = 2 * time.Minute
+	// HandoffTag marks the messages that announce, settle or escalate handoffs
+	HandoffTag = "handoff"
+	// OrchestratorID is the conversation flow in agent messages: agents answer
+	// handoffs to it and it assigns them
+	OrchestratorID = "system"
+)
+
+// States of a handoff
+const (
+	HandoffPending   = "pending"
+	HandoffAccepted  = "accepted"
+	HandoffEscalated = "escalated" // Nobody accepted; the user was asked to step in
+)
+
+// Handoff is a task one agent transferred to another, or to whichever agent
+// accepts it first, tracked until it is taken over or escalated to the user
+type Handoff struct {
+	ID             string     `json:"id"`
+	ConversationID string     `json:"conversation_id"`
+	From           string     `json:"from"`
+	To             string     `json:"to,omitempty"` // Empty when offered to every agent
+	Task           string     `json:"task"`
+	Context        string     `json:"context,omitempty"`
+	Status         string     `json:"status"`
+	Owner          string     `json:"owner,omitempty"` // The agent that took the task over
+	Declined       []string   `json:"declined,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	Deadline       time.Time  `json:"deadline"`
+	ResolvedAt     *time.Time `json:"resolved_at,omitempty"`
+}
+
+// addHandoff tracks a new handoff; it returns false if the handoff is already tracked
+func (m *Manager) addHandoff(handoff *Handoff) bool {
+	m.GetOrCreateConversation(handoff.ConversationID)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.handoffs[handoff.ID]; exists {
+		return false
+	}
+	m.handoffs[handoff.ID] = handoff
+	return true
+}
+
+// tracksHandoff reports whether a handoff is known
+func (m *Manager) tracksHandoff(handoffID string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	_, exists := m.handoffs[handoffID]
+	return exists
+}
+
+// acceptHandoff gives a pending handoff to the agent that accepted it, if it may take it
+func (m *Manager) acceptHandoff(handoffID, agentID string) (Handoff, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	handoff, exists := m.handoffs[handoffID]
+	if !exists || handoff.Status != HandoffPending || agentID == handoff.From || (handoff.To != "" && handoff.To != agentID) {
+		return Handoff{}, false
+	}
+	now := time.Now()
+	handoff.Status = HandoffAccepted
+	handoff.Owner = agentID
+	handoff.ResolvedAt = &now
+	return handoff.copy(), true
+}
+
+// declineHandoff records an agent turning a pending handoff down. A handoff
+// meant for that agent alone escalates right away.
+func (m *Manager) declineHandoff(handoffID, agentID string) (Handoff, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	handoff, exists := m.handoffs[handoffID]
+	if !exists || handoff.Status != HandoffPending {
+		return Handoff{}, false
+	}
+	handoff.Declined = append(handoff.Declined, agentID)
+	if handoff.To == "" || handoff.To != agentID {
+		return Handoff{}, false
+	}
+	return handoff.escalate(), true
+}
+
+// escalateHandoff escalates a handoff that is still pending
+func (m *Manager) escalateHandoff(handoffID string) (Handoff, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	handoff, exists := m.handoffs[handoffID]
+	if !exists || handoff.Status != HandoffPending {
+		return Handoff{}, false
+	}
+	return handoff.escalate(), true
+}
+
+// ListHandoffs returns the handoffs of a conversation, oldest first
+func (m *Manager) ListHandoffs(conversationID string) []Handoff {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	handoffs := make([]Handoff, 0)
+	for _, handoff := range m.handoffs {
+		if handoff.ConversationID == conversationID {
+			handoffs = append(handoffs, handoff.copy())
+		}
+	}
+	sort.Slice(handoffs, func(i, j int) bool {
+		return handoffs[i].CreatedAt.Before(handoffs[j].CreatedAt)
+	})
+	return handoffs
+}
+
+// escalate marks the handoff escalated and returns a copy; the caller holds the manager's lock
+func (h *Handoff) escalate() Handoff {
+	now := time.Now()
+	h.Status = HandoffEscalated
+	h.ResolvedAt = &now
+	return h.copy()
+}
+
+// copy returns a copy that doesn't share the declined list
+func (h *Handoff) copy() Handoff {
+	cp := *h
+	cp.Declined = append([]string(nil), h.Declined...)
+	return cp
+}
+
+// watchHandoffs follows the agent messages topic to track handoffs while the conversation flow runs
+func (f *FlowManager) watchHandoffs(ctx context.Context) {
+	if !f.kafkaClient.AgentMessagesEnabled() {
+		return
+	}
+
+	go func() {
+		err := f.kafkaClient.SubscribeToAgentMessages(ctx, "philoking-handoffs", func(message *types.AgentMessage) error {
+			return f.handleHandoffMessage(ctx, message)
+		})
+		if err != nil && ctx.Err() == nil {
+			log.Printf("Error tracking handoffs: %v", err)
+		}
+	}()
+}
+
+// handleHandoffMessage tracks handoffs, hands them to the first agent that
+// accepts and escalates the ones that are declined or time out. Answers may
+// overtake their handoff, which is on another partition; an error has the
+// consumer retry them.
+func (f *FlowManager) handleHandoffMessage(ctx context.Context, message *types.AgentMessage) error {
+	var payload types.HandoffPayload
+	switch message.Type {
+	case types.AgentMessageHandoff, types.AgentMessageHandoffAccept, types.AgentMessageHandoffDecline:
+		if err := message.DecodePayload(&payload); err != nil {
+			log.Printf("Ignoring malformed %s message from %s: %v", message.Type, message.FromAgent, err)
+			return nil
+		}
+	default:
+		return nil
+	}
+	if message.Type != types.AgentMessageHandoff && !f.conversationManager.tracksHandoff(payload.HandoffID) {
+		return fmt.Errorf("%s from %s for unknown handoff %s", message.Type, message.FromAgent, payload.HandoffID)
+	}
+
+	switch message.Type {
+	case types.AgentMessageHandoff:
+		f.startHandoff(ctx, message, payload)
+
+	case types.AgentMessageHandoffAccept:
+		handoff, ok := f.conversationManager.acceptHandoff(payload.HandoffID, message.FromAgent)
+		if !ok {
+			return nil // Taken by another agent or escalated already
+		}
+		log.Printf("Handoff %s was accepted by %s", handoff.ID, handoff.Owner)
+
+		assignment := &types.AgentMessage{
+			ID:             uuid.New().String(),
+			FromAgent:      OrchestratorID,
+			ToAgent:        handoff.Owner,
+			Type:           types.AgentMessageHandoffAssign,
+			Payload:        types.HandoffPayload{HandoffID: handoff.ID, Task: handoff.Task, Context: handoff.Context},
+			Timestamp:      time.Now(),
+			ConversationID: handoff.ConversationID,
+		}
+		if err := f.kafkaClient.PublishAgentMessage(ctx, assignment); err != nil {
+			log.Printf("Failed to assign handoff %s: %v", handoff.ID, err)
+		}
+		f.announceHandoff(ctx, handoff.ConversationID, fmt.Sprintf("🤝 %s took over from %s: %s", f.participantName(handoff.Owner), f.participantName(handoff.From), handoff.Task))
+
+	case types.AgentMessageHandoffDecline:
+		if payload.Reason != "" {
+			log.Printf("%s declined handoff %s: %s", message.FromAgent, payload.HandoffID, payload.Reason)
+		}
+		if handoff, escalated := f.conversationManager.declineHandoff(payload.HandoffID, message.FromAgent); escalated {
+			f.escalateHandoff(ctx, handoff)
+		}
+	}
+	return nil
+}
+
+// startHandoff tracks a new handoff and escalates it if nobody accepts in time
+func (f *FlowManager) startHandoff(ctx context.Context, message *types.AgentMessage, payload types.HandoffPayload) {
+	if payload.Task == "" || message.ConversationID == "" {
+		log.Printf("Ignoring handoff from %s without a task or conversation", message.FromAgent)
+		return
+	}
+
+	now := time.Now()
+	handoff := &Handoff{
+		ID:             firstNonEmpty(payload.HandoffID, message.ID),
+		ConversationID: message.ConversationID,
+		From:           message.FromAgent,
+		To:             message.ToAgent,
+		Task:           payload.Task,
+		Context:        payload.Context,
+		Status:         HandoffPending,
+		CreatedAt:      now,
+		Deadline:       now.Add(f.handoffTimeout()),
+	}
+	if !f.conversationManager.addHandoff(handoff) {
+		return // Redelivered
+	}
+
+	to := "whoever takes it"
+	if handoff.To != "" {
+		to = f.participantName(handoff.To)
+	}
+	f.announceHandoff(ctx, handoff.ConversationID, fmt.Sprintf("📨 %s hands a task over to %s: %s", f.participantName(handoff.From), to, handoff.Task))
+
+	time.AfterFunc(f.handoffTimeout(), func() {
+		if handoff, ok := f.conversationManager.escalateHandoff(handoff.ID); ok {
+			f.escalateHandoff(f.ctx, handoff)
+		}
+	})
+}
+
+// escalateHandoff asks the user to step in for a task nobody took over
+func (f *FlowManager) escalateHandoff(ctx context.Context, handoff Handoff) {
+	log.Printf("Handoff %s in conversation %s was escalated", handoff.ID, handoff.ConversationID)
+
+	content := fmt.Sprintf("🙋 Nobody took over %s's task: %s. Could you take it on, or ask one of the agents to?", f.participantName(handoff.From), handoff.Task)
+	if handoff.Context != "" {
+		content += " Context: " + handoff.Context
+	}
+	f.announceHandoff(ctx, handoff.ConversationID, content)
+}
+
+// announceHandoff tells the conversation how a handoff is going
+func (f *FlowManager) announceHandoff(ctx context.Context, conversationID, content string) {
+	message := f.newSystemMessage(content, conversationID)
+	message.Metadata.Tags = []string{HandoffTag}
+	if err := f.publisher.PublishMessage(ctx, message); err != nil {
+		log.Printf("Failed to publish handoff message: %v", err)
+	}
+}
+
+// handoffTimeout returns the configured time to wait for an agent to accept a handoff
+func (f *FlowManager) handoffTimeout() time.Duration {
+	if f.config.HandoffTimeout > 0 {
+		return f.config.HandoffTimeout
+	}
+	return DefaultHandoffTimeout
+}
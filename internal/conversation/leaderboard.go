@@ -0,0 +1,131 @@
+package conversation
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+
+	"philoking/internal/types"
+)
+
+// LeaderboardEntry ranks an agent by the scores judges gave its replies
+type LeaderboardEntry struct {
+	AgentID  string             `json:"agent_id"`
+	Name     string             `json:"name"`
+	Scored   int                `json:"scored"`   // Number of scores received
+	Criteria map[string]float64 `json:"criteria"` // Average score per criterion
+	Overall  float64            `json:"overall"`  // Average over all criteria
+}
+
+// scoreTally sums the scores of one agent in one conversation
+type scoreTally struct {
+	count  int
+	sums   map[string]int
+	counts map[string]int
+}
+
+// leaderboard collects judge scores per conversation and agent
+type leaderboard struct {
+	tallies map[string]map[string]*scoreTally // Conversation ID -> agent ID -> tally
+	mu      sync.Mutex
+}
+
+func newLeaderboard() *leaderboard {
+	return &leaderboard{
+		tallies: make(map[string]map[string]*scoreTally),
+	}
+}
+
+// record adds the scores of a score message
+func (l *leaderboard) record(conversationID string, message *types.ChatMessage) {
+	agentID := message.Metadata.Custom[types.ScoredAgentKey]
+	if agentID == "" {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	agents, exists := l.tallies[conversationID]
+	if !exists {
+		agents = make(map[string]*scoreTally)
+		l.tallies[conversationID] = agents
+	}
+	tally, exists := agents[agentID]
+	if !exists {
+		tally = &scoreTally{sums: make(map[string]int), counts: make(map[string]int)}
+		agents[agentID] = tally
+	}
+
+	tally.count++
+	for key, value := range message.Metadata.Custom {
+		if key == types.ScoredAgentKey || key == types.ScoredMessageKey {
+			continue
+		}
+		if score, err := strconv.Atoi(value); err == nil {
+			tally.sums[key] += score
+			tally.counts[key]++
+		}
+	}
+}
+
+// entries ranks the agents of a conversation, or of all conversations when
+// conversationID is empty, by their overall average score
+func (l *leaderboard) entries(conversationID string) []*LeaderboardEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	combined := make(map[string]*scoreTally)
+	for id, agents := range l.tallies {
+		if conversationID != "" && id != conversationID {
+			continue
+		}
+		for agentID, tally := range agents {
+			total, exists := combined[agentID]
+			if !exists {
+				total = &scoreTally{sums: make(map[string]int), counts: make(map[string]int)}
+				combined[agentID] = total
+			}
+			total.count += tally.count
+			for criterion, sum := range tally.sums {
+				total.sums[criterion] += sum
+				total.counts[criterion] += tally.counts[criterion]
+			}
+		}
+	}
+
+	entries := make([]*LeaderboardEntry, 0, len(combined))
+	for agentID, tally := range combined {
+		entry := &LeaderboardEntry{
+			AgentID:  agentID,
+			Scored:   tally.count,
+			Criteria: make(map[string]float64, len(tally.sums)),
+		}
+		for criterion, sum := range tally.sums {
+			entry.Criteria[criterion] = float64(sum) / float64(tally.counts[criterion])
+			entry.Overall += entry.Criteria[criterion]
+		}
+		if len(entry.Criteria) > 0 {
+			entry.Overall /= float64(len(entry.Criteria))
+		}
+		entries = append(entries, entry)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Overall != entries[j].Overall {
+			return entries[i].Overall > entries[j].Overall
+		}
+		return entries[i].AgentID < entries[j].AgentID
+	})
+	return entries
+}
+
+// Leaderboard ranks agents by the scores judge agents gave their replies, in
+// one conversation or, when conversationID is empty, in all of them
+func (f *FlowManager) Leaderboard(conversationID string) []*LeaderboardEntry {
+	entries := f.leaderboard.entries(conversationID)
+	for _, entry := range entries {
+		entry.Name = f.participantName(entry.AgentID)
+	}
+	return entries
+}
@@ -0,0 +1,241 @@
+package conversation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// ErrConversationExists is returned when creating a conversation that already has messages
+var ErrConversationExists = errors.New("conversation already exists")
+
+// ErrConversationNotFound is returned when a conversation is not held in memory
+var ErrConversationNotFound = errors.New("conversation not found")
+
+// ScheduledTag marks the welcome message of a conversation started on a schedule
+const ScheduledTag = "scheduled"
+
+// Info describes a conversation without its messages
+type Info struct {
+	ID       string   `json:"id"`
+	Title    string   `json:"title,omitempty"`
+	Topic    string   `json:"topic,omitempty"`
+	Mood     string   `json:"mood,omitempty"`
+	Goal     string   `json:"goal,omitempty"`
+	Members  []string `json:"members,omitempty"` // Agents taking part; empty means all
+	Messages int      `json:"messages"`
+	Private  bool     `json:"private,omitempty"` // Agents don't cite it in other conversations
+	// CreatedAt is when the conversation was first seen, or created explicitly
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ConversationUpdate changes the settable fields of a conversation; nil fields stay as they are
+type ConversationUpdate struct {
+	Title *string `json:"title"`
+	Topic *string `json:"topic"`
+	Mood  *string `json:"mood"`
+	Goal  *string `json:"goal"`
+	// Private keeps agents from citing the conversation in other conversations
+	Private *bool `json:"private"`
+}
+
+// info describes a conversation; callers hold conv.mu
+func (conv *Conversation) info() *Info {
+	return &Info{
+		ID:        conv.ID,
+		Title:     conv.Title,
+		Topic:     conv.Topic,
+		Mood:      conv.Mood,
+		Goal:      conv.Goal,
+		Members:   append([]string(nil), conv.Members...),
+		Messages:  len(conv.Messages),
+		Private:   conv.Private,
+		CreatedAt: conv.CreatedAt,
+		UpdatedAt: conv.UpdatedAt,
+	}
+}
+
+// lookup returns a conversation held in memory without creating it
+func (m *Manager) lookup(conversationID string) (*Conversation, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	conv, exists := m.conversations[conversationID]
+	return conv, exists
+}
+
+// GetInfo describes a conversation held in memory
+func (m *Manager) GetInfo(conversationID string) (*Info, bool) {
+	conv, exists := m.lookup(conversationID)
+	if !exists {
+		return nil, false
+	}
+
+	conv.mu.RLock()
+	defer conv.mu.RUnlock()
+	return conv.info(), true
+}
+
+// ListInfo describes the conversations held in memory, sorted by ID
+func (m *Manager) ListInfo() []*Info {
+	infos := make([]*Info, 0)
+	for _, id := range m.ListConversations() {
+		if info, ok := m.GetInfo(id); ok {
+			infos = append(infos, info)
+		}
+	}
+	return infos
+}
+
+// createConversation sets up a conversation with a title, topic, goal and
+// members. Lookups create empty conversations on the fly, so only one that
+// already has messages counts as existing.
+func (m *Manager) createConversation(conversationID, title, topic, goal string, members []string) (*Info, error) {
+	conv := m.GetOrCreateConversation(conversationID)
+
+	conv.mu.Lock()
+	defer conv.mu.Unlock()
+
+	if len(conv.Messages) > 0 {
+		return nil, ErrConversationExists
+	}
+
+	now := time.Now()
+	conv.Title = title
+	conv.Goal = goal
+	conv.Members = append([]string(nil), members...)
+	conv.CreatedAt = now
+	conv.UpdatedAt = now
+	if topic != "" {
+		conv.Topic = topic
+		conv.Timeline = append(conv.Timeline, TopicMoodEntry{Topic: topic, Mood: conv.Mood, Timestamp: now})
+	}
+	return conv.info(), nil
+}
+
+// UpdateConversation renames a conversation, sets its goal, topic or mood, or
+// makes it private.
+// A topic or mood set by hand is recorded on the timeline like a detected one.
+func (m *Manager) UpdateConversation(conversationID string, update ConversationUpdate) (*Info, error) {
+	conv, exists := m.lookup(conversationID)
+	if !exists {
+		return nil, ErrConversationNotFound
+	}
+
+	conv.mu.Lock()
+	now := time.Now()
+	if update.Title != nil {
+		conv.Title = *update.Title
+	}
+	if update.Goal != nil {
+		conv.Goal = *update.Goal
+	}
+	if update.Private != nil {
+		conv.Private = *update.Private
+	}
+	topic, mood := conv.Topic, conv.Mood
+	if update.Topic != nil {
+		topic = *update.Topic
+	}
+	if update.Mood != nil {
+		mood = *update.Mood
+	}
+	if topic != conv.Topic || mood != conv.Mood {
+		conv.Topic, conv.Mood = topic, mood
+		conv.Timeline = append(conv.Timeline, TopicMoodEntry{Topic: topic, Mood: mood, Timestamp: now})
+	}
+	conv.UpdatedAt = now
+	info := conv.info()
+	conv.mu.Unlock()
+
+	return info, nil
+}
+
+// IsMember reports whether an agent takes part in a conversation; every agent
+// does unless the conversation was created with a list of members
+func (m *Manager) IsMember(conversationID, agentID string) bool {
+	conv, exists := m.lookup(conversationID)
+	if !exists {
+		return true
+	}
+
+	conv.mu.RLock()
+	defer conv.mu.RUnlock()
+
+	if len(conv.Members) == 0 {
+		return true
+	}
+	for _, member := range conv.Members {
+		if member == agentID {
+			return true
+		}
+	}
+	return false
+}
+
+// GetGoal returns what a conversation should achieve, if it was given a goal
+func (m *Manager) GetGoal(conversationID string) string {
+	conv, exists := m.lookup(conversationID)
+	if !exists {
+		return ""
+	}
+
+	conv.mu.RLock()
+	defer conv.mu.RUnlock()
+	return conv.Goal
+}
+
+// CreateConversation starts a conversation with the given agents, or all of
+// them when members is empty, and announces its topic and goal in it with a
+// welcome message carrying the given tags
+func (f *FlowManager) CreateConversation(ctx context.Context, conversationID, title, topic, goal string, members []string, tags ...string) (*Info, error) {
+	names := make([]string, 0, len(members))
+	for _, id := range members {
+		participant, ok := f.participant(id)
+		if !ok || participant.Type != "agent" {
+			return nil, fmt.Errorf("unknown agent %q", id)
+		}
+		names = append(names, participant.Name)
+	}
+
+	info, err := f.conversationManager.createConversation(conversationID, title, topic, goal, members)
+	if err != nil {
+		return nil, err
+	}
+	for i, id := range members {
+		f.conversationManager.AddParticipant(conversationID, id, names[i], "agent")
+	}
+
+	announcement := f.newSystemMessage(welcome(title, topic, goal, names), conversationID)
+	announcement.Metadata.Tags = append(announcement.Metadata.Tags, tags...)
+	if err := f.publisher.PublishMessage(ctx, announcement); err != nil {
+		log.Printf("Failed to announce conversation %s: %v", conversationID, err)
+	}
+
+	log.Printf("Created conversation %s", conversationID)
+	return info, nil
+}
+
+// welcome builds the announcement that opens a new conversation
+func welcome(title, topic, goal string, names []string) string {
+	var b strings.Builder
+	b.WriteString("📣 Welcome")
+	if title != "" {
+		fmt.Fprintf(&b, " to %s", title)
+	}
+	b.WriteString(".")
+	if topic != "" {
+		fmt.Fprintf(&b, " Topic: %s.", topic)
+	}
+	if goal != "" {
+		fmt.Fprintf(&b, " Goal: %s.", goal)
+	}
+	if len(names) > 0 {
+		fmt.Fprintf(&b, " Taking part: %s.", strings.Join(names, ", "))
+	}
+	return b.String()
+}
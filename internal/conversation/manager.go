@@ -1,6 +1,7 @@
 package conversation
 
 import (
+	"sort"
 	"sync"
 	"time"
 
@@ -10,17 +11,54 @@ import (
 // Manager manages conversation state and context
 type Manager struct {
 	conversations map[string]*Conversation
+	polls         map[string]*Poll
+	reminders     map[string]*Reminder // Pending only
+	handoffs      map[string]*Handoff
+	sides         map[string]*SideConversation
 	mu            sync.RWMutex
 }
 
 // Conversation represents a conversation session
 type Conversation struct {
 	ID           string                  `json:"id"`
+	Title        string                  `json:"title,omitempty"`
+	Goal         string                  `json:"goal,omitempty"`    // What the agents should work towards
+	Members      []string                `json:"members,omitempty"` // Agents taking part; empty means all
 	Participants map[string]*Participant `json:"participants"`
-	Messages     []*types.ChatMessage    `json:"messages"`
-	CreatedAt    time.Time               `json:"created_at"`
-	UpdatedAt    time.Time               `json:"updated_at"`
-	mu           sync.RWMutex
+	Messages     []*types.ChatMessage    `json:"messages"` // Replaced, never modified in place, so copies stay valid
+	Summary      *Summary                `json:"summary,omitempty"`
+	Topic        string                  `json:"topic,omitempty"` // Current topic, the latest timeline value
+	Mood         string                  `json:"mood,omitempty"`  // Current mood, the latest timeline value
+	Timeline     []TopicMoodEntry        `json:"timeline"`
+	// PendingQuestion pauses agent chatter until the human answers
+	PendingQuestion *PendingQuestion `json:"pending_question,omitempty"`
+	// Phase is the active stage of a conversation with configured phases
+	Phase *Phase `json:"phase,omitempty"`
+	// Debate is the running or last finished debate
+	Debate *Debate `json:"debate,omitempty"`
+	// Tutoring is the running or last Socratic tutoring session
+	Tutoring *Tutoring `json:"tutoring,omitempty"`
+	// World is the shared state of a story told in the conversation
+	World *World `json:"world,omitempty"`
+	// Whiteboard is the document the participants write together
+	Whiteboard *Whiteboard `json:"whiteboard,omitempty"`
+	// WrapUp closes a conversation that went past the depth guard's caps
+	WrapUp *WrapUp `json:"wrap_up,omitempty"`
+	// ReopenedAt restarts the depth guard's count for a reopened conversation
+	ReopenedAt time.Time `json:"reopened_at,omitempty"`
+	// Private keeps agents from citing the conversation's messages in other conversations
+	Private bool `json:"private,omitempty"`
+	// Clustering groups the messages into the topics that emerged from their embeddings
+	Clustering *Clustering `json:"clustering,omitempty"`
+	CreatedAt  time.Time   `json:"created_at"`
+	UpdatedAt  time.Time   `json:"updated_at"`
+
+	messageIDs map[string]bool                 // IDs of the messages in history, to drop duplicates
+	receipts   map[string]map[string]time.Time // Message ID -> participant ID -> read time
+	ratings    map[string]map[string]Rating    // Message ID -> user ID -> rating
+	scratchpad []ScratchNote                   // Agents' reasoning, never part of the history
+	rewrites   int                             // Times messages were removed or replaced, see HistoryCursor
+	mu         sync.RWMutex
 }
 
 // Participant represents a conversation participant
@@ -32,10 +70,30 @@ type Participant struct {
 	LastSeen time.Time `json:"last_seen"`
 }
 
+// Summary is a digest of the conversation posted by a summarizer agent
+type Summary struct {
+	Content      string    `json:"content"`
+	AgentID      string    `json:"agent_id"`
+	MessageCount int       `json:"message_count"` // Number of messages covered by the digest
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// TopicMoodEntry records a change of topic and/or mood in a conversation
+type TopicMoodEntry struct {
+	Topic     string    `json:"topic"`
+	Mood      string    `json:"mood"`
+	MessageID string    `json:"message_id"` // Message that triggered the change
+	Timestamp time.Time `json:"timestamp"`
+}
+
 // NewManager creates a new conversation manager
 func NewManager() *Manager {
 	return &Manager{
 		conversations: make(map[string]*Conversation),
+		polls:         make(map[string]*Poll),
+		reminders:     make(map[string]*Reminder),
+		handoffs:      make(map[string]*Handoff),
+		sides:         make(map[string]*SideConversation),
 	}
 }
 
@@ -52,6 +110,8 @@ func (m *Manager) GetOrCreateConversation(conversationID string) *Conversation {
 		ID:           conversationID,
 		Participants: make(map[string]*Participant),
 		Messages:     make([]*types.ChatMessage, 0),
+		Timeline:     make([]TopicMoodEntry, 0),
+		messageIDs:   make(map[string]bool),
 		CreatedAt:    time.Now(),
 		UpdatedAt:    time.Now(),
 	}
@@ -62,11 +122,24 @@ func (m *Manager) GetOrCreateConversation(conversationID string) *Conversation {
 
 // AddMessage adds a message to a conversation
 func (m *Manager) AddMessage(conversationID string, message *types.ChatMessage) {
+	if message.Expired(time.Now()) {
+		return
+	}
+
 	conv := m.GetOrCreateConversation(conversationID)
 
 	conv.mu.Lock()
 	defer conv.mu.Unlock()
 
+	// Several consumers share the manager and Kafka may redeliver, so the
+	// same message can arrive more than once
+	if message.ID != "" {
+		if conv.messageIDs[message.ID] {
+			return
+		}
+		conv.messageIDs[message.ID] = true
+	}
+
 	conv.Messages = append(conv.Messages, message)
 	conv.UpdatedAt = time.Now()
 
@@ -83,6 +156,43 @@ func (m *Manager) AddMessage(conversationID string, message *types.ChatMessage)
 	}
 }
 
+// ExpireMessages removes ephemeral messages that have outlived their TTL
+// from a conversation and returns them
+func (m *Manager) ExpireMessages(conversationID string, now time.Time) []*types.ChatMessage {
+	conv := m.GetOrCreateConversation(conversationID)
+
+	conv.mu.Lock()
+	defer conv.mu.Unlock()
+
+	var expired []*types.ChatMessage
+	for _, message := range conv.Messages {
+		if message.Expired(now) {
+			expired = append(expired, message)
+		}
+	}
+	if len(expired) == 0 {
+		return nil
+	}
+
+	// Build a new slice; callers may still be reading the old one
+	kept := make([]*types.ChatMessage, 0, len(conv.Messages)-len(expired))
+	for _, message := range conv.Messages {
+		if !message.Expired(now) {
+			kept = append(kept, message)
+		}
+	}
+	conv.Messages = kept
+	conv.rewrites++
+
+	for _, message := range expired {
+		delete(conv.messageIDs, message.ID)
+		delete(conv.receipts, message.ID)
+		delete(conv.ratings, message.ID)
+	}
+
+	return expired
+}
+
 // AddParticipant adds a participant to a conversation
 func (m *Manager) AddParticipant(conversationID, participantID, name, participantType string) {
 	conv := m.GetOrCreateConversation(conversationID)
@@ -99,36 +209,60 @@ func (m *Manager) AddParticipant(conversationID, participantID, name, participan
 	}
 }
 
-// GetRecentMessages gets recent messages from a conversation
+// GetRecentMessages returns a copy of the most recent messages of a
+// conversation. The messages themselves are shared and must not be modified;
+// the manager replaces messages instead of changing them.
 func (m *Manager) GetRecentMessages(conversationID string, limit int) []*types.ChatMessage {
 	conv := m.GetOrCreateConversation(conversationID)
 
 	conv.mu.RLock()
 	defer conv.mu.RUnlock()
 
-	if len(conv.Messages) <= limit {
-		return conv.Messages
+	start := 0
+	if len(conv.Messages) > limit {
+		start = len(conv.Messages) - limit
 	}
+	return append([]*types.ChatMessage{}, conv.Messages[start:]...)
+}
+
+// GetMessages returns a copy of all messages of a conversation
+func (m *Manager) GetMessages(conversationID string) []*types.ChatMessage {
+	conv := m.GetOrCreateConversation(conversationID)
+
+	conv.mu.RLock()
+	defer conv.mu.RUnlock()
 
-	return conv.Messages[len(conv.Messages)-limit:]
+	return append([]*types.ChatMessage{}, conv.Messages...)
 }
 
-// IsRelevantToAgent checks if a message is relevant to a specific agent
-// Simplified version - all messages are potentially relevant
-func (m *Manager) IsRelevantToAgent(message *types.ChatMessage, agentID string, capabilities []string, personality string) bool {
-	// System messages are always relevant
-	if message.Type == types.MessageTypeSystem {
-		return true
-	}
+// HistoryCursor marks how much of a conversation's history a caller has read.
+// The zero cursor has read nothing.
+type HistoryCursor struct {
+	conv     *Conversation
+	rewrites int
+	read     int
+}
 
-	// Check if message is a direct reply to this agent
-	if message.Metadata.ReplyTo == agentID {
-		return true
-	}
+// GetMessagesSince returns a copy of the messages that followed the cursor,
+// so callers can extend a history they already hold, and the cursor to pass
+// next time. When messages were removed or replaced since (they expired or a
+// user's data was deleted) it returns all messages and false, and the caller
+// should start over.
+func (m *Manager) GetMessagesSince(conversationID string, cursor HistoryCursor) ([]*types.ChatMessage, HistoryCursor, bool) {
+	conv := m.GetOrCreateConversation(conversationID)
 
-	// All other messages are potentially relevant
-	// The agent's response chance will determine if it actually responds
-	return true
+	conv.mu.RLock()
+	defer conv.mu.RUnlock()
+
+	next := HistoryCursor{conv: conv, rewrites: conv.rewrites, read: len(conv.Messages)}
+	if cursor.conv == nil {
+		return append([]*types.ChatMessage{}, conv.Messages...), next, true
+	}
+	// A conversation restored from a snapshot is a new one
+	if cursor.conv != conv || cursor.rewrites != conv.rewrites {
+		return append([]*types.ChatMessage{}, conv.Messages...), next, false
+	}
+	return append([]*types.ChatMessage{}, conv.Messages[cursor.read:]...), next, true
 }
 
 // GetActiveParticipants gets active participants in a conversation
@@ -148,7 +282,86 @@ func (m *Manager) GetActiveParticipants(conversationID string) []*Participant {
 	return active
 }
 
+// GetParticipants returns copies of all participants of a conversation held
+// in memory, active or not, sorted by ID
+func (m *Manager) GetParticipants(conversationID string) []Participant {
+	conv, exists := m.lookup(conversationID)
+	if !exists {
+		return nil
+	}
+
+	conv.mu.RLock()
+	defer conv.mu.RUnlock()
+
+	participants := make([]Participant, 0, len(conv.Participants))
+	for _, participant := range conv.Participants {
+		participants = append(participants, *participant)
+	}
+	sort.Slice(participants, func(i, j int) bool {
+		return participants[i].ID < participants[j].ID
+	})
+	return participants
+}
+
 // GetConversationContext gets the current conversation context
 func (m *Manager) GetConversationContext(conversationID string) *Conversation {
 	return m.GetOrCreateConversation(conversationID)
 }
+
+// SetSummary stores the latest digest of a conversation
+func (m *Manager) SetSummary(conversationID string, summary *Summary) {
+	conv := m.GetOrCreateConversation(conversationID)
+
+	conv.mu.Lock()
+	defer conv.mu.Unlock()
+
+	conv.Summary = summary
+	conv.UpdatedAt = time.Now()
+}
+
+// GetSummary gets the latest digest of a conversation, or nil if none exists yet
+func (m *Manager) GetSummary(conversationID string) *Summary {
+	conv := m.GetOrCreateConversation(conversationID)
+
+	conv.mu.RLock()
+	defer conv.mu.RUnlock()
+
+	return conv.Summary
+}
+
+// UpdateTopicMood sets the current topic and mood of a conversation and
+// appends a timeline entry when either changes. An empty topic keeps the
+// current topic. It reports whether a new entry was added.
+func (m *Manager) UpdateTopicMood(conversationID, topic, mood, messageID string, at time.Time) bool {
+	conv := m.GetOrCreateConversation(conversationID)
+
+	conv.mu.Lock()
+	defer conv.mu.Unlock()
+
+	if topic == "" {
+		topic = conv.Topic
+	}
+	if topic == conv.Topic && mood == conv.Mood {
+		return false
+	}
+
+	conv.Topic = topic
+	conv.Mood = mood
+	conv.Timeline = append(conv.Timeline, TopicMoodEntry{
+		Topic:     topic,
+		Mood:      mood,
+		MessageID: messageID,
+		Timestamp: at,
+	})
+	return true
+}
+
+// GetTimeline returns a copy of the topic/mood timeline of a conversation
+func (m *Manager) GetTimeline(conversationID string) []TopicMoodEntry {
+	conv := m.GetOrCreateConversation(conversationID)
+
+	conv.mu.RLock()
+	defer conv.mu.RUnlock()
+
+	return append([]TopicMoodEntry{}, conv.Timeline...)
+}
@@ -0,0 +1,88 @@
+package conversation
+
+import (
+	"fmt"
+	"sort"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"philoking/internal/types"
+)
+
+func benchMessage(i int) *types.ChatMessage {
+	return &types.ChatMessage{
+		ID:        fmt.Sprintf("msg-%d", i),
+		Type:      types.MessageTypeUser,
+		Content:   "What is the good life, and can we know it when we live it?",
+		AgentID:   "user-1",
+		UserID:    "user-1",
+		Timestamp: time.Now(),
+		Metadata:  types.Metadata{ConversationID: "bench", FromAgent: "User"},
+	}
+}
+
+// reportLatency reports the throughput and the p99 of the measured operations
+func reportLatency(b *testing.B, latencies []time.Duration, elapsed time.Duration) {
+	if len(latencies) == 0 {
+		return
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	b.ReportMetric(float64(len(latencies))/elapsed.Seconds(), "ops/s")
+	b.ReportMetric(float64(latencies[len(latencies)*99/100].Nanoseconds()), "p99-ns")
+}
+
+func BenchmarkManagerAddMessage(b *testing.B) {
+	m := NewManager()
+	latencies := make([]time.Duration, b.N)
+
+	b.ResetTimer()
+	start := time.Now()
+	for i := 0; i < b.N; i++ {
+		t := time.Now()
+		m.AddMessage("bench", benchMessage(i))
+		latencies[i] = time.Since(t)
+	}
+	elapsed := time.Since(start)
+	b.StopTimer()
+
+	reportLatency(b, latencies, elapsed)
+}
+
+func BenchmarkManagerGetRecentMessages(b *testing.B) {
+	m := NewManager()
+	for i := 0; i < 1000; i++ {
+		m.AddMessage("bench", benchMessage(i))
+	}
+	latencies := make([]time.Duration, b.N)
+
+	b.ResetTimer()
+	start := time.Now()
+	for i := 0; i < b.N; i++ {
+		t := time.Now()
+		m.GetRecentMessages("bench", 1000)
+		latencies[i] = time.Since(t)
+	}
+	elapsed := time.Since(start)
+	b.StopTimer()
+
+	reportLatency(b, latencies, elapsed)
+}
+
+// BenchmarkManagerConcurrent mixes writers with agents reading history, as in a busy conversation
+func BenchmarkManagerConcurrent(b *testing.B) {
+	m := NewManager()
+	var next int64
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			i := int(atomic.AddInt64(&next, 1))
+			if i%4 == 0 {
+				m.AddMessage("bench", benchMessage(i))
+			} else {
+				m.GetRecentMessages("bench", 100)
+			}
+		}
+	})
+}
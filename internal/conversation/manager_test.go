@@ -0,0 +1,112 @@
+package conversation
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"philoking/internal/types"
+)
+
+func TestGetMessagesSince(t *testing.T) {
+	message := func(id, userID string) *types.ChatMessage {
+		return &types.ChatMessage{ID: id, Type: types.MessageTypeUser, Content: "about " + id, UserID: userID, Timestamp: time.Now()}
+	}
+	ids := func(messages []*types.ChatMessage) string {
+		var s []string
+		for _, m := range messages {
+			s = append(s, m.ID)
+		}
+		return fmt.Sprint(s)
+	}
+
+	tests := []struct {
+		name   string
+		change func(t *testing.T, m *Manager)
+		want   string
+		ok     bool
+	}{
+		{
+			name:   "nothing new",
+			change: func(t *testing.T, m *Manager) {},
+			want:   "[]",
+			ok:     true,
+		},
+		{
+			name: "new messages",
+			change: func(t *testing.T, m *Manager) {
+				m.AddMessage("c", message("m3", "alice"))
+				m.AddMessage("c", message("m2", "alice")) // Redelivered
+				m.AddMessage("c", message("m4", "bob"))
+			},
+			want: "[m3 m4]",
+			ok:   true,
+		},
+		{
+			name: "message expired",
+			change: func(t *testing.T, m *Manager) {
+				m.AddMessage("c", message("m3", "bob"))
+				m.ExpireMessages("c", time.Now().Add(2*time.Hour))
+			},
+			want: "[m2 m3]",
+		},
+		{
+			name: "user's messages scrubbed",
+			change: func(t *testing.T, m *Manager) {
+				m.scrubUser("alice")
+			},
+			want: "[m1 m2]",
+		},
+		{
+			name: "someone else's messages scrubbed",
+			change: func(t *testing.T, m *Manager) {
+				m.scrubUser("carol")
+			},
+			want: "[]",
+			ok:   true,
+		},
+		{
+			name: "archived and restored",
+			change: func(t *testing.T, m *Manager) {
+				snapshot, _ := m.Snapshot("c")
+				m.RemoveConversation("c")
+				if err := m.RestoreConversation(snapshot); err != nil {
+					t.Fatal(err)
+				}
+			},
+			want: "[m1 m2]",
+		},
+		{
+			name: "archived and started anew",
+			change: func(t *testing.T, m *Manager) {
+				m.RemoveConversation("c")
+				m.AddMessage("c", message("m5", "bob"))
+			},
+			want: "[m5]",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewManager()
+			ephemeral := message("m1", "alice")
+			expiresAt := time.Now().Add(time.Hour)
+			ephemeral.Metadata.ExpiresAt = &expiresAt
+			m.AddMessage("c", ephemeral)
+			m.AddMessage("c", message("m2", "bob"))
+
+			all, cursor, ok := m.GetMessagesSince("c", HistoryCursor{})
+			if !ok || ids(all) != "[m1 m2]" {
+				t.Fatalf("from the zero cursor: %s, %v", ids(all), ok)
+			}
+
+			tt.change(t, m)
+			got, next, ok := m.GetMessagesSince("c", cursor)
+			if ids(got) != tt.want || ok != tt.ok {
+				t.Errorf("GetMessagesSince = %s, %v; want %s, %v", ids(got), ok, tt.want, tt.ok)
+			}
+			if rest, _, ok := m.GetMessagesSince("c", next); !ok || len(rest) != 0 {
+				t.Errorf("from the next cursor: %s, %v; want nothing new", ids(rest), ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package conversation
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"philoking/internal/outbox"
+	"philoking/internal/types"
+)
+
+// pollBucket stores polls when the conversation flow has persistent storage
+const pollBucket = "polls"
+
+// UseOutbox persists the flow's state changes and publishes its messages
+// through the outbox, so the two can no longer diverge
+func (f *FlowManager) UseOutbox(o *outbox.Outbox) {
+	f.outbox = o
+	f.publisher = o
+}
+
+// commit persists a state change together with the messages announcing it.
+// Without an outbox the state lives in memory only and messages are published directly.
+func (f *FlowManager) commit(ctx context.Context, persist func(tx *outbox.Tx) error, messages ...*types.ChatMessage) error {
+	if f.outbox == nil {
+		for _, message := range messages {
+			if err := f.publisher.PublishMessage(ctx, message); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	return f.outbox.Update(func(tx *outbox.Tx) error {
+		if err := persist(tx); err != nil {
+			return err
+		}
+		for _, message := range messages {
+			if err := tx.Publish(message); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// persistPoll returns a persist step that stores the current state of a poll
+func (f *FlowManager) persistPoll(pollID string) func(tx *outbox.Tx) error {
+	return func(tx *outbox.Tx) error {
+		poll, ok := f.conversationManager.GetPoll(pollID)
+		if !ok {
+			return fmt.Errorf("poll %s not found", pollID)
+		}
+		return tx.Put(pollBucket, poll.ID, poll)
+	}
+}
+
+// restorePolls loads stored polls and schedules the closing of open ones
+func (f *FlowManager) restorePolls() error {
+	if f.outbox == nil {
+		return nil
+	}
+
+	store := f.outbox.Store()
+	ids, err := store.Keys(pollBucket)
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		var poll Poll
+		if ok, err := store.Get(pollBucket, id, &poll); err != nil || !ok {
+			log.Printf("Skipping unreadable poll %s: %v", id, err)
+			continue
+		}
+		if poll.Votes == nil {
+			poll.Votes = make(map[string]*Vote)
+		}
+		f.conversationManager.restorePoll(&poll)
+
+		if !poll.Closed {
+			f.scheduleClose(poll.ID, time.Until(poll.ClosesAt))
+		}
+	}
+
+	log.Printf("Restored %d poll(s) from storage", len(ids))
+	return nil
+}
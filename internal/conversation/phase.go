@@ -0,0 +1,233 @@
+package conversation
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"philoking/internal/config"
+	"philoking/internal/types"
+)
+
+// PhaseTag marks the moderator's phase announcements
+const PhaseTag = "phase"
+
+// Phase is the active stage of a conversation; agents outside it stay quiet
+type Phase struct {
+	Name      string    `json:"name"`
+	Index     int       `json:"index"` // Position among the configured phases
+	Total     int       `json:"total"`
+	Agents    []string  `json:"agents,omitempty"` // Agents allowed to speak; empty allows all
+	Prompt    string    `json:"prompt,omitempty"`
+	Messages  int       `json:"messages"` // Messages exchanged in this phase so far
+	StartedAt time.Time `json:"started_at"`
+}
+
+// Allows reports whether an agent may speak in the phase
+func (p *Phase) Allows(agentID string) bool {
+	if p == nil || len(p.Agents) == 0 {
+		return true
+	}
+	for _, id := range p.Agents {
+		if id == agentID {
+			return true
+		}
+	}
+	return false
+}
+
+// GetPhase returns a copy of the active phase of a conversation, or nil
+func (m *Manager) GetPhase(conversationID string) *Phase {
+	conv := m.GetOrCreateConversation(conversationID)
+
+	conv.mu.RLock()
+	defer conv.mu.RUnlock()
+
+	if conv.Phase == nil {
+		return nil
+	}
+	cp := *conv.Phase
+	cp.Agents = append([]string(nil), conv.Phase.Agents...)
+	return &cp
+}
+
+// replacePhase moves a conversation to the next phase, or ends its phases
+// when next is nil, provided it is still in the expected one (-1 for none);
+// timers and messages race to end a phase and only one of them may
+func (m *Manager) replacePhase(conversationID string, expected int, next *Phase) bool {
+	conv := m.GetOrCreateConversation(conversationID)
+
+	conv.mu.Lock()
+	defer conv.mu.Unlock()
+
+	current := -1
+	if conv.Phase != nil {
+		current = conv.Phase.Index
+	}
+	if expected != current {
+		return false
+	}
+	conv.Phase = next
+	return true
+}
+
+// countPhaseMessage counts a message towards the active phase and returns the
+// updated copy, or nil when the conversation has no phases
+func (m *Manager) countPhaseMessage(conversationID string) *Phase {
+	conv := m.GetOrCreateConversation(conversationID)
+
+	conv.mu.Lock()
+	defer conv.mu.Unlock()
+
+	if conv.Phase == nil {
+		return nil
+	}
+	conv.Phase.Messages++
+	cp := *conv.Phase
+	return &cp
+}
+
+// startPhases puts a conversation in its first phase, if phases are configured
+func (f *FlowManager) startPhases(ctx context.Context, conversationID string) {
+	if len(f.config.Phases) == 0 {
+		return
+	}
+	current := -1
+	if phase := f.conversationManager.GetPhase(conversationID); phase != nil {
+		current = phase.Index
+	}
+	f.enterPhase(ctx, conversationID, current, 0)
+}
+
+// enterPhase moves a conversation from phase `from` (-1 for none) to phase
+// `to`, ending the phases when `to` is past the last one, and announces it
+func (f *FlowManager) enterPhase(ctx context.Context, conversationID string, from, to int) {
+	var next *Phase
+	if to < len(f.config.Phases) {
+		cfg := f.config.Phases[to]
+		next = &Phase{
+			Name:      cfg.Name,
+			Index:     to,
+			Total:     len(f.config.Phases),
+			Agents:    append([]string(nil), cfg.Agents...),
+			Prompt:    cfg.Prompt,
+			StartedAt: time.Now(),
+		}
+	}
+	if !f.conversationManager.replacePhase(conversationID, from, next) {
+		return
+	}
+
+	var announcement *types.ChatMessage
+	if next == nil {
+		announcement = f.newSystemMessage("🏁 That was the last phase. The floor is open to everyone again.", conversationID)
+		log.Printf("Conversation %s finished its phases", conversationID)
+	} else {
+		announcement = f.newSystemMessage(f.phaseAnnouncement(next), conversationID)
+		announcement.Metadata.Custom = map[string]string{"phase": next.Name}
+		log.Printf("Conversation %s entered phase %s", conversationID, next.Name)
+
+		if duration := f.config.Phases[to].Duration; duration > 0 {
+			started := next.StartedAt
+			time.AfterFunc(duration, func() {
+				// The phase may have been left, or entered again, in the meantime
+				if phase := f.conversationManager.GetPhase(conversationID); phase != nil && phase.Index == to && phase.StartedAt.Equal(started) {
+					f.enterPhase(f.ctx, conversationID, to, to+1)
+				}
+			})
+		}
+	}
+	announcement.Metadata.Tags = []string{PhaseTag}
+	if err := f.publisher.PublishMessage(ctx, announcement); err != nil {
+		log.Printf("Failed to announce phase: %v", err)
+	}
+}
+
+// phaseAnnouncement describes a phase and who may speak in it
+func (f *FlowManager) phaseAnnouncement(phase *Phase) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "🔔 Phase %d of %d: %s.", phase.Index+1, phase.Total, phase.Name)
+	if phase.Prompt != "" {
+		fmt.Fprintf(&b, " %s", phase.Prompt)
+	}
+	if len(phase.Agents) > 0 {
+		names := make([]string, 0, len(phase.Agents))
+		for _, id := range phase.Agents {
+			names = append(names, f.participantName(id))
+		}
+		fmt.Fprintf(&b, " Speaking: %s.", strings.Join(names, ", "))
+	}
+	return b.String()
+}
+
+// handlePhaseMessage counts a discussion message and moves on once the phase
+// has had its number of messages
+func (f *FlowManager) handlePhaseMessage(ctx context.Context, message *types.ChatMessage, conversationID string) {
+	if message.IsCommand() || (message.Type != types.MessageTypeAgent && message.Type != types.MessageTypeUser) {
+		return
+	}
+
+	phase := f.conversationManager.countPhaseMessage(conversationID)
+	if phase == nil {
+		return
+	}
+	if limit := f.config.Phases[phase.Index].Messages; limit > 0 && phase.Messages >= limit {
+		f.enterPhase(ctx, conversationID, phase.Index, phase.Index+1)
+	}
+}
+
+// handlePhaseCommand handles "/phase", "/phase next", "/phase start" and "/phase <name>"
+func (f *FlowManager) handlePhaseCommand(ctx context.Context, message *types.ChatMessage, conversationID string) {
+	command := message.Metadata.Command
+	if len(f.config.Phases) == 0 {
+		f.replyCommandError(ctx, conversationID, command, fmt.Errorf("this conversation has no phases"))
+		return
+	}
+
+	phase := f.conversationManager.GetPhase(conversationID)
+	current := -1
+	if phase != nil {
+		current = phase.Index
+	}
+
+	if len(command.Args) == 0 {
+		status := "No phase is active. Use /phase start to begin."
+		if phase != nil {
+			status = fmt.Sprintf("%s (%d messages so far)", f.phaseAnnouncement(phase), phase.Messages)
+		}
+		if err := f.publisher.PublishMessage(ctx, f.newNotice(status, conversationID)); err != nil {
+			log.Printf("Failed to publish phase status: %v", err)
+		}
+		return
+	}
+
+	switch arg := strings.ToLower(strings.Join(command.Args, " ")); arg {
+	case "next":
+		if phase == nil {
+			f.replyCommandError(ctx, conversationID, command, fmt.Errorf("no phase is active; use /phase start"))
+			return
+		}
+		f.enterPhase(ctx, conversationID, current, current+1)
+	case "start":
+		f.enterPhase(ctx, conversationID, current, 0)
+	default:
+		for i, cfg := range f.config.Phases {
+			if strings.EqualFold(cfg.Name, arg) {
+				f.enterPhase(ctx, conversationID, current, i)
+				return
+			}
+		}
+		f.replyCommandError(ctx, conversationID, command, fmt.Errorf("unknown phase %q (phases: %s)", arg, strings.Join(phaseNames(f.config.Phases), ", ")))
+	}
+}
+
+// phaseNames lists the names of the configured phases
+func phaseNames(phases []config.PhaseConfig) []string {
+	names := make([]string, 0, len(phases))
+	for _, phase := range phases {
+		names = append(names, phase.Name)
+	}
+	return names
+}
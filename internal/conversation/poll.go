@@ -0,0 +1,309 @@
+package conversation
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"philoking/internal/types"
+
+	"github.com/google/uuid"
+)
+
+// Poll represents a question put to the participants of a conversation
+type Poll struct {
+	ID             string           `json:"id"`
+	ConversationID string           `json:"conversation_id"`
+	Question       string           `json:"question"`
+	Options        []string         `json:"options"`
+	Votes          map[string]*Vote `json:"votes"` // Keyed by voter ID, one vote per voter
+	CreatedBy      string           `json:"created_by"`
+	CreatedAt      time.Time        `json:"created_at"`
+	ClosesAt       time.Time        `json:"closes_at"`
+	Closed         bool             `json:"closed"`
+}
+
+// Vote is a single participant's choice in a poll
+type Vote struct {
+	VoterID   string    `json:"voter_id"`
+	VoterName string    `json:"voter_name"`
+	Option    int       `json:"option"` // 1-based option number
+	Reason    string    `json:"reason,omitempty"`
+	CastAt    time.Time `json:"cast_at"`
+}
+
+// Tally returns the number of votes per option, in option order
+func (p *Poll) Tally() []int {
+	counts := make([]int, len(p.Options))
+	for _, vote := range p.Votes {
+		counts[vote.Option-1]++
+	}
+	return counts
+}
+
+// CreatePoll creates a new open poll in a conversation
+func (m *Manager) CreatePoll(conversationID, question string, options []string, createdBy string, duration time.Duration) (*Poll, error) {
+	if question == "" {
+		return nil, fmt.Errorf("poll question is required")
+	}
+	if len(options) < 2 {
+		return nil, fmt.Errorf("a poll needs at least two options")
+	}
+
+	m.GetOrCreateConversation(conversationID)
+
+	now := time.Now()
+	poll := &Poll{
+		ID:             uuid.New().String()[:8],
+		ConversationID: conversationID,
+		Question:       question,
+		Options:        options,
+		Votes:          make(map[string]*Vote),
+		CreatedBy:      createdBy,
+		CreatedAt:      now,
+		ClosesAt:       now.Add(duration),
+	}
+
+	m.mu.Lock()
+	m.polls[poll.ID] = poll
+	m.mu.Unlock()
+
+	return poll, nil
+}
+
+// CastVote records a vote, replacing any earlier vote by the same voter
+func (m *Manager) CastVote(pollID string, vote *Vote) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	poll, exists := m.polls[pollID]
+	if !exists {
+		return fmt.Errorf("poll %s not found", pollID)
+	}
+	if poll.Closed {
+		return fmt.Errorf("poll %s is closed", pollID)
+	}
+	if vote.Option < 1 || vote.Option > len(poll.Options) {
+		return fmt.Errorf("option must be between 1 and %d", len(poll.Options))
+	}
+
+	vote.CastAt = time.Now()
+	poll.Votes[vote.VoterID] = vote
+	return nil
+}
+
+// ClosePoll closes a poll so no more votes are accepted and returns a snapshot of it
+func (m *Manager) ClosePoll(pollID string) (*Poll, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	poll, exists := m.polls[pollID]
+	if !exists {
+		return nil, fmt.Errorf("poll %s not found", pollID)
+	}
+	if poll.Closed {
+		return nil, fmt.Errorf("poll %s is already closed", pollID)
+	}
+
+	poll.Closed = true
+	return poll.snapshot(), nil
+}
+
+// GetPoll returns a snapshot of a poll by ID
+func (m *Manager) GetPoll(pollID string) (*Poll, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	poll, exists := m.polls[pollID]
+	if !exists {
+		return nil, false
+	}
+	return poll.snapshot(), true
+}
+
+// ListPolls returns snapshots of all polls in a conversation
+func (m *Manager) ListPolls(conversationID string) []*Poll {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	polls := make([]*Poll, 0)
+	for _, poll := range m.polls {
+		if poll.ConversationID == conversationID {
+			polls = append(polls, poll.snapshot())
+		}
+	}
+
+	sort.Slice(polls, func(i, j int) bool {
+		return polls[i].CreatedAt.Before(polls[j].CreatedAt)
+	})
+	return polls
+}
+
+// restorePoll adds a poll loaded from storage
+func (m *Manager) restorePoll(poll *Poll) {
+	m.GetOrCreateConversation(poll.ConversationID)
+
+	m.mu.Lock()
+	m.polls[poll.ID] = poll
+	m.mu.Unlock()
+}
+
+// snapshot copies a poll so callers can read it without holding the lock
+func (p *Poll) snapshot() *Poll {
+	cp := *p
+	cp.Options = append([]string(nil), p.Options...)
+	cp.Votes = make(map[string]*Vote, len(p.Votes))
+	for voterID, vote := range p.Votes {
+		v := *vote
+		cp.Votes[voterID] = &v
+	}
+	return &cp
+}
+
+// PollTag marks poll announcements; PollResultTag marks result announcements
+const (
+	PollTag       = "poll"
+	PollResultTag = "poll-result"
+)
+
+// DefaultPollDuration is how long a poll stays open when no duration is given
+const DefaultPollDuration = 2 * time.Minute
+
+// CreatePoll opens a poll, announces it to the conversation and schedules its closing
+func (f *FlowManager) CreatePoll(ctx context.Context, conversationID, question string, options []string, createdBy string, duration time.Duration) (*Poll, error) {
+	if duration <= 0 {
+		duration = DefaultPollDuration
+	}
+
+	poll, err := f.conversationManager.CreatePoll(conversationID, question, options, createdBy, duration)
+	if err != nil {
+		return nil, err
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "📊 Poll %s: %s", poll.ID, poll.Question)
+	for i, option := range poll.Options {
+		fmt.Fprintf(&b, "\n%d. %s", i+1, option)
+	}
+	fmt.Fprintf(&b, "\nVote with /vote %s <number> [reason] within %s.", poll.ID, duration)
+
+	announcement := f.newSystemMessage(b.String(), conversationID)
+	announcement.Metadata.Tags = []string{PollTag}
+	announcement.Metadata.Custom = map[string]string{"poll_id": poll.ID}
+	if err := f.commit(ctx, f.persistPoll(poll.ID), announcement); err != nil {
+		return poll, fmt.Errorf("failed to announce poll: %w", err)
+	}
+
+	f.scheduleClose(poll.ID, duration)
+
+	log.Printf("Created poll %s in conversation %s: %s", poll.ID, conversationID, question)
+	return poll, nil
+}
+
+// scheduleClose closes a poll and announces its results after the given delay
+func (f *FlowManager) scheduleClose(pollID string, delay time.Duration) {
+	time.AfterFunc(delay, func() {
+		if _, err := f.ClosePoll(f.ctx, pollID); err != nil {
+			log.Printf("Poll %s not closed by timer: %v", pollID, err)
+		}
+	})
+}
+
+// CastVote records a vote in a poll
+func (f *FlowManager) CastVote(pollID string, vote *Vote) error {
+	if err := f.conversationManager.CastVote(pollID, vote); err != nil {
+		return err
+	}
+	if err := f.commit(f.ctx, f.persistPoll(pollID)); err != nil {
+		log.Printf("Failed to persist vote in poll %s: %v", pollID, err)
+	}
+
+	log.Printf("%s voted %d in poll %s", vote.VoterName, vote.Option, pollID)
+	return nil
+}
+
+// ClosePoll closes a poll and announces the results
+func (f *FlowManager) ClosePoll(ctx context.Context, pollID string) (*Poll, error) {
+	poll, err := f.conversationManager.ClosePoll(pollID)
+	if err != nil {
+		return nil, err
+	}
+
+	tally := poll.Tally()
+	winner, best := -1, 0
+	var b strings.Builder
+	fmt.Fprintf(&b, "📊 Poll %s results: %s", poll.ID, poll.Question)
+	for i, option := range poll.Options {
+		fmt.Fprintf(&b, "\n%d. %s — %d vote(s)", i+1, option, tally[i])
+		if tally[i] > best {
+			winner, best = i, tally[i]
+		} else if tally[i] == best {
+			winner = -1 // Tie
+		}
+	}
+	switch {
+	case len(poll.Votes) == 0:
+		b.WriteString("\nNobody voted.")
+	case winner < 0:
+		b.WriteString("\nIt's a tie!")
+	default:
+		fmt.Fprintf(&b, "\nWinner: %s", poll.Options[winner])
+	}
+
+	result := f.newSystemMessage(b.String(), poll.ConversationID)
+	result.Metadata.Tags = []string{PollResultTag}
+	result.Metadata.Custom = map[string]string{"poll_id": poll.ID}
+	if err := f.commit(ctx, f.persistPoll(poll.ID), result); err != nil {
+		return poll, fmt.Errorf("failed to announce poll results: %w", err)
+	}
+
+	return poll, nil
+}
+
+// handlePollCommand handles /poll, /vote and /closepoll commands from the chat
+func (f *FlowManager) handlePollCommand(ctx context.Context, message *types.ChatMessage, conversationID string) {
+	command := message.Metadata.Command
+
+	var err error
+	switch command.Name {
+	case "poll":
+		parts := strings.Split(command.Raw, "|")
+		options := make([]string, 0, len(parts))
+		for _, option := range parts[1:] {
+			if option = strings.TrimSpace(option); option != "" {
+				options = append(options, option)
+			}
+		}
+		_, err = f.CreatePoll(ctx, conversationID, strings.TrimSpace(parts[0]), options, f.getParticipantID(message), 0)
+	case "vote":
+		if len(command.Args) < 2 {
+			err = fmt.Errorf("usage: /vote <poll id> <number> [reason]")
+			break
+		}
+		option, convErr := strconv.Atoi(command.Args[1])
+		if convErr != nil {
+			err = fmt.Errorf("invalid option %q", command.Args[1])
+			break
+		}
+		err = f.CastVote(command.Args[0], &Vote{
+			VoterID:   f.getParticipantID(message),
+			VoterName: message.Metadata.FromAgent,
+			Option:    option,
+			Reason:    strings.Join(command.Args[2:], " "),
+		})
+	case "closepoll":
+		if len(command.Args) < 1 {
+			err = fmt.Errorf("usage: /closepoll <poll id>")
+			break
+		}
+		_, err = f.ClosePoll(ctx, command.Args[0])
+	}
+
+	if err != nil {
+		f.replyCommandError(ctx, conversationID, command, err)
+	}
+}
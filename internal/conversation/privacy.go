@@ -0,0 +1,211 @@
+package conversation
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"philoking/internal/audit"
+	"philoking/internal/outbox"
+	"philoking/internal/types"
+)
+
+// DeletedTag marks a message whose content was scrubbed on the author's request
+const DeletedTag = "deleted"
+
+// tombstone replaces scrubbed message content and names
+const tombstone = "[deleted]"
+
+// DeletionReport describes what was scrubbed for a user data deletion request
+type DeletionReport struct {
+	UserID           string    `json:"user_id"`
+	MessagesScrubbed int       `json:"messages_scrubbed"`
+	Conversations    []string  `json:"conversations"`
+	VotesScrubbed    int       `json:"votes_scrubbed"`
+	DeletedAt        time.Time `json:"deleted_at"`
+	// Erased counts the records the erasers removed, by eraser name
+	Erased        map[string]int `json:"erased,omitempty"`
+	KafkaGuidance string         `json:"kafka_guidance"`
+}
+
+// Eraser removes a user's data kept outside the conversation state, such as
+// the embeddings of their messages, and returns how many records it removed
+type Eraser func(userID string) (int, error)
+
+// namedEraser is an eraser with the name it reports under
+type namedEraser struct {
+	name  string
+	erase Eraser
+}
+
+// UseAuditLog records privacy-relevant actions such as user data deletion in the audit trail
+func (f *FlowManager) UseAuditLog(l *audit.Log) {
+	f.auditLog = l
+}
+
+// UseEraser has user data deletion also erase the user's data that an eraser
+// keeps track of, reported under name
+func (f *FlowManager) UseEraser(name string, erase Eraser) {
+	f.erasers = append(f.erasers, namedEraser{name: name, erase: erase})
+}
+
+// scrubUser tombstones the messages of a user in every conversation, keeping
+// their IDs, types and timestamps, and forgets the user's read receipts and
+// participation. It returns the IDs of the scrubbed messages per conversation.
+func (m *Manager) scrubUser(userID string) map[string][]string {
+	m.mu.RLock()
+	conversations := make([]*Conversation, 0, len(m.conversations))
+	for _, conv := range m.conversations {
+		conversations = append(conversations, conv)
+	}
+	m.mu.RUnlock()
+
+	scrubbed := make(map[string][]string)
+	for _, conv := range conversations {
+		conv.mu.Lock()
+
+		// Replace the slice and the messages rather than editing them in
+		// place; readers may still hold the old ones
+		messages := make([]*types.ChatMessage, len(conv.Messages))
+		for i, message := range conv.Messages {
+			if message.UserID != userID {
+				messages[i] = message
+				continue
+			}
+			messages[i] = scrubMessage(message)
+			scrubbed[conv.ID] = append(scrubbed[conv.ID], message.ID)
+		}
+		conv.Messages = messages
+		if len(scrubbed[conv.ID]) > 0 {
+			conv.rewrites++
+		}
+
+		delete(conv.Participants, userID)
+		for _, readers := range conv.receipts {
+			delete(readers, userID)
+		}
+		for _, ratings := range conv.ratings {
+			delete(ratings, userID)
+		}
+
+		conv.mu.Unlock()
+	}
+	return scrubbed
+}
+
+// scrubVotes anonymizes a user's poll votes and returns the affected poll IDs
+func (m *Manager) scrubVotes(userID string) []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var pollIDs []string
+	for _, poll := range m.polls {
+		if vote, voted := poll.Votes[userID]; voted {
+			vote.VoterName = tombstone
+			vote.Reason = ""
+			pollIDs = append(pollIDs, poll.ID)
+		}
+	}
+	return pollIDs
+}
+
+// scrubMessage returns a tombstoned copy of a message that keeps its structure
+func scrubMessage(message *types.ChatMessage) *types.ChatMessage {
+	scrubbed := *message
+	scrubbed.Content = tombstone
+	scrubbed.Metadata.FromAgent = tombstone
+	scrubbed.Metadata.Command = nil
+	scrubbed.Metadata.Custom = nil
+	scrubbed.Metadata.Tags = append(append([]string{}, message.Metadata.Tags...), DeletedTag)
+	return &scrubbed
+}
+
+// forget anonymizes a participant in the analytics of every conversation
+func (t *analyticsTracker) forget(participantID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, state := range t.states {
+		if share, exists := state.participants[participantID]; exists {
+			share.Name = tombstone
+		}
+	}
+}
+
+// DeleteUserData scrubs a user's messages and personal data from the
+// conversation state, tells clients to drop the scrubbed messages and records
+// the deletion, requested by actor, in the audit trail.
+// Kafka keeps the original records until retention expires; the report
+// explains how to purge them sooner.
+func (f *FlowManager) DeleteUserData(ctx context.Context, userID, actor string) (*DeletionReport, error) {
+	if userID == "" {
+		return nil, fmt.Errorf("user id is required")
+	}
+
+	scrubbed := f.conversationManager.scrubUser(userID)
+	pollIDs := f.conversationManager.scrubVotes(userID)
+	f.analytics.forget(userID)
+
+	report := &DeletionReport{
+		UserID:        userID,
+		Conversations: make([]string, 0, len(scrubbed)),
+		VotesScrubbed: len(pollIDs),
+		DeletedAt:     time.Now(),
+	}
+
+	var events []*types.ChatMessage
+	for conversationID, messageIDs := range scrubbed {
+		report.Conversations = append(report.Conversations, conversationID)
+		report.MessagesScrubbed += len(messageIDs)
+		for _, messageID := range messageIDs {
+			events = append(events, newDeletionEvent(messageID, conversationID))
+		}
+	}
+
+	// Stored polls are rewritten together with the deletion events
+	persist := func(tx *outbox.Tx) error {
+		for _, pollID := range pollIDs {
+			if err := f.persistPoll(pollID)(tx); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := f.commit(ctx, persist, events...); err != nil {
+		return report, fmt.Errorf("user data scrubbed, but clients were not all notified: %w", err)
+	}
+
+	for _, eraser := range f.erasers {
+		erased, err := eraser.erase(userID)
+		if err != nil {
+			return report, fmt.Errorf("user data scrubbed, but the %s were not erased: %w", eraser.name, err)
+		}
+		if report.Erased == nil {
+			report.Erased = make(map[string]int)
+		}
+		report.Erased[eraser.name] = erased
+	}
+
+	report.KafkaGuidance = kafkaDeletionGuidance(f.kafkaClient.ConversationTopics())
+	log.Printf("Scrubbed data of user %s: %d message(s) in %d conversation(s), %d vote(s)",
+		userID, report.MessagesScrubbed, len(report.Conversations), report.VotesScrubbed)
+
+	if f.auditLog != nil {
+		record := audit.Record{Action: "user_data_deleted", Subject: userID, Actor: actor, Details: report, Timestamp: report.DeletedAt}
+		if err := f.auditLog.Write(record); err != nil {
+			return report, fmt.Errorf("user data scrubbed, but the audit record was not written: %w", err)
+		}
+	}
+	return report, nil
+}
+
+// kafkaDeletionGuidance explains how to purge the original records from Kafka
+func kafkaDeletionGuidance(topics []string) string {
+	return fmt.Sprintf("Kafka still holds the original records in %s until their retention expires. "+
+		"Chat records are not keyed, so log compaction cannot remove them; to purge them sooner, "+
+		"lower retention.ms on these topics or run kafka-delete-records.sh up to the current offsets. "+
+		"Archived copies in cold storage must be rehydrated and archived again to be scrubbed.",
+		strings.Join(topics, ", "))
+}
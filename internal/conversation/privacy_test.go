@@ -0,0 +1,212 @@
+package conversation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"philoking/internal/audit"
+	"philoking/internal/config"
+	"philoking/internal/kafka"
+	"philoking/internal/tap"
+	"philoking/internal/types"
+)
+
+// fakePublisher records the messages published, failing when err is set
+type fakePublisher struct {
+	mu        sync.Mutex
+	published []*types.ChatMessage
+	err       error
+}
+
+func (p *fakePublisher) PublishMessage(ctx context.Context, message *types.ChatMessage) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.err != nil {
+		return p.err
+	}
+	p.published = append(p.published, message)
+	return nil
+}
+
+func (p *fakePublisher) count() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.published)
+}
+
+func TestDeleteUserData(t *testing.T) {
+	errDown := errors.New("index unavailable")
+	tests := []struct {
+		name        string
+		publishErr  error
+		eraserErr   error
+		wantErr     string
+		wantErasers bool // Whether the erasers ran
+		wantAudit   bool
+	}{
+		{name: "deleted", wantErasers: true, wantAudit: true},
+		{name: "clients not notified", publishErr: errors.New("broker down"), wantErr: "clients were not all notified: broker down"},
+		{name: "eraser fails", eraserErr: errDown, wantErr: "user data scrubbed, but the embeddings were not erased: index unavailable", wantErasers: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kafkaClient, err := kafka.NewClient(config.KafkaConfig{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			manager := NewManager()
+			f := NewFlowManager(config.ConversationConfig{}, kafkaClient, manager)
+			publisher := &fakePublisher{err: tt.publishErr}
+			f.publisher = publisher
+			auditLog, err := audit.New(filepath.Join(t.TempDir(), "audit.jsonl"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			f.UseAuditLog(auditLog)
+
+			custom := map[string]string{"source": "mobile"}
+			manager.AddMessage("c1", &types.ChatMessage{ID: "m1", Type: types.MessageTypeUser, UserID: "alice", Content: "my address is ...", Timestamp: time.Now(), Metadata: types.Metadata{Custom: custom}})
+			manager.AddMessage("c1", &types.ChatMessage{ID: "m2", Type: types.MessageTypeUser, UserID: "bob", Content: "hi", Timestamp: time.Now()})
+			manager.AddMessage("c2", &types.ChatMessage{ID: "m3", Type: types.MessageTypeUser, UserID: "alice", Content: "me again", Timestamp: time.Now()})
+			poll, err := manager.CreatePoll("c1", "Is virtue teachable?", []string{"yes", "no"}, "bob", time.Hour)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := manager.CastVote(poll.ID, &Vote{VoterID: "alice", VoterName: "Alice", Option: 1, Reason: "Meno"}); err != nil {
+				t.Fatal(err)
+			}
+			original := manager.GetMessages("c1")
+
+			// The tap keeps the messages as they were, in the current and in rotated files
+			dir := t.TempDir()
+			tapFile := filepath.Join(dir, "conversations.jsonl")
+			rotated := filepath.Join(dir, "conversations-20250101T000000.jsonl")
+			data, _ := json.Marshal(&types.ChatMessage{ID: "m0", Type: types.MessageTypeUser, UserID: "alice", Content: "old news"})
+			if err := os.WriteFile(rotated, append(data, '\n'), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			conversationTap, err := tap.New(config.TapConfig{File: tapFile})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conversationTap.Close()
+			for _, message := range append(original, manager.GetMessages("c2")...) {
+				if err := conversationTap.Write(message); err != nil {
+					t.Fatal(err)
+				}
+			}
+			f.UseEraser("tapped messages", conversationTap.ForgetUser)
+
+			// Erasers run only once clients were told to drop the messages
+			var erasedAfter int
+			ran := false
+			f.UseEraser("embeddings", func(userID string) (int, error) {
+				ran = true
+				erasedAfter = publisher.count()
+				if userID != "alice" {
+					t.Errorf("erased %s, want alice", userID)
+				}
+				return 2, tt.eraserErr
+			})
+
+			report, err := f.DeleteUserData(context.Background(), "alice", "admin@example.com")
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("DeleteUserData = %v, want an error containing %q", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if ran != tt.wantErasers {
+				t.Fatalf("erasers ran = %v, want %v", ran, tt.wantErasers)
+			}
+			if ran && erasedAfter != 2 {
+				t.Errorf("the eraser ran after %d deletion events, want both", erasedAfter)
+			}
+
+			// The conversation state is scrubbed whether or not the rest succeeded
+			sort.Strings(report.Conversations)
+			if report.MessagesScrubbed != 2 || report.VotesScrubbed != 1 || strings.Join(report.Conversations, ",") != "c1,c2" {
+				t.Errorf("report %+v", report)
+			}
+			messages := manager.GetMessages("c1")
+			if scrubbed := messages[0]; scrubbed.Content != tombstone || scrubbed.Metadata.Custom != nil || !hasTag(scrubbed, DeletedTag) || scrubbed.ID != "m1" {
+				t.Errorf("message not scrubbed: %+v", scrubbed)
+			}
+			if messages[1].Content != "hi" {
+				t.Errorf("another user's message was scrubbed: %+v", messages[1])
+			}
+			if original[0].Content != "my address is ..." || custom["source"] != "mobile" {
+				t.Error("scrubbing edited a message a reader still holds")
+			}
+			if vote := poll.Votes["alice"]; vote.VoterName != tombstone || vote.Reason != "" || vote.Option != 1 {
+				t.Errorf("vote not scrubbed: %+v", vote)
+			}
+
+			if tt.publishErr == nil {
+				deleted := make(map[string]bool)
+				for _, event := range publisher.published {
+					if event.Type != types.MessageTypeDeletion {
+						t.Errorf("published %s, want deletion events", event.Type)
+					}
+					deleted[event.Metadata.ConversationID+"/"+event.Metadata.Custom[types.DeletedMessageKey]] = true
+				}
+				if len(deleted) != 2 || !deleted["c1/m1"] || !deleted["c2/m3"] {
+					t.Errorf("deletion events for %v", deleted)
+				}
+			}
+			if tt.wantErasers && tt.eraserErr == nil && (report.Erased["embeddings"] != 2 || report.Erased["tapped messages"] != 3) {
+				t.Errorf("erased %v, want 2 embeddings and 3 tapped messages", report.Erased)
+			}
+			if tt.wantErasers {
+				// The tap keeps taking messages after its files were rewritten
+				if err := conversationTap.Write(&types.ChatMessage{ID: "m4", Type: types.MessageTypeUser, UserID: "bob", Content: "bye"}); err != nil {
+					t.Fatal(err)
+				}
+				current, _ := os.ReadFile(tapFile)
+				old, _ := os.ReadFile(rotated)
+				if strings.Contains(string(current)+string(old), "alice") || !strings.Contains(string(current), `"hi"`) || !strings.Contains(string(current), `"bye"`) {
+					t.Errorf("tapped messages not scrubbed:\n%s%s", old, current)
+				}
+			}
+
+			records, err := auditLog.Records()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !tt.wantAudit {
+				if len(records) != 0 {
+					t.Errorf("audited a failed deletion: %+v", records)
+				}
+				return
+			}
+			if len(records) != 1 || records[0].Action != "user_data_deleted" || records[0].Subject != "alice" || records[0].Actor != "admin@example.com" {
+				t.Errorf("audit records %+v", records)
+			}
+		})
+	}
+}
+
+func TestDeleteUserDataRequiresUser(t *testing.T) {
+	f := NewFlowManager(config.ConversationConfig{}, nil, NewManager())
+	if _, err := f.DeleteUserData(context.Background(), "", "admin"); err == nil {
+		t.Error("DeleteUserData accepted an empty user ID")
+	}
+}
+
+func hasTag(message *types.ChatMessage, tag string) bool {
+	for _, t := range message.Metadata.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,142 @@
+package conversation
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"philoking/internal/types"
+)
+
+// DefaultQuestionTimeout is how long agents wait for the human when no timeout is configured
+const DefaultQuestionTimeout = 2 * time.Minute
+
+// PendingQuestion is a required question an agent put to the human; agent
+// chatter in the conversation pauses until it is answered or expires
+type PendingQuestion struct {
+	MessageID       string    `json:"message_id"`
+	AgentID         string    `json:"agent_id"`
+	Question        string    `json:"question"`
+	AskedAt         time.Time `json:"asked_at"`
+	ExpiresAt       time.Time `json:"expires_at"`
+	AnswerMessageID string    `json:"answer_message_id,omitempty"`
+}
+
+// SetPendingQuestion pauses a conversation on a question for the human
+func (m *Manager) SetPendingQuestion(conversationID string, question *PendingQuestion) {
+	conv := m.GetOrCreateConversation(conversationID)
+
+	conv.mu.Lock()
+	defer conv.mu.Unlock()
+
+	conv.PendingQuestion = question
+}
+
+// GetPendingQuestion returns a copy of the open question of a conversation, or nil
+func (m *Manager) GetPendingQuestion(conversationID string) *PendingQuestion {
+	conv := m.GetOrCreateConversation(conversationID)
+
+	conv.mu.RLock()
+	defer conv.mu.RUnlock()
+
+	if conv.PendingQuestion == nil {
+		return nil
+	}
+	cp := *conv.PendingQuestion
+	return &cp
+}
+
+// answerPendingQuestion records the human's answer if the question is still unanswered
+func (m *Manager) answerPendingQuestion(conversationID, answerMessageID string) bool {
+	conv := m.GetOrCreateConversation(conversationID)
+
+	conv.mu.Lock()
+	defer conv.mu.Unlock()
+
+	if conv.PendingQuestion == nil || conv.PendingQuestion.AnswerMessageID != "" {
+		return false
+	}
+	conv.PendingQuestion.AnswerMessageID = answerMessageID
+	return true
+}
+
+// clearPendingQuestion resumes the conversation if the given question is still pending
+func (m *Manager) clearPendingQuestion(conversationID, questionMessageID string) *PendingQuestion {
+	conv := m.GetOrCreateConversation(conversationID)
+
+	conv.mu.Lock()
+	defer conv.mu.Unlock()
+
+	question := conv.PendingQuestion
+	if question == nil || question.MessageID != questionMessageID {
+		return nil
+	}
+	conv.PendingQuestion = nil
+	return question
+}
+
+// AwaitsHuman decides how an agent treats a message while a question is
+// pending: only the asking agent may react, and only to the human's answer.
+// It returns false when the conversation is not paused.
+func (q *PendingQuestion) AwaitsHuman(agentID string, message *types.ChatMessage) (paused, mayRespond bool) {
+	if q == nil {
+		return false, false
+	}
+	return true, agentID == q.AgentID && message.Type == types.MessageTypeUser
+}
+
+// handleQuestionFlow pauses, resolves and expires human-in-the-loop questions
+func (f *FlowManager) handleQuestionFlow(ctx context.Context, message *types.ChatMessage, conversationID string) {
+	switch {
+	case message.Type == types.MessageTypeQuestion && message.Metadata.Required:
+		now := time.Now()
+		question := &PendingQuestion{
+			MessageID: message.ID,
+			AgentID:   message.AgentID,
+			Question:  message.Content,
+			AskedAt:   now,
+			ExpiresAt: now.Add(f.questionTimeout()),
+		}
+		f.conversationManager.SetPendingQuestion(conversationID, question)
+		log.Printf("Conversation %s paused for a question from %s", conversationID, message.AgentID)
+
+		time.AfterFunc(f.questionTimeout(), func() {
+			f.expireQuestion(conversationID, question.MessageID)
+		})
+
+	case message.Type == types.MessageTypeUser:
+		if f.conversationManager.answerPendingQuestion(conversationID, message.ID) {
+			log.Printf("Human answered the pending question in conversation %s", conversationID)
+		}
+
+	case message.Type == types.MessageTypeAgent:
+		// The asking agent's reply to the answer resumes the conversation
+		question := f.conversationManager.GetPendingQuestion(conversationID)
+		if question != nil && question.AnswerMessageID != "" && question.AgentID == message.AgentID {
+			f.conversationManager.clearPendingQuestion(conversationID, question.MessageID)
+			log.Printf("Conversation %s resumed after the question was answered", conversationID)
+		}
+	}
+}
+
+// expireQuestion resumes the conversation when nobody answered in time
+func (f *FlowManager) expireQuestion(conversationID, questionMessageID string) {
+	question := f.conversationManager.clearPendingQuestion(conversationID, questionMessageID)
+	if question == nil || question.AnswerMessageID != "" {
+		return
+	}
+
+	note := f.newNotice(fmt.Sprintf("⌛ No answer to %s's question, so the council carries on.", f.participantName(question.AgentID)), conversationID)
+	if err := f.publisher.PublishMessage(f.ctx, note); err != nil {
+		log.Printf("Failed to publish question timeout note: %v", err)
+	}
+}
+
+// questionTimeout returns the configured time to wait for a human answer
+func (f *FlowManager) questionTimeout() time.Duration {
+	if f.config.QuestionTimeout > 0 {
+		return f.config.QuestionTimeout
+	}
+	return DefaultQuestionTimeout
+}
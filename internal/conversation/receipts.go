@@ -0,0 +1,73 @@
+package conversation
+
+import (
+	"sort"
+	"time"
+)
+
+// ReadReceipt records that a participant consumed a message
+type ReadReceipt struct {
+	ParticipantID string    `json:"participant_id"`
+	ReadAt        time.Time `json:"read_at"`
+}
+
+// MarkRead records that a participant has read a message; it returns false if
+// the participant had already read it
+func (m *Manager) MarkRead(conversationID, messageID, participantID string) bool {
+	conv := m.GetOrCreateConversation(conversationID)
+
+	conv.mu.Lock()
+	defer conv.mu.Unlock()
+
+	if conv.receipts == nil {
+		conv.receipts = make(map[string]map[string]time.Time)
+	}
+	readers, exists := conv.receipts[messageID]
+	if !exists {
+		readers = make(map[string]time.Time)
+		conv.receipts[messageID] = readers
+	}
+	if _, read := readers[participantID]; read {
+		return false
+	}
+
+	readers[participantID] = time.Now()
+	return true
+}
+
+// GetReadReceipts returns who read a message, oldest receipt first
+func (m *Manager) GetReadReceipts(conversationID, messageID string) []ReadReceipt {
+	conv := m.GetOrCreateConversation(conversationID)
+
+	conv.mu.RLock()
+	defer conv.mu.RUnlock()
+
+	return sortedReceipts(conv.receipts[messageID])
+}
+
+// GetReadState returns the read receipts of every message in a conversation, keyed by message ID
+func (m *Manager) GetReadState(conversationID string) map[string][]ReadReceipt {
+	conv := m.GetOrCreateConversation(conversationID)
+
+	conv.mu.RLock()
+	defer conv.mu.RUnlock()
+
+	state := make(map[string][]ReadReceipt, len(conv.receipts))
+	for messageID, readers := range conv.receipts {
+		state[messageID] = sortedReceipts(readers)
+	}
+	return state
+}
+
+// sortedReceipts turns a reader map into receipts ordered by read time
+func sortedReceipts(readers map[string]time.Time) []ReadReceipt {
+	receipts := make([]ReadReceipt, 0, len(readers))
+	for participantID, readAt := range readers {
+		receipts = append(receipts, ReadReceipt{ParticipantID: participantID, ReadAt: readAt})
+	}
+
+	sort.Slice(receipts, func(i, j int) bool {
+		return receipts[i].ReadAt.Before(receipts[j].ReadAt)
+	})
+	return receipts
+}
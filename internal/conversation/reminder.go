@@ -0,0 +1,265 @@
+package conversation
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"philoking/internal/outbox"
+	"philoking/internal/types"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// ReminderTag marks the message a reminder posts when it is due
+	ReminderTag = "reminder"
+	// reminderBucket stores pending reminders when the conversation flow has persistent storage
+	reminderBucket = "reminders"
+	// maxReminderDelay bounds how far ahead a reminder can be set
+	maxReminderDelay = 366 * 24 * time.Hour
+)
+
+// Reminder is a message posted to a conversation at a later time
+type Reminder struct {
+	ID             string    `json:"id"`
+	ConversationID string    `json:"conversation_id"`
+	Text           string    `json:"text"`
+	DueAt          time.Time `json:"due_at"`
+	CreatedBy      string    `json:"created_by"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// ParseReminderTime parses when a reminder is due: a delay such as "90m",
+// "2h" or "3d", a clock time such as "17:30" (the next time it comes round)
+// or an RFC 3339 time
+func ParseReminderTime(when string, now time.Time) (time.Time, error) {
+	if days, ok := strings.CutSuffix(when, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil && n > 0 {
+			return now.AddDate(0, 0, n), nil
+		}
+	}
+	if delay, err := time.ParseDuration(when); err == nil {
+		if delay <= 0 {
+			return time.Time{}, fmt.Errorf("%q is not in the future", when)
+		}
+		return now.Add(delay), nil
+	}
+	if clock, err := time.ParseInLocation("15:04", when, now.Location()); err == nil {
+		at := time.Date(now.Year(), now.Month(), now.Day(), clock.Hour(), clock.Minute(), 0, 0, now.Location())
+		if !at.After(now) {
+			at = at.AddDate(0, 0, 1)
+		}
+		return at, nil
+	}
+	if at, err := time.Parse(time.RFC3339, when); err == nil {
+		if !at.After(now) {
+			return time.Time{}, fmt.Errorf("%s is in the past", when)
+		}
+		return at, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q; use a delay like 30m, 2h or 1d, a clock time like 17:30 or an RFC 3339 time", when)
+}
+
+// addReminder keeps a pending reminder
+func (m *Manager) addReminder(reminder *Reminder) {
+	m.GetOrCreateConversation(reminder.ConversationID)
+
+	m.mu.Lock()
+	m.reminders[reminder.ID] = reminder
+	m.mu.Unlock()
+}
+
+// takeReminder removes a pending reminder and returns it; of a reminder's
+// timer and its cancellation only the first gets it
+func (m *Manager) takeReminder(reminderID string) (*Reminder, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	reminder, exists := m.reminders[reminderID]
+	delete(m.reminders, reminderID)
+	return reminder, exists
+}
+
+// ListReminders returns the pending reminders of a conversation, the next one first
+func (m *Manager) ListReminders(conversationID string) []*Reminder {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var reminders []*Reminder
+	for _, reminder := range m.reminders {
+		if reminder.ConversationID == conversationID {
+			cp := *reminder
+			reminders = append(reminders, &cp)
+		}
+	}
+	sort.Slice(reminders, func(i, j int) bool {
+		return reminders[i].DueAt.Before(reminders[j].DueAt)
+	})
+	return reminders
+}
+
+// CreateReminder schedules a reminder, confirms it to the conversation and
+// stores it so it survives restarts
+func (f *FlowManager) CreateReminder(ctx context.Context, conversationID, text string, dueAt time.Time, createdBy string) (*Reminder, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil, fmt.Errorf("say what to remind about")
+	}
+	now := time.Now()
+	if !dueAt.After(now) {
+		return nil, fmt.Errorf("the reminder must be in the future")
+	}
+	if dueAt.Sub(now) > maxReminderDelay {
+		return nil, fmt.Errorf("reminders can be set at most %d days ahead", int(maxReminderDelay.Hours()/24))
+	}
+
+	reminder := &Reminder{
+		ID:             uuid.New().String()[:8],
+		ConversationID: conversationID,
+		Text:           text,
+		DueAt:          dueAt,
+		CreatedBy:      createdBy,
+		CreatedAt:      now,
+	}
+	f.conversationManager.addReminder(reminder)
+
+	confirmation := f.newSystemMessage(fmt.Sprintf("⏰ Reminder %s set for %s (in %s): %s",
+		reminder.ID, dueAt.Format("Mon 15:04"), dueAt.Sub(now).Round(time.Minute), text), conversationID)
+	confirmation.Metadata.Custom = map[string]string{"reminder_id": reminder.ID}
+	persist := func(tx *outbox.Tx) error {
+		return tx.Put(reminderBucket, reminder.ID, reminder)
+	}
+	if err := f.commit(ctx, persist, confirmation); err != nil {
+		f.conversationManager.takeReminder(reminder.ID)
+		return nil, fmt.Errorf("failed to store reminder: %w", err)
+	}
+
+	f.scheduleReminder(reminder)
+
+	log.Printf("Set reminder %s in conversation %s for %s", reminder.ID, conversationID, dueAt)
+	return reminder, nil
+}
+
+// scheduleReminder posts a reminder when it is due, or right away if it is overdue
+func (f *FlowManager) scheduleReminder(reminder *Reminder) {
+	time.AfterFunc(time.Until(reminder.DueAt), func() {
+		if err := f.fireReminder(f.ctx, reminder.ID); err != nil {
+			log.Printf("Reminder %s failed: %v", reminder.ID, err)
+		}
+	})
+}
+
+// fireReminder posts a due reminder to its conversation
+func (f *FlowManager) fireReminder(ctx context.Context, reminderID string) error {
+	reminder, exists := f.conversationManager.takeReminder(reminderID)
+	if !exists {
+		return nil // Cancelled
+	}
+
+	content := fmt.Sprintf("⏰ Reminder: %s", reminder.Text)
+	if name := f.participantName(reminder.CreatedBy); name != "" {
+		content += fmt.Sprintf(" (set by %s)", name)
+	}
+	if late := time.Since(reminder.DueAt); late > time.Minute {
+		content += fmt.Sprintf(" — due %s ago", late.Round(time.Minute))
+	}
+
+	message := f.newSystemMessage(content, reminder.ConversationID)
+	message.Metadata.Tags = []string{ReminderTag}
+	message.Metadata.Custom = map[string]string{"reminder_id": reminder.ID}
+	return f.commit(ctx, f.deleteReminder(reminder.ID), message)
+}
+
+// CancelReminder cancels a pending reminder
+func (f *FlowManager) CancelReminder(ctx context.Context, reminderID string) (*Reminder, error) {
+	reminder, exists := f.conversationManager.takeReminder(reminderID)
+	if !exists {
+		return nil, fmt.Errorf("no pending reminder %s", reminderID)
+	}
+
+	notice := f.newSystemMessage(fmt.Sprintf("⏰ Reminder %s cancelled: %s", reminder.ID, reminder.Text), reminder.ConversationID)
+	if err := f.commit(ctx, f.deleteReminder(reminder.ID), notice); err != nil {
+		return reminder, fmt.Errorf("failed to cancel reminder: %w", err)
+	}
+	return reminder, nil
+}
+
+// deleteReminder returns a persist step that forgets a stored reminder
+func (f *FlowManager) deleteReminder(reminderID string) func(tx *outbox.Tx) error {
+	return func(tx *outbox.Tx) error {
+		tx.Delete(reminderBucket, reminderID)
+		return nil
+	}
+}
+
+// restoreReminders loads stored reminders and schedules them again;
+// reminders that fell due while the app was down are posted right away
+func (f *FlowManager) restoreReminders() error {
+	if f.outbox == nil {
+		return nil
+	}
+
+	store := f.outbox.Store()
+	ids, err := store.Keys(reminderBucket)
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		var reminder Reminder
+		if ok, err := store.Get(reminderBucket, id, &reminder); err != nil || !ok {
+			log.Printf("Skipping unreadable reminder %s: %v", id, err)
+			continue
+		}
+		f.conversationManager.addReminder(&reminder)
+		f.scheduleReminder(&reminder)
+	}
+
+	log.Printf("Restored %d reminder(s) from storage", len(ids))
+	return nil
+}
+
+// handleReminderCommand handles /remind <when> <text>, /remind cancel <id> and /reminders
+func (f *FlowManager) handleReminderCommand(ctx context.Context, message *types.ChatMessage, conversationID string) {
+	command := message.Metadata.Command
+
+	var err error
+	switch {
+	case command.Name == "reminders":
+		err = f.listReminders(ctx, conversationID)
+	case len(command.Args) == 2 && command.Args[0] == "cancel":
+		_, err = f.CancelReminder(ctx, command.Args[1])
+	case len(command.Args) < 2:
+		err = fmt.Errorf("usage: /remind <when> <text>, e.g. /remind 2h check the oven, or /remind cancel <id>")
+	default:
+		var dueAt time.Time
+		if dueAt, err = ParseReminderTime(command.Args[0], time.Now()); err == nil {
+			text := strings.TrimSpace(strings.TrimPrefix(command.Raw, command.Args[0]))
+			_, err = f.CreateReminder(ctx, conversationID, text, dueAt, f.getParticipantID(message))
+		}
+	}
+
+	if err != nil {
+		f.replyCommandError(ctx, conversationID, command, err)
+	}
+}
+
+// listReminders posts the pending reminders of a conversation
+func (f *FlowManager) listReminders(ctx context.Context, conversationID string) error {
+	reminders := f.conversationManager.ListReminders(conversationID)
+	if len(reminders) == 0 {
+		return f.publisher.PublishMessage(ctx, f.newNotice("⏰ No pending reminders.", conversationID))
+	}
+
+	var b strings.Builder
+	b.WriteString("⏰ Pending reminders:")
+	for _, reminder := range reminders {
+		fmt.Fprintf(&b, "\n%s %s: %s", reminder.ID, reminder.DueAt.Format("Mon 15:04"), reminder.Text)
+	}
+	return f.publisher.PublishMessage(ctx, f.newNotice(b.String(), conversationID))
+}
@@ -0,0 +1,94 @@
+package conversation
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/rand"
+	"time"
+)
+
+// RevivalTag marks the moderator's prompts that revive a quiet conversation
+const RevivalTag = "revival"
+
+// watchSilence revives the watched conversations when they fall silent,
+// until ctx is done; conversationID is the main conversation
+func (f *FlowManager) watchSilence(ctx context.Context, conversationID string) {
+	idle := f.config.Revival.IdleAfter
+	if idle <= 0 {
+		return
+	}
+	watched := f.config.Revival.Conversations
+	if len(watched) == 0 {
+		watched = []string{conversationID}
+	}
+
+	ticker := time.NewTicker(max(min(idle/4, time.Minute), time.Second))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			for _, id := range watched {
+				if silence := f.silence(id, now); silence >= idle {
+					f.revive(ctx, id, silence)
+				}
+			}
+		}
+	}
+}
+
+// silence returns how long a conversation has been quiet; conversations
+// that are paused, run by the moderator or closed never count as quiet
+func (f *FlowManager) silence(conversationID string, now time.Time) time.Duration {
+	if f.conversationManager.GetWrapUp(conversationID) != nil ||
+		f.conversationManager.GetPendingQuestion(conversationID) != nil ||
+		f.conversationManager.GetActiveDebate(conversationID) != nil ||
+		f.conversationManager.GetActiveTutoring(conversationID) != nil {
+		return 0
+	}
+
+	last := f.conversationManager.GetRecentMessages(conversationID, 1)
+	if len(last) == 0 {
+		info, ok := f.conversationManager.GetInfo(conversationID)
+		if !ok {
+			return 0
+		}
+		return now.Sub(info.CreatedAt)
+	}
+	return now.Sub(last[0].Timestamp)
+}
+
+// revive asks the host agent, or a random one taking part, to pose a new
+// question related to the conversation's topic
+func (f *FlowManager) revive(ctx context.Context, conversationID string, silence time.Duration) {
+	host := f.config.Revival.Host
+	if participant, exists := f.participant(host); host != "" && (!exists || participant.Type != "agent") {
+		log.Printf("Revival host %s is not a registered agent, picking one at random", host)
+		host = ""
+	}
+	if host == "" {
+		agents := f.conversationAgents(conversationID)
+		if len(agents) == 0 {
+			return
+		}
+		host = agents[rand.Intn(len(agents))]
+	}
+
+	content := fmt.Sprintf("💤 It has been quiet for %d minutes. %s, get the conversation going again with a new question", int(silence.Minutes()), f.participantName(host))
+	if info, ok := f.conversationManager.GetInfo(conversationID); ok && info.Topic != "" {
+		content += fmt.Sprintf(" related to %s", info.Topic)
+	}
+	prompt := f.newSystemMessage(content+".", conversationID)
+	prompt.Metadata.ReplyTo = host
+	prompt.Metadata.Tags = []string{RevivalTag}
+	// Counting the prompt as activity gives the host a full idle period to answer
+	f.conversationManager.AddMessage(conversationID, prompt)
+	if err := f.publisher.PublishMessage(ctx, prompt); err != nil {
+		log.Printf("Failed to revive conversation %s: %v", conversationID, err)
+		return
+	}
+	log.Printf("Asked %s to revive conversation %s after %s of silence", host, conversationID, silence.Round(time.Second))
+}
@@ -0,0 +1,60 @@
+package conversation
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// maxScratchNotes bounds the scratch notes kept per conversation
+const maxScratchNotes = 500
+
+// ScratchNote is the reasoning an agent did before answering. Scratch notes
+// are kept apart from the messages: they are never published, shown to
+// clients or exported, and only admins can read them.
+type ScratchNote struct {
+	ID             string    `json:"id"`
+	ConversationID string    `json:"conversation_id"`
+	AgentID        string    `json:"agent_id"`
+	Content        string    `json:"content"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// AddScratchNote keeps an agent's reasoning, dropping the oldest notes of the conversation beyond maxScratchNotes
+func (m *Manager) AddScratchNote(conversationID, agentID, content string) {
+	conv := m.GetOrCreateConversation(conversationID)
+
+	conv.mu.Lock()
+	defer conv.mu.Unlock()
+
+	conv.scratchpad = append(conv.scratchpad, ScratchNote{
+		ID:             uuid.New().String(),
+		ConversationID: conversationID,
+		AgentID:        agentID,
+		Content:        content,
+		CreatedAt:      time.Now(),
+	})
+	if len(conv.scratchpad) > maxScratchNotes {
+		conv.scratchpad = append([]ScratchNote(nil), conv.scratchpad[len(conv.scratchpad)-maxScratchNotes:]...)
+	}
+}
+
+// ScratchNotes returns the scratch notes of a conversation, oldest first,
+// limited to one agent unless agentID is empty
+func (m *Manager) ScratchNotes(conversationID, agentID string) []ScratchNote {
+	notes := make([]ScratchNote, 0)
+	conv, exists := m.lookup(conversationID)
+	if !exists {
+		return notes
+	}
+
+	conv.mu.RLock()
+	defer conv.mu.RUnlock()
+
+	for _, note := range conv.scratchpad {
+		if agentID == "" || note.AgentID == agentID {
+			notes = append(notes, note)
+		}
+	}
+	return notes
+}
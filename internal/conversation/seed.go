@@ -0,0 +1,152 @@
+package conversation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"philoking/internal/outbox"
+	"philoking/internal/types"
+
+	"github.com/google/uuid"
+)
+
+// SeededTag marks messages imported from a transcript; agents read them as
+// history but don't reply to them
+const SeededTag = "seeded"
+
+// Transcript is a prior conversation to import
+type Transcript struct {
+	ConversationID string              `json:"conversation_id,omitempty"`
+	Messages       []TranscriptMessage `json:"messages"`
+}
+
+// TranscriptMessage is one message of a transcript; only the content is required
+type TranscriptMessage struct {
+	ID        string            `json:"id,omitempty"`
+	Type      types.MessageType `json:"type,omitempty"` // Defaults to "agent" when an agent_id is given, else "user"
+	From      string            `json:"from,omitempty"` // Display name of the sender
+	AgentID   string            `json:"agent_id,omitempty"`
+	UserID    string            `json:"user_id,omitempty"`
+	Content   string            `json:"content"`
+	Timestamp time.Time         `json:"timestamp,omitempty"`
+}
+
+// ParseTranscript reads a transcript: either an object with a messages list,
+// or a bare list of messages such as an exported chat history
+func ParseTranscript(data []byte) (*Transcript, error) {
+	var transcript Transcript
+	if trimmed := strings.TrimSpace(string(data)); strings.HasPrefix(trimmed, "[") {
+		if err := json.Unmarshal(data, &transcript.Messages); err != nil {
+			return nil, fmt.Errorf("invalid transcript: %w", err)
+		}
+	} else if err := json.Unmarshal(data, &transcript); err != nil {
+		return nil, fmt.Errorf("invalid transcript: %w", err)
+	}
+
+	if len(transcript.Messages) == 0 {
+		return nil, fmt.Errorf("transcript has no messages")
+	}
+	for i, message := range transcript.Messages {
+		if strings.TrimSpace(message.Content) == "" {
+			return nil, fmt.Errorf("transcript message %d has no content", i+1)
+		}
+	}
+	return &transcript, nil
+}
+
+// ChatMessages turns the transcript into chat messages for a conversation.
+// Messages keep their IDs and timestamps when the transcript has them;
+// missing timestamps are spread over the seconds before now, in order.
+func (t *Transcript) ChatMessages(conversationID string) []*types.ChatMessage {
+	now := time.Now()
+	messages := make([]*types.ChatMessage, 0, len(t.Messages))
+	for i, m := range t.Messages {
+		message := &types.ChatMessage{
+			ID:        m.ID,
+			Type:      m.Type,
+			Content:   m.Content,
+			AgentID:   m.AgentID,
+			UserID:    m.UserID,
+			Timestamp: m.Timestamp,
+			Metadata: types.Metadata{
+				ConversationID: conversationID,
+				FromAgent:      m.From,
+				Tags:           []string{SeededTag},
+			},
+		}
+		if message.ID == "" {
+			message.ID = uuid.New().String()
+		}
+		if message.Type == "" {
+			message.Type = types.MessageTypeUser
+			if m.AgentID != "" {
+				message.Type = types.MessageTypeAgent
+			}
+		}
+		if message.AgentID == "" {
+			message.AgentID = message.UserID
+		}
+		if message.AgentID == "" {
+			message.AgentID = "seed-" + strings.ToLower(strings.ReplaceAll(m.From, " ", "-"))
+		}
+		if message.Metadata.FromAgent == "" {
+			message.Metadata.FromAgent = message.AgentID
+		}
+		if message.Timestamp.IsZero() {
+			message.Timestamp = now.Add(time.Duration(i-len(t.Messages)) * time.Second)
+		}
+		messages = append(messages, message)
+	}
+	return messages
+}
+
+// Seed publishes the messages of an imported transcript, followed by a
+// moderator message that invites the agents to carry on. It returns the
+// number of imported messages.
+func Seed(ctx context.Context, publisher outbox.Publisher, messages []*types.ChatMessage, conversationID string) (int, error) {
+	for i, message := range messages {
+		if err := publisher.PublishMessage(ctx, message); err != nil {
+			return i, fmt.Errorf("failed to publish transcript message %d: %w", i+1, err)
+		}
+	}
+
+	invitation := &types.ChatMessage{
+		ID:        uuid.New().String(),
+		Type:      types.MessageTypeSystem,
+		Content:   fmt.Sprintf("📥 Imported %d messages from an earlier discussion. Please pick up where it left off.", len(messages)),
+		AgentID:   "system",
+		Timestamp: time.Now(),
+		Metadata: types.Metadata{
+			ConversationID: conversationID,
+			FromAgent:      "Moderator",
+		},
+	}
+	if err := publisher.PublishMessage(ctx, invitation); err != nil {
+		return len(messages), fmt.Errorf("failed to publish the invitation to continue: %w", err)
+	}
+
+	log.Printf("Seeded conversation %s with %d messages", conversationID, len(messages))
+	return len(messages), nil
+}
+
+// Seed imports a transcript into the conversation store and publishes it, so
+// agents continue the imported discussion
+func (f *FlowManager) Seed(ctx context.Context, transcript *Transcript, conversationID string) (int, error) {
+	if conversationID == "" {
+		conversationID = transcript.ConversationID
+	}
+	if conversationID == "" {
+		return 0, fmt.Errorf("no conversation to seed")
+	}
+
+	// Load the history right away; the copies coming back from Kafka are dropped as duplicates
+	messages := transcript.ChatMessages(conversationID)
+	for _, message := range messages {
+		f.conversationManager.AddMessage(conversationID, message)
+	}
+	return Seed(ctx, f.publisher, messages, conversationID)
+}
@@ -0,0 +1,262 @@
+package conversation
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"philoking/internal/types"
+
+	"github.com/google/uuid"
+)
+
+// SideStatus is the lifecycle state of a side conversation
+type SideStatus string
+
+const (
+	SideStatusOpen       SideStatus = "open"        // Participants are discussing
+	SideStatusWrappingUp SideStatus = "wrapping_up" // The first participant is writing the joint summary
+	SideStatusClosed     SideStatus = "closed"      // The summary was posted to the parent conversation
+)
+
+// SideConversationTag marks messages that coordinate a side conversation
+const SideConversationTag = "side-conversation"
+
+// DefaultSideTurns is the number of messages exchanged before wrapping up
+const DefaultSideTurns = 6
+
+// SideConversation is a private exchange between two agents spun off from a
+// parent conversation to work out a detail
+type SideConversation struct {
+	ID           string     `json:"id"`
+	ParentID     string     `json:"parent_id"`
+	Topic        string     `json:"topic"`
+	Participants []string   `json:"participants"` // Agent IDs; the first one starts and summarizes
+	MaxTurns     int        `json:"max_turns"`
+	Turns        int        `json:"turns"`
+	Status       SideStatus `json:"status"`
+	Summary      string     `json:"summary,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+// IsParticipant reports whether the agent takes part in the side conversation
+func (s *SideConversation) IsParticipant(agentID string) bool {
+	for _, p := range s.Participants {
+		if p == agentID {
+			return true
+		}
+	}
+	return false
+}
+
+// ShouldRespond decides whether an agent must answer a message in the side
+// conversation. Participants always answer each other while it is open;
+// addressed system messages (the opening and the summary request) go to a
+// single participant. Everybody else stays out.
+func (s *SideConversation) ShouldRespond(agentID string, message *types.ChatMessage) bool {
+	if !s.IsParticipant(agentID) || message.AgentID == agentID {
+		return false
+	}
+
+	switch s.Status {
+	case SideStatusOpen:
+		if message.Type == types.MessageTypeSystem {
+			return message.Metadata.ReplyTo == agentID
+		}
+		return s.IsParticipant(message.AgentID)
+	case SideStatusWrappingUp:
+		return message.Type == types.MessageTypeSystem && message.Metadata.ReplyTo == agentID
+	default:
+		return false
+	}
+}
+
+// CreateSideConversation creates a side conversation between agents under a parent conversation
+func (m *Manager) CreateSideConversation(parentID, topic string, participants []string, maxTurns int) (*SideConversation, error) {
+	if len(participants) != 2 || participants[0] == participants[1] {
+		return nil, fmt.Errorf("a side conversation needs two different agents")
+	}
+	if topic == "" {
+		return nil, fmt.Errorf("a side conversation needs a topic")
+	}
+	if maxTurns <= 0 {
+		maxTurns = DefaultSideTurns
+	}
+
+	side := &SideConversation{
+		ID:           "side-" + uuid.New().String()[:8],
+		ParentID:     parentID,
+		Topic:        topic,
+		Participants: append([]string(nil), participants...),
+		MaxTurns:     maxTurns,
+		Status:       SideStatusOpen,
+		CreatedAt:    time.Now(),
+	}
+
+	m.GetOrCreateConversation(parentID)
+	m.GetOrCreateConversation(side.ID)
+
+	m.mu.Lock()
+	m.sides[side.ID] = side
+	m.mu.Unlock()
+
+	return side, nil
+}
+
+// GetSideConversation returns a snapshot of a side conversation
+func (m *Manager) GetSideConversation(id string) (*SideConversation, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	side, exists := m.sides[id]
+	if !exists {
+		return nil, false
+	}
+	cp := *side
+	cp.Participants = append([]string(nil), side.Participants...)
+	return &cp, true
+}
+
+// ListSideConversations returns the side conversations of a parent conversation
+func (m *Manager) ListSideConversations(parentID string) []*SideConversation {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	sides := make([]*SideConversation, 0)
+	for _, side := range m.sides {
+		if side.ParentID == parentID {
+			cp := *side
+			cp.Participants = append([]string(nil), side.Participants...)
+			sides = append(sides, &cp)
+		}
+	}
+
+	sort.Slice(sides, func(i, j int) bool {
+		return sides[i].CreatedAt.Before(sides[j].CreatedAt)
+	})
+	return sides
+}
+
+// advanceSideConversation counts a participant's turn and moves the side
+// conversation to its next status; it returns the updated snapshot
+func (m *Manager) advanceSideConversation(id string, message *types.ChatMessage) (*SideConversation, SideStatus) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	side, exists := m.sides[id]
+	if !exists || !side.IsParticipant(message.AgentID) {
+		return nil, ""
+	}
+
+	previous := side.Status
+	switch side.Status {
+	case SideStatusOpen:
+		side.Turns++
+		if side.Turns >= side.MaxTurns {
+			side.Status = SideStatusWrappingUp
+		}
+	case SideStatusWrappingUp:
+		if message.AgentID == side.Participants[0] {
+			side.Summary = message.Content
+			side.Status = SideStatusClosed
+		}
+	}
+
+	if side.Status == previous {
+		return nil, ""
+	}
+	cp := *side
+	cp.Participants = append([]string(nil), side.Participants...)
+	return &cp, side.Status
+}
+
+// StartSideConversation spins off a side conversation between two agents and
+// announces it in the parent conversation
+func (f *FlowManager) StartSideConversation(ctx context.Context, parentID, topic string, agentIDs []string, maxTurns int) (*SideConversation, error) {
+	for _, agentID := range agentIDs {
+		if participant, exists := f.participant(agentID); !exists || participant.Type != "agent" {
+			return nil, fmt.Errorf("unknown agent %q", agentID)
+		}
+	}
+
+	side, err := f.conversationManager.CreateSideConversation(parentID, topic, agentIDs, maxTurns)
+	if err != nil {
+		return nil, err
+	}
+
+	first, second := f.participantName(side.Participants[0]), f.participantName(side.Participants[1])
+
+	announcement := f.newSystemMessage(fmt.Sprintf("🔀 %s and %s stepped aside to work out: %s", first, second, topic), parentID)
+	announcement.Metadata.Tags = []string{SideConversationTag}
+	announcement.Metadata.Custom = map[string]string{"side_conversation_id": side.ID}
+	if err := f.publisher.PublishMessage(ctx, announcement); err != nil {
+		return side, fmt.Errorf("failed to announce side conversation: %w", err)
+	}
+
+	opening := f.newSystemMessage(fmt.Sprintf(
+		"This is a private side conversation between %s and %s to work out: %s. %s, you start. Keep it focused; after %d messages you will be asked for a joint summary for the group.",
+		first, second, topic, first, side.MaxTurns), side.ID)
+	opening.Metadata.ReplyTo = side.Participants[0]
+	opening.Metadata.Tags = []string{SideConversationTag}
+	opening.Metadata.Custom = map[string]string{"parent_conversation_id": parentID}
+	if err := f.publisher.PublishMessage(ctx, opening); err != nil {
+		return side, fmt.Errorf("failed to open side conversation: %w", err)
+	}
+
+	log.Printf("Started side conversation %s between %s on: %s", side.ID, strings.Join(side.Participants, " and "), topic)
+	return side, nil
+}
+
+// handleSideMessage advances a side conversation when one of its participants speaks
+func (f *FlowManager) handleSideMessage(ctx context.Context, message *types.ChatMessage, conversationID string) {
+	side, status := f.conversationManager.advanceSideConversation(conversationID, message)
+	if side == nil {
+		return
+	}
+
+	first, second := f.participantName(side.Participants[0]), f.participantName(side.Participants[1])
+
+	switch status {
+	case SideStatusWrappingUp:
+		request := f.newSystemMessage(fmt.Sprintf(
+			"Time to wrap up. %s, write a short joint summary of what you and %s worked out, addressed to the whole group.", first, second), side.ID)
+		request.Metadata.ReplyTo = side.Participants[0]
+		request.Metadata.Tags = []string{SideConversationTag}
+		if err := f.publisher.PublishMessage(ctx, request); err != nil {
+			log.Printf("Failed to request side conversation summary: %v", err)
+		}
+	case SideStatusClosed:
+		summary := f.newSystemMessage(fmt.Sprintf("🔁 %s & %s on \"%s\": %s", first, second, side.Topic, side.Summary), side.ParentID)
+		summary.Metadata.Tags = []string{SideConversationTag}
+		summary.Metadata.Custom = map[string]string{"side_conversation_id": side.ID}
+		if err := f.publisher.PublishMessage(ctx, summary); err != nil {
+			log.Printf("Failed to post side conversation summary: %v", err)
+		}
+		log.Printf("Closed side conversation %s", side.ID)
+	}
+}
+
+// handleSideCommand handles "/aside <agent> <agent> <topic>"
+func (f *FlowManager) handleSideCommand(ctx context.Context, message *types.ChatMessage, conversationID string) {
+	command := message.Metadata.Command
+	if len(command.Args) < 3 {
+		f.replyCommandError(ctx, conversationID, command, fmt.Errorf("usage: /aside <agent id> <agent id> <topic>"))
+		return
+	}
+
+	topic := strings.Join(command.Args[2:], " ")
+	if _, err := f.StartSideConversation(ctx, conversationID, topic, command.Args[:2], 0); err != nil {
+		f.replyCommandError(ctx, conversationID, command, err)
+	}
+}
+
+// participantName returns the display name of a registered participant
+func (f *FlowManager) participantName(participantID string) string {
+	if participant, exists := f.participant(participantID); exists {
+		return participant.Name
+	}
+	return participantID
+}
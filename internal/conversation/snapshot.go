@@ -0,0 +1,156 @@
+package conversation
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"philoking/internal/types"
+)
+
+// Snapshot is a self-contained copy of a conversation, used to move it out of
+// memory and back, e.g. when archiving
+type Snapshot struct {
+	ID           string                  `json:"id"`
+	Title        string                  `json:"title,omitempty"`
+	Goal         string                  `json:"goal,omitempty"`
+	Members      []string                `json:"members,omitempty"`
+	Participants map[string]*Participant `json:"participants"`
+	Summary      *Summary                `json:"summary,omitempty"`
+	Topic        string                  `json:"topic,omitempty"`
+	Mood         string                  `json:"mood,omitempty"`
+	Timeline     []TopicMoodEntry        `json:"timeline"`
+	World        *World                  `json:"world,omitempty"`
+	Whiteboard   *Whiteboard             `json:"whiteboard,omitempty"`
+	WrapUp       *WrapUp                 `json:"wrap_up,omitempty"`
+	ReopenedAt   time.Time               `json:"reopened_at,omitempty"`
+	Clustering   *Clustering             `json:"clustering,omitempty"`
+	Private      bool                    `json:"private,omitempty"`
+	CreatedAt    time.Time               `json:"created_at"`
+	UpdatedAt    time.Time               `json:"updated_at"`
+	Messages     []*types.ChatMessage    `json:"-"` // Stored separately, one per line
+}
+
+// ListConversations returns the IDs of the conversations held in memory, sorted
+func (m *Manager) ListConversations() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	ids := make([]string, 0, len(m.conversations))
+	for id := range m.conversations {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// HasConversation reports whether a conversation is held in memory
+func (m *Manager) HasConversation(conversationID string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	_, exists := m.conversations[conversationID]
+	return exists
+}
+
+// Snapshot copies a conversation held in memory
+func (m *Manager) Snapshot(conversationID string) (*Snapshot, bool) {
+	m.mu.RLock()
+	conv, exists := m.conversations[conversationID]
+	m.mu.RUnlock()
+	if !exists {
+		return nil, false
+	}
+
+	conv.mu.RLock()
+	defer conv.mu.RUnlock()
+
+	participants := make(map[string]*Participant, len(conv.Participants))
+	for id, participant := range conv.Participants {
+		p := *participant
+		participants[id] = &p
+	}
+
+	return &Snapshot{
+		ID:           conv.ID,
+		Title:        conv.Title,
+		Goal:         conv.Goal,
+		Members:      append([]string(nil), conv.Members...),
+		Participants: participants,
+		Summary:      conv.Summary,
+		Topic:        conv.Topic,
+		Mood:         conv.Mood,
+		Timeline:     append([]TopicMoodEntry{}, conv.Timeline...),
+		World:        conv.World,
+		Whiteboard:   conv.Whiteboard,
+		WrapUp:       conv.WrapUp.copy(),
+		ReopenedAt:   conv.ReopenedAt,
+		Clustering:   conv.Clustering.copy(),
+		Private:      conv.Private,
+		CreatedAt:    conv.CreatedAt,
+		UpdatedAt:    conv.UpdatedAt,
+		Messages:     append([]*types.ChatMessage{}, conv.Messages...),
+	}, true
+}
+
+// RemoveConversation drops a conversation from memory
+func (m *Manager) RemoveConversation(conversationID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.conversations, conversationID)
+}
+
+// RestoreConversation loads a snapshot back into memory. It fails if the
+// conversation has received messages again in the meantime.
+func (m *Manager) RestoreConversation(snapshot *Snapshot) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Lookups recreate conversations on the fly, so an empty one may exist
+	if existing, exists := m.conversations[snapshot.ID]; exists {
+		existing.mu.RLock()
+		active := len(existing.Messages) > 0
+		existing.mu.RUnlock()
+		if active {
+			return fmt.Errorf("conversation %s is already loaded", snapshot.ID)
+		}
+	}
+
+	conv := &Conversation{
+		ID:           snapshot.ID,
+		Title:        snapshot.Title,
+		Goal:         snapshot.Goal,
+		Members:      snapshot.Members,
+		Participants: snapshot.Participants,
+		Messages:     snapshot.Messages,
+		Summary:      snapshot.Summary,
+		Topic:        snapshot.Topic,
+		Mood:         snapshot.Mood,
+		Timeline:     snapshot.Timeline,
+		World:        snapshot.World,
+		Whiteboard:   snapshot.Whiteboard,
+		WrapUp:       snapshot.WrapUp,
+		ReopenedAt:   snapshot.ReopenedAt,
+		Clustering:   snapshot.Clustering,
+		Private:      snapshot.Private,
+		CreatedAt:    snapshot.CreatedAt,
+		UpdatedAt:    snapshot.UpdatedAt,
+		messageIDs:   make(map[string]bool, len(snapshot.Messages)),
+	}
+	if conv.Participants == nil {
+		conv.Participants = make(map[string]*Participant)
+	}
+	if conv.Messages == nil {
+		conv.Messages = make([]*types.ChatMessage, 0)
+	}
+	if conv.Timeline == nil {
+		conv.Timeline = make([]TopicMoodEntry, 0)
+	}
+	for _, message := range conv.Messages {
+		conv.messageIDs[message.ID] = true
+	}
+
+	m.conversations[snapshot.ID] = conv
+	return nil
+}
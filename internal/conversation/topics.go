@@ -0,0 +1,71 @@
+package conversation
+
+import (
+	"strings"
+)
+
+// topicKeywords maps discussion topics to the keywords that signal them
+var topicKeywords = map[string][]string{
+	"ethics":       {"moral", "ethic", "virtue", "duty", "good", "evil", "right", "wrong", "justice"},
+	"metaphysics":  {"reality", "being", "existence", "substance", "universe", "infinite", "cosmos"},
+	"epistemology": {"knowledge", "know", "truth", "belief", "reason", "evidence", "certain"},
+	"religion":     {"god", "faith", "divine", "soul", "sin", "grace", "heaven", "church"},
+	"politics":     {"power", "state", "government", "society", "law", "freedom", "rights"},
+	"mind":         {"consciousness", "mind", "self", "thought", "perception", "instinct"},
+	"aesthetics":   {"beauty", "art", "music", "poetry", "taste", "sublime"},
+	"science":      {"science", "physics", "experiment", "theory", "nature", "evolution"},
+}
+
+// moodKeywords maps conversation moods to the words that signal them
+var moodKeywords = map[string][]string{
+	"positive": {"agree", "great", "love", "wonderful", "beautiful", "thanks", "indeed", "yes"},
+	"negative": {"disagree", "wrong", "absurd", "nonsense", "hate", "terrible", "no"},
+	"playful":  {"haha", "lol", "joke", "funny", "jest", "😂", "😄"},
+}
+
+// DetectTopic returns the topic whose keywords occur most often in content,
+// or an empty string if none match
+func DetectTopic(content string) string {
+	return bestMatch(content, topicKeywords)
+}
+
+// DetectMood estimates the mood of a message from its wording and punctuation
+func DetectMood(content string) string {
+	if mood := bestMatch(content, moodKeywords); mood != "" {
+		return mood
+	}
+	switch {
+	case strings.Count(content, "!") >= 2:
+		return "heated"
+	case strings.HasSuffix(strings.TrimSpace(content), "?"):
+		return "curious"
+	default:
+		return "neutral"
+	}
+}
+
+// bestMatch returns the key with the most keyword hits in content
+func bestMatch(content string, keywords map[string][]string) string {
+	words := strings.FieldsFunc(strings.ToLower(content), func(r rune) bool {
+		return strings.ContainsRune(" \t\n.,;:!?\"'()", r)
+	})
+
+	best, bestHits := "", 0
+	for key, list := range keywords {
+		hits := 0
+		for _, word := range words {
+			for _, keyword := range list {
+				// Short keywords must match exactly ("sin" should not match "since")
+				if word == keyword || (len(keyword) > 3 && strings.HasPrefix(word, keyword)) {
+					hits++
+					break
+				}
+			}
+		}
+		// Break ties alphabetically so detection is deterministic
+		if hits > bestHits || (hits == bestHits && hits > 0 && key < best) {
+			best, bestHits = key, hits
+		}
+	}
+	return best
+}
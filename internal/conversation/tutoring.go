@@ -0,0 +1,306 @@
+package conversation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"philoking/internal/config"
+	"philoking/internal/types"
+)
+
+// TutoringTag marks the moderator's tutoring announcements and lesson prompts
+const TutoringTag = "tutoring"
+
+// ObjectiveMetTag marks the tutor's message confirming the user met the lesson's objective
+const ObjectiveMetTag = "objective-met"
+
+// ErrTutoringActive is returned when starting tutoring while a session runs
+var ErrTutoringActive = errors.New("a tutoring session is already running in this conversation")
+
+// Lesson is a step of a tutoring syllabus
+type Lesson struct {
+	Topic       string     `json:"topic"`
+	Objective   string     `json:"objective,omitempty"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	Skipped     bool       `json:"skipped,omitempty"` // Moved past with /tutor next
+}
+
+// Tutoring is a Socratic tutoring session: the tutor guides the user through
+// the syllabus with questions only, and the other agents stay quiet unless
+// the user summons them by name
+type Tutoring struct {
+	Tutor     string    `json:"tutor"`
+	Syllabus  []Lesson  `json:"syllabus"`
+	Current   int       `json:"current"` // Index of the current lesson; len(Syllabus) once complete
+	Stopped   bool      `json:"stopped,omitempty"`
+	StartedAt time.Time `json:"started_at"`
+}
+
+// Active reports whether the session still runs
+func (t *Tutoring) Active() bool {
+	return !t.Stopped && t.Current < len(t.Syllabus)
+}
+
+// Lesson returns the current lesson, or nil once the session is over
+func (t *Tutoring) Lesson() *Lesson {
+	if !t.Active() {
+		return nil
+	}
+	return &t.Syllabus[t.Current]
+}
+
+// Completed returns how many lessons the user completed
+func (t *Tutoring) Completed() int {
+	completed := 0
+	for _, lesson := range t.Syllabus {
+		if lesson.CompletedAt != nil && !lesson.Skipped {
+			completed++
+		}
+	}
+	return completed
+}
+
+// ShouldRespond reports whether an agent answers a message during tutoring:
+// the tutor answers the user and its lesson prompts, the others only a user
+// who replies to them or mentions them by name
+func (t *Tutoring) ShouldRespond(agentID, agentName string, message *types.ChatMessage) bool {
+	if agentID == t.Tutor {
+		return message.Type == types.MessageTypeUser ||
+			(message.Type == types.MessageTypeSystem && message.Metadata.ReplyTo == agentID && isTagged(message, TutoringTag))
+	}
+	return message.Type == types.MessageTypeUser && summons(message, agentID, agentName)
+}
+
+// summons reports whether a message is addressed to an agent
+func summons(message *types.ChatMessage, agentID, agentName string) bool {
+	content := strings.ToLower(message.Content)
+	return message.Metadata.ReplyTo == agentID ||
+		strings.Contains(content, "@"+strings.ToLower(agentID)) ||
+		(agentName != "" && strings.Contains(content, strings.ToLower(agentName)))
+}
+
+// copy returns a deep copy of the session
+func (t *Tutoring) copy() *Tutoring {
+	cp := *t
+	cp.Syllabus = append([]Lesson(nil), t.Syllabus...)
+	return &cp
+}
+
+// GetTutoring returns a copy of the tutoring session of a conversation, or nil
+func (m *Manager) GetTutoring(conversationID string) *Tutoring {
+	conv := m.GetOrCreateConversation(conversationID)
+
+	conv.mu.RLock()
+	defer conv.mu.RUnlock()
+
+	if conv.Tutoring == nil {
+		return nil
+	}
+	return conv.Tutoring.copy()
+}
+
+// GetActiveTutoring returns a copy of the tutoring session of a conversation while it runs, or nil
+func (m *Manager) GetActiveTutoring(conversationID string) *Tutoring {
+	if tutoring := m.GetTutoring(conversationID); tutoring != nil && tutoring.Active() {
+		return tutoring
+	}
+	return nil
+}
+
+// startTutoring puts a conversation into tutoring, unless a session runs
+func (m *Manager) startTutoring(conversationID string, tutoring *Tutoring) error {
+	conv := m.GetOrCreateConversation(conversationID)
+
+	conv.mu.Lock()
+	defer conv.mu.Unlock()
+
+	if conv.Tutoring != nil && conv.Tutoring.Active() {
+		return ErrTutoringActive
+	}
+	conv.Tutoring = tutoring
+	return nil
+}
+
+// completeLesson moves past lesson `expected` and returns the updated copy,
+// or nil when that lesson is no longer current
+func (m *Manager) completeLesson(conversationID string, expected int, skipped bool) *Tutoring {
+	conv := m.GetOrCreateConversation(conversationID)
+
+	conv.mu.Lock()
+	defer conv.mu.Unlock()
+
+	tutoring := conv.Tutoring
+	if tutoring == nil || !tutoring.Active() || tutoring.Current != expected {
+		return nil
+	}
+
+	now := time.Now()
+	tutoring.Syllabus[tutoring.Current].CompletedAt = &now
+	tutoring.Syllabus[tutoring.Current].Skipped = skipped
+	tutoring.Current++
+	return tutoring.copy()
+}
+
+// stopTutoring ends a running session early
+func (m *Manager) stopTutoring(conversationID string) bool {
+	conv := m.GetOrCreateConversation(conversationID)
+
+	conv.mu.Lock()
+	defer conv.mu.Unlock()
+
+	if conv.Tutoring == nil || !conv.Tutoring.Active() {
+		return false
+	}
+	conv.Tutoring.Stopped = true
+	return true
+}
+
+// StartTutoring starts a Socratic tutoring session. The tutor and syllabus
+// default to the configuration when empty.
+func (f *FlowManager) StartTutoring(ctx context.Context, conversationID, tutor string, syllabus []config.LessonConfig) (*Tutoring, error) {
+	if tutor == "" {
+		tutor = f.config.Tutoring.Tutor
+	}
+	if len(syllabus) == 0 {
+		syllabus = f.config.Tutoring.Syllabus
+	}
+	if tutor == "" {
+		return nil, fmt.Errorf("tutoring needs a tutor")
+	}
+	if participant, exists := f.participant(tutor); !exists || participant.Type != "agent" {
+		return nil, fmt.Errorf("unknown agent %q", tutor)
+	}
+	if len(syllabus) == 0 {
+		return nil, fmt.Errorf("tutoring needs a syllabus")
+	}
+
+	tutoring := &Tutoring{Tutor: tutor, StartedAt: time.Now()}
+	for i, lesson := range syllabus {
+		if lesson.Topic == "" {
+			return nil, fmt.Errorf("lesson %d is missing a topic", i+1)
+		}
+		tutoring.Syllabus = append(tutoring.Syllabus, Lesson{Topic: lesson.Topic, Objective: lesson.Objective})
+	}
+	if err := f.conversationManager.startTutoring(conversationID, tutoring); err != nil {
+		return nil, err
+	}
+
+	announcement := fmt.Sprintf("🎓 %s will tutor you through %d lesson(s) with questions. The other agents stay quiet unless you mention them by name.",
+		f.participantName(tutor), len(tutoring.Syllabus))
+	message := f.newSystemMessage(announcement, conversationID)
+	message.Metadata.Tags = []string{TutoringTag}
+	if err := f.publisher.PublishMessage(ctx, message); err != nil {
+		log.Printf("Failed to announce tutoring: %v", err)
+	}
+
+	log.Printf("Started tutoring in conversation %s with %s", conversationID, tutor)
+	f.promptLesson(ctx, conversationID, tutoring.copy())
+	return tutoring.copy(), nil
+}
+
+// StopTutoring ends a running tutoring session
+func (f *FlowManager) StopTutoring(ctx context.Context, conversationID string) error {
+	if !f.conversationManager.stopTutoring(conversationID) {
+		return fmt.Errorf("no tutoring session is running")
+	}
+
+	message := f.newSystemMessage("🛑 Tutoring was stopped. The floor is open to everyone again.", conversationID)
+	message.Metadata.Tags = []string{TutoringTag}
+	if err := f.publisher.PublishMessage(ctx, message); err != nil {
+		log.Printf("Failed to announce the end of tutoring: %v", err)
+	}
+	log.Printf("Stopped tutoring in conversation %s", conversationID)
+	return nil
+}
+
+// SkipLesson moves on to the next lesson without the objective being met
+func (f *FlowManager) SkipLesson(ctx context.Context, conversationID string) error {
+	tutoring := f.conversationManager.GetActiveTutoring(conversationID)
+	if tutoring == nil {
+		return fmt.Errorf("no tutoring session is running")
+	}
+	if next := f.conversationManager.completeLesson(conversationID, tutoring.Current, true); next != nil {
+		f.promptLesson(ctx, conversationID, next)
+	}
+	return nil
+}
+
+// promptLesson asks the tutor to open the current lesson, or announces the
+// end of the syllabus
+func (f *FlowManager) promptLesson(ctx context.Context, conversationID string, tutoring *Tutoring) {
+	lesson := tutoring.Lesson()
+	if lesson == nil {
+		message := f.newSystemMessage(fmt.Sprintf("🎓 Tutoring complete: you worked through %d of %d lesson(s). The floor is open to everyone again.",
+			tutoring.Completed(), len(tutoring.Syllabus)), conversationID)
+		message.Metadata.Tags = []string{TutoringTag}
+		if err := f.publisher.PublishMessage(ctx, message); err != nil {
+			log.Printf("Failed to announce the end of tutoring: %v", err)
+		}
+		log.Printf("Tutoring in conversation %s complete", conversationID)
+		return
+	}
+
+	content := fmt.Sprintf("📘 Lesson %d of %d: %s.", tutoring.Current+1, len(tutoring.Syllabus), lesson.Topic)
+	if lesson.Objective != "" {
+		content += fmt.Sprintf(" Objective: %s.", strings.TrimSuffix(lesson.Objective, "."))
+	}
+	content += fmt.Sprintf(" %s, open the lesson with a question.", f.participantName(tutoring.Tutor))
+
+	prompt := f.newSystemMessage(content, conversationID)
+	prompt.Metadata.ReplyTo = tutoring.Tutor
+	prompt.Metadata.Tags = []string{TutoringTag}
+	prompt.Metadata.Custom = map[string]string{"lesson": strconv.Itoa(tutoring.Current + 1)}
+	if err := f.publisher.PublishMessage(ctx, prompt); err != nil {
+		log.Printf("Failed to prompt lesson: %v", err)
+	}
+}
+
+// handleTutoringMessage moves on to the next lesson when the tutor confirms
+// the user met the objective
+func (f *FlowManager) handleTutoringMessage(ctx context.Context, message *types.ChatMessage, conversationID string) {
+	if !isTagged(message, ObjectiveMetTag) {
+		return
+	}
+	tutoring := f.conversationManager.GetActiveTutoring(conversationID)
+	if tutoring == nil || message.AgentID != tutoring.Tutor {
+		return
+	}
+
+	if next := f.conversationManager.completeLesson(conversationID, tutoring.Current, false); next != nil {
+		f.promptLesson(ctx, conversationID, next)
+	}
+}
+
+// handleTutorCommand handles "/tutor", "/tutor <agent>", "/tutor next" and "/tutor stop"
+func (f *FlowManager) handleTutorCommand(ctx context.Context, message *types.ChatMessage, conversationID string) {
+	command := message.Metadata.Command
+
+	switch {
+	case len(command.Args) == 0:
+		status := "No tutoring session is running. Use /tutor <agent> to start one."
+		if tutoring := f.conversationManager.GetActiveTutoring(conversationID); tutoring != nil {
+			status = fmt.Sprintf("🎓 %s is tutoring: lesson %d of %d, %s (%d completed).",
+				f.participantName(tutoring.Tutor), tutoring.Current+1, len(tutoring.Syllabus), tutoring.Lesson().Topic, tutoring.Completed())
+		}
+		if err := f.publisher.PublishMessage(ctx, f.newNotice(status, conversationID)); err != nil {
+			log.Printf("Failed to publish tutoring status: %v", err)
+		}
+	case strings.EqualFold(command.Args[0], "stop"):
+		if err := f.StopTutoring(ctx, conversationID); err != nil {
+			f.replyCommandError(ctx, conversationID, command, err)
+		}
+	case strings.EqualFold(command.Args[0], "next"):
+		if err := f.SkipLesson(ctx, conversationID); err != nil {
+			f.replyCommandError(ctx, conversationID, command, err)
+		}
+	default:
+		if _, err := f.StartTutoring(ctx, conversationID, command.Args[0], nil); err != nil {
+			f.replyCommandError(ctx, conversationID, command, err)
+		}
+	}
+}
@@ -0,0 +1,238 @@
+package conversation
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"philoking/internal/types"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// maxWhiteboardSize bounds the text of a whiteboard, in bytes
+	maxWhiteboardSize = 64 * 1024
+	// maxWhiteboardRevisions is how many past edits a whiteboard keeps
+	maxWhiteboardRevisions = 50
+)
+
+// ErrWhiteboardVersion is returned when the whiteboard changed since the version an edit was based on
+var ErrWhiteboardVersion = errors.New("the whiteboard has changed since")
+
+// Whiteboard is a document the participants of a conversation write
+// together, e.g. a summary, essay or spec. Every edit makes a new version.
+type Whiteboard struct {
+	Title     string               `json:"title,omitempty"`
+	Text      string               `json:"text"`
+	Version   int                  `json:"version"`
+	UpdatedAt time.Time            `json:"updated_at"`
+	UpdatedBy string               `json:"updated_by,omitempty"`
+	Revisions []WhiteboardRevision `json:"revisions,omitempty"` // The latest edits, oldest first
+}
+
+// WhiteboardRevision records one edit
+type WhiteboardRevision struct {
+	Version int       `json:"version"`
+	Author  string    `json:"author"`
+	Summary string    `json:"summary,omitempty"`
+	Diff    string    `json:"diff"`
+	At      time.Time `json:"at"`
+}
+
+// WhiteboardEdit is a proposed change. Op "set" replaces the text, "append"
+// adds Text as a new paragraph and "replace" swaps the single occurrence of
+// Find for Replace. Appends and replacements still apply when others edited
+// the whiteboard in the meantime; set needs BaseVersion.
+type WhiteboardEdit struct {
+	Op          string `json:"op"`
+	Text        string `json:"text,omitempty"`
+	Find        string `json:"find,omitempty"`
+	Replace     string `json:"replace,omitempty"`
+	Title       string `json:"title,omitempty"`        // Renames the whiteboard when set
+	Summary     string `json:"summary,omitempty"`      // Why the edit was made
+	BaseVersion *int   `json:"base_version,omitempty"` // Rejects the edit if the whiteboard changed since
+}
+
+// apply returns the text after the edit
+func (e WhiteboardEdit) apply(text string) (string, error) {
+	switch e.Op {
+	case "set":
+		return e.Text, nil
+	case "append":
+		if e.Text == "" {
+			return "", fmt.Errorf("append needs text")
+		}
+		if text == "" {
+			return e.Text, nil
+		}
+		return strings.TrimRight(text, "\n") + "\n\n" + e.Text, nil
+	case "replace":
+		if e.Find == "" {
+			return "", fmt.Errorf("replace needs the text to find")
+		}
+		switch strings.Count(text, e.Find) {
+		case 0:
+			return "", fmt.Errorf("%q is not on the whiteboard", e.Find)
+		case 1:
+			return strings.Replace(text, e.Find, e.Replace, 1), nil
+		default:
+			return "", fmt.Errorf("%q occurs more than once; include more of the surrounding text", e.Find)
+		}
+	default:
+		return "", fmt.Errorf("unknown operation %q", e.Op)
+	}
+}
+
+// GetWhiteboard returns the whiteboard of a conversation, or nil if nobody wrote on it yet
+func (m *Manager) GetWhiteboard(conversationID string) *Whiteboard {
+	conv := m.GetOrCreateConversation(conversationID)
+
+	conv.mu.RLock()
+	defer conv.mu.RUnlock()
+
+	return conv.Whiteboard
+}
+
+// EditWhiteboard applies an edit to the whiteboard of a conversation and
+// returns the new whiteboard with the revision it made
+func (m *Manager) EditWhiteboard(conversationID string, edit WhiteboardEdit, author string) (*Whiteboard, *WhiteboardRevision, error) {
+	conv := m.GetOrCreateConversation(conversationID)
+
+	conv.mu.Lock()
+	defer conv.mu.Unlock()
+
+	current := conv.Whiteboard
+	if current == nil {
+		current = &Whiteboard{}
+	}
+	if edit.BaseVersion != nil && *edit.BaseVersion != current.Version {
+		return nil, nil, fmt.Errorf("%w version %d (now %d)", ErrWhiteboardVersion, *edit.BaseVersion, current.Version)
+	}
+	if edit.Op == "set" && edit.BaseVersion == nil && current.Version > 0 {
+		return nil, nil, fmt.Errorf("set needs the base_version it replaces")
+	}
+
+	text, err := edit.apply(current.Text)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(text) > maxWhiteboardSize {
+		return nil, nil, fmt.Errorf("the whiteboard can hold at most %d bytes", maxWhiteboardSize)
+	}
+
+	now := time.Now()
+	revision := WhiteboardRevision{
+		Version: current.Version + 1,
+		Author:  author,
+		Summary: edit.Summary,
+		Diff:    lineDiff(current.Text, text),
+		At:      now,
+	}
+	// Replaced, never modified in place, so copies stay valid
+	next := &Whiteboard{
+		Title:     current.Title,
+		Text:      text,
+		Version:   revision.Version,
+		UpdatedAt: now,
+		UpdatedBy: author,
+		Revisions: append(append([]WhiteboardRevision(nil), current.Revisions...), revision),
+	}
+	if edit.Title != "" {
+		next.Title = edit.Title
+	}
+	if len(next.Revisions) > maxWhiteboardRevisions {
+		next.Revisions = next.Revisions[len(next.Revisions)-maxWhiteboardRevisions:]
+	}
+	conv.Whiteboard = next
+	return next, &revision, nil
+}
+
+// NewWhiteboardEvent builds the event that sends a whiteboard edit to clients
+func NewWhiteboardEvent(conversationID string, revision *WhiteboardRevision) *types.ChatMessage {
+	return &types.ChatMessage{
+		ID:        uuid.New().String(),
+		Type:      types.MessageTypeWhiteboard,
+		Content:   revision.Diff,
+		AgentID:   "system",
+		Timestamp: revision.At,
+		Metadata: types.Metadata{
+			ConversationID: conversationID,
+			Custom: map[string]string{
+				types.WhiteboardVersionKey: strconv.Itoa(revision.Version),
+				types.WhiteboardAuthorKey:  revision.Author,
+				types.WhiteboardSummaryKey: revision.Summary,
+			},
+		},
+	}
+}
+
+// maxDiffCells bounds the work of a line diff; larger edits show as a rewrite
+const maxDiffCells = 1 << 22
+
+// lineDiff compares two texts line by line. Each run of changes starts with
+// "@@ -<line> +<line> @@", the line numbers of the run in both texts from 1,
+// followed by the removed lines marked "-" and the added lines marked "+".
+func lineDiff(before, after string) string {
+	a, b := splitLines(before), splitLines(after)
+
+	var diff strings.Builder
+	if (len(a)+1)*(len(b)+1) > maxDiffCells {
+		diff.WriteString("@@ -1 +1 @@\n")
+		for _, line := range a {
+			fmt.Fprintf(&diff, "-%s\n", line)
+		}
+		for _, line := range b {
+			fmt.Fprintf(&diff, "+%s\n", line)
+		}
+		return diff.String()
+	}
+
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	inRun := false
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		if i < len(a) && j < len(b) && a[i] == b[j] {
+			i++
+			j++
+			inRun = false
+			continue
+		}
+		if !inRun {
+			fmt.Fprintf(&diff, "@@ -%d +%d @@\n", i+1, j+1)
+			inRun = true
+		}
+		if i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]) {
+			fmt.Fprintf(&diff, "-%s\n", a[i])
+			i++
+		} else {
+			fmt.Fprintf(&diff, "+%s\n", b[j])
+			j++
+		}
+	}
+	return diff.String()
+}
+
+// splitLines splits a text into lines; the empty text has none
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
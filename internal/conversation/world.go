@@ -0,0 +1,343 @@
+package conversation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrWorldVersion is returned when the world changed since the version an update expected
+var ErrWorldVersion = errors.New("the world has changed since")
+
+// World is the shared state of a story or roleplay: where things are, who is
+// there and who carries what. It is replaced on every change, never modified
+// in place, so copies stay valid.
+type World struct {
+	Locations  map[string]*Location  `json:"locations"`
+	Characters map[string]*Character `json:"characters"`
+	// Inventory maps an owner, a character or a location, to the items it holds
+	Inventory map[string][]string `json:"inventory"`
+	Version   int                 `json:"version"`
+	UpdatedAt time.Time           `json:"updated_at"`
+	UpdatedBy string              `json:"updated_by,omitempty"`
+}
+
+// Location is a place in the world
+type Location struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description,omitempty"`
+	Exits       []string `json:"exits,omitempty"` // IDs of the locations reachable from here
+}
+
+// Character is a person or creature in the world
+type Character struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Location    string `json:"location,omitempty"` // ID of the location the character is at
+	Status      string `json:"status,omitempty"`   // e.g. "wounded" or "asleep"
+}
+
+// WorldOp is one change to the world. Op is one of set_location,
+// set_character, move, add_item, move_item, remove_item, remove_character
+// and remove_location.
+type WorldOp struct {
+	Op          string   `json:"op"`
+	ID          string   `json:"id,omitempty"` // Location or character
+	Name        string   `json:"name,omitempty"`
+	Description string   `json:"description,omitempty"`
+	Exits       []string `json:"exits,omitempty"`
+	Location    string   `json:"location,omitempty"`
+	Status      string   `json:"status,omitempty"`
+	Character   string   `json:"character,omitempty"` // For move
+	Item        string   `json:"item,omitempty"`
+	From        string   `json:"from,omitempty"` // Owner an item is taken from
+	To          string   `json:"to,omitempty"`   // Location a character moves to, or owner an item goes to
+}
+
+// WorldUpdate is a set of changes applied together, or not at all
+type WorldUpdate struct {
+	Ops []WorldOp `json:"ops"`
+	// ExpectedVersion, when set, rejects the update if somebody else changed the world first
+	ExpectedVersion *int `json:"expected_version,omitempty"`
+}
+
+// newWorld returns an empty world
+func newWorld() *World {
+	return &World{
+		Locations:  make(map[string]*Location),
+		Characters: make(map[string]*Character),
+		Inventory:  make(map[string][]string),
+	}
+}
+
+// clone returns a deep copy of the world
+func (w *World) clone() *World {
+	cp := newWorld()
+	for id, location := range w.Locations {
+		l := *location
+		l.Exits = append([]string(nil), location.Exits...)
+		cp.Locations[id] = &l
+	}
+	for id, character := range w.Characters {
+		c := *character
+		cp.Characters[id] = &c
+	}
+	for owner, items := range w.Inventory {
+		cp.Inventory[owner] = append([]string(nil), items...)
+	}
+	cp.Version, cp.UpdatedAt, cp.UpdatedBy = w.Version, w.UpdatedAt, w.UpdatedBy
+	return cp
+}
+
+// Validate checks that the world is consistent: characters stand in known
+// locations, exits lead to known locations and items belong to known owners
+func (w *World) Validate() error {
+	for id, location := range w.Locations {
+		for _, exit := range location.Exits {
+			if _, exists := w.Locations[exit]; !exists {
+				return fmt.Errorf("location %s has an exit to unknown location %s", id, exit)
+			}
+		}
+	}
+	for id, character := range w.Characters {
+		if _, exists := w.Locations[character.Location]; character.Location != "" && !exists {
+			return fmt.Errorf("character %s is at unknown location %s", id, character.Location)
+		}
+	}
+	for owner := range w.Inventory {
+		if !w.isOwner(owner) {
+			return fmt.Errorf("inventory of unknown owner %s", owner)
+		}
+	}
+	return nil
+}
+
+// isOwner reports whether items can belong to an ID
+func (w *World) isOwner(id string) bool {
+	_, isLocation := w.Locations[id]
+	_, isCharacter := w.Characters[id]
+	return isLocation || isCharacter
+}
+
+// apply makes one change to the world
+func (w *World) apply(op WorldOp) error {
+	switch op.Op {
+	case "set_location":
+		if op.ID == "" {
+			return fmt.Errorf("set_location needs an id")
+		}
+		location := w.Locations[op.ID]
+		if location == nil {
+			location = &Location{Name: op.ID}
+			w.Locations[op.ID] = location
+		}
+		if op.Name != "" {
+			location.Name = op.Name
+		}
+		if op.Description != "" {
+			location.Description = op.Description
+		}
+		if op.Exits != nil {
+			location.Exits = op.Exits
+		}
+	case "set_character":
+		if op.ID == "" {
+			return fmt.Errorf("set_character needs an id")
+		}
+		character := w.Characters[op.ID]
+		if character == nil {
+			character = &Character{Name: op.ID}
+			w.Characters[op.ID] = character
+		}
+		if op.Name != "" {
+			character.Name = op.Name
+		}
+		if op.Description != "" {
+			character.Description = op.Description
+		}
+		if op.Location != "" {
+			character.Location = op.Location
+		}
+		if op.Status != "" {
+			character.Status = op.Status
+		}
+	case "move":
+		character := w.Characters[op.Character]
+		if character == nil {
+			return fmt.Errorf("unknown character %q", op.Character)
+		}
+		if _, exists := w.Locations[op.To]; !exists {
+			return fmt.Errorf("unknown location %q", op.To)
+		}
+		character.Location = op.To
+	case "add_item":
+		if op.Item == "" {
+			return fmt.Errorf("add_item needs an item")
+		}
+		if !w.isOwner(op.To) {
+			return fmt.Errorf("unknown owner %q", op.To)
+		}
+		w.Inventory[op.To] = append(w.Inventory[op.To], op.Item)
+	case "move_item":
+		if !w.isOwner(op.To) {
+			return fmt.Errorf("unknown owner %q", op.To)
+		}
+		if err := w.takeItem(op.From, op.Item); err != nil {
+			return err
+		}
+		w.Inventory[op.To] = append(w.Inventory[op.To], op.Item)
+	case "remove_item":
+		return w.takeItem(op.From, op.Item)
+	case "remove_character":
+		if _, exists := w.Characters[op.ID]; !exists {
+			return fmt.Errorf("unknown character %q", op.ID)
+		}
+		if len(w.Inventory[op.ID]) > 0 {
+			return fmt.Errorf("character %s still carries %s", op.ID, strings.Join(w.Inventory[op.ID], ", "))
+		}
+		delete(w.Characters, op.ID)
+		delete(w.Inventory, op.ID)
+	case "remove_location":
+		if _, exists := w.Locations[op.ID]; !exists {
+			return fmt.Errorf("unknown location %q", op.ID)
+		}
+		for id, character := range w.Characters {
+			if character.Location == op.ID {
+				return fmt.Errorf("character %s is still at %s", id, op.ID)
+			}
+		}
+		if len(w.Inventory[op.ID]) > 0 {
+			return fmt.Errorf("location %s still holds %s", op.ID, strings.Join(w.Inventory[op.ID], ", "))
+		}
+		delete(w.Locations, op.ID)
+		delete(w.Inventory, op.ID)
+		for _, location := range w.Locations {
+			location.Exits = without(location.Exits, op.ID)
+		}
+	default:
+		return fmt.Errorf("unknown operation %q", op.Op)
+	}
+	return nil
+}
+
+// takeItem removes one item from an owner's inventory
+func (w *World) takeItem(owner, item string) error {
+	items := w.Inventory[owner]
+	for i, it := range items {
+		if it == item {
+			w.Inventory[owner] = append(items[:i:i], items[i+1:]...)
+			if len(w.Inventory[owner]) == 0 {
+				delete(w.Inventory, owner)
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("%q does not hold %q", owner, item)
+}
+
+// without returns ids without one of them
+func without(ids []string, id string) []string {
+	var kept []string
+	for _, other := range ids {
+		if other != id {
+			kept = append(kept, other)
+		}
+	}
+	return kept
+}
+
+// Describe renders the world as compact JSON, for agents
+func (w *World) Describe() string {
+	data, err := json.Marshal(w)
+	if err != nil {
+		return "{}"
+	}
+	return string(data)
+}
+
+// GetWorld returns the world of a conversation, or nil if it has none
+func (m *Manager) GetWorld(conversationID string) *World {
+	conv := m.GetOrCreateConversation(conversationID)
+
+	conv.mu.RLock()
+	defer conv.mu.RUnlock()
+
+	return conv.World
+}
+
+// UpdateWorld applies a set of changes to the world of a conversation, all
+// or none of them, and returns the new world. updatedBy names who made them.
+func (m *Manager) UpdateWorld(conversationID string, update WorldUpdate, updatedBy string) (*World, error) {
+	conv := m.GetOrCreateConversation(conversationID)
+
+	conv.mu.Lock()
+	defer conv.mu.Unlock()
+
+	world := newWorld()
+	if conv.World != nil {
+		world = conv.World.clone()
+	}
+	if update.ExpectedVersion != nil && *update.ExpectedVersion != world.Version {
+		return nil, fmt.Errorf("%w version %d (now %d)", ErrWorldVersion, *update.ExpectedVersion, world.Version)
+	}
+	for i, op := range update.Ops {
+		if err := world.apply(op); err != nil {
+			return nil, fmt.Errorf("operation %d: %w", i+1, err)
+		}
+	}
+	if err := world.Validate(); err != nil {
+		return nil, err
+	}
+
+	world.Version++
+	world.UpdatedAt = time.Now()
+	world.UpdatedBy = updatedBy
+	conv.World = world
+	return world, nil
+}
+
+// ReplaceWorld sets the whole world of a conversation, e.g. to start a story
+// from a prepared setting; nil clears it
+func (m *Manager) ReplaceWorld(conversationID string, world *World, updatedBy string) (*World, error) {
+	conv := m.GetOrCreateConversation(conversationID)
+
+	conv.mu.Lock()
+	defer conv.mu.Unlock()
+
+	if world == nil {
+		conv.World = nil
+		return nil, nil
+	}
+
+	replacement := newWorld()
+	for id, location := range world.Locations {
+		if location == nil {
+			return nil, fmt.Errorf("location %s is empty", id)
+		}
+		replacement.Locations[id] = location
+	}
+	for id, character := range world.Characters {
+		if character == nil {
+			return nil, fmt.Errorf("character %s is empty", id)
+		}
+		replacement.Characters[id] = character
+	}
+	for owner, items := range world.Inventory {
+		replacement.Inventory[owner] = items
+	}
+	replacement = replacement.clone()
+	if err := replacement.Validate(); err != nil {
+		return nil, err
+	}
+
+	if conv.World != nil {
+		replacement.Version = conv.World.Version
+	}
+	replacement.Version++
+	replacement.UpdatedAt = time.Now()
+	replacement.UpdatedBy = updatedBy
+	conv.World = replacement
+	return replacement, nil
+}
@@ -0,0 +1,302 @@
+package conversation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"philoking/internal/types"
+)
+
+// WrapUpTag marks the moderator's messages that wrap up a conversation
+const WrapUpTag = "wrap-up"
+
+// ErrConversationLocked is returned for messages to a conversation that was wrapped up
+var ErrConversationLocked = errors.New("conversation is locked")
+
+// WrapUp closes a conversation that went past the depth guard's caps: the
+// agents post their closing statements one by one, then the conversation is
+// locked
+type WrapUp struct {
+	Reason        string     `json:"reason"`
+	Speakers      []string   `json:"speakers"` // Agent IDs, in the order they close
+	Turn          int        `json:"turn"`     // Index of the current speaker; len(Speakers) once locked
+	StartedAt     time.Time  `json:"started_at"`
+	TurnStartedAt time.Time  `json:"turn_started_at"`
+	LockedAt      *time.Time `json:"locked_at,omitempty"`
+}
+
+// Locked reports whether all agents have closed and the conversation is locked
+func (w *WrapUp) Locked() bool {
+	return w.Turn >= len(w.Speakers)
+}
+
+// Speaker returns the agent asked for its closing statement, or "" once locked
+func (w *WrapUp) Speaker() string {
+	if w.Locked() {
+		return ""
+	}
+	return w.Speakers[w.Turn]
+}
+
+// ShouldRespond reports whether an agent must answer a message while the
+// conversation wraps up: only the speaker answers, and only the moderator's
+// prompt. Nobody answers once it is locked.
+func (w *WrapUp) ShouldRespond(agentID string, message *types.ChatMessage) bool {
+	return message.Type == types.MessageTypeSystem &&
+		message.Metadata.ReplyTo == agentID &&
+		w.Speaker() == agentID &&
+		isTagged(message, WrapUpTag)
+}
+
+// copy returns a deep copy of the wrap-up, or nil
+func (w *WrapUp) copy() *WrapUp {
+	if w == nil {
+		return nil
+	}
+	cp := *w
+	cp.Speakers = append([]string(nil), w.Speakers...)
+	return &cp
+}
+
+// GetWrapUp returns a copy of the wrap-up of a conversation, or nil when it is open
+func (m *Manager) GetWrapUp(conversationID string) *WrapUp {
+	conv, exists := m.lookup(conversationID)
+	if !exists {
+		return nil
+	}
+
+	conv.mu.RLock()
+	defer conv.mu.RUnlock()
+	return conv.WrapUp.copy()
+}
+
+// IsLocked reports whether a conversation was wrapped up and takes no more messages
+func (m *Manager) IsLocked(conversationID string) bool {
+	wrapUp := m.GetWrapUp(conversationID)
+	return wrapUp != nil && wrapUp.Locked()
+}
+
+// depth returns the number of discussion messages of a conversation and when
+// it started, both counted from its reopening, if it was reopened
+func (m *Manager) depth(conversationID string) (int, time.Time) {
+	conv := m.GetOrCreateConversation(conversationID)
+
+	conv.mu.RLock()
+	defer conv.mu.RUnlock()
+
+	messages := 0
+	for _, message := range conv.Messages {
+		if message.IsCommand() || (message.Type != types.MessageTypeAgent && message.Type != types.MessageTypeUser) {
+			continue
+		}
+		if message.Timestamp.Before(conv.ReopenedAt) {
+			continue
+		}
+		messages++
+	}
+	if conv.ReopenedAt.After(conv.CreatedAt) {
+		return messages, conv.ReopenedAt
+	}
+	return messages, conv.CreatedAt
+}
+
+// startWrapUp starts wrapping up a conversation, unless it already is
+func (m *Manager) startWrapUp(conversationID string, wrapUp *WrapUp) bool {
+	conv := m.GetOrCreateConversation(conversationID)
+
+	conv.mu.Lock()
+	defer conv.mu.Unlock()
+
+	if conv.WrapUp != nil {
+		return false
+	}
+	conv.WrapUp = wrapUp
+	return true
+}
+
+// advanceWrapUp ends turn `expected` and returns the updated copy, locking
+// the conversation after the last one. Timers and statements race to end a
+// turn and only one of them may.
+func (m *Manager) advanceWrapUp(conversationID string, expected int) *WrapUp {
+	conv := m.GetOrCreateConversation(conversationID)
+
+	conv.mu.Lock()
+	defer conv.mu.Unlock()
+
+	wrapUp := conv.WrapUp
+	if wrapUp == nil || wrapUp.Locked() || wrapUp.Turn != expected {
+		return nil
+	}
+	now := time.Now()
+	wrapUp.Turn++
+	wrapUp.TurnStartedAt = now
+	if wrapUp.Locked() {
+		wrapUp.LockedAt = &now
+	}
+	return wrapUp.copy()
+}
+
+// reopen unlocks a wrapped-up conversation and restarts the depth guard's count
+func (m *Manager) reopen(conversationID string) bool {
+	conv, exists := m.lookup(conversationID)
+	if !exists {
+		return false
+	}
+
+	conv.mu.Lock()
+	defer conv.mu.Unlock()
+
+	if conv.WrapUp == nil {
+		return false
+	}
+	conv.WrapUp = nil
+	conv.ReopenedAt = time.Now()
+	return true
+}
+
+// checkDepth wraps up a conversation once a discussion message takes it past
+// the configured number of messages or duration
+func (f *FlowManager) checkDepth(ctx context.Context, message *types.ChatMessage, conversationID string) {
+	guard := f.config.DepthGuard
+	if guard.MaxMessages <= 0 && guard.MaxDuration <= 0 {
+		return
+	}
+	if message.IsCommand() || (message.Type != types.MessageTypeAgent && message.Type != types.MessageTypeUser) {
+		return
+	}
+	// Side conversations end on their own
+	if _, ok := f.conversationManager.GetSideConversation(conversationID); ok {
+		return
+	}
+	if f.conversationManager.GetWrapUp(conversationID) != nil {
+		return
+	}
+
+	messages, started := f.conversationManager.depth(conversationID)
+	var reason string
+	switch {
+	case guard.MaxMessages > 0 && messages >= guard.MaxMessages:
+		reason = fmt.Sprintf("reached %d messages", messages)
+	case guard.MaxDuration > 0 && time.Since(started) >= guard.MaxDuration:
+		reason = fmt.Sprintf("ran for %s", guard.MaxDuration)
+	default:
+		return
+	}
+	f.wrapUp(ctx, conversationID, reason)
+}
+
+// wrapUp asks the conversation's agents for their closing statements, one by
+// one, and locks the conversation after the last
+func (f *FlowManager) wrapUp(ctx context.Context, conversationID, reason string) {
+	now := time.Now()
+	wrapUp := &WrapUp{
+		Reason:        reason,
+		Speakers:      f.conversationAgents(conversationID),
+		StartedAt:     now,
+		TurnStartedAt: now,
+	}
+	if len(wrapUp.Speakers) == 0 {
+		wrapUp.LockedAt = &now
+	}
+	cp := wrapUp.copy()
+	if !f.conversationManager.startWrapUp(conversationID, wrapUp) {
+		return
+	}
+
+	announcement := f.newSystemMessage(fmt.Sprintf("⏳ This conversation %s. Time to wrap up: each agent gets a closing statement, then the conversation is closed.", reason), conversationID)
+	announcement.Metadata.Tags = []string{WrapUpTag}
+	if err := f.publisher.PublishMessage(ctx, announcement); err != nil {
+		log.Printf("Failed to announce wrap-up: %v", err)
+	}
+
+	log.Printf("Wrapping up conversation %s: it %s", conversationID, reason)
+	f.promptClosingStatement(ctx, conversationID, cp)
+}
+
+// conversationAgents returns the agents taking part in a conversation: its
+// members, or else every registered agent, sorted by ID
+func (f *FlowManager) conversationAgents(conversationID string) []string {
+	if info, ok := f.conversationManager.GetInfo(conversationID); ok && len(info.Members) > 0 {
+		return info.Members
+	}
+
+	f.participantsMu.RLock()
+	defer f.participantsMu.RUnlock()
+
+	var agents []string
+	for id, participant := range f.participants {
+		if participant.Type == "agent" {
+			agents = append(agents, id)
+		}
+	}
+	sort.Strings(agents)
+	return agents
+}
+
+// promptClosingStatement asks the current speaker for its closing statement,
+// or locks the conversation once all agents are done
+func (f *FlowManager) promptClosingStatement(ctx context.Context, conversationID string, wrapUp *WrapUp) {
+	if wrapUp.Locked() {
+		message := f.newSystemMessage("🔒 Thanks, everyone. This conversation is now closed.", conversationID)
+		message.Metadata.Tags = []string{WrapUpTag}
+		if err := f.publisher.PublishMessage(ctx, message); err != nil {
+			log.Printf("Failed to announce locked conversation: %v", err)
+		}
+		log.Printf("Locked conversation %s", conversationID)
+		return
+	}
+
+	speaker := wrapUp.Speaker()
+	prompt := f.newSystemMessage(fmt.Sprintf("%s, please give your closing statement: your final thoughts on this conversation, in a few sentences.", f.participantName(speaker)), conversationID)
+	prompt.Metadata.ReplyTo = speaker
+	prompt.Metadata.Tags = []string{WrapUpTag}
+	if err := f.publisher.PublishMessage(ctx, prompt); err != nil {
+		log.Printf("Failed to prompt closing statement: %v", err)
+	}
+
+	if timeout := f.config.DepthGuard.ClosingTimeout; timeout > 0 {
+		index, started := wrapUp.Turn, wrapUp.TurnStartedAt
+		time.AfterFunc(timeout, func() {
+			// The statement may have been made, or the conversation reopened, in the meantime
+			current := f.conversationManager.GetWrapUp(conversationID)
+			if current == nil || current.Turn != index || !current.TurnStartedAt.Equal(started) {
+				return
+			}
+			if next := f.conversationManager.advanceWrapUp(conversationID, index); next != nil {
+				log.Printf("Agent %s made no closing statement in conversation %s", speaker, conversationID)
+				f.promptClosingStatement(f.ctx, conversationID, next)
+			}
+		})
+	}
+}
+
+// handleWrapUpMessage takes the speaker's closing statement and asks the next agent for theirs
+func (f *FlowManager) handleWrapUpMessage(ctx context.Context, message *types.ChatMessage, conversationID string) {
+	wrapUp := f.conversationManager.GetWrapUp(conversationID)
+	if wrapUp == nil || wrapUp.Locked() || message.AgentID != wrapUp.Speaker() {
+		return
+	}
+	if next := f.conversationManager.advanceWrapUp(conversationID, wrapUp.Turn); next != nil {
+		f.promptClosingStatement(ctx, conversationID, next)
+	}
+}
+
+// ReopenConversation unlocks a conversation that was wrapped up; the depth
+// guard counts again from now
+func (f *FlowManager) ReopenConversation(ctx context.Context, conversationID string) error {
+	if !f.conversationManager.reopen(conversationID) {
+		return fmt.Errorf("conversation is not wrapped up")
+	}
+
+	message := f.newSystemMessage("🔓 This conversation was reopened.", conversationID)
+	message.Metadata.Tags = []string{WrapUpTag}
+	if err := f.publisher.PublishMessage(ctx, message); err != nil {
+		log.Printf("Failed to announce reopened conversation: %v", err)
+	}
+	log.Printf("Reopened conversation %s", conversationID)
+	return nil
+}
@@ -0,0 +1,180 @@
+// Package daily starts a fresh conversation every day at a configured time,
+// archives the previous day's and tells webhooks about the new one.
+package daily
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"philoking/internal/config"
+	"philoking/internal/conversation"
+)
+
+// dateLayout is the date in the IDs and titles of daily conversations
+const dateLayout = "2006-01-02"
+
+// Creator starts conversations, like the conversation flow
+type Creator interface {
+	CreateConversation(ctx context.Context, conversationID, title, topic, goal string, members []string, tags ...string) (*conversation.Info, error)
+}
+
+// Archiver moves a conversation to cold storage
+type Archiver interface {
+	Archive(ctx context.Context, conversationID string) error
+}
+
+// Notification is what webhooks are sent when a daily conversation starts.
+// Text makes it readable as is by chat apps such as Slack.
+type Notification struct {
+	Event        string             `json:"event"`
+	Conversation *conversation.Info `json:"conversation"`
+	Archived     string             `json:"archived,omitempty"` // ID of the previous day's conversation
+	Text         string             `json:"text"`
+}
+
+// Scheduler starts the daily conversations
+type Scheduler struct {
+	config   config.DailyConfig
+	creator  Creator
+	manager  *conversation.Manager
+	archiver Archiver // Nil keeps the previous conversations in memory
+	client   *http.Client
+}
+
+// New creates a scheduler; archiver may be nil
+func New(cfg config.DailyConfig, creator Creator, manager *conversation.Manager, archiver Archiver) *Scheduler {
+	return &Scheduler{
+		config:   cfg,
+		creator:  creator,
+		manager:  manager,
+		archiver: archiver,
+		client:   &http.Client{Timeout: cfg.WebhookTimeout},
+	}
+}
+
+// ConversationID returns the ID of the daily conversation of a day
+func (s *Scheduler) ConversationID(day time.Time) string {
+	return s.config.IDPrefix + "-" + day.Format(dateLayout)
+}
+
+// Run starts the day's conversation at the configured time every day until ctx is done
+func (s *Scheduler) Run(ctx context.Context) {
+	for {
+		timer := time.NewTimer(time.Until(nextRun(s.config.StartAt, time.Now())))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+			if _, err := s.Start(ctx, time.Now()); err != nil {
+				log.Printf("Daily conversation failed: %v", err)
+			}
+		}
+	}
+}
+
+// nextRun returns the next time the clock shows startAt ("15:04"), in local time
+func nextRun(startAt string, now time.Time) time.Time {
+	clock, err := time.Parse("15:04", startAt)
+	if err != nil {
+		clock = time.Date(0, 1, 1, 9, 0, 0, 0, time.UTC) // Validated at startup
+	}
+	next := time.Date(now.Year(), now.Month(), now.Day(), clock.Hour(), clock.Minute(), 0, 0, now.Location())
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
+
+// Start creates the conversation of the given day, archives the one of the
+// day before if configured, and notifies the webhooks. A failing archive or
+// webhook is logged; the new conversation stays.
+func (s *Scheduler) Start(ctx context.Context, day time.Time) (*conversation.Info, error) {
+	title := strings.TrimSpace(s.config.Title + " " + day.Format(dateLayout))
+	info, err := s.creator.CreateConversation(ctx, s.ConversationID(day), title, s.config.Topic, s.config.Goal, s.config.Members, conversation.ScheduledTag)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create daily conversation: %w", err)
+	}
+
+	archived := ""
+	previous := s.ConversationID(day.AddDate(0, 0, -1))
+	if s.config.ArchivePrevious && s.archiver != nil && s.manager.HasConversation(previous) {
+		if err := s.archiver.Archive(ctx, previous); err != nil {
+			log.Printf("Failed to archive daily conversation %s: %v", previous, err)
+		} else {
+			archived = previous
+		}
+	}
+
+	s.notify(ctx, Notification{
+		Event:        "daily_conversation",
+		Conversation: info,
+		Archived:     archived,
+		Text:         announcement(info),
+	})
+	return info, nil
+}
+
+// announcement describes a new daily conversation in a sentence
+func announcement(info *conversation.Info) string {
+	text := fmt.Sprintf("%s has started", info.Title)
+	if info.Topic != "" {
+		text += ": " + info.Topic
+	}
+	return text + "."
+}
+
+// notify posts a notification to every webhook
+func (s *Scheduler) notify(ctx context.Context, notification Notification) {
+	if len(s.config.Webhooks) == 0 {
+		return
+	}
+	body, err := json.Marshal(notification)
+	if err != nil {
+		log.Printf("Failed to encode daily conversation notification: %v", err)
+		return
+	}
+
+	for _, webhook := range s.config.Webhooks {
+		if err := s.post(ctx, webhook, body); err != nil {
+			// The URL may hold a token, so only its host is logged
+			host := ""
+			if u, parseErr := url.Parse(webhook); parseErr == nil {
+				host = u.Host
+			}
+			log.Printf("Failed to notify webhook at %s of daily conversation %s: %v", host, notification.Conversation.ID, err)
+		}
+	}
+}
+
+// post sends a notification to one webhook
+func (s *Scheduler) post(ctx context.Context, webhook string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			return urlErr.Err // Without the URL
+		}
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded with %s", resp.Status)
+	}
+	return nil
+}
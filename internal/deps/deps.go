@@ -0,0 +1,112 @@
+package deps
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"philoking/internal/config"
+	"philoking/internal/llmhttp"
+)
+
+// probeTimeout bounds a single probe, so an unresponsive host counts as a failed attempt
+const probeTimeout = 10 * time.Second
+
+// Probe checks that a dependency is reachable
+type Probe struct {
+	Name  string
+	Check func(ctx context.Context) error
+}
+
+// Wait probes each dependency in turn, retrying with exponential backoff. It
+// gives up on a dependency after cfg.Attempts probes, or keeps trying until
+// ctx is done when cfg.WaitForDeps is set, and returns the dependencies that
+// could not be reached.
+func Wait(ctx context.Context, probes []Probe, cfg config.StartupConfig) error {
+	var errs []error
+	for _, probe := range probes {
+		if err := wait(ctx, probe, cfg); err != nil {
+			if ctx.Err() != nil {
+				return err
+			}
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// wait probes a single dependency until it answers or the attempts run out
+func wait(ctx context.Context, probe Probe, cfg config.StartupConfig) error {
+	start := time.Now()
+	backoff := cfg.InitialBackoff
+
+	for attempt := 1; ; attempt++ {
+		probeCtx, cancel := context.WithTimeout(ctx, probeTimeout)
+		err := probe.Check(probeCtx)
+		cancel()
+		if err == nil {
+			log.Printf("✅ %s is reachable", probe.Name)
+			return nil
+		}
+
+		if !cfg.WaitForDeps && attempt >= cfg.Attempts {
+			log.Printf("❌ %s is unreachable after %d attempts: %v", probe.Name, attempt, err)
+			return fmt.Errorf("%s: %w", probe.Name, err)
+		}
+		if cfg.WaitForDeps {
+			log.Printf("⏳ Waiting for %s (attempt %d, %s so far): %v; retrying in %s", probe.Name, attempt, time.Since(start).Round(time.Second), err, backoff)
+		} else {
+			log.Printf("⏳ Waiting for %s (attempt %d of %d): %v; retrying in %s", probe.Name, attempt, cfg.Attempts, err, backoff)
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%s: %w", probe.Name, ctx.Err())
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, cfg.MaxBackoff)
+	}
+}
+
+// OpenAI returns a probe of the OpenAI API: it lists the models next to the
+// configured chat completions endpoint, which also checks the API key
+func OpenAI(client *http.Client, cfg config.AgentsConfig) Probe {
+	return Probe{
+		Name: "OpenAI",
+		Check: func(ctx context.Context) error {
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, modelsURL(cfg.LLMURL), nil)
+			if err != nil {
+				return err
+			}
+			req.Header.Set("Authorization", "Bearer "+cfg.LLMAPIKey)
+			llmhttp.SetHeaders(req, cfg.HTTP, "openai")
+
+			resp, err := client.Do(req)
+			if err != nil {
+				return err
+			}
+			resp.Body.Close()
+
+			switch {
+			case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+				return fmt.Errorf("API key rejected (%d)", resp.StatusCode)
+			case resp.StatusCode >= 500:
+				return fmt.Errorf("server error (%d)", resp.StatusCode)
+			}
+			return nil
+		},
+	}
+}
+
+// modelsURL derives the model listing endpoint from the chat completions
+// endpoint; other endpoints, e.g. of gateways, are probed as they are
+func modelsURL(llmURL string) string {
+	if base, ok := strings.CutSuffix(llmURL, "/chat/completions"); ok {
+		return base + "/models"
+	}
+	return llmURL
+}
@@ -0,0 +1,321 @@
+// Package digest mails subscribed users a daily summary of each conversation
+// that was active since their previous digest.
+package digest
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/mail"
+	"strings"
+	"sync"
+	"time"
+
+	"philoking/internal/config"
+	"philoking/internal/conversation"
+	"philoking/internal/storage"
+)
+
+const (
+	// subscriptionBucket stores subscriptions when storage is configured
+	subscriptionBucket = "digest-subscriptions"
+	// window is how far back the first digest of a subscriber looks, and the most any digest covers
+	window = 24 * time.Hour
+)
+
+// Subscription is a user's request to receive the digest
+type Subscription struct {
+	UserID string `json:"user_id"`
+	Email  string `json:"email"`
+	// Conversations limits the digest to these conversations; empty means all
+	Conversations []string  `json:"conversations,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+	LastSentAt    time.Time `json:"last_sent_at,omitempty"`
+}
+
+// covers reports whether a subscriber wants the digest of a conversation
+func (s *Subscription) covers(conversationID string) bool {
+	if len(s.Conversations) == 0 {
+		return true
+	}
+	for _, id := range s.Conversations {
+		if id == conversationID {
+			return true
+		}
+	}
+	return false
+}
+
+// Summarizer writes a summary of a conversation, like the summarizer agent
+type Summarizer interface {
+	WriteSummary(ctx context.Context, conversationID string) (*conversation.Summary, error)
+}
+
+// Digester keeps the subscriptions and sends the digests
+type Digester struct {
+	config     config.DigestConfig
+	manager    *conversation.Manager
+	mailer     Mailer
+	store      *storage.Store // Nil keeps subscriptions in memory
+	summarizer Summarizer     // Nil uses the summaries conversations already have
+
+	mu            sync.Mutex
+	subscriptions map[string]*Subscription // By user ID
+}
+
+// New creates a digester and loads the stored subscriptions; store may be nil
+func New(cfg config.DigestConfig, manager *conversation.Manager, mailer Mailer, store *storage.Store) (*Digester, error) {
+	d := &Digester{
+		config:        cfg,
+		manager:       manager,
+		mailer:        mailer,
+		store:         store,
+		subscriptions: make(map[string]*Subscription),
+	}
+	if store == nil {
+		return d, nil
+	}
+
+	ids, err := store.Keys(subscriptionBucket)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load digest subscriptions: %w", err)
+	}
+	for _, id := range ids {
+		var subscription Subscription
+		if ok, err := store.Get(subscriptionBucket, id, &subscription); err != nil || !ok {
+			log.Printf("Skipping unreadable digest subscription %s: %v", id, err)
+			continue
+		}
+		d.subscriptions[id] = &subscription
+	}
+	return d, nil
+}
+
+// UseSummarizer brings the summaries of conversations up to date before they are mailed
+func (d *Digester) UseSummarizer(summarizer Summarizer) {
+	d.summarizer = summarizer
+}
+
+// Subscribe creates or replaces the subscription of a user
+func (d *Digester) Subscribe(subscription Subscription) (*Subscription, error) {
+	if subscription.UserID == "" {
+		return nil, fmt.Errorf("user id is required")
+	}
+	address, err := mail.ParseAddress(subscription.Email)
+	if err != nil {
+		return nil, fmt.Errorf("invalid email address %q", subscription.Email)
+	}
+	subscription.Email = address.Address
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if existing, exists := d.subscriptions[subscription.UserID]; exists {
+		subscription.CreatedAt, subscription.LastSentAt = existing.CreatedAt, existing.LastSentAt
+	} else {
+		subscription.CreatedAt, subscription.LastSentAt = time.Now(), time.Time{}
+	}
+	if err := d.save(&subscription); err != nil {
+		return nil, err
+	}
+	d.subscriptions[subscription.UserID] = &subscription
+
+	cp := subscription
+	return &cp, nil
+}
+
+// Unsubscribe removes the subscription of a user and reports whether there was one
+func (d *Digester) Unsubscribe(userID string) (bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if _, exists := d.subscriptions[userID]; !exists {
+		return false, nil
+	}
+	if d.store != nil {
+		err := d.store.Update(func(tx *storage.Tx) error {
+			tx.Delete(subscriptionBucket, userID)
+			return nil
+		})
+		if err != nil {
+			return false, err
+		}
+	}
+	delete(d.subscriptions, userID)
+	return true, nil
+}
+
+// Subscription returns the subscription of a user
+func (d *Digester) Subscription(userID string) (*Subscription, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	subscription, exists := d.subscriptions[userID]
+	if !exists {
+		return nil, false
+	}
+	cp := *subscription
+	return &cp, true
+}
+
+// save stores a subscription; callers hold d.mu
+func (d *Digester) save(subscription *Subscription) error {
+	if d.store == nil {
+		return nil
+	}
+	return d.store.Update(func(tx *storage.Tx) error {
+		return tx.Put(subscriptionBucket, subscription.UserID, subscription)
+	})
+}
+
+// Run sends the digest every day at the configured time until ctx is done
+func (d *Digester) Run(ctx context.Context) {
+	for {
+		timer := time.NewTimer(time.Until(nextRun(d.config.SendAt, time.Now())))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+			sent, err := d.Send(ctx)
+			if err != nil {
+				log.Printf("Digest failed: %v", err)
+			}
+			log.Printf("Sent %d digest(s)", sent)
+		}
+	}
+}
+
+// nextRun returns the next time the clock shows sendAt ("15:04"), in local time
+func nextRun(sendAt string, now time.Time) time.Time {
+	clock, err := time.Parse("15:04", sendAt)
+	if err != nil {
+		clock = time.Date(0, 1, 1, 8, 0, 0, 0, time.UTC) // Validated at startup
+	}
+	next := time.Date(now.Year(), now.Month(), now.Day(), clock.Hour(), clock.Minute(), 0, 0, now.Location())
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
+
+// Send mails every subscriber a digest of the conversations active since
+// their previous one and returns how many were sent. Subscribers without
+// activity to report get no email.
+func (d *Digester) Send(ctx context.Context) (int, error) {
+	d.mu.Lock()
+	subscriptions := make([]Subscription, 0, len(d.subscriptions))
+	for _, subscription := range d.subscriptions {
+		subscriptions = append(subscriptions, *subscription)
+	}
+	d.mu.Unlock()
+
+	now := time.Now()
+	summaries := make(map[string]string) // By conversation, written once per run
+	sent := 0
+	var errs []string
+	for _, subscription := range subscriptions {
+		since := subscription.LastSentAt
+		if since.IsZero() || now.Sub(since) > window {
+			since = now.Add(-window)
+		}
+
+		body := d.compose(ctx, &subscription, since, summaries)
+		if body == "" {
+			continue
+		}
+		if err := d.mailer.Send(subscription.Email, d.config.Subject, body); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", subscription.UserID, err))
+			continue
+		}
+		sent++
+		d.markSent(subscription.UserID, now)
+	}
+
+	if len(errs) > 0 {
+		return sent, fmt.Errorf("failed to mail %s", strings.Join(errs, "; "))
+	}
+	return sent, nil
+}
+
+// markSent records when a subscriber last got the digest
+func (d *Digester) markSent(userID string, at time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	subscription, exists := d.subscriptions[userID]
+	if !exists {
+		return // Unsubscribed meanwhile
+	}
+	subscription.LastSentAt = at
+	if err := d.save(subscription); err != nil {
+		log.Printf("Failed to store digest subscription %s: %v", userID, err)
+	}
+}
+
+// compose writes the digest of a subscriber, or "" if nothing happened
+func (d *Digester) compose(ctx context.Context, subscription *Subscription, since time.Time, summaries map[string]string) string {
+	var b strings.Builder
+	for _, info := range d.manager.ListInfo() {
+		if !subscription.covers(info.ID) || info.UpdatedAt.Before(since) {
+			continue
+		}
+		count := d.messagesSince(info.ID, since)
+		if count == 0 {
+			continue
+		}
+
+		summary, done := summaries[info.ID]
+		if !done {
+			summary = d.summary(ctx, info.ID)
+			summaries[info.ID] = summary
+		}
+
+		title := info.Title
+		if title == "" {
+			title = info.ID
+		}
+		fmt.Fprintf(&b, "== %s ==\n%d new message(s)", title, count)
+		if info.Topic != "" {
+			fmt.Fprintf(&b, " · topic: %s", info.Topic)
+		}
+		fmt.Fprintf(&b, "\n\n%s\n\n", summary)
+	}
+	if b.Len() == 0 {
+		return ""
+	}
+	return fmt.Sprintf("What happened since %s:\n\n%s\n", since.Format("Mon 2 Jan 15:04"), strings.TrimRight(b.String(), "\n"))
+}
+
+// messagesSince counts the messages of a conversation after a point in time
+func (d *Digester) messagesSince(conversationID string, since time.Time) int {
+	snapshot, ok := d.manager.Snapshot(conversationID)
+	if !ok {
+		return 0
+	}
+	count := 0
+	for _, message := range snapshot.Messages {
+		if message.Timestamp.After(since) {
+			count++
+		}
+	}
+	return count
+}
+
+// summary returns an up-to-date summary of a conversation
+func (d *Digester) summary(ctx context.Context, conversationID string) string {
+	current := d.manager.GetSummary(conversationID)
+	info, _ := d.manager.GetInfo(conversationID)
+	stale := current == nil || (info != nil && current.MessageCount < info.Messages)
+	if stale && d.summarizer != nil {
+		if summary, err := d.summarizer.WriteSummary(ctx, conversationID); err != nil {
+			log.Printf("Failed to summarize conversation %s for the digest: %v", conversationID, err)
+		} else {
+			current = summary
+		}
+	}
+	if current == nil {
+		return "(no summary yet)"
+	}
+	return current.Content
+}
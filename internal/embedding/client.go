@@ -0,0 +1,110 @@
+// Package embedding turns conversation messages into vectors and keeps them
+// in a store. A background job embeds new messages and clusters them into
+// the topics that emerge from the discussion.
+package embedding
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"philoking/internal/config"
+	"philoking/internal/llmhttp"
+)
+
+// Client embeds texts through Ollama or an OpenAI-compatible API
+type Client struct {
+	provider   string
+	url        string
+	apiKey     string
+	model      string
+	httpConfig config.LLMHTTPConfig
+	http       *http.Client
+}
+
+// NewClient creates a client for the configured provider; an empty URL is
+// derived from the agents' Ollama URL or chat completions endpoint
+func NewClient(cfg config.EmbeddingsConfig, agents config.AgentsConfig, httpClient *http.Client) *Client {
+	url := cfg.URL
+	if url == "" {
+		if cfg.Provider == "openai" {
+			base, _ := strings.CutSuffix(agents.LLMURL, "/chat/completions")
+			url = base + "/embeddings"
+		} else {
+			url = strings.TrimSuffix(agents.OllamaURL, "/") + "/api/embed"
+		}
+	}
+	return &Client{
+		provider:   cfg.Provider,
+		url:        url,
+		apiKey:     agents.LLMAPIKey,
+		model:      cfg.Model,
+		httpConfig: agents.HTTP,
+		http:       httpClient,
+	}
+}
+
+// Embed returns the vectors of the texts, in the same order
+func (c *Client) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	body, err := json.Marshal(map[string]interface{}{"model": c.model, "input": texts})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create embeddings request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.provider == "openai" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+	llmhttp.SetHeaders(req, c.httpConfig, c.provider)
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("embeddings request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("embeddings API error: %d - %s", resp.StatusCode, string(data))
+	}
+
+	var vectors [][]float32
+	if c.provider == "openai" {
+		var result struct {
+			Data []struct {
+				Index     int       `json:"index"`
+				Embedding []float32 `json:"embedding"`
+			} `json:"data"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+			return nil, fmt.Errorf("failed to decode embeddings: %w", err)
+		}
+		vectors = make([][]float32, len(result.Data))
+		for _, item := range result.Data {
+			if item.Index < 0 || item.Index >= len(vectors) {
+				return nil, fmt.Errorf("embedding index %d out of range", item.Index)
+			}
+			vectors[item.Index] = item.Embedding
+		}
+	} else {
+		var result struct {
+			Embeddings [][]float32 `json:"embeddings"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+			return nil, fmt.Errorf("failed to decode embeddings: %w", err)
+		}
+		vectors = result.Embeddings
+	}
+
+	if len(vectors) != len(texts) {
+		return nil, fmt.Errorf("got %d embeddings for %d texts", len(vectors), len(texts))
+	}
+	return vectors, nil
+}
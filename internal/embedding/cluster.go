@@ -0,0 +1,207 @@
+package embedding
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+
+	"philoking/internal/conversation"
+)
+
+const (
+	maxIterations = 20 // Of k-means, which usually settles well before
+	labelWords    = 3  // Keywords in a cluster's label
+	minPerCluster = 3  // Fewer messages per cluster make labels noise
+)
+
+// stopWords are left out of cluster labels
+var stopWords = map[string]bool{
+	"about": true, "after": true, "again": true, "all": true, "also": true, "and": true, "any": true, "are": true,
+	"because": true, "been": true, "before": true, "being": true, "but": true, "can": true, "could": true,
+	"did": true, "does": true, "doing": true, "don't": true, "each": true, "even": true, "every": true,
+	"for": true, "from": true, "had": true, "has": true, "have": true, "her": true, "here": true, "him": true,
+	"his": true, "how": true, "i'm": true, "into": true, "it's": true, "its": true, "just": true, "like": true,
+	"make": true, "many": true, "more": true, "most": true, "much": true, "must": true, "not": true, "now": true,
+	"one": true, "only": true, "other": true, "our": true, "out": true, "perhaps": true, "quite": true,
+	"really": true, "same": true, "says": true, "she": true, "should": true, "some": true, "such": true,
+	"than": true, "that": true, "that's": true, "the": true, "their": true, "them": true, "then": true,
+	"there": true, "these": true, "they": true, "thing": true, "think": true, "this": true, "those": true,
+	"through": true, "too": true, "very": true, "was": true, "way": true, "were": true, "what": true,
+	"when": true, "where": true, "which": true, "while": true, "who": true, "why": true, "will": true,
+	"with": true, "would": true, "yes": true, "yet": true, "you": true, "your": true, "you're": true,
+}
+
+// cluster groups the entries into at most k clusters with k-means on their
+// unit vectors. It returns the labeled clusters, largest first, and the index
+// of each entry's cluster.
+func cluster(entries []Entry, k int) ([]conversation.Cluster, []int) {
+	if n := len(entries) / minPerCluster; k > n {
+		k = n
+	}
+	if k < 1 {
+		k = 1
+	}
+
+	centroids := initialCentroids(entries, k)
+	assignments := make([]int, len(entries))
+	for iteration := 0; iteration < maxIterations; iteration++ {
+		changed := iteration == 0
+		for i, entry := range entries {
+			if nearest := nearestCentroid(entry.Vector, centroids); nearest != assignments[i] {
+				assignments[i], changed = nearest, true
+			}
+		}
+		if !changed {
+			break
+		}
+		centroids = meanCentroids(entries, assignments, centroids)
+	}
+
+	// Order the clusters by size, dropping empty ones
+	sizes := make([]int, len(centroids))
+	for _, index := range assignments {
+		sizes[index]++
+	}
+	order := make([]int, 0, len(centroids))
+	for index, size := range sizes {
+		if size > 0 {
+			order = append(order, index)
+		}
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return sizes[order[i]] > sizes[order[j]]
+	})
+	renumber := make(map[int]int, len(order))
+	for i, index := range order {
+		renumber[index] = i
+	}
+	for i, index := range assignments {
+		assignments[i] = renumber[index]
+	}
+
+	keywords := distinctiveWords(entries, assignments, len(order))
+	clusters := make([]conversation.Cluster, len(order))
+	for i, index := range order {
+		clusters[i] = conversation.Cluster{Label: strings.Join(keywords[i], " / "), Keywords: keywords[i], Messages: sizes[index]}
+		if clusters[i].Label == "" {
+			clusters[i].Label = "misc"
+		}
+	}
+	return clusters, assignments
+}
+
+// initialCentroids picks k entries spread apart: the first one, then each
+// time the entry least similar to the centroids picked so far
+func initialCentroids(entries []Entry, k int) [][]float32 {
+	centroids := [][]float32{entries[0].Vector}
+	for len(centroids) < k {
+		farthest, lowest := -1, float32(math.MaxFloat32)
+		for i, entry := range entries {
+			closest := float32(-math.MaxFloat32)
+			for _, centroid := range centroids {
+				if similarity := dot(entry.Vector, centroid); similarity > closest {
+					closest = similarity
+				}
+			}
+			if closest < lowest {
+				farthest, lowest = i, closest
+			}
+		}
+		centroids = append(centroids, entries[farthest].Vector)
+	}
+	return centroids
+}
+
+// nearestCentroid returns the index of the centroid most similar to vector
+func nearestCentroid(vector []float32, centroids [][]float32) int {
+	nearest, highest := 0, float32(-math.MaxFloat32)
+	for i, centroid := range centroids {
+		if similarity := dot(vector, centroid); similarity > highest {
+			nearest, highest = i, similarity
+		}
+	}
+	return nearest
+}
+
+// meanCentroids returns the normalized mean of each cluster's vectors; an
+// empty cluster keeps its previous centroid
+func meanCentroids(entries []Entry, assignments []int, previous [][]float32) [][]float32 {
+	sums := make([][]float32, len(previous))
+	for i, entry := range entries {
+		index := assignments[i]
+		if sums[index] == nil {
+			sums[index] = make([]float32, len(entry.Vector))
+		}
+		for d, v := range entry.Vector {
+			if d < len(sums[index]) {
+				sums[index][d] += v
+			}
+		}
+	}
+	for index, sum := range sums {
+		if sum == nil {
+			sums[index] = previous[index]
+			continue
+		}
+		sums[index] = normalize(sum)
+	}
+	return sums
+}
+
+// distinctiveWords returns the words that set each cluster apart: frequent
+// in the cluster and rare in the others
+func distinctiveWords(entries []Entry, assignments []int, k int) [][]string {
+	counts := make([]map[string]int, k)
+	for i := range counts {
+		counts[i] = make(map[string]int)
+	}
+	clustersWith := make(map[string]int)
+	for i, entry := range entries {
+		for _, word := range words(entry.Content) {
+			if counts[assignments[i]][word] == 0 {
+				clustersWith[word]++ // First time in this cluster
+			}
+			counts[assignments[i]][word]++
+		}
+	}
+
+	keywords := make([][]string, k)
+	for index, wordCounts := range counts {
+		type scored struct {
+			word  string
+			score float64
+		}
+		candidates := make([]scored, 0, len(wordCounts))
+		for word, count := range wordCounts {
+			idf := math.Log(1 + float64(k)/float64(clustersWith[word]))
+			candidates = append(candidates, scored{word: word, score: float64(count) * idf})
+		}
+		sort.Slice(candidates, func(i, j int) bool {
+			if candidates[i].score != candidates[j].score {
+				return candidates[i].score > candidates[j].score
+			}
+			return candidates[i].word < candidates[j].word
+		})
+		for i := 0; i < len(candidates) && i < labelWords; i++ {
+			keywords[index] = append(keywords[index], candidates[i].word)
+		}
+	}
+	return keywords
+}
+
+// words splits a message into lowercase words, without stop words and words
+// shorter than three letters
+func words(content string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(content), func(r rune) bool {
+		return !unicode.IsLetter(r) && r != '\''
+	})
+	kept := fields[:0]
+	for _, word := range fields {
+		word = strings.Trim(word, "'")
+		if len([]rune(word)) >= 3 && !stopWords[word] {
+			kept = append(kept, word)
+		}
+	}
+	return kept
+}
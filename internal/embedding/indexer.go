@@ -0,0 +1,135 @@
+package embedding
+
+import (
+	"context"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"philoking/internal/config"
+	"philoking/internal/conversation"
+	"philoking/internal/types"
+)
+
+// Indexer is the background job that embeds new messages and clusters the
+// conversations they belong to
+type Indexer struct {
+	config  config.EmbeddingsConfig
+	client  *Client
+	manager *conversation.Manager
+	store   *Store
+}
+
+// New creates the indexer, which keeps the embeddings in store
+func New(cfg config.EmbeddingsConfig, client *Client, manager *conversation.Manager, store *Store) *Indexer {
+	return &Indexer{config: cfg, client: client, manager: manager, store: store}
+}
+
+// Run indexes the conversations every configured interval until ctx is done
+func (x *Indexer) Run(ctx context.Context) {
+	ticker := time.NewTicker(x.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			x.Index(ctx)
+		}
+	}
+}
+
+// Index embeds the messages that are new since the last run and clusters
+// the conversations that got any, or were not clustered yet
+func (x *Indexer) Index(ctx context.Context) {
+	for _, conversationID := range x.manager.ListConversations() {
+		if ctx.Err() != nil {
+			return
+		}
+		added, err := x.embed(ctx, conversationID)
+		if err != nil {
+			log.Printf("Failed to embed messages of conversation %s: %v", conversationID, err)
+		}
+		// Clusters are redone when embeddings were added or erased
+		clustering := x.manager.GetClusters(conversationID)
+		entries := x.store.Conversation(conversationID)
+		if added == 0 && clustering != nil && clustered(clustering) == len(entries) {
+			continue
+		}
+		if len(entries) < x.config.MinMessages {
+			if clustering != nil && len(clustering.Clusters) > 0 {
+				x.manager.SetClusters(conversationID, nil, nil)
+			}
+			continue
+		}
+		clusters, indexes := cluster(entries, x.config.Clusters)
+		assignments := make(map[string]int, len(entries))
+		for i, entry := range entries {
+			assignments[entry.MessageID] = indexes[i]
+		}
+		x.manager.SetClusters(conversationID, clusters, assignments)
+	}
+}
+
+// clustered returns the number of messages a clustering was made of
+func clustered(clustering *conversation.Clustering) int {
+	total := 0
+	for _, cluster := range clustering.Clusters {
+		total += cluster.Messages
+	}
+	return total
+}
+
+// embed embeds the discussion messages of a conversation that are not in the
+// store yet, in batches, and returns how many were added
+func (x *Indexer) embed(ctx context.Context, conversationID string) (int, error) {
+	messages := x.manager.GetMessages(conversationID)
+	var pending []*types.ChatMessage
+	for _, message := range messages {
+		if message.IsCommand() || (message.Type != types.MessageTypeAgent && message.Type != types.MessageTypeUser) {
+			continue
+		}
+		// Scrubbed messages stay out, or a deletion would bring them back as tombstones
+		if strings.TrimSpace(message.Content) == "" || x.store.Has(message.ID) || slices.Contains(message.Metadata.Tags, conversation.DeletedTag) {
+			continue
+		}
+		pending = append(pending, message)
+	}
+
+	added := 0
+	for start := 0; start < len(pending); start += x.config.BatchSize {
+		batch := pending[start:min(start+x.config.BatchSize, len(pending))]
+		texts := make([]string, len(batch))
+		for i, message := range batch {
+			texts[i] = message.Content
+		}
+		vectors, err := x.client.Embed(ctx, texts)
+		if err != nil {
+			return added, err
+		}
+
+		entries := make([]Entry, len(batch))
+		for i, message := range batch {
+			sender := message.AgentID
+			if sender == "" {
+				sender = message.UserID
+			}
+			entries[i] = Entry{
+				MessageID:      message.ID,
+				ConversationID: conversationID,
+				Sender:         sender,
+				Name:           message.Metadata.FromAgent,
+				Content:        message.Content,
+				At:             message.Timestamp,
+				Vector:         vectors[i],
+			}
+		}
+		if err := x.store.Add(conversationID, entries); err != nil {
+			log.Printf("Failed to store embeddings of conversation %s: %v", conversationID, err)
+		}
+		added += len(entries)
+	}
+	return added, nil
+}
@@ -0,0 +1,84 @@
+package embedding
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Match is a message found by a search, with its similarity to the query
+type Match struct {
+	Entry
+	Score float32 `json:"score"`
+}
+
+// Search returns the messages of the conversations most similar to vector,
+// most similar first, leaving out those sent before since
+func (s *Store) Search(vector []float32, conversationIDs []string, since time.Time, limit int) []Match {
+	vector = normalize(vector)
+
+	s.mu.RLock()
+	var matches []Match
+	for _, conversationID := range conversationIDs {
+		for _, entry := range s.conversations[conversationID] {
+			if entry.At.Before(since) {
+				continue
+			}
+			matches = append(matches, Match{Entry: entry, Score: dot(vector, entry.Vector)})
+		}
+	}
+	s.mu.RUnlock()
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].Score > matches[j].Score
+	})
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches
+}
+
+// Searcher finds earlier messages by meaning rather than by their words
+type Searcher struct {
+	client *Client
+	store  *Store
+}
+
+// NewSearcher creates a searcher over the embeddings in store
+func NewSearcher(client *Client, store *Store) *Searcher {
+	return &Searcher{client: client, store: store}
+}
+
+// Search embeds the query and returns the most similar messages of the
+// conversations. A query that mentions a period, such as "last week", only
+// matches messages sent since then.
+func (s *Searcher) Search(ctx context.Context, query string, conversationIDs []string, limit int) ([]Match, error) {
+	vectors, err := s.client.Embed(ctx, []string{query})
+	if err != nil {
+		return nil, err
+	}
+	return s.store.Search(vectors[0], conversationIDs, since(query, time.Now()), limit), nil
+}
+
+// since returns the start of the period a query mentions, or the zero time
+// when it mentions none
+func since(query string, now time.Time) time.Time {
+	query = strings.ToLower(query)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	switch {
+	case strings.Contains(query, "today"):
+		return today
+	case strings.Contains(query, "yesterday"):
+		return today.AddDate(0, 0, -1)
+	case strings.Contains(query, "this week"):
+		return today.AddDate(0, 0, -7)
+	case strings.Contains(query, "last week"):
+		return today.AddDate(0, 0, -14)
+	case strings.Contains(query, "this month"):
+		return today.AddDate(0, -1, 0)
+	case strings.Contains(query, "last month"):
+		return today.AddDate(0, -2, 0)
+	}
+	return time.Time{}
+}
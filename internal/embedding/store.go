@@ -0,0 +1,169 @@
+package embedding
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sync"
+	"time"
+
+	"philoking/internal/storage"
+)
+
+// bucket stores the embeddings of each conversation when storage is configured
+const bucket = "embeddings"
+
+// Entry is the embedding of a message
+type Entry struct {
+	MessageID      string    `json:"message_id"`
+	ConversationID string    `json:"conversation_id"`
+	Sender         string    `json:"sender"` // Agent or user ID
+	Name           string    `json:"name,omitempty"`
+	Content        string    `json:"content"`
+	At             time.Time `json:"at"`
+	Vector         []float32 `json:"vector"` // Normalized to unit length
+}
+
+// Store keeps the embeddings of the conversations' messages; it is safe for concurrent use
+type Store struct {
+	store *storage.Store // Nil keeps embeddings in memory
+
+	mu            sync.RWMutex
+	conversations map[string][]Entry // By conversation ID, in the order they were added
+	messages      map[string]bool    // IDs of the embedded messages
+}
+
+// NewStore creates a store and loads the stored embeddings; store may be nil
+func NewStore(store *storage.Store) (*Store, error) {
+	s := &Store{
+		store:         store,
+		conversations: make(map[string][]Entry),
+		messages:      make(map[string]bool),
+	}
+	if store == nil {
+		return s, nil
+	}
+
+	ids, err := store.Keys(bucket)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load embeddings: %w", err)
+	}
+	for _, id := range ids {
+		var entries []Entry
+		if ok, err := store.Get(bucket, id, &entries); err != nil || !ok {
+			log.Printf("Skipping unreadable embeddings of conversation %s: %v", id, err)
+			continue
+		}
+		s.conversations[id] = entries
+		for _, entry := range entries {
+			s.messages[entry.MessageID] = true
+		}
+	}
+	return s, nil
+}
+
+// Has reports whether a message was embedded
+func (s *Store) Has(messageID string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.messages[messageID]
+}
+
+// Add stores the embeddings of a conversation's messages, normalizing their vectors
+func (s *Store) Add(conversationID string, entries []Entry) error {
+	s.mu.Lock()
+	for _, entry := range entries {
+		if s.messages[entry.MessageID] {
+			continue
+		}
+		entry.Vector = normalize(entry.Vector)
+		s.conversations[conversationID] = append(s.conversations[conversationID], entry)
+		s.messages[entry.MessageID] = true
+	}
+	all := append([]Entry(nil), s.conversations[conversationID]...)
+	s.mu.Unlock()
+
+	if s.store == nil {
+		return nil
+	}
+	return s.store.Update(func(tx *storage.Tx) error {
+		return tx.Put(bucket, conversationID, all)
+	})
+}
+
+// ForgetUser removes the embeddings of a user's messages, with their
+// content, and returns how many were removed
+func (s *Store) ForgetUser(userID string) (int, error) {
+	s.mu.Lock()
+	removed := 0
+	changed := make(map[string][]Entry)
+	for conversationID, entries := range s.conversations {
+		kept := make([]Entry, 0, len(entries))
+		for _, entry := range entries {
+			if entry.Sender == userID {
+				delete(s.messages, entry.MessageID)
+				continue
+			}
+			kept = append(kept, entry)
+		}
+		if len(kept) == len(entries) {
+			continue
+		}
+		removed += len(entries) - len(kept)
+		s.conversations[conversationID] = kept
+		changed[conversationID] = kept
+	}
+	s.mu.Unlock()
+
+	if s.store == nil || len(changed) == 0 {
+		return removed, nil
+	}
+	return removed, s.store.Update(func(tx *storage.Tx) error {
+		for conversationID, entries := range changed {
+			if len(entries) == 0 {
+				tx.Delete(bucket, conversationID)
+				continue
+			}
+			if err := tx.Put(bucket, conversationID, entries); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Conversation returns the embeddings of a conversation's messages
+func (s *Store) Conversation(conversationID string) []Entry {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]Entry(nil), s.conversations[conversationID]...)
+}
+
+// normalize scales a vector to unit length, so dot products are cosine similarities
+func normalize(vector []float32) []float32 {
+	var sum float64
+	for _, v := range vector {
+		sum += float64(v) * float64(v)
+	}
+	if sum == 0 {
+		return vector
+	}
+	norm := float32(math.Sqrt(sum))
+	normalized := make([]float32, len(vector))
+	for i, v := range vector {
+		normalized[i] = v / norm
+	}
+	return normalized
+}
+
+// dot returns the dot product of two vectors of the same length
+func dot(a, b []float32) float32 {
+	var sum float32
+	for i := range a {
+		if i >= len(b) {
+			break
+		}
+		sum += a[i] * b[i]
+	}
+	return sum
+}
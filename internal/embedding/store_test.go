@@ -0,0 +1,62 @@
+package embedding
+
+import (
+	"testing"
+
+	"philoking/internal/storage"
+)
+
+func TestStoreForgetUser(t *testing.T) {
+	dir := t.TempDir()
+	disk, err := storage.Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store, err := NewStore(disk)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	add := func(conversationID string, entries ...Entry) {
+		if err := store.Add(conversationID, entries); err != nil {
+			t.Fatal(err)
+		}
+	}
+	add("a",
+		Entry{MessageID: "a1", Sender: "alice", Content: "my secret", Vector: []float32{1, 0}},
+		Entry{MessageID: "a2", Sender: "socrates", Content: "a question", Vector: []float32{0, 1}},
+	)
+	add("b", Entry{MessageID: "b1", Sender: "alice", Content: "more secrets", Vector: []float32{1, 1}})
+
+	removed, err := store.ForgetUser("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed != 2 {
+		t.Errorf("removed = %d, want 2", removed)
+	}
+	if removed, _ := store.ForgetUser("alice"); removed != 0 {
+		t.Errorf("second ForgetUser removed %d", removed)
+	}
+
+	reloaded, err := NewStore(disk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, s := range map[string]*Store{"memory": store, "reloaded": reloaded} {
+		t.Run(name, func(t *testing.T) {
+			if s.Has("a1") || s.Has("b1") {
+				t.Error("the user's embeddings are still there")
+			}
+			if !s.Has("a2") {
+				t.Error("another sender's embedding was removed")
+			}
+			if entries := s.Conversation("a"); len(entries) != 1 || entries[0].MessageID != "a2" {
+				t.Errorf("conversation a = %+v", entries)
+			}
+			if entries := s.Conversation("b"); len(entries) != 0 {
+				t.Errorf("conversation b = %+v", entries)
+			}
+		})
+	}
+}
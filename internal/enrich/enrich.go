@@ -0,0 +1,190 @@
+// Package enrich adds facts from outside the conversation, such as the
+// weather or an encyclopedia entry, to LLM prompts. Context providers are
+// only asked when a message mentions one of their keywords or the
+// conversation is on one of their topics.
+package enrich
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"philoking/internal/config"
+)
+
+// maxCacheEntries bounds the lookups kept in memory
+const maxCacheEntries = 256
+
+// ContextProvider looks up facts from outside the conversation
+type ContextProvider interface {
+	// Name introduces the provider's facts in the prompt, e.g. "Weather"
+	Name() string
+	// Lookup returns facts about subject, what the message asks about or the
+	// conversation's topic; "" means there are none
+	Lookup(ctx context.Context, subject string) (string, error)
+}
+
+// trigger decides when a provider is asked
+type trigger struct {
+	provider ContextProvider
+	keywords *regexp.Regexp // Nil without keywords
+	topics   map[string]bool
+}
+
+// cached is a lookup kept for reuse
+type cached struct {
+	facts   string
+	expires time.Time
+}
+
+// Enricher asks the context providers a message calls for and adds their
+// facts to the prompt; it is safe for concurrent use
+type Enricher struct {
+	timeout  time.Duration
+	cacheTTL time.Duration
+	triggers []trigger
+
+	mu    sync.Mutex
+	cache map[string]cached // By provider name and subject
+}
+
+// New creates an enricher with the configured providers, which send their
+// requests through client; it returns nil when no providers are configured
+func New(cfg config.ContextConfig, client *http.Client) (*Enricher, error) {
+	if len(cfg.Providers) == 0 {
+		return nil, nil
+	}
+
+	e := &Enricher{
+		timeout:  cfg.Timeout,
+		cacheTTL: cfg.CacheTTL,
+		cache:    make(map[string]cached),
+	}
+	for _, providerConfig := range cfg.Providers {
+		var provider ContextProvider
+		var keywords []string
+		switch providerConfig.Type {
+		case "weather":
+			provider, keywords = newWeather(providerConfig, client), weatherKeywords
+		case "wikipedia":
+			provider, keywords = newWikipedia(providerConfig, client), wikipediaKeywords
+		default:
+			return nil, fmt.Errorf("unsupported context provider: %s", providerConfig.Type)
+		}
+		if len(providerConfig.Keywords) > 0 {
+			keywords = providerConfig.Keywords
+		}
+		e.Add(provider, keywords, providerConfig.Topics)
+	}
+	return e, nil
+}
+
+// Add plugs in a provider, asked when a message contains one of the keywords
+// or the conversation is on one of the topics
+func (e *Enricher) Add(provider ContextProvider, keywords, topics []string) {
+	t := trigger{provider: provider, keywords: keywordPattern(keywords), topics: make(map[string]bool)}
+	for _, topic := range topics {
+		t.topics[strings.ToLower(topic)] = true
+	}
+	e.triggers = append(e.triggers, t)
+}
+
+// keywordPattern matches any of the keywords as a whole, ignoring case, and
+// captures the rest of the sentence as the subject
+func keywordPattern(keywords []string) *regexp.Regexp {
+	var alternatives []string
+	for _, keyword := range keywords {
+		if keyword = strings.TrimSpace(keyword); keyword != "" {
+			alternatives = append(alternatives, regexp.QuoteMeta(keyword))
+		}
+	}
+	if len(alternatives) == 0 {
+		return nil
+	}
+	return regexp.MustCompile(`(?i)\b(?:` + strings.Join(alternatives, "|") + `)\b([^.?!\n]*)`)
+}
+
+// subject returns what a provider should look up for a message, and whether
+// the message calls for the provider at all
+func (t trigger) subject(message, topic string) (string, bool) {
+	if t.keywords != nil {
+		if match := t.keywords.FindStringSubmatch(message); match != nil {
+			if subject := strings.Trim(match[1], " ,;:\"'()"); subject != "" {
+				return subject, true
+			}
+			return topic, true
+		}
+	}
+	if topic != "" && t.topics[strings.ToLower(topic)] {
+		return topic, true
+	}
+	return "", false
+}
+
+// Prompt returns the facts the providers have on a message, ready to add to
+// a system prompt, or "" when the message calls for none. A provider that
+// fails or runs out of time is left out.
+func (e *Enricher) Prompt(ctx context.Context, message, topic string) string {
+	var facts []string
+	for _, t := range e.triggers {
+		subject, ok := t.subject(message, topic)
+		if !ok {
+			continue
+		}
+		found, err := e.lookup(ctx, t.provider, subject)
+		if err != nil {
+			log.Printf("Context provider %s could not look up %q: %v", t.provider.Name(), subject, err)
+			continue
+		}
+		if found != "" {
+			facts = append(facts, fmt.Sprintf("%s: %s", t.provider.Name(), found))
+		}
+	}
+	if len(facts) == 0 {
+		return ""
+	}
+	return " Background you may use if it helps, without quoting it: " + strings.Join(facts, " ")
+}
+
+// lookup asks a provider about a subject, reusing a recent answer
+func (e *Enricher) lookup(ctx context.Context, provider ContextProvider, subject string) (string, error) {
+	key := provider.Name() + "\x00" + strings.ToLower(subject)
+	now := time.Now()
+
+	e.mu.Lock()
+	entry, ok := e.cache[key]
+	e.mu.Unlock()
+	if ok && now.Before(entry.expires) {
+		return entry.facts, nil
+	}
+
+	if e.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, e.timeout)
+		defer cancel()
+	}
+	facts, err := provider.Lookup(ctx, subject)
+	if err != nil {
+		return "", err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if len(e.cache) >= maxCacheEntries {
+		for k, v := range e.cache {
+			if now.After(v.expires) {
+				delete(e.cache, k)
+			}
+		}
+		if len(e.cache) >= maxCacheEntries {
+			e.cache = make(map[string]cached)
+		}
+	}
+	e.cache[key] = cached{facts: facts, expires: now.Add(e.cacheTTL)}
+	return facts, nil
+}
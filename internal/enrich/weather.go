@@ -0,0 +1,163 @@
+package enrich
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"philoking/internal/config"
+)
+
+// weatherKeywords ask for the weather unless the configuration lists its own
+var weatherKeywords = []string{"weather", "forecast", "temperature", "rain", "raining", "sunny", "snow", "snowing", "umbrella"}
+
+const (
+	defaultForecastURL  = "https://api.open-meteo.com/v1/forecast"
+	defaultGeocodingURL = "https://geocoding-api.open-meteo.com/v1/search"
+)
+
+// weather reports the current weather at the configured place from Open-Meteo
+type weather struct {
+	location string
+	url      string
+	client   *http.Client
+
+	mu                  sync.Mutex
+	latitude, longitude float64
+	place               string // Resolved name of the location; empty until geocoded
+}
+
+// newWeather creates the weather provider; a location of the form
+// "52.37,4.89" is used as coordinates without geocoding
+func newWeather(cfg config.ContextProviderConfig, client *http.Client) *weather {
+	w := &weather{location: cfg.Location, url: cfg.URL, client: client}
+	if w.url == "" {
+		w.url = defaultForecastURL
+	}
+	if lat, lon, ok := strings.Cut(cfg.Location, ","); ok {
+		latitude, errLat := strconv.ParseFloat(strings.TrimSpace(lat), 64)
+		longitude, errLon := strconv.ParseFloat(strings.TrimSpace(lon), 64)
+		if errLat == nil && errLon == nil {
+			w.latitude, w.longitude, w.place = latitude, longitude, cfg.Location
+		}
+	}
+	return w
+}
+
+// Name introduces the weather in the prompt
+func (w *weather) Name() string {
+	return "Weather"
+}
+
+// Lookup returns the current weather at the configured place; the subject is ignored
+func (w *weather) Lookup(ctx context.Context, subject string) (string, error) {
+	latitude, longitude, place, err := w.coordinates(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	query := url.Values{
+		"latitude":  {strconv.FormatFloat(latitude, 'f', 4, 64)},
+		"longitude": {strconv.FormatFloat(longitude, 'f', 4, 64)},
+		"current":   {"temperature_2m,weather_code,wind_speed_10m"},
+	}
+	var forecast struct {
+		Current struct {
+			Temperature float64 `json:"temperature_2m"`
+			WeatherCode int     `json:"weather_code"`
+			WindSpeed   float64 `json:"wind_speed_10m"`
+		} `json:"current"`
+	}
+	if err := getJSON(ctx, w.client, w.url+"?"+query.Encode(), &forecast); err != nil {
+		return "", err
+	}
+
+	current := forecast.Current
+	return fmt.Sprintf("in %s it is now %.0f°C, %s, with wind at %.0f km/h.", place, current.Temperature, weatherDescription(current.WeatherCode), current.WindSpeed), nil
+}
+
+// coordinates returns where the configured place is, geocoding it once
+func (w *weather) coordinates(ctx context.Context) (float64, float64, string, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.place != "" {
+		return w.latitude, w.longitude, w.place, nil
+	}
+
+	var result struct {
+		Results []struct {
+			Name      string  `json:"name"`
+			Country   string  `json:"country"`
+			Latitude  float64 `json:"latitude"`
+			Longitude float64 `json:"longitude"`
+		} `json:"results"`
+	}
+	query := url.Values{"name": {w.location}, "count": {"1"}}
+	if err := getJSON(ctx, w.client, defaultGeocodingURL+"?"+query.Encode(), &result); err != nil {
+		return 0, 0, "", err
+	}
+	if len(result.Results) == 0 {
+		return 0, 0, "", fmt.Errorf("unknown location %q", w.location)
+	}
+	found := result.Results[0]
+	w.latitude, w.longitude, w.place = found.Latitude, found.Longitude, found.Name
+	if found.Country != "" {
+		w.place += ", " + found.Country
+	}
+	return w.latitude, w.longitude, w.place, nil
+}
+
+// weatherDescription describes a WMO weather code
+func weatherDescription(code int) string {
+	switch {
+	case code == 0:
+		return "clear sky"
+	case code <= 2:
+		return "partly cloudy"
+	case code == 3:
+		return "overcast"
+	case code <= 48:
+		return "foggy"
+	case code <= 57:
+		return "drizzle"
+	case code <= 67:
+		return "rain"
+	case code <= 77:
+		return "snow"
+	case code <= 82:
+		return "rain showers"
+	case code <= 86:
+		return "snow showers"
+	default:
+		return "thunderstorms"
+	}
+}
+
+// getJSON performs a GET request and decodes the JSON response into out
+func getJSON(ctx context.Context, client *http.Client, target string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", userAgent)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("%s returned %d: %s", req.URL.Host, resp.StatusCode, string(body))
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,80 @@
+package enrich
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"philoking/internal/config"
+)
+
+// userAgent identifies the providers' requests, as Wikimedia asks
+const userAgent = "philoking/1.0 (context provider)"
+
+// maxExtract bounds the characters of an article summary added to a prompt
+const maxExtract = 800
+
+// wikipediaKeywords ask for an encyclopedia entry unless the configuration lists its own
+var wikipediaKeywords = []string{"who was", "who is", "what is", "what was", "tell me about", "history of"}
+
+// wikipedia looks up the summary of the best-matching Wikipedia article
+type wikipedia struct {
+	url    string // Site, e.g. "https://en.wikipedia.org"
+	client *http.Client
+}
+
+// newWikipedia creates the Wikipedia provider for the configured language
+func newWikipedia(cfg config.ContextProviderConfig, client *http.Client) *wikipedia {
+	site := strings.TrimSuffix(cfg.URL, "/")
+	if site == "" {
+		language := cfg.Language
+		if language == "" {
+			language = "en"
+		}
+		site = fmt.Sprintf("https://%s.wikipedia.org", language)
+	}
+	return &wikipedia{url: site, client: client}
+}
+
+// Name introduces the encyclopedia entry in the prompt
+func (w *wikipedia) Name() string {
+	return "Wikipedia"
+}
+
+// Lookup returns the summary of the article that best matches the subject
+func (w *wikipedia) Lookup(ctx context.Context, subject string) (string, error) {
+	if subject == "" {
+		return "", nil
+	}
+
+	var search struct {
+		Pages []struct {
+			Key string `json:"key"`
+		} `json:"pages"`
+	}
+	query := url.Values{"q": {subject}, "limit": {"1"}}
+	if err := getJSON(ctx, w.client, w.url+"/w/rest.php/v1/search/page?"+query.Encode(), &search); err != nil {
+		return "", err
+	}
+	if len(search.Pages) == 0 {
+		return "", nil
+	}
+
+	var summary struct {
+		Title   string `json:"title"`
+		Extract string `json:"extract"`
+	}
+	if err := getJSON(ctx, w.client, w.url+"/api/rest_v1/page/summary/"+url.PathEscape(search.Pages[0].Key), &summary); err != nil {
+		return "", err
+	}
+	extract := strings.TrimSpace(summary.Extract)
+	if extract == "" {
+		return "", nil
+	}
+	if runes := []rune(extract); len(runes) > maxExtract {
+		extract = string(runes[:maxExtract]) + "…"
+	}
+	return fmt.Sprintf("%s. %s", summary.Title, extract), nil
+}
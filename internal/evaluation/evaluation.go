@@ -0,0 +1,330 @@
+// Package evaluation has an LLM judge look back over the stored
+// conversations and score how each agent did. Reports average the scores
+// per agent, so configs can be tuned and compared from one report to the next.
+package evaluation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"philoking/internal/config"
+	"philoking/internal/conversation"
+	"philoking/internal/storage"
+	"philoking/internal/types"
+
+	"github.com/google/uuid"
+)
+
+// bucket stores the reports when storage is configured
+const bucket = "evaluations"
+
+// States of a report
+const (
+	StatusRunning = "running"
+	StatusDone    = "done"
+	StatusFailed  = "failed"
+)
+
+// ErrRunning is returned when an evaluation is started while another runs
+var ErrRunning = errors.New("an evaluation is already running")
+
+// Scores rate an agent's part in a conversation from 1 to 10
+type Scores struct {
+	Coherence int `json:"coherence"` // How well its messages follow the discussion and hang together
+	// Repetitiveness is how much it repeats itself or others; lower is better
+	Repetitiveness int    `json:"repetitiveness"`
+	Engagement     int    `json:"engagement"` // How much it draws others in and moves the discussion on
+	Note           string `json:"note,omitempty"`
+}
+
+// Judge scores the agents taking part in a conversation, by agent ID
+type Judge interface {
+	EvaluateConversation(ctx context.Context, conversationID string, messages []*types.ChatMessage) (map[string]Scores, error)
+}
+
+// ConversationResult is the judge's verdict on one conversation
+type ConversationResult struct {
+	ConversationID string            `json:"conversation_id"`
+	Messages       int               `json:"messages"`
+	Scores         map[string]Scores `json:"scores,omitempty"` // By agent ID
+	Error          string            `json:"error,omitempty"`
+}
+
+// AgentResult averages an agent's scores over the conversations it took part in
+type AgentResult struct {
+	AgentID        string  `json:"agent_id"`
+	Name           string  `json:"name,omitempty"`
+	Conversations  int     `json:"conversations"`
+	Coherence      float64 `json:"coherence"`
+	Repetitiveness float64 `json:"repetitiveness"`
+	Engagement     float64 `json:"engagement"`
+}
+
+// Report is the outcome of one evaluation run
+type Report struct {
+	ID            string               `json:"id"`
+	Status        string               `json:"status"`
+	Judge         string               `json:"judge"`
+	StartedAt     time.Time            `json:"started_at"`
+	FinishedAt    time.Time            `json:"finished_at,omitempty"`
+	Agents        []AgentResult        `json:"agents"`
+	Conversations []ConversationResult `json:"conversations,omitempty"`
+	Error         string               `json:"error,omitempty"`
+}
+
+// Evaluator runs the evaluations and keeps their reports
+type Evaluator struct {
+	config  config.EvaluationConfig
+	manager *conversation.Manager
+	judge   Judge
+	judgeID string
+	store   *storage.Store // Nil keeps reports in memory
+
+	mu      sync.Mutex
+	reports map[string]*Report
+	running bool
+}
+
+// New creates an evaluator and loads the stored reports; store may be nil
+func New(cfg config.EvaluationConfig, manager *conversation.Manager, judgeID string, judge Judge, store *storage.Store) (*Evaluator, error) {
+	e := &Evaluator{
+		config:  cfg,
+		manager: manager,
+		judge:   judge,
+		judgeID: judgeID,
+		store:   store,
+		reports: make(map[string]*Report),
+	}
+	if store == nil {
+		return e, nil
+	}
+
+	ids, err := store.Keys(bucket)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load evaluation reports: %w", err)
+	}
+	for _, id := range ids {
+		var report Report
+		if ok, err := store.Get(bucket, id, &report); err != nil || !ok {
+			log.Printf("Skipping unreadable evaluation report %s: %v", id, err)
+			continue
+		}
+		if report.Status == StatusRunning {
+			report.Status, report.Error = StatusFailed, "interrupted by a restart"
+		}
+		e.reports[id] = &report
+	}
+	return e, nil
+}
+
+// Run evaluates the conversations every configured interval until ctx is done
+func (e *Evaluator) Run(ctx context.Context) {
+	if e.config.Interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(e.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := e.Start(ctx); err != nil {
+				log.Printf("Evaluation not started: %v", err)
+			}
+		}
+	}
+}
+
+// Start begins an evaluation in the background and returns its report, which fills in as it runs
+func (e *Evaluator) Start(ctx context.Context) (*Report, error) {
+	e.mu.Lock()
+	if e.running {
+		e.mu.Unlock()
+		return nil, ErrRunning
+	}
+	e.running = true
+	report := &Report{
+		ID:        uuid.New().String()[:8],
+		Status:    StatusRunning,
+		Judge:     e.judgeID,
+		StartedAt: time.Now(),
+		Agents:    make([]AgentResult, 0),
+	}
+	e.reports[report.ID] = report
+	cp := *report
+	e.mu.Unlock()
+
+	if err := e.save(&cp); err != nil {
+		log.Printf("Failed to store evaluation report %s: %v", cp.ID, err)
+	}
+	go e.evaluate(context.WithoutCancel(ctx), report.ID)
+	return &cp, nil
+}
+
+// evaluate has the judge score every conversation with enough messages and writes the report
+func (e *Evaluator) evaluate(ctx context.Context, reportID string) {
+	defer func() {
+		e.mu.Lock()
+		e.running = false
+		e.mu.Unlock()
+	}()
+	log.Printf("Evaluation %s started", reportID)
+
+	var results []ConversationResult
+	names := make(map[string]string)
+	for _, conversationID := range e.manager.ListConversations() {
+		messages := agentTranscript(e.manager.GetRecentMessages(conversationID, e.config.MaxMessages))
+		if len(messages) < e.config.MinMessages {
+			continue
+		}
+		for _, message := range messages {
+			if message.Type == types.MessageTypeAgent && message.Metadata.FromAgent != "" {
+				names[message.AgentID] = message.Metadata.FromAgent
+			}
+		}
+
+		result := ConversationResult{ConversationID: conversationID, Messages: len(messages)}
+		scores, err := e.judge.EvaluateConversation(ctx, conversationID, messages)
+		if err != nil {
+			log.Printf("Evaluation %s: could not judge conversation %s: %v", reportID, conversationID, err)
+			result.Error = err.Error()
+		} else {
+			result.Scores = scores
+		}
+		results = append(results, result)
+	}
+
+	e.mu.Lock()
+	report := e.reports[reportID]
+	report.Conversations = results
+	report.Agents = summarize(results, names)
+	report.FinishedAt = time.Now()
+	report.Status = StatusDone
+	if len(results) > 0 && len(report.Agents) == 0 {
+		report.Status, report.Error = StatusFailed, "the judge scored no conversation"
+	}
+	cp := *report
+	e.prune()
+	e.mu.Unlock()
+
+	if err := e.save(&cp); err != nil {
+		log.Printf("Failed to store evaluation report %s: %v", reportID, err)
+	}
+	log.Printf("Evaluation %s %s: %d conversation(s), %d agent(s)", reportID, cp.Status, len(results), len(cp.Agents))
+}
+
+// Get returns a copy of a report
+func (e *Evaluator) Get(id string) (*Report, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	report, exists := e.reports[id]
+	if !exists {
+		return nil, false
+	}
+	cp := *report
+	return &cp, true
+}
+
+// List returns the reports without their per-conversation results, newest first
+func (e *Evaluator) List() []*Report {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	reports := make([]*Report, 0, len(e.reports))
+	for _, report := range e.reports {
+		cp := *report
+		cp.Conversations = nil
+		reports = append(reports, &cp)
+	}
+	sort.Slice(reports, func(i, j int) bool {
+		return reports[i].StartedAt.After(reports[j].StartedAt)
+	})
+	return reports
+}
+
+// prune drops the oldest finished reports beyond the configured number to keep; e.mu must be held
+func (e *Evaluator) prune() {
+	var finished []*Report
+	for _, report := range e.reports {
+		if report.Status != StatusRunning {
+			finished = append(finished, report)
+		}
+	}
+	if len(finished) <= e.config.Keep {
+		return
+	}
+	sort.Slice(finished, func(i, j int) bool {
+		return finished[i].StartedAt.After(finished[j].StartedAt)
+	})
+	for _, report := range finished[e.config.Keep:] {
+		delete(e.reports, report.ID)
+		if e.store != nil {
+			id := report.ID
+			if err := e.store.Update(func(tx *storage.Tx) error {
+				tx.Delete(bucket, id)
+				return nil
+			}); err != nil {
+				log.Printf("Failed to delete evaluation report %s: %v", id, err)
+			}
+		}
+	}
+}
+
+// save stores a report when storage is configured
+func (e *Evaluator) save(report *Report) error {
+	if e.store == nil {
+		return nil
+	}
+	return e.store.Update(func(tx *storage.Tx) error {
+		return tx.Put(bucket, report.ID, report)
+	})
+}
+
+// agentTranscript keeps the messages of the discussion itself: those of
+// users and agents, without commands
+func agentTranscript(messages []*types.ChatMessage) []*types.ChatMessage {
+	transcript := make([]*types.ChatMessage, 0, len(messages))
+	for _, message := range messages {
+		if (message.Type == types.MessageTypeUser || message.Type == types.MessageTypeAgent) && !message.IsCommand() {
+			transcript = append(transcript, message)
+		}
+	}
+	return transcript
+}
+
+// summarize averages the scores of each agent over the conversations it was scored in
+func summarize(results []ConversationResult, names map[string]string) []AgentResult {
+	byAgent := make(map[string]*AgentResult)
+	for _, result := range results {
+		for agentID, scores := range result.Scores {
+			agent, exists := byAgent[agentID]
+			if !exists {
+				agent = &AgentResult{AgentID: agentID, Name: names[agentID]}
+				byAgent[agentID] = agent
+			}
+			agent.Conversations++
+			agent.Coherence += float64(scores.Coherence)
+			agent.Repetitiveness += float64(scores.Repetitiveness)
+			agent.Engagement += float64(scores.Engagement)
+		}
+	}
+
+	agents := make([]AgentResult, 0, len(byAgent))
+	for _, agent := range byAgent {
+		n := float64(agent.Conversations)
+		agent.Coherence, agent.Repetitiveness, agent.Engagement = agent.Coherence/n, agent.Repetitiveness/n, agent.Engagement/n
+		agents = append(agents, *agent)
+	}
+	sort.Slice(agents, func(i, j int) bool {
+		return agents[i].AgentID < agents[j].AgentID
+	})
+	return agents
+}
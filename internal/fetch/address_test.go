@@ -0,0 +1,86 @@
+package fetch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"philoking/internal/config"
+)
+
+func TestIsPublic(t *testing.T) {
+	tests := []struct {
+		addr   string
+		public bool
+	}{
+		{addr: "93.184.216.34", public: true},
+		{addr: "2606:2800:220:1:248:1893:25c8:1946", public: true},
+		{addr: "64:ff9b::5db8:d822", public: true}, // NAT64 of 93.184.216.34
+		{addr: "127.0.0.1"},
+		{addr: "10.1.2.3"},
+		{addr: "172.31.255.255"},
+		{addr: "192.168.1.1"},
+		{addr: "169.254.169.254"}, // Cloud metadata
+		{addr: "100.64.0.1"},      // Carrier-grade NAT
+		{addr: "100.127.255.254"},
+		{addr: "0.0.0.0"},
+		{addr: "0.1.2.3"},
+		{addr: "198.18.0.1"},
+		{addr: "224.0.0.1"},
+		{addr: "255.255.255.255"},
+		{addr: "::"},
+		{addr: "::1"},
+		{addr: "::ffff:127.0.0.1"},
+		{addr: "::ffff:10.0.0.1"},
+		{addr: "64:ff9b::a00:1"}, // NAT64 of 10.0.0.1
+		{addr: "2002:a00:1::1"},  // 6to4 of 10.0.0.1
+		{addr: "fd00::1"},
+		{addr: "fe80::1%eth0"},
+		{addr: "ff02::1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			if got := isPublic(netip.MustParseAddr(tt.addr)); got != tt.public {
+				t.Errorf("isPublic(%s) = %v, want %v", tt.addr, got, tt.public)
+			}
+		})
+	}
+}
+
+func TestFetchRefusesPrivateAddresses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		fmt.Fprint(w, "internal")
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name         string
+		url          string
+		allowPrivate bool
+		wantErr      error
+	}{
+		{name: "loopback address", url: server.URL, wantErr: ErrPrivateAddress},
+		{name: "name resolving to loopback", url: "http://localhost:" + server.URL[len("http://127.0.0.1:"):], wantErr: ErrPrivateAddress},
+		{name: "allowed", url: server.URL, allowPrivate: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := New(config.FetchConfig{AllowPrivate: tt.allowPrivate}, &http.Client{})
+			page, err := f.Fetch(context.Background(), tt.url)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Fetch = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || page.Text != "internal" {
+				t.Fatalf("Fetch = %+v, %v", page, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,256 @@
+// Package fetch reads web pages for agents, so they can discuss what people
+// link to. It extracts the main text of a page, respects robots.txt, bounds
+// the size of what it downloads and caches pages for a while.
+package fetch
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"philoking/internal/config"
+)
+
+const (
+	// maxRedirects bounds the redirects followed for one page
+	maxRedirects = 5
+	// maxCacheEntries bounds the pages and robots.txt files kept in memory
+	maxCacheEntries = 256
+)
+
+// ErrDisallowed is returned for pages robots.txt shuts the fetcher out of
+var ErrDisallowed = errors.New("robots.txt disallows fetching this page")
+
+// Page is the readable content of a web page
+type Page struct {
+	URL       string    `json:"url"` // After redirects
+	Title     string    `json:"title,omitempty"`
+	Text      string    `json:"text"`
+	Truncated bool      `json:"truncated,omitempty"` // The page was longer than the configured limits
+	FetchedAt time.Time `json:"fetched_at"`
+}
+
+// Fetcher reads web pages; it is safe for concurrent use
+type Fetcher struct {
+	cfg    config.FetchConfig
+	client *http.Client
+
+	mu     sync.Mutex
+	pages  map[string]cached[*Page]   // By URL
+	robots map[string]cached[*robots] // By scheme and host
+}
+
+// cached is a cache entry
+type cached[T any] struct {
+	value   T
+	expires time.Time
+}
+
+// New creates a fetcher with the TLS and connection settings of client.
+// Unless private addresses are allowed, the fetcher connects to sites itself
+// instead of through client's proxy, as it can only refuse the addresses it
+// dials.
+func New(cfg config.FetchConfig, client *http.Client) *Fetcher {
+	f := &Fetcher{
+		cfg:    cfg,
+		pages:  make(map[string]cached[*Page]),
+		robots: make(map[string]cached[*robots]),
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if t, ok := client.Transport.(*http.Transport); ok {
+		transport = t.Clone()
+	}
+	if !cfg.AllowPrivate {
+		// Checking the dialed address, rather than resolving the host first,
+		// leaves DNS no room to answer differently when connecting, and
+		// covers redirects too
+		dialer := &net.Dialer{KeepAlive: 30 * time.Second, Control: refusePrivate}
+		transport.DialContext = dialer.DialContext
+		transport.Proxy = nil
+	}
+
+	c := *client
+	c.Transport = transport
+	c.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if len(via) >= maxRedirects {
+			return fmt.Errorf("stopped after %d redirects", maxRedirects)
+		}
+		// Redirects must not lead to pages robots.txt forbids
+		return f.check(req.Context(), req.URL)
+	}
+	f.client = &c
+	return f
+}
+
+// Fetch returns the readable content of the page at rawURL
+func (f *Fetcher) Fetch(ctx context.Context, rawURL string) (*Page, error) {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return nil, fmt.Errorf("invalid url: %w", err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("only http and https links can be fetched")
+	}
+	u.Fragment = ""
+
+	if page, ok := lookup(f, f.pages, u.String()); ok {
+		return page, nil
+	}
+
+	if f.cfg.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, f.cfg.Timeout)
+		defer cancel()
+	}
+	if err := f.check(ctx, u); err != nil {
+		return nil, err
+	}
+
+	resp, err := f.get(ctx, u.String(), "text/html, text/plain;q=0.9, */*;q=0.1")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s answered %s", u.Host, resp.Status)
+	}
+
+	body, truncated, err := f.read(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", u, err)
+	}
+
+	page := &Page{URL: resp.Request.URL.String(), Truncated: truncated, FetchedAt: time.Now()}
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	switch {
+	case mediaType == "text/html" || mediaType == "application/xhtml+xml":
+		if page.Title, page.Text, err = extract(bytes.NewReader(body)); err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", u, err)
+		}
+	case strings.HasPrefix(mediaType, "text/") || mediaType == "":
+		page.Text = strings.TrimSpace(string(body))
+	default:
+		return nil, fmt.Errorf("%s is %s, not a web page", u, mediaType)
+	}
+
+	if f.cfg.MaxChars > 0 && utf8.RuneCountInString(page.Text) > f.cfg.MaxChars {
+		page.Text = strings.TrimSpace(string([]rune(page.Text)[:f.cfg.MaxChars])) + "…"
+		page.Truncated = true
+	}
+
+	store(f, f.pages, u.String(), page)
+	return page, nil
+}
+
+// get sends a GET request as the configured user agent
+func (f *Fetcher) get(ctx context.Context, rawURL, accept string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", f.cfg.UserAgent)
+	req.Header.Set("Accept", accept)
+	return f.client.Do(req)
+}
+
+// read reads a body up to the configured size and reports whether it was cut off
+func (f *Fetcher) read(body io.Reader) ([]byte, bool, error) {
+	if f.cfg.MaxBytes <= 0 {
+		data, err := io.ReadAll(body)
+		return data, false, err
+	}
+	data, err := io.ReadAll(io.LimitReader(body, f.cfg.MaxBytes+1))
+	if int64(len(data)) > f.cfg.MaxBytes {
+		return data[:f.cfg.MaxBytes], true, err
+	}
+	return data, false, err
+}
+
+// check refuses pages robots.txt forbids
+func (f *Fetcher) check(ctx context.Context, u *url.URL) error {
+	rules, err := f.robotsFor(ctx, u)
+	if err != nil {
+		return err
+	}
+	if !rules.allowed(u.EscapedPath()) {
+		return ErrDisallowed
+	}
+	return nil
+}
+
+// robotsFor returns the robots.txt rules of a site. A site without one
+// allows everything; one that fails to answer allows nothing for now.
+func (f *Fetcher) robotsFor(ctx context.Context, u *url.URL) (*robots, error) {
+	site := u.Scheme + "://" + u.Host
+	if rules, ok := lookup(f, f.robots, site); ok {
+		return rules, nil
+	}
+
+	rules := &robots{}
+	resp, err := f.get(ctx, site+"/robots.txt", "text/plain")
+	if err != nil {
+		return nil, fmt.Errorf("cannot read robots.txt of %s: %w", u.Host, err)
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		rules = parseRobots(resp.Body, f.cfg.UserAgent)
+	case resp.StatusCode >= 400 && resp.StatusCode < 500:
+		// No robots.txt
+	default:
+		return nil, fmt.Errorf("cannot read robots.txt of %s: %s", u.Host, resp.Status)
+	}
+
+	store(f, f.robots, site, rules)
+	return rules, nil
+}
+
+// lookup returns an unexpired cache entry
+func lookup[T any](f *Fetcher, cache map[string]cached[T], key string) (T, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	entry, ok := cache[key]
+	if !ok || time.Now().After(entry.expires) {
+		var zero T
+		return zero, false
+	}
+	return entry.value, true
+}
+
+// store caches a value for the configured TTL, making room if the cache is full
+func store[T any](f *Fetcher, cache map[string]cached[T], key string, value T) {
+	if f.cfg.CacheTTL <= 0 {
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	now := time.Now()
+	if len(cache) >= maxCacheEntries {
+		for k, entry := range cache {
+			if now.After(entry.expires) {
+				delete(cache, k)
+			}
+		}
+	}
+	if len(cache) >= maxCacheEntries {
+		for k := range cache {
+			delete(cache, k) // Any entry will do
+			break
+		}
+	}
+	cache[key] = cached[T]{value: value, expires: now.Add(f.cfg.CacheTTL)}
+}
@@ -0,0 +1,204 @@
+package fetch
+
+import (
+	"io"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// skipped are elements that never hold the main text of a page
+var skipped = map[atom.Atom]bool{
+	atom.Script: true, atom.Style: true, atom.Noscript: true, atom.Template: true,
+	atom.Nav: true, atom.Header: true, atom.Footer: true, atom.Aside: true,
+	atom.Form: true, atom.Button: true, atom.Iframe: true, atom.Svg: true, atom.Select: true,
+}
+
+// blocks are elements whose text is extracted as a paragraph of its own
+var blocks = map[atom.Atom]bool{
+	atom.P: true, atom.H1: true, atom.H2: true, atom.H3: true, atom.H4: true, atom.H5: true, atom.H6: true,
+	atom.Li: true, atom.Blockquote: true, atom.Pre: true, atom.Dt: true, atom.Dd: true,
+	atom.Figcaption: true, atom.Td: true, atom.Th: true,
+}
+
+// boilerplate are class and id fragments of page furniture around the main text
+var boilerplate = []string{"sidebar", "comment", "footer", "menu", "navbar", "cookie", "banner", "share", "related", "advert", "promo", "newsletter"}
+
+// extract returns the title and main text of an HTML page, leaving out
+// navigation, scripts and other page furniture, in the spirit of readability
+func extract(r io.Reader) (title, text string, err error) {
+	doc, err := html.Parse(r)
+	if err != nil {
+		return "", "", err
+	}
+
+	title = pageTitle(doc)
+	root := mainContent(doc)
+	if root == nil {
+		return title, "", nil
+	}
+
+	var paragraphs []string
+	collect(root, &paragraphs)
+	return title, strings.Join(paragraphs, "\n\n"), nil
+}
+
+// pageTitle prefers the og:title of a page over its <title>
+func pageTitle(doc *html.Node) string {
+	var title, ogTitle string
+	walk(doc, func(n *html.Node) bool {
+		switch {
+		case n.DataAtom == atom.Title && title == "":
+			title = collapse(textOf(n))
+		case n.DataAtom == atom.Meta && attr(n, "property") == "og:title":
+			ogTitle = collapse(attr(n, "content"))
+		}
+		return true
+	})
+	if ogTitle != "" {
+		return ogTitle
+	}
+	return title
+}
+
+// mainContent picks the element holding the page's main text: its <article>
+// or <main>, or else the element with the most paragraph text
+func mainContent(doc *html.Node) *html.Node {
+	var article, main, body, best *html.Node
+	bestScore := 0
+	walk(doc, func(n *html.Node) bool {
+		if n.Type != html.ElementNode {
+			return true
+		}
+		if skipped[n.DataAtom] || isBoilerplate(n) {
+			return false
+		}
+		switch n.DataAtom {
+		case atom.Article:
+			if article == nil {
+				article = n
+			}
+		case atom.Main:
+			if main == nil {
+				main = n
+			}
+		case atom.Body:
+			body = n
+		}
+		if score := paragraphText(n); score > bestScore {
+			best, bestScore = n, score
+		}
+		return true
+	})
+
+	for _, candidate := range []*html.Node{article, main, best, body} {
+		if candidate != nil {
+			return candidate
+		}
+	}
+	return doc
+}
+
+// paragraphText scores an element by the text of its direct <p> children
+func paragraphText(n *html.Node) int {
+	score := 0
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.DataAtom == atom.P {
+			score += len(collapse(textOf(c)))
+		}
+	}
+	return score
+}
+
+// collect appends the text of the blocks under n
+func collect(n *html.Node, paragraphs *[]string) {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type != html.ElementNode {
+			continue
+		}
+		if skipped[c.DataAtom] || isBoilerplate(c) {
+			continue
+		}
+		if blocks[c.DataAtom] && !hasBlocks(c) {
+			if text := collapse(textOf(c)); text != "" {
+				*paragraphs = append(*paragraphs, text)
+			}
+			continue
+		}
+		collect(c, paragraphs)
+	}
+}
+
+// hasBlocks reports whether an element contains other blocks, like a list item with a nested list
+func hasBlocks(n *html.Node) bool {
+	found := false
+	for c := n.FirstChild; c != nil && !found; c = c.NextSibling {
+		walk(c, func(d *html.Node) bool {
+			found = found || blocks[d.DataAtom]
+			return !found
+		})
+	}
+	return found
+}
+
+// isBoilerplate reports whether an element's class or id marks it as page furniture
+func isBoilerplate(n *html.Node) bool {
+	switch n.DataAtom {
+	case atom.Html, atom.Body, atom.Main, atom.Article:
+		return false // Themes put all sorts of classes on these
+	}
+	if attr(n, "aria-hidden") == "true" || attr(n, "role") == "navigation" {
+		return true
+	}
+	names := strings.ToLower(attr(n, "class") + " " + attr(n, "id"))
+	for _, fragment := range boilerplate {
+		if strings.Contains(names, fragment) {
+			return true
+		}
+	}
+	return false
+}
+
+// textOf returns the text under a node, skipping scripts and styles
+func textOf(n *html.Node) string {
+	var b strings.Builder
+	walk(n, func(d *html.Node) bool {
+		if d.Type == html.ElementNode && skipped[d.DataAtom] {
+			return false
+		}
+		switch {
+		case d.Type == html.TextNode:
+			b.WriteString(d.Data)
+		case d.DataAtom == atom.Br:
+			b.WriteString(" ")
+		}
+		return true
+	})
+	return b.String()
+}
+
+// walk visits n and its descendants depth first; visit returns false to skip a node's children
+func walk(n *html.Node, visit func(*html.Node) bool) {
+	if !visit(n) {
+		return
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		walk(c, visit)
+	}
+}
+
+// attr returns an attribute of an element, or ""
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// collapse joins the words of a text with single spaces
+func collapse(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
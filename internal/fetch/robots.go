@@ -0,0 +1,113 @@
+package fetch
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// robots holds the rules of a robots.txt that apply to one user agent
+type robots struct {
+	rules []robotsRule
+}
+
+// robotsRule allows or disallows paths starting with a prefix
+type robotsRule struct {
+	prefix string
+	allow  bool
+}
+
+// parseRobots reads the rules of a robots.txt for a user agent. The group
+// naming the agent applies, or else the "*" group.
+func parseRobots(r io.Reader, userAgent string) *robots {
+	agent := strings.ToLower(userAgent)
+	if i := strings.IndexAny(agent, "/ "); i > 0 {
+		agent = agent[:i] // "philoking/1.0" is "philoking" in robots.txt
+	}
+
+	var specific, wildcard []robotsRule
+	var groupAgents []string
+	inRules := false // Rules ended the current group's User-agent lines
+	matched := false
+
+	scanner := bufio.NewScanner(io.LimitReader(r, 512*1024))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		key, value, found := strings.Cut(line, ":")
+		if !found {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "user-agent":
+			if inRules {
+				groupAgents, inRules = nil, false
+			}
+			groupAgents = append(groupAgents, strings.ToLower(value))
+		case "allow", "disallow":
+			inRules = true
+			if value == "" {
+				continue // "Disallow:" allows everything
+			}
+			rule := robotsRule{prefix: value, allow: key == "allow"}
+			for _, name := range groupAgents {
+				switch {
+				case name == agent:
+					specific = append(specific, rule)
+					matched = true
+				case name == "*":
+					wildcard = append(wildcard, rule)
+				}
+			}
+		}
+	}
+
+	if matched {
+		return &robots{rules: specific}
+	}
+	return &robots{rules: wildcard}
+}
+
+// allowed reports whether a path may be fetched; the longest matching rule
+// wins and Allow wins ties
+func (r *robots) allowed(path string) bool {
+	if path == "" {
+		path = "/"
+	}
+	best, allow := -1, true
+	for _, rule := range r.rules {
+		if !matchesRobotsPath(rule.prefix, path) {
+			continue
+		}
+		if len(rule.prefix) > best || (len(rule.prefix) == best && rule.allow) {
+			best, allow = len(rule.prefix), rule.allow
+		}
+	}
+	return allow
+}
+
+// matchesRobotsPath matches a path against a robots.txt pattern with "*"
+// wildcards and an optional "$" end anchor
+func matchesRobotsPath(pattern, path string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	pattern = strings.TrimSuffix(pattern, "$")
+
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	rest := path[len(parts[0]):]
+	for _, part := range parts[1:] {
+		i := strings.Index(rest, part)
+		if i < 0 {
+			return false
+		}
+		rest = rest[i+len(part):]
+	}
+	return !anchored || rest == "" || strings.HasSuffix(pattern, "*")
+}
@@ -0,0 +1,468 @@
+// Package graphql executes GraphQL queries and subscriptions against a schema
+// of resolvers built in Go. It supports the commonly used subset of the
+// language: operations with variables, aliases, arguments, fragments, inline
+// fragments, @include and @skip. Types of variables and arguments are not
+// checked and there is no introspection.
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// ResolveFunc returns the value of a field of source. Fields of an object
+// type return a struct, map, pointer or slice of them; the fields selected
+// from it are resolved in turn.
+type ResolveFunc func(ctx context.Context, source interface{}, args Args) (interface{}, error)
+
+// Field is a field of an object type
+type Field struct {
+	// Type is the object type of the field's value; nil for scalars
+	Type *Object
+	// Resolve returns the field's value; nil reads the struct field or map
+	// entry named like the field in its JSON encoding
+	Resolve ResolveFunc
+}
+
+// Object is an object type
+type Object struct {
+	Name   string
+	Fields map[string]*Field
+}
+
+// Schema holds the root types. The resolvers of subscription fields return a
+// channel of events (<-chan interface{}), each executed like a query result.
+type Schema struct {
+	Query        *Object
+	Subscription *Object // Nil when there are no subscriptions
+}
+
+// Request is a GraphQL request as sent over HTTP or in a subscribe message
+type Request struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName,omitempty"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+}
+
+// Response is the result of a query or of a subscription event
+type Response struct {
+	Data   map[string]interface{} `json:"data"`
+	Errors []*Error               `json:"errors,omitempty"`
+}
+
+// Error is a request error or an error resolving a field
+type Error struct {
+	Message string        `json:"message"`
+	Path    []interface{} `json:"path,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Args are the arguments of a field, variables substituted
+type Args map[string]interface{}
+
+// String returns a string argument, or "" when it is not set
+func (a Args) String(name string) string {
+	switch value := a[name].(type) {
+	case string:
+		return value
+	case enumValue:
+		return string(value)
+	}
+	return ""
+}
+
+// Int returns an integer argument, or fallback when it is not set
+func (a Args) Int(name string, fallback int) int {
+	switch value := a[name].(type) {
+	case int64:
+		return int(value)
+	case float64:
+		return int(value)
+	case json.Number:
+		if n, err := value.Int64(); err == nil {
+			return int(n)
+		}
+	}
+	return fallback
+}
+
+// Has reports whether an argument is set and not null
+func (a Args) Has(name string) bool {
+	return a[name] != nil
+}
+
+// Strings returns a list of strings argument; a single string counts as a list of one
+func (a Args) Strings(name string) []string {
+	switch value := a[name].(type) {
+	case []interface{}:
+		result := make([]string, 0, len(value))
+		for _, item := range value {
+			if s, ok := item.(string); ok {
+				result = append(result, s)
+			} else if e, ok := item.(enumValue); ok {
+				result = append(result, string(e))
+			}
+		}
+		return result
+	case string:
+		return []string{value}
+	case enumValue:
+		return []string{string(value)}
+	}
+	return nil
+}
+
+// Execute runs a query; subscriptions are run with Subscribe
+func (s *Schema) Execute(ctx context.Context, req Request) *Response {
+	op, exec, err := s.prepare(req)
+	if err != nil {
+		return &Response{Errors: []*Error{{Message: err.Error()}}}
+	}
+	if op.kind != "query" {
+		return &Response{Errors: []*Error{{Message: op.kind + " operations are not supported here"}}}
+	}
+	data := exec.selectionSet(ctx, s.Query, nil, op.selections, nil)
+	return &Response{Data: data, Errors: exec.errors}
+}
+
+// IsSubscription reports whether the operation a request runs is a subscription
+func (s *Schema) IsSubscription(req Request) bool {
+	op, _, err := s.prepare(req)
+	return err == nil && op.kind == "subscription"
+}
+
+// Subscribe runs a subscription. Each event of its source is sent as a
+// response until ctx is done or the source ends, then the channel is closed.
+func (s *Schema) Subscribe(ctx context.Context, req Request) (<-chan *Response, error) {
+	op, exec, err := s.prepare(req)
+	if err != nil {
+		return nil, err
+	}
+	if op.kind != "subscription" || s.Subscription == nil {
+		return nil, fmt.Errorf("%s operations cannot be subscribed to", op.kind)
+	}
+
+	fields := exec.collect(op.selections, nil)
+	if len(fields) != 1 {
+		return nil, fmt.Errorf("a subscription must select exactly one field")
+	}
+	sel := fields[0]
+	field, ok := s.Subscription.Fields[sel.name]
+	if !ok {
+		return nil, fmt.Errorf("cannot query field %q on type %q", sel.name, s.Subscription.Name)
+	}
+	source, err := field.Resolve(ctx, nil, exec.arguments(sel.arguments))
+	if err != nil {
+		return nil, err
+	}
+	events, ok := source.(<-chan interface{})
+	if !ok {
+		return nil, fmt.Errorf("field %q is not a subscription", sel.name)
+	}
+
+	responses := make(chan *Response)
+	go func() {
+		defer close(responses)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-events:
+				if !ok {
+					return
+				}
+				eventExec := &executor{fragments: exec.fragments, variables: exec.variables}
+				key := sel.responseKey()
+				value := eventExec.complete(ctx, field.Type, event, sel.selections, []interface{}{key})
+				response := &Response{Data: map[string]interface{}{key: value}, Errors: eventExec.errors}
+				select {
+				case responses <- response:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return responses, nil
+}
+
+// prepare parses a request and picks the operation to run
+func (s *Schema) prepare(req Request) (*operation, *executor, error) {
+	doc, err := parse(req.Query)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var op *operation
+	switch {
+	case req.OperationName != "":
+		for _, candidate := range doc.operations {
+			if candidate.name == req.OperationName {
+				op = candidate
+			}
+		}
+		if op == nil {
+			return nil, nil, fmt.Errorf("unknown operation %q", req.OperationName)
+		}
+	case len(doc.operations) == 1:
+		op = doc.operations[0]
+	default:
+		return nil, nil, fmt.Errorf("the document has several operations; operationName must pick one")
+	}
+
+	variables := make(map[string]interface{})
+	for _, definition := range op.variables {
+		if value, ok := req.Variables[definition.name]; ok {
+			variables[definition.name] = value
+		} else {
+			variables[definition.name] = definition.defaultValue
+		}
+	}
+	return op, &executor{fragments: doc.fragments, variables: variables}, nil
+}
+
+// executor resolves the selections of an operation and gathers field errors
+type executor struct {
+	fragments map[string]*fragment
+	variables map[string]interface{}
+	errors    []*Error
+}
+
+// selectionSet resolves the selected fields of an object
+func (e *executor) selectionSet(ctx context.Context, object *Object, source interface{}, selections []*selection, path []interface{}) map[string]interface{} {
+	// Fragments can nest a query deeper than the parser allows a document to
+	if fieldDepth(path) >= maxDepth {
+		e.fail(path, fmt.Errorf("the query nests deeper than %d levels", maxDepth))
+		return nil
+	}
+
+	result := make(map[string]interface{})
+	for _, sel := range e.collect(selections, nil) {
+		key := sel.responseKey()
+		fieldPath := append(append([]interface{}(nil), path...), key)
+		if sel.name == "__typename" {
+			result[key] = object.Name
+			continue
+		}
+
+		field, ok := object.Fields[sel.name]
+		if !ok {
+			e.fail(fieldPath, fmt.Errorf("cannot query field %q on type %q", sel.name, object.Name))
+			result[key] = nil
+			continue
+		}
+		if field.Type == nil && len(sel.selections) > 0 {
+			e.fail(fieldPath, fmt.Errorf("field %q of type %q has no fields to select", sel.name, object.Name))
+			result[key] = nil
+			continue
+		}
+		if field.Type != nil && len(sel.selections) == 0 {
+			e.fail(fieldPath, fmt.Errorf("field %q of type %q must have a selection of subfields", sel.name, object.Name))
+			result[key] = nil
+			continue
+		}
+
+		var value interface{}
+		var err error
+		if field.Resolve != nil {
+			value, err = field.Resolve(ctx, source, e.arguments(sel.arguments))
+		} else {
+			value = defaultResolve(source, sel.name)
+		}
+		if err != nil {
+			e.fail(fieldPath, err)
+			result[key] = nil
+			continue
+		}
+		result[key] = e.complete(ctx, field.Type, value, sel.selections, fieldPath)
+	}
+	return result
+}
+
+// complete resolves the selections of an object value, or of each object in a
+// list; scalars are returned as they are
+func (e *executor) complete(ctx context.Context, object *Object, value interface{}, selections []*selection, path []interface{}) interface{} {
+	if object == nil || value == nil {
+		return value
+	}
+
+	v := reflect.ValueOf(value)
+	if v.Kind() == reflect.Slice {
+		if v.IsNil() {
+			return []interface{}{}
+		}
+		items := make([]interface{}, v.Len())
+		for i := range items {
+			items[i] = e.complete(ctx, object, v.Index(i).Interface(), selections, append(append([]interface{}(nil), path...), i))
+		}
+		return items
+	}
+	if isNil(value) {
+		return nil
+	}
+	return e.selectionSet(ctx, object, value, selections, path)
+}
+
+// collect flattens fragments into the fields they select and drops the
+// selections that @skip or @include leave out
+func (e *executor) collect(selections []*selection, visited map[string]bool) []*selection {
+	var fields []*selection
+	for _, sel := range selections {
+		if !e.included(sel) {
+			continue
+		}
+		switch {
+		case sel.spread != "":
+			frag, ok := e.fragments[sel.spread]
+			if !ok || visited[sel.spread] {
+				continue
+			}
+			if visited == nil {
+				visited = make(map[string]bool)
+			}
+			visited[sel.spread] = true
+			fields = append(fields, e.collect(frag.selections, visited)...)
+		case sel.name == "":
+			fields = append(fields, e.collect(sel.inline, visited)...)
+		default:
+			fields = append(fields, sel)
+		}
+	}
+	return merge(fields)
+}
+
+// merge combines the selections of fields requested under the same key
+func merge(fields []*selection) []*selection {
+	var merged []*selection
+	byKey := make(map[string]*selection)
+	for _, sel := range fields {
+		if first, ok := byKey[sel.responseKey()]; ok {
+			first.selections = append(append([]*selection(nil), first.selections...), sel.selections...)
+			continue
+		}
+		copied := *sel
+		byKey[sel.responseKey()] = &copied
+		merged = append(merged, &copied)
+	}
+	return merged
+}
+
+// included applies the @skip and @include directives of a selection
+func (e *executor) included(sel *selection) bool {
+	for _, d := range sel.directives {
+		condition, _ := e.value(d.arguments["if"]).(bool)
+		switch d.name {
+		case "skip":
+			if condition {
+				return false
+			}
+		case "include":
+			if !condition {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// arguments substitutes the variables in the arguments of a field
+func (e *executor) arguments(arguments map[string]interface{}) Args {
+	args := make(Args, len(arguments))
+	for name, value := range arguments {
+		args[name] = e.value(value)
+	}
+	return args
+}
+
+// value substitutes the variables in an argument value
+func (e *executor) value(value interface{}) interface{} {
+	switch value := value.(type) {
+	case variable:
+		return e.variables[string(value)]
+	case []interface{}:
+		list := make([]interface{}, len(value))
+		for i, item := range value {
+			list[i] = e.value(item)
+		}
+		return list
+	case map[string]interface{}:
+		object := make(map[string]interface{}, len(value))
+		for name, item := range value {
+			object[name] = e.value(item)
+		}
+		return object
+	}
+	return value
+}
+
+// fieldDepth returns the number of fields in a path, leaving out list indexes
+func fieldDepth(path []interface{}) int {
+	depth := 0
+	for _, key := range path {
+		if _, ok := key.(string); ok {
+			depth++
+		}
+	}
+	return depth
+}
+
+// fail records an error resolving the field at path
+func (e *executor) fail(path []interface{}, err error) {
+	e.errors = append(e.errors, &Error{Message: err.Error(), Path: path})
+}
+
+// defaultResolve reads the struct field or map entry of source named like
+// the field in its JSON encoding
+func defaultResolve(source interface{}, name string) interface{} {
+	if isNil(source) {
+		return nil
+	}
+	v := reflect.ValueOf(source)
+	for v.Kind() == reflect.Pointer {
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return nil
+		}
+		entry := v.MapIndex(reflect.ValueOf(name).Convert(v.Type().Key()))
+		if !entry.IsValid() {
+			return nil
+		}
+		return entry.Interface()
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			tag := strings.Split(field.Tag.Get("json"), ",")[0]
+			if tag == name || tag == "" && field.Name == name {
+				return v.Field(i).Interface()
+			}
+		}
+	}
+	return nil
+}
+
+// isNil reports whether a value is nil or a nil pointer, map or slice
+func isNil(value interface{}) bool {
+	if value == nil {
+		return true
+	}
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Map, reflect.Slice, reflect.Interface, reflect.Chan, reflect.Func:
+		return v.IsNil()
+	}
+	return false
+}
@@ -0,0 +1,268 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+type person struct {
+	Name    string    `json:"name"`
+	Age     int       `json:"age"`
+	Friends []*person `json:"friends"`
+}
+
+// testSchema serves a small social graph; socrates and plato are each
+// other's friends, so queries can nest as deep as they like
+func testSchema(events <-chan interface{}) *Schema {
+	socrates := &person{Name: "socrates", Age: 70}
+	plato := &person{Name: "plato", Age: 80, Friends: []*person{socrates}}
+	socrates.Friends = []*person{plato}
+	people := map[string]*person{"socrates": socrates, "plato": plato}
+
+	personType := &Object{Name: "Person", Fields: map[string]*Field{
+		"name": {},
+		"age":  {},
+		"mood": {Resolve: func(ctx context.Context, source interface{}, args Args) (interface{}, error) {
+			return nil, fmt.Errorf("%s is unreadable", source.(*person).Name)
+		}},
+	}}
+	personType.Fields["friends"] = &Field{Type: personType}
+
+	return &Schema{
+		Query: &Object{Name: "Query", Fields: map[string]*Field{
+			"person": {Type: personType, Resolve: func(ctx context.Context, source interface{}, args Args) (interface{}, error) {
+				if p, ok := people[args.String("name")]; ok {
+					return p, nil
+				}
+				return nil, nil
+			}},
+			"people": {Type: personType, Resolve: func(ctx context.Context, source interface{}, args Args) (interface{}, error) {
+				var list []*person
+				for _, name := range args.Strings("names") {
+					list = append(list, people[name])
+				}
+				return list, nil
+			}},
+			"echo": {Resolve: func(ctx context.Context, source interface{}, args Args) (interface{}, error) {
+				return map[string]interface{}(args), nil
+			}},
+			"limit": {Resolve: func(ctx context.Context, source interface{}, args Args) (interface{}, error) {
+				return args.Int("n", 10), nil
+			}},
+		}},
+		Subscription: &Object{Name: "Subscription", Fields: map[string]*Field{
+			"personChanged": {Type: personType, Resolve: func(ctx context.Context, source interface{}, args Args) (interface{}, error) {
+				return events, nil
+			}},
+			"broken": {Resolve: func(ctx context.Context, source interface{}, args Args) (interface{}, error) {
+				return nil, errors.New("no events today")
+			}},
+		}},
+	}
+}
+
+func TestExecute(t *testing.T) {
+	tests := []struct {
+		name string
+		req  Request
+		want string
+	}{
+		{
+			name: "fields, aliases and typename",
+			req:  Request{Query: `{ person(name: "plato") { __typename name years: age friends { name } } }`},
+			want: `{"data":{"person":{"__typename":"Person","friends":[{"name":"socrates"}],"name":"plato","years":80}}}`,
+		},
+		{
+			name: "missing object",
+			req:  Request{Query: `{ person(name: "xenophon") { name } }`},
+			want: `{"data":{"person":null}}`,
+		},
+		{
+			name: "lists and list arguments",
+			req:  Request{Query: `{ people(names: ["plato", "socrates"]) { name } one: people(names: "plato") { age } }`},
+			want: `{"data":{"one":[{"age":80}],"people":[{"name":"plato"},{"name":"socrates"}]}}`,
+		},
+		{
+			name: "variables and defaults",
+			req: Request{
+				Query:     `query($name: String, $n: Int = 3) { person(name: $name) { name } limit(n: $n) echo(list: [$name], obj: {n: $n}, e: DESC) }`,
+				Variables: map[string]interface{}{"name": "socrates"},
+			},
+			want: `{"data":{"echo":{"e":"DESC","list":["socrates"],"obj":{"n":3}},"limit":3,"person":{"name":"socrates"}}}`,
+		},
+		{
+			name: "fragments and field merging",
+			req:  Request{Query: `{ person(name: "plato") { ...Names ... on Person { friends { age } } } } fragment Names on Person { name friends { name } }`},
+			want: `{"data":{"person":{"friends":[{"age":70,"name":"socrates"}],"name":"plato"}}}`,
+		},
+		{
+			name: "skip and include",
+			req: Request{
+				Query:     `query($yes: Boolean) { person(name: "plato") { name @skip(if: $yes) age @include(if: $yes) ... on Person @include(if: false) { friends { name } } } }`,
+				Variables: map[string]interface{}{"yes": true},
+			},
+			want: `{"data":{"person":{"age":80}}}`,
+		},
+		{
+			name: "operation name",
+			req:  Request{Query: `query A { limit } query B { limit(n: 2) }`, OperationName: "B"},
+			want: `{"data":{"limit":2}}`,
+		},
+		{
+			name: "resolver error keeps the other fields",
+			req:  Request{Query: `{ person(name: "plato") { name friends { mood } } }`},
+			want: `{"data":{"person":{"friends":[{"mood":null}],"name":"plato"}},"errors":[{"message":"socrates is unreadable","path":["person","friends",0,"mood"]}]}`,
+		},
+		{
+			name: "unknown field",
+			req:  Request{Query: `{ person(name: "plato") { height } }`},
+			want: `{"data":{"person":{"height":null}},"errors":[{"message":"cannot query field \"height\" on type \"Person\"","path":["person","height"]}]}`,
+		},
+		{
+			name: "selection on a scalar",
+			req:  Request{Query: `{ limit { n } }`},
+			want: `{"data":{"limit":null},"errors":[{"message":"field \"limit\" of type \"Query\" has no fields to select","path":["limit"]}]}`,
+		},
+		{
+			name: "object without a selection",
+			req:  Request{Query: `{ person(name: "plato") }`},
+			want: `{"data":{"person":null},"errors":[{"message":"field \"person\" of type \"Query\" must have a selection of subfields","path":["person"]}]}`,
+		},
+		{
+			name: "syntax error",
+			req:  Request{Query: `{ person(name: "plato" { name } }`},
+			want: `{"data":null,"errors":[{"message":"syntax error: unexpected \"{\" at 23"}]}`,
+		},
+		{
+			name: "several operations without a name",
+			req:  Request{Query: `query A { limit } query B { limit }`},
+			want: `{"data":null,"errors":[{"message":"the document has several operations; operationName must pick one"}]}`,
+		},
+		{
+			name: "unknown operation name",
+			req:  Request{Query: `query A { limit }`, OperationName: "C"},
+			want: `{"data":null,"errors":[{"message":"unknown operation \"C\""}]}`,
+		},
+		{
+			name: "subscription",
+			req:  Request{Query: `subscription { personChanged { name } }`},
+			want: `{"data":null,"errors":[{"message":"subscription operations are not supported here"}]}`,
+		},
+	}
+	schema := testSchema(nil)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := json.Marshal(schema.Execute(context.Background(), tt.req))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("response\n got %s\nwant %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExecuteDeepFragments(t *testing.T) {
+	// Each fragment stays within the parser's limit, but spread into each
+	// other they nest far deeper
+	const levels = maxDepth - 2
+	var query strings.Builder
+	query.WriteString(`{ person(name: "plato") { ...F0 } }`)
+	for i := 0; i < 4; i++ {
+		fmt.Fprintf(&query, " fragment F%d on Person { %s", i, strings.Repeat("friends { ", levels))
+		if i < 3 {
+			fmt.Fprintf(&query, "...F%d", i+1)
+		} else {
+			query.WriteString("name")
+		}
+		query.WriteString(strings.Repeat(" }", levels) + " }")
+	}
+
+	resp := testSchema(nil).Execute(context.Background(), Request{Query: query.String()})
+	if len(resp.Errors) != 1 || !strings.Contains(resp.Errors[0].Message, "nests deeper") {
+		t.Fatalf("errors = %+v, want one nesting error", resp.Errors)
+	}
+	if depth := fieldDepth(resp.Errors[0].Path); depth != maxDepth {
+		t.Errorf("error at depth %d, want %d", depth, maxDepth)
+	}
+}
+
+func TestIsSubscription(t *testing.T) {
+	schema := testSchema(nil)
+	tests := []struct {
+		req  Request
+		want bool
+	}{
+		{req: Request{Query: `subscription { personChanged { name } }`}, want: true},
+		{req: Request{Query: `query { limit }`}},
+		{req: Request{Query: `{ limit } subscription S { broken }`, OperationName: "S"}, want: true},
+		{req: Request{Query: `subscription {`}},
+	}
+	for _, tt := range tests {
+		if got := schema.IsSubscription(tt.req); got != tt.want {
+			t.Errorf("IsSubscription(%q) = %v, want %v", tt.req.Query, got, tt.want)
+		}
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	events := make(chan interface{})
+	schema := testSchema(events)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	responses, err := schema.Subscribe(ctx, Request{Query: `subscription { changed: personChanged { name friends { name } } }`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		events <- &person{Name: "plato", Friends: []*person{{Name: "socrates"}}}
+		events <- map[string]interface{}{"name": "aristotle"}
+		close(events)
+	}()
+
+	want := []string{
+		`{"data":{"changed":{"friends":[{"name":"socrates"}],"name":"plato"}}}`,
+		`{"data":{"changed":{"friends":null,"name":"aristotle"}}}`,
+	}
+	var got []string
+	for resp := range responses {
+		encoded, err := json.Marshal(resp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, string(encoded))
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("responses\n got %s\nwant %s", got, want)
+	}
+}
+
+func TestSubscribeErrors(t *testing.T) {
+	schema := testSchema(nil)
+	tests := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{name: "query", query: `{ limit }`, want: "query operations cannot be subscribed to"},
+		{name: "two fields", query: `subscription { personChanged { name } broken }`, want: "exactly one field"},
+		{name: "unknown field", query: `subscription { nothing }`, want: `cannot query field "nothing"`},
+		{name: "resolver error", query: `subscription { broken }`, want: "no events today"},
+		{name: "syntax error", query: `subscription { personChanged { name }`, want: "syntax error"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := schema.Subscribe(context.Background(), Request{Query: tt.query})
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Subscribe = %v, want an error containing %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,709 @@
+package graphql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// document is a parsed GraphQL request document
+type document struct {
+	operations []*operation
+	fragments  map[string]*fragment
+}
+
+// operation is a query, mutation or subscription of a document
+type operation struct {
+	kind       string // "query", "mutation" or "subscription"
+	name       string
+	variables  []variableDefinition
+	selections []*selection
+}
+
+// variableDefinition declares a variable of an operation; its type is not checked
+type variableDefinition struct {
+	name         string
+	defaultValue interface{}
+}
+
+// fragment is a named set of selections; type conditions are not checked
+type fragment struct {
+	name       string
+	selections []*selection
+}
+
+// selection is a field, a fragment spread or an inline fragment
+type selection struct {
+	alias      string
+	name       string
+	arguments  map[string]interface{}
+	directives []directive
+	selections []*selection
+	spread     string       // Name of a spread fragment
+	inline     []*selection // Selections of an inline fragment, which has neither name nor spread
+}
+
+// directive is an @include or @skip annotation of a selection
+type directive struct {
+	name      string
+	arguments map[string]interface{}
+}
+
+// variable refers to a variable in an argument value
+type variable string
+
+// enumValue is an unquoted enum value in an argument
+type enumValue string
+
+// responseKey names a field in the response: its alias, or else its name
+func (s *selection) responseKey() string {
+	if s.alias != "" {
+		return s.alias
+	}
+	return s.name
+}
+
+// maxDepth bounds how deeply selection sets, values and types may nest, so a
+// hostile document can't exhaust the stack
+const maxDepth = 32
+
+// token kinds of the lexer
+const (
+	tokenEOF = iota
+	tokenPunctuator
+	tokenName
+	tokenInt
+	tokenFloat
+	tokenString
+)
+
+type token struct {
+	kind  int
+	value string
+	pos   int
+}
+
+// parser reads a document token by token
+type parser struct {
+	source string
+	pos    int
+	token  token
+	depth  int // Of the selection sets, values and types being read
+}
+
+// parse reads a GraphQL document
+func parse(source string) (*document, error) {
+	p := &parser{source: source}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+
+	doc := &document{fragments: make(map[string]*fragment)}
+	for p.token.kind != tokenEOF {
+		switch {
+		case p.peek(tokenPunctuator, "{"):
+			selections, err := p.parseSelectionSet()
+			if err != nil {
+				return nil, err
+			}
+			doc.operations = append(doc.operations, &operation{kind: "query", selections: selections})
+		case p.peek(tokenName, "query"), p.peek(tokenName, "mutation"), p.peek(tokenName, "subscription"):
+			op, err := p.parseOperation()
+			if err != nil {
+				return nil, err
+			}
+			doc.operations = append(doc.operations, op)
+		case p.peek(tokenName, "fragment"):
+			frag, err := p.parseFragment()
+			if err != nil {
+				return nil, err
+			}
+			doc.fragments[frag.name] = frag
+		default:
+			return nil, p.unexpected()
+		}
+	}
+	if len(doc.operations) == 0 {
+		return nil, fmt.Errorf("the document has no operation")
+	}
+	if err := doc.checkSpreads(); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// checkSpreads makes sure every spread fragment exists and that no fragment
+// spreads itself, directly or through others
+func (d *document) checkSpreads() error {
+	const (
+		visiting = 1
+		done     = 2
+	)
+	state := make(map[string]int)
+	var visit func(selections []*selection) error
+	visit = func(selections []*selection) error {
+		for _, sel := range selections {
+			if sel.spread == "" {
+				if err := visit(sel.selections); err != nil {
+					return err
+				}
+				if err := visit(sel.inline); err != nil {
+					return err
+				}
+				continue
+			}
+			frag, ok := d.fragments[sel.spread]
+			if !ok {
+				return fmt.Errorf("unknown fragment %q", sel.spread)
+			}
+			switch state[frag.name] {
+			case visiting:
+				return fmt.Errorf("fragment %q spreads itself", frag.name)
+			case done:
+				continue
+			}
+			state[frag.name] = visiting
+			if err := visit(frag.selections); err != nil {
+				return err
+			}
+			state[frag.name] = done
+		}
+		return nil
+	}
+
+	for _, op := range d.operations {
+		if err := visit(op.selections); err != nil {
+			return err
+		}
+	}
+	for _, frag := range d.fragments {
+		if err := visit([]*selection{{spread: frag.name}}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// enter goes one level deeper into the document; the caller leaves with p.depth--
+func (p *parser) enter() error {
+	p.depth++
+	if p.depth > maxDepth {
+		return fmt.Errorf("syntax error: the document nests deeper than %d levels", maxDepth)
+	}
+	return nil
+}
+
+// parseOperation reads a named operation with its variable definitions
+func (p *parser) parseOperation() (*operation, error) {
+	op := &operation{kind: p.token.value}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	if p.token.kind == tokenName {
+		op.name = p.token.value
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.peek(tokenPunctuator, "(") {
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		for !p.peek(tokenPunctuator, ")") {
+			definition, err := p.parseVariableDefinition()
+			if err != nil {
+				return nil, err
+			}
+			op.variables = append(op.variables, definition)
+		}
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := p.parseDirectives(); err != nil {
+		return nil, err
+	}
+
+	selections, err := p.parseSelectionSet()
+	if err != nil {
+		return nil, err
+	}
+	op.selections = selections
+	return op, nil
+}
+
+// parseVariableDefinition reads "$name: Type = default"
+func (p *parser) parseVariableDefinition() (variableDefinition, error) {
+	var definition variableDefinition
+	if err := p.expect(tokenPunctuator, "$"); err != nil {
+		return definition, err
+	}
+	name, err := p.expectName()
+	if err != nil {
+		return definition, err
+	}
+	definition.name = name
+	if err := p.expect(tokenPunctuator, ":"); err != nil {
+		return definition, err
+	}
+	if err := p.skipType(); err != nil {
+		return definition, err
+	}
+
+	if p.peek(tokenPunctuator, "=") {
+		if err := p.next(); err != nil {
+			return definition, err
+		}
+		if definition.defaultValue, err = p.parseValue(true); err != nil {
+			return definition, err
+		}
+	}
+	return definition, nil
+}
+
+// skipType reads a type reference such as "[String!]!"
+func (p *parser) skipType() error {
+	if err := p.enter(); err != nil {
+		return err
+	}
+	defer func() { p.depth-- }()
+
+	if p.peek(tokenPunctuator, "[") {
+		if err := p.next(); err != nil {
+			return err
+		}
+		if err := p.skipType(); err != nil {
+			return err
+		}
+		if err := p.expect(tokenPunctuator, "]"); err != nil {
+			return err
+		}
+	} else if _, err := p.expectName(); err != nil {
+		return err
+	}
+
+	if p.peek(tokenPunctuator, "!") {
+		return p.next()
+	}
+	return nil
+}
+
+// parseFragment reads "fragment Name on Type { ... }"
+func (p *parser) parseFragment() (*fragment, error) {
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	name, err := p.expectName()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(tokenName, "on"); err != nil {
+		return nil, err
+	}
+	if _, err := p.expectName(); err != nil {
+		return nil, err
+	}
+	if _, err := p.parseDirectives(); err != nil {
+		return nil, err
+	}
+
+	selections, err := p.parseSelectionSet()
+	if err != nil {
+		return nil, err
+	}
+	return &fragment{name: name, selections: selections}, nil
+}
+
+// parseSelectionSet reads the selections between braces
+func (p *parser) parseSelectionSet() ([]*selection, error) {
+	if err := p.enter(); err != nil {
+		return nil, err
+	}
+	defer func() { p.depth-- }()
+
+	if err := p.expect(tokenPunctuator, "{"); err != nil {
+		return nil, err
+	}
+
+	var selections []*selection
+	for !p.peek(tokenPunctuator, "}") {
+		sel, err := p.parseSelection()
+		if err != nil {
+			return nil, err
+		}
+		selections = append(selections, sel)
+	}
+	return selections, p.next()
+}
+
+// parseSelection reads a field, a fragment spread or an inline fragment
+func (p *parser) parseSelection() (*selection, error) {
+	if p.peek(tokenPunctuator, "...") {
+		return p.parseFragmentSelection()
+	}
+
+	sel := &selection{}
+	name, err := p.expectName()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek(tokenPunctuator, ":") {
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		sel.alias = name
+		if name, err = p.expectName(); err != nil {
+			return nil, err
+		}
+	}
+	sel.name = name
+
+	if sel.arguments, err = p.parseArguments(); err != nil {
+		return nil, err
+	}
+	if sel.directives, err = p.parseDirectives(); err != nil {
+		return nil, err
+	}
+	if p.peek(tokenPunctuator, "{") {
+		if sel.selections, err = p.parseSelectionSet(); err != nil {
+			return nil, err
+		}
+	}
+	return sel, nil
+}
+
+// parseFragmentSelection reads "...Name" or "... on Type { ... }"
+func (p *parser) parseFragmentSelection() (*selection, error) {
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+
+	sel := &selection{}
+	if p.token.kind == tokenName && p.token.value != "on" {
+		sel.spread = p.token.value
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		var err error
+		sel.directives, err = p.parseDirectives()
+		return sel, err
+	}
+
+	if p.peek(tokenName, "on") {
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		if _, err := p.expectName(); err != nil {
+			return nil, err
+		}
+	}
+	var err error
+	if sel.directives, err = p.parseDirectives(); err != nil {
+		return nil, err
+	}
+	sel.inline, err = p.parseSelectionSet()
+	return sel, err
+}
+
+// parseArguments reads "(name: value, ...)" when present
+func (p *parser) parseArguments() (map[string]interface{}, error) {
+	if !p.peek(tokenPunctuator, "(") {
+		return nil, nil
+	}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+
+	arguments := make(map[string]interface{})
+	for !p.peek(tokenPunctuator, ")") {
+		name, err := p.expectName()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(tokenPunctuator, ":"); err != nil {
+			return nil, err
+		}
+		if arguments[name], err = p.parseValue(false); err != nil {
+			return nil, err
+		}
+	}
+	return arguments, p.next()
+}
+
+// parseDirectives reads "@name(arguments)" annotations
+func (p *parser) parseDirectives() ([]directive, error) {
+	var directives []directive
+	for p.peek(tokenPunctuator, "@") {
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		name, err := p.expectName()
+		if err != nil {
+			return nil, err
+		}
+		arguments, err := p.parseArguments()
+		if err != nil {
+			return nil, err
+		}
+		directives = append(directives, directive{name: name, arguments: arguments})
+	}
+	return directives, nil
+}
+
+// parseValue reads an argument value; constant values may not use variables
+func (p *parser) parseValue(constant bool) (interface{}, error) {
+	if err := p.enter(); err != nil {
+		return nil, err
+	}
+	defer func() { p.depth-- }()
+
+	tok := p.token
+	switch {
+	case tok.kind == tokenPunctuator && tok.value == "$" && !constant:
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		name, err := p.expectName()
+		return variable(name), err
+	case tok.kind == tokenPunctuator && tok.value == "[":
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		list := []interface{}{}
+		for !p.peek(tokenPunctuator, "]") {
+			item, err := p.parseValue(constant)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, item)
+		}
+		return list, p.next()
+	case tok.kind == tokenPunctuator && tok.value == "{":
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		object := make(map[string]interface{})
+		for !p.peek(tokenPunctuator, "}") {
+			name, err := p.expectName()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(tokenPunctuator, ":"); err != nil {
+				return nil, err
+			}
+			if object[name], err = p.parseValue(constant); err != nil {
+				return nil, err
+			}
+		}
+		return object, p.next()
+	case tok.kind == tokenInt:
+		n, err := strconv.ParseInt(tok.value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid integer %s at %d", tok.value, tok.pos)
+		}
+		return n, p.next()
+	case tok.kind == tokenFloat:
+		f, err := strconv.ParseFloat(tok.value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %s at %d", tok.value, tok.pos)
+		}
+		return f, p.next()
+	case tok.kind == tokenString:
+		return tok.value, p.next()
+	case tok.kind == tokenName:
+		var value interface{}
+		switch tok.value {
+		case "true":
+			value = true
+		case "false":
+			value = false
+		case "null":
+			value = nil
+		default:
+			value = enumValue(tok.value)
+		}
+		return value, p.next()
+	}
+	return nil, p.unexpected()
+}
+
+// peek reports whether the current token is the given one
+func (p *parser) peek(kind int, value string) bool {
+	return p.token.kind == kind && p.token.value == value
+}
+
+// expect consumes the given token
+func (p *parser) expect(kind int, value string) error {
+	if !p.peek(kind, value) {
+		return p.unexpected()
+	}
+	return p.next()
+}
+
+// expectName consumes a name and returns it
+func (p *parser) expectName() (string, error) {
+	if p.token.kind != tokenName {
+		return "", p.unexpected()
+	}
+	name := p.token.value
+	return name, p.next()
+}
+
+// unexpected describes the current token as a syntax error
+func (p *parser) unexpected() error {
+	if p.token.kind == tokenEOF {
+		return fmt.Errorf("syntax error: unexpected end of document")
+	}
+	return fmt.Errorf("syntax error: unexpected %q at %d", p.token.value, p.token.pos)
+}
+
+// next reads the following token, skipping whitespace, commas and comments
+func (p *parser) next() error {
+	for p.pos < len(p.source) {
+		c := p.source[p.pos]
+		if c == '#' {
+			for p.pos < len(p.source) && p.source[p.pos] != '\n' && p.source[p.pos] != '\r' {
+				p.pos++
+			}
+			continue
+		}
+		if c != ' ' && c != '\t' && c != '\n' && c != '\r' && c != ',' {
+			break
+		}
+		p.pos++
+	}
+	if p.pos >= len(p.source) {
+		p.token = token{kind: tokenEOF, pos: p.pos}
+		return nil
+	}
+
+	start := p.pos
+	c := p.source[p.pos]
+	switch {
+	case strings.HasPrefix(p.source[p.pos:], "..."):
+		p.pos += 3
+		p.token = token{kind: tokenPunctuator, value: "...", pos: start}
+	case strings.IndexByte("!$()[]{}:=@|&", c) >= 0:
+		p.pos++
+		p.token = token{kind: tokenPunctuator, value: string(c), pos: start}
+	case c == '_' || isLetter(c):
+		for p.pos < len(p.source) && (p.source[p.pos] == '_' || isLetter(p.source[p.pos]) || isDigit(p.source[p.pos])) {
+			p.pos++
+		}
+		p.token = token{kind: tokenName, value: p.source[start:p.pos], pos: start}
+	case c == '-' || isDigit(c):
+		return p.readNumber()
+	case c == '"':
+		return p.readString()
+	default:
+		r, _ := utf8.DecodeRuneInString(p.source[p.pos:])
+		return fmt.Errorf("syntax error: unexpected character %q at %d", r, start)
+	}
+	return nil
+}
+
+// readNumber reads an integer or float token
+func (p *parser) readNumber() error {
+	start := p.pos
+	kind := tokenInt
+	if p.source[p.pos] == '-' {
+		p.pos++
+	}
+	digits := func() {
+		for p.pos < len(p.source) && isDigit(p.source[p.pos]) {
+			p.pos++
+		}
+	}
+	digits()
+	if p.pos < len(p.source) && p.source[p.pos] == '.' {
+		kind = tokenFloat
+		p.pos++
+		digits()
+	}
+	if p.pos < len(p.source) && (p.source[p.pos] == 'e' || p.source[p.pos] == 'E') {
+		kind = tokenFloat
+		p.pos++
+		if p.pos < len(p.source) && (p.source[p.pos] == '+' || p.source[p.pos] == '-') {
+			p.pos++
+		}
+		digits()
+	}
+	p.token = token{kind: kind, value: p.source[start:p.pos], pos: start}
+	return nil
+}
+
+// readString reads a quoted or block string token
+func (p *parser) readString() error {
+	start := p.pos
+	if strings.HasPrefix(p.source[p.pos:], `"""`) {
+		end := strings.Index(p.source[p.pos+3:], `"""`)
+		if end < 0 {
+			return fmt.Errorf("syntax error: unterminated string at %d", start)
+		}
+		value := p.source[p.pos+3 : p.pos+3+end]
+		p.pos += 3 + end + 3
+		p.token = token{kind: tokenString, value: strings.TrimSpace(value), pos: start}
+		return nil
+	}
+
+	var b strings.Builder
+	p.pos++
+	for {
+		if p.pos >= len(p.source) || p.source[p.pos] == '\n' {
+			return fmt.Errorf("syntax error: unterminated string at %d", start)
+		}
+		c := p.source[p.pos]
+		if c == '"' {
+			p.pos++
+			break
+		}
+		if c != '\\' {
+			b.WriteByte(c)
+			p.pos++
+			continue
+		}
+		if p.pos+1 >= len(p.source) {
+			return fmt.Errorf("syntax error: unterminated string at %d", start)
+		}
+		escape := p.source[p.pos+1]
+		p.pos += 2
+		switch escape {
+		case '"', '\\', '/':
+			b.WriteByte(escape)
+		case 'b':
+			b.WriteByte('\b')
+		case 'f':
+			b.WriteByte('\f')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		case 'u':
+			if p.pos+4 > len(p.source) {
+				return fmt.Errorf("syntax error: invalid escape at %d", p.pos-2)
+			}
+			code, err := strconv.ParseUint(p.source[p.pos:p.pos+4], 16, 32)
+			if err != nil {
+				return fmt.Errorf("syntax error: invalid escape at %d", p.pos-2)
+			}
+			b.WriteRune(rune(code))
+			p.pos += 4
+		default:
+			return fmt.Errorf("syntax error: invalid escape at %d", p.pos-2)
+		}
+	}
+	p.token = token{kind: tokenString, value: b.String(), pos: start}
+	return nil
+}
+
+func isLetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
@@ -0,0 +1,178 @@
+package graphql
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseValidDocuments(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		check  func(t *testing.T, doc *document)
+	}{
+		{
+			name:   "shorthand query",
+			source: `{ agents { id } }`,
+			check: func(t *testing.T, doc *document) {
+				op := doc.operations[0]
+				if op.kind != "query" || op.name != "" || len(op.selections) != 1 || op.selections[0].name != "agents" {
+					t.Errorf("operation = %+v", op)
+				}
+			},
+		},
+		{
+			name:   "named operations with variables and defaults",
+			source: `query One($id: ID!, $n: Int = 5, $types: [String!] = ["agent"]) { a } subscription Two { b }`,
+			check: func(t *testing.T, doc *document) {
+				if len(doc.operations) != 2 {
+					t.Fatalf("operations = %d", len(doc.operations))
+				}
+				one := doc.operations[0]
+				want := []variableDefinition{{name: "id"}, {name: "n", defaultValue: int64(5)}, {name: "types", defaultValue: []interface{}{"agent"}}}
+				if one.name != "One" || !reflect.DeepEqual(one.variables, want) {
+					t.Errorf("first operation = %+v", one)
+				}
+				if two := doc.operations[1]; two.kind != "subscription" || two.name != "Two" {
+					t.Errorf("second operation = %+v", two)
+				}
+			},
+		},
+		{
+			name:   "aliases and arguments of every kind",
+			source: `{ first: conversation(id: "c1", n: -3, ratio: 1.5e2, on: true, off: false, none: null, order: DESC, ids: [1, 2], filter: {type: "agent"}, v: $var) { id } }`,
+			check: func(t *testing.T, doc *document) {
+				sel := doc.operations[0].selections[0]
+				if sel.alias != "first" || sel.name != "conversation" {
+					t.Errorf("alias %q, name %q", sel.alias, sel.name)
+				}
+				want := map[string]interface{}{
+					"id": "c1", "n": int64(-3), "ratio": 150.0, "on": true, "off": false, "none": nil,
+					"order": enumValue("DESC"), "ids": []interface{}{int64(1), int64(2)},
+					"filter": map[string]interface{}{"type": "agent"}, "v": variable("var"),
+				}
+				if !reflect.DeepEqual(sel.arguments, want) {
+					t.Errorf("arguments = %#v", sel.arguments)
+				}
+			},
+		},
+		{
+			name:   "fragments, inline fragments and directives",
+			source: "query { agents { ...AgentFields @include(if: $full) ... on Agent @skip(if: false) { name } } }\nfragment AgentFields on Agent { id }",
+			check: func(t *testing.T, doc *document) {
+				agents := doc.operations[0].selections[0]
+				if len(agents.selections) != 2 {
+					t.Fatalf("selections = %+v", agents.selections)
+				}
+				spread, inline := agents.selections[0], agents.selections[1]
+				if spread.spread != "AgentFields" || len(spread.directives) != 1 || spread.directives[0].name != "include" {
+					t.Errorf("spread = %+v", spread)
+				}
+				if len(inline.inline) != 1 || inline.inline[0].name != "name" || inline.directives[0].name != "skip" {
+					t.Errorf("inline fragment = %+v", inline)
+				}
+				if frag := doc.fragments["AgentFields"]; frag == nil || frag.selections[0].name != "id" {
+					t.Errorf("fragment = %+v", frag)
+				}
+			},
+		},
+		{
+			name:   "comments, commas and escapes",
+			source: "# leading comment\n{ a(s: \"q\\\"uote\\\\ \\n\\u00e9\", block: \"\"\"\n  raw \"text\"\n\"\"\"),, b # trailing\n}",
+			check: func(t *testing.T, doc *document) {
+				selections := doc.operations[0].selections
+				if len(selections) != 2 {
+					t.Fatalf("selections = %d", len(selections))
+				}
+				if got := selections[0].arguments["s"]; got != "q\"uote\\ \né" {
+					t.Errorf("escaped string = %q", got)
+				}
+				if got := selections[0].arguments["block"]; got != `raw "text"` {
+					t.Errorf("block string = %q", got)
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := parse(tt.source)
+			if err != nil {
+				t.Fatalf("parse: %v", err)
+			}
+			tt.check(t, doc)
+		})
+	}
+}
+
+func TestParseMalformedDocuments(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   string
+	}{
+		{name: "empty", source: "", want: "no operation"},
+		{name: "only a fragment", source: "fragment F on T { a }", want: "no operation"},
+		{name: "unclosed selection set", source: "{ a { b }", want: "unexpected end"},
+		{name: "stray closing brace", source: "{ a } }", want: `unexpected "}"`},
+		{name: "missing field name", source: "{ : a }", want: `unexpected ":"`},
+		{name: "argument without value", source: "{ a(id:) }", want: `unexpected ")"`},
+		{name: "unclosed arguments", source: `{ a(id: "x" }`, want: `unexpected "}"`},
+		{name: "variable in a default value", source: "query($a: Int = $b) { a }", want: `unexpected "$"`},
+		{name: "variable without type", source: "query($a) { a }", want: `unexpected ")"`},
+		{name: "unknown keyword", source: "schema { a }", want: `unexpected "schema"`},
+		{name: "unterminated string", source: `{ a(s: "open) }`, want: "unterminated string"},
+		{name: "newline in string", source: "{ a(s: \"one\ntwo\") }", want: "unterminated string"},
+		{name: "unterminated block string", source: `{ a(s: """open) }`, want: "unterminated string"},
+		{name: "invalid escape", source: `{ a(s: "\x") }`, want: "invalid escape"},
+		{name: "short unicode escape", source: `{ a(s: "\u12") }`, want: "invalid escape"},
+		{name: "unexpected character", source: "{ a% }", want: "unexpected character"},
+		{name: "integer out of range", source: "{ a(n: 99999999999999999999) }", want: "invalid integer"},
+		{name: "lone minus", source: "{ a(n: -) }", want: "invalid integer"},
+		{name: "fragment without type condition", source: "{ ...F } fragment F { a }", want: `unexpected "{"`},
+		{name: "unknown fragment", source: "{ ...Missing }", want: `unknown fragment "Missing"`},
+		{name: "fragment spreading itself", source: "{ ...A } fragment A on T { a { ...A } }", want: `fragment "A" spreads itself`},
+		{name: "fragments spreading each other", source: "{ ...A } fragment A on T { ...B } fragment B on T { ... on T { ...A } }", want: "spreads itself"},
+		{name: "unused fragment cycle", source: "{ a } fragment A on T { ...B } fragment B on T { ...A }", want: "spreads itself"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parse(tt.source)
+			if err == nil {
+				t.Fatalf("parse succeeded, want error containing %q", tt.want)
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %q, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseDeepNesting(t *testing.T) {
+	nested := func(depth int, open, close string) string {
+		return strings.Repeat(open, depth) + strings.Repeat(close, depth)
+	}
+	tests := []struct {
+		name   string
+		source string
+		ok     bool
+	}{
+		{name: "selection sets at the limit", source: nested(maxDepth, "{ a ", "}"), ok: true},
+		{name: "selection sets past the limit", source: nested(maxDepth+1, "{ a ", "}")},
+		{name: "hostile selection sets", source: nested(100000, "{ a ", "}")},
+		{name: "list values past the limit", source: "{ a(v: " + nested(maxDepth, "[", "]") + ") }"},
+		{name: "object values past the limit", source: "{ a(v: " + nested(maxDepth, "{v: ", "}") + ") }"},
+		{name: "list types past the limit", source: "query($v: " + nested(maxDepth+1, "[", "]") + ") { a }"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parse(tt.source)
+			if tt.ok && err != nil {
+				t.Errorf("parse: %v", err)
+			}
+			if !tt.ok && (err == nil || !strings.Contains(err.Error(), "nests deeper")) {
+				t.Errorf("error = %v, want a nesting error", err)
+			}
+		})
+	}
+}
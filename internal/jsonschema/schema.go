@@ -0,0 +1,249 @@
+// Package jsonschema validates JSON documents against the commonly used
+// subset of JSON Schema: types, properties, required, additionalProperties,
+// enum, items and the numeric, length and size bounds.
+package jsonschema
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Schema is a parsed JSON Schema
+type Schema struct {
+	raw  json.RawMessage
+	root map[string]interface{}
+}
+
+// Parse reads a JSON Schema
+func Parse(data []byte) (*Schema, error) {
+	var root map[string]interface{}
+	if err := json.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("invalid JSON schema: %w", err)
+	}
+	return &Schema{raw: append(json.RawMessage(nil), data...), root: root}, nil
+}
+
+// MustParse is like Parse but panics on an invalid schema; for schemas built into the code
+func MustParse(data string) *Schema {
+	schema, err := Parse([]byte(data))
+	if err != nil {
+		panic(err)
+	}
+	return schema
+}
+
+// MarshalJSON returns the schema as it was parsed, for sending to providers
+func (s *Schema) MarshalJSON() ([]byte, error) {
+	return s.raw, nil
+}
+
+// Validate checks a JSON document against the schema
+func (s *Schema) Validate(document []byte) error {
+	var value interface{}
+	if err := json.Unmarshal(document, &value); err != nil {
+		return fmt.Errorf("invalid JSON: %w", err)
+	}
+	return validate(s.root, value, "$")
+}
+
+// validate checks a decoded value against a schema node at a JSON path
+func validate(schema map[string]interface{}, value interface{}, path string) error {
+	if t, ok := schema["type"]; ok && !matchesType(t, value) {
+		return fmt.Errorf("%s: expected %v, got %s", path, t, typeName(value))
+	}
+
+	if enum, ok := schema["enum"].([]interface{}); ok {
+		found := false
+		for _, option := range enum {
+			if equal(option, value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%s: %v is not one of %v", path, value, enum)
+		}
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return validateObject(schema, v, path)
+	case []interface{}:
+		return validateArray(schema, v, path)
+	case string:
+		length := float64(utf8.RuneCountInString(v))
+		if limit, ok := number(schema["minLength"]); ok && length < limit {
+			return fmt.Errorf("%s: shorter than %v characters", path, limit)
+		}
+		if limit, ok := number(schema["maxLength"]); ok && length > limit {
+			return fmt.Errorf("%s: longer than %v characters", path, limit)
+		}
+	case float64:
+		if limit, ok := number(schema["minimum"]); ok && v < limit {
+			return fmt.Errorf("%s: %v is less than %v", path, v, limit)
+		}
+		if limit, ok := number(schema["maximum"]); ok && v > limit {
+			return fmt.Errorf("%s: %v is more than %v", path, v, limit)
+		}
+	}
+	return nil
+}
+
+// validateObject checks required, declared and additional properties
+func validateObject(schema map[string]interface{}, object map[string]interface{}, path string) error {
+	if required, ok := schema["required"].([]interface{}); ok {
+		for _, name := range required {
+			if key, ok := name.(string); ok {
+				if _, exists := object[key]; !exists {
+					return fmt.Errorf("%s: missing property %q", path, key)
+				}
+			}
+		}
+	}
+
+	properties, _ := schema["properties"].(map[string]interface{})
+	additional := schema["additionalProperties"]
+
+	// Sorted so the first error reported is stable
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if property, ok := properties[key].(map[string]interface{}); ok {
+			if err := validate(property, object[key], path+"."+key); err != nil {
+				return err
+			}
+			continue
+		}
+		switch a := additional.(type) {
+		case bool:
+			if !a {
+				return fmt.Errorf("%s: unexpected property %q", path, key)
+			}
+		case map[string]interface{}:
+			if err := validate(a, object[key], path+"."+key); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// validateArray checks the size and the items of an array
+func validateArray(schema map[string]interface{}, array []interface{}, path string) error {
+	size := float64(len(array))
+	if limit, ok := number(schema["minItems"]); ok && size < limit {
+		return fmt.Errorf("%s: fewer than %v items", path, limit)
+	}
+	if limit, ok := number(schema["maxItems"]); ok && size > limit {
+		return fmt.Errorf("%s: more than %v items", path, limit)
+	}
+
+	if items, ok := schema["items"].(map[string]interface{}); ok {
+		for i, item := range array {
+			if err := validate(items, item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// matchesType reports whether a value has the type, or one of the types, a schema allows
+func matchesType(t interface{}, value interface{}) bool {
+	switch t := t.(type) {
+	case string:
+		return isType(t, value)
+	case []interface{}:
+		for _, option := range t {
+			if name, ok := option.(string); ok && isType(name, value) {
+				return true
+			}
+		}
+		return false
+	default:
+		return true
+	}
+}
+
+// isType reports whether a value is of a JSON Schema type
+func isType(name string, value interface{}) bool {
+	switch name {
+	case "integer":
+		n, ok := value.(float64)
+		return ok && n == math.Trunc(n)
+	case "number":
+		_, ok := value.(float64)
+		return ok
+	default:
+		return typeName(value) == name
+	}
+}
+
+// typeName returns the JSON Schema type of a decoded value
+func typeName(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+// number reads a numeric schema keyword
+func number(value interface{}) (float64, bool) {
+	n, ok := value.(float64)
+	return n, ok
+}
+
+// equal compares two decoded JSON values
+func equal(a, b interface{}) bool {
+	left, _ := json.Marshal(a)
+	right, _ := json.Marshal(b)
+	return string(left) == string(right)
+}
+
+// Extract returns the JSON document in a model's answer, dropping Markdown
+// code fences and any text around the outermost object or array
+func Extract(response string) string {
+	response = strings.TrimSpace(response)
+	if fenced := strings.Index(response, "```"); fenced >= 0 {
+		rest := response[fenced+3:]
+		if newline := strings.Index(rest, "\n"); newline >= 0 {
+			rest = rest[newline+1:]
+		}
+		if end := strings.Index(rest, "```"); end >= 0 {
+			response = strings.TrimSpace(rest[:end])
+		}
+	}
+
+	start := strings.IndexAny(response, "{[")
+	if start < 0 {
+		return response
+	}
+	closing := "}"
+	if response[start] == '[' {
+		closing = "]"
+	}
+	if end := strings.LastIndex(response, closing); end > start {
+		return response[start : end+1]
+	}
+	return response
+}
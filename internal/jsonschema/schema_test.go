@@ -0,0 +1,152 @@
+package jsonschema
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		schema   string
+		document string
+		wantErr  string // Empty when the document is valid
+	}{
+		{name: "no keywords", schema: `{}`, document: `[1, "two", null]`},
+
+		{name: "string", schema: `{"type": "string"}`, document: `"hi"`},
+		{name: "string given a number", schema: `{"type": "string"}`, document: `1`, wantErr: "$: expected string, got number"},
+		{name: "boolean", schema: `{"type": "boolean"}`, document: `false`},
+		{name: "null", schema: `{"type": "null"}`, document: `null`},
+		{name: "null given an object", schema: `{"type": "null"}`, document: `{}`, wantErr: "expected null, got object"},
+		{name: "integer", schema: `{"type": "integer"}`, document: `3.0`},
+		{name: "integer given a fraction", schema: `{"type": "integer"}`, document: `3.5`, wantErr: "expected integer, got number"},
+		{name: "number given an integer", schema: `{"type": "number"}`, document: `3`},
+		{name: "array given an object", schema: `{"type": "array"}`, document: `{}`, wantErr: "expected array, got object"},
+		{name: "one of several types", schema: `{"type": ["string", "null"]}`, document: `null`},
+		{name: "none of several types", schema: `{"type": ["string", "null"]}`, document: `true`, wantErr: "expected [string null], got boolean"},
+
+		{name: "enum", schema: `{"enum": ["a", 1, null]}`, document: `1`},
+		{name: "enum of objects", schema: `{"enum": [{"a": [1, 2]}]}`, document: `{"a": [1, 2]}`},
+		{name: "not in enum", schema: `{"enum": ["a", "b"]}`, document: `"c"`, wantErr: "c is not one of [a b]"},
+
+		{name: "minLength counts characters", schema: `{"minLength": 3}`, document: `"héé"`},
+		{name: "too short", schema: `{"minLength": 3}`, document: `"ab"`, wantErr: "shorter than 3 characters"},
+		{name: "maxLength counts characters", schema: `{"maxLength": 2}`, document: `"世界"`},
+		{name: "too long", schema: `{"maxLength": 2}`, document: `"abc"`, wantErr: "longer than 2 characters"},
+		{name: "minimum is inclusive", schema: `{"minimum": 1}`, document: `1`},
+		{name: "below minimum", schema: `{"minimum": 1}`, document: `0.5`, wantErr: "0.5 is less than 1"},
+		{name: "maximum is inclusive", schema: `{"maximum": 10}`, document: `10`},
+		{name: "above maximum", schema: `{"maximum": 10}`, document: `11`, wantErr: "11 is more than 10"},
+		{name: "bounds ignore other types", schema: `{"minimum": 1, "minLength": 5}`, document: `true`},
+
+		{name: "enough items", schema: `{"minItems": 1, "maxItems": 2}`, document: `[1, 2]`},
+		{name: "too few items", schema: `{"minItems": 1}`, document: `[]`, wantErr: "fewer than 1 items"},
+		{name: "too many items", schema: `{"maxItems": 1}`, document: `[1, 2]`, wantErr: "more than 1 items"},
+		{name: "items", schema: `{"items": {"type": "integer"}}`, document: `[1, 2, 3]`},
+		{name: "bad item", schema: `{"items": {"type": "integer"}}`, document: `[1, "2"]`, wantErr: "$[1]: expected integer, got string"},
+
+		{
+			name:     "properties and required",
+			schema:   `{"type": "object", "properties": {"name": {"type": "string"}}, "required": ["name"]}`,
+			document: `{"name": "socrates", "age": 70}`,
+		},
+		{
+			name:     "missing required property",
+			schema:   `{"type": "object", "required": ["name"]}`,
+			document: `{"age": 70}`,
+			wantErr:  `$: missing property "name"`,
+		},
+		{
+			name:     "bad property",
+			schema:   `{"properties": {"age": {"type": "integer", "minimum": 0}}}`,
+			document: `{"age": -1}`,
+			wantErr:  "$.age: -1 is less than 0",
+		},
+		{
+			name:     "no additional properties",
+			schema:   `{"properties": {"a": {}}, "additionalProperties": false}`,
+			document: `{"a": 1, "b": 2}`,
+			wantErr:  `$: unexpected property "b"`,
+		},
+		{
+			name:     "additional properties allowed",
+			schema:   `{"properties": {"a": {}}, "additionalProperties": true}`,
+			document: `{"a": 1, "b": 2}`,
+		},
+		{
+			name:     "schema for additional properties",
+			schema:   `{"additionalProperties": {"type": "number"}}`,
+			document: `{"a": 1, "b": "two"}`,
+			wantErr:  "$.b: expected number, got string",
+		},
+		{
+			name:     "first error in key order",
+			schema:   `{"additionalProperties": false}`,
+			document: `{"z": 1, "m": 2, "a": 3}`,
+			wantErr:  `unexpected property "a"`,
+		},
+		{
+			name: "nested path",
+			schema: `{"properties": {"scores": {"type": "array", "items": {"type": "object",
+				"properties": {"value": {"type": "integer", "maximum": 10}}}}}}`,
+			document: `{"scores": [{"value": 3}, {"value": 11}]}`,
+			wantErr:  "$.scores[1].value: 11 is more than 10",
+		},
+
+		{name: "invalid JSON", schema: `{}`, document: `{"a":`, wantErr: "invalid JSON"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schema, err := Parse([]byte(tt.schema))
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = schema.Validate([]byte(tt.document))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Validate = %v, want valid", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate = %v, want an error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	for _, invalid := range []string{``, `{`, `[]`, `"string"`} {
+		if _, err := Parse([]byte(invalid)); err == nil {
+			t.Errorf("Parse(%q) succeeded", invalid)
+		}
+	}
+
+	source := `{"type": "object",  "required": ["a"]}`
+	schema := MustParse(source)
+	data, err := schema.MarshalJSON()
+	if err != nil || string(data) != source {
+		t.Errorf("MarshalJSON = %s, %v; want the schema as parsed", data, err)
+	}
+}
+
+func TestExtract(t *testing.T) {
+	tests := []struct {
+		response string
+		want     string
+	}{
+		{response: `{"a": 1}`, want: `{"a": 1}`},
+		{response: "```json\n{\"a\": 1}\n```", want: `{"a": 1}`},
+		{response: "Here you go:\n```\n[1, 2]\n```\nAnything else?", want: `[1, 2]`},
+		{response: `Sure! {"a": {"b": 2}} Hope that helps.`, want: `{"a": {"b": 2}}`},
+		{response: `The list: [1, [2]] done`, want: `[1, [2]]`},
+		{response: `  no JSON here  `, want: `no JSON here`},
+		{response: `unclosed { object`, want: `unclosed { object`},
+	}
+	for _, tt := range tests {
+		if got := Extract(tt.response); got != tt.want {
+			t.Errorf("Extract(%q) = %q, want %q", tt.response, got, tt.want)
+		}
+	}
+}
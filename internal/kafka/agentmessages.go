@@ -0,0 +1,71 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"philoking/internal/types"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// AgentMessagesEnabled reports whether agents can exchange direct messages
+func (c *Client) AgentMessagesEnabled() bool {
+	return c.config.Topics.AgentMessages != ""
+}
+
+// PublishAgentMessage sends a direct message between agents. It goes to its
+// own topic, which neither the conversation flow nor the web server reads.
+func (c *Client) PublishAgentMessage(ctx context.Context, message *types.AgentMessage) error {
+	topic := c.config.Topics.AgentMessages
+	if topic == "" {
+		return fmt.Errorf("agent messages are disabled (kafka.topics.agent_messages is empty)")
+	}
+
+	data, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("failed to marshal agent message: %w", err)
+	}
+	if data, err = c.sealPayload(topic, data); err != nil {
+		return err
+	}
+
+	record := kafka.Message{
+		Topic: topic,
+		Key:   []byte(message.ToAgent),
+		Value: data,
+	}
+	signature, signed, err := c.keys.sign(message.FromAgent, data)
+	if err != nil {
+		return err
+	}
+	if signed {
+		record.Headers = append(record.Headers, signature)
+	}
+	return c.write(ctx, topic, record)
+}
+
+// SubscribeToAgentMessages consumes the direct messages between agents sent
+// from now on. The handler sees every message and picks its own.
+func (c *Client) SubscribeToAgentMessages(ctx context.Context, groupID string, handler func(*types.AgentMessage) error) error {
+	topic := c.config.Topics.AgentMessages
+	if topic == "" {
+		return fmt.Errorf("agent messages are disabled (kafka.topics.agent_messages is empty)")
+	}
+
+	return c.consume(ctx, groupID, []string{topic}, kafka.LastOffset, func(msg kafka.Message) error {
+		data, err := c.openPayload(msg.Topic, msg.Value)
+		if err != nil {
+			return fmt.Errorf("%w: %v", errUnreadable, err)
+		}
+		var message types.AgentMessage
+		if err := json.Unmarshal(data, &message); err != nil {
+			return fmt.Errorf("%w: %v", errUnreadable, err)
+		}
+		if err := c.keys.verify(message.FromAgent, msg); err != nil {
+			return err
+		}
+		return handler(&message)
+	})
+}
@@ -0,0 +1,300 @@
+package kafka
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"philoking/internal/types"
+)
+
+// chatMessageSchema is the Avro schema of types.ChatMessage. Keep it in sync
+// with the struct; new fields need a default so old messages stay readable.
+const chatMessageSchema = `{
+  "type": "record",
+  "name": "ChatMessage",
+  "namespace": "philoking",
+  "fields": [
+    {"name": "id", "type": "string"},
+    {"name": "type", "type": "string"},
+    {"name": "content", "type": "string"},
+    {"name": "agent_id", "type": "string", "default": ""},
+    {"name": "user_id", "type": "string", "default": ""},
+    {"name": "timestamp", "type": {"type": "long", "logicalType": "timestamp-micros"}},
+    {"name": "metadata", "type": {
+      "type": "record",
+      "name": "Metadata",
+      "fields": [
+        {"name": "conversation_id", "type": "string", "default": ""},
+        {"name": "reply_to", "type": "string", "default": ""},
+        {"name": "from_agent", "type": "string", "default": ""},
+        {"name": "command", "type": ["null", {
+          "type": "record",
+          "name": "Command",
+          "fields": [
+            {"name": "name", "type": "string"},
+            {"name": "args", "type": {"type": "array", "items": "string"}},
+            {"name": "raw", "type": "string"}
+          ]
+        }], "default": null},
+        {"name": "required", "type": "boolean", "default": false},
+        {"name": "expires_at", "type": ["null", {"type": "long", "logicalType": "timestamp-micros"}], "default": null},
+        {"name": "tags", "type": {"type": "array", "items": "string"}, "default": []},
+        {"name": "custom", "type": {"type": "map", "values": "string"}, "default": {}}
+      ]
+    }}
+  ]
+}`
+
+// avroMagicByte starts every message in the Confluent wire format, followed
+// by the 4-byte schema ID and the Avro binary body
+const avroMagicByte = 0
+
+// avroSerializer writes compact, schema-validated Avro messages registered in
+// a Confluent Schema Registry. It still reads JSON so topics can be migrated
+// without downtime.
+type avroSerializer struct {
+	registry *schemaRegistry
+	mu       sync.Mutex
+	ids      map[string]int // Schema ID registered per topic
+}
+
+// newAvroSerializer creates an Avro serializer backed by a schema registry
+func newAvroSerializer(registry *schemaRegistry) *avroSerializer {
+	return &avroSerializer{
+		registry: registry,
+		ids:      make(map[string]int),
+	}
+}
+
+// Marshal encodes a message in the Confluent Avro wire format
+func (s *avroSerializer) Marshal(topic string, message *types.ChatMessage) ([]byte, error) {
+	id, err := s.schemaID(topic)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &avroWriter{buf: []byte{avroMagicByte, 0, 0, 0, 0}}
+	binary.BigEndian.PutUint32(w.buf[1:5], uint32(id))
+
+	w.string(message.ID)
+	w.string(string(message.Type))
+	w.string(message.Content)
+	w.string(message.AgentID)
+	w.string(message.UserID)
+	w.long(message.Timestamp.UnixMicro())
+
+	metadata := message.Metadata
+	w.string(metadata.ConversationID)
+	w.string(metadata.ReplyTo)
+	w.string(metadata.FromAgent)
+	if metadata.Command == nil {
+		w.long(0)
+	} else {
+		w.long(1)
+		w.string(metadata.Command.Name)
+		w.strings(metadata.Command.Args)
+		w.string(metadata.Command.Raw)
+	}
+	w.boolean(metadata.Required)
+	if metadata.ExpiresAt == nil {
+		w.long(0)
+	} else {
+		w.long(1)
+		w.long(metadata.ExpiresAt.UnixMicro())
+	}
+	w.strings(metadata.Tags)
+	w.stringMap(metadata.Custom)
+
+	return w.buf, nil
+}
+
+// Unmarshal decodes an Avro message, or a JSON one written before the switch
+func (s *avroSerializer) Unmarshal(topic string, data []byte) (*types.ChatMessage, error) {
+	if len(data) == 0 || data[0] != avroMagicByte {
+		return jsonSerializer{}.Unmarshal(topic, data)
+	}
+	if len(data) < 5 {
+		return nil, fmt.Errorf("avro message too short")
+	}
+	if err := s.registry.verify(int(binary.BigEndian.Uint32(data[1:5])), chatMessageSchema); err != nil {
+		return nil, err
+	}
+
+	r := &avroReader{r: bytes.NewReader(data[5:])}
+	message := &types.ChatMessage{
+		ID:        r.string(),
+		Type:      types.MessageType(r.string()),
+		Content:   r.string(),
+		AgentID:   r.string(),
+		UserID:    r.string(),
+		Timestamp: time.UnixMicro(r.long()),
+	}
+
+	metadata := &message.Metadata
+	metadata.ConversationID = r.string()
+	metadata.ReplyTo = r.string()
+	metadata.FromAgent = r.string()
+	if r.long() == 1 {
+		metadata.Command = &types.Command{Name: r.string(), Args: r.strings(), Raw: r.string()}
+	}
+	metadata.Required = r.boolean()
+	if r.long() == 1 {
+		expiresAt := time.UnixMicro(r.long())
+		metadata.ExpiresAt = &expiresAt
+	}
+	metadata.Tags = r.strings()
+	metadata.Custom = r.stringMap()
+
+	if r.err != nil {
+		return nil, fmt.Errorf("failed to decode avro message: %w", r.err)
+	}
+	return message, nil
+}
+
+// schemaID registers the message schema for a topic once and returns its ID
+func (s *avroSerializer) schemaID(topic string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if id, ok := s.ids[topic]; ok {
+		return id, nil
+	}
+
+	// Subjects follow the registry's default topic name strategy
+	id, err := s.registry.register(topic+"-value", chatMessageSchema)
+	if err != nil {
+		return 0, err
+	}
+	s.ids[topic] = id
+	return id, nil
+}
+
+// avroWriter appends values in the Avro binary encoding
+type avroWriter struct {
+	buf []byte
+}
+
+// long writes a zig-zag varint, which is what encoding/binary's varints are
+func (w *avroWriter) long(v int64) {
+	w.buf = binary.AppendVarint(w.buf, v)
+}
+
+func (w *avroWriter) string(s string) {
+	w.long(int64(len(s)))
+	w.buf = append(w.buf, s...)
+}
+
+func (w *avroWriter) boolean(b bool) {
+	if b {
+		w.buf = append(w.buf, 1)
+	} else {
+		w.buf = append(w.buf, 0)
+	}
+}
+
+// strings writes an array of strings as a single block
+func (w *avroWriter) strings(values []string) {
+	if len(values) > 0 {
+		w.long(int64(len(values)))
+		for _, v := range values {
+			w.string(v)
+		}
+	}
+	w.long(0)
+}
+
+// stringMap writes a map of strings as a single block
+func (w *avroWriter) stringMap(values map[string]string) {
+	if len(values) > 0 {
+		w.long(int64(len(values)))
+		for k, v := range values {
+			w.string(k)
+			w.string(v)
+		}
+	}
+	w.long(0)
+}
+
+// avroReader reads values in the Avro binary encoding, keeping the first error
+type avroReader struct {
+	r   *bytes.Reader
+	err error
+}
+
+func (r *avroReader) long() int64 {
+	if r.err != nil {
+		return 0
+	}
+	v, err := binary.ReadVarint(r.r)
+	if err != nil {
+		r.err = err
+	}
+	return v
+}
+
+func (r *avroReader) string() string {
+	n := r.long()
+	if r.err != nil {
+		return ""
+	}
+	if n < 0 || n > int64(r.r.Len()) {
+		r.err = errors.New("invalid string length")
+		return ""
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(r.r, b); err != nil {
+		r.err = err
+	}
+	return string(b)
+}
+
+func (r *avroReader) boolean() bool {
+	if r.err != nil {
+		return false
+	}
+	b, err := r.r.ReadByte()
+	if err != nil {
+		r.err = err
+	}
+	return b == 1
+}
+
+// blockCount reads the item count of the next array or map block
+func (r *avroReader) blockCount() int64 {
+	n := r.long()
+	if n < 0 {
+		// A negative count is followed by the block size in bytes, which we don't need
+		r.long()
+		n = -n
+	}
+	return n
+}
+
+func (r *avroReader) strings() []string {
+	var values []string
+	for n := r.blockCount(); n > 0 && r.err == nil; n = r.blockCount() {
+		for i := int64(0); i < n && r.err == nil; i++ {
+			values = append(values, r.string())
+		}
+	}
+	return values
+}
+
+func (r *avroReader) stringMap() map[string]string {
+	var values map[string]string
+	for n := r.blockCount(); n > 0 && r.err == nil; n = r.blockCount() {
+		if values == nil {
+			values = make(map[string]string)
+		}
+		for i := int64(0); i < n && r.err == nil; i++ {
+			k := r.string()
+			values[k] = r.string()
+		}
+	}
+	return values
+}
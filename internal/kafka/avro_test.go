@@ -0,0 +1,195 @@
+package kafka
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"philoking/internal/config"
+	"philoking/internal/types"
+)
+
+// fakeRegistry serves chatMessageSchema as ID 7 and some other schema as ID 8
+func fakeRegistry(t *testing.T) (*schemaRegistry, *int32) {
+	var registrations int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPost && strings.HasPrefix(r.URL.Path, "/subjects/"):
+			atomic.AddInt32(&registrations, 1)
+			w.Write([]byte(`{"id":7}`))
+		case r.URL.Path == "/schemas/ids/7":
+			json.NewEncoder(w).Encode(map[string]string{"schema": chatMessageSchema})
+		case r.URL.Path == "/schemas/ids/8":
+			w.Write([]byte(`{"schema":"{\"type\":\"string\"}"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return newSchemaRegistry(config.SchemaRegistryConfig{URL: srv.URL}), &registrations
+}
+
+func TestAvroRoundTrip(t *testing.T) {
+	timestamp := time.UnixMicro(1700000000123456)
+	expiresAt := timestamp.Add(time.Hour)
+
+	tests := []struct {
+		name    string
+		message types.ChatMessage
+	}{
+		{
+			name:    "zero metadata",
+			message: types.ChatMessage{ID: "m1", Type: types.MessageTypeUser, Content: "hello", UserID: "u1", Timestamp: timestamp},
+		},
+		{
+			name: "every field",
+			message: types.ChatMessage{
+				ID: "m2", Type: types.MessageTypeAgent, Content: "héllo, 世界", AgentID: "socrates", UserID: "u1", Timestamp: timestamp,
+				Metadata: types.Metadata{
+					ConversationID: "c1",
+					ReplyTo:        "m1",
+					FromAgent:      "Socrates",
+					Command:        &types.Command{Name: "ask", Args: []string{"plato", "why?"}, Raw: "/ask plato why?"},
+					Required:       true,
+					ExpiresAt:      &expiresAt,
+					Tags:           []string{"question", "urgent"},
+					Custom:         map[string]string{"lang": "en", "": "empty key"},
+				},
+			},
+		},
+		{
+			name: "command without arguments",
+			message: types.ChatMessage{
+				ID: "m3", Type: types.MessageTypeSystem, Timestamp: timestamp,
+				Metadata: types.Metadata{Command: &types.Command{Name: "help", Raw: "/help"}},
+			},
+		},
+		{
+			name:    "before the epoch",
+			message: types.ChatMessage{ID: "m4", Type: types.MessageTypeUser, Timestamp: time.UnixMicro(-86400000000)},
+		},
+	}
+
+	registry, registrations := fakeRegistry(t)
+	s := newAvroSerializer(registry)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := s.Marshal("chat", &tt.message)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if data[0] != avroMagicByte || !bytes.Equal(data[1:5], []byte{0, 0, 0, 7}) {
+				t.Errorf("header = %x, want the magic byte and schema ID 7", data[:5])
+			}
+
+			got, err := s.Unmarshal("chat", data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !got.Timestamp.Equal(tt.message.Timestamp) {
+				t.Errorf("timestamp = %v, want %v", got.Timestamp, tt.message.Timestamp)
+			}
+			got.Timestamp = tt.message.Timestamp
+			if got.Metadata.ExpiresAt != nil && got.Metadata.ExpiresAt.Equal(expiresAt) {
+				got.Metadata.ExpiresAt = &expiresAt
+			}
+			if !reflect.DeepEqual(*got, tt.message) {
+				t.Errorf("round trip\n got %+v\nwant %+v", *got, tt.message)
+			}
+		})
+	}
+	if *registrations != 1 {
+		t.Errorf("schema registered %d times, want once per topic", *registrations)
+	}
+}
+
+func TestAvroEmptyCustomMap(t *testing.T) {
+	s := newAvroSerializer(nil)
+	s.ids["chat"] = 7
+	data, err := s.Marshal("chat", &types.ChatMessage{ID: "m1", Timestamp: time.UnixMicro(0), Metadata: types.Metadata{Custom: map[string]string{}, Tags: []string{}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Empty and nil collections are encoded alike, as an empty block
+	want := "000000000704" + hex.EncodeToString([]byte("m1")) + "0000000000" + "0000000000000000"
+	if got := hex.EncodeToString(data); got != want {
+		t.Errorf("encoded\n got %s\nwant %s", got, want)
+	}
+}
+
+func TestAvroUnmarshal(t *testing.T) {
+	// Written by github.com/hamba/avro from chatMessageSchema; unlike us it
+	// writes arrays and maps as blocks with a byte size
+	const hamba = "046d310a6167656e740c68c3a96c6c6f10736f6372617465730080898182838985060263000002" +
+		"0661736b03080261026200102f61736b20612062010280999eeb9d89850601040278000108026b027600"
+	body, err := hex.DecodeString(hamba)
+	if err != nil {
+		t.Fatal(err)
+	}
+	withHeader := func(id byte, body []byte) []byte {
+		return append([]byte{avroMagicByte, 0, 0, 0, id}, body...)
+	}
+
+	tests := []struct {
+		name    string
+		data    []byte
+		check   func(t *testing.T, m *types.ChatMessage)
+		wantErr string
+	}{
+		{
+			name: "another library's encoding",
+			data: withHeader(7, body),
+			check: func(t *testing.T, m *types.ChatMessage) {
+				md := m.Metadata
+				if m.Content != "héllo" || m.AgentID != "socrates" || md.Command == nil || !reflect.DeepEqual(md.Command.Args, []string{"a", "b"}) ||
+					!md.Required || md.ExpiresAt == nil || !reflect.DeepEqual(md.Tags, []string{"x"}) || md.Custom["k"] != "v" {
+					t.Errorf("decoded %+v", m)
+				}
+			},
+		},
+		{
+			name: "JSON written before the switch",
+			data: []byte(`{"id":"m1","type":"user","content":"hi","timestamp":"2024-01-02T03:04:05Z"}`),
+			check: func(t *testing.T, m *types.ChatMessage) {
+				if m.ID != "m1" || m.Content != "hi" {
+					t.Errorf("decoded %+v", m)
+				}
+			},
+		},
+		{name: "header cut short", data: []byte{avroMagicByte, 0, 0}, wantErr: "too short"},
+		{name: "unknown schema", data: withHeader(9, body), wantErr: "failed to fetch schema 9"},
+		{name: "other schema", data: withHeader(8, body), wantErr: "unsupported schema 8"},
+		{name: "truncated body", data: withHeader(7, body[:40]), wantErr: "failed to decode"},
+		{name: "string longer than the message", data: withHeader(7, []byte{0x7e, 'a'}), wantErr: "invalid string length"},
+		{
+			name:    "huge array block",
+			data:    withHeader(7, append(bytes.Clone(body[:43]), 0xfe, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x7f)),
+			wantErr: "failed to decode",
+		},
+	}
+
+	registry, _ := fakeRegistry(t)
+	s := newAvroSerializer(registry)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := s.Unmarshal("chat", tt.data)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Unmarshal = %v, want an error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.check(t, m)
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"philoking/internal/config"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// Defaults for clients created without a loaded consumer configuration
+const (
+	defaultInitialBackoff  = 100 * time.Millisecond
+	defaultMaxBackoff      = 30 * time.Second
+	defaultRecreateAfter   = 5
+	defaultHandlerAttempts = 3
+)
+
+// backoff grows the delay after each consecutive failure, with jitter so
+// consumers that failed together don't retry in lockstep
+type backoff struct {
+	initial  time.Duration
+	max      time.Duration
+	failures int
+	rng      *rand.Rand
+}
+
+// newBackoff creates a backoff from the consumer configuration
+func newBackoff(cfg config.ConsumerConfig) *backoff {
+	b := &backoff{
+		initial: cfg.InitialBackoff,
+		max:     cfg.MaxBackoff,
+		rng:     rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	if b.initial <= 0 {
+		b.initial = defaultInitialBackoff
+	}
+	if b.max < b.initial {
+		b.max = max(defaultMaxBackoff, b.initial)
+	}
+	return b
+}
+
+// next records a failure and returns how long to wait before retrying:
+// a random delay between half and all of the exponentially growing ceiling
+func (b *backoff) next() time.Duration {
+	b.failures++
+	ceiling := b.max
+	if shift := b.failures - 1; shift < 32 && b.initial<<shift < b.max {
+		ceiling = b.initial << shift
+	}
+	half := ceiling / 2
+	return half + time.Duration(b.rng.Int63n(int64(ceiling-half)+1))
+}
+
+// reset forgets the failures after a success
+func (b *backoff) reset() {
+	b.failures = 0
+}
+
+// retryable reports whether a read error is transient, so the reader can
+// be used again once the broker recovers. Anything else, such as a closed
+// or broken connection, needs a new reader.
+func retryable(err error) bool {
+	var kafkaErr kafka.Error
+	if errors.As(err, &kafkaErr) {
+		return kafkaErr.Temporary()
+	}
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.ErrClosedPipe) || errors.Is(err, net.ErrClosed) {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		return true // e.g. a broker refusing connections while it restarts
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// ConsumerStats describes the health of a consumer group of this client
+type ConsumerStats struct {
+	GroupID           string    `json:"group_id"`
+	Topics            []string  `json:"topics"`
+	Consumed          int64     `json:"consumed"`
+	Errors            int64     `json:"errors"`
+	Recreated         int64     `json:"recreated"`          // Readers replaced after unrecoverable errors
+	Failed            int64     `json:"failed"`             // Records the handler gave up on, see Topics.DeadLetter
+	Panics            int64     `json:"panics"`             // Records whose handler panicked, which fail right away
+	ConsecutiveErrors int       `json:"consecutive_errors"` // Since the last message read
+	BackoffMs         int64     `json:"backoff_ms"`         // Current delay before the next read; 0 when healthy
+	LastError         string    `json:"last_error,omitempty"`
+	LastErrorAt       time.Time `json:"last_error_at,omitempty"`
+}
+
+// consumerMetrics keeps the stats of the consumers of a client
+type consumerMetrics struct {
+	mu        sync.Mutex
+	consumers map[string]*ConsumerStats // By group ID
+}
+
+// update changes the stats of a consumer group
+func (m *consumerMetrics) update(groupID string, topics []string, fn func(*ConsumerStats)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.consumers == nil {
+		m.consumers = make(map[string]*ConsumerStats)
+	}
+	stats, exists := m.consumers[groupID]
+	if !exists {
+		stats = &ConsumerStats{GroupID: groupID, Topics: topics}
+		m.consumers[groupID] = stats
+	}
+	fn(stats)
+}
+
+// ConsumerStats returns the stats of the consumer groups of this client, by group ID
+func (c *Client) ConsumerStats() []ConsumerStats {
+	c.metrics.mu.Lock()
+	defer c.metrics.mu.Unlock()
+
+	stats := make([]ConsumerStats, 0, len(c.metrics.consumers))
+	for _, consumer := range c.metrics.consumers {
+		stats = append(stats, *consumer)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].GroupID < stats[j].GroupID
+	})
+	return stats
+}
@@ -0,0 +1,194 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// claimRetention is how long decided claims are remembered
+const claimRetention = 10 * time.Minute
+
+// claimRecord is a replica's bid to answer a message, keyed by message and agent ID
+type claimRecord struct {
+	Key        string    `json:"key"`
+	InstanceID string    `json:"instance_id"`
+	ClaimedAt  time.Time `json:"claimed_at"`
+}
+
+// Claimer makes sure only one replica of an agent answers a given message.
+// Replicas publish claims to a compacted single-partition topic and all read
+// it back in order, so they agree that the first claim for a key wins.
+type Claimer struct {
+	client     *Client
+	instanceID string
+	timeout    time.Duration
+	mu         sync.Mutex
+	winners    map[string]claimRecord   // Decided claims by key
+	waiters    map[string][]chan string // Claims awaiting their outcome, by key
+}
+
+// NewClaimer creates a claimer for this process; timeout bounds how long a
+// claim waits to be confirmed
+func (c *Client) NewClaimer(instanceID string, timeout time.Duration) *Claimer {
+	return &Claimer{
+		client:     c,
+		instanceID: instanceID,
+		timeout:    timeout,
+		winners:    make(map[string]claimRecord),
+		waiters:    make(map[string][]chan string),
+	}
+}
+
+// Start creates the claims topic if needed and follows it in the background
+func (cl *Claimer) Start(ctx context.Context) error {
+	topic := cl.client.config.Topics.Claims
+	if err := cl.client.ensureCompactedTopic(topic); err != nil {
+		return fmt.Errorf("failed to prepare claims topic %s: %w", topic, err)
+	}
+
+	reader := kafka.NewReader(kafka.ReaderConfig{
+		Brokers:   cl.client.config.Brokers,
+		Topic:     topic,
+		Partition: 0,
+		MaxWait:   100 * time.Millisecond, // Claims are latency sensitive
+	})
+	if err := reader.SetOffset(kafka.LastOffset); err != nil {
+		reader.Close()
+		return fmt.Errorf("failed to seek claims topic: %w", err)
+	}
+
+	go func() {
+		defer reader.Close()
+		delay := newBackoff(cl.client.config.Consumer)
+		for {
+			msg, err := reader.ReadMessage(ctx)
+			if err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				wait := delay.next()
+				log.Printf("Error reading claims: %v (failure %d); retrying in %s", err, delay.failures, wait.Round(time.Millisecond))
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(wait):
+				}
+				continue
+			}
+			delay.reset()
+
+			var record claimRecord
+			if err := json.Unmarshal(msg.Value, &record); err != nil {
+				log.Printf("Error unmarshaling claim: %v", err)
+				continue
+			}
+			cl.record(record)
+		}
+	}()
+
+	log.Printf("Claiming replies on topic %s as %s", topic, cl.instanceID)
+	return nil
+}
+
+// Claim bids to answer a message on behalf of an agent and reports whether
+// this replica won. An error means the outcome could not be confirmed.
+func (cl *Claimer) Claim(ctx context.Context, messageID, agentID string) (bool, error) {
+	key := messageID + "/" + agentID
+	outcome := make(chan string, 1)
+
+	cl.mu.Lock()
+	if winner, decided := cl.winners[key]; decided {
+		cl.mu.Unlock()
+		return winner.InstanceID == cl.instanceID, nil
+	}
+	cl.waiters[key] = append(cl.waiters[key], outcome)
+	cl.mu.Unlock()
+
+	data, err := json.Marshal(claimRecord{Key: key, InstanceID: cl.instanceID, ClaimedAt: time.Now()})
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal claim: %w", err)
+	}
+	if err := cl.client.producer.WriteMessages(ctx, kafka.Message{
+		Topic: cl.client.config.Topics.Claims,
+		Key:   []byte(key),
+		Value: data,
+	}); err != nil {
+		return false, fmt.Errorf("failed to publish claim: %w", err)
+	}
+
+	timer := time.NewTimer(cl.timeout)
+	defer timer.Stop()
+
+	select {
+	case winner := <-outcome:
+		return winner == cl.instanceID, nil
+	case <-timer.C:
+		return false, fmt.Errorf("claim %s not confirmed within %s", key, cl.timeout)
+	case <-ctx.Done():
+		return false, ctx.Err()
+	}
+}
+
+// record decides a key on its first claim and notifies the waiting replicas
+func (cl *Claimer) record(record claimRecord) {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+
+	if _, decided := cl.winners[record.Key]; decided {
+		return
+	}
+	cl.winners[record.Key] = record
+
+	for _, outcome := range cl.waiters[record.Key] {
+		outcome <- record.InstanceID
+	}
+	delete(cl.waiters, record.Key)
+
+	// Forget old decisions; their messages won't be delivered again
+	for key, winner := range cl.winners {
+		if time.Since(winner.ClaimedAt) > claimRetention {
+			delete(cl.winners, key)
+		}
+	}
+}
+
+// ensureCompactedTopic creates a single-partition compacted topic if it doesn't exist
+func (c *Client) ensureCompactedTopic(topic string) error {
+	conn, err := kafka.Dial("tcp", c.config.Brokers[0])
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	controller, err := conn.Controller()
+	if err != nil {
+		return err
+	}
+	controllerConn, err := kafka.Dial("tcp", net.JoinHostPort(controller.Host, strconv.Itoa(controller.Port)))
+	if err != nil {
+		return err
+	}
+	defer controllerConn.Close()
+
+	err = controllerConn.CreateTopics(kafka.TopicConfig{
+		Topic:             topic,
+		NumPartitions:     1,
+		ReplicationFactor: 1,
+		ConfigEntries: []kafka.ConfigEntry{
+			{ConfigName: "cleanup.policy", ConfigValue: "compact"},
+		},
+	})
+	if errors.Is(err, kafka.TopicAlreadyExists) {
+		return nil
+	}
+	return err
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"sort"
 	"time"
 
 	"philoking/internal/config"
@@ -13,11 +14,25 @@ import (
 )
 
 type Client struct {
-	producer *kafka.Writer
-	config   config.KafkaConfig
+	producer   messageWriter
+	config     config.KafkaConfig
+	serializer Serializer
+	metrics    consumerMetrics
+	keys       *Keyring // Nil leaves messages unsigned
+}
+
+// messageWriter writes records to Kafka; a *kafka.Writer outside of tests
+type messageWriter interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
 }
 
 func NewClient(cfg config.KafkaConfig) (*Client, error) {
+	serializer, err := NewSerializer(cfg.Serialization)
+	if err != nil {
+		return nil, err
+	}
+
 	// Create producer
 	producer := &kafka.Writer{
 		Addr:      kafka.TCP(cfg.Brokers...),
@@ -26,62 +41,283 @@ func NewClient(cfg config.KafkaConfig) (*Client, error) {
 	}
 
 	return &Client{
-		producer: producer,
-		config:   cfg,
+		producer:   producer,
+		config:     cfg,
+		serializer: serializer,
 	}, nil
 }
 
-// PublishMessage publishes a message to the chat topic
+// Ping checks that at least one broker answers a metadata request
+func (c *Client) Ping(ctx context.Context) error {
+	if len(c.config.Brokers) == 0 {
+		return fmt.Errorf("no Kafka brokers configured")
+	}
+
+	var lastErr error
+	for _, broker := range c.config.Brokers {
+		conn, err := kafka.DialContext(ctx, "tcp", broker)
+		if err != nil {
+			lastErr = fmt.Errorf("broker %s: %w", broker, err)
+			continue
+		}
+		_, err = conn.Brokers()
+		conn.Close()
+		if err != nil {
+			lastErr = fmt.Errorf("broker %s: %w", broker, err)
+			continue
+		}
+		return nil
+	}
+	return lastErr
+}
+
+// PublishMessage publishes a message to the topic its type belongs to
 func (c *Client) PublishMessage(ctx context.Context, message *types.ChatMessage) error {
-	data, err := message.ToJSON()
+	return c.publish(ctx, message, false)
+}
+
+// PublishSigned publishes a message of an agent, signed with the agent's key
+// if it has one. Only agents publish through it: anything else that sets an
+// agent's ID on a message must not get that agent's signature.
+func (c *Client) PublishSigned(ctx context.Context, message *types.ChatMessage) error {
+	return c.publish(ctx, message, true)
+}
+
+// publish writes a message to the topic its type belongs to, signed when asked
+func (c *Client) publish(ctx context.Context, message *types.ChatMessage, sign bool) error {
+	topic := c.topicFor(message)
+	data, err := c.serializer.Marshal(topic, message)
 	if err != nil {
 		return fmt.Errorf("failed to marshal message: %w", err)
 	}
 
-	log.Printf("Publishing message to Kafka topic %s: %s (type: %s, agent: %s)", c.config.Topics.ChatMessages, message.Content, message.Type, message.AgentID)
-
-	return c.producer.WriteMessages(ctx, kafka.Message{
-		Topic: c.config.Topics.ChatMessages,
+	record := kafka.Message{
+		Topic: topic,
 		Value: data,
-	})
+	}
+	if sign {
+		signature, signed, err := c.keys.sign(message.AgentID, data)
+		if err != nil {
+			return err
+		}
+		if signed {
+			record.Headers = append(record.Headers, signature)
+		}
+	}
+
+	log.Printf("Publishing message to Kafka topic %s: %s (type: %s, agent: %s)", topic, message.Content, message.Type, message.AgentID)
+
+	return c.write(ctx, topic, record)
 }
 
-// SubscribeToMessages subscribes to chat messages with a specific consumer group
+// SubscribeToMessages subscribes to the whole conversation, user messages and
+// agent responses alike, with a specific consumer group
 func (c *Client) SubscribeToMessages(ctx context.Context, groupID string, handler func(*types.ChatMessage) error) error {
-	reader := kafka.NewReader(kafka.ReaderConfig{
-		Brokers:  c.config.Brokers,
-		Topic:    c.config.Topics.ChatMessages,
-		GroupID:  groupID,
-		MinBytes: 10e3, // 10KB
-		MaxBytes: 10e6, // 10MB
+	return c.subscribe(ctx, groupID, c.conversationTopics(), kafka.FirstOffset, handler)
+}
+
+// SubscribeFromLatest subscribes to the whole conversation with a consumer
+// group that, when new, starts at the end of the topics instead of replaying
+// their history
+func (c *Client) SubscribeFromLatest(ctx context.Context, groupID string, handler func(*types.ChatMessage) error) error {
+	return c.subscribe(ctx, groupID, c.conversationTopics(), kafka.LastOffset, handler)
+}
+
+// subscribe consumes chat messages from the given topics with a consumer group
+func (c *Client) subscribe(ctx context.Context, groupID string, topics []string, startOffset int64, handler func(*types.ChatMessage) error) error {
+	// At-least-once delivery may hand us a message twice after a rebalance
+	seen := newSeenIDs(dedupeWindow)
+
+	return c.consume(ctx, groupID, topics, startOffset, func(msg kafka.Message) error {
+		chatMsg, err := c.serializer.Unmarshal(msg.Topic, msg.Value)
+		if err != nil {
+			return fmt.Errorf("%w: %v", errUnreadable, err)
+		}
+
+		// Nobody may speak for an agent that signs its messages
+		if err := c.keys.verify(chatMsg.AgentID, msg); err != nil {
+			return err
+		}
+
+		if chatMsg.ID != "" && seen.seen(chatMsg.ID) {
+			log.Printf("Kafka skipped redelivered message %s in group %s", chatMsg.ID, groupID)
+			return nil
+		}
+
+		if chatMsg.Expired(time.Now()) {
+			log.Printf("Kafka skipped expired message %s in group %s", chatMsg.ID, groupID)
+			return nil
+		}
+
+		log.Printf("Kafka consumed message in group %s: %s (type: %s, agent: %s)", groupID, chatMsg.Content, chatMsg.Type, chatMsg.AgentID)
+
+		if err := handler(chatMsg); err != nil {
+			seen.forget(chatMsg.ID) // Let the retry through
+			return err
+		}
+		return nil
 	})
-	defer reader.Close()
+}
+
+// consume reads raw records from the given topics with a consumer group until
+// ctx is done. A record's offset is committed only after it was handled or
+// moved to the dead-letter topic, so a crash redelivers it instead of losing it.
+func (c *Client) consume(ctx context.Context, groupID string, topics []string, startOffset int64, handle func(kafka.Message) error) error {
+	groupID = c.config.GroupPrefix + groupID
+	readerConfig := kafka.ReaderConfig{
+		Brokers:     c.config.Brokers,
+		GroupID:     groupID,
+		StartOffset: startOffset,
+		MinBytes:    10e3, // 10KB
+		MaxBytes:    10e6, // 10MB
+	}
+	if len(topics) == 1 {
+		readerConfig.Topic = topics[0]
+	} else {
+		readerConfig.GroupTopics = topics
+	}
+
+	reader := kafka.NewReader(readerConfig)
+	defer func() { reader.Close() }()
+
+	delay := newBackoff(c.config.Consumer)
+	recreateAfter := c.config.Consumer.RecreateAfter
+	if recreateAfter < 1 {
+		recreateAfter = defaultRecreateAfter
+	}
 
 	for {
+		msg, err := reader.FetchMessage(ctx)
+		if err == nil {
+			if delay.failures > 0 {
+				log.Printf("Kafka consumer %s recovered after %d failed read(s)", groupID, delay.failures)
+			}
+			delay.reset()
+			c.metrics.update(groupID, topics, func(stats *ConsumerStats) {
+				stats.Consumed++
+				stats.ConsecutiveErrors, stats.BackoffMs = 0, 0
+			})
+			if err := c.deliver(ctx, groupID, msg, handle); err != nil {
+				return err
+			}
+			err = reader.CommitMessages(ctx, msg)
+			if err == nil {
+				continue
+			}
+			// Uncommitted records are delivered again, and the dedupe catches them
+			log.Printf("Kafka consumer %s failed to commit %s/%d@%d: %v", groupID, msg.Topic, msg.Partition, msg.Offset, err)
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		// A broken reader, or one that keeps failing, is replaced by a fresh one
+		wait := delay.next()
+		recreate := !retryable(err) || delay.failures%recreateAfter == 0
+		c.metrics.update(groupID, topics, func(stats *ConsumerStats) {
+			stats.Errors++
+			stats.ConsecutiveErrors = delay.failures
+			stats.BackoffMs = wait.Milliseconds()
+			stats.LastError, stats.LastErrorAt = err.Error(), time.Now()
+			if recreate {
+				stats.Recreated++
+			}
+		})
+		if recreate {
+			log.Printf("Kafka consumer %s recreating its reader after %v (failure %d); retrying in %s", groupID, err, delay.failures, wait.Round(time.Millisecond))
+			reader.Close()
+			reader = kafka.NewReader(readerConfig)
+		} else {
+			log.Printf("Kafka consumer %s failed to read: %v (failure %d); retrying in %s", groupID, err, delay.failures, wait.Round(time.Millisecond))
+		}
+
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
-		default:
-			msg, err := reader.ReadMessage(ctx)
+		case <-time.After(wait):
+		}
+	}
+}
+
+// ReadAllMessages reads every message currently stored in the conversation
+// topics, across all partitions, ordered by timestamp. It does not join a consumer group.
+func (c *Client) ReadAllMessages(ctx context.Context) ([]*types.ChatMessage, error) {
+	conn, err := kafka.DialContext(ctx, "tcp", c.config.Brokers[0])
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to Kafka: %w", err)
+	}
+	defer conn.Close()
+
+	var messages []*types.ChatMessage
+	for _, topic := range c.conversationTopics() {
+		partitions, err := conn.ReadPartitions(topic)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read partitions of %s: %w", topic, err)
+		}
+
+		for _, partition := range partitions {
+			partitionMessages, err := c.readPartition(ctx, topic, partition.ID)
 			if err != nil {
-				log.Printf("Error reading message: %v", err)
-				time.Sleep(time.Second)
-				continue
+				return nil, err
 			}
+			messages = append(messages, partitionMessages...)
+		}
+	}
 
-			var chatMsg types.ChatMessage
-			if err := chatMsg.FromJSON(msg.Value); err != nil {
-				log.Printf("Error unmarshaling message: %v", err)
-				continue
-			}
+	sort.SliceStable(messages, func(i, j int) bool {
+		return messages[i].Timestamp.Before(messages[j].Timestamp)
+	})
+	return messages, nil
+}
+
+// readPartition reads a single partition from the first to the last stored offset
+func (c *Client) readPartition(ctx context.Context, topic string, partition int) ([]*types.ChatMessage, error) {
+	conn, err := kafka.DialLeader(ctx, "tcp", c.config.Brokers[0], topic, partition)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s partition %d: %w", topic, partition, err)
+	}
+	first, last, err := conn.ReadOffsets()
+	conn.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read offsets of %s partition %d: %w", topic, partition, err)
+	}
+	if first >= last {
+		return nil, nil
+	}
 
-			log.Printf("Kafka consumed message in group %s: %s (type: %s, agent: %s)", groupID, chatMsg.Content, chatMsg.Type, chatMsg.AgentID)
+	reader := kafka.NewReader(kafka.ReaderConfig{
+		Brokers:   c.config.Brokers,
+		Topic:     topic,
+		Partition: partition,
+		MaxBytes:  10e6, // 10MB
+	})
+	defer reader.Close()
 
-			if err := handler(&chatMsg); err != nil {
-				log.Printf("Error handling message: %v", err)
-			}
+	if err := reader.SetOffset(first); err != nil {
+		return nil, fmt.Errorf("failed to seek %s partition %d: %w", topic, partition, err)
+	}
+
+	var messages []*types.ChatMessage
+	for offset := first; offset < last; {
+		msg, err := reader.ReadMessage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s partition %d: %w", topic, partition, err)
+		}
+		offset = msg.Offset + 1
+
+		chatMsg, err := c.serializer.Unmarshal(topic, msg.Value)
+		if err != nil {
+			log.Printf("Skipping malformed message at offset %d: %v", msg.Offset, err)
+			continue
 		}
+		if err := c.keys.verify(chatMsg.AgentID, msg); err != nil {
+			log.Printf("Skipping message at offset %d: %v", msg.Offset, err)
+			continue
+		}
+		messages = append(messages, chatMsg)
 	}
+
+	return messages, nil
 }
 
 // Close closes the Kafka client
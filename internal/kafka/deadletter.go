@@ -0,0 +1,119 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"runtime/debug"
+	"strconv"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// errUnreadable marks malformed records, which no retry can handle
+var errUnreadable = errors.New("unreadable record")
+
+// ErrPanicked marks records whose handler panicked. They go to the
+// dead-letter topic right away, as a retry would most likely panic again.
+var ErrPanicked = errors.New("handler panicked")
+
+// deliver hands a record to the handler, retrying failures with backoff, and
+// moves it to the dead-letter topic once the attempts run out. The record may
+// be committed afterwards unless an error is returned, which only happens
+// when ctx is done.
+func (c *Client) deliver(ctx context.Context, groupID string, msg kafka.Message, handle func(kafka.Message) error) error {
+	attempts := c.config.Consumer.HandlerAttempts
+	if attempts < 1 {
+		attempts = defaultHandlerAttempts
+	}
+	delay := newBackoff(c.config.Consumer)
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		if err = c.handleSafely(groupID, msg, handle); err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if errors.Is(err, errUnreadable) || errors.Is(err, errForged) || errors.Is(err, ErrPanicked) || attempt >= attempts {
+			break
+		}
+
+		wait := delay.next()
+		log.Printf("Kafka consumer %s failed to handle %s/%d@%d: %v (attempt %d of %d); retrying in %s", groupID, msg.Topic, msg.Partition, msg.Offset, err, attempt, attempts, wait.Round(time.Millisecond))
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+
+	c.metrics.update(groupID, nil, func(stats *ConsumerStats) {
+		stats.Failed++
+	})
+	return c.deadLetter(ctx, groupID, msg, err)
+}
+
+// handleSafely hands a record to the handler, turning a panic into an error
+// so that it doesn't take the consumer loop down
+func (c *Client) handleSafely(groupID string, msg kafka.Message, handle func(kafka.Message) error) (err error) {
+	defer func() {
+		recovered := recover()
+		if recovered == nil {
+			return
+		}
+		log.Printf("Kafka consumer %s panicked handling %s/%d@%d: %v\n%s", groupID, msg.Topic, msg.Partition, msg.Offset, recovered, debug.Stack())
+		c.metrics.update(groupID, nil, func(stats *ConsumerStats) {
+			stats.Panics++
+		})
+		err = fmt.Errorf("%w: %v", ErrPanicked, recovered)
+	}()
+	return handle(msg)
+}
+
+// deadLetter copies a record the handler gave up on to the dead-letter topic,
+// with headers saying where it came from and why it failed. Without a
+// dead-letter topic the record is dropped with a log line.
+func (c *Client) deadLetter(ctx context.Context, groupID string, msg kafka.Message, cause error) error {
+	topic := c.config.Topics.DeadLetter
+	if topic == "" {
+		log.Printf("Kafka consumer %s dropped %s/%d@%d: %v", groupID, msg.Topic, msg.Partition, msg.Offset, cause)
+		return nil
+	}
+
+	record := kafka.Message{
+		Topic: topic,
+		Key:   msg.Key,
+		Value: msg.Value,
+		Headers: append(msg.Headers[:len(msg.Headers):len(msg.Headers)],
+			kafka.Header{Key: "philoking-source-topic", Value: []byte(msg.Topic)},
+			kafka.Header{Key: "philoking-source-partition", Value: []byte(strconv.Itoa(msg.Partition))},
+			kafka.Header{Key: "philoking-source-offset", Value: []byte(strconv.FormatInt(msg.Offset, 10))},
+			kafka.Header{Key: "philoking-consumer-group", Value: []byte(groupID)},
+			kafka.Header{Key: "philoking-error", Value: []byte(cause.Error())},
+		),
+	}
+
+	// The record is only committed once it is safely in the dead-letter topic
+	delay := newBackoff(c.config.Consumer)
+	for {
+		err := c.producer.WriteMessages(ctx, record)
+		if err == nil {
+			log.Printf("Kafka consumer %s moved %s/%d@%d to %s: %v", groupID, msg.Topic, msg.Partition, msg.Offset, topic, cause)
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		wait := delay.next()
+		log.Printf("Kafka consumer %s failed to write to dead-letter topic %s: %v; retrying in %s", groupID, topic, err, wait.Round(time.Millisecond))
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"philoking/internal/config"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// fakeWriter records the records written, failing the first failures writes
+type fakeWriter struct {
+	mu       sync.Mutex
+	written  []kafka.Message
+	failures int
+}
+
+func (w *fakeWriter) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.failures > 0 {
+		w.failures--
+		return errors.New("broker unavailable")
+	}
+	w.written = append(w.written, msgs...)
+	return nil
+}
+
+func (w *fakeWriter) Close() error {
+	return nil
+}
+
+func TestDeliverDeadLetters(t *testing.T) {
+	errBusy := errors.New("busy")
+	tests := []struct {
+		name         string
+		handle       func(attempt int) error
+		noTopic      bool
+		writeFails   int
+		wantAttempts int
+		wantError    string // The philoking-error header; empty when nothing is dead-lettered
+		wantPanics   int64
+	}{
+		{name: "handled", handle: func(int) error { return nil }, wantAttempts: 1},
+		{
+			name: "handled on a retry",
+			handle: func(attempt int) error {
+				if attempt < 3 {
+					return errBusy
+				}
+				return nil
+			},
+			wantAttempts: 3,
+		},
+		{name: "attempts run out", handle: func(int) error { return errBusy }, wantAttempts: 3, wantError: "busy"},
+		{name: "unreadable", handle: func(int) error { return fmt.Errorf("%w: bad JSON", errUnreadable) }, wantAttempts: 1, wantError: "unreadable record: bad JSON"},
+		{name: "forged", handle: func(int) error { return fmt.Errorf("%w: bad signature", errForged) }, wantAttempts: 1, wantError: "forged record: bad signature"},
+		{name: "panic", handle: func(int) error { panic("nil map") }, wantAttempts: 1, wantError: "handler panicked: nil map", wantPanics: 1},
+		{name: "dead-letter topic unavailable", handle: func(int) error { return errUnreadable }, writeFails: 2, wantAttempts: 1, wantError: "unreadable record"},
+		{name: "no dead-letter topic", handle: func(int) error { return errUnreadable }, noTopic: true, wantAttempts: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			writer := &fakeWriter{failures: tt.writeFails}
+			cfg := config.KafkaConfig{Consumer: config.ConsumerConfig{InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond, HandlerAttempts: 3}}
+			if !tt.noTopic {
+				cfg.Topics.DeadLetter = "dead-letters"
+			}
+			c := &Client{producer: writer, config: cfg}
+
+			msg := kafka.Message{Topic: "chat", Partition: 2, Offset: 41, Key: []byte("c1"), Value: []byte(`{"id":"m1"}`),
+				Headers: []kafka.Header{{Key: signatureHeader, Value: []byte("sig")}}}
+			attempts := 0
+			err := c.deliver(context.Background(), "group", msg, func(kafka.Message) error {
+				attempts++
+				return tt.handle(attempts)
+			})
+			if err != nil {
+				t.Fatalf("deliver = %v, want the record committed", err)
+			}
+			if attempts != tt.wantAttempts {
+				t.Errorf("handled %d times, want %d", attempts, tt.wantAttempts)
+			}
+
+			if tt.wantError == "" {
+				if len(writer.written) != 0 {
+					t.Errorf("dead-lettered %+v", writer.written)
+				}
+				return
+			}
+			if len(writer.written) != 1 {
+				t.Fatalf("wrote %d records, want one dead letter", len(writer.written))
+			}
+			record := writer.written[0]
+			if record.Topic != "dead-letters" || string(record.Key) != "c1" || string(record.Value) != `{"id":"m1"}` {
+				t.Errorf("dead letter %+v", record)
+			}
+			headers := make(map[string]string)
+			for _, h := range record.Headers {
+				headers[h.Key] = string(h.Value)
+			}
+			want := map[string]string{
+				signatureHeader:              "sig",
+				"philoking-source-topic":     "chat",
+				"philoking-source-partition": "2",
+				"philoking-source-offset":    "41",
+				"philoking-consumer-group":   "group",
+				"philoking-error":            tt.wantError,
+			}
+			for key, value := range want {
+				if headers[key] != value {
+					t.Errorf("header %s = %q, want %q", key, headers[key], value)
+				}
+			}
+			if len(msg.Headers) != 1 {
+				t.Error("dead-lettering changed the headers of the original record")
+			}
+
+			stats := c.ConsumerStats()
+			if len(stats) != 1 || stats[0].Failed != 1 || stats[0].Panics != tt.wantPanics {
+				t.Errorf("consumer stats %+v", stats)
+			}
+		})
+	}
+}
+
+func TestDeliverStopsWithContext(t *testing.T) {
+	writer := &fakeWriter{failures: 1 << 30}
+	cfg := config.KafkaConfig{Consumer: config.ConsumerConfig{InitialBackoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond}}
+	cfg.Topics.DeadLetter = "dead-letters"
+	c := &Client{producer: writer, config: cfg}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	// Nothing may be committed while the record is neither handled nor dead-lettered
+	err := c.deliver(ctx, "group", kafka.Message{Topic: "chat"}, func(kafka.Message) error { return errUnreadable })
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("deliver = %v, want the context's error", err)
+	}
+}
@@ -0,0 +1,48 @@
+package kafka
+
+import "container/list"
+
+// dedupeWindow is the number of recent message IDs each consumer remembers
+const dedupeWindow = 1000
+
+// seenIDs is a fixed-size LRU set of recently consumed message IDs, used to
+// drop redeliveries after a rebalance. It is not safe for concurrent use;
+// every subscription owns its own.
+type seenIDs struct {
+	capacity int
+	order    *list.List               // Most recently seen at the front
+	entries  map[string]*list.Element // Message ID -> element in order
+}
+
+// newSeenIDs creates an LRU set that remembers up to capacity IDs
+func newSeenIDs(capacity int) *seenIDs {
+	return &seenIDs{
+		capacity: capacity,
+		order:    list.New(),
+		entries:  make(map[string]*list.Element, capacity),
+	}
+}
+
+// seen records an ID and reports whether it was already present
+func (s *seenIDs) seen(id string) bool {
+	if element, ok := s.entries[id]; ok {
+		s.order.MoveToFront(element)
+		return true
+	}
+
+	s.entries[id] = s.order.PushFront(id)
+	if s.order.Len() > s.capacity {
+		oldest := s.order.Back()
+		s.order.Remove(oldest)
+		delete(s.entries, oldest.Value.(string))
+	}
+	return false
+}
+
+// forget removes an ID, so a message that failed to be handled is accepted when it comes again
+func (s *seenIDs) forget(id string) {
+	if element, ok := s.entries[id]; ok {
+		s.order.Remove(element)
+		delete(s.entries, id)
+	}
+}
@@ -0,0 +1,211 @@
+package kafka
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strings"
+
+	"philoking/internal/config"
+	"philoking/internal/types"
+)
+
+// encryptedMagic starts every encrypted payload. Neither JSON nor the Avro
+// wire format can start with it.
+var encryptedMagic = []byte("PKE\x01")
+
+// KeyProvider supplies the AES keys that encrypt message payloads
+type KeyProvider interface {
+	// CurrentKey returns the key new messages are encrypted with
+	CurrentKey() (id string, key []byte, err error)
+	// Key returns a key by ID, to decrypt the messages encrypted with it
+	Key(id string) ([]byte, error)
+}
+
+// staticKeys provides the keys from the configuration and the keys file
+type staticKeys struct {
+	current string
+	keys    map[string][]byte
+}
+
+// NewKeyProvider loads the configured encryption keys
+func NewKeyProvider(cfg config.EncryptionConfig) (KeyProvider, error) {
+	encoded := make(map[string]string, len(cfg.Keys))
+	for id, key := range cfg.Keys {
+		encoded[id] = key
+	}
+	if cfg.KeysFile != "" {
+		if err := readKeysFile(cfg.KeysFile, encoded); err != nil {
+			return nil, err
+		}
+	}
+
+	p := &staticKeys{current: cfg.KeyID, keys: make(map[string][]byte, len(encoded))}
+	for id, value := range encoded {
+		if len(id) > 255 {
+			return nil, fmt.Errorf("encryption key id %q is too long", id)
+		}
+		key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("encryption key %s is not valid base64", id)
+		}
+		if _, err := aes.NewCipher(key); err != nil {
+			return nil, fmt.Errorf("encryption key %s: %w", id, err)
+		}
+		p.keys[id] = key
+	}
+	if _, ok := p.keys[p.current]; !ok {
+		return nil, fmt.Errorf("encryption key %q is not configured", p.current)
+	}
+	return p, nil
+}
+
+// readKeysFile adds the "id=base64key" lines of a file to keys; blank lines and lines starting with # are skipped
+func readKeysFile(path string, keys map[string]string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read encryption keys: %w", err)
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		id, key, ok := strings.Cut(text, "=")
+		if !ok || strings.TrimSpace(id) == "" {
+			return fmt.Errorf("%s:%d: want id=base64key", path, line)
+		}
+		keys[strings.TrimSpace(id)] = key
+	}
+	return scanner.Err()
+}
+
+// CurrentKey returns the configured key_id and its key
+func (p *staticKeys) CurrentKey() (string, []byte, error) {
+	return p.current, p.keys[p.current], nil
+}
+
+// Key returns a key by ID
+func (p *staticKeys) Key(id string) ([]byte, error) {
+	key, ok := p.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("unknown encryption key %q", id)
+	}
+	return key, nil
+}
+
+// encryptingSerializer seals the payloads of another serializer with AES-GCM.
+// A payload is the magic, the key ID (length-prefixed), the nonce and the
+// ciphertext; the topic is authenticated too, so payloads can't be moved
+// between topics.
+type encryptingSerializer struct {
+	inner           Serializer
+	keys            KeyProvider
+	acceptPlaintext bool
+}
+
+// Marshal serializes and encrypts a message
+func (s encryptingSerializer) Marshal(topic string, message *types.ChatMessage) ([]byte, error) {
+	plaintext, err := s.inner.Marshal(topic, message)
+	if err != nil {
+		return nil, err
+	}
+	return s.seal(topic, plaintext)
+}
+
+// Unmarshal decrypts and deserializes a message
+func (s encryptingSerializer) Unmarshal(topic string, data []byte) (*types.ChatMessage, error) {
+	plaintext, err := s.open(topic, data)
+	if err != nil {
+		return nil, err
+	}
+	return s.inner.Unmarshal(topic, plaintext)
+}
+
+// seal encrypts a payload with the current key
+func (s encryptingSerializer) seal(topic string, plaintext []byte) ([]byte, error) {
+	id, key, err := s.keys.CurrentKey()
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, 0, len(encryptedMagic)+1+len(id)+aead.NonceSize())
+	header = append(header, encryptedMagic...)
+	header = append(header, byte(len(id)))
+	header = append(header, id...)
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to create nonce: %w", err)
+	}
+	header = append(header, nonce...)
+	return aead.Seal(header, nonce, plaintext, []byte(topic)), nil
+}
+
+// open decrypts a payload with the key it names
+func (s encryptingSerializer) open(topic string, data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, encryptedMagic) {
+		if s.acceptPlaintext {
+			return data, nil
+		}
+		return nil, fmt.Errorf("message is not encrypted")
+	}
+
+	rest := data[len(encryptedMagic):]
+	if len(rest) < 1 || len(rest) < 1+int(rest[0]) {
+		return nil, fmt.Errorf("truncated encrypted message")
+	}
+	id := string(rest[1 : 1+int(rest[0])])
+	rest = rest[1+int(rest[0]):]
+
+	key, err := s.keys.Key(id)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) < aead.NonceSize() {
+		return nil, fmt.Errorf("truncated encrypted message")
+	}
+	plaintext, err := aead.Open(nil, rest[:aead.NonceSize()], rest[aead.NonceSize():], []byte(topic))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt message with key %s: %w", id, err)
+	}
+	return plaintext, nil
+}
+
+// sealPayload encrypts a payload that doesn't go through the serializer, when encryption is enabled
+func (c *Client) sealPayload(topic string, data []byte) ([]byte, error) {
+	if encrypting, ok := c.serializer.(encryptingSerializer); ok {
+		return encrypting.seal(topic, data)
+	}
+	return data, nil
+}
+
+// openPayload decrypts a payload sealed with sealPayload
+func (c *Client) openPayload(topic string, data []byte) ([]byte, error) {
+	if encrypting, ok := c.serializer.(encryptingSerializer); ok {
+		return encrypting.open(topic, data)
+	}
+	return data, nil
+}
+
+// newGCM creates an AES-GCM cipher
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
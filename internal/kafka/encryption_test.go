@@ -0,0 +1,146 @@
+package kafka
+
+import (
+	"bytes"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"philoking/internal/config"
+	"philoking/internal/types"
+)
+
+func testKey(b byte, size int) string {
+	return base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{b}, size))
+}
+
+func TestNewKeyProvider(t *testing.T) {
+	keysFile := filepath.Join(t.TempDir(), "keys")
+	if err := os.WriteFile(keysFile, []byte("# rotated in March\n\nold = "+testKey(2, 24)+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	badFile := filepath.Join(t.TempDir(), "bad")
+	if err := os.WriteFile(badFile, []byte("just-a-key\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		cfg     config.EncryptionConfig
+		wantErr string
+	}{
+		{name: "configured keys", cfg: config.EncryptionConfig{KeyID: "k1", Keys: map[string]string{"k1": testKey(1, 32), "k0": testKey(0, 16)}}},
+		{name: "key from the file", cfg: config.EncryptionConfig{KeyID: "old", KeysFile: keysFile}},
+		{name: "current key missing", cfg: config.EncryptionConfig{KeyID: "k2", Keys: map[string]string{"k1": testKey(1, 32)}}, wantErr: `"k2" is not configured`},
+		{name: "no key ID", cfg: config.EncryptionConfig{Keys: map[string]string{"k1": testKey(1, 32)}}, wantErr: "is not configured"},
+		{name: "not base64", cfg: config.EncryptionConfig{KeyID: "k1", Keys: map[string]string{"k1": "not base64!"}}, wantErr: "not valid base64"},
+		{name: "wrong key size", cfg: config.EncryptionConfig{KeyID: "k1", Keys: map[string]string{"k1": testKey(1, 20)}}, wantErr: "invalid key size"},
+		{name: "key ID too long", cfg: config.EncryptionConfig{KeyID: "k1", Keys: map[string]string{"k1": testKey(1, 32), strings.Repeat("x", 256): testKey(1, 32)}}, wantErr: "too long"},
+		{name: "malformed keys file", cfg: config.EncryptionConfig{KeyID: "k1", Keys: map[string]string{"k1": testKey(1, 32)}, KeysFile: badFile}, wantErr: ":1: want id=base64key"},
+		{name: "missing keys file", cfg: config.EncryptionConfig{KeyID: "k1", KeysFile: filepath.Join(t.TempDir(), "nothing")}, wantErr: "failed to read encryption keys"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys, err := NewKeyProvider(tt.cfg)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("NewKeyProvider = %v, want an error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if id, _, _ := keys.CurrentKey(); id != tt.cfg.KeyID {
+				t.Errorf("current key %q, want %q", id, tt.cfg.KeyID)
+			}
+		})
+	}
+}
+
+func TestEncryptingSerializer(t *testing.T) {
+	newSerializer := func(current string, acceptPlaintext bool) encryptingSerializer {
+		keys, err := NewKeyProvider(config.EncryptionConfig{KeyID: current, Keys: map[string]string{"k1": testKey(1, 32), "k2": testKey(2, 16)}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return encryptingSerializer{inner: jsonSerializer{}, keys: keys, acceptPlaintext: acceptPlaintext}
+	}
+	message := &types.ChatMessage{ID: "m1", Type: types.MessageTypeUser, Content: "a secret about Kant"}
+
+	s := newSerializer("k1", false)
+	sealed, err := s.Marshal("chat", message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(sealed, encryptedMagic) || bytes.Contains(sealed, []byte("Kant")) {
+		t.Fatalf("payload is not encrypted: %q", sealed)
+	}
+	again, _ := s.Marshal("chat", message)
+	if bytes.Equal(sealed, again) {
+		t.Error("two encryptions of the same message are equal; the nonce is reused")
+	}
+	plaintext, _ := jsonSerializer{}.Marshal("chat", message)
+
+	tampered := bytes.Clone(sealed)
+	tampered[len(tampered)-1] ^= 1
+	unknownKey := bytes.Clone(sealed)
+	unknownKey[len(encryptedMagic)+2] = 'x' // "k1" becomes "kx"
+
+	tests := []struct {
+		name       string
+		serializer encryptingSerializer
+		topic      string
+		data       []byte
+		wantErr    string
+	}{
+		{name: "round trip", serializer: s, topic: "chat", data: sealed},
+		{name: "after the key was rotated", serializer: newSerializer("k2", false), topic: "chat", data: sealed},
+		{name: "moved to another topic", serializer: s, topic: "control", data: sealed, wantErr: "failed to decrypt"},
+		{name: "tampered", serializer: s, topic: "chat", data: tampered, wantErr: "failed to decrypt"},
+		{name: "unknown key", serializer: s, topic: "chat", data: unknownKey, wantErr: `unknown encryption key "kx"`},
+		{name: "truncated key ID", serializer: s, topic: "chat", data: append(bytes.Clone(encryptedMagic), 9, 'k'), wantErr: "truncated"},
+		{name: "truncated nonce", serializer: s, topic: "chat", data: sealed[:len(encryptedMagic)+3+5], wantErr: "truncated"},
+		{name: "plaintext refused", serializer: s, topic: "chat", data: plaintext, wantErr: "not encrypted"},
+		{name: "plaintext accepted", serializer: newSerializer("k1", true), topic: "chat", data: plaintext},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.serializer.Unmarshal(tt.topic, tt.data)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Unmarshal = %v, want an error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.ID != message.ID || got.Content != message.Content {
+				t.Errorf("decrypted %+v", got)
+			}
+		})
+	}
+}
+
+func TestSealPayload(t *testing.T) {
+	plain := &Client{serializer: jsonSerializer{}}
+	if data, _ := plain.sealPayload("agent-messages", []byte("note")); string(data) != "note" {
+		t.Errorf("sealed without encryption: %q", data)
+	}
+
+	serializer, err := NewSerializer(config.SerializationConfig{Encryption: config.EncryptionConfig{Enabled: true, KeyID: "k1", Keys: map[string]string{"k1": testKey(1, 32)}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &Client{serializer: serializer}
+	sealed, err := c.sealPayload("agent-messages", []byte("note"))
+	if err != nil || bytes.Contains(sealed, []byte("note")) {
+		t.Fatalf("sealPayload = %q, %v", sealed, err)
+	}
+	if opened, err := c.openPayload("agent-messages", sealed); err != nil || string(opened) != "note" {
+		t.Errorf("openPayload = %q, %v", opened, err)
+	}
+}
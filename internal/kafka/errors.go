@@ -0,0 +1,72 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"philoking/internal/types"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// BrokerError is a write to Kafka that failed
+type BrokerError struct {
+	Topic string
+	Err   error
+}
+
+func (e *BrokerError) Error() string {
+	return fmt.Sprintf("failed to write to Kafka topic %s: %v", e.Topic, e.Err)
+}
+
+func (e *BrokerError) Unwrap() error {
+	return e.Err
+}
+
+// write sends records to a topic, reporting a failure as a BrokerError
+func (c *Client) write(ctx context.Context, topic string, records ...kafka.Message) error {
+	if err := c.producer.WriteMessages(ctx, records...); err != nil {
+		return &BrokerError{Topic: topic, Err: err}
+	}
+	return nil
+}
+
+// ErrorsEnabled reports whether agent failures are published for the error feed
+func (c *Client) ErrorsEnabled() bool {
+	return c.config.Topics.Errors != ""
+}
+
+// PublishError reports an agent failure on the errors topic
+func (c *Client) PublishError(ctx context.Context, event *types.ErrorEvent) error {
+	topic := c.config.Topics.Errors
+	if topic == "" {
+		return fmt.Errorf("the error feed is disabled (kafka.topics.errors is empty)")
+	}
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal error event: %w", err)
+	}
+	return c.write(ctx, topic, kafka.Message{
+		Topic: topic,
+		Key:   []byte(event.AgentID),
+		Value: data,
+	})
+}
+
+// SubscribeToErrors consumes the agent failures reported from now on
+func (c *Client) SubscribeToErrors(ctx context.Context, groupID string, handler func(*types.ErrorEvent) error) error {
+	topic := c.config.Topics.Errors
+	if topic == "" {
+		return fmt.Errorf("the error feed is disabled (kafka.topics.errors is empty)")
+	}
+
+	return c.consume(ctx, groupID, []string{topic}, kafka.LastOffset, func(msg kafka.Message) error {
+		var event types.ErrorEvent
+		if err := json.Unmarshal(msg.Value, &event); err != nil || event.Kind == "" {
+			return nil // Not an error event, e.g. on a shared topic
+		}
+		return handler(&event)
+	})
+}
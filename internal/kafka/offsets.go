@@ -0,0 +1,174 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"philoking/internal/types"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// StartPosition is where a consumer group starts reading the conversation
+type StartPosition struct {
+	Offset int64     // kafka.FirstOffset or kafka.LastOffset
+	At     time.Time // When set, the first message at or after this time instead of Offset
+	Reset  bool      // Move the group there even if it committed offsets before; otherwise only a new group starts there
+}
+
+// Replays reports whether the group reads messages from before it subscribed
+func (p StartPosition) Replays() bool {
+	return p.Offset == kafka.FirstOffset || !p.At.IsZero()
+}
+
+// ParseStartPosition reads a start_from setting:
+//
+//   - "" or "checkpoint" resumes from the offsets the group committed, starting at the earliest when new
+//   - "earliest" and "latest" move the group to the start or the end of the topics
+//   - a duration such as "1h" replays that window before now
+//   - an RFC 3339 timestamp starts at the first message at or after it
+func ParseStartPosition(value string, now time.Time) (StartPosition, error) {
+	switch value {
+	case "", "checkpoint":
+		return StartPosition{Offset: kafka.FirstOffset}, nil
+	case "earliest":
+		return StartPosition{Offset: kafka.FirstOffset, Reset: true}, nil
+	case "latest":
+		return StartPosition{Offset: kafka.LastOffset, Reset: true}, nil
+	}
+	if window, err := time.ParseDuration(value); err == nil {
+		if window <= 0 {
+			return StartPosition{}, fmt.Errorf("replay window %q must be positive", value)
+		}
+		return StartPosition{Offset: kafka.LastOffset, At: now.Add(-window), Reset: true}, nil
+	}
+	if at, err := time.Parse(time.RFC3339, value); err == nil {
+		return StartPosition{Offset: kafka.LastOffset, At: at, Reset: true}, nil
+	}
+	return StartPosition{}, fmt.Errorf("invalid start position %q: want checkpoint, earliest, latest, a duration or an RFC 3339 time", value)
+}
+
+// SubscribeFrom subscribes to the whole conversation with a consumer group
+// that starts reading at the given position
+func (c *Client) SubscribeFrom(ctx context.Context, groupID string, from StartPosition, handler func(*types.ChatMessage) error) error {
+	topics := c.conversationTopics()
+	if from.Reset {
+		// A group with active members refuses the commit; it then simply resumes where it was
+		if err := c.resetOffsets(ctx, groupID, topics, from); err != nil {
+			log.Printf("Failed to move consumer group %s to its start position: %v", groupID, err)
+		}
+	}
+	return c.subscribe(ctx, groupID, topics, from.Offset, handler)
+}
+
+// resetOffsets commits the offsets of a start position for every partition of
+// the topics, so the group's next reader starts there
+func (c *Client) resetOffsets(ctx context.Context, groupID string, topics []string, from StartPosition) error {
+	client := &kafka.Client{Addr: kafka.TCP(c.config.Brokers...), Timeout: 10 * time.Second}
+
+	metadata, err := client.Metadata(ctx, &kafka.MetadataRequest{Topics: topics})
+	if err != nil {
+		return fmt.Errorf("failed to read topic metadata: %w", err)
+	}
+	partitions := make(map[string][]int)
+	for _, topic := range metadata.Topics {
+		if topic.Error != nil {
+			continue // Not created yet; a new topic has no history to skip
+		}
+		for _, partition := range topic.Partitions {
+			partitions[topic.Name] = append(partitions[topic.Name], partition.ID)
+		}
+	}
+	if len(partitions) == 0 {
+		return nil
+	}
+
+	request := func(partition int) kafka.OffsetRequest {
+		if from.Offset == kafka.FirstOffset {
+			return kafka.FirstOffsetOf(partition)
+		}
+		return kafka.LastOffsetOf(partition)
+	}
+	offsets, err := listOffsets(ctx, client, partitions, request)
+	if err != nil {
+		return err
+	}
+	if !from.At.IsZero() {
+		// Partitions without messages since then keep the end offset
+		found, err := listOffsets(ctx, client, partitions, func(partition int) kafka.OffsetRequest {
+			return kafka.TimeOffsetOf(partition, from.At)
+		})
+		if err != nil {
+			return err
+		}
+		for topic, byPartition := range found {
+			for partition, offset := range byPartition {
+				if offsets[topic] == nil {
+					offsets[topic] = make(map[int]int64)
+				}
+				offsets[topic][partition] = offset
+			}
+		}
+	}
+
+	commits := make(map[string][]kafka.OffsetCommit, len(offsets))
+	for topic, byPartition := range offsets {
+		for partition, offset := range byPartition {
+			commits[topic] = append(commits[topic], kafka.OffsetCommit{Partition: partition, Offset: offset})
+		}
+	}
+	response, err := client.OffsetCommit(ctx, &kafka.OffsetCommitRequest{
+		GroupID:      c.config.GroupPrefix + groupID,
+		GenerationID: -1, // Committing outside a group generation
+		Topics:       commits,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to commit offsets: %w", err)
+	}
+	for topic, partitions := range response.Topics {
+		for _, partition := range partitions {
+			if partition.Error != nil {
+				return fmt.Errorf("failed to commit offset of %s/%d: %w", topic, partition.Partition, partition.Error)
+			}
+		}
+	}
+	return nil
+}
+
+// listOffsets looks up one offset per partition, by topic and partition.
+// Lookups by time that find no message are left out.
+func listOffsets(ctx context.Context, client *kafka.Client, partitions map[string][]int, request func(int) kafka.OffsetRequest) (map[string]map[int]int64, error) {
+	requests := make(map[string][]kafka.OffsetRequest, len(partitions))
+	for topic, ids := range partitions {
+		for _, id := range ids {
+			requests[topic] = append(requests[topic], request(id))
+		}
+	}
+	response, err := client.ListOffsets(ctx, &kafka.ListOffsetsRequest{Topics: requests})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list offsets: %w", err)
+	}
+
+	offsets := make(map[string]map[int]int64, len(response.Topics))
+	for topic, partitionOffsets := range response.Topics {
+		offsets[topic] = make(map[int]int64, len(partitionOffsets))
+		for _, partition := range partitionOffsets {
+			if partition.Error != nil {
+				return nil, fmt.Errorf("failed to list offsets of %s/%d: %w", topic, partition.Partition, partition.Error)
+			}
+			switch {
+			case len(partition.Offsets) > 0:
+				for offset := range partition.Offsets {
+					offsets[topic][partition.Partition] = offset
+				}
+			case partition.FirstOffset >= 0:
+				offsets[topic][partition.Partition] = partition.FirstOffset
+			case partition.LastOffset >= 0:
+				offsets[topic][partition.Partition] = partition.LastOffset
+			}
+		}
+	}
+	return offsets, nil
+}
@@ -0,0 +1,57 @@
+package kafka
+
+import (
+	"fmt"
+
+	"philoking/internal/config"
+	"philoking/internal/types"
+)
+
+// Serializer converts chat messages to and from their Kafka wire format
+type Serializer interface {
+	Marshal(topic string, message *types.ChatMessage) ([]byte, error)
+	Unmarshal(topic string, data []byte) (*types.ChatMessage, error)
+}
+
+// NewSerializer creates the serializer for the configured wire format,
+// encrypting payloads when encryption is enabled
+func NewSerializer(cfg config.SerializationConfig) (Serializer, error) {
+	var serializer Serializer
+	switch cfg.Format {
+	case "json", "":
+		serializer = jsonSerializer{}
+	case "avro":
+		if cfg.SchemaRegistry.URL == "" {
+			return nil, fmt.Errorf("the avro format needs a schema registry url")
+		}
+		serializer = newAvroSerializer(newSchemaRegistry(cfg.SchemaRegistry))
+	default:
+		return nil, fmt.Errorf("unsupported serialization format %q", cfg.Format)
+	}
+
+	if !cfg.Encryption.Enabled {
+		return serializer, nil
+	}
+	keys, err := NewKeyProvider(cfg.Encryption)
+	if err != nil {
+		return nil, err
+	}
+	return encryptingSerializer{inner: serializer, keys: keys, acceptPlaintext: cfg.Encryption.AcceptPlaintext}, nil
+}
+
+// jsonSerializer is the default, human-readable wire format
+type jsonSerializer struct{}
+
+// Marshal encodes a message as JSON
+func (jsonSerializer) Marshal(topic string, message *types.ChatMessage) ([]byte, error) {
+	return message.ToJSON()
+}
+
+// Unmarshal decodes a JSON message
+func (jsonSerializer) Unmarshal(topic string, data []byte) (*types.ChatMessage, error) {
+	var message types.ChatMessage
+	if err := message.FromJSON(data); err != nil {
+		return nil, err
+	}
+	return &message, nil
+}
@@ -0,0 +1,142 @@
+package kafka
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+
+	"philoking/internal/config"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// signatureHeader carries the base64 signature of a record's value
+const signatureHeader = "philoking-signature"
+
+// errForged marks records whose signature doesn't match the agent they claim to come from
+var errForged = errors.New("forged record")
+
+// agentKey signs and verifies the messages of one agent
+type agentKey struct {
+	algorithm string
+	secret    []byte             // HMAC
+	private   ed25519.PrivateKey // Nil when this replica can only verify
+	public    ed25519.PublicKey
+}
+
+// Keyring holds the signing keys of the agents. Messages agents publish
+// with PublishSigned are signed with their key and must carry a valid
+// signature when consumed; agents without a key are not checked.
+type Keyring struct {
+	keys map[string]*agentKey // By agent ID
+}
+
+// NewKeyring loads the signing keys of the configured agents
+func NewKeyring(agents []config.AgentConfig) (*Keyring, error) {
+	k := &Keyring{keys: make(map[string]*agentKey)}
+	for _, agent := range agents {
+		signing := agent.Signing
+		if signing.Algorithm == "" {
+			continue
+		}
+		if err := signing.Validate(); err != nil {
+			return nil, fmt.Errorf("agent %s: signing: %w", agent.ID, err)
+		}
+
+		key := &agentKey{algorithm: signing.Algorithm}
+		switch signing.Algorithm {
+		case "hmac":
+			key.secret = []byte(signing.Key)
+		case "ed25519":
+			if signing.Key != "" {
+				raw, _ := base64.StdEncoding.DecodeString(signing.Key)
+				if len(raw) == ed25519.SeedSize {
+					raw = ed25519.NewKeyFromSeed(raw)
+				}
+				key.private = ed25519.PrivateKey(raw)
+				key.public = key.private.Public().(ed25519.PublicKey)
+			}
+			if signing.PublicKey != "" {
+				public, _ := base64.StdEncoding.DecodeString(signing.PublicKey)
+				if key.public != nil && !key.public.Equal(ed25519.PublicKey(public)) {
+					return nil, fmt.Errorf("agent %s: signing: public_key does not match key", agent.ID)
+				}
+				key.public = public
+			}
+		}
+		k.keys[agent.ID] = key
+	}
+	return k, nil
+}
+
+// UseKeyring signs published agent messages and verifies consumed ones
+func (c *Client) UseKeyring(keys *Keyring) {
+	c.keys = keys
+}
+
+// sign returns the signature header for a record published by an agent, if it signs its messages
+func (k *Keyring) sign(agentID string, value []byte) (kafka.Header, bool, error) {
+	if k == nil || agentID == "" {
+		return kafka.Header{}, false, nil
+	}
+	key, ok := k.keys[agentID]
+	if !ok {
+		return kafka.Header{}, false, nil
+	}
+
+	var signature []byte
+	switch key.algorithm {
+	case "hmac":
+		mac := hmac.New(sha256.New, key.secret)
+		mac.Write(value)
+		signature = mac.Sum(nil)
+	case "ed25519":
+		if key.private == nil {
+			return kafka.Header{}, false, fmt.Errorf("no private key to sign messages of agent %s", agentID)
+		}
+		signature = ed25519.Sign(key.private, value)
+	}
+	return kafka.Header{Key: signatureHeader, Value: []byte(base64.StdEncoding.EncodeToString(signature))}, true, nil
+}
+
+// verify checks the signature of a record that claims to come from an agent
+func (k *Keyring) verify(agentID string, msg kafka.Message) error {
+	if k == nil || agentID == "" {
+		return nil
+	}
+	key, ok := k.keys[agentID]
+	if !ok {
+		return nil
+	}
+
+	var encoded []byte
+	for _, header := range msg.Headers {
+		if header.Key == signatureHeader {
+			encoded = header.Value
+		}
+	}
+	if encoded == nil {
+		return fmt.Errorf("%w: message of agent %s is not signed", errForged, agentID)
+	}
+	signature, err := base64.StdEncoding.DecodeString(string(encoded))
+	if err != nil {
+		return fmt.Errorf("%w: malformed signature on message of agent %s", errForged, agentID)
+	}
+
+	var valid bool
+	switch key.algorithm {
+	case "hmac":
+		mac := hmac.New(sha256.New, key.secret)
+		mac.Write(msg.Value)
+		valid = hmac.Equal(signature, mac.Sum(nil))
+	case "ed25519":
+		valid = ed25519.Verify(key.public, msg.Value, signature)
+	}
+	if !valid {
+		return fmt.Errorf("%w: invalid signature on message of agent %s", errForged, agentID)
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package kafka
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"testing"
+
+	"philoking/internal/config"
+
+	"github.com/segmentio/kafka-go"
+)
+
+func TestKeyringSignAndVerify(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seed := base64.StdEncoding.EncodeToString(private.Seed())
+	publicKey := base64.StdEncoding.EncodeToString(public)
+
+	signer, err := NewKeyring([]config.AgentConfig{
+		{ID: "hmac-agent", Signing: config.SigningConfig{Algorithm: "hmac", Key: "a shared secret of some length"}},
+		{ID: "ed-agent", Signing: config.SigningConfig{Algorithm: "ed25519", Key: seed}},
+		{ID: "open-agent"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A replica that doesn't run ed-agent only has its public key
+	verifier, err := NewKeyring([]config.AgentConfig{
+		{ID: "hmac-agent", Signing: config.SigningConfig{Algorithm: "hmac", Key: "a shared secret of some length"}},
+		{ID: "ed-agent", Signing: config.SigningConfig{Algorithm: "ed25519", PublicKey: publicKey}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	value := []byte(`{"content":"hello"}`)
+	sign := func(agentID string) []kafka.Header {
+		header, signed, err := signer.sign(agentID, value)
+		if err != nil {
+			t.Fatalf("sign %s: %v", agentID, err)
+		}
+		if !signed {
+			return nil
+		}
+		return []kafka.Header{header}
+	}
+
+	tests := []struct {
+		name    string
+		agentID string
+		headers []kafka.Header
+		value   []byte
+		forged  bool
+	}{
+		{name: "hmac signed", agentID: "hmac-agent", headers: sign("hmac-agent"), value: value},
+		{name: "ed25519 signed", agentID: "ed-agent", headers: sign("ed-agent"), value: value},
+		{name: "agent without key", agentID: "open-agent", value: value},
+		{name: "unknown sender", agentID: "user-1", value: value},
+		{name: "unsigned", agentID: "hmac-agent", value: value, forged: true},
+		{name: "tampered value", agentID: "ed-agent", headers: sign("ed-agent"), value: []byte(`{"content":"bye"}`), forged: true},
+		{name: "signed by another agent", agentID: "ed-agent", headers: sign("hmac-agent"), value: value, forged: true},
+		{name: "malformed signature", agentID: "hmac-agent", headers: []kafka.Header{{Key: signatureHeader, Value: []byte("%%%")}}, value: value, forged: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := verifier.verify(tt.agentID, kafka.Message{Value: tt.value, Headers: tt.headers})
+			if tt.forged != errors.Is(err, errForged) {
+				t.Errorf("verify = %v, want forged %v", err, tt.forged)
+			}
+		})
+	}
+}
+
+func TestKeyringCannotSignWithPublicKeyOnly(t *testing.T) {
+	public, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, err := NewKeyring([]config.AgentConfig{
+		{ID: "ed-agent", Signing: config.SigningConfig{Algorithm: "ed25519", PublicKey: base64.StdEncoding.EncodeToString(public)}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := keys.sign("ed-agent", []byte("value")); err == nil {
+		t.Error("sign without a private key succeeded")
+	}
+}
+
+func TestNilKeyringChecksNothing(t *testing.T) {
+	var keys *Keyring
+	if _, signed, err := keys.sign("agent", []byte("value")); signed || err != nil {
+		t.Errorf("sign = %v, %v; want unsigned", signed, err)
+	}
+	if err := keys.verify("agent", kafka.Message{Value: []byte("value")}); err != nil {
+		t.Errorf("verify = %v", err)
+	}
+}
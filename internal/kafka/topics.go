@@ -0,0 +1,126 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"philoking/internal/types"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// The topic layout splits traffic by role:
+//   - chat messages: what users and the moderator say
+//   - chat responses: what agents say
+//   - control: housekeeping events such as message deletions, whiteboard edits and alerts
+//   - presence: WebSocket users coming and going
+//
+// Pointing several roles at the same topic name gives the single-topic layout
+// of older deployments; the typed helpers below filter by message type so
+// they behave the same either way.
+
+// topicFor returns the topic a chat message is published to
+func (c *Client) topicFor(message *types.ChatMessage) string {
+	switch {
+	case isResponse(message):
+		return c.config.Topics.ChatResponses
+	case isControl(message):
+		return c.config.Topics.Control
+	default:
+		return c.config.Topics.ChatMessages
+	}
+}
+
+// conversationTopics returns the distinct topics that together hold the conversation
+func (c *Client) conversationTopics() []string {
+	return distinct(c.config.Topics.ChatMessages, c.config.Topics.ChatResponses)
+}
+
+// ConversationTopics returns the names of the topics that hold the conversation
+func (c *Client) ConversationTopics() []string {
+	return c.conversationTopics()
+}
+
+// SubscribeToChatMessages consumes what users and the moderator say, from the start of the topic
+func (c *Client) SubscribeToChatMessages(ctx context.Context, groupID string, handler func(*types.ChatMessage) error) error {
+	return c.subscribe(ctx, groupID, []string{c.config.Topics.ChatMessages}, kafka.FirstOffset, func(message *types.ChatMessage) error {
+		if isResponse(message) || isControl(message) {
+			return nil
+		}
+		return handler(message)
+	})
+}
+
+// SubscribeToChatResponses consumes what agents say, from the start of the topic
+func (c *Client) SubscribeToChatResponses(ctx context.Context, groupID string, handler func(*types.ChatMessage) error) error {
+	return c.subscribe(ctx, groupID, []string{c.config.Topics.ChatResponses}, kafka.FirstOffset, func(message *types.ChatMessage) error {
+		if !isResponse(message) {
+			return nil
+		}
+		return handler(message)
+	})
+}
+
+// SubscribeToControl consumes control events published from now on
+func (c *Client) SubscribeToControl(ctx context.Context, groupID string, handler func(*types.ChatMessage) error) error {
+	return c.subscribe(ctx, groupID, []string{c.config.Topics.Control}, kafka.LastOffset, func(message *types.ChatMessage) error {
+		if !isControl(message) {
+			return nil
+		}
+		return handler(message)
+	})
+}
+
+// PublishPresence announces a user coming online or going offline
+func (c *Client) PublishPresence(ctx context.Context, event *types.PresenceEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal presence event: %w", err)
+	}
+
+	return c.write(ctx, c.config.Topics.Presence, kafka.Message{
+		Topic: c.config.Topics.Presence,
+		Key:   []byte(event.UserID),
+		Value: data,
+	})
+}
+
+// SubscribeToPresence consumes presence events published from now on
+func (c *Client) SubscribeToPresence(ctx context.Context, groupID string, handler func(*types.PresenceEvent) error) error {
+	return c.consume(ctx, groupID, []string{c.config.Topics.Presence}, kafka.LastOffset, func(msg kafka.Message) error {
+		var event types.PresenceEvent
+		if err := json.Unmarshal(msg.Value, &event); err != nil || event.Status == "" {
+			return nil // Not a presence event, e.g. on a shared single-topic layout
+		}
+		return handler(&event)
+	})
+}
+
+// isResponse reports whether a message was said by an agent
+func isResponse(message *types.ChatMessage) bool {
+	return message.Type == types.MessageTypeAgent || message.Type == types.MessageTypeQuestion || message.Type == types.MessageTypeScore
+}
+
+// isControl reports whether a message is a control event for clients
+func isControl(message *types.ChatMessage) bool {
+	return message.Type == types.MessageTypeDeletion || message.Type == types.MessageTypeWhiteboard || message.Type == types.MessageTypeAlert
+}
+
+// distinct returns the non-empty names in order, without duplicates
+func distinct(names ...string) []string {
+	var topics []string
+	for _, name := range names {
+		if name == "" {
+			continue
+		}
+		duplicate := false
+		for _, topic := range topics {
+			duplicate = duplicate || topic == name
+		}
+		if !duplicate {
+			topics = append(topics, name)
+		}
+	}
+	return topics
+}
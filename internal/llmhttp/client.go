@@ -0,0 +1,114 @@
+package llmhttp
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"philoking/internal/config"
+)
+
+// DefaultTimeout bounds a whole LLM request made by a client without tuned timeouts
+const DefaultTimeout = 30 * time.Second
+
+// NewClient creates the HTTP client shared by all LLM agents. Its pooled
+// transport honours the configured egress proxy, extra trusted certificates,
+// connection limits and timeouts, so dozens of agents reuse a handful of
+// keep-alive connections instead of exhausting sockets.
+func NewClient(cfg config.LLMHTTPConfig) (*http.Client, error) {
+	dialer := &net.Dialer{
+		Timeout:   cfg.ConnectTimeout,
+		KeepAlive: 30 * time.Second,
+	}
+
+	transport := &http.Transport{
+		DialContext:           dialer.DialContext,
+		MaxIdleConns:          cfg.MaxIdleConns,
+		MaxIdleConnsPerHost:   cfg.MaxIdleConnsPerHost,
+		MaxConnsPerHost:       cfg.MaxConnsPerHost,
+		IdleConnTimeout:       cfg.IdleConnTimeout,
+		TLSHandshakeTimeout:   cfg.ConnectTimeout,
+		ResponseHeaderTimeout: cfg.ReadTimeout,
+		ExpectContinueTimeout: time.Second,
+		ForceAttemptHTTP2:     cfg.HTTP2,
+	}
+	if !cfg.HTTP2 {
+		// A non-nil empty map disables the automatic HTTP/2 upgrade
+		transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+
+	proxy, err := proxyFunc(cfg.ProxyURL)
+	if err != nil {
+		return nil, err
+	}
+	transport.Proxy = proxy
+
+	if cfg.CABundle != "" {
+		pool, err := certPool(cfg.CABundle)
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+	}
+
+	// Requests are bounded by the connect and read timeouts and by the caller's context
+	return &http.Client{Transport: transport}, nil
+}
+
+// proxyFunc routes requests through the configured proxy, or through the one
+// named by HTTPS_PROXY, HTTP_PROXY and NO_PROXY when none is configured
+func proxyFunc(proxyURL string) (func(*http.Request) (*url.URL, error), error) {
+	if proxyURL == "" {
+		return http.ProxyFromEnvironment, nil
+	}
+
+	u, err := url.Parse(proxyURL)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid proxy url %q", proxyURL)
+	}
+	return http.ProxyURL(u), nil
+}
+
+// certPool returns the system roots extended with the certificates in a PEM bundle,
+// so TLS-intercepting firewalls can be trusted without dropping the public CAs
+func certPool(bundle string) (*x509.CertPool, error) {
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+
+	pem, err := os.ReadFile(bundle)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in CA bundle %s", bundle)
+	}
+	return pool, nil
+}
+
+// SetHeaders adds the extra headers configured for a provider to a request.
+// Values may reference environment variables, e.g. "${GATEWAY_TOKEN}".
+func SetHeaders(req *http.Request, cfg config.LLMHTTPConfig, provider string) {
+	for name, value := range cfg.Headers[provider] {
+		req.Header.Set(name, os.ExpandEnv(value))
+	}
+}
+
+// WithoutReadTimeout returns a client sharing the connection settings of
+// client but without its read timeout, for calls such as model downloads and
+// loads that may take minutes before the server answers; bound them with a context
+func WithoutReadTimeout(client *http.Client) *http.Client {
+	transport, ok := client.Transport.(*http.Transport)
+	if !ok {
+		return client
+	}
+	transport = transport.Clone()
+	transport.ResponseHeaderTimeout = 0
+	return &http.Client{Transport: transport}
+}
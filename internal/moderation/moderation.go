@@ -0,0 +1,231 @@
+// Package moderation filters the messages users send before they reach the
+// conversation. Word lists and an optional moderation API reject messages
+// that break the rules and flag borderline ones for admins.
+package moderation
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"philoking/internal/config"
+)
+
+// maxFlags bounds the flagged messages kept for admins
+const maxFlags = 200
+
+// Actions the filter takes on a message
+const (
+	Allow  = "allow"
+	Flag   = "flag"   // Posted, but shown to admins
+	Reject = "reject" // Not posted
+)
+
+// Verdict is the filter's decision on a message
+type Verdict struct {
+	Action string `json:"action"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// RejectedError is returned for a message the filter rejected; Message is
+// meant for the sender
+type RejectedError struct {
+	Message string
+	Reason  string
+}
+
+func (e *RejectedError) Error() string {
+	return "message rejected: " + e.Reason
+}
+
+// FlaggedMessage is a borderline message that was posted and needs an admin's eye
+type FlaggedMessage struct {
+	MessageID      string    `json:"message_id"`
+	ConversationID string    `json:"conversation_id"`
+	UserID         string    `json:"user_id"`
+	Content        string    `json:"content"`
+	Reason         string    `json:"reason"`
+	FlaggedAt      time.Time `json:"flagged_at"`
+}
+
+// Filter checks user messages against the word lists and the moderation API
+type Filter struct {
+	config     config.ModerationConfig
+	blocked    *regexp.Regexp // Nil without blocked words
+	flagged    *regexp.Regexp // Nil without flagged words
+	httpClient *http.Client
+
+	mu    sync.Mutex
+	flags []FlaggedMessage // Oldest first
+}
+
+// New creates a filter from configuration; it returns nil when moderation is disabled
+func New(cfg config.ModerationConfig) *Filter {
+	if !cfg.Enabled {
+		return nil
+	}
+	return &Filter{
+		config:     cfg,
+		blocked:    wordPattern(cfg.BlockedWords),
+		flagged:    wordPattern(cfg.FlaggedWords),
+		httpClient: &http.Client{Timeout: cfg.API.Timeout},
+	}
+}
+
+// wordPattern matches any of the words or phrases as a whole, ignoring case
+func wordPattern(words []string) *regexp.Regexp {
+	var alternatives []string
+	for _, word := range words {
+		if word = strings.TrimSpace(word); word != "" {
+			alternatives = append(alternatives, regexp.QuoteMeta(word))
+		}
+	}
+	if len(alternatives) == 0 {
+		return nil
+	}
+	return regexp.MustCompile(`(?i)\b(?:` + strings.Join(alternatives, "|") + `)\b`)
+}
+
+// Check decides what happens to a message; a rejection comes with a
+// *RejectedError. When the moderation API fails, the word lists decide.
+func (f *Filter) Check(ctx context.Context, content string) (Verdict, error) {
+	if f.blocked != nil && f.blocked.MatchString(content) {
+		return f.reject("blocked word")
+	}
+
+	verdict := Verdict{Action: Allow}
+	if f.flagged != nil && f.flagged.MatchString(content) {
+		verdict = Verdict{Action: Flag, Reason: "flagged word"}
+	}
+
+	if f.config.API.URL != "" {
+		category, score, err := f.score(ctx, content)
+		switch {
+		case err != nil:
+			log.Printf("Moderation API failed, checking the word lists only: %v", err)
+		case score >= f.config.API.BlockThreshold:
+			return f.reject(category)
+		case score >= f.config.API.FlagThreshold && verdict.Action == Allow:
+			verdict = Verdict{Action: Flag, Reason: fmt.Sprintf("%s (%.2f)", category, score)}
+		}
+	}
+	return verdict, nil
+}
+
+// reject returns the verdict and error for a rejected message
+func (f *Filter) reject(reason string) (Verdict, error) {
+	return Verdict{Action: Reject, Reason: reason}, &RejectedError{Message: f.config.RejectionMessage, Reason: reason}
+}
+
+// AddFlag keeps a posted message that was flagged, dropping the oldest beyond maxFlags
+func (f *Filter) AddFlag(flag FlaggedMessage) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.flags = append(f.flags, flag)
+	if len(f.flags) > maxFlags {
+		f.flags = append([]FlaggedMessage(nil), f.flags[len(f.flags)-maxFlags:]...)
+	}
+}
+
+// Flags returns the flagged messages, newest first
+func (f *Filter) Flags() []FlaggedMessage {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	flags := make([]FlaggedMessage, 0, len(f.flags))
+	for i := len(f.flags) - 1; i >= 0; i-- {
+		flags = append(flags, f.flags[i])
+	}
+	return flags
+}
+
+// ForgetUser drops the flagged messages of a user and returns how many were dropped
+func (f *Filter) ForgetUser(userID string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	kept := make([]FlaggedMessage, 0, len(f.flags))
+	for _, flag := range f.flags {
+		if flag.UserID != userID {
+			kept = append(kept, flag)
+		}
+	}
+	forgotten := len(f.flags) - len(kept)
+	f.flags = kept
+	return forgotten
+}
+
+// moderationResponse is the answer of an OpenAI-compatible moderation API
+type moderationResponse struct {
+	Results []struct {
+		Flagged        bool               `json:"flagged"`
+		CategoryScores map[string]float64 `json:"category_scores"`
+	} `json:"results"`
+}
+
+// score asks the moderation API about a message and returns its worst category and that category's score
+func (f *Filter) score(ctx context.Context, content string) (string, float64, error) {
+	request := map[string]string{"input": content}
+	if f.config.API.Model != "" {
+		request["model"] = f.config.API.Model
+	}
+	body, err := json.Marshal(request)
+	if err != nil {
+		return "", 0, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, f.config.API.URL, bytes.NewReader(body))
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to create moderation request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if f.config.API.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+f.config.API.APIKey)
+	}
+
+	resp, err := f.httpClient.Do(req)
+	if err != nil {
+		return "", 0, fmt.Errorf("moderation request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return "", 0, fmt.Errorf("moderation API error: %d - %s", resp.StatusCode, string(data))
+	}
+
+	var result moderationResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", 0, fmt.Errorf("failed to decode moderation response: %w", err)
+	}
+	if len(result.Results) == 0 {
+		return "", 0, fmt.Errorf("moderation response has no results")
+	}
+
+	// The worst category decides; sorting keeps ties deterministic
+	categories := make([]string, 0, len(result.Results[0].CategoryScores))
+	for category := range result.Results[0].CategoryScores {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+	worst, score := "", 0.0
+	for _, category := range categories {
+		if s := result.Results[0].CategoryScores[category]; s > score {
+			worst, score = category, s
+		}
+	}
+	if result.Results[0].Flagged && score < f.config.API.FlagThreshold {
+		worst, score = "flagged", f.config.API.FlagThreshold
+	}
+	return worst, score, nil
+}
@@ -0,0 +1,26 @@
+package moderation
+
+import (
+	"fmt"
+	"testing"
+
+	"philoking/internal/config"
+)
+
+func TestFilterForgetUser(t *testing.T) {
+	f := New(config.ModerationConfig{Enabled: true})
+	for i, userID := range []string{"alice", "bob", "alice"} {
+		f.AddFlag(FlaggedMessage{MessageID: fmt.Sprint(i), UserID: userID, Content: "borderline"})
+	}
+
+	if forgotten := f.ForgetUser("alice"); forgotten != 2 {
+		t.Errorf("forgotten = %d, want 2", forgotten)
+	}
+	flags := f.Flags()
+	if len(flags) != 1 || flags[0].UserID != "bob" {
+		t.Errorf("flags = %+v, want only bob's", flags)
+	}
+	if forgotten := f.ForgetUser("carol"); forgotten != 0 {
+		t.Errorf("forgotten = %d for a user without flags", forgotten)
+	}
+}
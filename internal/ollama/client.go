@@ -0,0 +1,203 @@
+package ollama
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"philoking/internal/config"
+	"philoking/internal/llmhttp"
+)
+
+// ErrModelNotFound is returned when a model is not installed on the server
+var ErrModelNotFound = errors.New("model not found")
+
+// Client talks to the model management endpoints of an Ollama server
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	httpConfig config.LLMHTTPConfig // Extra headers for the "ollama" provider
+}
+
+// PullProgress is one status update of a model download
+type PullProgress struct {
+	Status    string `json:"status"`
+	Digest    string `json:"digest,omitempty"`
+	Total     int64  `json:"total,omitempty"`
+	Completed int64  `json:"completed,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// Percent returns how much of the current layer has been downloaded, or -1
+// when the status has no size
+func (p PullProgress) Percent() int {
+	if p.Total <= 0 {
+		return -1
+	}
+	return int(p.Completed * 100 / p.Total)
+}
+
+// Model is a model installed on the Ollama server
+type Model struct {
+	Name       string       `json:"name"`
+	Size       int64        `json:"size"`
+	Digest     string       `json:"digest"`
+	ModifiedAt time.Time    `json:"modified_at"`
+	Details    ModelDetails `json:"details"`
+}
+
+// ModelDetails describes a model's architecture
+type ModelDetails struct {
+	Family            string `json:"family,omitempty"`
+	ParameterSize     string `json:"parameter_size,omitempty"`
+	QuantizationLevel string `json:"quantization_level,omitempty"`
+}
+
+// NewClient creates a client for the Ollama server at baseURL
+func NewClient(baseURL string, httpClient *http.Client, httpConfig config.LLMHTTPConfig) *Client {
+	return &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: httpClient,
+		httpConfig: httpConfig,
+	}
+}
+
+// Pull downloads a model, or checks that it is up to date, reporting each
+// status update to progress
+func (c *Client) Pull(ctx context.Context, model string, progress func(PullProgress)) error {
+	resp, err := c.post(ctx, "/api/pull", map[string]interface{}{"model": model, "stream": true})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		var update PullProgress
+		if err := json.Unmarshal(scanner.Bytes(), &update); err != nil {
+			return fmt.Errorf("invalid pull progress: %w", err)
+		}
+		if update.Error != "" {
+			return fmt.Errorf("failed to pull %s: %s", model, update.Error)
+		}
+		if progress != nil {
+			progress(update)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read pull progress: %w", err)
+	}
+	return nil
+}
+
+// Ping checks that the server answers
+func (c *Client) Ping(ctx context.Context) error {
+	resp, err := c.do(ctx, http.MethodGet, "/api/version", nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// List returns the models installed on the server
+func (c *Client) List(ctx context.Context) ([]Model, error) {
+	resp, err := c.do(ctx, http.MethodGet, "/api/tags", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var tags struct {
+		Models []Model `json:"models"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tags); err != nil {
+		return nil, fmt.Errorf("failed to decode Ollama models: %w", err)
+	}
+	if tags.Models == nil {
+		tags.Models = []Model{}
+	}
+	return tags.Models, nil
+}
+
+// Delete removes a model from the server
+func (c *Client) Delete(ctx context.Context, model string) error {
+	resp, err := c.do(ctx, http.MethodDelete, "/api/delete", map[string]string{"model": model})
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// Generate runs a short, non-streaming generation, which also loads the
+// model into memory
+func (c *Client) Generate(ctx context.Context, model, prompt string, maxTokens int) (string, error) {
+	resp, err := c.post(ctx, "/api/generate", map[string]interface{}{
+		"model":   model,
+		"prompt":  prompt,
+		"stream":  false,
+		"options": map[string]interface{}{"num_predict": maxTokens},
+	})
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var generated struct {
+		Response string `json:"response"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&generated); err != nil {
+		return "", fmt.Errorf("failed to decode Ollama response: %w", err)
+	}
+	return generated.Response, nil
+}
+
+// post sends a JSON request and returns the response when it succeeded
+func (c *Client) post(ctx context.Context, path string, body interface{}) (*http.Response, error) {
+	return c.do(ctx, http.MethodPost, path, body)
+}
+
+// do sends a request with an optional JSON body and returns the response when
+// it succeeded; a missing model is reported as ErrModelNotFound
+func (c *Client) do(ctx context.Context, method, path string, body interface{}) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		jsonData, err := json.Marshal(body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal Ollama request: %w", err)
+		}
+		reader = bytes.NewReader(jsonData)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Ollama request: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	llmhttp.SetHeaders(req, c.httpConfig, "ollama")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach Ollama: %w", err)
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return nil, ErrModelNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("ollama API error: %d - %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return resp, nil
+}
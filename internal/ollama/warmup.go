@@ -0,0 +1,66 @@
+package ollama
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// warmupPrompt is the tiny generation that loads a model into memory
+const warmupPrompt = "Hello"
+
+// Warmup pulls each model when asked to and runs a one-token generation, so
+// the first user message doesn't wait for a multi-minute model load. Each
+// model gets its own timeout; failures are logged and the next model is tried.
+func (c *Client) Warmup(ctx context.Context, models []string, pull bool, timeout time.Duration) {
+	for _, model := range models {
+		if ctx.Err() != nil {
+			return
+		}
+		c.warmupModel(ctx, model, pull, timeout)
+	}
+}
+
+// warmupModel pulls and loads a single model
+func (c *Client) warmupModel(ctx context.Context, model string, pull bool, timeout time.Duration) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	start := time.Now()
+
+	if pull {
+		log.Printf("⬇️  Pulling model %s...", model)
+		if err := c.Pull(ctx, model, pullLogger(model)); err != nil {
+			log.Printf("⚠️  Warm-up of %s failed: %v", model, err)
+			return
+		}
+	}
+
+	log.Printf("🔥 Loading model %s...", model)
+	if _, err := c.Generate(ctx, model, warmupPrompt, 1); err != nil {
+		log.Printf("⚠️  Warm-up of %s failed: %v", model, err)
+		return
+	}
+	log.Printf("✅ Model %s is ready (%s)", model, time.Since(start).Round(time.Second))
+}
+
+// pullLogger logs status changes and every 10% of a download
+func pullLogger(model string) func(PullProgress) {
+	lastStatus, lastPercent := "", -1
+	return func(p PullProgress) {
+		percent := p.Percent()
+		if p.Status != lastStatus {
+			lastStatus, lastPercent = p.Status, -1
+			if percent < 0 {
+				log.Printf("   %s: %s", model, p.Status)
+				return
+			}
+		}
+		if percent >= 0 && (lastPercent < 0 || percent/10 > lastPercent/10) {
+			lastPercent = percent
+			log.Printf("   %s: %s %d%%", model, p.Status, percent)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"philoking/internal/storage"
+	"philoking/internal/types"
+)
+
+// bucket holds the messages waiting to be published
+const bucket = "outbox"
+
+// retryInterval is how long the relay waits before retrying a failed publish
+const retryInterval = 5 * time.Second
+
+// Publisher publishes chat messages, e.g. to Kafka
+type Publisher interface {
+	PublishMessage(ctx context.Context, message *types.ChatMessage) error
+}
+
+// Outbox makes state changes and the messages announcing them consistent:
+// both are written in one storage transaction, and a relay publishes the
+// stored messages until the publisher accepts them.
+type Outbox struct {
+	store     *storage.Store
+	publisher Publisher
+	wake      chan struct{}
+}
+
+// Tx is a storage transaction that can also stage messages for publication
+type Tx struct {
+	*storage.Tx
+}
+
+// New creates an outbox in the given store
+func New(store *storage.Store, publisher Publisher) *Outbox {
+	return &Outbox{
+		store:     store,
+		publisher: publisher,
+		wake:      make(chan struct{}, 1),
+	}
+}
+
+// Store returns the store the outbox writes to
+func (o *Outbox) Store() *storage.Store {
+	return o.store
+}
+
+// Update runs fn in a transaction; messages it stages are published once the transaction commits
+func (o *Outbox) Update(fn func(tx *Tx) error) error {
+	if err := o.store.Update(func(tx *storage.Tx) error {
+		return fn(&Tx{Tx: tx})
+	}); err != nil {
+		return err
+	}
+
+	select {
+	case o.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// PublishMessage stages a single message; it satisfies Publisher so the
+// outbox can stand in for the Kafka client
+func (o *Outbox) PublishMessage(ctx context.Context, message *types.ChatMessage) error {
+	return o.Update(func(tx *Tx) error {
+		return tx.Publish(message)
+	})
+}
+
+// Publish stages a message to be published after the transaction commits
+func (tx *Tx) Publish(message *types.ChatMessage) error {
+	// Keys sort in staging order so the relay keeps messages in order
+	key := fmt.Sprintf("%020d-%s", time.Now().UnixNano(), message.ID)
+	return tx.Put(bucket, key, message)
+}
+
+// Run relays staged messages until ctx is done, including any left over from a previous run
+func (o *Outbox) Run(ctx context.Context) {
+	ticker := time.NewTicker(retryInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := o.relay(ctx); err != nil {
+			log.Printf("Outbox relay paused: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-o.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// relay publishes staged messages in order, removing each once it is accepted.
+// A crash between the two publishes the message again; consumers drop the duplicate by ID.
+func (o *Outbox) relay(ctx context.Context) error {
+	keys, err := o.store.Keys(bucket)
+	if err != nil {
+		return fmt.Errorf("failed to list outbox: %w", err)
+	}
+
+	for _, key := range keys {
+		var message types.ChatMessage
+		if ok, err := o.store.Get(bucket, key, &message); err != nil || !ok {
+			continue
+		}
+
+		if err := o.publisher.PublishMessage(ctx, &message); err != nil {
+			return fmt.Errorf("failed to publish %s: %w", message.ID, err)
+		}
+
+		if err := o.store.Update(func(tx *storage.Tx) error {
+			tx.Delete(bucket, key)
+			return nil
+		}); err != nil {
+			return fmt.Errorf("failed to clear %s from the outbox: %w", message.ID, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package quota
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"philoking/internal/config"
+)
+
+// callWindow is the sliding window for per-conversation call limits
+const callWindow = time.Hour
+
+// ExceededError is returned when an LLM call would exceed a quota
+type ExceededError struct {
+	Quota string // "conversation_calls" or "daily_tokens"
+	Scope string // Conversation ID or provider name
+	Limit int
+	// FirstNotice is true the first time this quota is hit in its window,
+	// so callers can announce it once instead of on every call
+	FirstNotice bool
+}
+
+func (e *ExceededError) Error() string {
+	switch e.Quota {
+	case "conversation_calls":
+		return fmt.Sprintf("quota exceeded: conversation %s reached %d LLM calls per hour", e.Scope, e.Limit)
+	default:
+		return fmt.Sprintf("quota exceeded: provider %s reached %d tokens today", e.Scope, e.Limit)
+	}
+}
+
+// Limiter enforces LLM usage quotas shared by all agents
+type Limiter struct {
+	config   config.QuotaConfig
+	calls    map[string][]time.Time // Conversation ID -> call times within the window
+	tokens   map[string]int         // Provider -> tokens used today
+	day      string                 // Day the token counters belong to
+	notified map[string]bool        // Quotas already announced in their current window
+	mu       sync.Mutex
+}
+
+// NewLimiter creates a quota limiter; a zero limit disables that quota
+func NewLimiter(cfg config.QuotaConfig) *Limiter {
+	return &Limiter{
+		config:   cfg,
+		calls:    make(map[string][]time.Time),
+		tokens:   make(map[string]int),
+		day:      today(),
+		notified: make(map[string]bool),
+	}
+}
+
+// Allow reserves an LLM call for a conversation and provider, or returns an
+// *ExceededError if a quota has been reached
+func (l *Limiter) Allow(conversationID, provider string) error {
+	return l.allow(conversationID, provider, time.Now())
+}
+
+// allow is Allow at a given time
+func (l *Limiter) allow(conversationID, provider string, now time.Time) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.resetDay()
+
+	if limit := l.config.MaxDailyTokens[provider]; limit > 0 && l.tokens[provider] >= limit {
+		return l.exceeded("daily_tokens", provider, limit)
+	}
+
+	if limit := l.config.MaxCallsPerConversationPerHour; limit > 0 {
+		calls := l.calls[conversationID]
+		cutoff := now.Add(-callWindow)
+		for len(calls) > 0 && calls[0].Before(cutoff) {
+			calls = calls[1:]
+		}
+		if len(calls) >= limit {
+			l.calls[conversationID] = calls
+			return l.exceeded("conversation_calls", conversationID, limit)
+		}
+		// Calls are allowed again, so hitting the limit next time is news
+		delete(l.notified, "conversation_calls:"+conversationID)
+		l.calls[conversationID] = append(calls, now)
+	}
+
+	return nil
+}
+
+// RecordTokens adds the tokens used by a completed call to the provider's daily total
+func (l *Limiter) RecordTokens(provider string, tokens int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.resetDay()
+	l.tokens[provider] += tokens
+}
+
+// Usage returns the tokens used per provider today
+func (l *Limiter) Usage() map[string]int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.resetDay()
+	usage := make(map[string]int, len(l.tokens))
+	for provider, tokens := range l.tokens {
+		usage[provider] = tokens
+	}
+	return usage
+}
+
+// exceeded builds an ExceededError and marks the quota as announced
+func (l *Limiter) exceeded(quota, scope string, limit int) error {
+	key := quota + ":" + scope
+	first := !l.notified[key]
+	l.notified[key] = true
+	return &ExceededError{Quota: quota, Scope: scope, Limit: limit, FirstNotice: first}
+}
+
+// resetDay clears the daily token counters when the day changes
+func (l *Limiter) resetDay() {
+	if day := today(); day != l.day {
+		l.day = day
+		l.tokens = make(map[string]int)
+		for key := range l.notified {
+			if strings.HasPrefix(key, "daily_tokens:") {
+				delete(l.notified, key)
+			}
+		}
+	}
+}
+
+func today() string {
+	return time.Now().Format("2006-01-02")
+}
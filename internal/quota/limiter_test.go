@@ -0,0 +1,72 @@
+package quota
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"philoking/internal/config"
+)
+
+func TestLimiterConversationCalls(t *testing.T) {
+	l := NewLimiter(config.QuotaConfig{MaxCallsPerConversationPerHour: 2})
+	start := time.Now()
+
+	// Each step calls at start plus the given offset
+	tests := []struct {
+		name   string
+		at     time.Duration
+		conv   string
+		allow  bool
+		notice bool
+	}{
+		{name: "first call", at: 0, conv: "c", allow: true},
+		{name: "second call", at: 10 * time.Minute, conv: "c", allow: true},
+		{name: "limit reached", at: 20 * time.Minute, conv: "c", notice: true},
+		{name: "limit still reached", at: 30 * time.Minute, conv: "c"},
+		{name: "other conversation", at: 30 * time.Minute, conv: "d", allow: true},
+		{name: "first call left the window", at: 61 * time.Minute, conv: "c", allow: true},
+		{name: "limit reached again", at: 62 * time.Minute, conv: "c", notice: true},
+		{name: "announced once more", at: 63 * time.Minute, conv: "c"},
+	}
+	for _, tt := range tests {
+		err := l.allow(tt.conv, "openai", start.Add(tt.at))
+		var exceeded *ExceededError
+		switch {
+		case tt.allow && err != nil:
+			t.Fatalf("%s: Allow = %v, want the call allowed", tt.name, err)
+		case !tt.allow && !errors.As(err, &exceeded):
+			t.Fatalf("%s: Allow = %v, want an ExceededError", tt.name, err)
+		case !tt.allow && exceeded.FirstNotice != tt.notice:
+			t.Errorf("%s: FirstNotice = %v, want %v", tt.name, exceeded.FirstNotice, tt.notice)
+		}
+	}
+}
+
+func TestLimiterDailyTokens(t *testing.T) {
+	l := NewLimiter(config.QuotaConfig{MaxDailyTokens: map[string]int{"openai": 100}})
+
+	if err := l.Allow("c", "openai"); err != nil {
+		t.Fatal(err)
+	}
+	l.RecordTokens("openai", 120)
+
+	for i, notice := range []bool{true, false} {
+		var exceeded *ExceededError
+		if err := l.Allow("c", "openai"); !errors.As(err, &exceeded) || exceeded.Quota != "daily_tokens" || exceeded.FirstNotice != notice {
+			t.Errorf("call %d: Allow = %v, want daily_tokens exceeded with FirstNotice %v", i, err, notice)
+		}
+	}
+	if err := l.Allow("c", "ollama"); err != nil {
+		t.Errorf("Allow for another provider = %v", err)
+	}
+
+	// A new day resets the counters and the notice
+	l.day = "2000-01-01"
+	if err := l.Allow("c", "openai"); err != nil {
+		t.Errorf("Allow on a new day = %v", err)
+	}
+	if usage := l.Usage(); usage["openai"] != 0 {
+		t.Errorf("usage on a new day = %v", usage)
+	}
+}
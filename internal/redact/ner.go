@@ -0,0 +1,97 @@
+package redact
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// nerClient masks named entities found by an external recognition service.
+// The service receives {"text": "..."} and answers with
+// {"entities": [{"start": 0, "end": 5, "label": "PERSON"}]}, where offsets
+// count characters, as spaCy and most Python NER servers report them.
+type nerClient struct {
+	url        string
+	labels     map[string]bool // Empty masks every entity
+	httpClient *http.Client
+}
+
+type nerEntity struct {
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+	Label string `json:"label"`
+}
+
+func newNERClient(url string, labels []string) *nerClient {
+	c := &nerClient{
+		url:        url,
+		labels:     make(map[string]bool),
+		httpClient: &http.Client{Timeout: 5 * time.Second},
+	}
+	for _, label := range labels {
+		c.labels[strings.ToUpper(label)] = true
+	}
+	return c
+}
+
+// redact replaces recognized entities with their label, e.g. "[PERSON]"
+func (c *nerClient) redact(ctx context.Context, text string) (string, error) {
+	body, err := json.Marshal(map[string]string{"text": text})
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("failed to create entity recognition request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("entity recognition request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		data, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("entity recognition error: %d - %s", resp.StatusCode, string(data))
+	}
+
+	var result struct {
+		Entities []nerEntity `json:"entities"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("failed to decode entity recognition response: %w", err)
+	}
+
+	return c.mask([]rune(text), result.Entities), nil
+}
+
+// mask replaces the entities in text, skipping overlapping and out-of-range spans
+func (c *nerClient) mask(text []rune, entities []nerEntity) string {
+	sort.Slice(entities, func(i, j int) bool { return entities[i].Start < entities[j].Start })
+
+	var b strings.Builder
+	pos := 0
+	for _, entity := range entities {
+		label := strings.ToUpper(entity.Label)
+		if len(c.labels) > 0 && !c.labels[label] {
+			continue
+		}
+		if entity.Start < pos || entity.End > len(text) || entity.Start >= entity.End {
+			continue
+		}
+		b.WriteString(string(text[pos:entity.Start]))
+		b.WriteString("[" + label + "]")
+		pos = entity.End
+	}
+	b.WriteString(string(text[pos:]))
+	return b.String()
+}
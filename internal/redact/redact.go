@@ -0,0 +1,167 @@
+package redact
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+
+	"philoking/internal/config"
+)
+
+// builtins are the rules that can be enabled by name
+var builtins = map[string]rule{
+	"email": {
+		name:        "email",
+		re:          regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`),
+		replacement: "[EMAIL]",
+	},
+	"phone": {
+		name:        "phone",
+		re:          regexp.MustCompile(`(?:\+|\b00)?\(?\d{1,4}\)?(?:[ .\-]?\(?\d{1,4}\)?){2,6}\b`),
+		replacement: "[PHONE]",
+		valid:       isPhoneNumber,
+	},
+	"credit_card": {
+		name:        "credit_card",
+		re:          regexp.MustCompile(`\b\d(?:[ \-]?\d){12,18}\b`),
+		replacement: "[CARD]",
+		valid:       passesLuhn,
+	},
+}
+
+// rule masks the matches of a regular expression
+type rule struct {
+	name        string
+	re          *regexp.Regexp
+	replacement string
+	valid       func(match string) bool // Optional check that filters false positives
+}
+
+// Redactor masks personal data such as email addresses, phone numbers and
+// credit card numbers before text is sent to an external LLM provider
+type Redactor struct {
+	rules     []rule
+	ner       *nerClient // Nil unless a named-entity recognition service is configured
+	providers map[string]bool
+}
+
+// New creates a redactor from configuration; it returns nil when redaction is disabled
+func New(cfg config.RedactionConfig) (*Redactor, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+
+	r := &Redactor{providers: make(map[string]bool)}
+	for _, provider := range cfg.Providers {
+		r.providers[provider] = true
+	}
+
+	// Credit cards go first so the phone rule doesn't claim their digits
+	for _, name := range []string{"credit_card", "email", "phone"} {
+		if contains(cfg.Builtins, name) {
+			r.rules = append(r.rules, builtins[name])
+		}
+	}
+	for _, name := range cfg.Builtins {
+		if _, exists := builtins[name]; !exists {
+			return nil, fmt.Errorf("unknown built-in redaction rule %q", name)
+		}
+	}
+
+	for _, pattern := range cfg.Patterns {
+		re, err := regexp.Compile(pattern.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redaction pattern %s: %w", pattern.Name, err)
+		}
+		replacement := pattern.Replacement
+		if replacement == "" {
+			replacement = "[" + strings.ToUpper(pattern.Name) + "]"
+		}
+		r.rules = append(r.rules, rule{name: pattern.Name, re: re, replacement: replacement})
+	}
+
+	if cfg.NERURL != "" {
+		r.ner = newNERClient(cfg.NERURL, cfg.NERLabels)
+	}
+	return r, nil
+}
+
+// Applies reports whether prompts for the provider are redacted
+func (r *Redactor) Applies(provider string) bool {
+	return r != nil && r.providers[provider]
+}
+
+// Redact masks personal data in text. When the entity recognition service
+// fails, the text is still masked by the regular expression rules.
+func (r *Redactor) Redact(ctx context.Context, text string) string {
+	if r == nil {
+		return text
+	}
+
+	if r.ner != nil {
+		masked, err := r.ner.redact(ctx, text)
+		if err != nil {
+			log.Printf("Entity recognition failed, falling back to pattern redaction: %v", err)
+		} else {
+			text = masked
+		}
+	}
+
+	for _, rule := range r.rules {
+		text = rule.re.ReplaceAllStringFunc(text, func(match string) string {
+			if rule.valid != nil && !rule.valid(match) {
+				return match
+			}
+			return rule.replacement
+		})
+	}
+	return text
+}
+
+// isPhoneNumber accepts matches with enough digits to be a phone number
+func isPhoneNumber(match string) bool {
+	digits := countDigits(match)
+	return digits >= 9 && digits <= 15
+}
+
+// passesLuhn reports whether the digits in match form a valid card number
+func passesLuhn(match string) bool {
+	sum, double := 0, false
+	for i := len(match) - 1; i >= 0; i-- {
+		c := match[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+func countDigits(s string) int {
+	n := 0
+	for _, c := range s {
+		if c >= '0' && c <= '9' {
+			n++
+		}
+	}
+	return n
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,186 @@
+// Package rules decides whether an agent responds to a message with
+// expressions in the Common Expression Language (CEL), e.g.
+//
+//	message.type == "user" && contains(topic, "ethics")
+//
+// Expressions see the message, the agent and the conversation's topic, mood,
+// goal, phase and length; see Input. The first rule whose expression is true
+// decides, and messages no rule matches are left to the response chance.
+package rules
+
+import (
+	"fmt"
+	"strings"
+
+	"philoking/internal/types"
+
+	"github.com/google/cel-go/cel"
+	celtypes "github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+)
+
+// Action is what a matching rule makes the agent do
+type Action string
+
+const (
+	// None means no rule matched
+	None Action = ""
+	// Respond makes the agent answer, whatever its response chance
+	Respond Action = "respond"
+	// Ignore keeps the agent quiet
+	Ignore Action = "ignore"
+)
+
+// Rule pairs an expression with the action taken when it is true
+type Rule struct {
+	When   string
+	Action Action
+}
+
+// Input is what rule expressions are evaluated against
+type Input struct {
+	Message   *types.ChatMessage
+	AgentID   string
+	AgentName string
+	Topic     string
+	Mood      string
+	Goal      string
+	Phase     string // Name of the active phase, if any
+	Messages  int    // Messages in the conversation so far
+}
+
+// Engine evaluates an agent's rules; it is safe for concurrent use
+type Engine struct {
+	rules    []Rule
+	programs []cel.Program
+}
+
+// env declares the variables and functions available to expressions
+var env = mustEnv()
+
+func mustEnv() *cel.Env {
+	e, err := cel.NewEnv(
+		cel.Variable("message", cel.MapType(cel.StringType, cel.DynType)),
+		cel.Variable("agent", cel.MapType(cel.StringType, cel.StringType)),
+		cel.Variable("topic", cel.StringType),
+		cel.Variable("mood", cel.StringType),
+		cel.Variable("goal", cel.StringType),
+		cel.Variable("phase", cel.StringType),
+		cel.Variable("messages", cel.IntType),
+		// contains(text, part) ignores case, unlike text.contains(part)
+		cel.Function("contains",
+			cel.Overload("contains_string_string_ignore_case", []*cel.Type{cel.StringType, cel.StringType}, cel.BoolType,
+				cel.BinaryBinding(func(text, part ref.Val) ref.Val {
+					return celtypes.Bool(strings.Contains(strings.ToLower(string(text.(celtypes.String))), strings.ToLower(string(part.(celtypes.String)))))
+				}),
+			),
+		),
+	)
+	if err != nil {
+		panic(err)
+	}
+	return e
+}
+
+// Check reports whether an expression is valid and evaluates to a boolean
+func Check(expression string) error {
+	_, err := compile(expression)
+	return err
+}
+
+// New compiles rules into an engine
+func New(rules []Rule) (*Engine, error) {
+	engine := &Engine{rules: rules}
+	for _, rule := range rules {
+		if rule.Action != Respond && rule.Action != Ignore {
+			return nil, fmt.Errorf("rule %q: action must be %q or %q", rule.When, Respond, Ignore)
+		}
+		program, err := compile(rule.When)
+		if err != nil {
+			return nil, err
+		}
+		engine.programs = append(engine.programs, program)
+	}
+	return engine, nil
+}
+
+// compile parses and type-checks an expression
+func compile(expression string) (cel.Program, error) {
+	ast, issues := env.Compile(expression)
+	if issues != nil && issues.Err() != nil {
+		return nil, fmt.Errorf("rule %q: %w", expression, issues.Err())
+	}
+	// Message fields are dynamic, so message.is_command is only checked when evaluated
+	if t := ast.OutputType(); t != cel.BoolType && t != cel.DynType {
+		return nil, fmt.Errorf("rule %q: must be true or false, not %s", expression, ast.OutputType())
+	}
+	program, err := env.Program(ast)
+	if err != nil {
+		return nil, fmt.Errorf("rule %q: %w", expression, err)
+	}
+	return program, nil
+}
+
+// Decide returns the action of the first rule that matches, with its
+// expression. Rules that fail to evaluate, e.g. on a missing message field,
+// don't match and are returned in errs.
+func (e *Engine) Decide(input Input) (action Action, rule string, errs []error) {
+	if e == nil || len(e.programs) == 0 {
+		return None, "", nil
+	}
+
+	vars := variables(input)
+	for i, program := range e.programs {
+		out, _, err := program.Eval(vars)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("rule %q: %w", e.rules[i].When, err))
+			continue
+		}
+		matched, ok := out.Value().(bool)
+		if !ok {
+			errs = append(errs, fmt.Errorf("rule %q: must be true or false, not %v", e.rules[i].When, out.Value()))
+			continue
+		}
+		if matched {
+			return e.rules[i].Action, e.rules[i].When, errs
+		}
+	}
+	return None, "", errs
+}
+
+// variables exposes the input to expressions
+func variables(input Input) map[string]interface{} {
+	message := map[string]interface{}{}
+	if m := input.Message; m != nil {
+		tags := m.Metadata.Tags
+		if tags == nil {
+			tags = []string{}
+		}
+		from := m.Metadata.FromAgent
+		if from == "" {
+			from = m.AgentID
+		}
+		message = map[string]interface{}{
+			"id":              m.ID,
+			"type":            string(m.Type),
+			"content":         m.Content,
+			"from":            from,
+			"agent_id":        m.AgentID,
+			"user_id":         m.UserID,
+			"reply_to":        m.Metadata.ReplyTo,
+			"conversation_id": m.Metadata.ConversationID,
+			"tags":            tags,
+			"is_command":      m.IsCommand(),
+		}
+	}
+
+	return map[string]interface{}{
+		"message":  message,
+		"agent":    map[string]string{"id": input.AgentID, "name": input.AgentName},
+		"topic":    input.Topic,
+		"mood":     input.Mood,
+		"goal":     input.Goal,
+		"phase":    input.Phase,
+		"messages": input.Messages,
+	}
+}
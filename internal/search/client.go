@@ -0,0 +1,199 @@
+package search
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"philoking/internal/config"
+)
+
+// Result is a single web search hit
+type Result struct {
+	Title   string `json:"title"`
+	URL     string `json:"url"`
+	Snippet string `json:"snippet"`
+}
+
+// Client runs web searches against a configured search API
+type Client interface {
+	Search(ctx context.Context, query string) ([]Result, error)
+}
+
+// NewClient creates a search client for the configured provider
+func NewClient(cfg config.SearchConfig) (Client, error) {
+	maxResults := cfg.MaxResults
+	if maxResults <= 0 {
+		maxResults = 5
+	}
+
+	base := httpSearcher{
+		url:        cfg.URL,
+		apiKey:     cfg.APIKey,
+		maxResults: maxResults,
+		client: &http.Client{
+			Timeout: 15 * time.Second,
+		},
+	}
+
+	switch cfg.Provider {
+	case "searxng":
+		if cfg.URL == "" {
+			return nil, fmt.Errorf("searxng search requires a url")
+		}
+		return &searxngClient{base}, nil
+	case "brave":
+		if cfg.APIKey == "" {
+			return nil, fmt.Errorf("brave search requires an api key")
+		}
+		if base.url == "" {
+			base.url = "https://api.search.brave.com/res/v1/web/search"
+		}
+		return &braveClient{base}, nil
+	case "bing":
+		if cfg.APIKey == "" {
+			return nil, fmt.Errorf("bing search requires an api key")
+		}
+		if base.url == "" {
+			base.url = "https://api.bing.microsoft.com/v7.0/search"
+		}
+		return &bingClient{base}, nil
+	case "":
+		return nil, fmt.Errorf("no search provider configured")
+	default:
+		return nil, fmt.Errorf("unsupported search provider: %s", cfg.Provider)
+	}
+}
+
+// httpSearcher holds the settings shared by all HTTP search providers
+type httpSearcher struct {
+	url        string
+	apiKey     string
+	maxResults int
+	client     *http.Client
+}
+
+// getJSON performs a GET request and decodes the JSON response into out
+func (h *httpSearcher) getJSON(ctx context.Context, endpoint string, headers map[string]string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create search request: %w", err)
+	}
+
+	req.Header.Set("Accept", "application/json")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to make search request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("search API error: %d - %s", resp.StatusCode, string(body))
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode search response: %w", err)
+	}
+	return nil
+}
+
+// limit truncates results to the configured maximum
+func (h *httpSearcher) limit(results []Result) []Result {
+	if len(results) > h.maxResults {
+		return results[:h.maxResults]
+	}
+	return results
+}
+
+// searxngClient queries a self-hosted SearxNG instance
+type searxngClient struct {
+	httpSearcher
+}
+
+func (s *searxngClient) Search(ctx context.Context, query string) ([]Result, error) {
+	endpoint := s.url + "/search?format=json&q=" + url.QueryEscape(query)
+
+	var resp struct {
+		Results []struct {
+			Title   string `json:"title"`
+			URL     string `json:"url"`
+			Content string `json:"content"`
+		} `json:"results"`
+	}
+	if err := s.getJSON(ctx, endpoint, nil, &resp); err != nil {
+		return nil, err
+	}
+
+	results := make([]Result, 0, len(resp.Results))
+	for _, r := range resp.Results {
+		results = append(results, Result{Title: r.Title, URL: r.URL, Snippet: r.Content})
+	}
+	return s.limit(results), nil
+}
+
+// braveClient queries the Brave Search API
+type braveClient struct {
+	httpSearcher
+}
+
+func (b *braveClient) Search(ctx context.Context, query string) ([]Result, error) {
+	endpoint := fmt.Sprintf("%s?q=%s&count=%d", b.url, url.QueryEscape(query), b.maxResults)
+
+	var resp struct {
+		Web struct {
+			Results []struct {
+				Title       string `json:"title"`
+				URL         string `json:"url"`
+				Description string `json:"description"`
+			} `json:"results"`
+		} `json:"web"`
+	}
+	headers := map[string]string{"X-Subscription-Token": b.apiKey}
+	if err := b.getJSON(ctx, endpoint, headers, &resp); err != nil {
+		return nil, err
+	}
+
+	results := make([]Result, 0, len(resp.Web.Results))
+	for _, r := range resp.Web.Results {
+		results = append(results, Result{Title: r.Title, URL: r.URL, Snippet: r.Description})
+	}
+	return b.limit(results), nil
+}
+
+// bingClient queries the Bing Web Search API
+type bingClient struct {
+	httpSearcher
+}
+
+func (b *bingClient) Search(ctx context.Context, query string) ([]Result, error) {
+	endpoint := fmt.Sprintf("%s?q=%s&count=%d", b.url, url.QueryEscape(query), b.maxResults)
+
+	var resp struct {
+		WebPages struct {
+			Value []struct {
+				Name    string `json:"name"`
+				URL     string `json:"url"`
+				Snippet string `json:"snippet"`
+			} `json:"value"`
+		} `json:"webPages"`
+	}
+	headers := map[string]string{"Ocp-Apim-Subscription-Key": b.apiKey}
+	if err := b.getJSON(ctx, endpoint, headers, &resp); err != nil {
+		return nil, err
+	}
+
+	results := make([]Result, 0, len(resp.WebPages.Value))
+	for _, r := range resp.WebPages.Value {
+		results = append(results, Result{Title: r.Name, URL: r.URL, Snippet: r.Snippet})
+	}
+	return b.limit(results), nil
+}
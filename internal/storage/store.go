@@ -0,0 +1,218 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// journalFile holds the transaction being applied; it is only present
+// between committing a transaction and finishing applying it
+const journalFile = "journal.json"
+
+// Store is a small transactional key-value store kept as JSON files in a
+// directory, one subdirectory per bucket. Every transaction is journaled
+// before it is applied, so a crash never leaves one half applied.
+type Store struct {
+	dir string
+	mu  sync.RWMutex
+}
+
+// Tx collects the writes of a transaction; they become visible together on commit
+type Tx struct {
+	store *Store
+	ops   []op
+}
+
+// op is a single write in a transaction
+type op struct {
+	Bucket string          `json:"bucket"`
+	Key    string          `json:"key"`
+	Value  json.RawMessage `json:"value,omitempty"`
+	Delete bool            `json:"delete,omitempty"`
+}
+
+// Open opens the store in dir, creating it if needed and finishing any
+// transaction that was interrupted
+func Open(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create storage directory: %w", err)
+	}
+
+	s := &Store{dir: dir}
+	if err := s.recover(); err != nil {
+		return nil, fmt.Errorf("failed to recover storage journal: %w", err)
+	}
+	return s, nil
+}
+
+// Update runs fn in a transaction and commits its writes atomically.
+// Nothing is written if fn returns an error.
+func (s *Store) Update(fn func(tx *Tx) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx := &Tx{store: s}
+	if err := fn(tx); err != nil {
+		return err
+	}
+	if len(tx.ops) == 0 {
+		return nil
+	}
+
+	data, err := json.Marshal(tx.ops)
+	if err != nil {
+		return fmt.Errorf("failed to encode transaction: %w", err)
+	}
+	if err := writeFileAtomic(filepath.Join(s.dir, journalFile), data); err != nil {
+		return fmt.Errorf("failed to journal transaction: %w", err)
+	}
+	return s.apply(tx.ops)
+}
+
+// Get reads a value into v and reports whether the key exists
+func (s *Store) Get(bucket, key string, v interface{}) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.get(bucket, key, v)
+}
+
+// Keys returns the keys of a bucket in sorted order
+func (s *Store) Keys(bucket string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	entries, err := os.ReadDir(filepath.Join(s.dir, bucket))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), ".json")
+		if !ok {
+			continue
+		}
+		key, err := url.PathUnescape(name)
+		if err != nil {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// Put stores v as JSON under a key
+func (tx *Tx) Put(bucket, key string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s/%s: %w", bucket, key, err)
+	}
+	tx.ops = append(tx.ops, op{Bucket: bucket, Key: key, Value: data})
+	return nil
+}
+
+// Delete removes a key
+func (tx *Tx) Delete(bucket, key string) {
+	tx.ops = append(tx.ops, op{Bucket: bucket, Key: key, Delete: true})
+}
+
+// Get reads a value, seeing the transaction's own earlier writes
+func (tx *Tx) Get(bucket, key string, v interface{}) (bool, error) {
+	for i := len(tx.ops) - 1; i >= 0; i-- {
+		if op := tx.ops[i]; op.Bucket == bucket && op.Key == key {
+			if op.Delete {
+				return false, nil
+			}
+			return true, json.Unmarshal(op.Value, v)
+		}
+	}
+	return tx.store.get(bucket, key, v)
+}
+
+// get reads a value without locking
+func (s *Store) get(bucket, key string, v interface{}) (bool, error) {
+	data, err := os.ReadFile(s.path(bucket, key))
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, json.Unmarshal(data, v)
+}
+
+// apply writes the operations of a journaled transaction and clears the journal
+func (s *Store) apply(ops []op) error {
+	for _, op := range ops {
+		path := s.path(op.Bucket, op.Key)
+		if op.Delete {
+			if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return err
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return err
+		}
+		if err := writeFileAtomic(path, op.Value); err != nil {
+			return err
+		}
+	}
+	return os.Remove(filepath.Join(s.dir, journalFile))
+}
+
+// recover re-applies a transaction that was journaled but not fully applied
+func (s *Store) recover() error {
+	data, err := os.ReadFile(filepath.Join(s.dir, journalFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var ops []op
+	if err := json.Unmarshal(data, &ops); err != nil {
+		return err
+	}
+	return s.apply(ops)
+}
+
+// path returns the file holding a key
+func (s *Store) path(bucket, key string) string {
+	return filepath.Join(s.dir, bucket, url.PathEscape(key)+".json")
+}
+
+// writeFileAtomic writes data to a temporary file, syncs it and renames it into place
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,257 @@
+package tap
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"philoking/internal/config"
+	"philoking/internal/types"
+)
+
+// backupTimeFormat stamps rotated files; it sorts chronologically
+const backupTimeFormat = "20060102T150405"
+
+// Tap appends chat messages as JSON lines to a file and rotates it by size
+// and age, keeping a bounded number of rotated files
+type Tap struct {
+	cfg      config.TapConfig
+	file     *os.File
+	size     int64
+	openedAt time.Time
+	mu       sync.Mutex
+}
+
+// New opens the tap file, appending to an existing one
+func New(cfg config.TapConfig) (*Tap, error) {
+	if err := os.MkdirAll(filepath.Dir(cfg.File), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create tap directory: %w", err)
+	}
+
+	t := &Tap{cfg: cfg}
+	if err := t.open(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Write appends a message to the file, rotating it first when it is due
+func (t *Tap) Write(message *types.ChatMessage) error {
+	data, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("failed to encode message for the tap: %w", err)
+	}
+	data = append(data, '\n')
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.file == nil {
+		return fmt.Errorf("tap is closed")
+	}
+	if t.due(int64(len(data)), time.Now()) {
+		if err := t.rotate(); err != nil {
+			return err
+		}
+	}
+
+	n, err := t.file.Write(data)
+	t.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("failed to write to tap: %w", err)
+	}
+	return nil
+}
+
+// Close closes the tap file
+func (t *Tap) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.file == nil {
+		return nil
+	}
+	err := t.file.Close()
+	t.file = nil
+	return err
+}
+
+// ForgetUser removes the messages a user sent from the tap file and the
+// rotated files, and returns how many were removed
+func (t *Tap) ForgetUser(userID string) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	files, err := t.backups()
+	if err != nil {
+		return 0, fmt.Errorf("failed to list rotated tap files: %w", err)
+	}
+
+	removed := 0
+	for _, file := range files {
+		n, err := forgetUser(file, userID)
+		removed += n
+		if err != nil {
+			return removed, err
+		}
+	}
+
+	if t.file == nil {
+		n, err := forgetUser(t.cfg.File, userID)
+		return removed + n, err
+	}
+
+	// The current file is reopened after the rewrite; it keeps its age
+	if err := t.file.Close(); err != nil {
+		return removed, fmt.Errorf("failed to close tap file: %w", err)
+	}
+	t.file = nil
+	openedAt := t.openedAt
+	n, err := forgetUser(t.cfg.File, userID)
+	removed += n
+	if err := t.open(); err != nil {
+		return removed, err
+	}
+	t.openedAt = openedAt
+	return removed, err
+}
+
+// forgetUser rewrites a tap file without the messages a user sent, and
+// returns how many were removed. Lines that aren't messages are kept.
+func forgetUser(file, userID string) (int, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read tap file: %w", err)
+	}
+
+	var kept bytes.Buffer
+	removed := 0
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, len(data)+1) // A line is never longer than the file
+	for scanner.Scan() {
+		var message types.ChatMessage
+		if json.Unmarshal(scanner.Bytes(), &message) == nil && message.UserID == userID {
+			removed++
+			continue
+		}
+		kept.Write(scanner.Bytes())
+		kept.WriteByte('\n')
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, fmt.Errorf("failed to read tap file: %w", err)
+	}
+	if removed == 0 {
+		return 0, nil
+	}
+
+	// Replace the file at once, so readers never see it half written
+	temp := file + ".tmp"
+	if err := os.WriteFile(temp, kept.Bytes(), 0o600); err != nil {
+		return 0, fmt.Errorf("failed to rewrite tap file: %w", err)
+	}
+	if err := os.Rename(temp, file); err != nil {
+		os.Remove(temp)
+		return 0, fmt.Errorf("failed to rewrite tap file: %w", err)
+	}
+	return removed, nil
+}
+
+// due reports whether the file must be rotated before writing n more bytes.
+// An empty file is never rotated, so a single large message still gets written.
+func (t *Tap) due(n int64, now time.Time) bool {
+	if t.size == 0 {
+		return false
+	}
+	if t.cfg.MaxSizeMB > 0 && t.size+n > int64(t.cfg.MaxSizeMB)<<20 {
+		return true
+	}
+	return t.cfg.RotateEvery > 0 && now.Sub(t.openedAt) >= t.cfg.RotateEvery
+}
+
+// open opens the tap file; an existing file counts as opened when it was created
+func (t *Tap) open() error {
+	file, err := os.OpenFile(t.cfg.File, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open tap file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to open tap file: %w", err)
+	}
+
+	t.file = file
+	t.size = info.Size()
+	t.openedAt = time.Now()
+	if t.size > 0 {
+		// The modification time is the best guess at the age of a file left by a previous run
+		t.openedAt = info.ModTime()
+	}
+	return nil
+}
+
+// rotate renames the current file with a timestamp, opens a new one and
+// removes the oldest rotated files beyond MaxBackups
+func (t *Tap) rotate() error {
+	if err := t.file.Close(); err != nil {
+		return fmt.Errorf("failed to close tap file: %w", err)
+	}
+	t.file = nil
+
+	backup := t.backupName(time.Now())
+	if err := os.Rename(t.cfg.File, backup); err != nil {
+		return fmt.Errorf("failed to rotate tap file: %w", err)
+	}
+	log.Printf("Rotated conversation tap to %s", backup)
+
+	if err := t.open(); err != nil {
+		return err
+	}
+	t.prune()
+	return nil
+}
+
+// backupName returns the name of a rotated file, e.g. conversations-20250102T150405.jsonl
+func (t *Tap) backupName(at time.Time) string {
+	ext := filepath.Ext(t.cfg.File)
+	return fmt.Sprintf("%s-%s%s", strings.TrimSuffix(t.cfg.File, ext), at.Format(backupTimeFormat), ext)
+}
+
+// prune removes the oldest rotated files beyond MaxBackups
+func (t *Tap) prune() {
+	if t.cfg.MaxBackups <= 0 {
+		return
+	}
+
+	backups, err := t.backups()
+	if err != nil {
+		log.Printf("Error listing rotated tap files: %v", err)
+		return
+	}
+
+	for len(backups) > t.cfg.MaxBackups {
+		if err := os.Remove(backups[0]); err != nil {
+			log.Printf("Error removing rotated tap file %s: %v", backups[0], err)
+		}
+		backups = backups[1:]
+	}
+}
+
+// backups lists the rotated files, oldest first
+func (t *Tap) backups() ([]string, error) {
+	ext := filepath.Ext(t.cfg.File)
+	backups, err := filepath.Glob(strings.TrimSuffix(t.cfg.File, ext) + "-*" + ext)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(backups)
+	return backups, nil
+}
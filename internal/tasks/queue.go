@@ -0,0 +1,455 @@
+// Package tasks runs agent work that takes longer than a chat turn, such as
+// ingesting a document or researching a question. Agents enqueue tasks,
+// workers process them in the background and the conversation hears how
+// they are going. With storage configured, tasks survive restarts.
+package tasks
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"philoking/internal/config"
+	"philoking/internal/outbox"
+	"philoking/internal/types"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// bucket stores the tasks when storage is configured
+	bucket = "tasks"
+	// Tag marks the status messages of tasks
+	Tag = "task"
+	// maxFinished bounds the finished tasks kept for the API
+	maxFinished = 200
+)
+
+// States of a task
+const (
+	StatusQueued  = "queued"
+	StatusRunning = "running"
+	StatusDone    = "done"
+	StatusFailed  = "failed"
+)
+
+// Custom metadata keys of status messages
+const (
+	TaskIDKey       = "task_id"
+	TaskStatusKey   = "task_status"
+	TaskProgressKey = "task_progress"
+)
+
+// Task is a job an agent hands to the queue
+type Task struct {
+	ID             string    `json:"id"`
+	Kind           string    `json:"kind"` // Picks the worker, e.g. "research"
+	ConversationID string    `json:"conversation_id,omitempty"`
+	AgentID        string    `json:"agent_id,omitempty"` // The agent doing the work
+	Input          string    `json:"input"`
+	Status         string    `json:"status"`
+	Attempts       int       `json:"attempts"`
+	Progress       int       `json:"progress"` // Percent
+	ProgressNote   string    `json:"progress_note,omitempty"`
+	Result         string    `json:"result,omitempty"`
+	Error          string    `json:"error,omitempty"` // Of the latest attempt
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+	RunAt          time.Time `json:"run_at"` // A queued task waits until then, e.g. between retries
+}
+
+// finished reports whether the task is done or has failed for good
+func (t *Task) finished() bool {
+	return t.Status == StatusDone || t.Status == StatusFailed
+}
+
+// Progress reports how far a task is, in percent, with a note on the current step
+type Progress func(percent int, note string)
+
+// Worker does the work of a task and returns its result
+type Worker func(ctx context.Context, task *Task, progress Progress) (string, error)
+
+// Queue keeps the tasks and runs them on a pool of workers
+type Queue struct {
+	config    config.TasksConfig
+	publisher outbox.Publisher // The Kafka client, or the outbox when storage is configured
+	outbox    *outbox.Outbox   // Nil keeps tasks in memory
+
+	mu      sync.Mutex
+	workers map[string]Worker // By kind
+	tasks   map[string]*Task
+	wake    chan struct{}
+}
+
+// New creates a queue and loads the stored tasks; box may be nil. Tasks
+// that were running when the app stopped are queued again.
+func New(cfg config.TasksConfig, publisher outbox.Publisher, box *outbox.Outbox) (*Queue, error) {
+	q := &Queue{
+		config:    cfg,
+		publisher: publisher,
+		workers:   make(map[string]Worker),
+		tasks:     make(map[string]*Task),
+		wake:      make(chan struct{}, 1),
+	}
+	if box == nil {
+		return q, nil
+	}
+	q.publisher, q.outbox = box, box
+
+	store := box.Store()
+	ids, err := store.Keys(bucket)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load tasks: %w", err)
+	}
+	for _, id := range ids {
+		var task Task
+		if ok, err := store.Get(bucket, id, &task); err != nil || !ok {
+			log.Printf("Skipping unreadable task %s: %v", id, err)
+			continue
+		}
+		if task.Status == StatusRunning {
+			task.Status = StatusQueued
+		}
+		q.tasks[id] = &task
+	}
+	return q, nil
+}
+
+// Register sets the worker of a kind of task
+func (q *Queue) Register(kind string, worker Worker) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.workers[kind] = worker
+}
+
+// Enqueue adds a task to the queue and tells its conversation
+func (q *Queue) Enqueue(ctx context.Context, task Task) (*Task, error) {
+	if task.Input == "" {
+		return nil, fmt.Errorf("task input is required")
+	}
+	q.mu.Lock()
+	_, known := q.workers[task.Kind]
+	q.mu.Unlock()
+	if !known {
+		return nil, fmt.Errorf("unknown kind of task %q", task.Kind)
+	}
+
+	now := time.Now()
+	task.ID = uuid.New().String()[:8]
+	task.Status = StatusQueued
+	task.Attempts, task.Progress, task.ProgressNote, task.Result, task.Error = 0, 0, "", "", ""
+	task.CreatedAt, task.UpdatedAt, task.RunAt = now, now, now
+
+	q.mu.Lock()
+	q.tasks[task.ID] = &task
+	cp := task
+	q.mu.Unlock()
+
+	if err := q.commit(ctx, &cp, fmt.Sprintf("⏳ Task %s queued (%s): %s", cp.ID, cp.Kind, cp.Input)); err != nil {
+		q.mu.Lock()
+		delete(q.tasks, task.ID)
+		q.mu.Unlock()
+		return nil, fmt.Errorf("failed to store task: %w", err)
+	}
+	q.notify()
+
+	log.Printf("Queued %s task %s for agent %s", cp.Kind, cp.ID, cp.AgentID)
+	return &cp, nil
+}
+
+// Get returns a copy of a task
+func (q *Queue) Get(id string) (*Task, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	task, exists := q.tasks[id]
+	if !exists {
+		return nil, false
+	}
+	cp := *task
+	return &cp, true
+}
+
+// List returns the tasks of a conversation, or all tasks for "", newest first
+func (q *Queue) List(conversationID string) []*Task {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	tasks := make([]*Task, 0)
+	for _, task := range q.tasks {
+		if conversationID == "" || task.ConversationID == conversationID {
+			cp := *task
+			tasks = append(tasks, &cp)
+		}
+	}
+	sort.Slice(tasks, func(i, j int) bool {
+		return tasks[i].CreatedAt.After(tasks[j].CreatedAt)
+	})
+	return tasks
+}
+
+// Run processes tasks on the configured number of workers until ctx is done
+func (q *Queue) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for i := 0; i < q.config.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			q.work(ctx)
+		}()
+	}
+	log.Printf("Task queue running with %d worker(s)", q.config.Workers)
+	wg.Wait()
+}
+
+// work runs due tasks one at a time, sleeping until the next one is due
+func (q *Queue) work(ctx context.Context) {
+	for {
+		task, wait := q.next()
+		if task != nil {
+			q.process(ctx, task)
+			continue
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-q.wake:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+// next claims the queued task that has been due the longest, or returns how
+// long to wait for one
+func (q *Queue) next() (*Task, time.Duration) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	now := time.Now()
+	var due *Task
+	wait := time.Minute
+	for _, task := range q.tasks {
+		if task.Status != StatusQueued {
+			continue
+		}
+		if task.RunAt.After(now) {
+			wait = min(wait, task.RunAt.Sub(now))
+			continue
+		}
+		if due == nil || task.RunAt.Before(due.RunAt) {
+			due = task
+		}
+	}
+	if due == nil {
+		return nil, wait
+	}
+
+	due.Status = StatusRunning
+	due.Attempts++
+	due.UpdatedAt = now
+	cp := *due
+	return &cp, 0
+}
+
+// process runs one attempt of a task and records how it went
+func (q *Queue) process(ctx context.Context, task *Task) {
+	q.mu.Lock()
+	worker := q.workers[task.Kind]
+	q.mu.Unlock()
+
+	if err := q.commit(ctx, task, ""); err != nil {
+		log.Printf("Failed to store task %s: %v", task.ID, err)
+	}
+
+	var result string
+	var err error
+	if worker == nil {
+		err = fmt.Errorf("no worker for %s tasks", task.Kind)
+	} else {
+		attemptCtx, cancel := context.WithTimeout(ctx, q.attemptTimeout())
+		result, err = worker(attemptCtx, task, func(percent int, note string) {
+			q.report(ctx, task.ID, percent, note)
+		})
+		cancel()
+	}
+
+	// Tasks interrupted by shutdown run again after a restart
+	if ctx.Err() != nil {
+		return
+	}
+
+	var status string
+	done := q.update(task.ID, func(t *Task) {
+		t.UpdatedAt = time.Now()
+		switch {
+		case err == nil:
+			t.Status, t.Progress, t.ProgressNote, t.Result, t.Error = StatusDone, 100, "", result, ""
+			status = fmt.Sprintf("✅ Task %s (%s) is done.", t.ID, t.Kind)
+		case t.Attempts < q.maxAttempts():
+			delay := q.retryDelay(t.Attempts)
+			t.Status, t.Error, t.RunAt = StatusQueued, err.Error(), t.UpdatedAt.Add(delay)
+			status = fmt.Sprintf("🔁 Task %s (%s) failed: %v. Retrying in %s (attempt %d of %d).", t.ID, t.Kind, err, delay.Round(time.Second), t.Attempts+1, q.maxAttempts())
+		default:
+			t.Status, t.Error = StatusFailed, err.Error()
+			status = fmt.Sprintf("❌ Task %s (%s) failed after %d attempt(s): %v", t.ID, t.Kind, t.Attempts, err)
+		}
+	})
+	if err := q.commit(ctx, done, status); err != nil {
+		log.Printf("Failed to store task %s: %v", task.ID, err)
+	}
+	q.prune(ctx)
+	q.notify()
+}
+
+// report records the progress of a running task and tells its conversation
+func (q *Queue) report(ctx context.Context, taskID string, percent int, note string) {
+	percent = max(0, min(percent, 99))
+	task := q.update(taskID, func(t *Task) {
+		t.Progress, t.ProgressNote, t.UpdatedAt = percent, note, time.Now()
+	})
+
+	content := fmt.Sprintf("⏳ Task %s (%s) is %d%% done", task.ID, task.Kind, percent)
+	if note != "" {
+		content += ": " + note
+	}
+	if err := q.commit(ctx, task, content); err != nil {
+		log.Printf("Failed to store progress of task %s: %v", taskID, err)
+	}
+}
+
+// update changes a task and returns a copy
+func (q *Queue) update(taskID string, change func(t *Task)) *Task {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	task := q.tasks[taskID]
+	change(task)
+	cp := *task
+	return &cp
+}
+
+// commit stores a task together with a status message for its conversation,
+// if there is one to post
+func (q *Queue) commit(ctx context.Context, task *Task, status string) error {
+	var message *types.ChatMessage
+	if status != "" && task.ConversationID != "" {
+		message = statusMessage(task, status)
+	}
+
+	if q.outbox == nil {
+		if message == nil {
+			return nil
+		}
+		return q.publisher.PublishMessage(ctx, message)
+	}
+	return q.outbox.Update(func(tx *outbox.Tx) error {
+		if err := tx.Put(bucket, task.ID, task); err != nil {
+			return err
+		}
+		if message == nil {
+			return nil
+		}
+		return tx.Publish(message)
+	})
+}
+
+// prune forgets the oldest finished tasks beyond maxFinished
+func (q *Queue) prune(ctx context.Context) {
+	q.mu.Lock()
+	var finished []*Task
+	for _, task := range q.tasks {
+		if task.finished() {
+			finished = append(finished, task)
+		}
+	}
+	if len(finished) <= maxFinished {
+		q.mu.Unlock()
+		return
+	}
+	sort.Slice(finished, func(i, j int) bool {
+		return finished[i].UpdatedAt.Before(finished[j].UpdatedAt)
+	})
+	finished = finished[:len(finished)-maxFinished]
+	for _, task := range finished {
+		delete(q.tasks, task.ID)
+	}
+	q.mu.Unlock()
+
+	if q.outbox == nil {
+		return
+	}
+	err := q.outbox.Update(func(tx *outbox.Tx) error {
+		for _, task := range finished {
+			tx.Delete(bucket, task.ID)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Failed to forget finished tasks: %v", err)
+	}
+}
+
+// notify wakes an idle worker
+func (q *Queue) notify() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// statusMessage builds a system message about a task
+func statusMessage(task *Task, content string) *types.ChatMessage {
+	return &types.ChatMessage{
+		ID:        uuid.New().String(),
+		Type:      types.MessageTypeSystem,
+		Content:   content,
+		AgentID:   "system",
+		Timestamp: time.Now(),
+		Metadata: types.Metadata{
+			ConversationID: task.ConversationID,
+			FromAgent:      "Moderator",
+			Tags:           []string{Tag},
+			Custom: map[string]string{
+				TaskIDKey:       task.ID,
+				TaskStatusKey:   task.Status,
+				TaskProgressKey: strconv.Itoa(task.Progress),
+			},
+		},
+	}
+}
+
+// attemptTimeout returns how long one attempt of a task may take
+func (q *Queue) attemptTimeout() time.Duration {
+	if q.config.Timeout > 0 {
+		return q.config.Timeout
+	}
+	return 10 * time.Minute
+}
+
+// maxAttempts returns how often a task is tried before it fails
+func (q *Queue) maxAttempts() int {
+	if q.config.MaxAttempts > 0 {
+		return q.config.MaxAttempts
+	}
+	return 1
+}
+
+// retryDelay returns the wait before the next attempt, doubling with every failed one
+func (q *Queue) retryDelay(attempts int) time.Duration {
+	delay := q.config.RetryDelay
+	if delay <= 0 {
+		delay = 30 * time.Second
+	}
+	for i := 1; i < attempts && delay < time.Hour; i++ {
+		delay *= 2
+	}
+	return min(delay, time.Hour)
+}
@@ -0,0 +1,38 @@
+package types
+
+import "strings"
+
+// CommandPrefix marks a chat message as a command (e.g. "/roll 2d6")
+const CommandPrefix = "/"
+
+// Command is a structured command parsed from a chat message
+type Command struct {
+	Name string   `json:"name"`           // Command name without the prefix, lower-cased
+	Args []string `json:"args,omitempty"` // Whitespace-separated arguments
+	Raw  string   `json:"raw"`            // Everything after the command name
+}
+
+// ParseCommand parses a command message, returning nil if the content is not a command
+func ParseCommand(content string) *Command {
+	content = strings.TrimSpace(content)
+	if !strings.HasPrefix(content, CommandPrefix) || len(content) == len(CommandPrefix) {
+		return nil
+	}
+
+	name, raw, _ := strings.Cut(strings.TrimPrefix(content, CommandPrefix), " ")
+	if name == "" {
+		return nil
+	}
+
+	raw = strings.TrimSpace(raw)
+	return &Command{
+		Name: strings.ToLower(name),
+		Args: strings.Fields(raw),
+		Raw:  raw,
+	}
+}
+
+// IsCommand reports whether the message carries a parsed command
+func (m *ChatMessage) IsCommand() bool {
+	return m.Metadata.Command != nil
+}
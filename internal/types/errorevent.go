@@ -0,0 +1,29 @@
+package types
+
+import "time"
+
+// ErrorKind classifies a failure in the error feed
+type ErrorKind string
+
+const (
+	ErrorKindProvider   ErrorKind = "provider"   // An LLM provider failed or could not be reached
+	ErrorKindBroker     ErrorKind = "broker"     // Kafka could not be written to
+	ErrorKindValidation ErrorKind = "validation" // Invalid settings or input
+	ErrorKindPanic      ErrorKind = "panic"      // A handler panicked
+	ErrorKindTimeout    ErrorKind = "timeout"    // A message took longer than the agent may take
+	ErrorKindInternal   ErrorKind = "internal"   // Anything else
+)
+
+// ErrorEvent reports an agent failure to the admins
+type ErrorEvent struct {
+	ID             string    `json:"id"`
+	Kind           ErrorKind `json:"kind"`
+	AgentID        string    `json:"agent_id"`
+	ConversationID string    `json:"conversation_id,omitempty"`
+	MessageID      string    `json:"message_id,omitempty"` // The message being handled
+	Error          string    `json:"error"`
+	Provider       string    `json:"provider,omitempty"`    // Provider errors only
+	StatusCode     int       `json:"status_code,omitempty"` // Provider errors with a response only
+	Topic          string    `json:"topic,omitempty"`       // Broker errors only
+	Timestamp      time.Time `json:"timestamp"`
+}
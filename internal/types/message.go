@@ -13,8 +13,64 @@ const (
 	MessageTypeAgent   MessageType = "agent"
 	MessageTypeSystem  MessageType = "system"
 	MessageTypeContext MessageType = "context"
+	// MessageTypeQuestion is a question an agent puts to the human
+	MessageTypeQuestion MessageType = "question"
+	// MessageTypeDeletion tells consumers to remove an expired message
+	MessageTypeDeletion MessageType = "deletion"
+	// MessageTypeScore is a judge's evaluation of another agent's reply; the
+	// scores per criterion are in Metadata.Custom
+	MessageTypeScore MessageType = "score"
+	// MessageTypeWhiteboard announces an edit of a conversation's whiteboard;
+	// the content is a line diff and the new version is in Metadata.Custom
+	MessageTypeWhiteboard MessageType = "whiteboard"
+	// MessageTypeAlert tells operators about a problem, such as an agent the
+	// supervisor had to restart; details are in Metadata.Custom
+	MessageTypeAlert MessageType = "alert"
 )
 
+// DeletedMessageKey is the custom metadata key naming the message a deletion event removes
+const DeletedMessageKey = "message_id"
+
+// Custom metadata keys of whiteboard events
+const (
+	WhiteboardVersionKey = "version"
+	WhiteboardAuthorKey  = "author"
+	WhiteboardSummaryKey = "summary"
+)
+
+// Custom metadata keys of score messages; the remaining keys are criteria with scores from 1 to 10
+const (
+	ScoredMessageKey = "scored_message_id"
+	ScoredAgentKey   = "scored_agent_id"
+)
+
+// Custom metadata keys of alerts about an agent
+const (
+	AlertAgentKey  = "agent_id"
+	AlertReasonKey = "reason"
+)
+
+// CitationsKey is the custom metadata key of an agent reply's citations, a JSON array of Citation
+const CitationsKey = "citations"
+
+// Custom metadata keys of agent replies annotated with what generating them
+// took; the cost is only set for models with a configured price
+const (
+	LatencyKey          = "latency_ms"
+	PromptTokensKey     = "prompt_tokens"
+	CompletionTokensKey = "completion_tokens"
+	CostKey             = "cost_usd"
+)
+
+// Citation points at a message of another conversation that an agent reply
+// draws on; the reply refers to it as "[Ref]"
+type Citation struct {
+	Ref            string `json:"ref"`
+	ConversationID string `json:"conversation_id"`
+	MessageID      string `json:"message_id"`
+	Title          string `json:"title,omitempty"` // Of the cited conversation
+}
+
 // ChatMessage represents a message in the chat system
 type ChatMessage struct {
 	ID        string      `json:"id"`
@@ -26,15 +82,42 @@ type ChatMessage struct {
 	Metadata  Metadata    `json:"metadata,omitempty"`
 }
 
-// Metadata contains additional information about the message
+// Metadata contains additional information about the message. New fields
+// must be added to the Avro schema in internal/kafka/avro.go as well.
 type Metadata struct {
 	ConversationID string            `json:"conversation_id,omitempty"`
 	ReplyTo        string            `json:"reply_to,omitempty"`
 	FromAgent      string            `json:"from_agent,omitempty"` // Human-readable agent name
+	Command        *Command          `json:"command,omitempty"`    // Set when the message is a "/command"
+	Required       bool              `json:"required,omitempty"`   // Question must be answered before agents continue
+	ExpiresAt      *time.Time        `json:"expires_at,omitempty"` // Ephemeral messages disappear after this time
 	Tags           []string          `json:"tags,omitempty"`
 	Custom         map[string]string `json:"custom,omitempty"`
 }
 
+// Types of agent messages
+const (
+	// AgentMessageNote is a private remark the recipient takes into account in its next reply
+	AgentMessageNote = "note"
+	// AgentMessageHandoff transfers a task to the recipient, or to whoever accepts it when broadcast
+	AgentMessageHandoff = "handoff"
+	// AgentMessageHandoffAccept offers to take over a handed off task
+	AgentMessageHandoffAccept = "handoff_accept"
+	// AgentMessageHandoffDecline refuses a handed off task
+	AgentMessageHandoffDecline = "handoff_decline"
+	// AgentMessageHandoffAssign tells the accepting agent the task is now theirs
+	AgentMessageHandoffAssign = "handoff_assign"
+)
+
+// HandoffPayload is the payload of the handoff messages. The handoff itself
+// carries the task and its context; answers and assignments repeat its ID.
+type HandoffPayload struct {
+	HandoffID string `json:"handoff_id"`
+	Task      string `json:"task,omitempty"`
+	Context   string `json:"context,omitempty"` // What the new owner needs to know, e.g. what was tried
+	Reason    string `json:"reason,omitempty"`  // Why a handoff was declined
+}
+
 // AgentMessage represents a message sent between agents
 type AgentMessage struct {
 	ID             string      `json:"id"`
@@ -46,6 +129,15 @@ type AgentMessage struct {
 	ConversationID string      `json:"conversation_id"`
 }
 
+// DecodePayload decodes the payload into v; after a trip through Kafka it is plain JSON
+func (m *AgentMessage) DecodePayload(v interface{}) error {
+	data, err := json.Marshal(m.Payload)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
 // KafkaMessage wraps messages for Kafka transport
 type KafkaMessage struct {
 	Topic   string          `json:"topic"`
@@ -53,6 +145,46 @@ type KafkaMessage struct {
 	Payload json.RawMessage `json:"payload"`
 }
 
+// SetTTL makes the message ephemeral, expiring ttl from its timestamp
+func (m *ChatMessage) SetTTL(ttl time.Duration) {
+	if ttl <= 0 {
+		return
+	}
+	expiresAt := m.Timestamp.Add(ttl)
+	m.Metadata.ExpiresAt = &expiresAt
+}
+
+// Expired reports whether an ephemeral message has outlived its TTL
+func (m *ChatMessage) Expired(now time.Time) bool {
+	return m.Metadata.ExpiresAt != nil && !now.Before(*m.Metadata.ExpiresAt)
+}
+
+// SetCitations records the messages of other conversations the message cites
+func (m *ChatMessage) SetCitations(citations []Citation) {
+	if len(citations) == 0 {
+		return
+	}
+	data, err := json.Marshal(citations)
+	if err != nil {
+		return
+	}
+	if m.Metadata.Custom == nil {
+		m.Metadata.Custom = make(map[string]string)
+	}
+	m.Metadata.Custom[CitationsKey] = string(data)
+}
+
+// Citations returns the messages of other conversations the message cites
+func (m *ChatMessage) Citations() []Citation {
+	var citations []Citation
+	if data := m.Metadata.Custom[CitationsKey]; data != "" {
+		if err := json.Unmarshal([]byte(data), &citations); err != nil {
+			return nil
+		}
+	}
+	return citations
+}
+
 // ToJSON converts a message to JSON bytes
 func (m *ChatMessage) ToJSON() ([]byte, error) {
 	return json.Marshal(m)
@@ -0,0 +1,20 @@
+package types
+
+import "time"
+
+// PresenceStatus tells whether a user is connected
+type PresenceStatus string
+
+const (
+	PresenceOnline  PresenceStatus = "online"
+	PresenceOffline PresenceStatus = "offline"
+)
+
+// PresenceEvent announces a WebSocket user coming online or going offline
+type PresenceEvent struct {
+	UserID     string         `json:"user_id"`
+	Name       string         `json:"name"`
+	Status     PresenceStatus `json:"status"`
+	InstanceID string         `json:"instance_id"` // Web replica the user is connected to
+	Timestamp  time.Time      `json:"timestamp"`
+}
@@ -0,0 +1,119 @@
+package web
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strconv"
+
+	"philoking/internal/agent"
+	"philoking/internal/moderation"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultLastCalls is how many LLM calls the debug API returns unless asked for more or fewer
+const defaultLastCalls = 5
+
+// defaultDryRunMessages is how many would-be messages of a dry-running agent the debug API returns by default
+const defaultDryRunMessages = 20
+
+// adminTokenHeader carries the admin token of requests to the admin endpoints
+const adminTokenHeader = "X-Admin-Token"
+
+// actorKey is the gin context key of who made an authenticated request, as
+// recorded in the audit trail
+const actorKey = "actor"
+
+// requireAdmin lets requests through only with the configured admin token;
+// without one the admin endpoints are disabled
+func (s *Server) requireAdmin(c *gin.Context) {
+	if s.config.AdminToken == "" {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "the admin API is disabled (web.admin_token is not set)"})
+		return
+	}
+	token := c.GetHeader(adminTokenHeader)
+	if subtle.ConstantTimeCompare([]byte(token), []byte(s.config.AdminToken)) != 1 {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "a valid " + adminTokenHeader + " header is required"})
+		return
+	}
+	c.Set(actorKey, "admin") // Admins share the token, so that is all we know of them
+	c.Next()
+}
+
+// handleGetScratchpad returns the agents' reasoning in a conversation, of
+// one agent with ?agent_id=
+func (s *Server) handleGetScratchpad(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"notes": s.convManager.ScratchNotes(c.Param("id"), c.Query("agent_id"))})
+}
+
+// handleGetLastCalls returns an agent's latest LLM calls, newest first: the
+// exact prompts and the raw provider responses, with keys redacted. ?n=
+// picks how many.
+func (s *Server) handleGetLastCalls(c *gin.Context) {
+	a, exists := s.agentManager.GetAgent(c.Param("id"))
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "agent not found"})
+		return
+	}
+	recorder, ok := a.(agent.CallRecorder)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "the agent does not call an LLM"})
+		return
+	}
+
+	n, ok := countParam(c, defaultLastCalls)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"agent_id": a.ID(), "calls": recorder.LastCalls(n)})
+}
+
+// handleGetDryRun returns what a dry-running agent would have sent, newest
+// first. ?n= picks how many.
+func (s *Server) handleGetDryRun(c *gin.Context) {
+	a, exists := s.agentManager.GetAgent(c.Param("id"))
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "agent not found"})
+		return
+	}
+	runner, ok := a.(agent.DryRunner)
+	if !ok || !runner.DryRun() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "the agent is not dry-running"})
+		return
+	}
+
+	n, ok := countParam(c, defaultDryRunMessages)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"agent_id": a.ID(), "messages": runner.DryRunMessages(n)})
+}
+
+// countParam reads the ?n= query parameter, falling back to the given default
+func countParam(c *gin.Context, fallback int) (int, bool) {
+	value := c.Query("n")
+	if value == "" {
+		return fallback, true
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "n must be a positive number"})
+		return 0, false
+	}
+	return n, true
+}
+
+// UseModeration filters the messages users send before they are posted
+func (s *Server) UseModeration(filter *moderation.Filter) {
+	s.moderation = filter
+	s.flags.moderation.Store(true)
+}
+
+// handleGetModerationFlags returns the borderline user messages that were posted, newest first
+func (s *Server) handleGetModerationFlags(c *gin.Context) {
+	if s.moderation == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "moderation is not enabled"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"flags": s.moderation.Flags()})
+}
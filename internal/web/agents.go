@@ -0,0 +1,181 @@
+package web
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"sort"
+
+	"philoking/internal/agent"
+	"philoking/internal/config"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// handleGetAgents returns information about available agents by ID, a page at a time
+func (s *Server) handleGetAgents(c *gin.Context) {
+	params, ok := parseListParams(c)
+	if !ok {
+		return
+	}
+
+	agents := s.agentManager.ListAgents()
+	sort.Slice(agents, func(i, j int) bool {
+		return agents[i].ID() < agents[j].ID()
+	})
+
+	result := make([]gin.H, 0, len(agents))
+	for _, a := range agents {
+		info := gin.H{
+			"id":   a.ID(),
+			"name": a.Name(),
+		}
+		if reporter, ok := a.(agent.StatsReporter); ok {
+			stats := reporter.Stats()
+			info["running"] = stats.Running
+			info["response_chance"] = stats.ResponseChance
+		}
+		result = append(result, info)
+	}
+
+	respondPage(c, "agents", result, func(info gin.H) string { return info["id"].(string) }, params)
+}
+
+// handleGetAgentStats returns the runtime statistics of a single agent
+func (s *Server) handleGetAgentStats(c *gin.Context) {
+	a, exists := s.agentManager.GetAgent(c.Param("id"))
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "agent not found"})
+		return
+	}
+
+	reporter, ok := a.(agent.StatsReporter)
+	if !ok {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "agent does not report statistics"})
+		return
+	}
+
+	c.JSON(http.StatusOK, reporter.Stats())
+}
+
+// handleCreateAgent creates and starts an agent from a JSON spec
+func (s *Server) handleCreateAgent(c *gin.Context) {
+	var req struct {
+		ID             string            `json:"id"` // Generated when empty
+		Type           string            `json:"type"`
+		Name           string            `json:"name" binding:"required"`
+		Model          string            `json:"model"`
+		Prompt         string            `json:"prompt"` // The agent's description, used in its system prompt
+		ResponseChance float64           `json:"response_chance"`
+		Preset         string            `json:"preset"`
+		Traits         []string          `json:"traits"`
+		Instructions   string            `json:"instructions"`
+		Settings       map[string]string `json:"settings"`
+		StartFrom      string            `json:"start_from"` // Empty only hears messages sent from now on
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.ID == "" {
+		req.ID = uuid.New().String()
+	}
+	if req.Type == "" && req.Preset == "" {
+		req.Type = "llm"
+	}
+
+	a, err := s.agentManager.CreateAgent(config.AgentConfig{
+		ID:             req.ID,
+		Name:           req.Name,
+		Type:           req.Type,
+		Model:          req.Model,
+		Description:    req.Prompt,
+		ResponseChance: req.ResponseChance,
+		IsEnabled:      true,
+		Preset:         req.Preset,
+		Traits:         req.Traits,
+		Instructions:   req.Instructions,
+		Settings:       req.Settings,
+		StartFrom:      req.StartFrom,
+	})
+	var invalid *config.ValidationError
+	switch {
+	case errors.Is(err, agent.ErrAgentExists):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case errors.As(err, &invalid):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	s.flowManager.RegisterParticipant(a.ID(), a.Name(), "agent")
+	c.JSON(http.StatusCreated, gin.H{"id": a.ID(), "name": a.Name()})
+}
+
+// handleCloneAgent creates a copy of an agent with a changed persona, e.g. an adversary for a debate
+func (s *Server) handleCloneAgent(c *gin.Context) {
+	var req struct {
+		ID             string   `json:"id"`
+		Name           string   `json:"name"`
+		Model          string   `json:"model"`
+		Temperature    float64  `json:"temperature"`
+		ResponseChance float64  `json:"response_chance"`
+		Traits         []string `json:"traits"`
+		Instructions   string   `json:"instructions"`
+		Oppose         bool     `json:"oppose"` // Take the opposite stance to the original
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	a, err := s.agentManager.CloneAgent(c.Param("id"), agent.Clone(req))
+	switch {
+	case errors.Is(err, agent.ErrAgentNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, agent.ErrAgentExists):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	s.flowManager.RegisterParticipant(a.ID(), a.Name(), "agent")
+	c.JSON(http.StatusCreated, gin.H{"id": a.ID(), "name": a.Name()})
+}
+
+// handleRestartAgent stops an agent and starts it again
+func (s *Server) handleRestartAgent(c *gin.Context) {
+	id := c.Param("id")
+	err := s.agentManager.RestartAgent(id)
+	switch {
+	case errors.Is(err, agent.ErrAgentNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"restarted": id})
+}
+
+// handleDeleteAgent stops an agent and removes it
+func (s *Server) handleDeleteAgent(c *gin.Context) {
+	id := c.Param("id")
+	if err := s.agentManager.RemoveAgent(id); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	s.flowManager.UnregisterParticipant(id)
+	c.JSON(http.StatusOK, gin.H{"deleted": id})
+}
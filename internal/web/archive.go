@@ -0,0 +1,56 @@
+package web
+
+import (
+	"errors"
+	"net/http"
+
+	"philoking/internal/archive"
+
+	"github.com/gin-gonic/gin"
+)
+
+// handleArchive moves a conversation to cold storage right away
+func (s *Server) handleArchive(c *gin.Context) {
+	if s.archiver == nil {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "archiving is not configured"})
+		return
+	}
+
+	conversationID := c.Param("id")
+	if !s.convManager.HasConversation(conversationID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "conversation is not loaded"})
+		return
+	}
+
+	if err := s.archiver.Archive(c.Request.Context(), conversationID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"conversation_id": conversationID, "status": "archived"})
+}
+
+// handleRehydrate loads an archived conversation back into memory
+func (s *Server) handleRehydrate(c *gin.Context) {
+	if s.archiver == nil {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "archiving is not configured"})
+		return
+	}
+
+	conversationID := c.Param("id")
+	snapshot, err := s.archiver.Rehydrate(c.Request.Context(), conversationID)
+	switch {
+	case errors.Is(err, archive.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"conversation_id": conversationID,
+		"status":          "rehydrated",
+		"messages":        len(snapshot.Messages),
+	})
+}
@@ -0,0 +1,54 @@
+package web
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"philoking/internal/audit"
+
+	"github.com/gin-gonic/gin"
+)
+
+// auditEntry is an audit record with its line in the trail, which serves as its cursor
+type auditEntry struct {
+	line int
+	audit.Record
+}
+
+// UseAudit lets admins read the audit trail
+func (s *Server) UseAudit(l *audit.Log) {
+	s.audit = l
+}
+
+// handleListAudit lists the audit trail, oldest first unless ?order=desc, a
+// page at a time, optionally of one ?action= or ?subject=
+func (s *Server) handleListAudit(c *gin.Context) {
+	if s.audit == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "the audit trail is not available"})
+		return
+	}
+	params, ok := parseListParams(c)
+	if !ok {
+		return
+	}
+
+	records, err := s.audit.Records()
+	switch {
+	case errors.Is(err, audit.ErrNotStored):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	action, subject := c.Query("action"), c.Query("subject")
+	entries := make([]auditEntry, 0, len(records))
+	for i, record := range records {
+		if (action == "" || record.Action == action) && (subject == "" || record.Subject == subject) {
+			entries = append(entries, auditEntry{line: i + 1, Record: record})
+		}
+	}
+	respondPage(c, "records", entries, func(entry auditEntry) string { return strconv.Itoa(entry.line) }, params)
+}
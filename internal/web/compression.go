@@ -0,0 +1,81 @@
+package web
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// gzipWriter buffers a response so it can be compressed once its size is
+// known; a handler that flushes streams its response uncompressed instead
+type gzipWriter struct {
+	gin.ResponseWriter
+	body      bytes.Buffer
+	streaming bool
+}
+
+func (w *gzipWriter) Write(data []byte) (int, error) {
+	if w.streaming {
+		return w.ResponseWriter.Write(data)
+	}
+	return w.body.Write(data)
+}
+
+func (w *gzipWriter) WriteString(s string) (int, error) {
+	if w.streaming {
+		return w.ResponseWriter.WriteString(s)
+	}
+	return w.body.WriteString(s)
+}
+
+// Flush switches to streaming: what was buffered is sent as it is
+func (w *gzipWriter) Flush() {
+	if !w.streaming {
+		w.streaming = true
+		w.ResponseWriter.Write(w.body.Bytes())
+		w.body.Reset()
+	}
+	w.ResponseWriter.Flush()
+}
+
+// gzipResponses compresses API responses of at least minBytes for clients
+// that accept gzip, such as long agent essays and history listings
+func gzipResponses(minBytes int) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !strings.HasPrefix(c.Request.URL.Path, "/api/") || !strings.Contains(c.GetHeader("Accept-Encoding"), "gzip") {
+			c.Next()
+			return
+		}
+
+		w := &gzipWriter{ResponseWriter: c.Writer}
+		c.Writer = w
+		c.Next()
+		c.Writer = w.ResponseWriter
+		if w.streaming {
+			return
+		}
+
+		header := w.Header()
+		header.Add("Vary", "Accept-Encoding")
+		if w.body.Len() < minBytes || header.Get("Content-Encoding") != "" {
+			w.ResponseWriter.Write(w.body.Bytes())
+			return
+		}
+
+		var compressed bytes.Buffer
+		zw := gzip.NewWriter(&compressed)
+		zw.Write(w.body.Bytes())
+		if err := zw.Close(); err != nil {
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		header.Set("Content-Encoding", "gzip")
+		header.Set("Content-Length", strconv.Itoa(compressed.Len()))
+		w.ResponseWriter.Write(compressed.Bytes())
+	}
+}
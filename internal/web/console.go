@@ -0,0 +1,121 @@
+package web
+
+import (
+	"log"
+	"net/http"
+	"sort"
+	"sync/atomic"
+
+	"philoking/internal/agent"
+	"philoking/internal/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// runtimeFlags are the features admins switch on and off while the server runs
+type runtimeFlags struct {
+	moderation atomic.Bool // Check user messages; needs a moderation filter
+	debug      atomic.Bool // Serve the endpoints under /api/debug
+}
+
+// flagsUpdate switches the runtime flags that are set
+type flagsUpdate struct {
+	Moderation *bool `json:"moderation"`
+	Debug      *bool `json:"debug"`
+}
+
+// UseConfig lets admins read the configuration the server runs with, secrets redacted
+func (s *Server) UseConfig(cfg *config.Config) {
+	s.appConfig = cfg
+}
+
+// requireDebug refuses the debug endpoints while debug mode is off
+func (s *Server) requireDebug(c *gin.Context) {
+	if !s.flags.debug.Load() {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "debug mode is off"})
+		return
+	}
+	c.Next()
+}
+
+// handleGetConfig returns the effective configuration, secrets redacted
+func (s *Server) handleGetConfig(c *gin.Context) {
+	if s.appConfig == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "the configuration is not available"})
+		return
+	}
+	c.JSON(http.StatusOK, s.appConfig.Redacted())
+}
+
+// handleGetFlags returns the runtime flags
+func (s *Server) handleGetFlags(c *gin.Context) {
+	c.JSON(http.StatusOK, s.flagsJSON())
+}
+
+// handleUpdateFlags switches moderation and debug mode on or off until the server restarts
+func (s *Server) handleUpdateFlags(c *gin.Context) {
+	var update flagsUpdate
+	if err := c.ShouldBindJSON(&update); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if update.Moderation != nil && *update.Moderation && s.moderation == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "moderation is not configured"})
+		return
+	}
+
+	if update.Moderation != nil {
+		s.flags.moderation.Store(*update.Moderation)
+		log.Printf("Moderation switched %s by an admin", onOff(*update.Moderation))
+	}
+	if update.Debug != nil {
+		s.flags.debug.Store(*update.Debug)
+		log.Printf("Debug mode switched %s by an admin", onOff(*update.Debug))
+	}
+	c.JSON(http.StatusOK, s.flagsJSON())
+}
+
+// flagsJSON describes the runtime flags
+func (s *Server) flagsJSON() gin.H {
+	return gin.H{
+		"moderation":            s.flags.moderation.Load(),
+		"moderation_configured": s.moderation != nil,
+		"debug":                 s.flags.debug.Load(),
+	}
+}
+
+// onOff names the state of a flag in the log
+func onOff(enabled bool) string {
+	if enabled {
+		return "on"
+	}
+	return "off"
+}
+
+// handleGetTopology returns what this instance runs: the agents with their
+// statistics, the conversations and the connected clients
+func (s *Server) handleGetTopology(c *gin.Context) {
+	agents := s.agentManager.ListAgents()
+	sort.Slice(agents, func(i, j int) bool {
+		return agents[i].ID() < agents[j].ID()
+	})
+
+	nodes := make([]gin.H, 0, len(agents))
+	for _, a := range agents {
+		node := gin.H{
+			"id":   a.ID(),
+			"name": a.Name(),
+		}
+		if reporter, ok := a.(agent.StatsReporter); ok {
+			node["stats"] = reporter.Stats()
+		}
+		nodes = append(nodes, node)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"instance_id":   s.instanceID,
+		"agents":        nodes,
+		"conversations": s.convManager.ListInfo(),
+		"clients":       s.hub.Sessions(),
+	})
+}
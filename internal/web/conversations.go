@@ -0,0 +1,483 @@
+package web
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"time"
+
+	"philoking/internal/agent"
+	"philoking/internal/config"
+	"philoking/internal/conversation"
+	"philoking/internal/types"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// handleListConversations describes the conversations held in memory by ID, a page at a time
+func (s *Server) handleListConversations(c *gin.Context) {
+	params, ok := parseListParams(c)
+	if !ok {
+		return
+	}
+	respondPage(c, "conversations", s.convManager.ListInfo(), func(info *conversation.Info) string { return info.ID }, params)
+}
+
+// handleListMessages lists the messages of a conversation in the order they
+// were posted, a page at a time, optionally of the ?type= values or from ?agent_id=
+func (s *Server) handleListMessages(c *gin.Context) {
+	conversationID := c.Param("id")
+	if _, exists := s.convManager.GetInfo(conversationID); !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "conversation not found"})
+		return
+	}
+	params, ok := parseListParams(c)
+	if !ok {
+		return
+	}
+
+	messages := s.convManager.GetMessages(conversationID)
+	messages = filterMessages(messages, "", c.QueryArray("type"), c.Query("agent_id"))
+	respondPage(c, "messages", messages, func(message *types.ChatMessage) string { return message.ID }, params)
+}
+
+// filterMessages keeps the messages of a conversation, of the given types and
+// from a sender; empty filters keep all
+func filterMessages(messages []*types.ChatMessage, conversationID string, wanted []string, senderID string) []*types.ChatMessage {
+	var kept []*types.ChatMessage
+	for _, message := range messages {
+		if conversationID != "" && message.Metadata.ConversationID != conversationID {
+			continue
+		}
+		if senderID != "" && message.AgentID != senderID {
+			continue
+		}
+		if len(wanted) > 0 && !contains(wanted, string(message.Type)) {
+			continue
+		}
+		kept = append(kept, message)
+	}
+	return kept
+}
+
+// contains reports whether a list holds a string
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+// handleCreateConversation starts a conversation with a topic, a goal and,
+// optionally, a subset of the agents
+func (s *Server) handleCreateConversation(c *gin.Context) {
+	var req struct {
+		ID           string   `json:"id"` // Generated when empty
+		Title        string   `json:"title"`
+		Topic        string   `json:"topic"`
+		Goal         string   `json:"goal"`
+		Participants []string `json:"participants"` // Agent IDs; empty lets all agents take part
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.ID == "" {
+		req.ID = uuid.New().String()
+	}
+
+	info, err := s.flowManager.CreateConversation(c.Request.Context(), req.ID, req.Title, req.Topic, req.Goal, req.Participants)
+	switch {
+	case errors.Is(err, conversation.ErrConversationExists):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, info)
+}
+
+// handleUpdateConversation renames a conversation, sets its goal, topic or mood, or makes it private
+func (s *Server) handleUpdateConversation(c *gin.Context) {
+	var update conversation.ConversationUpdate
+	if err := c.ShouldBindJSON(&update); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	info, err := s.convManager.UpdateConversation(c.Param("id"), update)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, info)
+}
+
+// handleGetSummary returns the latest digest of a conversation
+func (s *Server) handleGetSummary(c *gin.Context) {
+	summary := s.convManager.GetSummary(c.Param("id"))
+	if summary == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "no summary available yet"})
+		return
+	}
+
+	c.JSON(http.StatusOK, summary)
+}
+
+// handleSummarize asks the first available summarizer agent for a fresh digest
+func (s *Server) handleSummarize(c *gin.Context) {
+	for _, a := range s.agentManager.ListAgents() {
+		summarizer, ok := a.(agent.Summarizer)
+		if !ok {
+			continue
+		}
+
+		summary, err := summarizer.Summarize(c.Request.Context(), c.Param("id"))
+		if err != nil && summary == nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, summary)
+		return
+	}
+
+	c.JSON(http.StatusNotFound, gin.H{"error": "no summarizer agent configured"})
+}
+
+// handleGetAnalytics returns dashboard data for a conversation; the optional
+// bucket query parameter (e.g. "5m") sets the volume resolution
+func (s *Server) handleGetAnalytics(c *gin.Context) {
+	bucket := time.Minute
+	if raw := c.Query("bucket"); raw != "" {
+		parsed, err := time.ParseDuration(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid bucket duration"})
+			return
+		}
+		bucket = parsed
+	}
+
+	c.JSON(http.StatusOK, s.flowManager.GetAnalytics(c.Param("id"), bucket))
+}
+
+// handleGetLeaderboard ranks agents by the scores judges gave their replies,
+// optionally limited to one conversation
+func (s *Server) handleGetLeaderboard(c *gin.Context) {
+	conversationID := c.Query("conversation_id")
+	c.JSON(http.StatusOK, gin.H{
+		"conversation_id": conversationID,
+		"agents":          s.flowManager.Leaderboard(conversationID),
+	})
+}
+
+// handleGetTimeline returns how the topic and mood of a conversation drifted
+func (s *Server) handleGetTimeline(c *gin.Context) {
+	conversationID := c.Param("id")
+	timeline := s.convManager.GetTimeline(conversationID)
+
+	// The latest entry holds the current topic and mood
+	var topic, mood string
+	if len(timeline) > 0 {
+		topic = timeline[len(timeline)-1].Topic
+		mood = timeline[len(timeline)-1].Mood
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"conversation_id": conversationID,
+		"topic":           topic,
+		"mood":            mood,
+		"timeline":        timeline,
+	})
+}
+
+// handleGetTranscript renders a conversation as a standalone HTML page;
+// ?download=true offers it as a file
+func (s *Server) handleGetTranscript(c *gin.Context) {
+	conversationID := c.Param("id")
+	snapshot, exists := s.convManager.Snapshot(conversationID)
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "conversation not found; rehydrate archived conversations first"})
+		return
+	}
+
+	var page bytes.Buffer
+	if err := conversation.WriteHTML(&page, snapshot); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if c.Query("download") == "true" {
+		c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": conversationID + ".html"}))
+	}
+	c.Data(http.StatusOK, "text/html; charset=utf-8", page.Bytes())
+}
+
+// handleGetPendingQuestion returns the question the agents are waiting on, if any
+func (s *Server) handleGetPendingQuestion(c *gin.Context) {
+	conversationID := c.Param("id")
+	question := s.convManager.GetPendingQuestion(conversationID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"conversation_id": conversationID,
+		"paused":          question != nil,
+		"question":        question,
+	})
+}
+
+// handleGetPhase returns the active phase of a conversation, if any
+func (s *Server) handleGetPhase(c *gin.Context) {
+	conversationID := c.Param("id")
+	c.JSON(http.StatusOK, gin.H{
+		"conversation_id": conversationID,
+		"phase":           s.convManager.GetPhase(conversationID),
+	})
+}
+
+// handleGetDebate returns the running or last debate of a conversation, if any
+func (s *Server) handleGetDebate(c *gin.Context) {
+	conversationID := c.Param("id")
+	c.JSON(http.StatusOK, gin.H{
+		"conversation_id": conversationID,
+		"debate":          s.convManager.GetDebate(conversationID),
+	})
+}
+
+// handleStartDebate has agents debate a proposition, moderated turn by turn
+func (s *Server) handleStartDebate(c *gin.Context) {
+	var req struct {
+		Proposition string   `json:"proposition" binding:"required"`
+		For         []string `json:"for" binding:"required"`
+		Against     []string `json:"against" binding:"required"`
+		Judge       string   `json:"judge"`
+		Rounds      int      `json:"rounds"`
+		WordLimit   int      `json:"word_limit"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	debate, err := s.flowManager.StartDebate(c.Request.Context(), c.Param("id"), req.Proposition, req.For, req.Against, req.Judge, req.Rounds, req.WordLimit)
+	if errors.Is(err, conversation.ErrDebateActive) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, debate)
+}
+
+// handleStopDebate ends a running debate early
+func (s *Server) handleStopDebate(c *gin.Context) {
+	if err := s.flowManager.StopDebate(c.Request.Context(), c.Param("id")); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"stopped": true})
+}
+
+// handleGetWrapUp returns the wrap-up of a conversation that went past the depth guard's caps, if any
+func (s *Server) handleGetWrapUp(c *gin.Context) {
+	conversationID := c.Param("id")
+	c.JSON(http.StatusOK, gin.H{
+		"conversation_id": conversationID,
+		"wrap_up":         s.convManager.GetWrapUp(conversationID),
+	})
+}
+
+// handleReopenConversation unlocks a conversation that was wrapped up
+func (s *Server) handleReopenConversation(c *gin.Context) {
+	if err := s.flowManager.ReopenConversation(c.Request.Context(), c.Param("id")); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"reopened": true})
+}
+
+// handleGetTutoring returns the running or last tutoring session of a conversation, with its progress
+func (s *Server) handleGetTutoring(c *gin.Context) {
+	conversationID := c.Param("id")
+	c.JSON(http.StatusOK, gin.H{
+		"conversation_id": conversationID,
+		"tutoring":        s.convManager.GetTutoring(conversationID),
+	})
+}
+
+// handleStartTutoring has an agent tutor the user through a syllabus
+func (s *Server) handleStartTutoring(c *gin.Context) {
+	var req struct {
+		Tutor    string `json:"tutor"`
+		Syllabus []struct {
+			Topic     string `json:"topic"`
+			Objective string `json:"objective"`
+		} `json:"syllabus"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var syllabus []config.LessonConfig
+	for _, lesson := range req.Syllabus {
+		syllabus = append(syllabus, config.LessonConfig{Topic: lesson.Topic, Objective: lesson.Objective})
+	}
+	tutoring, err := s.flowManager.StartTutoring(c.Request.Context(), c.Param("id"), req.Tutor, syllabus)
+	if errors.Is(err, conversation.ErrTutoringActive) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, tutoring)
+}
+
+// handleStopTutoring ends a running tutoring session
+func (s *Server) handleStopTutoring(c *gin.Context) {
+	if err := s.flowManager.StopTutoring(c.Request.Context(), c.Param("id")); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"stopped": true})
+}
+
+// handleGetWorld returns the world state of a story told in a conversation
+func (s *Server) handleGetWorld(c *gin.Context) {
+	conversationID := c.Param("id")
+	c.JSON(http.StatusOK, gin.H{
+		"conversation_id": conversationID,
+		"world":           s.convManager.GetWorld(conversationID),
+	})
+}
+
+// handleReplaceWorld sets up the world of a story, e.g. from a prepared setting
+func (s *Server) handleReplaceWorld(c *gin.Context) {
+	var world conversation.World
+	if err := c.ShouldBindJSON(&world); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	replaced, err := s.convManager.ReplaceWorld(c.Param("id"), &world, "api")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, replaced)
+}
+
+// handleUpdateWorld applies a list of operations to the world of a story, all or none
+func (s *Server) handleUpdateWorld(c *gin.Context) {
+	var update conversation.WorldUpdate
+	if err := c.ShouldBindJSON(&update); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	world, err := s.convManager.UpdateWorld(c.Param("id"), update, "api")
+	if errors.Is(err, conversation.ErrWorldVersion) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, world)
+}
+
+// handleGetWhiteboard returns the document a conversation is writing, with its recent edits
+func (s *Server) handleGetWhiteboard(c *gin.Context) {
+	conversationID := c.Param("id")
+	c.JSON(http.StatusOK, gin.H{
+		"conversation_id": conversationID,
+		"whiteboard":      s.convManager.GetWhiteboard(conversationID),
+	})
+}
+
+// handleEditWhiteboard applies an edit to the whiteboard and sends the diff to clients
+func (s *Server) handleEditWhiteboard(c *gin.Context) {
+	var req struct {
+		conversation.WhiteboardEdit
+		Author string `json:"author"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Author == "" {
+		req.Author = "api"
+	}
+
+	conversationID := c.Param("id")
+	whiteboard, revision, err := s.convManager.EditWhiteboard(conversationID, req.WhiteboardEdit, req.Author)
+	if errors.Is(err, conversation.ErrWhiteboardVersion) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := s.kafkaClient.PublishMessage(c.Request.Context(), conversation.NewWhiteboardEvent(conversationID, revision)); err != nil {
+		log.Printf("Failed to publish whiteboard edit: %v", err)
+	}
+	c.JSON(http.StatusOK, whiteboard)
+}
+
+// handleStartSideConversation lets two agents step aside to work out a detail
+func (s *Server) handleStartSideConversation(c *gin.Context) {
+	var req struct {
+		Topic    string   `json:"topic" binding:"required"`
+		Agents   []string `json:"agents" binding:"required"`
+		MaxTurns int      `json:"max_turns"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	side, err := s.flowManager.StartSideConversation(c.Request.Context(), c.Param("id"), req.Topic, req.Agents, req.MaxTurns)
+	if err != nil && side == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, side)
+}
+
+// handleListSideConversations lists the side conversations of a conversation
+func (s *Server) handleListSideConversations(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"side_conversations": s.convManager.ListSideConversations(c.Param("id"))})
+}
+
+// handleGetSideConversation returns a side conversation with its messages
+func (s *Server) handleGetSideConversation(c *gin.Context) {
+	side, exists := s.convManager.GetSideConversation(c.Param("id"))
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "side conversation not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"side_conversation": side,
+		"messages":          s.convManager.GetRecentMessages(side.ID, 1000),
+	})
+}
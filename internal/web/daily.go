@@ -0,0 +1,37 @@
+package web
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"philoking/internal/conversation"
+	"philoking/internal/daily"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UseDaily lets admins start the daily conversation early
+func (s *Server) UseDaily(scheduler *daily.Scheduler) {
+	s.daily = scheduler
+}
+
+// handleStartDaily starts today's daily conversation right away instead of
+// waiting for its time
+func (s *Server) handleStartDaily(c *gin.Context) {
+	if s.daily == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "daily conversations are not enabled"})
+		return
+	}
+
+	info, err := s.daily.Start(c.Request.Context(), time.Now())
+	if errors.Is(err, conversation.ErrConversationExists) {
+		c.JSON(http.StatusConflict, gin.H{"error": "today's conversation has already started"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, info)
+}
@@ -0,0 +1,95 @@
+package web
+
+import (
+	"net/http"
+
+	"philoking/internal/digest"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UseDigest enables the digest subscription endpoints
+func (s *Server) UseDigest(digester *digest.Digester) {
+	s.digest = digester
+}
+
+// requireDigest responds with an error unless the email digest is enabled
+func (s *Server) requireDigest(c *gin.Context) bool {
+	if s.digest == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "the email digest is not enabled"})
+		return false
+	}
+	return true
+}
+
+// handleGetDigestSubscription returns the caller's digest subscription
+func (s *Server) handleGetDigestSubscription(c *gin.Context) {
+	if !s.requireDigest(c) {
+		return
+	}
+
+	subscription, exists := s.digest.Subscription(c.GetString(sessionUserKey))
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not subscribed"})
+		return
+	}
+	c.JSON(http.StatusOK, subscription)
+}
+
+// handleSubscribeDigest subscribes the caller to the digest, or changes the subscription
+func (s *Server) handleSubscribeDigest(c *gin.Context) {
+	if !s.requireDigest(c) {
+		return
+	}
+
+	var req struct {
+		Email         string   `json:"email" binding:"required"`
+		Conversations []string `json:"conversations"` // Empty means all
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	subscription, err := s.digest.Subscribe(digest.Subscription{
+		UserID:        c.GetString(sessionUserKey),
+		Email:         req.Email,
+		Conversations: req.Conversations,
+	})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, subscription)
+}
+
+// handleUnsubscribeDigest ends the caller's digest subscription
+func (s *Server) handleUnsubscribeDigest(c *gin.Context) {
+	if !s.requireDigest(c) {
+		return
+	}
+
+	removed, err := s.digest.Unsubscribe(c.GetString(sessionUserKey))
+	switch {
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	case !removed:
+		c.JSON(http.StatusNotFound, gin.H{"error": "not subscribed"})
+	default:
+		c.JSON(http.StatusOK, gin.H{"status": "unsubscribed"})
+	}
+}
+
+// handleSendDigest sends the digest right away instead of waiting for its time
+func (s *Server) handleSendDigest(c *gin.Context) {
+	if !s.requireDigest(c) {
+		return
+	}
+
+	sent, err := s.digest.Send(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error(), "sent": sent})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"sent": sent})
+}
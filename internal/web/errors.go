@@ -0,0 +1,62 @@
+package web
+
+import (
+	"net/http"
+	"sync"
+
+	"philoking/internal/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	errorFeedSize      = 500 // Agent failures the error feed keeps
+	defaultErrorsShown = 50  // Failures the admin API returns unless asked for more or fewer
+)
+
+// errorFeed keeps the latest agent failures reported on the errors topic
+type errorFeed struct {
+	mu     sync.Mutex
+	events []types.ErrorEvent
+}
+
+// add records a failure, dropping the oldest once the feed is full
+func (f *errorFeed) add(event *types.ErrorEvent) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.events = append(f.events, *event)
+	if len(f.events) > errorFeedSize {
+		f.events = f.events[len(f.events)-errorFeedSize:]
+	}
+}
+
+// latest returns up to n failures, newest first, of the given kind and agent if set
+func (f *errorFeed) latest(n int, kind types.ErrorKind, agentID string) []types.ErrorEvent {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	events := make([]types.ErrorEvent, 0, min(n, len(f.events)))
+	for i := len(f.events) - 1; i >= 0 && len(events) < n; i-- {
+		event := f.events[i]
+		if (kind != "" && event.Kind != kind) || (agentID != "" && event.AgentID != agentID) {
+			continue
+		}
+		events = append(events, event)
+	}
+	return events
+}
+
+// handleGetErrors returns the latest agent failures, newest first. ?n= picks
+// how many, ?kind= and ?agent_id= filter them.
+func (s *Server) handleGetErrors(c *gin.Context) {
+	if !s.kafkaClient.ErrorsEnabled() {
+		c.JSON(http.StatusNotFound, gin.H{"error": "the error feed is disabled (kafka.topics.errors is empty)"})
+		return
+	}
+	n, ok := countParam(c, defaultErrorsShown)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"errors": s.errors.latest(n, types.ErrorKind(c.Query("kind")), c.Query("agent_id"))})
+}
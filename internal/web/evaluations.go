@@ -0,0 +1,88 @@
+package web
+
+import (
+	"errors"
+	"net/http"
+
+	"philoking/internal/agent"
+	"philoking/internal/evaluation"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultShadowComparisons is how many comparisons with a shadow the evaluation API returns by default
+const defaultShadowComparisons = 20
+
+// handleGetShadowComparisons returns an agent's latest replies next to those
+// of its shadow variant, newest first. ?n= picks how many.
+func (s *Server) handleGetShadowComparisons(c *gin.Context) {
+	a, exists := s.agentManager.GetAgent(c.Param("id"))
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "agent not found"})
+		return
+	}
+	evaluator, ok := a.(agent.ShadowEvaluator)
+	if !ok || !evaluator.HasShadow() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "the agent has no shadow"})
+		return
+	}
+
+	n, ok := countParam(c, defaultShadowComparisons)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"agent_id": a.ID(), "comparisons": evaluator.ShadowComparisons(n)})
+}
+
+// UseEvaluations enables the evaluation report endpoints
+func (s *Server) UseEvaluations(evaluator *evaluation.Evaluator) {
+	s.evaluations = evaluator
+}
+
+// requireEvaluations responds with an error unless evaluation reports are enabled
+func (s *Server) requireEvaluations(c *gin.Context) bool {
+	if s.evaluations == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "evaluation reports are not enabled"})
+		return false
+	}
+	return true
+}
+
+// handleListEvaluations lists the evaluation reports with their per-agent scores, newest first
+func (s *Server) handleListEvaluations(c *gin.Context) {
+	if !s.requireEvaluations(c) {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"reports": s.evaluations.List()})
+}
+
+// handleGetEvaluation returns an evaluation report, including the scores of every conversation
+func (s *Server) handleGetEvaluation(c *gin.Context) {
+	if !s.requireEvaluations(c) {
+		return
+	}
+	report, exists := s.evaluations.Get(c.Param("id"))
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "report not found"})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// handleStartEvaluation evaluates the stored conversations now; the report
+// it returns is filled in when the judge is done
+func (s *Server) handleStartEvaluation(c *gin.Context) {
+	if !s.requireEvaluations(c) {
+		return
+	}
+	report, err := s.evaluations.Start(c.Request.Context())
+	if errors.Is(err, evaluation.ErrRunning) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusAccepted, report)
+}
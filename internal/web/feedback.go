@@ -0,0 +1,91 @@
+package web
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"philoking/internal/agent"
+	"philoking/internal/conversation"
+
+	"github.com/gin-gonic/gin"
+)
+
+// rateMessage records a user's rating, lets the rated agent learn from it and
+// tells the clients about the new tally
+func (s *Server) rateMessage(conversationID, messageID, userID string, positive bool) (conversation.FeedbackTally, error) {
+	if conversationID == "" {
+		conversationID = defaultConversationID
+	}
+
+	agentID, tally, err := s.convManager.RateMessage(conversationID, messageID, userID, positive)
+	if err != nil {
+		return tally, err
+	}
+
+	if a, exists := s.agentManager.GetAgent(agentID); exists {
+		if receiver, ok := a.(agent.FeedbackReceiver); ok {
+			receiver.ReceiveFeedback(positive)
+		}
+	}
+
+	data, err := json.Marshal(map[string]interface{}{
+		"type":            "feedback",
+		"conversation_id": conversationID,
+		"message_id":      messageID,
+		"likes":           tally.Likes,
+		"dislikes":        tally.Dislikes,
+	})
+	if err != nil {
+		log.Printf("Error marshaling feedback: %v", err)
+		return tally, nil
+	}
+	s.hub.Broadcast(data)
+	return tally, nil
+}
+
+// handleRateMessage rates an agent message with a thumbs up or down, as the caller
+func (s *Server) handleRateMessage(c *gin.Context) {
+	var req struct {
+		Rating string `json:"rating"` // "up" or "down"
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || (req.Rating != "up" && req.Rating != "down") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": `a rating of "up" or "down" is required`})
+		return
+	}
+
+	tally, err := s.rateMessage(c.Param("id"), c.Param("messageId"), c.GetString(sessionUserKey), req.Rating == "up")
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"conversation_id": c.Param("id"),
+		"message_id":      c.Param("messageId"),
+		"likes":           tally.Likes,
+		"dislikes":        tally.Dislikes,
+	})
+}
+
+// handleGetMessageFeedback returns the ratings of a message
+func (s *Server) handleGetMessageFeedback(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"conversation_id": c.Param("id"),
+		"message_id":      c.Param("messageId"),
+		"ratings":         s.convManager.GetRatings(c.Param("id"), c.Param("messageId")),
+	})
+}
+
+// handleGetAgentFeedback returns how users rated an agent in a conversation
+func (s *Server) handleGetAgentFeedback(c *gin.Context) {
+	conversationID := c.DefaultQuery("conversation_id", defaultConversationID)
+	agentID := c.Param("id")
+
+	c.JSON(http.StatusOK, gin.H{
+		"agent_id":        agentID,
+		"conversation_id": conversationID,
+		"feedback":        s.convManager.GetAgentFeedback(conversationID, agentID),
+		"prompt":          s.convManager.FeedbackPrompt(conversationID, agentID),
+	})
+}
@@ -0,0 +1,460 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+
+	"philoking/internal/agent"
+	"philoking/internal/conversation"
+	"philoking/internal/graphql"
+	"philoking/internal/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// feedBuffer is the number of messages queued per GraphQL subscription; a
+// subscription that falls further behind misses messages
+const feedBuffer = 64
+
+// graphqlSDL describes the schema built by graphqlSchema, for frontend tooling
+const graphqlSDL = `type Query {
+  conversations(first: Int, after: String, last: Int, before: String): ConversationConnection!
+  conversation(id: ID!): Conversation
+  agents: [Agent!]!
+  agent(id: ID!): Agent
+}
+
+type Subscription {
+  # Messages as they are broadcast, optionally of one conversation, of some
+  # types (e.g. ["agent"]) or from one agent or user
+  messages(conversation_id: ID, types: [String!], agent_id: ID): Message!
+}
+
+type Conversation {
+  id: ID!
+  title: String
+  topic: String
+  mood: String
+  goal: String
+  members: [ID!]
+  private: Boolean
+  created_at: String!
+  updated_at: String!
+  message_count: Int!
+  messages(first: Int, after: String, last: Int, before: String, types: [String!]): MessageConnection!
+  participants: [Participant!]!
+  summary: Summary
+}
+
+type Message {
+  id: ID!
+  type: String!
+  content: String!
+  agent_id: ID
+  user_id: ID
+  timestamp: String!
+  metadata: Metadata!
+}
+
+type Metadata {
+  conversation_id: ID
+  reply_to: ID
+  from_agent: String
+  tags: [String!]
+  expires_at: String
+  custom: JSON
+}
+
+type Participant {
+  id: ID!
+  name: String!
+  type: String!
+  is_active: Boolean!
+  last_seen: String!
+}
+
+type Summary {
+  content: String!
+  agent_id: ID!
+  message_count: Int!
+  created_at: String!
+}
+
+type Agent {
+  id: ID!
+  name: String!
+  stats: AgentStats
+}
+
+type AgentStats {
+  running: Boolean!
+  response_chance: Float!
+  effective_response_chance: Float!
+  messages_seen: Int!
+  responses_sent: Int!
+  skipped_by_chance: Int!
+  ignored_by_rule: Int!
+  llm_calls: Int!
+  llm_failures: Int!
+  panics: Int!
+  average_latency_ms: Float!
+  last_response_at: String
+}
+
+type ConversationConnection {
+  nodes: [Conversation!]!
+  page_info: PageInfo!
+  total_count: Int!
+}
+
+type MessageConnection {
+  nodes: [Message!]!
+  page_info: PageInfo!
+  total_count: Int!
+}
+
+# Cursors are the IDs of the first and last node
+type PageInfo {
+  start_cursor: String
+  end_cursor: String
+  has_next_page: Boolean!
+  has_previous_page: Boolean!
+}
+
+scalar JSON
+`
+
+// connection is a page of a list, in the shape of Relay connections
+type connection struct {
+	Nodes      interface{} `json:"nodes"`
+	PageInfo   pageInfo    `json:"page_info"`
+	TotalCount int         `json:"total_count"`
+}
+
+// pageInfo holds the cursors to continue a connection from
+type pageInfo struct {
+	StartCursor     string `json:"start_cursor,omitempty"`
+	EndCursor       string `json:"end_cursor,omitempty"`
+	HasNextPage     bool   `json:"has_next_page"`
+	HasPreviousPage bool   `json:"has_previous_page"`
+}
+
+// paginateConnection picks the page of items that the first, after, last and
+// before arguments ask for; the cursor of an item is its ID
+func paginateConnection[T any](items []T, id func(T) string, args graphql.Args) (*connection, error) {
+	if args.Has("first") && args.Has("last") {
+		return nil, fmt.Errorf("first and last cannot be combined")
+	}
+
+	start, end := 0, len(items)
+	if after := args.String("after"); after != "" {
+		if start = indexOf(items, id, after) + 1; start == 0 {
+			return nil, errUnknownCursor
+		}
+	}
+	if before := args.String("before"); before != "" {
+		if end = indexOf(items, id, before); end < 0 {
+			return nil, errUnknownCursor
+		}
+	}
+	if start > end {
+		start = end
+	}
+
+	if args.Has("last") {
+		last := args.Int("last", defaultPageSize)
+		if last < 1 || last > maxPageSize {
+			return nil, fmt.Errorf("last must be between 1 and %d", maxPageSize)
+		}
+		start = max(start, end-last)
+	} else {
+		first := args.Int("first", defaultPageSize)
+		if first < 1 || first > maxPageSize {
+			return nil, fmt.Errorf("first must be between 1 and %d", maxPageSize)
+		}
+		end = min(end, start+first)
+	}
+
+	page := items[start:end]
+	result := &connection{
+		Nodes:      page,
+		TotalCount: len(items),
+		PageInfo: pageInfo{
+			HasNextPage:     end < len(items),
+			HasPreviousPage: start > 0,
+		},
+	}
+	if len(page) > 0 {
+		result.PageInfo.StartCursor = id(page[0])
+		result.PageInfo.EndCursor = id(page[len(page)-1])
+	}
+	return result, nil
+}
+
+// scalars declares fields read from the source as they are
+func scalars(names ...string) map[string]*graphql.Field {
+	fields := make(map[string]*graphql.Field, len(names))
+	for _, name := range names {
+		fields[name] = &graphql.Field{}
+	}
+	return fields
+}
+
+// connectionOf declares the connection type of a node type
+func connectionOf(node *graphql.Object) *graphql.Object {
+	return &graphql.Object{
+		Name: node.Name + "Connection",
+		Fields: map[string]*graphql.Field{
+			"nodes": {Type: node},
+			"page_info": {Type: &graphql.Object{
+				Name:   "PageInfo",
+				Fields: scalars("start_cursor", "end_cursor", "has_next_page", "has_previous_page"),
+			}},
+			"total_count": {},
+		},
+	}
+}
+
+// graphqlSchema builds the schema served at /graphql, as described by graphqlSDL
+func (s *Server) graphqlSchema() *graphql.Schema {
+	metadata := &graphql.Object{
+		Name:   "Metadata",
+		Fields: scalars("conversation_id", "reply_to", "from_agent", "tags", "expires_at", "custom"),
+	}
+	message := &graphql.Object{
+		Name:   "Message",
+		Fields: scalars("id", "type", "content", "agent_id", "user_id", "timestamp"),
+	}
+	message.Fields["metadata"] = &graphql.Field{Type: metadata}
+
+	participant := &graphql.Object{
+		Name:   "Participant",
+		Fields: scalars("id", "name", "type", "is_active", "last_seen"),
+	}
+	summary := &graphql.Object{
+		Name:   "Summary",
+		Fields: scalars("content", "agent_id", "message_count", "created_at"),
+	}
+
+	conv := &graphql.Object{
+		Name:   "Conversation",
+		Fields: scalars("id", "title", "topic", "mood", "goal", "members", "private", "created_at", "updated_at"),
+	}
+	conv.Fields["message_count"] = &graphql.Field{
+		Resolve: func(_ context.Context, source interface{}, _ graphql.Args) (interface{}, error) {
+			return source.(*conversation.Info).Messages, nil
+		},
+	}
+	conv.Fields["messages"] = &graphql.Field{
+		Type:    connectionOf(message),
+		Resolve: s.resolveMessages,
+	}
+	conv.Fields["participants"] = &graphql.Field{
+		Type: participant,
+		Resolve: func(_ context.Context, source interface{}, _ graphql.Args) (interface{}, error) {
+			return s.convManager.GetParticipants(source.(*conversation.Info).ID), nil
+		},
+	}
+	conv.Fields["summary"] = &graphql.Field{
+		Type: summary,
+		Resolve: func(_ context.Context, source interface{}, _ graphql.Args) (interface{}, error) {
+			return s.convManager.GetSummary(source.(*conversation.Info).ID), nil
+		},
+	}
+
+	stats := &graphql.Object{
+		Name: "AgentStats",
+		Fields: scalars("running", "response_chance", "effective_response_chance", "messages_seen", "responses_sent",
+			"skipped_by_chance", "ignored_by_rule", "llm_calls", "llm_failures", "panics", "average_latency_ms", "last_response_at"),
+	}
+	agentType := &graphql.Object{
+		Name: "Agent",
+		Fields: map[string]*graphql.Field{
+			"id": {Resolve: func(_ context.Context, source interface{}, _ graphql.Args) (interface{}, error) {
+				return source.(agent.Agent).ID(), nil
+			}},
+			"name": {Resolve: func(_ context.Context, source interface{}, _ graphql.Args) (interface{}, error) {
+				return source.(agent.Agent).Name(), nil
+			}},
+			"stats": {Type: stats, Resolve: func(_ context.Context, source interface{}, _ graphql.Args) (interface{}, error) {
+				if reporter, ok := source.(agent.StatsReporter); ok {
+					return reporter.Stats(), nil
+				}
+				return nil, nil
+			}},
+		},
+	}
+
+	query := &graphql.Object{
+		Name: "Query",
+		Fields: map[string]*graphql.Field{
+			"conversations": {
+				Type: connectionOf(conv),
+				Resolve: func(_ context.Context, _ interface{}, args graphql.Args) (interface{}, error) {
+					return paginateConnection(s.convManager.ListInfo(), func(info *conversation.Info) string { return info.ID }, args)
+				},
+			},
+			"conversation": {
+				Type: conv,
+				Resolve: func(_ context.Context, _ interface{}, args graphql.Args) (interface{}, error) {
+					if info, exists := s.convManager.GetInfo(args.String("id")); exists {
+						return info, nil
+					}
+					return nil, nil
+				},
+			},
+			"agents": {
+				Type: agentType,
+				Resolve: func(_ context.Context, _ interface{}, _ graphql.Args) (interface{}, error) {
+					agents := s.agentManager.ListAgents()
+					sort.Slice(agents, func(i, j int) bool {
+						return agents[i].ID() < agents[j].ID()
+					})
+					return agents, nil
+				},
+			},
+			"agent": {
+				Type: agentType,
+				Resolve: func(_ context.Context, _ interface{}, args graphql.Args) (interface{}, error) {
+					if a, exists := s.agentManager.GetAgent(args.String("id")); exists {
+						return a, nil
+					}
+					return nil, nil
+				},
+			},
+		},
+	}
+
+	subscription := &graphql.Object{
+		Name: "Subscription",
+		Fields: map[string]*graphql.Field{
+			"messages": {Type: message, Resolve: s.subscribeMessages},
+		},
+	}
+
+	return &graphql.Schema{Query: query, Subscription: subscription}
+}
+
+// resolveMessages pages through the messages of a conversation, oldest first,
+// optionally of some types only
+func (s *Server) resolveMessages(_ context.Context, source interface{}, args graphql.Args) (interface{}, error) {
+	messages := s.convManager.GetMessages(source.(*conversation.Info).ID)
+	if wanted := args.Strings("types"); len(wanted) > 0 {
+		messages = filterMessages(messages, "", wanted, "")
+	}
+	return paginateConnection(messages, func(message *types.ChatMessage) string { return message.ID }, args)
+}
+
+// subscribeMessages streams the broadcast messages that match the arguments
+func (s *Server) subscribeMessages(ctx context.Context, _ interface{}, args graphql.Args) (interface{}, error) {
+	conversationID, wanted, senderID := args.String("conversation_id"), args.Strings("types"), args.String("agent_id")
+
+	messages := s.feed.subscribe()
+	events := make(chan interface{})
+	go func() {
+		defer s.feed.unsubscribe(messages)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case message := <-messages:
+				if len(filterMessages([]*types.ChatMessage{message}, conversationID, wanted, senderID)) == 0 {
+					continue
+				}
+				select {
+				case events <- message:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return (<-chan interface{})(events), nil
+}
+
+// messageFeed hands the messages broadcast to WebSocket clients to the GraphQL subscriptions
+type messageFeed struct {
+	mu          sync.Mutex
+	subscribers map[chan *types.ChatMessage]bool
+}
+
+// subscribe returns a channel that receives the broadcast messages
+func (f *messageFeed) subscribe() chan *types.ChatMessage {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.subscribers == nil {
+		f.subscribers = make(map[chan *types.ChatMessage]bool)
+	}
+	messages := make(chan *types.ChatMessage, feedBuffer)
+	f.subscribers[messages] = true
+	return messages
+}
+
+// unsubscribe stops sending messages to a subscriber
+func (f *messageFeed) unsubscribe(messages chan *types.ChatMessage) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.subscribers, messages)
+}
+
+// publish sends a message to the subscribers, skipping those that fall behind
+func (f *messageFeed) publish(message *types.ChatMessage) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for messages := range f.subscribers {
+		select {
+		case messages <- message:
+		default:
+			log.Printf("GraphQL subscription is too slow, dropping message %s", message.ID)
+		}
+	}
+}
+
+// handleGraphQL runs a GraphQL query posted as JSON
+func (s *Server) handleGraphQL(c *gin.Context) {
+	var req graphql.Request
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": []gin.H{{"message": err.Error()}}})
+		return
+	}
+	s.executeGraphQL(c, req)
+}
+
+// handleGetGraphQL opens a WebSocket for subscriptions, or runs the query in
+// the ?query=, ?operationName= and ?variables= parameters
+func (s *Server) handleGetGraphQL(c *gin.Context) {
+	if c.IsWebsocket() {
+		s.handleGraphQLWebSocket(c)
+		return
+	}
+
+	req := graphql.Request{Query: c.Query("query"), OperationName: c.Query("operationName")}
+	if variables := c.Query("variables"); variables != "" {
+		if err := json.Unmarshal([]byte(variables), &req.Variables); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"errors": []gin.H{{"message": "invalid variables: " + err.Error()}}})
+			return
+		}
+	}
+	s.executeGraphQL(c, req)
+}
+
+// executeGraphQL runs a query; subscriptions need the WebSocket
+func (s *Server) executeGraphQL(c *gin.Context, req graphql.Request) {
+	if s.graphql.IsSubscription(req) {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": []gin.H{{"message": "subscriptions are served over the WebSocket at /graphql"}}})
+		return
+	}
+	c.JSON(http.StatusOK, s.graphql.Execute(c.Request.Context(), req))
+}
+
+// handleGetGraphQLSchema returns the schema in the GraphQL schema language
+func (s *Server) handleGetGraphQLSchema(c *gin.Context) {
+	c.String(http.StatusOK, graphqlSDL)
+}
@@ -0,0 +1,166 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"philoking/internal/graphql"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+// graphqlProtocol is the WebSocket subprotocol of GraphQL subscriptions, as
+// spoken by graphql-ws and most GraphQL clients
+const graphqlProtocol = "graphql-transport-ws"
+
+// Close codes of the graphql-transport-ws protocol
+const (
+	closeBadRequest      = 4400
+	closeUnauthorized    = 4401
+	closeAlreadyExisting = 4409
+)
+
+// graphqlMessage is a message of the graphql-transport-ws protocol
+type graphqlMessage struct {
+	ID      string      `json:"id,omitempty"`
+	Type    string      `json:"type"`
+	Payload interface{} `json:"payload,omitempty"`
+}
+
+// graphqlSocket is a WebSocket running GraphQL operations for a client
+type graphqlSocket struct {
+	conn       *websocket.Conn
+	writeMu    sync.Mutex
+	mu         sync.Mutex
+	operations map[string]context.CancelFunc // Running operations by ID
+}
+
+// handleGraphQLWebSocket runs the queries and subscriptions a client sends
+// over the graphql-transport-ws protocol
+func (s *Server) handleGraphQLWebSocket(c *gin.Context) {
+	upgrader := s.upgrader
+	upgrader.Subprotocols = []string{graphqlProtocol}
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		log.Printf("GraphQL WebSocket upgrade failed: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	socket := &graphqlSocket{conn: conn, operations: make(map[string]context.CancelFunc)}
+	acknowledged := false
+	for {
+		var raw struct {
+			ID      string          `json:"id"`
+			Type    string          `json:"type"`
+			Payload json.RawMessage `json:"payload"`
+		}
+		if err := conn.ReadJSON(&raw); err != nil {
+			return
+		}
+
+		switch raw.Type {
+		case "connection_init":
+			acknowledged = true
+			socket.send(graphqlMessage{Type: "connection_ack"})
+		case "ping":
+			socket.send(graphqlMessage{Type: "pong"})
+		case "pong":
+		case "subscribe":
+			if !acknowledged {
+				socket.close(closeUnauthorized, "Unauthorized")
+				return
+			}
+			var req graphql.Request
+			if err := json.Unmarshal(raw.Payload, &req); err != nil || raw.ID == "" {
+				socket.close(closeBadRequest, "Invalid subscribe message")
+				return
+			}
+			opCtx, ok := socket.start(ctx, raw.ID)
+			if !ok {
+				socket.close(closeAlreadyExisting, "Subscriber for "+raw.ID+" already exists")
+				return
+			}
+			go s.runGraphQLOperation(opCtx, socket, raw.ID, req)
+		case "complete":
+			socket.stop(raw.ID)
+		default:
+			socket.close(closeBadRequest, "Unknown message type "+raw.Type)
+			return
+		}
+	}
+}
+
+// runGraphQLOperation sends the result of a query, or the events of a
+// subscription until either side completes it
+func (s *Server) runGraphQLOperation(ctx context.Context, socket *graphqlSocket, id string, req graphql.Request) {
+	defer socket.stop(id)
+
+	if !s.graphql.IsSubscription(req) {
+		socket.send(graphqlMessage{ID: id, Type: "next", Payload: s.graphql.Execute(ctx, req)})
+		socket.send(graphqlMessage{ID: id, Type: "complete"})
+		return
+	}
+
+	responses, err := s.graphql.Subscribe(ctx, req)
+	if err != nil {
+		socket.send(graphqlMessage{ID: id, Type: "error", Payload: []*graphql.Error{{Message: err.Error()}}})
+		return
+	}
+	for response := range responses {
+		socket.send(graphqlMessage{ID: id, Type: "next", Payload: response})
+	}
+	// The client already knows about operations it completed itself
+	if ctx.Err() == nil {
+		socket.send(graphqlMessage{ID: id, Type: "complete"})
+	}
+}
+
+// start registers an operation; it fails when the ID is taken
+func (g *graphqlSocket) start(ctx context.Context, id string) (context.Context, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if _, exists := g.operations[id]; exists {
+		return nil, false
+	}
+	opCtx, cancel := context.WithCancel(ctx)
+	g.operations[id] = cancel
+	return opCtx, true
+}
+
+// stop ends an operation and frees its ID
+func (g *graphqlSocket) stop(id string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if cancel, exists := g.operations[id]; exists {
+		cancel()
+		delete(g.operations, id)
+	}
+}
+
+// send writes a protocol message; operations write concurrently
+func (g *graphqlSocket) send(message graphqlMessage) {
+	g.writeMu.Lock()
+	defer g.writeMu.Unlock()
+
+	if err := g.conn.WriteJSON(message); err != nil {
+		log.Printf("Error writing to GraphQL WebSocket: %v", err)
+	}
+}
+
+// close ends the connection with a protocol error
+func (g *graphqlSocket) close(code int, reason string) {
+	data := websocket.FormatCloseMessage(code, reason)
+	if err := g.conn.WriteControl(websocket.CloseMessage, data, time.Now().Add(time.Second)); err != nil {
+		log.Printf("Error closing GraphQL WebSocket: %v", err)
+	}
+}
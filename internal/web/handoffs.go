@@ -0,0 +1,12 @@
+package web
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// handleListHandoffs lists the tasks agents handed off in a conversation and how each went
+func (s *Server) handleListHandoffs(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"handoffs": s.convManager.ListHandoffs(c.Param("id"))})
+}
@@ -0,0 +1,360 @@
+package web
+
+import (
+	"encoding/json"
+	"log"
+	"sort"
+	"sync"
+
+	"github.com/gorilla/websocket"
+)
+
+// clientSendBuffer is the number of outgoing messages queued per client
+// before the client is considered too slow and disconnected
+const clientSendBuffer = 256
+
+// ClientInfo stores information about a WebSocket client
+type ClientInfo struct {
+	Conn   *websocket.Conn
+	UserID string
+	Name   string
+	Addr   string // The client's IP address, which blocks also apply to
+	send   chan []byte
+	muted  map[string]bool // Senders whose messages the user doesn't want to see; guarded by the hub
+	// msgpack is set for clients that negotiated MessagePack; they get binary frames
+	msgpack bool
+	// subscription narrows what the connection receives; guarded by the hub
+	subscription Subscription
+}
+
+// Session describes a connected user for moderators
+type Session struct {
+	UserID       string        `json:"user_id"`
+	Name         string        `json:"name"`
+	Address      string        `json:"address"`
+	Muted        []string      `json:"muted"`
+	Subscription *Subscription `json:"subscription,omitempty"`
+}
+
+// Subscription narrows the messages a client receives to some conversations,
+// message types and senders; empty lists let everything through
+type Subscription struct {
+	Conversations []string `json:"conversations,omitempty"`
+	Types         []string `json:"types,omitempty"`
+	Agents        []string `json:"agents,omitempty"`
+}
+
+// Envelope describes a broadcast for the clients' subscriptions and mutes;
+// subscriptions don't filter on the fields left empty
+type Envelope struct {
+	ConversationID string
+	Type           string
+	SenderID       string
+}
+
+// wants reports whether a broadcast passes the subscription
+func (s Subscription) wants(env Envelope) bool {
+	return matches(s.Conversations, env.ConversationID) && matches(s.Types, env.Type) && matches(s.Agents, env.SenderID)
+}
+
+// isEmpty reports whether the subscription lets everything through
+func (s Subscription) isEmpty() bool {
+	return len(s.Conversations) == 0 && len(s.Types) == 0 && len(s.Agents) == 0
+}
+
+// matches reports whether a value passes a filter; empty filters and values always do
+func matches(filter []string, value string) bool {
+	return len(filter) == 0 || value == "" || contains(filter, value)
+}
+
+// Hub fans out messages to the WebSocket clients connected to this instance
+type Hub struct {
+	clients map[*ClientInfo]bool
+	blocked map[string]bool // User IDs and addresses moderators blocked
+	// blockedFor maps the addresses blocked along with a user to that user,
+	// so a user who clears their session cookie stays blocked
+	blockedFor map[string]string
+	mu         sync.RWMutex
+}
+
+// NewHub creates a new broadcast hub
+func NewHub() *Hub {
+	return &Hub{
+		clients:    make(map[*ClientInfo]bool),
+		blocked:    make(map[string]bool),
+		blockedFor: make(map[string]string),
+	}
+}
+
+// Register adds a client connected from addr to the hub and starts its writer
+// goroutine. The backlog is queued before the client receives any broadcast,
+// so history always precedes live messages.
+func (h *Hub) Register(conn *websocket.Conn, userID, name, addr string, backlog ...[]byte) *ClientInfo {
+	client := &ClientInfo{
+		Conn:   conn,
+		UserID: userID,
+		Name:   name,
+		Addr:   addr,
+		send:   make(chan []byte, clientSendBuffer),
+		muted:  make(map[string]bool),
+	}
+	client.msgpack = conn != nil && conn.Subprotocol() == msgpackProtocol
+
+	// Keep room for live messages once the backlog is queued
+	if excess := len(backlog) - clientSendBuffer/2; excess > 0 {
+		backlog = backlog[excess:]
+	}
+	for _, data := range backlog {
+		if frame, err := client.encode(data); err == nil {
+			client.send <- frame
+		}
+	}
+
+	h.mu.Lock()
+	h.clients[client] = true
+	h.mu.Unlock()
+
+	go client.writePump()
+	return client
+}
+
+// Unregister removes a client from the hub and stops its writer goroutine
+func (h *Hub) Unregister(client *ClientInfo) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.clients[client]; ok {
+		delete(h.clients, client)
+		close(client.send)
+	}
+}
+
+// Broadcast queues data for every connected client, dropping clients that
+// cannot keep up
+func (h *Hub) Broadcast(data []byte) {
+	h.BroadcastMessage(Envelope{}, data)
+}
+
+// BroadcastMessage queues a message for every connected client that
+// subscribed to it and hasn't muted its sender
+func (h *Hub) BroadcastMessage(env Envelope, data []byte) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var packed []byte // Encoded once for all MessagePack clients
+	var packErr error
+	for client := range h.clients {
+		if env.SenderID != "" && client.muted[env.SenderID] {
+			continue
+		}
+		if !client.subscription.wants(env) {
+			continue
+		}
+		frame := data
+		if client.msgpack {
+			if packed == nil && packErr == nil {
+				if packed, packErr = toMsgpack(data); packErr != nil {
+					log.Printf("Error encoding broadcast as MessagePack: %v", packErr)
+				}
+			}
+			if packErr != nil {
+				continue
+			}
+			frame = packed
+		}
+		select {
+		case client.send <- frame:
+		default:
+			log.Printf("Client %s is too slow, disconnecting", client.Name)
+			delete(h.clients, client)
+			close(client.send)
+		}
+	}
+}
+
+// Mute hides, or with muted false shows again, a sender's messages from a
+// user's sessions; it reports whether the user is connected here
+func (h *Hub) Mute(userID, senderID string, muted bool) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	found := false
+	for client := range h.clients {
+		if client.UserID != userID {
+			continue
+		}
+		found = true
+		if muted {
+			client.muted[senderID] = true
+		} else {
+			delete(client.muted, senderID)
+		}
+	}
+	return found
+}
+
+// SendJSONTo queues a JSON message for a client that is still connected; it
+// reports whether the message was queued
+func (h *Hub) SendJSONTo(client *ClientInfo, v interface{}) bool {
+	data, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("Error marshaling message for %s: %v", client.Name, err)
+		return false
+	}
+	if data, err = client.encode(data); err != nil {
+		log.Printf("Error encoding message for %s: %v", client.Name, err)
+		return false
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	if !h.clients[client] {
+		return false
+	}
+	select {
+	case client.send <- data:
+		return true
+	default:
+		return false
+	}
+}
+
+// Subscribe replaces what a client receives; an empty subscription lets everything through
+func (h *Hub) Subscribe(client *ClientInfo, subscription Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	client.subscription = subscription
+}
+
+// Muted returns the senders a user muted, and whether the user is connected here
+func (h *Hub) Muted(userID string) ([]string, bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for client := range h.clients {
+		if client.UserID == userID {
+			return sortedKeys(client.muted), true
+		}
+	}
+	return nil, false
+}
+
+// Block refuses a user ID or an IP address from now on. Blocking a user also
+// blocks the addresses of the user's connections, as users can start a new
+// session at will. The connections from blocked addresses are closed.
+func (h *Hub) Block(id string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.blocked[id] = true
+	for client := range h.clients {
+		if client.UserID == id && client.Addr != "" && !h.blocked[client.Addr] {
+			h.blocked[client.Addr] = true
+			h.blockedFor[client.Addr] = id
+		}
+	}
+	for client := range h.clients {
+		if h.blocked[client.UserID] || h.blocked[client.Addr] {
+			delete(h.clients, client)
+			close(client.send)
+		}
+	}
+}
+
+// Unblock lifts the block of a user ID, with the addresses blocked along
+// with it, or of an address; it reports whether anything was blocked
+func (h *Hub) Unblock(id string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if !h.blocked[id] {
+		return false
+	}
+	delete(h.blocked, id)
+	delete(h.blockedFor, id)
+	for addr, userID := range h.blockedFor {
+		if userID == id {
+			delete(h.blocked, addr)
+			delete(h.blockedFor, addr)
+		}
+	}
+	return true
+}
+
+// IsBlocked reports whether moderators blocked a user or the address they connect from
+func (h *Hub) IsBlocked(userID, addr string) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return (userID != "" && h.blocked[userID]) || (addr != "" && h.blocked[addr])
+}
+
+// Blocked returns the blocked user IDs and addresses
+func (h *Hub) Blocked() []string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return sortedKeys(h.blocked)
+}
+
+// Sessions returns the users connected to this instance
+func (h *Hub) Sessions() []Session {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	sessions := make([]Session, 0, len(h.clients))
+	for client := range h.clients {
+		session := Session{UserID: client.UserID, Name: client.Name, Address: client.Addr, Muted: sortedKeys(client.muted)}
+		if !client.subscription.isEmpty() {
+			subscription := client.subscription
+			session.Subscription = &subscription
+		}
+		sessions = append(sessions, session)
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].Name < sessions[j].Name
+	})
+	return sessions
+}
+
+// sortedKeys returns the keys of a set in order
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Count returns the number of connected clients
+func (h *Hub) Count() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.clients)
+}
+
+// writePump writes queued messages to the connection; it is the only
+// goroutine writing to the connection
+func (c *ClientInfo) writePump() {
+	defer c.Conn.Close()
+
+	messageType := websocket.TextMessage
+	if c.msgpack {
+		messageType = websocket.BinaryMessage
+	}
+	for data := range c.send {
+		if err := c.Conn.WriteMessage(messageType, data); err != nil {
+			log.Printf("Error writing to client %s: %v", c.Name, err)
+			return
+		}
+	}
+	c.Conn.WriteMessage(websocket.CloseMessage, []byte{})
+}
+
+// encode turns a JSON message into what the client negotiated
+func (c *ClientInfo) encode(data []byte) ([]byte, error) {
+	if !c.msgpack {
+		return data, nil
+	}
+	return toMsgpack(data)
+}
@@ -0,0 +1,86 @@
+package web
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// BenchmarkHubBroadcast measures fan-out to connected WebSocket clients, from
+// Broadcast until each client has read the message
+func BenchmarkHubBroadcast(b *testing.B) {
+	for _, clients := range []int{10, 100} {
+		b.Run(fmt.Sprintf("%d-clients", clients), func(b *testing.B) {
+			benchmarkBroadcast(b, clients)
+		})
+	}
+}
+
+func benchmarkBroadcast(b *testing.B, clients int) {
+	hub := NewHub()
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		hub.Register(conn, "bench", "bench", "")
+	}))
+	defer server.Close()
+
+	var (
+		latencies []time.Duration
+		mu        sync.Mutex
+		received  sync.WaitGroup
+	)
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http")
+	for i := 0; i < clients; i++ {
+		conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+		if err != nil {
+			b.Fatal(err)
+		}
+		defer conn.Close()
+
+		go func() {
+			for {
+				_, data, err := conn.ReadMessage()
+				if err != nil {
+					return
+				}
+				sent := time.Unix(0, int64(binary.BigEndian.Uint64(data)))
+				mu.Lock()
+				latencies = append(latencies, time.Since(sent))
+				mu.Unlock()
+				received.Done()
+			}
+		}()
+	}
+	for hub.Count() < clients {
+		time.Sleep(time.Millisecond)
+	}
+
+	b.ResetTimer()
+	start := time.Now()
+	for i := 0; i < b.N; i++ {
+		received.Add(clients)
+		data := make([]byte, 8)
+		binary.BigEndian.PutUint64(data, uint64(time.Now().UnixNano()))
+		hub.Broadcast(data)
+		received.Wait()
+	}
+	elapsed := time.Since(start)
+	b.StopTimer()
+
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	b.ReportMetric(float64(len(latencies))/elapsed.Seconds(), "deliveries/s")
+	b.ReportMetric(float64(latencies[len(latencies)*99/100].Nanoseconds()), "p99-ns")
+}
@@ -0,0 +1,105 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+// connectClient registers a WebSocket client of the given user with the hub
+func connectClient(t *testing.T, hub *Hub, userID string) *ClientInfo {
+	return connectClientFrom(t, hub, userID, "")
+}
+
+// connectClientFrom registers a WebSocket client of the given user, connected from addr
+func connectClientFrom(t *testing.T, hub *Hub, userID, addr string) *ClientInfo {
+	t.Helper()
+
+	registered := make(chan *ClientInfo, 1)
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		registered <- hub.Register(conn, userID, userID, addr)
+	}))
+	t.Cleanup(server.Close)
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return <-registered
+}
+
+func TestSendJSONToDroppedClient(t *testing.T) {
+	tests := []struct {
+		name string
+		drop func(hub *Hub, client *ClientInfo)
+	}{
+		{name: "unregistered", drop: func(hub *Hub, client *ClientInfo) { hub.Unregister(client) }},
+		{name: "blocked", drop: func(hub *Hub, client *ClientInfo) { hub.Block(client.UserID) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hub := NewHub()
+			client := connectClient(t, hub, "user-1")
+			if !hub.SendJSONTo(client, map[string]string{"type": "pong"}) {
+				t.Fatal("message to a connected client was not queued")
+			}
+
+			tt.drop(hub, client)
+
+			// The send channel is closed now; sending on it would panic
+			if hub.SendJSONTo(client, map[string]string{"type": "pong"}) {
+				t.Error("message to a dropped client was queued")
+			}
+		})
+	}
+}
+
+func TestBlock(t *testing.T) {
+	tests := []struct {
+		name    string
+		block   string
+		unblock string
+		userID  string // Of a later session
+		addr    string
+		blocked bool
+	}{
+		{name: "same session", block: "troll", userID: "troll", addr: "198.51.100.1", blocked: true},
+		{name: "new session from the same address", block: "troll", userID: "troll-2", addr: "192.0.2.7", blocked: true},
+		{name: "other address", block: "troll", userID: "alice", addr: "198.51.100.1"},
+		{name: "address", block: "192.0.2.7", userID: "anyone", addr: "192.0.2.7", blocked: true},
+		{name: "user unblocked", block: "troll", unblock: "troll", userID: "troll-2", addr: "192.0.2.7"},
+		{name: "address unblocked", block: "troll", unblock: "192.0.2.7", userID: "troll-2", addr: "192.0.2.7"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hub := NewHub()
+			troll := connectClientFrom(t, hub, "troll", "192.0.2.7")
+			neighbour := connectClientFrom(t, hub, "neighbour", "192.0.2.7")
+			alice := connectClientFrom(t, hub, "alice", "198.51.100.1")
+
+			hub.Block(tt.block)
+			if hub.SendJSONTo(troll, map[string]string{"type": "pong"}) || hub.SendJSONTo(neighbour, map[string]string{"type": "pong"}) {
+				t.Error("a session from the blocked address is still connected")
+			}
+			if !hub.SendJSONTo(alice, map[string]string{"type": "pong"}) {
+				t.Error("a session from another address was disconnected")
+			}
+
+			if tt.unblock != "" && !hub.Unblock(tt.unblock) {
+				t.Fatalf("Unblock(%s) found no block", tt.unblock)
+			}
+			if got := hub.IsBlocked(tt.userID, tt.addr); got != tt.blocked {
+				t.Errorf("IsBlocked(%s, %s) = %v, want %v (blocked: %v)", tt.userID, tt.addr, got, tt.blocked, hub.Blocked())
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package web
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// sessionCookie keeps a browser's session, so its user ID survives reconnects
+const sessionCookie = "philoking_session"
+
+// sessionHeader carries the session token of clients that don't keep cookies
+const sessionHeader = "X-Session-Token"
+
+// sessionUserKey is the gin context key of the user ID of the caller's session
+const sessionUserKey = "session_user"
+
+// sessionLifetime is how long a browser keeps its session cookie
+const sessionLifetime = 365 * 24 * time.Hour
+
+// newSessionKey returns the key session tokens are signed with: the
+// configured secret, or a random key that lasts as long as the process
+func newSessionKey(secret string) []byte {
+	if secret != "" {
+		return []byte(secret)
+	}
+	key := make([]byte, 32)
+	rand.Read(key)
+	return key
+}
+
+// sessionToken returns the token that proves this server handed out a user ID
+func (s *Server) sessionToken(userID string) string {
+	mac := hmac.New(sha256.New, s.sessionKey)
+	mac.Write([]byte(userID))
+	return userID + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// sessionUser returns the user ID of a session token, if the token is valid
+func (s *Server) sessionUser(token string) (string, bool) {
+	i := strings.LastIndexByte(token, '.')
+	if i <= 0 {
+		return "", false
+	}
+	userID := token[:i]
+	return userID, hmac.Equal([]byte(token), []byte(s.sessionToken(userID)))
+}
+
+// requestSession returns the user ID of the session a request carries in the
+// X-Session-Token header or the session cookie
+func (s *Server) requestSession(r *http.Request) (string, bool) {
+	token := r.Header.Get(sessionHeader)
+	if token == "" {
+		if cookie, err := r.Cookie(sessionCookie); err == nil {
+			token = cookie.Value
+		}
+	}
+	return s.sessionUser(token)
+}
+
+// newSession hands out a user ID and the cookie that keeps it
+func (s *Server) newSession() (string, *http.Cookie) {
+	userID := uuid.New().String()
+	return userID, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    s.sessionToken(userID),
+		Path:     "/",
+		MaxAge:   int(sessionLifetime / time.Second),
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	}
+}
+
+// requireSession aborts requests that carry no valid session, and otherwise
+// makes the session's user ID the caller
+func (s *Server) requireSession(c *gin.Context) {
+	userID, ok := s.requestSession(c.Request)
+	if !ok {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "a session is required; start one with POST /api/session"})
+		return
+	}
+	c.Set(sessionUserKey, userID)
+	c.Next()
+}
+
+// handleStartSession returns the caller's session, starting one when the
+// request carries none; scripts send the token in the X-Session-Token header
+func (s *Server) handleStartSession(c *gin.Context) {
+	userID, ok := s.requestSession(c.Request)
+	if !ok {
+		var cookie *http.Cookie
+		userID, cookie = s.newSession()
+		http.SetCookie(c.Writer, cookie)
+	}
+	c.JSON(http.StatusOK, gin.H{"user_id": userID, "token": s.sessionToken(userID)})
+}
@@ -0,0 +1,116 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"philoking/internal/config"
+	"philoking/internal/conversation"
+	"philoking/internal/digest"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestSessionUser(t *testing.T) {
+	s := NewServer(config.WebConfig{SessionSecret: "secret"}, nil, conversation.NewManager(), nil, nil, nil)
+	other := NewServer(config.WebConfig{SessionSecret: "other"}, nil, conversation.NewManager(), nil, nil, nil)
+	token := s.sessionToken("alice")
+
+	tests := []struct {
+		name  string
+		token string
+		want  string
+	}{
+		{name: "valid", token: token, want: "alice"},
+		{name: "same secret on another replica", token: NewServer(config.WebConfig{SessionSecret: "secret"}, nil, conversation.NewManager(), nil, nil, nil).sessionToken("alice"), want: "alice"},
+		{name: "other user's ID", token: "bob" + strings.TrimPrefix(token, "alice")},
+		{name: "other secret", token: other.sessionToken("alice")},
+		{name: "no signature", token: "alice"},
+		{name: "empty", token: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := s.sessionUser(tt.token)
+			if ok != (tt.want != "") || (ok && got != tt.want) {
+				t.Errorf("sessionUser = %q, %v, want %q", got, ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestDigestSubscriptionIsTheCallersOwn(t *testing.T) {
+	s := NewServer(config.WebConfig{AdminToken: "admin"}, nil, conversation.NewManager(), nil, nil, nil)
+	digester, err := digest.New(config.DigestConfig{}, conversation.NewManager(), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.UseDigest(digester)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/api/session", s.handleStartSession)
+	session := r.Group("/api/session", s.requireSession)
+	session.GET("/digest", s.handleGetDigestSubscription)
+	session.PUT("/digest", s.handleSubscribeDigest)
+	session.DELETE("/digest", s.handleUnsubscribeDigest)
+	r.POST("/api/digest/send", s.requireAdmin, s.handleSendDigest)
+
+	do := func(method, path, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set(sessionHeader, token)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	// Starting a session hands out a user ID, a token and a cookie
+	w := do(http.MethodPost, "/api/session", "", "")
+	var started struct {
+		UserID string `json:"user_id"`
+		Token  string `json:"token"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &started); err != nil || started.UserID == "" {
+		t.Fatalf("POST /api/session = %s, %v", w.Body.String(), err)
+	}
+	if cookies := w.Result().Cookies(); len(cookies) != 1 || cookies[0].Name != sessionCookie || cookies[0].Value != started.Token || !cookies[0].HttpOnly {
+		t.Errorf("cookies = %+v, want the session remembered", cookies)
+	}
+	if w := do(http.MethodPost, "/api/session", started.Token, ""); !strings.Contains(w.Body.String(), started.UserID) || len(w.Result().Cookies()) != 0 {
+		t.Errorf("a known session was restarted: %s", w.Body.String())
+	}
+
+	if w := do(http.MethodPut, "/api/session/digest", started.Token, `{"email":"alice@example.com"}`); w.Code != http.StatusOK {
+		t.Fatalf("subscribe: %d %s", w.Code, w.Body.String())
+	}
+	if subscription, ok := digester.Subscription(started.UserID); !ok || subscription.Email != "alice@example.com" {
+		t.Fatalf("subscription of the session's user = %+v", subscription)
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		token  string
+		status int
+	}{
+		{name: "read without a session", method: http.MethodGet, path: "/api/session/digest", status: http.StatusUnauthorized},
+		{name: "forged session", method: http.MethodGet, path: "/api/session/digest", token: started.UserID + ".forged", status: http.StatusUnauthorized},
+		{name: "unsubscribe without a session", method: http.MethodDelete, path: "/api/session/digest", status: http.StatusUnauthorized},
+		{name: "another session", method: http.MethodGet, path: "/api/session/digest", token: s.sessionToken("mallory"), status: http.StatusNotFound},
+		{name: "send without the admin token", method: http.MethodPost, path: "/api/digest/send", token: started.Token, status: http.StatusUnauthorized},
+		{name: "read own", method: http.MethodGet, path: "/api/session/digest", token: started.Token, status: http.StatusOK},
+		{name: "unsubscribe own", method: http.MethodDelete, path: "/api/session/digest", token: started.Token, status: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := do(tt.method, tt.path, tt.token, ""); w.Code != tt.status {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.status, w.Body.String())
+			}
+		})
+	}
+}
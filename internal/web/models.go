@@ -0,0 +1,93 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"philoking/internal/ollama"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UseOllama enables the model management endpoints for the Ollama server the agents use
+func (s *Server) UseOllama(client *ollama.Client) {
+	s.ollama = client
+}
+
+// requireOllama responds with an error unless agents use an Ollama server
+func (s *Server) requireOllama(c *gin.Context) bool {
+	if s.ollama == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "agents don't use an Ollama server"})
+		return false
+	}
+	return true
+}
+
+// handleListModels lists the models installed on the Ollama server
+func (s *Server) handleListModels(c *gin.Context) {
+	if !s.requireOllama(c) {
+		return
+	}
+
+	models, err := s.ollama.List(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, models)
+}
+
+// handlePullModel downloads a model, streaming Ollama's progress updates as
+// newline-delimited JSON
+func (s *Server) handlePullModel(c *gin.Context) {
+	if !s.requireOllama(c) {
+		return
+	}
+
+	var req struct {
+		Model string `json:"model" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Status(http.StatusOK)
+	encoder := json.NewEncoder(c.Writer)
+	err := s.ollama.Pull(c.Request.Context(), req.Model, func(progress ollama.PullProgress) {
+		encoder.Encode(progress)
+		c.Writer.Flush()
+	})
+	if err != nil {
+		// The status line is already sent; the last update carries the failure
+		encoder.Encode(ollama.PullProgress{Status: "error", Error: err.Error()})
+		c.Writer.Flush()
+	}
+}
+
+// handleDeleteModel removes a model from the Ollama server
+func (s *Server) handleDeleteModel(c *gin.Context) {
+	if !s.requireOllama(c) {
+		return
+	}
+
+	// Model names may contain slashes, e.g. "library/llama2:7b"
+	model := strings.TrimPrefix(c.Param("name"), "/")
+	if model == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "model name is required"})
+		return
+	}
+
+	if err := s.ollama.Delete(c.Request.Context(), model); err != nil {
+		if errors.Is(err, ollama.ErrModelNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"deleted": model})
+}
@@ -0,0 +1,152 @@
+package web
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+)
+
+// msgpackProtocol is the WebSocket subprotocol of clients that receive
+// MessagePack in binary frames instead of JSON
+const msgpackProtocol = "msgpack"
+
+// msgpackField is a member of a JSON object, kept in order
+type msgpackField struct {
+	key   string
+	value interface{}
+}
+
+// toMsgpack re-encodes a JSON document as MessagePack. Objects keep their key
+// order, integers use the smallest integer format and other numbers float64.
+func toMsgpack(data []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	value, err := decodeOrdered(dec)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode JSON for MessagePack: %w", err)
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, fmt.Errorf("failed to decode JSON for MessagePack: trailing data")
+	}
+
+	var buf bytes.Buffer
+	writeMsgpack(&buf, value)
+	return buf.Bytes(), nil
+}
+
+// decodeOrdered decodes the next JSON value; objects become []msgpackField
+func decodeOrdered(dec *json.Decoder) (interface{}, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch tok {
+	case json.Delim('['):
+		list := []interface{}{}
+		for dec.More() {
+			item, err := decodeOrdered(dec)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, item)
+		}
+		_, err := dec.Token()
+		return list, err
+	case json.Delim('{'):
+		object := []msgpackField{}
+		for dec.More() {
+			keyTok, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			value, err := decodeOrdered(dec)
+			if err != nil {
+				return nil, err
+			}
+			object = append(object, msgpackField{key: keyTok.(string), value: value})
+		}
+		_, err := dec.Token()
+		return object, err
+	}
+	return tok, nil
+}
+
+// writeMsgpack appends the MessagePack encoding of a decoded JSON value
+func writeMsgpack(buf *bytes.Buffer, value interface{}) {
+	switch v := value.(type) {
+	case nil:
+		buf.WriteByte(0xc0)
+	case bool:
+		if v {
+			buf.WriteByte(0xc3)
+		} else {
+			buf.WriteByte(0xc2)
+		}
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			writeMsgpackInt(buf, n)
+			return
+		}
+		f, _ := v.Float64()
+		buf.WriteByte(0xcb)
+		binary.Write(buf, binary.BigEndian, math.Float64bits(f))
+	case string:
+		writeMsgpackHeader(buf, len(v), 0xa0, 32, 0xd9, 0xda, 0xdb)
+		buf.WriteString(v)
+	case []interface{}:
+		writeMsgpackHeader(buf, len(v), 0x90, 16, 0, 0xdc, 0xdd)
+		for _, item := range v {
+			writeMsgpack(buf, item)
+		}
+	case []msgpackField:
+		writeMsgpackHeader(buf, len(v), 0x80, 16, 0, 0xde, 0xdf)
+		for _, field := range v {
+			writeMsgpack(buf, field.key)
+			writeMsgpack(buf, field.value)
+		}
+	}
+}
+
+// writeMsgpackInt appends an integer in the smallest format that holds it
+func writeMsgpackInt(buf *bytes.Buffer, n int64) {
+	switch {
+	case n >= 0 && n <= 0x7f:
+		buf.WriteByte(byte(n))
+	case n < 0 && n >= -32:
+		buf.WriteByte(byte(int8(n)))
+	case n >= math.MinInt8 && n <= math.MaxInt8:
+		buf.WriteByte(0xd0)
+		buf.WriteByte(byte(int8(n)))
+	case n >= math.MinInt16 && n <= math.MaxInt16:
+		buf.WriteByte(0xd1)
+		binary.Write(buf, binary.BigEndian, int16(n))
+	case n >= math.MinInt32 && n <= math.MaxInt32:
+		buf.WriteByte(0xd2)
+		binary.Write(buf, binary.BigEndian, int32(n))
+	default:
+		buf.WriteByte(0xd3)
+		binary.Write(buf, binary.BigEndian, n)
+	}
+}
+
+// writeMsgpackHeader appends the type and length of a string, array or map:
+// a fix format below fixLimit, then the 8 (strings only), 16 and 32 bit formats
+func writeMsgpackHeader(buf *bytes.Buffer, n int, fix byte, fixLimit int, format8, format16, format32 byte) {
+	switch {
+	case n < fixLimit:
+		buf.WriteByte(fix | byte(n))
+	case format8 != 0 && n <= math.MaxUint8:
+		buf.WriteByte(format8)
+		buf.WriteByte(byte(n))
+	case n <= math.MaxUint16:
+		buf.WriteByte(format16)
+		binary.Write(buf, binary.BigEndian, uint16(n))
+	default:
+		buf.WriteByte(format32)
+		binary.Write(buf, binary.BigEndian, uint32(n))
+	}
+}
@@ -0,0 +1,118 @@
+package web
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Bounds of the pages of list endpoints and GraphQL lists
+const (
+	defaultPageSize = 50
+	maxPageSize     = 500
+)
+
+// errUnknownCursor is returned for a cursor whose item is no longer listed
+var errUnknownCursor = errors.New("unknown cursor; the item it points at may have been removed")
+
+// listParams are the paging parameters of a list endpoint: ?limit= (50 by
+// default, at most 500), ?cursor= from the previous page's next_cursor and
+// ?order=asc or desc
+type listParams struct {
+	limit int
+	after string // Key of the last item of the previous page
+	desc  bool
+}
+
+// parseListParams reads the paging parameters, answering 400 when they are invalid
+func parseListParams(c *gin.Context) (listParams, bool) {
+	params := listParams{limit: defaultPageSize}
+
+	if value := c.Query("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxPageSize {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a number from 1 to " + strconv.Itoa(maxPageSize)})
+			return params, false
+		}
+		params.limit = limit
+	}
+
+	if cursor := c.Query("cursor"); cursor != "" {
+		key, err := base64.RawURLEncoding.DecodeString(cursor)
+		if err != nil || len(key) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid cursor"})
+			return params, false
+		}
+		params.after = string(key)
+	}
+
+	switch c.DefaultQuery("order", "asc") {
+	case "asc":
+	case "desc":
+		params.desc = true
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "order must be asc or desc"})
+		return params, false
+	}
+	return params, true
+}
+
+// pageOf returns up to limit items following the one with the after key,
+// and the key of the last item when more follow
+func pageOf[T any](items []T, key func(T) string, after string, limit int) ([]T, string, error) {
+	start := 0
+	if after != "" {
+		i := indexOf(items, key, after)
+		if i < 0 {
+			return nil, "", errUnknownCursor
+		}
+		start = i + 1
+	}
+
+	end := min(len(items), start+limit)
+	next := ""
+	if end < len(items) && end > start {
+		next = key(items[end-1])
+	}
+	return items[start:end], next, nil
+}
+
+// indexOf returns the position of the item with a key, or -1
+func indexOf[T any](items []T, key func(T) string, value string) int {
+	for i, item := range items {
+		if key(item) == value {
+			return i
+		}
+	}
+	return -1
+}
+
+// respondPage answers with a page of items, listed in a stable order, under
+// name, with the total number of items and the cursor of the next page
+func respondPage[T any](c *gin.Context, name string, items []T, key func(T) string, params listParams) {
+	if params.desc {
+		reversed := make([]T, len(items))
+		for i, item := range items {
+			reversed[len(items)-1-i] = item
+		}
+		items = reversed
+	}
+
+	page, next, err := pageOf(items, key, params.after, params.limit)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if page == nil {
+		page = []T{}
+	}
+
+	response := gin.H{name: page, "total": len(items)}
+	if next != "" {
+		response["next_cursor"] = base64.RawURLEncoding.EncodeToString([]byte(next))
+	}
+	c.JSON(http.StatusOK, response)
+}
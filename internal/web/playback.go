@@ -0,0 +1,158 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"philoking/internal/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultPlaybackGap caps the pauses of a replay unless asked otherwise, so
+// a conversation that rested overnight doesn't stall its playback
+const defaultPlaybackGap = 30 * time.Second
+
+// errClientGone is returned when a WebSocket client disconnects during a replay
+var errClientGone = errors.New("client disconnected")
+
+// playback is how a conversation is replayed
+type playback struct {
+	speed  float64       // Pauses are divided by it; 0 replays instantly
+	maxGap time.Duration // Longest pause before scaling; 0 keeps every pause
+}
+
+// parsePlayback reads a speed ("1", "5x", "instant") and a maximum pause
+// ("30s", "0" for none); empty values use 1x and defaultPlaybackGap
+func parsePlayback(speed, maxGap string) (playback, error) {
+	p := playback{speed: 1, maxGap: defaultPlaybackGap}
+
+	switch speed = strings.TrimSuffix(strings.TrimSpace(speed), "x"); speed {
+	case "":
+	case "instant", "0":
+		p.speed = 0
+	default:
+		value, err := strconv.ParseFloat(speed, 64)
+		if err != nil || value <= 0 {
+			return p, fmt.Errorf("speed must be a positive number such as 1 or 5, or instant")
+		}
+		p.speed = value
+	}
+
+	if maxGap != "" {
+		gap, err := time.ParseDuration(maxGap)
+		if err != nil || gap < 0 {
+			return p, fmt.Errorf("max_gap must be a duration such as 30s, or 0")
+		}
+		p.maxGap = gap
+	}
+	return p, nil
+}
+
+// replay sends messages with their original pauses, capped and divided by
+// the speed, until all are sent, send fails or ctx is done
+func (p playback) replay(ctx context.Context, messages []*types.ChatMessage, send func(*types.ChatMessage) error) error {
+	for i, message := range messages {
+		if i > 0 && p.speed > 0 {
+			gap := message.Timestamp.Sub(messages[i-1].Timestamp)
+			if p.maxGap > 0 && gap > p.maxGap {
+				gap = p.maxGap
+			}
+			if gap > 0 {
+				timer := time.NewTimer(time.Duration(float64(gap) / p.speed))
+				select {
+				case <-ctx.Done():
+					timer.Stop()
+					return ctx.Err()
+				case <-timer.C:
+				}
+			}
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := send(message); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// playbackMessages returns the messages of a conversation held in memory to replay
+func (s *Server) playbackMessages(conversationID string) ([]*types.ChatMessage, bool) {
+	if _, exists := s.convManager.GetInfo(conversationID); !exists {
+		return nil, false
+	}
+	messages := s.convManager.GetMessages(conversationID)
+	return messages, true
+}
+
+// handlePlayback streams the messages of a conversation as newline-delimited
+// JSON with their original timing, sped up by ?speed= (1 by default, e.g. 5
+// or instant) with pauses capped at ?max_gap=
+func (s *Server) handlePlayback(c *gin.Context) {
+	p, err := parsePlayback(c.Query("speed"), c.Query("max_gap"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	messages, exists := s.playbackMessages(c.Param("id"))
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "conversation not found; rehydrate archived conversations first"})
+		return
+	}
+
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Status(http.StatusOK)
+	encoder := json.NewEncoder(c.Writer)
+	p.replay(c.Request.Context(), messages, func(message *types.ChatMessage) error {
+		if err := encoder.Encode(message); err != nil {
+			return err
+		}
+		c.Writer.Flush()
+		return nil
+	})
+}
+
+// startPlayback replays a conversation to a single WebSocket client, each
+// message wrapped in a playback_message event between playback_started and
+// playback_finished. The replay stops when ctx is cancelled.
+func (s *Server) startPlayback(ctx context.Context, client *ClientInfo, conversationID string, p playback) {
+	messages, exists := s.playbackMessages(conversationID)
+	if !exists {
+		s.hub.SendJSONTo(client, map[string]string{"type": "playback_error", "conversation_id": conversationID, "error": "conversation not found"})
+		return
+	}
+
+	s.hub.SendJSONTo(client, map[string]interface{}{
+		"type":            "playback_started",
+		"conversation_id": conversationID,
+		"messages":        len(messages),
+		"speed":           p.speed,
+	})
+	err := p.replay(ctx, messages, func(message *types.ChatMessage) error {
+		// Instant replays wait for the client to read, so its queue doesn't overflow
+		for len(client.send) > clientSendBuffer/2 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(10 * time.Millisecond):
+			}
+		}
+		event := map[string]interface{}{"type": "playback_message", "conversation_id": conversationID, "message": message}
+		if !s.hub.SendJSONTo(client, event) {
+			return errClientGone
+		}
+		return nil
+	})
+	if errors.Is(err, errClientGone) {
+		return
+	}
+	s.hub.SendJSONTo(client, map[string]interface{}{"type": "playback_finished", "conversation_id": conversationID, "stopped": err != nil})
+}
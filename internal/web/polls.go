@@ -0,0 +1,88 @@
+package web
+
+import (
+	"net/http"
+	"time"
+
+	"philoking/internal/conversation"
+
+	"github.com/gin-gonic/gin"
+)
+
+// handleCreatePoll opens a new poll in a conversation
+func (s *Server) handleCreatePoll(c *gin.Context) {
+	var req struct {
+		Question        string   `json:"question" binding:"required"`
+		Options         []string `json:"options" binding:"required"`
+		CreatedBy       string   `json:"created_by"`
+		DurationSeconds int      `json:"duration_seconds"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	duration := time.Duration(req.DurationSeconds) * time.Second
+	poll, err := s.flowManager.CreatePoll(c.Request.Context(), c.Param("id"), req.Question, req.Options, req.CreatedBy, duration)
+	if err != nil && poll == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, poll)
+}
+
+// handleListPolls lists the polls of a conversation
+func (s *Server) handleListPolls(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"polls": s.convManager.ListPolls(c.Param("id"))})
+}
+
+// handleGetPoll returns a poll with its votes and tally
+func (s *Server) handleGetPoll(c *gin.Context) {
+	poll, exists := s.convManager.GetPoll(c.Param("id"))
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "poll not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"poll": poll, "tally": poll.Tally()})
+}
+
+// handleCastVote records the caller's vote in a poll
+func (s *Server) handleCastVote(c *gin.Context) {
+	var req struct {
+		VoterName string `json:"voter_name"`
+		Option    int    `json:"option" binding:"required"` // 1-based option number
+		Reason    string `json:"reason"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	vote := &conversation.Vote{
+		VoterID:   c.GetString(sessionUserKey),
+		VoterName: req.VoterName,
+		Option:    req.Option,
+		Reason:    req.Reason,
+	}
+	if err := s.flowManager.CastVote(c.Param("id"), vote); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "vote recorded"})
+}
+
+// handleClosePoll closes a poll early and announces the results
+func (s *Server) handleClosePoll(c *gin.Context) {
+	poll, err := s.flowManager.ClosePoll(c.Request.Context(), c.Param("id"))
+	if err != nil && poll == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"poll": poll, "tally": poll.Tally()})
+}
@@ -0,0 +1,38 @@
+package web
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// handleDeleteUserData scrubs a user's messages and personal data on request
+func (s *Server) handleDeleteUserData(c *gin.Context) {
+	report, err := s.flowManager.DeleteUserData(c.Request.Context(), c.Param("id"), c.GetString(actorKey))
+	if err != nil {
+		if report == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "report": report})
+		return
+	}
+
+	// Flagged messages keep their content for the moderators
+	if s.moderation != nil {
+		if report.Erased == nil {
+			report.Erased = make(map[string]int)
+		}
+		report.Erased["moderation_flags"] = s.moderation.ForgetUser(c.Param("id"))
+	}
+
+	// The digest subscription holds the user's email address
+	if s.digest != nil {
+		if _, err := s.digest.Unsubscribe(c.Param("id")); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "report": report})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, report)
+}
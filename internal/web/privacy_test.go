@@ -0,0 +1,44 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"philoking/internal/config"
+	"philoking/internal/conversation"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestDeleteUserDataRequiresAdmin(t *testing.T) {
+	tests := []struct {
+		name       string
+		adminToken string
+		header     string
+		status     int
+	}{
+		{name: "admin API disabled", status: http.StatusNotFound},
+		{name: "no token", adminToken: "secret", status: http.StatusUnauthorized},
+		{name: "wrong token", adminToken: "secret", header: "guess", status: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewServer(config.WebConfig{AdminToken: tt.adminToken}, nil, conversation.NewManager(), nil, nil, nil)
+			gin.SetMode(gin.TestMode)
+			r := gin.New()
+			r.DELETE("/api/users/:id/data", s.requireAdmin, s.handleDeleteUserData)
+
+			req := httptest.NewRequest(http.MethodDelete, "/api/users/someone/data", nil)
+			req.Header.Set("X-Requested-By", "admin")
+			if tt.header != "" {
+				req.Header.Set(adminTokenHeader, tt.header)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tt.status {
+				t.Errorf("status = %d, want %d", w.Code, tt.status)
+			}
+		})
+	}
+}
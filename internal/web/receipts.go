@@ -0,0 +1,52 @@
+package web
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// markRead records a client's read receipt and tells the other clients about it
+func (s *Server) markRead(conversationID, messageID, participantID string) {
+	if conversationID == "" {
+		conversationID = defaultConversationID
+	}
+	if !s.convManager.MarkRead(conversationID, messageID, participantID) {
+		return
+	}
+
+	data, err := json.Marshal(map[string]interface{}{
+		"type":            "receipt",
+		"conversation_id": conversationID,
+		"message_id":      messageID,
+		"participant_id":  participantID,
+		"read_at":         time.Now(),
+	})
+	if err != nil {
+		log.Printf("Error marshaling read receipt: %v", err)
+		return
+	}
+	s.hub.Broadcast(data)
+}
+
+// handleGetReadState returns the read receipts of every message in a conversation
+func (s *Server) handleGetReadState(c *gin.Context) {
+	conversationID := c.Param("id")
+
+	c.JSON(http.StatusOK, gin.H{
+		"conversation_id": conversationID,
+		"receipts":        s.convManager.GetReadState(conversationID),
+	})
+}
+
+// handleGetReadReceipts returns who read a single message
+func (s *Server) handleGetReadReceipts(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"conversation_id": c.Param("id"),
+		"message_id":      c.Param("messageId"),
+		"receipts":        s.convManager.GetReadReceipts(c.Param("id"), c.Param("messageId")),
+	})
+}
@@ -0,0 +1,53 @@
+package web
+
+import (
+	"net/http"
+	"time"
+
+	"philoking/internal/conversation"
+
+	"github.com/gin-gonic/gin"
+)
+
+// handleListReminders lists the pending reminders of a conversation
+func (s *Server) handleListReminders(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"reminders": s.convManager.ListReminders(c.Param("id"))})
+}
+
+// handleCreateReminder schedules a reminder in a conversation
+func (s *Server) handleCreateReminder(c *gin.Context) {
+	var req struct {
+		Text      string `json:"text" binding:"required"`
+		When      string `json:"when" binding:"required"` // e.g. "2h", "17:30" or an RFC 3339 time
+		CreatedBy string `json:"created_by"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	dueAt, err := conversation.ParseReminderTime(req.When, time.Now())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	reminder, err := s.flowManager.CreateReminder(c.Request.Context(), c.Param("id"), req.Text, dueAt, req.CreatedBy)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, reminder)
+}
+
+// handleCancelReminder cancels a pending reminder
+func (s *Server) handleCancelReminder(c *gin.Context) {
+	reminder, err := s.flowManager.CancelReminder(c.Request.Context(), c.Param("id"))
+	if err != nil && reminder == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, reminder)
+}
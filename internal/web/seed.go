@@ -0,0 +1,33 @@
+package web
+
+import (
+	"io"
+	"net/http"
+
+	"philoking/internal/conversation"
+
+	"github.com/gin-gonic/gin"
+)
+
+// handleSeedConversation imports a transcript so agents continue the discussion
+func (s *Server) handleSeedConversation(c *gin.Context) {
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	transcript, err := conversation.ParseTranscript(body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	count, err := s.flowManager.Seed(c.Request.Context(), transcript, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "imported": count})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"conversation_id": c.Param("id"), "imported": count})
+}
@@ -1,15 +1,28 @@
 package web
 
 import (
+	"compress/flate"
 	"context"
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
-	"sync"
+	"strconv"
 	"time"
 
+	"philoking/internal/agent"
+	"philoking/internal/archive"
+	"philoking/internal/audit"
 	"philoking/internal/config"
+	"philoking/internal/conversation"
+	"philoking/internal/daily"
+	"philoking/internal/digest"
+	"philoking/internal/evaluation"
+	"philoking/internal/graphql"
 	"philoking/internal/kafka"
+	"philoking/internal/moderation"
+	"philoking/internal/ollama"
+	"philoking/internal/tasks"
 	"philoking/internal/types"
 
 	"github.com/gin-gonic/gin"
@@ -17,40 +30,82 @@ import (
 	"github.com/gorilla/websocket"
 )
 
-// ClientInfo stores information about a WebSocket client
-type ClientInfo struct {
-	Conn   *websocket.Conn
-	UserID string
-	Name   string
-}
+// defaultConversationID is the conversation users chat in through the web UI
+const defaultConversationID = "main-conversation"
 
 // Server handles web requests and WebSocket connections
 type Server struct {
-	config      config.WebConfig
-	kafkaClient *kafka.Client
-	upgrader    websocket.Upgrader
-	clients     map[*websocket.Conn]*ClientInfo
-	clientsMu   sync.RWMutex
+	config       config.WebConfig
+	kafkaClient  *kafka.Client
+	convManager  *conversation.Manager
+	flowManager  *conversation.FlowManager
+	agentManager *agent.Manager
+	archiver     *archive.Archiver     // Nil when archiving is not configured
+	ollama       *ollama.Client        // Nil unless agents use an Ollama server
+	digest       *digest.Digester      // Nil unless the email digest is enabled
+	daily        *daily.Scheduler      // Nil unless daily conversations are enabled
+	tasks        *tasks.Queue          // Nil unless the task queue is enabled
+	evaluations  *evaluation.Evaluator // Nil unless evaluation reports are enabled
+	moderation   *moderation.Filter    // Nil unless user messages are moderated
+	errors       errorFeed             // Agent failures, when kafka.topics.errors is set
+	appConfig    *config.Config        // Nil unless admins may read the configuration
+	audit        *audit.Log            // Nil unless admins may read the audit trail
+	flags        runtimeFlags
+	graphql      *graphql.Schema
+	feed         messageFeed // Broadcast messages for GraphQL subscriptions
+	upgrader     websocket.Upgrader
+	hub          *Hub
+	instanceID   string
+	sessionKey   []byte // Signs the session tokens identifying users
 }
 
 // NewServer creates a new web server
-func NewServer(cfg config.WebConfig, kafkaClient *kafka.Client) *Server {
-	return &Server{
-		config:      cfg,
-		kafkaClient: kafkaClient,
+func NewServer(cfg config.WebConfig, kafkaClient *kafka.Client, convManager *conversation.Manager, flowManager *conversation.FlowManager, agentManager *agent.Manager, archiver *archive.Archiver) *Server {
+	s := &Server{
+		config:       cfg,
+		kafkaClient:  kafkaClient,
+		convManager:  convManager,
+		flowManager:  flowManager,
+		agentManager: agentManager,
+		archiver:     archiver,
 		upgrader: websocket.Upgrader{
 			CheckOrigin: func(r *http.Request) bool {
 				return true // Allow all origins in development
 			},
+			EnableCompression: cfg.CompressWebSocket,
+			Subprotocols:      []string{msgpackProtocol}, // Offered by clients that want MessagePack
 		},
-		clients: make(map[*websocket.Conn]*ClientInfo),
+		hub:        NewHub(),
+		instanceID: cfg.InstanceID,
+		sessionKey: newSessionKey(cfg.SessionSecret),
 	}
+	s.flags.debug.Store(true)
+	return s
 }
 
 // Start starts the web server
 func (s *Server) Start() error {
+	addr := s.config.Host + ":" + s.config.Port
+	handler := s.Handler()
+	go s.startMessageConsumer()
+	log.Printf("Web server starting on %s", addr)
+	return http.ListenAndServe(addr, handler)
+}
+
+// Handler sets up the routes; Start serves it and relays the Kafka messages
+// to the WebSocket clients, or a TenantRouter does next to other tenants
+func (s *Server) Handler() http.Handler {
 	gin.SetMode(gin.ReleaseMode)
 	r := gin.Default()
+	// Clients must not pick their own address, which blocks apply to
+	if err := r.SetTrustedProxies(s.config.TrustedProxies); err != nil {
+		log.Printf("Ignoring web.trusted_proxies: %v", err)
+		r.SetTrustedProxies(nil)
+	}
+
+	if s.config.GzipResponses {
+		r.Use(gzipResponses(s.config.GzipMinBytes))
+	}
 
 	// Serve static files
 	r.Static("/static", "./web/static")
@@ -58,16 +113,124 @@ func (s *Server) Start() error {
 
 	// Routes
 	r.GET("/", s.handleIndex)
+	r.GET("/healthz", s.handleHealth)
 	r.GET("/ws", s.handleWebSocket)
-	r.POST("/api/message", s.handleSendMessage)
+	r.POST("/api/message", s.requireSession, s.handleSendMessage)
 	r.GET("/api/agents", s.handleGetAgents)
-
-	// Start Kafka message consumer for WebSocket broadcasting
-	go s.startMessageConsumer()
-
-	addr := s.config.Host + ":" + s.config.Port
-	log.Printf("Web server starting on %s", addr)
-	return r.Run(addr)
+	r.GET("/api/agents/:id/stats", s.handleGetAgentStats)
+	r.GET("/api/agents/:id/feedback", s.handleGetAgentFeedback)
+	r.GET("/api/leaderboard", s.handleGetLeaderboard)
+	r.GET("/api/conversations", s.handleListConversations)
+	r.GET("/api/conversations/:id/messages", s.handleListMessages)
+	r.GET("/api/conversations/:id/playback", s.handlePlayback)
+	r.GET("/api/conversations/:id/summary", s.handleGetSummary)
+	r.GET("/api/conversations/:id/analytics", s.handleGetAnalytics)
+	r.GET("/api/conversations/:id/timeline", s.handleGetTimeline)
+	r.GET("/api/conversations/:id/transcript", s.handleGetTranscript)
+	r.GET("/api/conversations/:id/question", s.handleGetPendingQuestion)
+	r.GET("/api/conversations/:id/phase", s.handleGetPhase)
+	r.GET("/api/conversations/:id/debate", s.handleGetDebate)
+	r.GET("/api/conversations/:id/tutoring", s.handleGetTutoring)
+	r.GET("/api/conversations/:id/wrap-up", s.handleGetWrapUp)
+	r.GET("/api/conversations/:id/world", s.handleGetWorld)
+	r.GET("/api/conversations/:id/whiteboard", s.handleGetWhiteboard)
+	r.GET("/api/conversations/:id/receipts", s.handleGetReadState)
+	r.GET("/api/conversations/:id/messages/:messageId/receipts", s.handleGetReadReceipts)
+	r.GET("/api/conversations/:id/messages/:messageId/feedback", s.handleGetMessageFeedback)
+	r.POST("/api/conversations/:id/messages/:messageId/feedback", s.requireSession, s.handleRateMessage)
+	r.GET("/api/conversations/:id/side-conversations", s.handleListSideConversations)
+	r.GET("/api/side-conversations/:id", s.handleGetSideConversation)
+	r.GET("/api/conversations/:id/polls", s.handleListPolls)
+	r.GET("/api/polls/:id", s.handleGetPoll)
+	r.POST("/api/polls/:id/votes", s.requireSession, s.handleCastVote)
+	r.GET("/api/conversations/:id/reminders", s.handleListReminders)
+	r.GET("/api/conversations/:id/handoffs", s.handleListHandoffs)
+	r.GET("/api/conversations/:id/tasks", s.handleListTasks)
+	r.GET("/api/tasks/:id", s.handleGetTask)
+	r.DELETE("/api/users/:id/data", s.requireAdmin, s.handleDeleteUserData)
+	r.POST("/api/digest/send", s.requireAdmin, s.handleSendDigest)
+	r.GET("/api/models", s.handleListModels)
+
+	// The caller's own session: who they are, what they subscribed to and
+	// which agents they muted
+	r.POST("/api/session", s.handleStartSession)
+	session := r.Group("/api/session", s.requireSession)
+	session.GET("/digest", s.handleGetDigestSubscription)
+	session.PUT("/digest", s.handleSubscribeDigest)
+	session.DELETE("/digest", s.handleUnsubscribeDigest)
+	session.GET("/mutes", s.handleListMutes)
+	session.PUT("/mutes/:agentId", s.handleMuteAgent)
+	session.DELETE("/mutes/:agentId", s.handleUnmuteAgent)
+
+	// GraphQL for custom frontends; subscriptions over the WebSocket at GET /graphql
+	s.graphql = s.graphqlSchema()
+	r.POST("/graphql", s.handleGraphQL)
+	r.GET("/graphql", s.handleGetGraphQL)
+	r.GET("/graphql/schema", s.handleGetGraphQLSchema)
+
+	// Debugging, for admins only
+	debug := r.Group("/api/debug", s.requireAdmin, s.requireDebug)
+	debug.GET("/conversations/:id/scratchpad", s.handleGetScratchpad)
+	debug.GET("/agents/:id/last-calls", s.handleGetLastCalls)
+	debug.GET("/agents/:id/dry-run", s.handleGetDryRun)
+	debug.GET("/errors", s.handleGetErrors)
+
+	// Moderation, for admins only
+	moderation := r.Group("/api/moderation", s.requireAdmin)
+	moderation.GET("/flags", s.handleGetModerationFlags)
+	moderation.GET("/sessions", s.handleListSessions)
+	moderation.GET("/blocks", s.handleListBlocks)
+	moderation.PUT("/blocks/:id", s.handleBlockUser)
+	moderation.DELETE("/blocks/:id", s.handleUnblockUser)
+
+	// Admin console: configuration, runtime flags, topology and scheduled conversations
+	admin := r.Group("/api/admin", s.requireAdmin)
+	admin.GET("/config", s.handleGetConfig)
+	admin.GET("/flags", s.handleGetFlags)
+	admin.PATCH("/flags", s.handleUpdateFlags)
+	admin.GET("/topology", s.handleGetTopology)
+	admin.GET("/audit", s.handleListAudit)
+	admin.POST("/daily", s.handleStartDaily)
+
+	// Managing agents, conversations and models, and everything that changes a
+	// conversation's course or runs agents on demand, for admins only. Users
+	// take part through the chat and their session.
+	manage := r.Group("/api", s.requireAdmin)
+	manage.POST("/agents", s.handleCreateAgent)
+	manage.DELETE("/agents/:id", s.handleDeleteAgent)
+	manage.POST("/agents/:id/clone", s.handleCloneAgent)
+	manage.POST("/agents/:id/restart", s.handleRestartAgent)
+	manage.POST("/conversations", s.handleCreateConversation)
+	manage.PATCH("/conversations/:id", s.handleUpdateConversation)
+	manage.DELETE("/conversations/:id", s.handleArchive)
+	manage.POST("/conversations/:id/archive", s.handleArchive)
+	manage.POST("/conversations/:id/rehydrate", s.handleRehydrate)
+	manage.POST("/models/pull", s.handlePullModel)
+	manage.DELETE("/models/*name", s.handleDeleteModel)
+	manage.POST("/conversations/:id/summarize", s.handleSummarize)
+	manage.POST("/conversations/:id/debate", s.handleStartDebate)
+	manage.DELETE("/conversations/:id/debate", s.handleStopDebate)
+	manage.POST("/conversations/:id/tutoring", s.handleStartTutoring)
+	manage.DELETE("/conversations/:id/tutoring", s.handleStopTutoring)
+	manage.POST("/conversations/:id/reopen", s.handleReopenConversation)
+	manage.PUT("/conversations/:id/world", s.handleReplaceWorld)
+	manage.PATCH("/conversations/:id/world", s.handleUpdateWorld)
+	manage.POST("/conversations/:id/whiteboard", s.handleEditWhiteboard)
+	manage.POST("/conversations/:id/seed", s.handleSeedConversation)
+	manage.POST("/conversations/:id/side-conversations", s.handleStartSideConversation)
+	manage.POST("/conversations/:id/polls", s.handleCreatePoll)
+	manage.POST("/polls/:id/close", s.handleClosePoll)
+	manage.POST("/conversations/:id/reminders", s.handleCreateReminder)
+	manage.DELETE("/reminders/:id", s.handleCancelReminder)
+	manage.POST("/conversations/:id/tasks", s.handleCreateTask)
+
+	evaluations := r.Group("/api/evaluations", s.requireAdmin)
+	evaluations.GET("", s.handleListEvaluations)
+	evaluations.POST("", s.handleStartEvaluation)
+	evaluations.GET("/:id", s.handleGetEvaluation)
+	evaluations.GET("/shadows/:id", s.handleGetShadowComparisons)
+
+	return r
 }
 
 // handleIndex serves the main chat page
@@ -79,27 +242,41 @@ func (s *Server) handleIndex(c *gin.Context) {
 
 // handleWebSocket handles WebSocket connections
 func (s *Server) handleWebSocket(c *gin.Context) {
-	conn, err := s.upgrader.Upgrade(c.Writer, c.Request, nil)
+	// The connection belongs to the browser's session, or starts one
+	userID, ok := s.requestSession(c.Request)
+	header := http.Header{}
+	if !ok {
+		var cookie *http.Cookie
+		userID, cookie = s.newSession()
+		header.Add("Set-Cookie", cookie.String())
+	}
+	if s.hub.IsBlocked(userID, c.ClientIP()) {
+		c.JSON(http.StatusForbidden, gin.H{"error": errUserBlocked.Error()})
+		return
+	}
+
+	conn, err := s.upgrader.Upgrade(c.Writer, c.Request, header)
 	if err != nil {
 		log.Printf("WebSocket upgrade error: %v", err)
 		return
 	}
-	defer conn.Close()
+	if s.config.CompressWebSocket {
+		// Only takes effect when the browser negotiated permessage-deflate
+		conn.SetCompressionLevel(flate.BestSpeed)
+	}
 
-	// Create unique user agent for this connection
-	userID := uuid.New().String()
-	userName := "User-" + userID[:8] // Short ID for display
+	userName := "User-" + userID[:min(8, len(userID))] // Short ID for display
 
-	// Register client with user info
-	s.clientsMu.Lock()
-	s.clients[conn] = &ClientInfo{
-		Conn:   conn,
-		UserID: userID,
-		Name:   userName,
-	}
-	s.clientsMu.Unlock()
+	// Register client with user info, sending recent history first so the page isn't blank
+	client := s.hub.Register(conn, userID, userName, c.ClientIP(), s.backfill()...)
+	s.hub.SendJSONTo(client, map[string]string{"type": "session", "user_id": userID, "name": userName, "token": s.sessionToken(userID)})
+	s.publishPresence(userID, userName, types.PresenceOnline)
 
-	log.Printf("WebSocket client connected as %s (ID: %s). Total clients: %d", userName, userID, len(s.clients))
+	log.Printf("WebSocket client connected as %s (ID: %s). Total clients: %d", userName, userID, s.hub.Count())
+
+	// A replay of a stored conversation runs next to the live messages
+	stopPlayback := func() {}
+	defer func() { stopPlayback() }()
 
 	// Handle client messages
 	for {
@@ -113,27 +290,127 @@ func (s *Server) handleWebSocket(c *gin.Context) {
 		// Handle different message types
 		switch msg["type"] {
 		case "ping":
-			conn.WriteJSON(map[string]string{"type": "pong"})
+			s.hub.SendJSONTo(client, map[string]string{"type": "pong"})
 		case "message":
 			// Forward to Kafka with user info
 			if content, ok := msg["content"].(string); ok {
-				s.sendUserMessage(content, userID, userName)
+				ttl, _ := msg["ttl"].(string)
+				duration, _ := time.ParseDuration(ttl)
+				conversationID, _ := msg["conversation_id"].(string)
+				var rejected *moderation.RejectedError
+				err := s.sendUserMessage(conversationID, content, userID, userName, client.Addr, duration)
+				if errors.As(err, &rejected) {
+					s.hub.SendJSONTo(client, map[string]string{"type": "rejected", "content": rejected.Message, "conversation_id": conversationID})
+				} else if errors.Is(err, conversation.ErrConversationLocked) {
+					s.hub.SendJSONTo(client, map[string]string{"type": "rejected", "content": "This conversation is closed.", "conversation_id": conversationID})
+				} else if err != nil {
+					log.Printf("Failed to send message of %s: %v", userName, err)
+				}
+			}
+		case "subscribe":
+			// The client only wants some conversations, message types or agents
+			subscription := Subscription{
+				Conversations: stringList(msg["conversations"]),
+				Types:         stringList(msg["types"]),
+				Agents:        stringList(msg["agents"]),
+			}
+			s.hub.Subscribe(client, subscription)
+			s.hub.SendJSONTo(client, map[string]interface{}{"type": "subscribed", "subscription": subscription})
+		case "unsubscribe":
+			// The client wants everything again
+			s.hub.Subscribe(client, Subscription{})
+			s.hub.SendJSONTo(client, map[string]interface{}{"type": "subscribed", "subscription": Subscription{}})
+		case "playback":
+			// The client replays a stored conversation; a new replay replaces the running one
+			conversationID, _ := msg["conversation_id"].(string)
+			speed, _ := msg["speed"].(string)
+			if number, ok := msg["speed"].(float64); ok {
+				speed = strconv.FormatFloat(number, 'f', -1, 64)
+			}
+			maxGap, _ := msg["max_gap"].(string)
+			p, err := parsePlayback(speed, maxGap)
+			if err != nil || conversationID == "" {
+				if err == nil {
+					err = errors.New("conversation_id is required")
+				}
+				s.hub.SendJSONTo(client, map[string]string{"type": "playback_error", "conversation_id": conversationID, "error": err.Error()})
+				continue
+			}
+			stopPlayback()
+			ctx, cancel := context.WithCancel(context.Background())
+			stopPlayback = cancel
+			go s.startPlayback(ctx, client, conversationID, p)
+		case "stop_playback":
+			stopPlayback()
+		case "mute", "unmute":
+			// The user hides an agent's messages, or shows them again
+			if agentID, _ := msg["agent_id"].(string); agentID != "" {
+				s.hub.Mute(userID, agentID, msg["type"] == "mute")
+			}
+		case "ack":
+			// The client displayed a message
+			messageID, _ := msg["message_id"].(string)
+			conversationID, _ := msg["conversation_id"].(string)
+			if messageID != "" {
+				s.markRead(conversationID, messageID, userID)
+			}
+		case "feedback":
+			// The user rated an agent message
+			messageID, _ := msg["message_id"].(string)
+			conversationID, _ := msg["conversation_id"].(string)
+			rating, _ := msg["rating"].(string)
+			if messageID != "" && (rating == "up" || rating == "down") {
+				if _, err := s.rateMessage(conversationID, messageID, userID, rating == "up"); err != nil {
+					log.Printf("Ignoring feedback from %s: %v", userName, err)
+				}
 			}
 		}
 	}
 
 	// Unregister client
-	s.clientsMu.Lock()
-	delete(s.clients, conn)
-	s.clientsMu.Unlock()
-	log.Printf("WebSocket client disconnected. Total clients: %d", len(s.clients))
+	s.hub.Unregister(client)
+	s.publishPresence(userID, userName, types.PresenceOffline)
+	log.Printf("WebSocket client disconnected. Total clients: %d", s.hub.Count())
 }
 
-// handleSendMessage handles HTTP POST requests to send messages
+// backfill returns the recent messages of the active conversation, encoded for a new client
+func (s *Server) backfill() [][]byte {
+	if s.config.BackfillMessages <= 0 {
+		return nil
+	}
+
+	now := time.Now()
+	var backlog [][]byte
+	for _, message := range s.convManager.GetRecentMessages(defaultConversationID, s.config.BackfillMessages) {
+		if message.Expired(now) {
+			continue
+		}
+		data, err := json.Marshal(message)
+		if err != nil {
+			log.Printf("Error marshaling message for backfill: %v", err)
+			continue
+		}
+		backlog = append(backlog, data)
+	}
+	return backlog
+}
+
+// handleHealth reports liveness for load balancers and Kubernetes probes
+func (s *Server) handleHealth(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"status":    "ok",
+		"instance":  s.instanceID,
+		"clients":   s.hub.Count(),
+		"consumers": s.kafkaClient.ConsumerStats(),
+	})
+}
+
+// handleSendMessage handles HTTP POST requests to send messages as the caller's session
 func (s *Server) handleSendMessage(c *gin.Context) {
 	var req struct {
-		Content string `json:"content"`
-		UserID  string `json:"user_id"`
+		Content        string `json:"content"`
+		TTL            string `json:"ttl"`             // Optional, e.g. "30s", for ephemeral messages
+		ConversationID string `json:"conversation_id"` // Optional; the main conversation by default
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -141,14 +418,36 @@ func (s *Server) handleSendMessage(c *gin.Context) {
 		return
 	}
 
-	// Generate user ID and name if not provided
-	userID := req.UserID
-	if userID == "" {
-		userID = uuid.New().String()
+	var ttl time.Duration
+	if req.TTL != "" {
+		var err error
+		if ttl, err = time.ParseDuration(req.TTL); err != nil || ttl <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "ttl must be a positive duration such as 30s"})
+			return
+		}
 	}
-	userName := "User-" + userID[:8]
 
-	if err := s.sendUserMessage(req.Content, userID, userName); err != nil {
+	userID := c.GetString(sessionUserKey)
+	userName := "User-" + userID[:min(8, len(userID))] // Short IDs are kept whole
+
+	if err := s.sendUserMessage(req.ConversationID, req.Content, userID, userName, c.ClientIP(), ttl); err != nil {
+		var rejected *moderation.RejectedError
+		if errors.As(err, &rejected) {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": rejected.Message})
+			return
+		}
+		if errors.Is(err, errAgentUserID) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, errUserBlocked) {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, conversation.ErrConversationLocked) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -156,39 +455,75 @@ func (s *Server) handleSendMessage(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"status": "message sent"})
 }
 
-// handleGetAgents returns information about available agents
-func (s *Server) handleGetAgents(c *gin.Context) {
-	// TODO: Query the actual agent manager for real agent information
-	// For now, return a simple response
-	c.JSON(http.StatusOK, gin.H{"agents": []map[string]string{}})
-}
-
-// sendUserMessage sends a user message to Kafka
-func (s *Server) sendUserMessage(content, userID, userName string) error {
+// sendUserMessage sends a user message, sent from addr, to Kafka, to the main
+// conversation when conversationID is empty; a positive ttl makes it
+// ephemeral. Messages the moderation filter rejects return a
+// *moderation.RejectedError.
+func (s *Server) sendUserMessage(conversationID, content, userID, userName, addr string, ttl time.Duration) error {
+	if conversationID == "" {
+		conversationID = defaultConversationID
+	}
+	if s.agentManager.IsAgentID(userID) {
+		return errAgentUserID
+	}
+	if s.hub.IsBlocked(userID, addr) {
+		return errUserBlocked
+	}
+	if s.convManager.IsLocked(conversationID) {
+		return conversation.ErrConversationLocked
+	}
+	var verdict moderation.Verdict
+	if s.moderation != nil && s.flags.moderation.Load() {
+		var err error
+		if verdict, err = s.moderation.Check(context.Background(), content); err != nil {
+			log.Printf("Rejected message of user %s (%s): %v", userName, userID, err)
+			return err
+		}
+	}
 	message := &types.ChatMessage{
-		ID:        generateID(),
+		ID:        uuid.New().String(), // Unique, as redeliveries are dropped by ID
 		Type:      types.MessageTypeUser,
 		Content:   content,
 		AgentID:   userID, // Treat user as an agent
 		UserID:    userID,
 		Timestamp: time.Now(),
 		Metadata: types.Metadata{
-			ConversationID: "main-conversation",
+			ConversationID: conversationID,
 			FromAgent:      userName, // Human-readable name
+			Command:        types.ParseCommand(content),
 		},
 	}
 
+	message.SetTTL(ttl)
+
 	log.Printf("User %s (%s) sending message: %s", userName, userID, content)
-	return s.kafkaClient.PublishMessage(context.Background(), message)
+	if err := s.kafkaClient.PublishMessage(context.Background(), message); err != nil {
+		return err
+	}
+	if verdict.Action == moderation.Flag {
+		s.moderation.AddFlag(moderation.FlaggedMessage{
+			MessageID:      message.ID,
+			ConversationID: conversationID,
+			UserID:         userID,
+			Content:        content,
+			Reason:         verdict.Reason,
+			FlaggedAt:      time.Now(),
+		})
+	}
+	return nil
 }
 
 // startMessageConsumer starts consuming messages from Kafka and broadcasting to WebSocket clients
 func (s *Server) startMessageConsumer() {
 	ctx := context.Background()
 
+	// Every web instance uses its own consumer group so that each replica
+	// receives all messages for its own connected clients
+	groupID := "philoking-web-" + s.instanceID
+
 	// Subscribe to all messages
 	go func() {
-		err := s.kafkaClient.SubscribeToMessages(ctx, "philoking-web", func(message *types.ChatMessage) error {
+		err := s.kafkaClient.SubscribeFromLatest(ctx, groupID, func(message *types.ChatMessage) error {
 			s.broadcastMessage(message)
 			return nil
 		})
@@ -196,13 +531,45 @@ func (s *Server) startMessageConsumer() {
 			log.Printf("Error in message consumer: %v", err)
 		}
 	}()
+
+	// Deletions and other control events are relayed to clients as well
+	go func() {
+		err := s.kafkaClient.SubscribeToControl(ctx, groupID+"-control", func(message *types.ChatMessage) error {
+			s.broadcastMessage(message)
+			return nil
+		})
+		if err != nil {
+			log.Printf("Error in control consumer: %v", err)
+		}
+	}()
+
+	// Agent failures for the admins
+	if s.kafkaClient.ErrorsEnabled() {
+		go func() {
+			err := s.kafkaClient.SubscribeToErrors(ctx, groupID+"-errors", func(event *types.ErrorEvent) error {
+				s.errors.add(event)
+				return nil
+			})
+			if err != nil {
+				log.Printf("Error in error feed consumer: %v", err)
+			}
+		}()
+	}
+
+	// Presence of users on every replica
+	go func() {
+		err := s.kafkaClient.SubscribeToPresence(ctx, groupID+"-presence", func(event *types.PresenceEvent) error {
+			s.broadcastPresence(event)
+			return nil
+		})
+		if err != nil {
+			log.Printf("Error in presence consumer: %v", err)
+		}
+	}()
 }
 
 // broadcastMessage broadcasts a message to all connected WebSocket clients
 func (s *Server) broadcastMessage(message *types.ChatMessage) {
-	s.clientsMu.RLock()
-	defer s.clientsMu.RUnlock()
-
 	// Get sender name for display
 	senderName := message.AgentID
 	if message.Metadata.FromAgent != "" {
@@ -218,26 +585,53 @@ func (s *Server) broadcastMessage(message *types.ChatMessage) {
 		return
 	}
 
-	// Broadcast to all clients
-	for conn, clientInfo := range s.clients {
-		if err := conn.WriteMessage(websocket.TextMessage, data); err != nil {
-			log.Printf("Error broadcasting to client %s: %v", clientInfo.Name, err)
-			conn.Close()
-			delete(s.clients, conn)
-		}
+	// Broadcast to the clients that subscribed to it, except those that muted the sender
+	conversationID := message.Metadata.ConversationID
+	if conversationID == "" {
+		conversationID = defaultConversationID
+	}
+	s.hub.BroadcastMessage(Envelope{ConversationID: conversationID, Type: string(message.Type), SenderID: message.AgentID}, data)
+	s.feed.publish(message)
+}
+
+// publishPresence announces a user connecting to or disconnecting from this replica
+func (s *Server) publishPresence(userID, userName string, status types.PresenceStatus) {
+	event := &types.PresenceEvent{
+		UserID:     userID,
+		Name:       userName,
+		Status:     status,
+		InstanceID: s.instanceID,
+		Timestamp:  time.Now(),
+	}
+	if err := s.kafkaClient.PublishPresence(context.Background(), event); err != nil {
+		log.Printf("Failed to publish presence of %s: %v", userName, err)
 	}
 }
 
-// generateID generates a simple ID (in production, use a proper UUID library)
-func generateID() string {
-	return time.Now().Format("20060102150405") + "-" + randomString(6)
+// broadcastPresence tells the WebSocket clients that a user came or went
+func (s *Server) broadcastPresence(event *types.PresenceEvent) {
+	data, err := json.Marshal(map[string]interface{}{
+		"type":      "presence",
+		"user_id":   event.UserID,
+		"name":      event.Name,
+		"status":    event.Status,
+		"timestamp": event.Timestamp,
+	})
+	if err != nil {
+		log.Printf("Error marshaling presence event: %v", err)
+		return
+	}
+	s.hub.BroadcastMessage(Envelope{Type: "presence"}, data)
 }
 
-func randomString(length int) string {
-	const charset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
-	b := make([]byte, length)
-	for i := range b {
-		b[i] = charset[time.Now().UnixNano()%int64(len(charset))]
+// stringList reads a list of strings from a decoded WebSocket message
+func stringList(value interface{}) []string {
+	items, _ := value.([]interface{})
+	var list []string
+	for _, item := range items {
+		if s, ok := item.(string); ok && s != "" {
+			list = append(list, s)
+		}
 	}
-	return string(b)
+	return list
 }
@@ -0,0 +1,98 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"philoking/internal/agent"
+	"philoking/internal/config"
+	"philoking/internal/conversation"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestSendMessageRejectsAgentAndBlockedUserIDs(t *testing.T) {
+	agents := agent.NewManager(nil, config.AgentsConfig{Agents: []config.AgentConfig{
+		{ID: "socrates", IsEnabled: true},
+		{ID: "plato"}, // Disabled agents keep their ID
+	}})
+	s := NewServer(config.WebConfig{}, nil, conversation.NewManager(), nil, agents, nil)
+	s.hub.Block("troll")
+	s.hub.Block("192.0.2.7")
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/api/message", s.requireSession, s.handleSendMessage)
+
+	tests := []struct {
+		name   string
+		body   string
+		token  string
+		addr   string
+		status int
+	}{
+		{name: "no session", body: `{"content":"I am Socrates","user_id":"socrates"}`, status: http.StatusUnauthorized},
+		{name: "forged session", body: `{"content":"I am Socrates"}`, token: "socrates.forged", status: http.StatusUnauthorized},
+		{name: "agent ID", body: `{"content":"I am Socrates"}`, token: s.sessionToken("socrates"), status: http.StatusBadRequest},
+		{name: "disabled agent ID", body: `{"content":"I am Plato"}`, token: s.sessionToken("plato"), status: http.StatusBadRequest},
+		{name: "blocked user", body: `{"content":"hi"}`, token: s.sessionToken("troll"), status: http.StatusForbidden},
+		{name: "blocked address", body: `{"content":"hi"}`, token: s.sessionToken("troll-again"), addr: "192.0.2.7:4242", status: http.StatusForbidden},
+		{name: "malformed body", body: `{`, token: s.sessionToken("alice"), status: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/message", strings.NewReader(tt.body))
+			if tt.token != "" {
+				req.Header.Set(sessionHeader, tt.token)
+			}
+			if tt.addr != "" {
+				req.RemoteAddr = tt.addr
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tt.status {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.status, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestStateChangingRoutesRequireAdminOrSession(t *testing.T) {
+	// Server.Handler loads the templates relative to the repository root
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir("../.."); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	s := NewServer(config.WebConfig{AdminToken: "secret"}, nil, conversation.NewManager(), nil, agent.NewManager(nil, config.AgentsConfig{}), nil)
+	engine := s.Handler().(*gin.Engine)
+
+	// Users act through their session; these are open to anyone
+	public := map[string]bool{
+		"POST /api/session": true, // Starts one
+		"POST /graphql":     true, // Queries only
+	}
+	for _, route := range engine.Routes() {
+		if route.Method == http.MethodGet || route.Method == http.MethodHead {
+			continue
+		}
+		name := route.Method + " " + route.Path
+		if public[name] {
+			continue
+		}
+		t.Run(name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, httptest.NewRequest(route.Method, strings.ReplaceAll(route.Path, "*name", "x"), strings.NewReader("{}")))
+			if w.Code != http.StatusUnauthorized {
+				t.Errorf("status = %d without the admin token or a session, want %d", w.Code, http.StatusUnauthorized)
+			}
+		})
+	}
+}
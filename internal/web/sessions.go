@@ -0,0 +1,77 @@
+package web
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// errUserBlocked is returned for messages of users moderators blocked
+var errUserBlocked = errors.New("you have been blocked by a moderator")
+
+// errAgentUserID is returned for messages of users whose ID is an agent's,
+// which would let them pass for that agent
+var errAgentUserID = errors.New("user_id belongs to an agent")
+
+// handleListMutes returns the agents the caller muted in their connected sessions
+func (s *Server) handleListMutes(c *gin.Context) {
+	muted, connected := s.hub.Muted(c.GetString(sessionUserKey))
+	if !connected {
+		c.JSON(http.StatusNotFound, gin.H{"error": "session not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"muted": muted})
+}
+
+// handleMuteAgent hides an agent's messages from the caller's WebSocket stream
+func (s *Server) handleMuteAgent(c *gin.Context) {
+	s.setMuted(c, true)
+}
+
+// handleUnmuteAgent shows a muted agent's messages to the caller again
+func (s *Server) handleUnmuteAgent(c *gin.Context) {
+	s.setMuted(c, false)
+}
+
+// setMuted mutes or unmutes an agent for the caller's connected sessions
+func (s *Server) setMuted(c *gin.Context, muted bool) {
+	userID := c.GetString(sessionUserKey)
+	agentID := c.Param("agentId")
+	if _, exists := s.agentManager.GetAgent(agentID); !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "agent not found"})
+		return
+	}
+	if !s.hub.Mute(userID, agentID, muted) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "session not found"})
+		return
+	}
+	current, _ := s.hub.Muted(userID)
+	c.JSON(http.StatusOK, gin.H{"muted": current})
+}
+
+// handleListSessions lists the users connected to this instance
+func (s *Server) handleListSessions(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"sessions": s.hub.Sessions()})
+}
+
+// handleListBlocks lists the blocked users and addresses
+func (s *Server) handleListBlocks(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"blocked": s.hub.Blocked()})
+}
+
+// handleBlockUser disconnects a user's sessions, or those from an address,
+// and refuses their messages
+func (s *Server) handleBlockUser(c *gin.Context) {
+	s.hub.Block(c.Param("id"))
+	c.JSON(http.StatusOK, gin.H{"blocked": c.Param("id")})
+}
+
+// handleUnblockUser lets a blocked user send messages again
+func (s *Server) handleUnblockUser(c *gin.Context) {
+	if !s.hub.Unblock(c.Param("id")) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user is not blocked"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"unblocked": c.Param("id")})
+}
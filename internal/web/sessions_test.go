@@ -0,0 +1,62 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"philoking/internal/agent"
+	"philoking/internal/config"
+	"philoking/internal/conversation"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestMutesAreTheCallersOwn(t *testing.T) {
+	conversations := conversation.NewManager()
+	agents := agent.NewManager(nil, config.AgentsConfig{})
+	if err := agents.RegisterAgent(agent.NewEchoAgent("socrates", "Socrates", nil, 1, conversations)); err != nil {
+		t.Fatal(err)
+	}
+	s := NewServer(config.WebConfig{}, nil, conversations, nil, agents, nil)
+	connectClient(t, s.hub, "alice")
+	connectClient(t, s.hub, "bob")
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	session := r.Group("/api/session", s.requireSession)
+	session.GET("/mutes", s.handleListMutes)
+	session.PUT("/mutes/:agentId", s.handleMuteAgent)
+	session.DELETE("/mutes/:agentId", s.handleUnmuteAgent)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		token  string
+		status int
+		want   string // In the body
+	}{
+		{name: "without a session", method: http.MethodPut, path: "/api/session/mutes/socrates", status: http.StatusUnauthorized},
+		{name: "forged session", method: http.MethodPut, path: "/api/session/mutes/socrates", token: "bob.forged", status: http.StatusUnauthorized},
+		{name: "unknown agent", method: http.MethodPut, path: "/api/session/mutes/nobody", token: s.sessionToken("alice"), status: http.StatusNotFound},
+		{name: "not connected", method: http.MethodPut, path: "/api/session/mutes/socrates", token: s.sessionToken("carol"), status: http.StatusNotFound},
+		{name: "mute", method: http.MethodPut, path: "/api/session/mutes/socrates", token: s.sessionToken("alice"), status: http.StatusOK, want: `["socrates"]`},
+		{name: "others are not muted", method: http.MethodGet, path: "/api/session/mutes", token: s.sessionToken("bob"), status: http.StatusOK, want: `"muted":[]`},
+		{name: "unmute", method: http.MethodDelete, path: "/api/session/mutes/socrates", token: s.sessionToken("alice"), status: http.StatusOK, want: `"muted":[]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.token != "" {
+				req.Header.Set(sessionHeader, tt.token)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tt.status || !strings.Contains(w.Body.String(), tt.want) {
+				t.Errorf("%d %s, want %d with %s", w.Code, w.Body.String(), tt.status, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package web
+
+import (
+	"net/http"
+
+	"philoking/internal/tasks"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UseTasks enables the task queue endpoints
+func (s *Server) UseTasks(queue *tasks.Queue) {
+	s.tasks = queue
+}
+
+// requireTasks responds with an error unless the task queue is enabled
+func (s *Server) requireTasks(c *gin.Context) bool {
+	if s.tasks == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "the task queue is not enabled"})
+		return false
+	}
+	return true
+}
+
+// handleListTasks lists the tasks of a conversation, newest first
+func (s *Server) handleListTasks(c *gin.Context) {
+	if !s.requireTasks(c) {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"tasks": s.tasks.List(c.Param("id"))})
+}
+
+// handleCreateTask queues a task for an agent in a conversation
+func (s *Server) handleCreateTask(c *gin.Context) {
+	if !s.requireTasks(c) {
+		return
+	}
+
+	var req struct {
+		Kind    string `json:"kind" binding:"required"`
+		AgentID string `json:"agent_id" binding:"required"`
+		Input   string `json:"input" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if _, exists := s.agentManager.GetAgent(req.AgentID); !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "agent not found"})
+		return
+	}
+
+	task, err := s.tasks.Enqueue(c.Request.Context(), tasks.Task{
+		Kind:           req.Kind,
+		ConversationID: c.Param("id"),
+		AgentID:        req.AgentID,
+		Input:          req.Input,
+	})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusAccepted, task)
+}
+
+// handleGetTask returns a task with its status, progress and result
+func (s *Server) handleGetTask(c *gin.Context) {
+	if !s.requireTasks(c) {
+		return
+	}
+
+	task, exists := s.tasks.Get(c.Param("id"))
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "task not found"})
+		return
+	}
+	c.JSON(http.StatusOK, task)
+}
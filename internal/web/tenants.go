@@ -0,0 +1,119 @@
+package web
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+
+	"philoking/internal/config"
+)
+
+// apiKeyCookie remembers the API key a browser opened the chat with, so the
+// page's own API calls and WebSocket reach the same tenant
+const apiKeyCookie = "philoking_api_key"
+
+// TenantRouter serves several tenants from one listener. Every request must
+// carry a tenant's API key, and only reaches that tenant's server.
+type TenantRouter struct {
+	config  config.WebConfig
+	tenants map[[sha256.Size]byte]tenantRoute // By hashed API key
+	servers []*Server
+	static  http.Handler
+}
+
+// tenantRoute is the server of a tenant
+type tenantRoute struct {
+	id      string
+	handler http.Handler
+}
+
+// NewTenantRouter creates a router without tenants
+func NewTenantRouter(cfg config.WebConfig) *TenantRouter {
+	return &TenantRouter{
+		config:  cfg,
+		tenants: make(map[[sha256.Size]byte]tenantRoute),
+		static:  http.StripPrefix("/static/", http.FileServer(http.Dir("./web/static"))),
+	}
+}
+
+// Add routes the requests made with the given API keys to a tenant's server
+func (t *TenantRouter) Add(tenantID string, apiKeys []string, server *Server) {
+	route := tenantRoute{id: tenantID, handler: server.Handler()}
+	for _, key := range apiKeys {
+		t.tenants[sha256.Sum256([]byte(key))] = route
+	}
+	t.servers = append(t.servers, server)
+}
+
+// Start relays the Kafka messages of every tenant to its WebSocket clients
+// and serves the tenants on the configured address
+func (t *TenantRouter) Start() error {
+	for _, server := range t.servers {
+		go server.startMessageConsumer()
+	}
+	addr := t.config.Host + ":" + t.config.Port
+	log.Printf("Web server starting on %s for %d tenant(s)", addr, len(t.tenantIDs()))
+	return http.ListenAndServe(addr, t)
+}
+
+// ServeHTTP picks the tenant by API key. Static files and the liveness
+// check need no key.
+func (t *TenantRouter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case strings.HasPrefix(r.URL.Path, "/static/"):
+		t.static.ServeHTTP(w, r)
+		return
+	case r.URL.Path == "/healthz" && apiKey(r) == "":
+		writeJSON(w, http.StatusOK, map[string]any{"status": "ok", "tenants": len(t.tenantIDs())})
+		return
+	}
+
+	key := apiKey(r)
+	route, ok := t.tenants[sha256.Sum256([]byte(key))]
+	if key == "" || !ok {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "a valid API key is required"})
+		return
+	}
+
+	// Opening the chat with ?api_key= keeps the browser on this tenant
+	if r.URL.Query().Get("api_key") != "" {
+		http.SetCookie(w, &http.Cookie{Name: apiKeyCookie, Value: key, Path: "/", HttpOnly: true, SameSite: http.SameSiteStrictMode})
+	}
+	route.handler.ServeHTTP(w, r)
+}
+
+// tenantIDs lists the tenants, once each
+func (t *TenantRouter) tenantIDs() map[string]bool {
+	ids := make(map[string]bool)
+	for _, route := range t.tenants {
+		ids[route.id] = true
+	}
+	return ids
+}
+
+// apiKey returns the API key of a request, from the X-API-Key header, a
+// bearer token, the api_key query parameter or the cookie, in that order
+func apiKey(r *http.Request) string {
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return key
+	}
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return strings.TrimSpace(token)
+	}
+	if key := r.URL.Query().Get("api_key"); key != "" {
+		return key
+	}
+	if cookie, err := r.Cookie(apiKeyCookie); err == nil {
+		return cookie.Value
+	}
+	return ""
+}
+
+// writeJSON writes a JSON response outside of gin
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,116 @@
+package web
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"philoking/internal/agent"
+	"philoking/internal/config"
+	"philoking/internal/conversation"
+	"philoking/internal/types"
+)
+
+func TestTenantRouterIsolatesTenants(t *testing.T) {
+	// Server.Handler loads the templates relative to the repository root
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir("../.."); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	router := NewTenantRouter(config.WebConfig{})
+	for _, tenant := range []struct {
+		id           string
+		keys         []string
+		conversation string
+	}{
+		{id: "acme", keys: []string{"acme-key", "acme-key-2"}, conversation: "acme-talk"},
+		{id: "globex", keys: []string{"globex-key"}, conversation: "globex-talk"},
+	} {
+		conversations := conversation.NewManager()
+		conversations.AddMessage(tenant.conversation, &types.ChatMessage{ID: tenant.id + "-1", Type: types.MessageTypeUser, Content: "hello"})
+		server := NewServer(config.WebConfig{}, nil, conversations, nil, agent.NewManager(nil, config.AgentsConfig{}), nil)
+		router.Add(tenant.id, tenant.keys, server)
+	}
+
+	tests := []struct {
+		name   string
+		path   string
+		header http.Header
+		cookie string
+		status int
+		want   string // The only conversation listed
+	}{
+		{name: "API key header", path: "/api/conversations", header: http.Header{"X-Api-Key": {"acme-key"}}, status: http.StatusOK, want: "acme-talk"},
+		{name: "second API key", path: "/api/conversations", header: http.Header{"X-Api-Key": {"acme-key-2"}}, status: http.StatusOK, want: "acme-talk"},
+		{name: "bearer token", path: "/api/conversations", header: http.Header{"Authorization": {"Bearer globex-key"}}, status: http.StatusOK, want: "globex-talk"},
+		{name: "query parameter", path: "/api/conversations?api_key=globex-key", status: http.StatusOK, want: "globex-talk"},
+		{name: "cookie", path: "/api/conversations", cookie: "acme-key", status: http.StatusOK, want: "acme-talk"},
+		{name: "header wins", path: "/api/conversations", header: http.Header{"X-Api-Key": {"acme-key"}, "Authorization": {"Bearer globex-key"}}, status: http.StatusOK, want: "acme-talk"},
+		{name: "other tenant's conversation", path: "/api/conversations/globex-talk/messages", header: http.Header{"X-Api-Key": {"acme-key"}}, status: http.StatusNotFound},
+		{name: "own conversation", path: "/api/conversations/globex-talk/messages", header: http.Header{"X-Api-Key": {"globex-key"}}, status: http.StatusOK},
+		{name: "no key", path: "/api/conversations", status: http.StatusUnauthorized},
+		{name: "wrong key", path: "/api/conversations", header: http.Header{"X-Api-Key": {"initech-key"}}, status: http.StatusUnauthorized},
+		{name: "chat page without key", path: "/", status: http.StatusUnauthorized},
+		{name: "health check without key", path: "/healthz", status: http.StatusOK},
+		{name: "static files without key", path: "/static/does-not-exist.js", status: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			for key, values := range tt.header {
+				req.Header[key] = values
+			}
+			if tt.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: apiKeyCookie, Value: tt.cookie})
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body.String())
+			}
+			if tt.want == "" {
+				return
+			}
+			var page struct {
+				Conversations []struct {
+					ID string `json:"id"`
+				} `json:"conversations"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &page); err != nil {
+				t.Fatal(err)
+			}
+			if len(page.Conversations) != 1 || page.Conversations[0].ID != tt.want {
+				t.Errorf("conversations = %+v, want only %s", page.Conversations, tt.want)
+			}
+		})
+	}
+}
+
+func TestTenantRouterRemembersQueryKey(t *testing.T) {
+	router := NewTenantRouter(config.WebConfig{})
+	router.tenants[sha256.Sum256([]byte("acme-key"))] = tenantRoute{id: "acme", handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/?api_key=acme-key", nil))
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != apiKeyCookie || cookies[0].Value != "acme-key" || !cookies[0].HttpOnly {
+		t.Errorf("cookies = %+v, want the API key remembered", cookies)
+	}
+
+	w = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-API-Key", "acme-key")
+	router.ServeHTTP(w, req)
+	if cookies := w.Result().Cookies(); len(cookies) != 0 {
+		t.Errorf("cookies = %+v, want none for a header key", cookies)
+	}
+}
@@ -0,0 +1,232 @@
+// Package agentplugin is the SDK for agent plugins: custom agent types built
+// as separate programs. The host starts the plugin's program for every agent
+// of its type and talks to it with JSON-RPC over the program's stdin and
+// stdout, so a plugin shares nothing with the host but this protocol, not
+// even its Go version. Plugins must therefore log to stderr, never stdout.
+// The calls are plain JSON-RPC 1.0, one object per call, rather than the gRPC
+// of hashicorp/go-plugin, so a plugin can also be written in a language with
+// nothing but a JSON library.
+//
+// A plugin implements Handler and hands it to Serve:
+//
+//	func main() {
+//		agentplugin.Serve(&shouter{})
+//	}
+package agentplugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"os"
+	"os/exec"
+	"time"
+)
+
+// ProtocolVersion is raised on incompatible changes of the protocol
+const ProtocolVersion = 1
+
+// serviceName is the RPC service a plugin registers
+const serviceName = "Plugin"
+
+// shutdownTimeout is how long a plugin gets to exit before it is killed
+const shutdownTimeout = 5 * time.Second
+
+// Message is a chat message as plugins see it
+type Message struct {
+	ID             string            `json:"id"`
+	Type           string            `json:"type"` // "user", "agent", "system", ...
+	Content        string            `json:"content"`
+	AgentID        string            `json:"agent_id,omitempty"`
+	UserID         string            `json:"user_id,omitempty"`
+	FromName       string            `json:"from_name,omitempty"` // Human-readable sender name
+	ConversationID string            `json:"conversation_id"`
+	ReplyTo        string            `json:"reply_to,omitempty"`
+	Tags           []string          `json:"tags,omitempty"`
+	Custom         map[string]string `json:"custom,omitempty"`
+	Timestamp      time.Time         `json:"timestamp"`
+}
+
+// Config describes the agent a plugin process serves
+type Config struct {
+	ProtocolVersion int               `json:"protocol_version"`
+	ID              string            `json:"id"`
+	Name            string            `json:"name"`
+	Description     string            `json:"description,omitempty"`
+	Settings        map[string]string `json:"settings,omitempty"` // The agent's settings from the host config
+}
+
+// Reply is a message the agent sends to the conversation it was handling
+type Reply struct {
+	Content string   `json:"content"`
+	Tags    []string `json:"tags,omitempty"`
+}
+
+// Handler is implemented by plugins. It mirrors the host's agent.Agent and
+// agent.MessageHandler: Init and Shutdown stand in for Start and Stop, and
+// HandleMessage returns the replies to send instead of publishing them. The
+// host has already applied the agent's response chance and the conversation
+// rules, so every message passed in is one the agent may answer.
+type Handler interface {
+	Init(cfg Config) error
+	HandleMessage(message Message, history []Message) ([]Reply, error)
+	Shutdown() error
+}
+
+// Empty is the argument or result of calls without one
+type Empty struct{}
+
+// HandleArgs is the argument of the HandleMessage call
+type HandleArgs struct {
+	Message Message   `json:"message"`
+	History []Message `json:"history"` // Recent messages of the conversation, oldest first
+}
+
+// HandleResult is the result of the HandleMessage call
+type HandleResult struct {
+	Replies []Reply `json:"replies"`
+}
+
+// service exposes a Handler over RPC
+type service struct {
+	handler Handler
+}
+
+func (s *service) Init(cfg Config, _ *Empty) error {
+	if cfg.ProtocolVersion != ProtocolVersion {
+		return fmt.Errorf("plugin speaks protocol version %d, host speaks %d", ProtocolVersion, cfg.ProtocolVersion)
+	}
+	return s.handler.Init(cfg)
+}
+
+func (s *service) HandleMessage(args HandleArgs, result *HandleResult) error {
+	replies, err := s.handler.HandleMessage(args.Message, args.History)
+	result.Replies = replies
+	return err
+}
+
+func (s *service) Shutdown(_ Empty, _ *Empty) error {
+	return s.handler.Shutdown()
+}
+
+// Serve answers the host's calls on stdin and stdout until the host closes
+// stdin. It sends the standard logger to stderr.
+func Serve(handler Handler) {
+	log.SetOutput(os.Stderr)
+
+	server := rpc.NewServer()
+	if err := server.RegisterName(serviceName, &service{handler: handler}); err != nil {
+		log.Fatalf("Failed to register plugin: %v", err)
+	}
+	server.ServeCodec(jsonrpc.NewServerCodec(stdio{Reader: os.Stdin, WriteCloser: os.Stdout}))
+}
+
+// stdio joins a process's input and output into one connection
+type stdio struct {
+	io.Reader
+	io.WriteCloser
+}
+
+// Client runs a plugin program and calls it; the host uses it
+type Client struct {
+	cmd  *exec.Cmd
+	rpc  *rpc.Client
+	done chan struct{} // Closed when the process has exited
+}
+
+// Launch starts a plugin program; its stderr goes to the host's stderr
+func Launch(path string, args ...string) (*Client, error) {
+	cmd := exec.Command(path, args...)
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start plugin %s: %w", path, err)
+	}
+
+	c := &Client{
+		cmd:  cmd,
+		rpc:  rpc.NewClientWithCodec(jsonrpc.NewClientCodec(stdio{Reader: stdout, WriteCloser: stdin})),
+		done: make(chan struct{}),
+	}
+	go func() {
+		cmd.Wait()
+		close(c.done)
+	}()
+	return c, nil
+}
+
+// Init tells the plugin which agent it serves
+func (c *Client) Init(ctx context.Context, cfg Config) error {
+	cfg.ProtocolVersion = ProtocolVersion
+	return c.call(ctx, "Init", cfg, &Empty{})
+}
+
+// HandleMessage passes a message to the plugin and returns its replies
+func (c *Client) HandleMessage(ctx context.Context, message Message, history []Message) ([]Reply, error) {
+	var result HandleResult
+	if err := c.call(ctx, "HandleMessage", HandleArgs{Message: message, History: history}, &result); err != nil {
+		return nil, err
+	}
+	return result.Replies, nil
+}
+
+// Exited reports whether the plugin process has exited, e.g. after a crash
+func (c *Client) Exited() bool {
+	select {
+	case <-c.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// Close asks the plugin to shut down and waits for it to exit, killing it
+// when it takes too long
+func (c *Client) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	err := c.call(ctx, "Shutdown", Empty{}, &Empty{})
+	c.rpc.Close()
+
+	select {
+	case <-c.done:
+	case <-ctx.Done():
+		c.cmd.Process.Kill()
+		<-c.done
+	}
+	if errors.Is(err, rpc.ErrShutdown) {
+		return nil
+	}
+	return err
+}
+
+// call makes an RPC call that gives up when ctx is done
+func (c *Client) call(ctx context.Context, method string, args, reply interface{}) error {
+	call := c.rpc.Go(serviceName+"."+method, args, reply, make(chan *rpc.Call, 1))
+	select {
+	case <-call.Done:
+		return call.Error
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-c.done:
+		// The answer may have arrived just before the exit
+		select {
+		case <-call.Done:
+			return call.Error
+		default:
+		}
+		return fmt.Errorf("plugin exited: %w", rpc.ErrShutdown)
+	}
+}
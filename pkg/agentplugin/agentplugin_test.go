@@ -0,0 +1,182 @@
+package agentplugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// stubEnv makes the test binary run as a stub plugin instead of the tests
+const stubEnv = "AGENTPLUGIN_STUB"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(stubEnv) != "" {
+		Serve(&stub{})
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// stub answers messages by their content: "fail" returns an error, "crash"
+// exits, "hang" never answers, and anything else is echoed with the greeting
+// from the settings and the size of the history
+type stub struct {
+	cfg Config
+}
+
+func (s *stub) Init(cfg Config) error {
+	if cfg.Settings["greeting"] == "" {
+		return errors.New("no greeting configured")
+	}
+	s.cfg = cfg
+	return nil
+}
+
+func (s *stub) HandleMessage(message Message, history []Message) ([]Reply, error) {
+	switch message.Content {
+	case "fail":
+		return nil, errors.New("cannot answer that")
+	case "crash":
+		os.Exit(3)
+	case "hang":
+		select {}
+	}
+	return []Reply{{
+		Content: fmt.Sprintf("%s %s, %s said %q after %d messages", s.cfg.Settings["greeting"], message.FromName, s.cfg.Name, message.Content, len(history)),
+		Tags:    []string{"stub"},
+	}}, nil
+}
+
+func (s *stub) Shutdown() error {
+	return nil
+}
+
+// launchStub starts the stub plugin and initializes it
+func launchStub(t *testing.T) *Client {
+	t.Setenv(stubEnv, "1")
+	client, err := Launch(os.Args[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := client.Init(ctx, Config{ID: "stub", Name: "Stub", Settings: map[string]string{"greeting": "Hello"}}); err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	return client
+}
+
+func TestPluginProtocol(t *testing.T) {
+	client := launchStub(t)
+	history := []Message{{ID: "m1", Content: "earlier"}, {ID: "m2", Content: "later"}}
+
+	tests := []struct {
+		name    string
+		content string
+		want    string
+		wantErr string
+	}{
+		{name: "reply", content: "hi", want: `Hello Ada, Stub said "hi" after 2 messages`},
+		{name: "handler error", content: "fail", wantErr: "cannot answer that"},
+		{name: "after an error", content: "again", want: `Hello Ada, Stub said "again" after 2 messages`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			replies, err := client.HandleMessage(ctx, Message{ID: "m3", Content: tt.content, FromName: "Ada"}, history)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("HandleMessage = %v, want an error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(replies) != 1 || replies[0].Content != tt.want || len(replies[0].Tags) != 1 {
+				t.Errorf("replies = %+v, want %q", replies, tt.want)
+			}
+		})
+	}
+
+	if err := client.Close(); err != nil {
+		t.Errorf("Close: %v", err)
+	}
+	if !client.Exited() {
+		t.Error("the plugin is still running after Close")
+	}
+}
+
+func TestPluginInitError(t *testing.T) {
+	t.Setenv(stubEnv, "1")
+	client, err := Launch(os.Args[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	err = client.Init(context.Background(), Config{ID: "stub"})
+	if err == nil || !strings.Contains(err.Error(), "no greeting configured") {
+		t.Errorf("Init = %v, want the plugin's error", err)
+	}
+}
+
+func TestPluginCrash(t *testing.T) {
+	client := launchStub(t)
+
+	_, err := client.HandleMessage(context.Background(), Message{Content: "crash"}, nil)
+	if err == nil {
+		t.Fatal("HandleMessage succeeded although the plugin exited")
+	}
+	select {
+	case <-client.done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the plugin did not exit")
+	}
+	if !client.Exited() {
+		t.Error("Exited = false after a crash")
+	}
+}
+
+func TestPluginTimeout(t *testing.T) {
+	client := launchStub(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if _, err := client.HandleMessage(ctx, Message{Content: "hang"}, nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("HandleMessage = %v, want the deadline error", err)
+	}
+	if client.Exited() {
+		t.Error("a slow answer stopped the plugin")
+	}
+
+	// The plugin still answers other calls
+	replies, err := client.HandleMessage(context.Background(), Message{Content: "hi"}, nil)
+	if err != nil || len(replies) != 1 {
+		t.Errorf("HandleMessage after a timeout = %+v, %v", replies, err)
+	}
+
+	// but waits for the hanging call before exiting, so Close has to kill it
+	start := time.Now()
+	if err := client.Close(); err != nil {
+		t.Errorf("Close: %v", err)
+	}
+	if !client.Exited() || time.Since(start) < shutdownTimeout {
+		t.Errorf("Close returned after %v, want the plugin killed after %v", time.Since(start), shutdownTimeout)
+	}
+}
+
+func TestServiceRejectsOtherProtocolVersions(t *testing.T) {
+	s := &service{handler: &stub{}}
+	err := s.Init(Config{ProtocolVersion: ProtocolVersion + 1, Settings: map[string]string{"greeting": "Hello"}}, &Empty{})
+	if err == nil || !strings.Contains(err.Error(), "protocol version") {
+		t.Errorf("Init = %v, want a protocol version error", err)
+	}
+}
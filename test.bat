@@ -9,7 +9,7 @@ echo Waiting for Kafka to be ready...
 timeout /t 3 /nobreak > nul
 
 echo 2. Building application...
-go build -o philoking.exe main.go
+go build -o philoking.exe ./cmd/philoking
 
 if %errorlevel% neq 0 (
     echo ❌ Build failed!
@@ -37,4 +37,4 @@ set MODEL=gpt-oss:20b
 set OLLAMA_URL=http://localhost:11434
 
 REM Run the application
-philoking.exe
+philoking.exe serve
@@ -6,6 +6,9 @@ class ChatApp {
         this.sendButton = document.getElementById('send-button');
         this.messagesContainer = document.getElementById('messages');
         this.connectionStatus = document.getElementById('connection-status');
+        this.whiteboardTitle = document.getElementById('whiteboard-title');
+        this.whiteboard = document.getElementById('whiteboard');
+        this.whiteboardVersion = 0;
         
         this.init();
     }
@@ -100,6 +103,54 @@ class ChatApp {
         if (message.type === 'pong') {
             return; // Handle ping/pong
         }
+
+        if (message.type === 'presence') {
+            this.showPresence(message);
+            return;
+        }
+
+        if (message.type === 'receipt') {
+            this.showReceipt(message);
+            return;
+        }
+
+        if (message.type === 'feedback') {
+            this.showFeedback(message);
+            return;
+        }
+
+        if (message.type === 'score') {
+            this.showScore(message);
+            return;
+        }
+
+        if (message.type === 'whiteboard') {
+            this.showWhiteboard(message);
+            return;
+        }
+
+        if (message.type === 'session') {
+            // The server names this connection's session; the session API is keyed on it
+            this.userId = message.user_id;
+            return;
+        }
+
+        if (message.type === 'rejected') {
+            // The server didn't post the user's message
+            this.addMessage({ type: 'system', content: message.content });
+            return;
+        }
+
+        if (message.type === 'alert') {
+            // E.g. an agent the supervisor restarted
+            this.addMessage({ type: 'system', content: message.content });
+            return;
+        }
+
+        if (message.type === 'deletion') {
+            this.removeMessage(message.metadata && message.metadata.custom && message.metadata.custom.message_id);
+            return;
+        }
         
         this.addMessage(message);
     }
@@ -109,27 +160,219 @@ class ChatApp {
         
         const messageElement = document.createElement('div');
         messageElement.className = `message ${message.type}-message`;
+        messageElement.dataset.messageId = message.id;
+
+        // Side conversations between two agents are shown as asides
+        const conversationId = message.metadata && message.metadata.conversation_id;
+        if (conversationId && conversationId.startsWith('side-')) {
+            messageElement.classList.add('side-message');
+        }
         
         const contentElement = document.createElement('div');
         contentElement.className = 'message-content';
         contentElement.textContent = message.content;
         
         messageElement.appendChild(contentElement);
+
+        // Agents cite what they took from other conversations as [1], [2], ...
+        const citations = this.parseCitations(message);
+        if (citations.length > 0) {
+            messageElement.appendChild(this.createCitations(citations));
+        }
         
         // Add metadata if available
         if (message.agent_id || message.user_id) {
             const metaElement = document.createElement('div');
             metaElement.className = 'message-meta';
             metaElement.textContent = message.agent_id || message.user_id;
+            const usage = this.formatUsage(message);
+            if (usage) {
+                metaElement.textContent += ' · ' + usage;
+            }
             messageElement.appendChild(metaElement);
         }
         
+        // Agent messages can be rated with a thumbs up or down
+        if (message.type === 'agent' && message.id) {
+            messageElement.appendChild(this.createFeedbackButtons(message.id, conversationId));
+        }
+        
         this.messagesContainer.appendChild(messageElement);
         this.scrollToBottom();
+
+        // Let the server know this message was displayed
+        if (message.id && this.isConnected) {
+            this.ws.send(JSON.stringify({
+                type: 'ack',
+                message_id: message.id,
+                conversation_id: conversationId
+            }));
+        }
         
         console.log('Message added to UI successfully');
     }
 
+    formatUsage(message) {
+        const custom = (message.metadata && message.metadata.custom) || {};
+        if (!custom.latency_ms) {
+            return '';
+        }
+        const parts = [`${(Number(custom.latency_ms) / 1000).toFixed(1)}s`];
+        const tokens = Number(custom.prompt_tokens || 0) + Number(custom.completion_tokens || 0);
+        if (tokens > 0) {
+            parts.push(`${tokens} tokens`);
+        }
+        if (custom.cost_usd) {
+            const cost = Number(custom.cost_usd);
+            parts.push(cost < 0.001 ? '<$0.001' : `$${cost.toFixed(3)}`);
+        }
+        return parts.join(' · ');
+    }
+
+    parseCitations(message) {
+        const custom = (message.metadata && message.metadata.custom) || {};
+        if (!custom.citations) {
+            return [];
+        }
+        try {
+            return JSON.parse(custom.citations);
+        } catch (error) {
+            console.warn('Ignoring malformed citations:', error);
+            return [];
+        }
+    }
+
+    createCitations(citations) {
+        const citationsElement = document.createElement('div');
+        citationsElement.className = 'message-citations';
+        citations.forEach(citation => {
+            const citationElement = document.createElement('div');
+            citationElement.className = 'message-citation';
+            citationElement.textContent = `[${citation.ref}] from “${citation.title || citation.conversation_id}”`;
+            citationElement.title = `Conversation ${citation.conversation_id}, message ${citation.message_id}`;
+            citationElement.dataset.conversationId = citation.conversation_id;
+            citationElement.dataset.messageId = citation.message_id;
+            citationsElement.appendChild(citationElement);
+        });
+        return citationsElement;
+    }
+
+        createFeedbackButtons(messageId, conversationId) {
+        const feedbackElement = document.createElement('div');
+        feedbackElement.className = 'message-feedback';
+
+        ['up', 'down'].forEach(rating => {
+            const button = document.createElement('button');
+            button.className = `feedback-button feedback-${rating}`;
+            button.dataset.rating = rating;
+            button.textContent = rating === 'up' ? '👍' : '👎';
+            button.addEventListener('click', () => {
+                if (!this.isConnected) {
+                    return;
+                }
+                this.ws.send(JSON.stringify({
+                    type: 'feedback',
+                    message_id: messageId,
+                    conversation_id: conversationId,
+                    rating: rating
+                }));
+                feedbackElement.querySelectorAll('.feedback-button').forEach(b => b.classList.toggle('selected', b === button));
+            });
+            feedbackElement.appendChild(button);
+        });
+
+        return feedbackElement;
+    }
+
+    showFeedback(feedback) {
+        const element = this.messagesContainer.querySelector(`[data-message-id="${CSS.escape(feedback.message_id)}"]`);
+        if (!element) {
+            return;
+        }
+        const up = element.querySelector('.feedback-up');
+        const down = element.querySelector('.feedback-down');
+        if (up && down) {
+            up.textContent = feedback.likes ? `👍 ${feedback.likes}` : '👍';
+            down.textContent = feedback.dislikes ? `👎 ${feedback.dislikes}` : '👎';
+        }
+    }
+
+    showPresence(event) {
+        const presenceElement = document.createElement('div');
+        presenceElement.className = 'presence-notice';
+        presenceElement.textContent = `${event.name} ${event.status === 'online' ? 'joined' : 'left'}`;
+        this.messagesContainer.appendChild(presenceElement);
+        this.scrollToBottom();
+    }
+
+    showScore(score) {
+        const custom = (score.metadata && score.metadata.custom) || {};
+        const element = this.messagesContainer.querySelector(`[data-message-id="${CSS.escape(custom.scored_message_id || '')}"]`);
+        if (!element) {
+            return;
+        }
+
+        const criteria = Object.keys(custom).filter(key => key !== 'scored_message_id' && key !== 'scored_agent_id');
+        const scoreElement = document.createElement('div');
+        scoreElement.className = 'message-score';
+        scoreElement.title = `Scored by ${score.metadata.from_agent || score.agent_id}`;
+        scoreElement.textContent = '⚖️ ' + criteria.map(key => `${key} ${custom[key]}/10`).join(' · ');
+        element.appendChild(scoreElement);
+    }
+
+    showReceipt(receipt) {
+        const element = this.messagesContainer.querySelector(`[data-message-id="${CSS.escape(receipt.message_id)}"]`);
+        if (!element) {
+            return;
+        }
+        let receiptsElement = element.querySelector('.message-receipts');
+        if (!receiptsElement) {
+            receiptsElement = document.createElement('div');
+            receiptsElement.className = 'message-receipts';
+            element.appendChild(receiptsElement);
+        }
+        const count = Number(receiptsElement.dataset.count || 0) + 1;
+        receiptsElement.dataset.count = count;
+        receiptsElement.textContent = `✓ Read by ${count}`;
+    }
+
+    async showWhiteboard(event) {
+        const custom = (event.metadata && event.metadata.custom) || {};
+        const version = Number(custom.version || 0);
+        if (version <= this.whiteboardVersion) {
+            return;
+        }
+        console.log(`Whiteboard edited by ${custom.author} (version ${version}):\n${event.content}`);
+
+        // The diff is for clients that track the text; fetching it is simpler and never drifts
+        const conversationId = (event.metadata && event.metadata.conversation_id) || 'main-conversation';
+        try {
+            const response = await fetch(`/api/conversations/${encodeURIComponent(conversationId)}/whiteboard`);
+            const { whiteboard } = await response.json();
+            if (!whiteboard || whiteboard.version <= this.whiteboardVersion) {
+                return;
+            }
+            this.whiteboardVersion = whiteboard.version;
+            this.whiteboardTitle.textContent = `📝 ${whiteboard.title || 'Whiteboard'} (v${whiteboard.version})`;
+            this.whiteboardTitle.title = custom.summary ? `${custom.author}: ${custom.summary}` : `Last edited by ${custom.author}`;
+            this.whiteboard.textContent = whiteboard.text;
+            this.whiteboardTitle.hidden = false;
+            this.whiteboard.hidden = false;
+        } catch (error) {
+            console.error('Failed to load the whiteboard:', error);
+        }
+    }
+
+    removeMessage(messageId) {
+        if (!messageId) {
+            return;
+        }
+        const element = this.messagesContainer.querySelector(`[data-message-id="${CSS.escape(messageId)}"]`);
+        if (element) {
+            element.remove();
+        }
+    }
+
     scrollToBottom() {
         this.messagesContainer.scrollTop = this.messagesContainer.scrollHeight;
     }